@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"net"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/activecm/rita/v5/config"
@@ -17,6 +17,10 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// ADAPTIVE_BEACON_MODIFIER_NAME is the name of the modifier given to beacons whose interval shifted part way through.
+// It is scored during analysis since the modifier phase doesn't have the timestamps of each connection.
+const ADAPTIVE_BEACON_MODIFIER_NAME = "adaptive_beacon"
+
 type Analyzer struct {
 	Database        *database.DB
 	ImportID        util.FixedString
@@ -29,9 +33,19 @@ type Analyzer struct {
 	maxTSBeacon     time.Time
 	minTSBeacon     time.Time
 	networkSize     uint64
-	useCurrentTime  bool
 	skipBeaconing   bool
-	firstSeenMaxTS  time.Time
+
+	// runs the scoop queries one at a time with a single analysis and writer worker, see analysisWorkers
+	deterministic bool
+
+	// optional country and ASN lookups for external destinations, nil when not configured
+	geoIP *GeoIPLookup
+
+	// limits analysis to the connections of these hosts, analyzes every connection when empty
+	HostFilter HostFilter
+
+	// number of connections whose duration was clamped before long connection scoring
+	clampedLongConns atomic.Uint64
 
 	writer *database.BulkWriter
 }
@@ -48,6 +62,10 @@ type ThreatMixtape struct {
 	Beacon
 	BeaconThreatScore float32 `ch:"beacon_threat_score"` // bucketed beacon score
 	BeaconType        string  `ch:"beacon_type"`
+	Allowlisted       bool    `ch:"allowlisted"` // destination is on the beacon allowlist
+
+	// GEOLOCATION of the external destination
+	Geolocation
 
 	//  LONG CONNECTIONS
 	LongConnScore float32 `ch:"long_conn_score"`
@@ -71,7 +89,7 @@ type ThreatMixtape struct {
 	ModifierValue string  `ch:"modifier_value"`
 
 	// modifiers that are able to be added to the same row as the threat indicator scores
-	// these are detected during the analysis phase (in the spagooper)
+	// prevalence and first seen are scored by the modifier package, the others during the analysis phase (in the spagooper)
 	PrevalenceScore          float32 `ch:"prevalence_score"`
 	FirstSeenScore           float32 `ch:"first_seen_score"`
 	ThreatIntelDataSizeScore float32 `ch:"threat_intel_data_size_score"`
@@ -79,7 +97,7 @@ type ThreatMixtape struct {
 }
 
 // NewAnalyzer returns a new Analyzer object
-func NewAnalyzer(db *database.DB, cfg *config.Config, importID util.FixedString, minTS, maxTS, minTSBeacon, maxTSBeacon time.Time, useCurrentTime bool, skipBeaconing bool) (*Analyzer, error) {
+func NewAnalyzer(db *database.DB, cfg *config.Config, importID util.FixedString, minTS, maxTS, minTSBeacon, maxTSBeacon time.Time, skipBeaconing bool, deterministic bool) (*Analyzer, error) {
 
 	// create a rate limiter to control the rate of writing to the database
 	limiter := rate.NewLimiter(5, 5)
@@ -87,38 +105,57 @@ func NewAnalyzer(db *database.DB, cfg *config.Config, importID util.FixedString,
 	if err != nil {
 		return nil, err
 	}
-	var firstSeenMaxTS time.Time
-	if !useCurrentTime {
-		firstSeenMaxTS = maxTS
+	// warn when the minimum beacon duration is longer than the beacon window, since no beacons can be scored
+	if !skipBeaconing {
+		warnMinBeaconDurationExceedsSpan(&cfg.Scoring.Beacon, maxTSBeacon.Sub(minTSBeacon))
+	}
+
+	geoIP, err := NewGeoIPLookup(cfg.GeoIP)
+	if err != nil {
+		return nil, err
 	}
 
-	workers := int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+	workers := analysisWorkers(deterministic)
 	return &Analyzer{
 		Database:        db,
 		Config:          cfg,
 		ImportID:        importID,
 		AnalysisWorkers: workers,
 		WriterWorkers:   workers,
-		useCurrentTime:  useCurrentTime,
 		maxTS:           maxTS,
 		minTS:           minTS,
 		maxTSBeacon:     maxTSBeacon,
 		minTSBeacon:     minTSBeacon,
-		firstSeenMaxTS:  firstSeenMaxTS,
 		skipBeaconing:   skipBeaconing,
+		deterministic:   deterministic,
 		networkSize:     networkSize,
+		geoIP:           geoIP,
 		UconnChan:       make(chan AnalysisResult),
 		writer:          database.NewBulkWriter(db, cfg, workers, db.GetSelectedDB(), "threat_mixtape", "INSERT INTO {database:Identifier}.threat_mixtape", limiter, false),
 	}, nil
 }
 
+// analysisWorkers returns the number of analysis and writer workers to use. A deterministic analysis uses a single
+// worker of each so that the results are scored and written in the order that they were scooped.
+func analysisWorkers(deterministic bool) int {
+	if deterministic {
+		return 1
+	}
+	return int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+}
+
 func (analyzer *Analyzer) Analyze() error {
-	logger := zlog.GetLogger()
+	logger := zlog.WithImport(analyzer.Database.GetSelectedDB(), analyzer.ImportID.Hex())
 
 	// log the start time of the analysis
 	start := time.Now()
 	logger.Debug().Msg("Starting Analysis")
 
+	// read the connections on a single thread so that the scoop queries return them in the same order every time
+	if analyzer.deterministic {
+		analyzer.Database = analyzer.Database.SingleThreaded()
+	}
+
 	// create an error group to manage the analysis threads
 	analysisErrGroup, ctx := errgroup.WithContext(context.Background())
 
@@ -136,24 +173,37 @@ func (analyzer *Analyzer) Analyze() error {
 	}
 
 	// start spagooper to feed anlysis threads
-	err := analyzer.Spagoop(ctx)
-	if err != nil {
-		return fmt.Errorf("could not perform spagoop analysis: %w", err)
-	}
+	spagoopErr := analyzer.Spagoop(ctx)
+
+	// wait for all analysis threads to finish, spagoop closes the uconn channel even if it fails, so the threads
+	// always finish with the results that were sent to them
+	analysisErr := analysisErrGroup.Wait()
+
+	// close the mixtape writer and the geoip databases on every exit path, the writer is only closed once the analysis
+	// threads have stopped writing to it
+	writerErr := analyzer.writer.Close()
+	analyzer.geoIP.Close()
 
-	// wait for all analysis threads to finish
-	if err := analysisErrGroup.Wait(); err != nil {
-		logger.Fatal().Err(err).Msg("could not perform beacon analysis")
-		return err
+	if spagoopErr != nil {
+		return fmt.Errorf("could not perform spagoop analysis: %w", spagoopErr)
+	}
+	if analysisErr != nil {
+		logger.Error().Err(analysisErr).Msg("could not perform beacon analysis")
+		return analysisErr
+	}
+	if writerErr != nil {
+		return fmt.Errorf("could not write analysis results: %w", writerErr)
 	}
 
-	// close the mixtape writer
-	analyzer.writer.Close()
+	if clamped := analyzer.clampedLongConns.Load(); clamped > 0 {
+		logger.Warn().Uint64("connections", clamped).Str("dataset_span", analyzer.maxTS.Sub(analyzer.minTS).String()).
+			Msg("Clamped the duration of connections that were longer than plausible for long connection scoring")
+	}
 
 	// log the end time of the analysis
 	end := time.Now()
 	diff := time.Since(start)
-	logger.Info().Str("elapsed_time", diff.String()).Time("analysis_began", start).Time("analysis_finished", end).Msg("Finished Analysis! 🎉")
+	logger.Info().Str("phase", "analysis").Dur("duration", diff).Str("elapsed_time", diff.String()).Time("analysis_began", start).Time("analysis_finished", end).Msg("Finished Analysis! 🎉")
 
 	return nil
 }
@@ -198,12 +248,19 @@ func (analyzer *Analyzer) runAnalysis() error {
 
 		hasThreatIndicator := false
 
+		// modifier row for beacons whose interval shifted, written after the row that it modifies
+		var adaptiveBeacon *ThreatMixtape
+
 		// C2 OVER DNS
 		if entry.TLD != "" && entry.SubdomainCount > 0 {
+			// DNS entries are only scored for C2 over DNS
+			if !analyzer.Config.ModuleEnabled(config.ModuleC2OverDNS) {
+				continue
+			}
 			// run c2 over dns analysis on entry if the TLD is a known c2 domain
 			c2OverDNSScore := calculateBucketedScore(float64(entry.SubdomainCount), analyzer.Config.Scoring.C2ScoreThresholds)
 
-			hash, err := util.NewFixedStringHash(entry.TLD)
+			hash, err := util.NewBeaconHash("dns", util.ConnectionIdentity{FQDN: entry.TLD})
 			if err != nil {
 				logger.Debug().Str("src", entry.Src.String()).Str("fqdn", entry.FQDN).Msg("could not create hash from TLD")
 			}
@@ -212,20 +269,18 @@ func (analyzer *Analyzer) runAnalysis() error {
 			if entry.SubdomainCount >= uint64(analyzer.Config.Scoring.C2ScoreThresholds.Base) {
 				hasThreatIndicator = true
 				mixtape.C2OverDNSScore = c2OverDNSScore
-				// run c2 over dns direct connection analysis
-				if shouldHaveC2OverDNSDirectConnModifier(entry.DirectConns, entry.QueriedBy) {
-					mixtape.C2OverDNSDirectConnScore = analyzer.Config.Modifiers.C2OverDNSDirectConnScoreIncrease
-				}
 			}
 
 		} else {
 
 			// ALL OTHER THREAT INDICATORS
 			// Run beaconing as long as there are min/max beacon timestamps
-			if !analyzer.skipBeaconing {
+			if !analyzer.skipBeaconing && analyzer.Config.ModuleEnabled(config.ModuleBeacons) {
 				// run beacon analysis on entry if there are enough unique connections and the overall connection count is less than a strobe (1 connection per second)
 
-				if entry.TSUnique >= uint64(analyzer.Config.Scoring.Beacon.UniqueConnectionThreshold) && entry.Count < 86400 {
+				// connections that weren't observed for long enough, like short-lived scans, are not beacon candidates
+				if entry.TSUnique >= uint64(analyzer.Config.Scoring.Beacon.GetUniqueConnectionThreshold(entry.BeaconType)) && entry.Count < 86400 &&
+					getObservedSpan(entry.TSList) >= analyzer.Config.Scoring.Beacon.GetMinBeaconDuration(entry.BeaconType) {
 					beacon, err := analyzer.analyzeBeacon(&entry)
 					if err != nil {
 						continue // all the errors will get logged in the beacon analyzer so we get a line number
@@ -234,23 +289,51 @@ func (analyzer *Analyzer) runAnalysis() error {
 					hasThreatIndicator = true
 					mixtape.Beacon = beacon
 					mixtape.BeaconThreatScore = beaconThreatScore
+
+					// a beacon that changes its interval part way through averages out to a middling timestamp score
+					adaptiveBeacon = analyzer.getAdaptiveBeaconModifier(mixtape)
 				}
 			}
 
-			// run long connection analysis on entry if the total duration is greater than the minimum duration threshold
-			if entry.TotalDuration >= float64(analyzer.Config.Scoring.LongConnectionScoreThresholds.Base) {
-				longConnScore := calculateBucketedScore(entry.TotalDuration, analyzer.Config.Scoring.LongConnectionScoreThresholds)
-				hasThreatIndicator = true
-				mixtape.LongConnScore = longConnScore
+			// the connections of distributed, per-port, and parent domain beacons are already scored on their own for every
+			// other indicator
+			pooled := entry.BeaconType == "distributed" || entry.BeaconType == "ip_port" || entry.BeaconType == "sni_parent"
+
+			// run long connection analysis on entry if the total duration is greater than the minimum duration threshold, the
+			// scoop queries already bounded the duration of each connection to the plausible durations before summing them
+			if !pooled && analyzer.Config.ModuleEnabled(config.ModuleLongConnections) {
+				if entry.ClampedDurationCount > 0 {
+					logger.Debug().
+						Str("src", entry.Src.String()).
+						Str("dst", entry.Dst.String()).
+						Str("fqdn", entry.FQDN).
+						Uint64("clamped_connections", entry.ClampedDurationCount).
+						Float64("total_duration", entry.TotalDuration).Msg("connections were longer than plausible, clamped them")
+					analyzer.clampedLongConns.Add(entry.ClampedDurationCount)
+				}
+
+				if entry.TotalDuration >= float64(analyzer.Config.Scoring.LongConnectionScoreThresholds.Base) {
+					longConnScore := calculateBucketedScore(entry.TotalDuration, analyzer.Config.Scoring.LongConnectionScoreThresholds)
+					hasThreatIndicator = true
+					mixtape.LongConnScore = longConnScore
+				}
 			}
 
 			// record entry as a strobe if the overall connection count meets the strobe threshold (1 connection per second)
-			if entry.Count >= 86400 {
+			if !pooled && analyzer.Config.ModuleEnabled(config.ModuleStrobes) && entry.Count >= 86400 {
 				hasThreatIndicator = true
 				mixtape.Strobe = true
 				mixtape.StrobeScore = analyzer.Config.Scoring.StrobeImpact.Score
 			}
 
+			// flag known good destinations so that they can be left out of exports, they are still scored
+			mixtape.Allowlisted = analyzer.Config.Filter.CheckIfBeaconAllowlisted(entry.Dst, entry.FQDN)
+
+			// annotate the external destination with its country and ASN
+			if analyzer.geoIP != nil {
+				mixtape.Geolocation = analyzer.geoIP.Lookup(externalDestination(&entry, &analyzer.Config.Filter))
+			}
+
 			// MODIFIERS
 			// due to performance impact, these modifiers are scored here instead of in the modifier package
 			// MISSING HOST HEADER MODIFIER
@@ -259,7 +342,7 @@ func (analyzer *Analyzer) runAnalysis() error {
 			}
 
 			// Threat Intel Data Size Score
-			if entry.OnThreatIntel {
+			if analyzer.Config.ModuleEnabled(config.ModuleThreatIntel) && entry.OnThreatIntel {
 				if entry.TotalBytes >= analyzer.Config.Modifiers.ThreatIntelDataSizeThreshold {
 					mixtape.ThreatIntelDataSizeScore = analyzer.Config.Modifiers.ThreatIntelScoreIncrease
 				}
@@ -269,44 +352,76 @@ func (analyzer *Analyzer) runAnalysis() error {
 
 		if hasThreatIndicator {
 
-			// Modifiers that apply to all connection types
-			// first seen scoring
-			// use the current time to score against unless useCurrentTime is false
-			relativeTime := util.GetRelativeFirstSeenTimestamp(analyzer.useCurrentTime, analyzer.firstSeenMaxTS)
-			timeSince := relativeTime.Sub(entry.FirstSeenHistorical)
-			daysSinceFirstSeen := float32(timeSince.Hours() / 24)
-
-			// Historical First Seen Scoring
-			// only apply to rolling datasets
-			if analyzer.Database.Rolling {
-				if daysSinceFirstSeen <= analyzer.Config.Modifiers.FirstSeenIncreaseThreshold {
-					mixtape.FirstSeenScore = analyzer.Config.Modifiers.FirstSeenScoreIncrease
-				} else if daysSinceFirstSeen >= analyzer.Config.Modifiers.FirstSeenDecreaseThreshold {
-					mixtape.FirstSeenScore = -1 * analyzer.Config.Modifiers.FirstSeenScoreDecrease
-				}
-			}
-
-			// Prevalence Scoring
-			if entry.Prevalence <= analyzer.Config.Modifiers.PrevalenceIncreaseThreshold {
-				mixtape.PrevalenceScore = analyzer.Config.Modifiers.PrevalenceScoreIncrease
-			} else if entry.Prevalence >= analyzer.Config.Modifiers.PrevalenceDecreaseThreshold {
-				mixtape.PrevalenceScore = -1 * analyzer.Config.Modifiers.PrevalenceScoreDecrease
-			}
-
-			// record entry as a threat intel if the entry is marked as threat intel
-			if entry.OnThreatIntel {
+			// record entry as a threat intel if the entry is marked as threat intel, the threat intel, prevalence, first
+			// seen, and C2 over DNS direct connection scores are added by their modifiers in the modifier package
+			if analyzer.Config.ModuleEnabled(config.ModuleThreatIntel) && entry.OnThreatIntel {
 				mixtape.ThreatIntel = true
-				mixtape.ThreatIntelScore = analyzer.Config.Scoring.ThreatIntelImpact.Score
 			}
 
 			// check to see if any of the workers cancelled before sending another entry to the writer
 			analyzer.writer.WriteChannel <- mixtape
+
+			if adaptiveBeacon != nil {
+				analyzer.writer.WriteChannel <- adaptiveBeacon
+			}
 		}
 	}
 
 	return nil
 }
 
+// getAdaptiveBeaconModifier returns a modifier row for the beacon if the most frequent interval between its connections
+// shifted part way through, or nil if it didn't or the modifier is disabled. The row only identifies the beacon so that
+// it is grouped with the beacon's result like the rows written during the modifier phase.
+func (analyzer *Analyzer) getAdaptiveBeaconModifier(mixtape *ThreatMixtape) *ThreatMixtape {
+	modifiers := analyzer.Config.Modifiers
+	if modifiers.AdaptiveBeaconScoreIncrease == 0 {
+		return nil
+	}
+
+	from, to, drifted := getCadenceDrift(widenTimestamps(mixtape.TSList), int(modifiers.AdaptiveBeaconSegments),
+		float64(modifiers.AdaptiveBeaconIntervalRatio), analyzer.Config.Scoring.Beacon.TsJitterTolerance,
+		analyzer.Config.Scoring.Beacon.ScorePrecision,
+	)
+	if !drifted {
+		return nil
+	}
+
+	return &ThreatMixtape{
+		AnalyzedAt: mixtape.AnalyzedAt,
+		ImportID:   mixtape.ImportID,
+		AnalysisResult: AnalysisResult{
+			Hash:     mixtape.Hash,
+			Src:      mixtape.Src,
+			SrcNUID:  mixtape.SrcNUID,
+			Dst:      mixtape.Dst,
+			DstNUID:  mixtape.DstNUID,
+			FQDN:     mixtape.FQDN,
+			LastSeen: mixtape.LastSeen,
+		},
+		ModifierName:  ADAPTIVE_BEACON_MODIFIER_NAME,
+		ModifierScore: modifiers.AdaptiveBeaconScoreIncrease,
+		ModifierValue: fmt.Sprintf("%ds to %ds", from, to),
+	}
+}
+
+// warnMinBeaconDurationExceedsSpan logs a warning for each beacon type whose minimum beacon duration is longer than
+// the span of the data being analyzed, and returns the beacon types that can't be scored
+func warnMinBeaconDurationExceedsSpan(beaconCfg *config.Beacon, span time.Duration) []string {
+	logger := zlog.GetLogger()
+
+	var exceeded []string
+	for _, beaconType := range []string{"ip", "sni"} {
+		minDuration := beaconCfg.GetMinBeaconDuration(beaconType)
+		if minDuration > span {
+			logger.Warn().Str("beacon_type", beaconType).Str("min_beacon_duration", minDuration.String()).Str("dataset_span", span.String()).
+				Msg("The minimum beacon duration is longer than the span of the dataset, no beacons of this type will be scored")
+			exceeded = append(exceeded, beaconType)
+		}
+	}
+	return exceeded
+}
+
 func calculateBucketedScore(value float64, thresholds config.ScoreThresholds) float32 {
 	base := float64(thresholds.Base)
 	low := float64(thresholds.Low)
@@ -339,23 +454,3 @@ func calculateBucketedScore(value float64, thresholds config.ScoreThresholds) fl
 	}
 	return score / 100
 }
-
-// shouldHaveC2OverDNSDirectConnModifier returns true if no ips other than the ones in queriedby made connections to this domain
-func shouldHaveC2OverDNSDirectConnModifier(directConns, queriedBy []net.IP) bool {
-	if len(queriedBy) > 0 {
-		queried := make(map[string]struct{})
-		for _, ip := range queriedBy {
-			queried[ip.String()] = struct{}{}
-		}
-
-		// check for any ips in direct conns that aren't in queried by
-		for _, ip := range directConns {
-			if _, ok := queried[ip.String()]; !ok {
-				return false
-			}
-		}
-
-	}
-	// apply direct conn modifier if no ips other than the ones in queried by made connections to this domain
-	return true
-}
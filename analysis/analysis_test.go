@@ -2,10 +2,15 @@ package analysis
 
 import (
 	"log"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
@@ -101,3 +106,295 @@ func TestCalculateBucketedScore(t *testing.T) {
 		})
 	}
 }
+
+func TestWarnMinBeaconDurationExceedsSpan(t *testing.T) {
+	beaconCfg := config.Beacon{
+		MinBeaconDurationHours:        2,
+		MinBeaconDurationHoursPerType: config.BeaconTypeDurations{SNI: 12},
+	}
+
+	require.Empty(t, warnMinBeaconDurationExceedsSpan(&beaconCfg, 24*time.Hour), "a full day of data should be able to score every beacon type")
+	require.Equal(t, []string{"sni"}, warnMinBeaconDurationExceedsSpan(&beaconCfg, 6*time.Hour))
+	require.Equal(t, []string{"ip", "sni"}, warnMinBeaconDurationExceedsSpan(&beaconCfg, time.Hour))
+	require.Empty(t, warnMinBeaconDurationExceedsSpan(&config.Beacon{}, 0), "no minimum duration should never warn")
+}
+
+func TestMaxPlausibleDuration(t *testing.T) {
+	span := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		bounds   config.DurationBounds
+		span     time.Duration
+		expected float64
+	}{
+		{name: "Dataset Span", span: span, expected: span.Seconds()},
+		{name: "Configured Maximum", bounds: config.DurationBounds{Max: 43200}, span: span, expected: 43200},
+		{name: "Configured Maximum Replaces Dataset Span", bounds: config.DurationBounds{Max: 172800}, span: span, expected: 172800},
+		{name: "Unknown Dataset Span", expected: 0},
+		{name: "Negative Dataset Span", span: -time.Hour, expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.InDelta(t, test.expected, maxPlausibleDuration(test.bounds, test.span), 0.0001, "maximum duration should match expected value")
+		})
+	}
+}
+
+func TestRunAnalysisCountsClampedLongConns(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	// the scoop queries bound the duration of each connection, so three connections that each claimed to last longer
+	// than the 2 hours of data in the dataset are totaled as 2 hours each
+	analyzer := &Analyzer{
+		Database:  &database.DB{},
+		Config:    &cfg,
+		UconnChan: make(chan AnalysisResult, 1),
+		writer:    &database.BulkWriter{WriteChannel: make(chan database.Data, 1)},
+	}
+	analyzer.UconnChan <- AnalysisResult{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("1.1.1.1"), Count: 3, TSUnique: 3, TotalDuration: 3 * 7200, ClampedDurationCount: 3}
+	close(analyzer.UconnChan)
+
+	require.NoError(t, analyzer.runAnalysis())
+	close(analyzer.writer.WriteChannel)
+
+	var results []*ThreatMixtape
+	for data := range analyzer.writer.WriteChannel {
+		results = append(results, data.(*ThreatMixtape))
+	}
+	require.Len(t, results, 1)
+	require.InDelta(t, calculateBucketedScore(3*7200, cfg.Scoring.LongConnectionScoreThresholds), results[0].LongConnScore, 0.0001, "the bounded duration should be scored")
+	require.EqualValues(t, 3, analyzer.clampedLongConns.Load(), "the clamped connections should be counted")
+}
+
+func TestRunAnalysisModules(t *testing.T) {
+	strobe := AnalysisResult{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("1.1.1.1"), Count: 90000, TSUnique: 86400, OnThreatIntel: true, Prevalence: 0.01}
+	longConn := AnalysisResult{Src: net.ParseIP("10.0.0.2"), Dst: net.ParseIP("1.1.1.2"), Count: 1, TSUnique: 1, TotalDuration: 100000}
+	c2OverDNS := AnalysisResult{Src: net.ParseIP("10.0.0.3"), TLD: "example.com", FQDN: "example.com", SubdomainCount: 1000}
+
+	// runs the analysis on the entries and returns the mixtape entries that would have been written
+	analyze := func(t *testing.T, cfg *config.Config) []*ThreatMixtape {
+		t.Helper()
+		analyzer := &Analyzer{
+			Database:  &database.DB{},
+			Config:    cfg,
+			UconnChan: make(chan AnalysisResult, 3),
+			writer:    &database.BulkWriter{WriteChannel: make(chan database.Data, 3)},
+		}
+		analyzer.UconnChan <- strobe
+		analyzer.UconnChan <- longConn
+		analyzer.UconnChan <- c2OverDNS
+		close(analyzer.UconnChan)
+
+		require.NoError(t, analyzer.runAnalysis())
+		close(analyzer.writer.WriteChannel)
+
+		var results []*ThreatMixtape
+		for data := range analyzer.writer.WriteChannel {
+			results = append(results, data.(*ThreatMixtape))
+		}
+		return results
+	}
+
+	t.Run("Disabled Modules", func(t *testing.T) {
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+		cfg.DisabledModules = []string{config.ModuleBeacons, config.ModuleThreatIntel, config.ModuleC2OverDNS}
+
+		results := analyze(t, &cfg)
+		require.Len(t, results, 2, "the c2 over dns entry should be skipped")
+
+		require.True(t, results[0].Strobe, "disabling beacons should still record strobes")
+		require.False(t, results[0].ThreatIntel)
+
+		require.Positive(t, results[1].LongConnScore)
+	})
+
+	t.Run("Enabled Modules", func(t *testing.T) {
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+		cfg.EnabledModules = []string{config.ModuleStrobes, config.ModuleThreatIntel}
+
+		results := analyze(t, &cfg)
+		require.Len(t, results, 1, "only strobes should be recorded")
+
+		require.True(t, results[0].Strobe)
+		require.True(t, results[0].ThreatIntel)
+		require.Zero(t, results[0].ThreatIntelScore, "threat intel should be scored by its modifier")
+	})
+}
+
+func TestRunAnalysisDistributedConns(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	analyzer := &Analyzer{
+		Database:  &database.DB{},
+		Config:    &cfg,
+		UconnChan: make(chan AnalysisResult, 1),
+		writer:    &database.BulkWriter{WriteChannel: make(chan database.Data, 1)},
+	}
+
+	// the pool's connections are already scored on their own as strobes and long connections
+	analyzer.UconnChan <- AnalysisResult{
+		Src: net.ParseIP("10.0.0.1"), Dst: net.IPv6unspecified, BeaconType: "distributed",
+		ServerIPs: []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("1.1.1.2"), net.ParseIP("1.1.1.3")},
+		Count:     90000, TSUnique: 1, TotalDuration: 100000, PortProtoService: []string{"443:tcp:ssl"},
+	}
+	close(analyzer.UconnChan)
+
+	require.NoError(t, analyzer.runAnalysis())
+	close(analyzer.writer.WriteChannel)
+	require.Empty(t, analyzer.writer.WriteChannel, "distributed connections should only be scored as beacons")
+}
+
+func TestRunAnalysisAdaptiveBeacon(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	// the beacon connects every 60 seconds for 4 hours, then slows down to every 300 seconds for 4 more
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tsList := []uint32{uint32(start.Unix())}
+	for i := 0; i < 240; i++ {
+		tsList = append(tsList, tsList[len(tsList)-1]+60)
+	}
+	for i := 0; i < 48; i++ {
+		tsList = append(tsList, tsList[len(tsList)-1]+300)
+	}
+	bytes := make([]float64, len(tsList))
+	for i := range bytes {
+		bytes[i] = 512
+	}
+
+	beacon := AnalysisResult{
+		Hash: util.FixedString{Data: [16]byte{1}}, Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("1.1.1.1"), BeaconType: "ip",
+		Count: uint64(len(tsList)), TSUnique: uint64(len(tsList)), TSList: tsList, BytesList: bytes, DstBytesList: bytes,
+		LastSeen: time.Unix(int64(tsList[len(tsList)-1]), 0),
+	}
+
+	analyze := func(t *testing.T, cfg *config.Config) []*ThreatMixtape {
+		t.Helper()
+		analyzer := &Analyzer{
+			Database:    &database.DB{},
+			Config:      cfg,
+			UconnChan:   make(chan AnalysisResult, 1),
+			writer:      &database.BulkWriter{WriteChannel: make(chan database.Data, 2)},
+			minTSBeacon: start,
+			maxTSBeacon: start.Add(24 * time.Hour),
+		}
+		analyzer.UconnChan <- beacon
+		close(analyzer.UconnChan)
+
+		require.NoError(t, analyzer.runAnalysis())
+		close(analyzer.writer.WriteChannel)
+
+		var results []*ThreatMixtape
+		for data := range analyzer.writer.WriteChannel {
+			results = append(results, data.(*ThreatMixtape))
+		}
+		return results
+	}
+
+	t.Run("Cadence Change Is Flagged", func(t *testing.T) {
+		results := analyze(t, &cfg)
+		require.Len(t, results, 2, "the beacon should be written along with its modifier")
+		require.Positive(t, results[0].Beacon.Score, "the beacon should be scored")
+		require.Empty(t, results[0].ModifierName)
+
+		modifier := results[1]
+		require.Equal(t, ADAPTIVE_BEACON_MODIFIER_NAME, modifier.ModifierName)
+		require.InDelta(t, cfg.Modifiers.AdaptiveBeaconScoreIncrease, modifier.ModifierScore, 0.00001)
+		require.Equal(t, "60s to 300s", modifier.ModifierValue)
+		require.Equal(t, results[0].Hash, modifier.Hash, "the modifier should be grouped with the beacon's result")
+		require.Equal(t, results[0].LastSeen, modifier.LastSeen, "the modifier should be grouped with the beacon's result")
+		require.Empty(t, modifier.TSList, "the modifier row should only identify the beacon")
+		require.Zero(t, modifier.Count, "the modifier row should only identify the beacon")
+	})
+
+	t.Run("Modifier Disabled", func(t *testing.T) {
+		disabled := cfg
+		disabled.Modifiers.AdaptiveBeaconScoreIncrease = 0
+
+		results := analyze(t, &disabled)
+		require.Len(t, results, 1, "only the beacon should be written")
+	})
+}
+
+func TestDistributedHash(t *testing.T) {
+	src := net.ParseIP("10.0.0.1")
+
+	hash, err := distributedHash(src, uuid.Nil, []string{"443:tcp:ssl"})
+	require.NoError(t, err)
+
+	same, err := distributedHash(src, uuid.Nil, []string{"443:tcp:ssl"})
+	require.NoError(t, err)
+	require.Equal(t, hash, same, "the same pool should always have the same hash")
+
+	otherPort, err := distributedHash(src, uuid.Nil, []string{"8443:tcp:ssl"})
+	require.NoError(t, err)
+	require.NotEqual(t, hash, otherPort, "pools on different ports should have different hashes")
+
+	otherSrc, err := distributedHash(net.ParseIP("10.0.0.2"), uuid.Nil, []string{"443:tcp:ssl"})
+	require.NoError(t, err)
+	require.NotEqual(t, hash, otherSrc, "pools of different sources should have different hashes")
+}
+
+func TestPortHash(t *testing.T) {
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+
+	hash, err := portHash(src, uuid.Nil, dst, uuid.Nil, []string{"443:tcp:ssl"})
+	require.NoError(t, err)
+
+	sameService, err := portHash(src, uuid.Nil, dst, uuid.Nil, []string{"443:tcp:http"})
+	require.NoError(t, err)
+	require.Equal(t, hash, sameService, "connections on the same port and protocol should have the same hash")
+
+	otherPort, err := portHash(src, uuid.Nil, dst, uuid.Nil, []string{"8443:tcp:ssl"})
+	require.NoError(t, err)
+	require.NotEqual(t, hash, otherPort, "connections on different ports should have different hashes")
+
+	connHash, err := util.NewFixedStringHash(src.To16().String() + uuid.Nil.String() + dst.To16().String() + uuid.Nil.String())
+	require.NoError(t, err)
+	require.NotEqual(t, connHash, hash, "per-port results should not replace the result for the connection as a whole")
+
+	_, err = portHash(src, uuid.Nil, dst, uuid.Nil, nil)
+	require.Error(t, err, "connections without a port can't be hashed")
+}
+
+func TestGroupSubdomains(t *testing.T) {
+	subdomains, parents := groupSubdomains([]string{
+		"b.cdn.example.com", "A.example.com", // grouped under example.com
+		"only.example.org",                   // the only subdomain of its parent domain
+		"x.example.co.uk", "y.example.co.uk", // grouped under the registrable domain, not the public suffix
+		"example.com", "com", "10.0.0.1", "", // no parent domain
+	})
+	// the subdomains are sorted by parent domain so that the query parameters are stable
+	require.Equal(t, []string{"x.example.co.uk", "y.example.co.uk", "A.example.com", "b.cdn.example.com"}, subdomains)
+	require.Equal(t, []string{"example.co.uk", "example.co.uk", "example.com", "example.com"}, parents)
+
+	subdomains, parents = groupSubdomains(nil)
+	require.Empty(t, subdomains)
+	require.Empty(t, parents)
+}
+
+func TestParentDomainHash(t *testing.T) {
+	src := net.ParseIP("10.0.0.1")
+
+	hash, err := parentDomainHash(src, uuid.Nil, "example.com")
+	require.NoError(t, err)
+
+	otherParent, err := parentDomainHash(src, uuid.Nil, "example.org")
+	require.NoError(t, err)
+	require.NotEqual(t, hash, otherParent, "different parent domains should have different hashes")
+
+	sniHash, err := util.NewFixedStringHash(src.To16().String(), uuid.Nil.String(), "example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, sniHash, hash, "parent domain results should not replace the result for a connection to the domain itself")
+}
+
+func TestAnalysisWorkers(t *testing.T) {
+	require.Equal(t, 1, analysisWorkers(true), "a deterministic analysis should use a single worker")
+	require.GreaterOrEqual(t, analysisWorkers(false), 4, "analysis should use at least 4 workers")
+}
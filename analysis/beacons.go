@@ -6,28 +6,46 @@ import (
 	"math"
 	"slices"
 	"sort"
+	"time"
 
+	"github.com/activecm/rita/v5/config"
 	zlog "github.com/activecm/rita/v5/logger"
-	"github.com/activecm/rita/v5/util"
 
 	"github.com/montanaflynn/stats"
 )
 
 var ErrInvalidDatasetTimeRange = errors.New("invalid dataset timerange: min ts is greater than or equal to max ts")
 var ErrInputSliceEmpty = errors.New("input slice must not be empty")
+var ErrInputSliceTooShort = errors.New("input slice must not contain fewer than 3 elements")
 
 type Beacon struct {
-	BeaconType     string  `ch:"beacon_type"` // (sni, ip)
+	BeaconType     string  `ch:"beacon_type"` // (sni, ip, rdp, distributed, ip_port)
 	Score          float32 `ch:"beacon_score"`
 	TimestampScore float32 `ch:"ts_score"`
 	DataSizeScore  float32 `ch:"ds_score"`
 	HistogramScore float32 `ch:"hist_score"`
 	DurationScore  float32 `ch:"dur_score"`
 
-	TSIntervals      []int64 `ch:"ts_intervals"`
-	TSIntervalCounts []int64 `ch:"ts_interval_counts"`
-	DSSizes          []int64 `ch:"ds_sizes"`
-	DSCounts         []int64 `ch:"ds_size_counts"`
+	TSIntervals      []int64   `ch:"ts_intervals"`
+	TSIntervalCounts []int64   `ch:"ts_interval_counts"`
+	TSIntervalP50    int64     `ch:"ts_interval_p50"`
+	TSIntervalP90    int64     `ch:"ts_interval_p90"`
+	TSIntervalP99    int64     `ch:"ts_interval_p99"`
+	DSSizes          []int64   `ch:"ds_sizes"`
+	DSCounts         []int64   `ch:"ds_size_counts"`
+	HistBinEdges     []float64 `ch:"hist_bin_edges"`
+	HistCounts       []int64   `ch:"hist_counts"`
+}
+
+// Histogram is the distribution of connections between a host pair over the beacon time span, the counts
+// can be used to graph the number of connections in each bin
+type Histogram struct {
+	BinEdges   []float64       `json:"bin_edges"`   // unix timestamps of the edges of each bin, has one more entry than counts
+	Counts     []int           `json:"counts"`      // number of connections in each bin
+	FreqCount  map[int32]int32 `json:"freq_count"`  // number of bins (value) for each bar height (key)
+	TotalBars  int             `json:"total_bars"`  // number of bins with at least one connection
+	LongestRun int             `json:"longest_run"` // longest run of consecutive non-empty bins, including wrap around
+	Score      float64         `json:"score"`
 }
 
 func (analyzer *Analyzer) analyzeBeacon(entry *AnalysisResult) (Beacon, error) {
@@ -40,24 +58,35 @@ func (analyzer *Analyzer) analyzeBeacon(entry *AnalysisResult) (Beacon, error) {
 		return beacon, ErrInvalidDatasetTimeRange
 	}
 
+	// widen the timestamps so that the calculations on them can't overflow
+	tsList := widenTimestamps(entry.TSList)
+
 	// calculate timestamp scores and metrics (unused fields are used by the test functions)
-	tsScore, _, _, intervals, intervalCounts, _, _, err := getTimestampScore(entry.TSList)
+	tsScore, _, _, intervals, intervalCounts, _, _, err := getTimestampScore(tsList, analyzer.Config.Scoring.Beacon.TsJitterTolerance, analyzer.Config.Scoring.Beacon.ScorePrecision)
+	if err != nil {
+		logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
+		return beacon, err
+	}
+
+	// calculate the percentiles of the intervals to describe the cadence of the beacon
+	intervalPercentiles, err := getIntervalPercentiles(intervals, intervalCounts, 50, 90, 99)
 	if err != nil {
 		logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
 		return beacon, err
 	}
 
-	// calculate data size scores and metrics
-	dsScore, _, _, dsSizes, dsCounts, _, _, err := getDataSizeScore(entry.BytesList)
+	// calculate data size scores and metrics in the configured direction
+	dsScore, dsSizes, dsCounts, err := getDirectionalDataSizeScore(entry.BytesList, entry.DstBytesList, analyzer.Config.Scoring.Beacon.DsDirection, analyzer.Config.Scoring.Beacon.DsOutlierTrimPercent, analyzer.Config.Scoring.Beacon.ScorePrecision)
 	if err != nil {
 		logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
 		return beacon, err
 	}
 
 	// calculate histogram score (note: we currently look at a 24 hour period)
-	_, _, totalBars, longestRun, histScore, err := getHistogramScore(
-		analyzer.minTSBeacon.Unix(), analyzer.maxTSBeacon.Unix(), entry.TSList, analyzer.Config.Scoring.Beacon.HistModeSensitivity,
+	hist, err := GetHistogramScore(
+		analyzer.minTSBeacon.Unix(), analyzer.maxTSBeacon.Unix(), tsList, analyzer.Config.Scoring.Beacon.HistModeSensitivity,
 		analyzer.Config.Scoring.Beacon.HistBimodalOutlierRemoval, analyzer.Config.Scoring.Beacon.HistBimodalMinHours, 24,
+		analyzer.Config.Scoring.Beacon.ScorePrecision,
 	)
 	if err != nil {
 		logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
@@ -66,19 +95,45 @@ func (analyzer *Analyzer) analyzeBeacon(entry *AnalysisResult) (Beacon, error) {
 
 	// calculate duration score
 	_, _, durScore, err := getDurationScore(
-		analyzer.minTSBeacon.Unix(), analyzer.maxTSBeacon.Unix(), int64(entry.TSList[0]), int64(entry.TSList[len(entry.TSList)-1]),
-		totalBars, longestRun, analyzer.Config.Scoring.Beacon.DurMinHours, analyzer.Config.Scoring.Beacon.DurIdealNumberOfConsistentHours,
+		analyzer.minTSBeacon.Unix(), analyzer.maxTSBeacon.Unix(), tsList[0], tsList[len(tsList)-1],
+		hist.TotalBars, hist.LongestRun, analyzer.Config.Scoring.Beacon.DurMinHours, analyzer.Config.Scoring.Beacon.DurIdealNumberOfConsistentHours,
+		analyzer.Config.Scoring.Beacon.ScorePrecision,
 	)
 	if err != nil {
 		logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
 		return beacon, err
 	}
 
+	// score the beacon over its best window of active hours when enabled, keeping the full span scores if they're higher
+	if windowHours := analyzer.Config.Scoring.Beacon.ConsistencyWindowHours; windowHours > 0 {
+		windowBins := getWindowBins(analyzer.minTSBeacon.Unix(), analyzer.maxTSBeacon.Unix(), len(hist.Counts), windowHours)
+		if windowBins < len(hist.Counts) {
+			windowHistScore, windowDurScore, err := getWindowedScores(hist.Counts, windowBins,
+				analyzer.Config.Scoring.Beacon.HistModeSensitivity, analyzer.Config.Scoring.Beacon.HistBimodalOutlierRemoval,
+				analyzer.Config.Scoring.Beacon.HistBimodalMinHours, analyzer.Config.Scoring.Beacon.DurMinHours,
+				analyzer.Config.Scoring.Beacon.DurIdealNumberOfConsistentHours, analyzer.Config.Scoring.Beacon.ScorePrecision,
+			)
+			if err != nil {
+				logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
+				return beacon, err
+			}
+			hist.Score = math.Max(hist.Score, windowHistScore)
+			durScore = math.Max(durScore, windowDurScore)
+		}
+	}
+
+	// convert the histogram counts for storage
+	histCounts := make([]int64, len(hist.Counts))
+	for i, count := range hist.Counts {
+		histCounts[i] = int64(count)
+	}
+
 	// calculate overall beacon score
 	score, err := getBeaconScore(tsScore, analyzer.Config.Scoring.Beacon.TsWeight,
 		dsScore, analyzer.Config.Scoring.Beacon.DsWeight,
 		durScore, analyzer.Config.Scoring.Beacon.DurWeight,
-		histScore, analyzer.Config.Scoring.Beacon.HistWeight)
+		hist.Score, analyzer.Config.Scoring.Beacon.HistWeight,
+		analyzer.Config.Scoring.Beacon.ScorePrecision)
 	if err != nil {
 		logger.Err(err).Caller().Str("src", entry.Src.String()).Str("dst", entry.Dst.String()).Str("fqdn", entry.FQDN).Send()
 		return beacon, err
@@ -94,20 +149,45 @@ func (analyzer *Analyzer) analyzeBeacon(entry *AnalysisResult) (Beacon, error) {
 		Score:          float32(score),
 		TimestampScore: float32(tsScore),
 		DataSizeScore:  float32(dsScore),
-		HistogramScore: float32(histScore),
+		HistogramScore: float32(hist.Score),
 		DurationScore:  float32(durScore),
 
 		// graphing fields
 		TSIntervals:      intervals,
 		TSIntervalCounts: intervalCounts,
+		TSIntervalP50:    intervalPercentiles[0],
+		TSIntervalP90:    intervalPercentiles[1],
+		TSIntervalP99:    intervalPercentiles[2],
 		DSSizes:          dsSizes,
 		DSCounts:         dsCounts,
+		HistBinEdges:     hist.BinEdges,
+		HistCounts:       histCounts,
 	}
 	return beacon, nil
 }
 
+// widenTimestamps converts the unix timestamps of a connection, which are read from the database as 32 bit values since
+// ClickHouse DateTime columns are 32 bit, to 64 bit values so that the intervals and histograms calculated from them can't
+// wrap around for timestamps that are out of order or near the end of the 32 bit range
+func widenTimestamps(tsList []uint32) []int64 {
+	widened := make([]int64, len(tsList))
+	for i, ts := range tsList {
+		widened[i] = int64(ts)
+	}
+	return widened
+}
+
+// getObservedSpan returns the time between the earliest and latest connection in a list of unix timestamps
+func getObservedSpan(tsList []uint32) time.Duration {
+	if len(tsList) < 2 {
+		return 0
+	}
+	first, last := slices.Min(tsList), slices.Max(tsList)
+	return time.Duration(last-first) * time.Second
+}
+
 // getBeaconScore calculates the overall beacon score from the weighted subscores
-func getBeaconScore(tsScore, tsWeight, dsScore, dsWeight, durScore, durWeight, histScore, histWeight float64) (float64, error) {
+func getBeaconScore(tsScore, tsWeight, dsScore, dsWeight, durScore, durWeight, histScore, histWeight float64, precision int) (float64, error) {
 	// ensure that the calculated subscores are between 0 and 1
 	scores := []float64{tsScore, dsScore, durScore, histScore}
 	for _, score := range scores {
@@ -130,7 +210,7 @@ func getBeaconScore(tsScore, tsWeight, dsScore, dsWeight, durScore, durWeight, h
 	}
 
 	// calculate the final score
-	score := math.Round(((tsScore*tsWeight)+(dsScore*dsWeight)+(durScore*durWeight)+(histScore*histWeight))*1000) / 1000
+	score := roundScore((tsScore*tsWeight)+(dsScore*dsWeight)+(durScore*durWeight)+(histScore*histWeight), precision)
 
 	return score, nil
 }
@@ -139,7 +219,8 @@ func getBeaconScore(tsScore, tsWeight, dsScore, dsWeight, durScore, durWeight, h
 // statistical properties of the intervals between timestamps, utilizing skewness and median absolute deviation
 // to calculate a score that reflects the consistency of the intervals. This function returns the ts score, skew,
 // median absolute deviation, intervals between timestamps, their counts, the most frequent interval, and its count.
-func getTimestampScore(tsList []uint32) (float64, float64, float64, []int64, []int64, int64, int64, error) {
+// The jitter tolerance reduces how much the dispersion of the intervals lowers the score.
+func getTimestampScore(tsList []int64, jitterTolerance float64, precision int) (float64, float64, float64, []int64, []int64, int64, int64, error) {
 	// ensure that the input slice has at least 4 elements (need at least 3 intervals, which requires at least 4 timestamps)
 	if len(tsList) < 4 {
 		return 0, 0, 0, nil, nil, 0, 0, fmt.Errorf("timestamp slice must contain at least 4 elements")
@@ -185,7 +266,7 @@ func getTimestampScore(tsList []uint32) (float64, float64, float64, []int64, []i
 	deltaTimes := deltaTimesFull[nonZeroIndex:]
 
 	// calculate ts score, skew, and median absolute deviation
-	tsScore, tsSkew, tsMadm, err := calculateStatisticalScore(deltaTimes, 1)
+	tsScore, tsSkew, tsMadm, err := calculateStatisticalScore(deltaTimes, 1, jitterTolerance, precision)
 	if err != nil {
 		return 0, 0, 0, nil, nil, 0, 0, err
 	}
@@ -194,11 +275,113 @@ func getTimestampScore(tsList []uint32) (float64, float64, float64, []int64, []i
 
 }
 
+// minSegmentTSScore is the timestamp score that a segment of a beacon's connections needs for its most frequent interval
+// to be compared to the other segments, since the modal interval of irregular connections doesn't describe a cadence
+const minSegmentTSScore = 0.7
+
+// getCadenceDrift splits the sorted timestamps of a beacon into segments of the same number of intervals and looks for
+// a shift in the most frequent interval between consecutive regular segments. It returns the modal intervals of the two
+// segments with the largest shift, and whether the longer of the two is at least ratio times the shorter one. Segments
+// that are too short or too irregular to have a meaningful modal interval are skipped.
+func getCadenceDrift(tsList []int64, segments int, ratio float64, jitterTolerance float64, precision int) (int64, int64, bool) {
+	numIntervals := len(tsList) - 1
+	if segments < 2 || numIntervals < segments {
+		return 0, 0, false
+	}
+
+	var from, to, previous int64
+	largestShift := 0.0
+	for i := 0; i < segments; i++ {
+		// neighbouring segments share a timestamp so that no interval is left out
+		start := i * numIntervals / segments
+		end := (i+1)*numIntervals/segments + 1
+
+		tsScore, _, _, intervals, intervalCounts, _, _, err := getTimestampScore(tsList[start:end], jitterTolerance, precision)
+		if err != nil || tsScore < minSegmentTSScore {
+			continue
+		}
+
+		mode := getNonZeroMode(intervals, intervalCounts)
+		if mode == 0 {
+			continue
+		}
+
+		if previous > 0 {
+			shift := float64(max(previous, mode)) / float64(min(previous, mode))
+			if shift > largestShift {
+				largestShift = shift
+				from, to = previous, mode
+			}
+		}
+		previous = mode
+	}
+
+	return from, to, largestShift >= ratio
+}
+
+// getNonZeroMode returns the most frequent non-zero interval from the distinct intervals and their counts, preferring
+// the shorter interval on ties. It returns 0 if every interval is zero.
+func getNonZeroMode(intervals []int64, intervalCounts []int64) int64 {
+	var mode, modeCount int64
+	for i, interval := range intervals {
+		if interval > 0 && intervalCounts[i] > modeCount {
+			mode, modeCount = interval, intervalCounts[i]
+		}
+	}
+	return mode
+}
+
+// getIntervalPercentiles calculates the given percentiles of the intervals between timestamps from the sorted distinct
+// intervals and their counts, using the nearest rank method so that each percentile is an interval that was observed.
+// Zero intervals between connections made in the same second are left out since they don't describe the cadence.
+func getIntervalPercentiles(intervals []int64, intervalCounts []int64, percentiles ...float64) ([]int64, error) {
+	if len(intervals) != len(intervalCounts) {
+		return nil, errors.New("intervals and interval counts must be the same length")
+	}
+
+	// count the non-zero intervals
+	var total int64
+	for i, interval := range intervals {
+		if interval > 0 {
+			total += intervalCounts[i]
+		}
+	}
+	if total == 0 {
+		return nil, ErrInputSliceEmpty
+	}
+
+	results := make([]int64, len(percentiles))
+	for p, percentile := range percentiles {
+		if percentile <= 0 || percentile > 100 {
+			return nil, fmt.Errorf("percentile must be greater than 0 and at most 100, got %v", percentile)
+		}
+
+		// find the first interval whose cumulative count reaches the rank of the percentile
+		rank := int64(math.Ceil(percentile / 100 * float64(total)))
+		var cumulative int64
+		for i, interval := range intervals {
+			if interval <= 0 {
+				continue
+			}
+			cumulative += intervalCounts[i]
+			if cumulative >= rank {
+				results[p] = interval
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
 // getDataSizeScore calculates the data size score for a given list of data sizes. This score is based on the
 // statistical properties of the data sizes, utilizing skewness and median absolute deviation to calculate a
 // score that reflects the consistency of the data sizes. This function returns the ds score, skew,
 // median absolute deviation, unique data sizes, their counts, the most frequent data size, and its count.
-func getDataSizeScore(bytesList []float64) (float64, float64, float64, []int64, []int64, int64, int64, error) {
+// trimPercent is the percentage of the largest and of the smallest data sizes that are left out of the score so that
+// a few unusual transfers don't hide an otherwise regular beacon; the distinct sizes and their counts include every
+// data size.
+func getDataSizeScore(bytesList []float64, trimPercent float64, precision int) (float64, float64, float64, []int64, []int64, int64, int64, error) {
 	// ensure that the input slice has at least 3 elements
 	if len(bytesList) < 3 {
 		return 0, 0, 0, nil, nil, 0, 0, fmt.Errorf("bytes slice must contain at least 3 elements")
@@ -214,7 +397,7 @@ func getDataSizeScore(bytesList []float64) (float64, float64, float64, []int64,
 	}
 
 	// calculate datasize score, skew, and median absolute deviation
-	dsScore, dsSkew, dsMadm, err := calculateStatisticalScore(bytesList, 0)
+	dsScore, dsSkew, dsMadm, err := calculateStatisticalScore(trimOutliers(bytesList, trimPercent), 0, 0, precision)
 	if err != nil {
 		return 0, 0, 0, nil, nil, 0, 0, err
 	}
@@ -223,8 +406,46 @@ func getDataSizeScore(bytesList []float64) (float64, float64, float64, []int64,
 
 }
 
+// trimOutliers returns the sorted values without the given percentage of the values from each end. The number of values
+// trimmed from each end is rounded down, and at least 3 values are always kept so that the skew can be calculated.
+func trimOutliers(sorted []float64, percent float64) []float64 {
+	trim := int(float64(len(sorted)) * percent / 100)
+	if trim <= 0 || len(sorted)-2*trim < 3 {
+		return sorted
+	}
+	return sorted[trim : len(sorted)-trim]
+}
+
+// getDirectionalDataSizeScore calculates the data size score from the data sizes sent by the source, received by the source,
+// or both, based on the configured direction. When combining, each direction is scored separately and the higher score is
+// used so that a regular data size in one direction isn't washed out by a noisy data size in the other. The distinct data
+// sizes and their counts are returned for the direction that produced the score.
+func getDirectionalDataSizeScore(sendBytes []float64, receiveBytes []float64, direction string, trimPercent float64, precision int) (float64, []int64, []int64, error) {
+	switch direction {
+	case config.DataSizeDirectionReceive:
+		score, _, _, sizes, counts, _, _, err := getDataSizeScore(receiveBytes, trimPercent, precision)
+		return score, sizes, counts, err
+	case config.DataSizeDirectionCombined:
+		sendScore, _, _, sendSizes, sendCounts, _, _, err := getDataSizeScore(sendBytes, trimPercent, precision)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		receiveScore, _, _, receiveSizes, receiveCounts, _, _, err := getDataSizeScore(receiveBytes, trimPercent, precision)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if receiveScore > sendScore {
+			return receiveScore, receiveSizes, receiveCounts, nil
+		}
+		return sendScore, sendSizes, sendCounts, nil
+	default:
+		score, _, _, sizes, counts, _, _, err := getDataSizeScore(sendBytes, trimPercent, precision)
+		return score, sizes, counts, err
+	}
+}
+
 // calculateStatisticalScore calculates the statistical score, skew, and median absolute deviation for a given list of float64 values
-func calculateStatisticalScore(values []float64, defaultMadScore float64) (float64, float64, float64, error) {
+func calculateStatisticalScore(values []float64, defaultMadScore float64, madTolerance float64, precision int) (float64, float64, float64, error) {
 	// ensure that the input slice is not empty
 	if len(values) == 0 {
 		return 0, 0, 0, ErrInputSliceEmpty
@@ -237,69 +458,77 @@ func calculateStatisticalScore(values []float64, defaultMadScore float64) (float
 	}
 
 	// calculate the median absolute deviation of the values
-	mad, madScore, err := calculateMedianAbsoluteDeviation(values, defaultMadScore)
+	mad, madScore, err := calculateMedianAbsoluteDeviation(values, defaultMadScore, madTolerance)
 	if err != nil {
 		return 0, 0, 0, err
 	}
 
 	// calculate final statistical score
-	score := math.Round(((skewScore+madScore)/2.0)*1000) / 1000
+	score := roundScore((skewScore+madScore)/2.0, precision)
 
 	return score, skew, mad, nil
 }
 
-// getHistogramScore calculates a score based on the histogram of timestamps of a host pair over a specified period of time
-func getHistogramScore(datasetMin int64, datasetMax int64, tsList []uint32, modeSensitivity float64, bimodalOutlierRemoval int, bimodalMinHoursSeen int, beaconTimeSpan int) ([]int, map[int32]int32, int, int, float64, error) {
+// GetHistogramScore calculates a score based on the histogram of timestamps of a host pair over a specified period of time
+// and returns the histogram along with its score
+func GetHistogramScore(datasetMin int64, datasetMax int64, tsList []int64, modeSensitivity float64, bimodalOutlierRemoval int, bimodalMinHoursSeen int, beaconTimeSpan int, precision int) (Histogram, error) {
 	// ensure that the input slice is not empty
 	if len(tsList) == 0 {
-		return nil, nil, 0, 0, 0, ErrInputSliceEmpty
+		return Histogram{}, ErrInputSliceEmpty
 	}
 
 	// ensure that the dataset time range is valid
 	if datasetMax <= datasetMin {
-		return nil, nil, 0, 0, 0, ErrInvalidDatasetTimeRange
+		return Histogram{}, ErrInvalidDatasetTimeRange
 	}
 
 	// get histogram bin eges (note: we currently look at a 24 hour period)
 	binEdges, err := computeHistogramBins(datasetMin, datasetMax, beaconTimeSpan)
 	if err != nil {
-		return nil, nil, 0, 0, 0, err
+		return Histogram{}, err
 	}
 
 	// use timestamps to get freqencies for each bin
 	freqList, freqCount, totalBars, longestRun, err := createHistogram(binEdges, tsList, modeSensitivity)
 	if err != nil {
-		return nil, nil, 0, 0, 0, err
+		return Histogram{}, err
 	}
 
 	// calculate first potential score: coefficient of variation
 	// coefficient of variation will help score histograms that have jitter in the number of
 	// connections but where the overall graph would still look relatively flat and consistent
 	// calculate coefficient of variation score
-	cvScore, err := calculateCoefficientOfVariationScore(freqList)
+	cvScore, err := calculateCoefficientOfVariationScore(freqList, precision)
 	if err != nil {
-		return nil, nil, 0, 0, 0, err
+		return Histogram{}, err
 	}
 
 	// calculate second potential score: bimodal fit
 	// this will score well for graphs that have 2-3 flat sections in their connection histogram,
 	// or a bimodal freqCount histogram.
-	bimodalFitScore, err := calculateBimodalFitScore(freqCount, totalBars, bimodalOutlierRemoval, bimodalMinHoursSeen)
+	bimodalFitScore, err := calculateBimodalFitScore(freqCount, totalBars, bimodalOutlierRemoval, bimodalMinHoursSeen, precision)
 	if err != nil {
-		return nil, nil, 0, 0, 0, err
+		return Histogram{}, err
 	}
 
 	// calculate final score
 	// the final score is the max of the coefficient of variation and bimodal fit scores
 	score := math.Max(cvScore, bimodalFitScore)
 
-	return freqList, freqCount, totalBars, longestRun, score, nil
+	return Histogram{
+		BinEdges:   binEdges,
+		Counts:     freqList,
+		FreqCount:  freqCount,
+		TotalBars:  totalBars,
+		LongestRun: longestRun,
+		Score:      score,
+	}, nil
 }
 
 // getDurationScore calculates a duration score based on the provided input parameters, provided that
 // a sufficient amount of hours (default threshold: 6 hours) are represented in the connection frequency histogram.
 // The duration score is derived from two potential subscores: dataset timespan coverage and consistency of connection hours
-func getDurationScore(datasetMin int64, datasetMax int64, histMin int64, histMax int64, totalBars int, longestConsecutiveRun int, minHoursThreshold int, idealNumberConsistentHours int) (float64, float64, float64, error) {
+func getDurationScore(datasetMin int64, datasetMax int64, histMin int64, histMax int64, totalBars int, longestConsecutiveRun int, minHoursThreshold int, idealNumberConsistentHours int, precision int) (float64, float64, float64, error) {
 
 	// ensure that the input values are valid
 	if minHoursThreshold < 1 || idealNumberConsistentHours < 1 || datasetMax <= datasetMin || histMax <= histMin {
@@ -317,7 +546,7 @@ func getDurationScore(datasetMin int64, datasetMax int64, histMin int64, histMax
 		// entire specified timeframe. It is calculated as:
 		//    [ timestamp of last connection - timestamp of first connection ] /
 		//    [ last timestamp of dataset - first timestamp of dataset ]
-		coverage = math.Ceil((float64(histMax-histMin)/float64(datasetMax-datasetMin))*1000) / 1000
+		coverage = ceilScore(float64(histMax-histMin)/float64(datasetMax-datasetMin), precision)
 		if coverage > 1.0 {
 			coverage = 1.0
 		}
@@ -327,7 +556,7 @@ func getDurationScore(datasetMin int64, datasetMax int64, histMin int64, histMax
 		// of consecutive hours observed. Consecutive hours include wrap-around from the start
 		// to the end of the dataset. It is calculated as:
 		//    [ longest run of consecutive hours seen] / [ Ideal consecutive hours (default: 12) ]
-		consistency = math.Ceil((float64(longestConsecutiveRun)/float64(idealNumberConsistentHours))*1000) / 1000
+		consistency = ceilScore(float64(longestConsecutiveRun)/float64(idealNumberConsistentHours), precision)
 		if consistency > 1.0 {
 			consistency = 1.0
 		}
@@ -339,13 +568,124 @@ func getDurationScore(datasetMin int64, datasetMax int64, histMin int64, histMax
 	return coverage, consistency, score, nil
 }
 
+// getWindowBins returns the number of histogram bins that cover the given number of hours of the beacon time span
+func getWindowBins(datasetMin int64, datasetMax int64, numBins int, windowHours int) int {
+	binSeconds := float64(datasetMax-datasetMin) / float64(numBins)
+	bins := int(math.Round(float64(windowHours) * 3600 / binSeconds))
+	return max(1, min(bins, numBins))
+}
+
+// getWindowedScores scores the consistency of a beacon over each contiguous window of windowBins bars of its connection
+// histogram, wrapping around from the end of the beacon time span to the start, and returns the histogram and duration
+// scores of the best window. This rewards beacons that are consistently present during only part of each day, which
+// are otherwise penalized for the empty bins outside of their active hours. The duration subscores are measured against
+// the window instead of the full span, so the minimum and ideal hours seen are capped to the window size.
+func getWindowedScores(histogram []int, windowBins int, modeSensitivity float64, bimodalOutlierRemoval int, bimodalMinHoursSeen int, minHoursThreshold int, idealNumberConsistentHours int, precision int) (float64, float64, error) {
+	// ensure that the input values are valid
+	if len(histogram) == 0 {
+		return 0, 0, ErrInputSliceEmpty
+	}
+	if windowBins < 1 || windowBins > len(histogram) {
+		return 0, 0, fmt.Errorf("window size must be between 1 and %d bins, got %d", len(histogram), windowBins)
+	}
+
+	minHours := min(minHoursThreshold, windowBins)
+	idealHours := min(idealNumberConsistentHours, windowBins)
+
+	bestHist, bestDur := float64(0), float64(0)
+	window := make([]int, windowBins)
+	for start := range histogram {
+		// copy the bars of this window, wrapping around to the start of the histogram
+		first, last, total := -1, -1, 0
+		for i := range window {
+			window[i] = histogram[(start+i)%len(histogram)]
+			if window[i] > 0 {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+			total += window[i]
+		}
+
+		// skip windows without any connections
+		if total == 0 {
+			continue
+		}
+
+		// score the histogram of the window like the full histogram
+		cvScore, err := calculateCoefficientOfVariationScore(window, precision)
+		if err != nil {
+			return 0, 0, err
+		}
+		freqCount, totalBars, _, err := getFrequencyCounts(window, modeSensitivity)
+		if err != nil {
+			return 0, 0, err
+		}
+		bimodalFitScore, err := calculateBimodalFitScore(freqCount, totalBars, bimodalOutlierRemoval, bimodalMinHoursSeen, precision)
+		if err != nil {
+			return 0, 0, err
+		}
+		histScore := math.Max(cvScore, bimodalFitScore)
+
+		// score the duration of the window, the longest run doesn't wrap around since the window is contiguous
+		durScore := float64(0)
+		if totalBars >= minHours {
+			coverage := math.Min(ceilScore(float64(last-first+1)/float64(windowBins), precision), 1)
+			consistency := math.Min(ceilScore(float64(getLongestRun(window))/float64(idealHours), precision), 1)
+			durScore = math.Max(coverage, consistency)
+		}
+
+		// keep the window with the best combined score
+		if histScore+durScore > bestHist+bestDur {
+			bestHist, bestDur = histScore, durScore
+		}
+	}
+
+	return bestHist, bestDur, nil
+}
+
+// getLongestRun returns the longest run of consecutive non-empty bars in a histogram, without wrapping around
+func getLongestRun(histogram []int) int {
+	longestRun, currentRun := 0, 0
+	for _, bar := range histogram {
+		if bar > 0 {
+			currentRun++
+			longestRun = max(longestRun, currentRun)
+		} else {
+			currentRun = 0
+		}
+	}
+	return longestRun
+}
+
+// roundScore rounds a score to the given number of decimal places
+func roundScore(score float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(score*scale) / scale
+}
+
+// ceilScore rounds a score up to the given number of decimal places
+func ceilScore(score float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Ceil(score*scale) / scale
+}
+
+// BowleySkewness returns the Bowley (quartile) skewness of values, (Q1 + Q3 - 2*Q2) / (Q3 - Q1), and a
+// symmetry score of 1 - |skew| between 0 and 1. The skewness is 0 and the score is 1 when the median is equal
+// to the lower or upper quartile, or when the interquartile range is less than 10, since skewness isn't
+// meaningful for distributions that tight. Values must have at least 3 elements and are not modified.
+func BowleySkewness(values []float64) (skew float64, score float64, err error) {
+	return calculateBowleySkewness(values)
+}
+
 // calculateBowleySkewness calculates a measure of skewness for a distribution.
 // Perfect beacons would have symmetric delta time and size distributions
 func calculateBowleySkewness(data []float64) (float64, float64, error) {
 	// ensure that the input slice is not empty, since the minimum number of
 	// elements required to calculate skewness is 3
 	if len(data) < 3 {
-		return 0, 0, fmt.Errorf("input slice must not contain fewer than 3 elements")
+		return 0, 0, ErrInputSliceTooShort
 	}
 
 	// calculate the quartiles
@@ -380,8 +720,9 @@ func calculateBowleySkewness(data []float64) (float64, float64, error) {
 
 // calculateMedianAbsoluteDeviation calculates the Median Absolute Deviation (MAD) about the median,
 // providing a score that measures the dispersion of a distribution. Perfectly consistent data would
-// result in a MAD score close to zero
-func calculateMedianAbsoluteDeviation(data []float64, defaultScore float64) (float64, float64, error) {
+// result in a MAD score close to zero. The tolerance is a fraction in [0,1) that scales down the
+// MAD to median ratio so that the score is more forgiving of dispersion; a tolerance of 0 has no effect
+func calculateMedianAbsoluteDeviation(data []float64, defaultScore float64, tolerance float64) (float64, float64, error) {
 	// ensure the the input slice is not empty
 	if len(data) == 0 {
 		return 0, 0, ErrInputSliceEmpty
@@ -408,7 +749,7 @@ func calculateMedianAbsoluteDeviation(data []float64, defaultScore float64) (flo
 	// consistent the data is. As the MAD increases, the score decreases, indicating more dispersion
 	score := defaultScore
 	if median >= 1 {
-		score = (median - mad) / median
+		score = (median - mad*(1-tolerance)) / median
 	}
 
 	// If the score is less than zero or NaN, return zero
@@ -518,8 +859,7 @@ func computeHistogramBins(startTime int64, endTime int64, numBins int) ([]float6
 }
 
 // createHistogram calculates the distribution of timestamps across given bin edges
-// func createHistogram(binEdges []uint32, timestamps []uint32, modeSensitivity float64) ([]int, map[int32]int32, int, int, error) {
-func createHistogram(binEdges []float64, timestamps []uint32, modeSensitivity float64) ([]int, map[int32]int32, int, int, error) {
+func createHistogram(binEdges []float64, timestamps []int64, modeSensitivity float64) ([]int, map[int32]int32, int, int, error) {
 	// validate input
 	if len(binEdges) < 2 {
 		return nil, nil, 0, 0, errors.New("bin edges must contain at least 2 elements")
@@ -535,46 +875,30 @@ func createHistogram(binEdges []float64, timestamps []uint32, modeSensitivity fl
 	}
 
 	// ensure that the timestamps are sorted
-	if !util.UInt32sAreSorted(timestamps) {
-		util.SortUInt32s(timestamps)
+	if !slices.IsSorted(timestamps) {
+		slices.Sort(timestamps)
 	}
 
-	// Initialize nextBinIndex with the second bin edge to start comparisons.
-	// This variable represents the upper limit of the current bin, used to determine
-	// if a timestamp falls within the current bin or if we need to move to the next bin.
-	currentBinIndex := 0
-	nextBinEdge := binEdges[currentBinIndex+1]
-
 	// calculate the number of connections that occurred within the time span represented by each bin
 	// this is basically a histogram of the number of connections that occurred within each bin
 	// i,e, for a timestamp list of [1, 5, 23, 25, 42, 45] and bin edges [0, 10, 20, 30, 40, 50],
 	// the histogram would be [2,0,2,0,2]
+	// timestamps before the first edge are counted in the first bin, and timestamps at or after the
+	// last edge are counted in the last bin
 	connectionHistogram := make([]int, len(binEdges)-1)
 
-	// loop over sorted timestamp list
-	for _, timestamp := range timestamps {
-
-		// increment if still in the current bin
-		if float64(timestamp) < nextBinEdge {
-			connectionHistogram[currentBinIndex]++
-			continue
-		}
-
-		// if the timestamp is greater than or equal to the next bin edge, move to the next bin
-		for j := currentBinIndex + 1; j < len(binEdges)-1; j++ {
-			currentBinIndex = j
-			nextBinEdge = binEdges[j+1]
-			if float64(timestamp) < binEdges[j+1] {
-				break
-			}
-
-		}
-
-		// increment count
-		// this will also capture and increment for a situation where the final timestamp is
-		// equal to the final bin
-		connectionHistogram[currentBinIndex]++
+	// since the timestamps are sorted, the number of timestamps before each inner bin edge can be found with a
+	// binary search instead of placing every timestamp, which keeps this fast for connections made every second
+	binStart := 0
+	for i := range connectionHistogram[:len(connectionHistogram)-1] {
+		edge := binEdges[i+1]
+		binEnd := binStart + sort.Search(len(timestamps)-binStart, func(j int) bool {
+			return float64(timestamps[binStart+j]) >= edge
+		})
+		connectionHistogram[i] = binEnd - binStart
+		binStart = binEnd
 	}
+	connectionHistogram[len(connectionHistogram)-1] = len(timestamps) - binStart
 
 	// get histogram frequency counts
 	freqCount, totalBars, longestRun, err := getFrequencyCounts(connectionHistogram, modeSensitivity)
@@ -660,11 +984,7 @@ func getFrequencyCounts(connectionHistogram []int, modeSensitivity float64) (map
 				bin := int(math.Floor(float64(frequency)/binSize) * binSize)
 
 				// create or increment bin
-				if _, ok := freqCount[int32(bin)]; !ok {
-					freqCount[int32(bin)] = 1
-				} else {
-					freqCount[int32(bin)]++
-				}
+				freqCount[int32(bin)]++
 			}
 
 		}
@@ -693,7 +1013,7 @@ func getFrequencyCounts(connectionHistogram []int, modeSensitivity float64) (map
 // calculateCoefficientOfVariationScore calculates a score based on the coefficient of variation (CV) for a given frequency list.
 // The CV is a standardized measure of dispersion of a frequency distribution, defined as the ratio of the standard deviation to the mean.
 // This function returns a score inversely related to the CV, aiming to score datasets based on their uniformity or consistency.
-func calculateCoefficientOfVariationScore(freqList []int) (float64, error) {
+func calculateCoefficientOfVariationScore(freqList []int, precision int) (float64, error) {
 	// ensure that the input is valid
 
 	// ensure that the input slice is not empty
@@ -732,7 +1052,7 @@ func calculateCoefficientOfVariationScore(freqList []int) (float64, error) {
 	if cv > 1.0 {
 		cvScore = 0.0
 	} else {
-		cvScore = math.Round((1.0-cv)*1000) / 1000
+		cvScore = roundScore(1.0-cv, precision)
 	}
 
 	// ensure that the score does not exceed 1
@@ -749,7 +1069,7 @@ func calculateCoefficientOfVariationScore(freqList []int) (float64, error) {
 // connection counts per hour. The score is computed only if the number of total bars on the histogram is at least the
 // specified minimum (default: 11). The final score is normalized between 0 and 1, where 1 indicates a perfect fit for
 // bimodal patterns, and 0 indicates a poor fit.
-func calculateBimodalFitScore(freqCount map[int32]int32, totalBars int, modalOutlierRemoval int, minHoursForBimodalAnalysis int) (float64, error) {
+func calculateBimodalFitScore(freqCount map[int32]int32, totalBars int, modalOutlierRemoval int, minHoursForBimodalAnalysis int, precision int) (float64, error) {
 	// ensure that the input is valid
 	if len(freqCount) == 0 {
 		return 0, errors.New("frequency count map must not be empty")
@@ -794,7 +1114,7 @@ func calculateBimodalFitScore(freqCount map[int32]int32, totalBars int, modalOut
 	}
 
 	// calculate final score, ensuring that it does not exceed 1
-	modalFitScore := math.Round(float64(modalFit)*1000) / 1000
+	modalFitScore := roundScore(modalFit, precision)
 	if modalFitScore > 1.0 {
 		modalFitScore = 1.0
 	}
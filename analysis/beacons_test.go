@@ -1,7 +1,11 @@
 package analysis
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
 
 	"github.com/stretchr/testify/require"
 )
@@ -222,7 +226,7 @@ func TestGetBeaconScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			score, err := getBeaconScore(test.tsScore, test.tsWeight, test.dsScore, test.dsWeight, test.durScore, test.durWeight, test.histScore, test.histWeight)
+			score, err := getBeaconScore(test.tsScore, test.tsWeight, test.dsScore, test.dsWeight, test.durScore, test.durWeight, test.histScore, test.histWeight, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
@@ -233,10 +237,38 @@ func TestGetBeaconScore(t *testing.T) {
 	}
 }
 
+func TestRoundScore(t *testing.T) {
+	tests := []struct {
+		name          string
+		score         float64
+		precision     int
+		expectedRound float64
+		expectedCeil  float64
+	}{
+		{name: "Default Precision", score: 0.123456, precision: 3, expectedRound: 0.123, expectedCeil: 0.124},
+		{name: "Minimum Precision", score: 0.123456, precision: 2, expectedRound: 0.12, expectedCeil: 0.13},
+		{name: "Maximum Precision", score: 0.1234564, precision: 6, expectedRound: 0.123456, expectedCeil: 0.123457},
+		{name: "Rounds Half Up", score: 0.0125, precision: 3, expectedRound: 0.013, expectedCeil: 0.013},
+		{name: "Already Rounded", score: 0.5, precision: 4, expectedRound: 0.5, expectedCeil: 0.5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.InDelta(t, test.expectedRound, roundScore(test.score, test.precision), 1e-9, "rounded score should match expected value")
+			require.InDelta(t, test.expectedCeil, ceilScore(test.score, test.precision), 1e-9, "ceiling score should match expected value")
+		})
+	}
+
+	// the beacon score should be rounded to the requested precision
+	score, err := getBeaconScore(0.123456, 0.25, 0.123456, 0.25, 0.123456, 0.25, 0.123456, 0.25, 5)
+	require.NoError(t, err)
+	require.InDelta(t, 0.12346, score, 1e-9, "beacon score should be rounded to 5 decimal places")
+}
+
 func TestGetTimestampScore(t *testing.T) {
 	tests := []struct {
 		name                         string
-		tsList                       []uint32
+		tsList                       []int64
 		expectedScore                float64
 		expectedSkew                 float64
 		expectedMAD                  float64
@@ -248,7 +280,7 @@ func TestGetTimestampScore(t *testing.T) {
 	}{
 		{
 			name:   "Simple Number List",
-			tsList: []uint32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			tsList: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
 			// intervals between timestamps: 1, 1, 1, 1, 1, 1, 1, 1, 1
 			expectedUniqueIntervals:      []int64{1},
 			expectedUniqueIntervalCounts: []int64{9},
@@ -264,7 +296,7 @@ func TestGetTimestampScore(t *testing.T) {
 		{
 			name: "Connection with Perfect Intervals",
 			// timestamps : 1517338924, 1517338924 + 60, 1517338924 + 120, 1517338924 + 180, 1517338924 + 240, 1517338924 + 300, 1517338924 + 360, 1517338924 + 420, 1517338924 + 480, 1517338924 + 540,
-			tsList: []uint32{1517338924, 1517338984, 1517339044, 1517339104, 1517339164, 1517339224, 1517339284, 1517339344, 1517339404, 1517339464},
+			tsList: []int64{1517338924, 1517338984, 1517339044, 1517339104, 1517339164, 1517339224, 1517339284, 1517339344, 1517339404, 1517339464},
 			// intervals between timestamps: 60, 60, 60, 60, 60, 60, 60, 60, 60
 			expectedUniqueIntervals:      []int64{60},
 			expectedUniqueIntervalCounts: []int64{9},
@@ -280,7 +312,7 @@ func TestGetTimestampScore(t *testing.T) {
 		{
 			name: "Connection with Closely-Valued Intervals",
 			// timestamps : 1517338924, 1517338924 + 98, 1517338924 + 98 + 99, 1517338924 + 98 + 99 + 99, 1517338924 + 98 + 99 + 99 + 100, 1517338924 + 98 + 99 + 99 + 100 + 100, 1517338924 + 98 + 99 + 99 + 100 + 100 + 100, 1517338924 + 98 + 99 + 99 + 100 + 100 + 100 + 101, 1517338924 + 98 + 99 + 99 + 100 + 100 + 100 + 101 + 101, 1517338924 + 98 + 99 + 99 + 100 + 100 + 100 + 101 + 101 + 102,
-			tsList: []uint32{1517338924, 1517339022, 1517339121, 1517339220, 1517339320, 1517339420, 1517339520, 1517339621, 1517339722, 1517339824},
+			tsList: []int64{1517338924, 1517339022, 1517339121, 1517339220, 1517339320, 1517339420, 1517339520, 1517339621, 1517339722, 1517339824},
 			// intervals between timestamps: 98, 99, 99, 100, 100, 100, 101, 101, 102
 			expectedUniqueIntervals:      []int64{98, 99, 100, 101, 102},
 			expectedUniqueIntervalCounts: []int64{1, 2, 3, 2, 1},
@@ -296,7 +328,7 @@ func TestGetTimestampScore(t *testing.T) {
 		{
 			name: "Connection with Bi-Modal Intervals",
 			// timestamps : 1517338924, 1517338924 + 98, 1517338924 + 98 + 300, 1517338924 + 98 + 300 + 98, 1517338924 + 98 + 300 + 98 + 300, 1517338924 + 98 + 300 + 98 + 300 + 98, 1517338924 + 98 + 300 + 98 + 300 + 98 + 300, 1517338924 + 98 + 300 + 98 + 300 + 98 + 300 + 98, 1517338924 + 98 + 300 + 98 + 300 + 98 + 300 + 98 + 300, 1517338924 + 98 + 300 + 98 + 300 + 98 + 300 + 98 + 300 + 98,
-			tsList: []uint32{1517338924, 1517339022, 1517339322, 1517339420, 1517339720, 1517339818, 1517340118, 1517340216, 1517340516, 1517340614, 1517340914},
+			tsList: []int64{1517338924, 1517339022, 1517339322, 1517339420, 1517339720, 1517339818, 1517340118, 1517340216, 1517340516, 1517340614, 1517340914},
 			// intervals between timestamps: 98, 300, 98, 300, 98, 300, 98, 300, 98
 			expectedUniqueIntervals:      []int64{98, 300},
 			expectedUniqueIntervalCounts: []int64{5, 5},
@@ -320,7 +352,7 @@ func TestGetTimestampScore(t *testing.T) {
 		},
 		{
 			name:   "Connection with Random Intervals",
-			tsList: []uint32{1517338924, 1517338925, 1517339224, 1517339249, 1517344224, 1517344314, 1517344316, 1517344358, 1517344858, 1517346358},
+			tsList: []int64{1517338924, 1517338925, 1517339224, 1517339249, 1517344224, 1517344314, 1517344316, 1517344358, 1517344858, 1517346358},
 			// intervals between timestamps: 1, 299, 25, 4975, 90, 2, 42, 500, 1500
 			expectedUniqueIntervals:      []int64{1, 2, 25, 42, 90, 299, 500, 1500, 4975},
 			expectedUniqueIntervalCounts: []int64{1, 1, 1, 1, 1, 1, 1, 1, 1},
@@ -338,21 +370,35 @@ func TestGetTimestampScore(t *testing.T) {
 			expectedScore: 0.083,
 			expectedError: false,
 		},
+		{
+			name: "Connection with Timestamps Past the 32 Bit Range",
+			// timestamps start after 2106-02-07, when unix timestamps no longer fit in a uint32
+			tsList: []int64{4294967000, 4294967060, 4294967120, 4294967180, 4294967240, 4294967300, 4294967360, 4294967420},
+			// intervals between timestamps: 60, 60, 60, 60, 60, 60, 60
+			expectedUniqueIntervals:      []int64{60},
+			expectedUniqueIntervalCounts: []int64{7},
+			expectedTSMode:               60,
+			expectedTSModeCount:          7,
+			expectedSkew:                 0,
+			expectedMAD:                  0,
+			expectedScore:                1,
+			expectedError:                false,
+		},
 		{
 			// should not happen in practice, since we query for connections with > 3 unique timestamps
 			name:   "Connection with < 3 Non-Zero Intervals",
-			tsList: []uint32{60, 60, 60, 60, 60, 60, 60, 60, 60},
+			tsList: []int64{60, 60, 60, 60, 60, 60, 60, 60, 60},
 			// intervals between timestamps: 0, 0, 0, 0, 0, 0, 0, 0
 			expectedError: true,
 		},
 		{
 			name:          "Length of Timestamp List < 4",
-			tsList:        []uint32{1517338924, 1517338925},
+			tsList:        []int64{1517338924, 1517338925},
 			expectedError: true,
 		},
 		{
 			name:          "Empty Input Slice",
-			tsList:        []uint32{},
+			tsList:        []int64{},
 			expectedError: true,
 		},
 	}
@@ -362,7 +408,7 @@ func TestGetTimestampScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			score, skew, mad, intervals, intervalCounts, mode, modeCount, err := getTimestampScore(test.tsList)
+			score, skew, mad, intervals, intervalCounts, mode, modeCount, err := getTimestampScore(test.tsList, 0, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
@@ -382,6 +428,18 @@ func TestGetTimestampScore(t *testing.T) {
 	}
 }
 
+func TestWidenTimestamps(t *testing.T) {
+	require.Equal(t, []int64{0, 1517338924, 4294967295}, widenTimestamps([]uint32{0, 1517338924, 4294967295}))
+	require.Empty(t, widenTimestamps(nil))
+}
+
+func TestGetObservedSpan(t *testing.T) {
+	require.Equal(t, time.Duration(0), getObservedSpan(nil), "no connections should have no span")
+	require.Equal(t, time.Duration(0), getObservedSpan([]uint32{1517338924}), "a single connection should have no span")
+	require.Equal(t, 20*time.Minute, getObservedSpan([]uint32{1517338924, 1517339524, 1517340124}))
+	require.Equal(t, 20*time.Minute, getObservedSpan([]uint32{1517340124, 1517338924, 1517339524}), "span should not depend on the order of the timestamps")
+}
+
 func TestGetDataSizeScore(t *testing.T) {
 	tests := []struct {
 		name                     string
@@ -487,7 +545,7 @@ func TestGetDataSizeScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			score, skew, mad, sizes, sizeCounts, mode, modeCount, err := getDataSizeScore(test.bytesList)
+			score, skew, mad, sizes, sizeCounts, mode, modeCount, err := getDataSizeScore(test.bytesList, 0, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
@@ -507,6 +565,294 @@ func TestGetDataSizeScore(t *testing.T) {
 	}
 }
 
+func TestGetDataSizeScoreOutlierTrimming(t *testing.T) {
+	// a regular beacon that uploaded a file once, the outlier becomes the upper quartile of so few connections
+	bytesList := func() []float64 { return []float64{420, 2000000, 400, 430, 410} }
+
+	tests := []struct {
+		name          string
+		trimPercent   float64
+		expectedScore float64
+		expectedSkew  float64
+	}{
+		{
+			name:          "No Trimming",
+			trimPercent:   0,
+			expectedScore: 0.488, // skew score = 1 - 1 = 0, MAD score = 1 - (10/420) = 0.976
+			expectedSkew:  1,
+		},
+		{
+			name:          "Too Small To Trim",
+			trimPercent:   10, // 10% of 5 connections rounds down to 0
+			expectedScore: 0.488,
+			expectedSkew:  1,
+		},
+		{
+			name:          "Outlier Trimmed",
+			trimPercent:   20,    // the largest and smallest sizes are trimmed, leaving 410, 420, 430
+			expectedScore: 0.988, // skew score = 1, MAD score = 1 - (10/420) = 0.976
+			expectedSkew:  0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			score, skew, _, sizes, sizeCounts, _, _, err := getDataSizeScore(bytesList(), test.trimPercent, 3)
+			require.NoError(err)
+
+			require.InDelta(test.expectedScore, score, 0.001, "Expected score to be %v, got %v", test.expectedScore, score)
+			require.InDelta(test.expectedSkew, skew, 0.001, "Expected skew to be %v, got %v", test.expectedSkew, skew)
+
+			// trimmed sizes are still reported
+			require.Equal([]int64{400, 410, 420, 430, 2000000}, sizes)
+			require.Equal([]int64{1, 1, 1, 1, 1}, sizeCounts)
+		})
+	}
+}
+
+func TestGetDirectionalDataSizeScore(t *testing.T) {
+	// the sent data sizes are noisy, but the received data sizes are all the same
+	send := func() []float64 { return []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10} }
+	receive := func() []float64 { return []float64{500, 500, 500, 500, 500, 500, 500, 500, 500, 500} }
+
+	tests := []struct {
+		name                     string
+		direction                string
+		sendBytes                []float64
+		receiveBytes             []float64
+		expectedScore            float64
+		expectedUniqueSizes      []int64
+		expectedUniqueSizeCounts []int64
+		expectedError            bool
+	}{
+		{
+			name:                     "Send",
+			direction:                config.DataSizeDirectionSend,
+			sendBytes:                send(),
+			receiveBytes:             receive(),
+			expectedScore:            0.773,
+			expectedUniqueSizes:      []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			expectedUniqueSizeCounts: []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:                     "Receive",
+			direction:                config.DataSizeDirectionReceive,
+			sendBytes:                send(),
+			receiveBytes:             receive(),
+			expectedScore:            1,
+			expectedUniqueSizes:      []int64{500},
+			expectedUniqueSizeCounts: []int64{10},
+		},
+		{
+			name:                     "Combined Uses Regular Receive Side",
+			direction:                config.DataSizeDirectionCombined,
+			sendBytes:                send(),
+			receiveBytes:             receive(),
+			expectedScore:            1,
+			expectedUniqueSizes:      []int64{500},
+			expectedUniqueSizeCounts: []int64{10},
+		},
+		{
+			name:                     "Combined Uses Regular Send Side",
+			direction:                config.DataSizeDirectionCombined,
+			sendBytes:                receive(),
+			receiveBytes:             send(),
+			expectedScore:            1,
+			expectedUniqueSizes:      []int64{500},
+			expectedUniqueSizeCounts: []int64{10},
+		},
+		{
+			name:          "Combined With Too Few Received Sizes",
+			direction:     config.DataSizeDirectionCombined,
+			sendBytes:     send(),
+			receiveBytes:  []float64{1, 2},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			score, sizes, sizeCounts, err := getDirectionalDataSizeScore(test.sendBytes, test.receiveBytes, test.direction, 0, 3)
+			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
+
+			require.InDelta(test.expectedScore, score, 0.001, "Expected score to be %v, got %v", test.expectedScore, score)
+			require.Equal(test.expectedUniqueSizes, sizes, "Expected unique sizes to be %v, got %v", test.expectedUniqueSizes, sizes)
+			require.Equal(test.expectedUniqueSizeCounts, sizeCounts, "Expected unique size counts to be %v, got %v", test.expectedUniqueSizeCounts, sizeCounts)
+		})
+	}
+}
+
+func TestGetIntervalPercentiles(t *testing.T) {
+	tests := []struct {
+		name           string
+		intervals      []int64
+		intervalCounts []int64
+		expected       []int64
+		expectedError  bool
+	}{
+		{
+			name:           "Single Interval",
+			intervals:      []int64{60},
+			intervalCounts: []int64{100},
+			expected:       []int64{60, 60, 60},
+		},
+		{
+			name:           "Mostly Regular With Outliers",
+			intervals:      []int64{58, 60, 62, 300},
+			intervalCounts: []int64{10, 70, 19, 1},
+			expected:       []int64{60, 62, 62},
+		},
+		{
+			name:           "Long Tail",
+			intervals:      []int64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
+			intervalCounts: []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+			expected:       []int64{50, 90, 100},
+		},
+		{
+			name:           "Zero Intervals Are Skipped",
+			intervals:      []int64{0, 30, 60},
+			intervalCounts: []int64{50, 1, 3},
+			expected:       []int64{60, 60, 60},
+		},
+		{
+			name:           "Only Zero Intervals",
+			intervals:      []int64{0},
+			intervalCounts: []int64{5},
+			expectedError:  true,
+		},
+		{
+			name:           "Mismatched Lengths",
+			intervals:      []int64{30, 60},
+			intervalCounts: []int64{1},
+			expectedError:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			percentiles, err := getIntervalPercentiles(test.intervals, test.intervalCounts, 50, 90, 99)
+			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
+			if test.expectedError {
+				return
+			}
+			require.Equal(test.expected, percentiles, "Expected percentiles to be %v, got %v", test.expected, percentiles)
+		})
+	}
+
+	t.Run("Invalid Percentile", func(t *testing.T) {
+		_, err := getIntervalPercentiles([]int64{60}, []int64{1}, 0)
+		require.Error(t, err)
+	})
+}
+
+func TestGetCadenceDrift(t *testing.T) {
+	// cadence returns timestamps starting at start with the given number of connections at each interval in turn
+	cadence := func(start int64, phases ...[2]int64) []int64 {
+		tsList := []int64{start}
+		for _, phase := range phases {
+			for i := int64(0); i < phase[1]; i++ {
+				tsList = append(tsList, tsList[len(tsList)-1]+phase[0])
+			}
+		}
+		return tsList
+	}
+
+	tests := []struct {
+		name            string
+		tsList          []int64
+		segments        int
+		ratio           float64
+		expectedFrom    int64
+		expectedTo      int64
+		expectedDrifted bool
+	}{
+		{
+			name:            "Shift From 60s To 300s",
+			tsList:          cadence(1717236000, [2]int64{60, 40}, [2]int64{300, 40}),
+			segments:        4,
+			ratio:           2,
+			expectedFrom:    60,
+			expectedTo:      300,
+			expectedDrifted: true,
+		},
+		{
+			name:            "Shift From 300s To 60s",
+			tsList:          cadence(1717236000, [2]int64{300, 30}, [2]int64{60, 30}),
+			segments:        2,
+			ratio:           2,
+			expectedFrom:    300,
+			expectedTo:      60,
+			expectedDrifted: true,
+		},
+		{
+			name:            "Shift Mid Segment",
+			tsList:          cadence(1717236000, [2]int64{60, 50}, [2]int64{300, 30}),
+			segments:        4,
+			ratio:           2,
+			expectedFrom:    60,
+			expectedTo:      300,
+			expectedDrifted: true,
+		},
+		{
+			name:         "Constant Interval",
+			tsList:       cadence(1717236000, [2]int64{60, 80}),
+			segments:     4,
+			ratio:        2,
+			expectedFrom: 60,
+			expectedTo:   60,
+		},
+		{
+			name:         "Shift Below Ratio",
+			tsList:       cadence(1717236000, [2]int64{60, 40}, [2]int64{90, 40}),
+			segments:     4,
+			ratio:        2,
+			expectedFrom: 60,
+			expectedTo:   90,
+		},
+		{
+			name: "Irregular Segment Is Skipped",
+			tsList: cadence(1717236000, [][2]int64{
+				{60, 20},
+				{7, 1}, {500, 1}, {13, 1}, {900, 1}, {31, 1}, {250, 1}, {3, 1}, {700, 1}, {45, 1}, {1200, 1},
+				{9, 1}, {333, 1}, {77, 1}, {1500, 1}, {21, 1}, {640, 1}, {5, 1}, {980, 1}, {111, 1}, {430, 1},
+				{300, 20},
+			}...),
+			segments:        3,
+			ratio:           2,
+			expectedFrom:    60,
+			expectedTo:      300,
+			expectedDrifted: true,
+		},
+		{
+			name:     "Too Few Connections",
+			tsList:   cadence(1717236000, [2]int64{60, 3}, [2]int64{300, 3}),
+			segments: 4,
+			ratio:    2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, drifted := getCadenceDrift(test.tsList, test.segments, test.ratio, 0, 3)
+			require.Equal(t, test.expectedDrifted, drifted, "drift should be detected: %v", test.expectedDrifted)
+			require.Equal(t, test.expectedFrom, from, "interval before the shift should match")
+			require.Equal(t, test.expectedTo, to, "interval after the shift should match")
+		})
+	}
+}
+
+func TestGetNonZeroMode(t *testing.T) {
+	require.Equal(t, int64(60), getNonZeroMode([]int64{0, 60, 300}, []int64{50, 10, 5}), "zero intervals should be skipped")
+	require.Equal(t, int64(60), getNonZeroMode([]int64{60, 300}, []int64{5, 5}), "ties should prefer the shorter interval")
+	require.Equal(t, int64(0), getNonZeroMode([]int64{0}, []int64{5}))
+}
+
 func TestCalculateStatisticalScore(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -655,7 +1001,7 @@ func TestCalculateStatisticalScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			score, skew, mad, err := calculateStatisticalScore(test.values, test.defaultMadScore)
+			score, skew, mad, err := calculateStatisticalScore(test.values, test.defaultMadScore, 0, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
@@ -884,7 +1230,7 @@ func TestGetDurationScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			coverage, consistency, score, err := getDurationScore(test.datasetMin, test.datasetMax, test.histMin, test.histMax, test.totalBars, test.longestConsecutiveRun, test.minHoursThreshold, test.idealConsistencyHours)
+			coverage, consistency, score, err := getDurationScore(test.datasetMin, test.datasetMax, test.histMin, test.histMax, test.totalBars, test.longestConsecutiveRun, test.minHoursThreshold, test.idealConsistencyHours, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", false, err)
@@ -903,7 +1249,7 @@ func TestGetHistogramScore(t *testing.T) {
 		name                       string
 		datasetMin                 int64
 		datasetMax                 int64
-		tsList                     []uint32
+		tsList                     []int64
 		modalSensitivity           float64
 		bimodalOutlierRemoval      int
 		minHoursForBimodalAnalysis int
@@ -920,7 +1266,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:       "Simple Number List",
 			datasetMin: 1,
 			datasetMax: 11,
-			tsList: []uint32{
+			tsList: []int64{
 				1,
 				2, 2,
 				3, 3, 3,
@@ -948,7 +1294,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Regular Intervals",
 			datasetMin:                 1517338924,
 			datasetMax:                 1517338924 + 24*3600, // 24 hours later
-			tsList:                     []uint32{1517338924, 1517338924 + 1*3600, 1517338924 + 2*3600, 1517338924 + 3*3600, 1517338924 + 4*3600, 1517338924 + 5*3600, 1517338924 + 6*3600, 1517338924 + 7*3600, 1517338924 + 8*3600, 1517338924 + 9*3600, 1517338924 + 10*3600, 1517338924 + 11*3600, 1517338924 + 12*3600, 1517338924 + 13*3600, 1517338924 + 14*3600, 1517338924 + 15*3600, 1517338924 + 16*3600, 1517338924 + 17*3600, 1517338924 + 18*3600, 1517338924 + 19*3600, 1517338924 + 20*3600, 1517338924 + 21*3600, 1517338924 + 22*3600, 1517338924 + 23*3600},
+			tsList:                     []int64{1517338924, 1517338924 + 1*3600, 1517338924 + 2*3600, 1517338924 + 3*3600, 1517338924 + 4*3600, 1517338924 + 5*3600, 1517338924 + 6*3600, 1517338924 + 7*3600, 1517338924 + 8*3600, 1517338924 + 9*3600, 1517338924 + 10*3600, 1517338924 + 11*3600, 1517338924 + 12*3600, 1517338924 + 13*3600, 1517338924 + 14*3600, 1517338924 + 15*3600, 1517338924 + 16*3600, 1517338924 + 17*3600, 1517338924 + 18*3600, 1517338924 + 19*3600, 1517338924 + 20*3600, 1517338924 + 21*3600, 1517338924 + 22*3600, 1517338924 + 23*3600},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 11,
@@ -969,7 +1315,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Closely-Valued Timestamps",
 			datasetMin:                 98,
 			datasetMax:                 102,
-			tsList:                     []uint32{98, 99, 99, 100, 100, 100, 101, 101, 102},
+			tsList:                     []int64{98, 99, 99, 100, 100, 100, 101, 101, 102},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 6,
@@ -991,7 +1337,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Random Intervals, CV > 1",
 			datasetMin:                 0,
 			datasetMax:                 1000000,
-			tsList:                     []uint32{524885, 1, 5000, 98654, 50, 41, 965842, 3, 12001, 200400, 104001, 199999},
+			tsList:                     []int64{524885, 1, 5000, 98654, 50, 41, 965842, 3, 12001, 200400, 104001, 199999},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 6,
@@ -1013,7 +1359,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Bimodal Histogram",
 			datasetMin:                 0,
 			datasetMax:                 100,
-			tsList:                     []uint32{1, 2, 3, 4, 15, 21, 22, 23, 24, 35, 41, 42, 43, 44, 55, 61, 62, 63, 64, 75, 81, 82, 83, 84, 95},
+			tsList:                     []int64{1, 2, 3, 4, 15, 21, 22, 23, 24, 35, 41, 42, 43, 44, 55, 61, 62, 63, 64, 75, 81, 82, 83, 84, 95},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 6,
@@ -1031,7 +1377,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Histogram that has Gaps and a Wraparound Timestamp Run",
 			datasetMin:                 0,
 			datasetMax:                 250,
-			tsList:                     []uint32{10, 20, 100, 110, 110, 220},
+			tsList:                     []int64{10, 20, 100, 110, 110, 220},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 6,
@@ -1049,7 +1395,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Single Bar Histogram",
 			datasetMin:                 1517338924,
 			datasetMax:                 1517338924 + 24*3600, // 24 hours later
-			tsList:                     []uint32{1517338924, 1517338924 + 60, 1517338924 + 120, 1517338924 + 180, 1517338924 + 240, 1517338924 + 300, 1517338924 + 360, 1517338924 + 420, 1517338924 + 480, 1517338924 + 540},
+			tsList:                     []int64{1517338924, 1517338924 + 60, 1517338924 + 120, 1517338924 + 180, 1517338924 + 240, 1517338924 + 300, 1517338924 + 360, 1517338924 + 420, 1517338924 + 480, 1517338924 + 540},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 11,
@@ -1069,7 +1415,7 @@ func TestGetHistogramScore(t *testing.T) {
 			name:                       "Connection with Single Bar Histogram and MinHoursForBimodal Analysis Set to < 3",
 			datasetMin:                 1517338924,
 			datasetMax:                 1517338924 + 24*3600, // 24 hours later
-			tsList:                     []uint32{1517338924, 1517338924 + 60, 1517338924 + 120, 1517338924 + 180, 1517338924 + 240, 1517338924 + 300, 1517338924 + 360, 1517338924 + 420, 1517338924 + 480, 1517338924 + 540},
+			tsList:                     []int64{1517338924, 1517338924 + 60, 1517338924 + 120, 1517338924 + 180, 1517338924 + 240, 1517338924 + 300, 1517338924 + 360, 1517338924 + 420, 1517338924 + 480, 1517338924 + 540},
 			modalSensitivity:           0.05,
 			bimodalOutlierRemoval:      1,
 			minHoursForBimodalAnalysis: 1, // < 3
@@ -1087,21 +1433,21 @@ func TestGetHistogramScore(t *testing.T) {
 			name:          "Empty Timestamp List",
 			datasetMin:    0,
 			datasetMax:    10,
-			tsList:        []uint32{},
+			tsList:        []int64{},
 			expectedError: true,
 		},
 		{
 			name:          "Dataset Min > Dataset Max",
 			datasetMin:    1,
 			datasetMax:    0,
-			tsList:        []uint32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			tsList:        []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
 			expectedError: true,
 		},
 		{
 			name:          "Dataset Min == Dataset Max",
 			datasetMin:    1,
 			datasetMax:    1,
-			tsList:        []uint32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+			tsList:        []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
 			expectedError: true,
 		},
 	}
@@ -1111,17 +1457,22 @@ func TestGetHistogramScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			freqList, freqCount, totalBars, longestRun, score, err := getHistogramScore(test.datasetMin, test.datasetMax, test.tsList, test.modalSensitivity, test.bimodalOutlierRemoval, test.minHoursForBimodalAnalysis, test.beaconTimeSpan)
+			hist, err := GetHistogramScore(test.datasetMin, test.datasetMax, test.tsList, test.modalSensitivity, test.bimodalOutlierRemoval, test.minHoursForBimodalAnalysis, test.beaconTimeSpan, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", false, err)
 
 			// check the calculated values
-			require.Equal(test.expectedHistogram, freqList, "Expected frequency list to be %v, got %v", test.expectedHistogram, freqList)
-			require.Equal(test.expectedFreqCount, freqCount, "Expected frequency count to be %v, got %v", test.expectedFreqCount, freqCount)
-			require.Equal(test.expectedTotalBars, totalBars, "Expected total bars to be %v, got %v", test.expectedTotalBars, totalBars)
-			require.Equal(test.expectedLongestRun, longestRun, "Expected longest run to be %v, got %v", test.expectedLongestRun, longestRun)
-			require.InDelta(test.expectedScore, score, 0.001, "Expected score to be %v, got %v", test.expectedScore, score)
+			require.Equal(test.expectedHistogram, hist.Counts, "Expected frequency list to be %v, got %v", test.expectedHistogram, hist.Counts)
+			require.Equal(test.expectedFreqCount, hist.FreqCount, "Expected frequency count to be %v, got %v", test.expectedFreqCount, hist.FreqCount)
+			require.Equal(test.expectedTotalBars, hist.TotalBars, "Expected total bars to be %v, got %v", test.expectedTotalBars, hist.TotalBars)
+			require.Equal(test.expectedLongestRun, hist.LongestRun, "Expected longest run to be %v, got %v", test.expectedLongestRun, hist.LongestRun)
+			require.InDelta(test.expectedScore, hist.Score, 0.001, "Expected score to be %v, got %v", test.expectedScore, hist.Score)
+
+			// the histogram has one more bin edge than bins
+			if !test.expectedError {
+				require.Len(hist.BinEdges, len(hist.Counts)+1, "Expected one more bin edge than bins")
+			}
 
 		})
 	}
@@ -1259,6 +1610,38 @@ func TestCalculateBowleySkewness(t *testing.T) {
 	}
 }
 
+func TestBowleySkewness(t *testing.T) {
+	t.Run("Matches Internal Calculation", func(t *testing.T) {
+		values := []float64{10, 20, 30, 40, 100}
+		expectedSkew, expectedScore, err := calculateBowleySkewness(values)
+		require.NoError(t, err)
+
+		skew, score, err := BowleySkewness(values)
+		require.NoError(t, err)
+		require.InDelta(t, expectedSkew, skew, 0.001)
+		require.InDelta(t, expectedScore, score, 0.001)
+	})
+
+	t.Run("Median Equal to Quartile", func(t *testing.T) {
+		skew, score, err := BowleySkewness([]float64{10, 10, 10, 50, 90})
+		require.NoError(t, err)
+		require.InDelta(t, 0, skew, 0.001)
+		require.InDelta(t, 1, score, 0.001, "the score should be 1 when the median is equal to a quartile")
+	})
+
+	t.Run("Input Not Modified", func(t *testing.T) {
+		values := []float64{100, 10, 40, 20, 30}
+		_, _, err := BowleySkewness(values)
+		require.NoError(t, err)
+		require.Equal(t, []float64{100, 10, 40, 20, 30}, values)
+	})
+
+	t.Run("Too Few Elements", func(t *testing.T) {
+		_, _, err := BowleySkewness([]float64{1, 2})
+		require.ErrorIs(t, err, ErrInputSliceTooShort)
+	})
+}
+
 func TestCalculateDistinctCounts(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -1349,6 +1732,7 @@ func TestCalculateMedianAbsoluteDeviation(t *testing.T) {
 		name          string
 		inputData     []float64
 		defaultScore  float64
+		tolerance     float64
 		expectedMAD   float64
 		expectedScore float64
 		expectError   bool
@@ -1385,6 +1769,24 @@ func TestCalculateMedianAbsoluteDeviation(t *testing.T) {
 			expectedScore: 0.6154,
 			expectError:   false,
 		},
+		{
+			name:          "Simple List With Tolerance",
+			inputData:     []float64{1, 2, 2, 3, 3, 3, 4, 5, 5},
+			defaultScore:  1,
+			tolerance:     0.5,
+			expectedMAD:   1,
+			expectedScore: 0.8333,
+			expectError:   false,
+		},
+		{
+			name:          "Bigger Numbers With Tolerance",
+			inputData:     []float64{1000, 1500, 2000, 2500, 3000, 3500, 4000, 4500, 5000, 5500},
+			defaultScore:  1,
+			tolerance:     0.2,
+			expectedMAD:   1250,
+			expectedScore: 0.6923,
+			expectError:   false,
+		},
 		{
 			name:          "Empty Slice",
 			inputData:     []float64{},
@@ -1400,7 +1802,7 @@ func TestCalculateMedianAbsoluteDeviation(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			mad, score, err := calculateMedianAbsoluteDeviation(test.inputData, test.defaultScore)
+			mad, score, err := calculateMedianAbsoluteDeviation(test.inputData, test.defaultScore, test.tolerance)
 
 			// check if an error was expected
 			require.Equal(test.expectError, err != nil, "error should match expected value")
@@ -1448,7 +1850,7 @@ func TestComputeHistogramBins(t *testing.T) {
 			// step: (maxTS - minTS) / total edges - 1 = 24 / 24 = 1
 			// first edge: 1, last edge: 24
 			// bin edges: first edge, first edge + step, first edge + 2*step, ... , last edge
-			// expectedBinEdges: []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24},
+			// expectedBinEdges: []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24},
 			expectedBinEdges: []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24},
 			expectedError:    false,
 		},
@@ -1602,7 +2004,7 @@ func TestCalculateCoefficientOfVariationScore(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
-			score, err := calculateCoefficientOfVariationScore(test.freqList)
+			score, err := calculateCoefficientOfVariationScore(test.freqList, 3)
 
 			// check if an error was expected
 			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
@@ -1780,7 +2182,7 @@ func TestCalculateBimodalFitScore(t *testing.T) {
 			require := require.New(t)
 
 			// run the function
-			score, err := calculateBimodalFitScore(tc.freqCount, tc.totalBars, tc.modalOutlierRemoval, tc.minHoursForBimodalAnalysis)
+			score, err := calculateBimodalFitScore(tc.freqCount, tc.totalBars, tc.modalOutlierRemoval, tc.minHoursForBimodalAnalysis, 3)
 
 			// check if an error was expected
 			require.Equal(tc.expectedError, err != nil, "Expected error to be %v, got %v", tc.expectedError, err)
@@ -1797,7 +2199,7 @@ func TestCreateHistogram(t *testing.T) {
 	tests := []struct {
 		name               string
 		binEdges           []float64
-		tsList             []uint32
+		tsList             []int64
 		modalSensitivity   float64
 		expectedHistogram  []int
 		expectedFreqCount  map[int32]int32
@@ -1809,7 +2211,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Simple Flat Histogram",
 			binEdges:           []float64{0, 10, 20, 30},
-			tsList:             []uint32{1, 5, 11, 15, 21, 25},
+			tsList:             []int64{1, 5, 11, 15, 21, 25},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int{2, 2, 2},
 			expectedFreqCount:  map[int32]int32{2: 3},
@@ -1820,7 +2222,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Multiple Bins, but All Timestamps in One",
 			binEdges:           []float64{0, 100, 200},
-			tsList:             []uint32{10, 20, 30, 40},
+			tsList:             []int64{10, 20, 30, 40},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int{4, 0},
 			expectedFreqCount:  map[int32]int32{4: 1},
@@ -1831,7 +2233,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Single Bin",
 			binEdges:           []float64{0, 100},
-			tsList:             []uint32{10, 20, 30, 40, 50},
+			tsList:             []int64{10, 20, 30, 40, 50},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int{5},
 			expectedFreqCount:  map[int32]int32{5: 1},
@@ -1842,7 +2244,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Histogram with Gaps and Wraparound Timestamp Run",
 			binEdges:           []float64{0, 50, 100, 150, 200, 250},
-			tsList:             []uint32{10, 20, 100, 110, 110, 220},
+			tsList:             []int64{10, 20, 100, 110, 110, 220},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int{2, 0, 3, 0, 1},
 			expectedFreqCount:  map[int32]int32{1: 1, 2: 1, 3: 1},
@@ -1853,7 +2255,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Bimodal Histogram",
 			binEdges:           []float64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
-			tsList:             []uint32{1, 2, 3, 4, 15, 21, 22, 23, 24, 35, 41, 42, 43, 44, 55, 61, 62, 63, 64, 75, 81, 82, 83, 84, 95},
+			tsList:             []int64{1, 2, 3, 4, 15, 21, 22, 23, 24, 35, 41, 42, 43, 44, 55, 61, 62, 63, 64, 75, 81, 82, 83, 84, 95},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int{4, 1, 4, 1, 4, 1, 4, 1, 4, 1},
 			expectedFreqCount:  map[int32]int32{1: 5, 4: 5},
@@ -1863,7 +2265,7 @@ func TestCreateHistogram(t *testing.T) {
 		},
 		{
 			name:               "Last Value in Value List Equal to Last Bin Edge",
-			tsList:             []uint32{98, 99, 99, 100, 100, 100, 101, 101, 102},
+			tsList:             []int64{98, 99, 99, 100, 100, 100, 101, 101, 102},
 			modalSensitivity:   0.05,
 			binEdges:           []float64{98, 98.5, 99, 99.5, 100, 100.5, 101, 101.5, 102},
 			expectedHistogram:  []int{1, 0, 2, 0, 3, 0, 2, 1},
@@ -1875,7 +2277,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:     "High Modal Sensitivity",
 			binEdges: []float64{0, 100, 200, 300, 400, 500, 600, 700, 800, 900, 1000},
-			tsList: []uint32{
+			tsList: []int64{
 				10, 15, 20, 25, 30, 35, 40, 45, 50,
 				110, 115, 120, 125, 130, 135, 140, 145, 150, 155,
 				210, 215, 220, 225, 230, 235, 240, 245, 250, 255, 260,
@@ -1897,7 +2299,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:     "Low Modal Sensitivity",
 			binEdges: []float64{0, 100, 200, 300, 400, 500, 600, 700, 800, 900, 1000},
-			tsList: []uint32{
+			tsList: []int64{
 				10, 15, 20, 25, 30, 35, 40, 45, 50,
 				110, 115, 120, 125, 130, 135, 140, 145, 150, 155,
 				210, 215, 220, 225, 230, 235, 240, 245, 250, 255, 260,
@@ -1919,7 +2321,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:     "Bimodal - High Sensitivity",
 			binEdges: []float64{0, 100, 200, 300, 400, 500, 600, 700, 800, 900, 1000, 1100, 1200, 1300, 1400, 1500, 1600, 1700, 1800, 1900, 2000},
-			tsList: []uint32{
+			tsList: []int64{
 				10, 15, 20, 25, 30, 35, 40, 45, 50,
 				110, 115, 120, 125, 130, 135, 140, 145, 150, 155,
 				210, 215, 220, 225, 230, 235, 240, 245, 250, 255, 260,
@@ -1950,7 +2352,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:     "Bimodal - Low Sensitivity",
 			binEdges: []float64{0, 100, 200, 300, 400, 500, 600, 700, 800, 900, 1000, 1100, 1200, 1300, 1400, 1500, 1600, 1700, 1800, 1900, 2000},
-			tsList: []uint32{
+			tsList: []int64{
 				10, 15, 20, 25, 30, 35, 40, 45, 50,
 				110, 115, 120, 125, 130, 135, 140, 145, 150, 155,
 				210, 215, 220, 225, 230, 235, 240, 245, 250, 255, 260,
@@ -1981,7 +2383,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Unsorted Slice",
 			binEdges:           []float64{0, 100, 200, 300, 400, 500},
-			tsList:             []uint32{450, 10, 30, 205, 299},
+			tsList:             []int64{450, 10, 30, 205, 299},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int{2, 0, 2, 0, 1},
 			expectedFreqCount:  map[int32]int32{1: 1, 2: 2},
@@ -1992,7 +2394,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Invalid Bin Edges",
 			binEdges:           []float64{10},
-			tsList:             []uint32{15, 22, 35},
+			tsList:             []int64{15, 22, 35},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int(nil),
 			expectedFreqCount:  map[int32]int32(nil),
@@ -2004,7 +2406,7 @@ func TestCreateHistogram(t *testing.T) {
 		{
 			name:               "Empty Timestamps Slice",
 			binEdges:           []float64{10, 20, 30, 40},
-			tsList:             []uint32{},
+			tsList:             []int64{},
 			modalSensitivity:   0.05,
 			expectedHistogram:  []int(nil),
 			expectedFreqCount:  map[int32]int32(nil),
@@ -2038,6 +2440,39 @@ func TestCreateHistogram(t *testing.T) {
 	}
 }
 
+func TestHistogramJSON(t *testing.T) {
+	require := require.New(t)
+
+	binEdges := []float64{0, 10, 20, 30}
+	counts, freqCount, totalBars, longestRun, err := createHistogram(binEdges, []int64{1, 5, 11, 15, 21, 25}, 0.05)
+	require.NoError(err)
+
+	hist := Histogram{
+		BinEdges:   binEdges,
+		Counts:     counts,
+		FreqCount:  freqCount,
+		TotalBars:  totalBars,
+		LongestRun: longestRun,
+		Score:      1,
+	}
+
+	data, err := json.Marshal(hist)
+	require.NoError(err)
+	require.JSONEq(`{
+		"bin_edges": [0, 10, 20, 30],
+		"counts": [2, 2, 2],
+		"freq_count": {"2": 3},
+		"total_bars": 3,
+		"longest_run": 3,
+		"score": 1
+	}`, string(data))
+
+	// the histogram should survive a round trip so that it can be read back by a frontend
+	var decoded Histogram
+	require.NoError(json.Unmarshal(data, &decoded))
+	require.Equal(hist, decoded)
+}
+
 func TestGetFrequencyCounts(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -2194,3 +2629,178 @@ func TestGetFrequencyCounts(t *testing.T) {
 		})
 	}
 }
+
+func TestGetWindowBins(t *testing.T) {
+	day := int64(24 * 3600)
+	tests := []struct {
+		name         string
+		datasetMin   int64
+		datasetMax   int64
+		windowHours  int
+		expectedBins int
+	}{
+		{name: "One Day Span", datasetMin: 0, datasetMax: day, windowHours: 8, expectedBins: 8},
+		{name: "Two Day Span", datasetMin: 0, datasetMax: 2 * day, windowHours: 8, expectedBins: 4},
+		{name: "Window Longer Than Span", datasetMin: 0, datasetMax: day / 2, windowHours: 24, expectedBins: 24},
+		{name: "Window Shorter Than a Bin", datasetMin: 0, datasetMax: 10 * day, windowHours: 1, expectedBins: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expectedBins, getWindowBins(test.datasetMin, test.datasetMax, 24, test.windowHours))
+		})
+	}
+}
+
+func TestGetWindowedScores(t *testing.T) {
+	// connections every hour from 9 to 5
+	businessHours := make([]int, 24)
+	for i := 9; i < 17; i++ {
+		businessHours[i] = 60
+	}
+
+	// connections every hour from 8pm to 4am, which wraps around the end of the histogram
+	overnight := make([]int, 24)
+	for i := 20; i < 28; i++ {
+		overnight[i%24] = 60
+	}
+
+	// connections every hour of the day
+	fullDay := make([]int, 24)
+	for i := range fullDay {
+		fullDay[i] = 60
+	}
+
+	tests := []struct {
+		name              string
+		histogram         []int
+		windowBins        int
+		expectedHistScore float64
+		expectedDurScore  float64
+		expectedError     bool
+	}{
+		{
+			name:              "Business Hours in Window",
+			histogram:         businessHours,
+			windowBins:        8,
+			expectedHistScore: 1,
+			expectedDurScore:  1,
+		},
+		{
+			name:              "Overnight Wraps Around",
+			histogram:         overnight,
+			windowBins:        8,
+			expectedHistScore: 1,
+			expectedDurScore:  1,
+		},
+		{
+			name:              "Business Hours Longer Window",
+			histogram:         businessHours,
+			windowBins:        12,
+			expectedHistScore: 0.293,
+			expectedDurScore:  0.667,
+		},
+		{
+			name:              "Full Day",
+			histogram:         fullDay,
+			windowBins:        8,
+			expectedHistScore: 1,
+			expectedDurScore:  1,
+		},
+		{
+			name:              "No Connections",
+			histogram:         make([]int, 24),
+			windowBins:        8,
+			expectedHistScore: 0,
+			expectedDurScore:  0,
+		},
+		{
+			name:          "Window Larger Than Histogram",
+			histogram:     businessHours,
+			windowBins:    25,
+			expectedError: true,
+		},
+		{
+			name:          "Empty Histogram",
+			histogram:     []int{},
+			windowBins:    8,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			histScore, durScore, err := getWindowedScores(test.histogram, test.windowBins, 0.05, 1, 11, 6, 12, 3)
+			require.Equal(test.expectedError, err != nil, "Expected error to be %v, got %v", test.expectedError, err)
+			require.InDelta(test.expectedHistScore, histScore, 0.001, "Expected histogram score to be %v, got %v", test.expectedHistScore, histScore)
+			require.InDelta(test.expectedDurScore, durScore, 0.001, "Expected duration score to be %v, got %v", test.expectedDurScore, durScore)
+		})
+	}
+}
+
+func TestGetLongestRun(t *testing.T) {
+	require.Equal(t, 0, getLongestRun([]int{0, 0, 0}))
+	require.Equal(t, 3, getLongestRun([]int{1, 1, 0, 1, 1, 1, 0}))
+	// runs don't wrap around the end of the histogram
+	require.Equal(t, 2, getLongestRun([]int{1, 1, 0, 0, 1, 1}))
+}
+
+// benchmarkTimestamps returns a day of timestamps that are interval seconds apart
+func benchmarkTimestamps(interval int64) []int64 {
+	var timestamps []int64
+	for ts := int64(0); ts < 86400; ts += interval {
+		timestamps = append(timestamps, ts)
+	}
+	return timestamps
+}
+
+func BenchmarkCreateHistogram(b *testing.B) {
+	binEdges, err := computeHistogramBins(0, 86400, 24)
+	require.NoError(b, err)
+
+	for _, bench := range []struct {
+		name     string
+		interval int64
+	}{
+		{name: "Every Second", interval: 1},
+		{name: "Every Minute", interval: 60},
+		{name: "Every Hour", interval: 3600},
+	} {
+		timestamps := benchmarkTimestamps(bench.interval)
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _, _, _, err := createHistogram(binEdges, timestamps, 0.05)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetFrequencyCounts(b *testing.B) {
+	histogram := []int{60, 58, 61, 60, 0, 0, 59, 60, 120, 118, 121, 120, 60, 61, 59, 60, 0, 0, 60, 58, 61, 60, 120, 119}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _, _, err := getFrequencyCounts(histogram, 0.05)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetHistogramScore(b *testing.B) {
+	timestamps := benchmarkTimestamps(60)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := GetHistogramScore(0, 86400, timestamps, 0.05, 1, 11, 24, 3)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
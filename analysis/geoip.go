@@ -0,0 +1,140 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var ErrUnexpectedGeoIPDatabase = errors.New("unexpected geoip database type")
+
+// Geolocation holds the country and autonomous system of a connection's external destination
+type Geolocation struct {
+	Country string `ch:"dst_country"` // ISO 3166-1 alpha-2 country code
+	ASN     uint32 `ch:"dst_asn"`
+	ASOrg   string `ch:"dst_as_org"`
+}
+
+// GeoIPLookup resolves IP addresses against MaxMind GeoLite2 country and ASN databases
+type GeoIPLookup struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+type geoIPCountryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type geoIPASNRecord struct {
+	ASN   uint32 `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// NewGeoIPLookup opens the configured GeoIP databases, it returns nil if neither database is configured
+func NewGeoIPLookup(cfg config.GeoIP) (*GeoIPLookup, error) {
+	if cfg.CountryDatabasePath == "" && cfg.ASNDatabasePath == "" {
+		return nil, nil
+	}
+
+	lookup := &GeoIPLookup{}
+
+	if cfg.CountryDatabasePath != "" {
+		reader, err := openGeoIPDatabase(cfg.CountryDatabasePath, "Country", "City")
+		if err != nil {
+			return nil, err
+		}
+		lookup.country = reader
+	}
+
+	if cfg.ASNDatabasePath != "" {
+		reader, err := openGeoIPDatabase(cfg.ASNDatabasePath, "ASN")
+		if err != nil {
+			lookup.Close()
+			return nil, err
+		}
+		lookup.asn = reader
+	}
+
+	return lookup, nil
+}
+
+// openGeoIPDatabase opens a MaxMind database and verifies that its type contains one of the expected names
+func openGeoIPDatabase(path string, expectedTypes ...string) (*maxminddb.Reader, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open geoip database %s: %w", path, err)
+	}
+
+	for _, expected := range expectedTypes {
+		if strings.Contains(reader.Metadata.DatabaseType, expected) {
+			return reader, nil
+		}
+	}
+
+	reader.Close()
+	return nil, fmt.Errorf("%w: %s is a %s database, expected one of %v", ErrUnexpectedGeoIPDatabase, path, reader.Metadata.DatabaseType, expectedTypes)
+}
+
+// Lookup returns the geolocation of the IP address, fields that can't be resolved are left empty
+func (g *GeoIPLookup) Lookup(ip net.IP) Geolocation {
+	var geo Geolocation
+	if g == nil || ip == nil {
+		return geo
+	}
+
+	if g.country != nil {
+		var record geoIPCountryRecord
+		if err := g.country.Lookup(ip, &record); err == nil {
+			geo.Country = record.Country.ISOCode
+		}
+	}
+
+	if g.asn != nil {
+		var record geoIPASNRecord
+		if err := g.asn.Lookup(ip, &record); err == nil {
+			geo.ASN = record.ASN
+			geo.ASOrg = record.ASOrg
+		}
+	}
+
+	return geo
+}
+
+// Close closes the underlying databases
+func (g *GeoIPLookup) Close() {
+	if g == nil {
+		return
+	}
+	if g.country != nil {
+		g.country.Close()
+	}
+	if g.asn != nil {
+		g.asn.Close()
+	}
+}
+
+// externalDestination returns the external IP address that a connection was made to, or nil if there isn't one
+// SNI connections don't have a single destination IP, so the first external server IP is used instead
+func externalDestination(entry *AnalysisResult, filter *config.Filter) net.IP {
+	if entry.Dst != nil && !entry.Dst.IsUnspecified() {
+		if filter.CheckIfInternal(entry.Dst) {
+			return nil
+		}
+		return entry.Dst
+	}
+
+	for _, ip := range entry.ServerIPs {
+		if ip != nil && !ip.IsUnspecified() && !filter.CheckIfInternal(ip) {
+			return ip
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGeoIPLookup(t *testing.T) {
+	t.Run("Disabled Without Paths", func(t *testing.T) {
+		lookup, err := NewGeoIPLookup(config.GeoIP{})
+		require.NoError(t, err)
+		require.Nil(t, lookup, "geoip lookups should be disabled when no databases are configured")
+
+		// a disabled lookup returns an empty geolocation and can be closed
+		require.Equal(t, Geolocation{}, lookup.Lookup(net.ParseIP("8.8.8.8")))
+		lookup.Close()
+	})
+
+	t.Run("Missing Database", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "GeoLite2-ASN.mmdb")
+		_, err := NewGeoIPLookup(config.GeoIP{ASNDatabasePath: missing})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), missing, "error should name the database that couldn't be opened")
+	})
+}
+
+func TestExternalDestination(t *testing.T) {
+	_, internal, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	filter := &config.Filter{InternalSubnets: []*net.IPNet{internal}}
+
+	tests := []struct {
+		name     string
+		entry    AnalysisResult
+		expected net.IP
+	}{
+		{
+			name:     "External Destination",
+			entry:    AnalysisResult{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("93.184.216.34")},
+			expected: net.ParseIP("93.184.216.34"),
+		},
+		{
+			name:     "Internal Destination",
+			entry:    AnalysisResult{Src: net.ParseIP("93.184.216.34"), Dst: net.ParseIP("10.0.0.1")},
+			expected: nil,
+		},
+		{
+			name:     "SNI Uses First External Server IP",
+			entry:    AnalysisResult{Src: net.ParseIP("10.0.0.1"), Dst: net.IPv6unspecified, ServerIPs: []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("93.184.216.34")}},
+			expected: net.ParseIP("93.184.216.34"),
+		},
+		{
+			name:     "SNI Without Server IPs",
+			entry:    AnalysisResult{Src: net.ParseIP("10.0.0.1"), Dst: net.IPv6unspecified},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, externalDestination(&test.entry, filter))
+		})
+	}
+}
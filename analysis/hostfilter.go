@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// HostFilter limits analysis to the connections of a set of hosts. A connection is analyzed when its source is
+// one of the Src hosts and its destination is one of the Dst hosts, an empty list matches every host.
+type HostFilter struct {
+	Src []net.IP
+	Dst []net.IP
+}
+
+// IsSet returns whether the filter limits analysis to any hosts
+func (f HostFilter) IsSet() bool {
+	return len(f.Src) > 0 || len(f.Dst) > 0
+}
+
+// condition returns a SQL condition on the src and dst columns of a table that only matches the connections
+// of the filtered hosts, or true if no hosts are filtered
+func (f HostFilter) condition() string {
+	var conds []string
+	if len(f.Src) > 0 {
+		conds = append(conds, "src IN {only_src:Array(IPv6)}")
+	}
+	if len(f.Dst) > 0 {
+		conds = append(conds, "dst IN {only_dst:Array(IPv6)}")
+	}
+	if len(conds) == 0 {
+		return "true"
+	}
+	return "(" + strings.Join(conds, " AND ") + ")"
+}
+
+// addParameters adds the filtered hosts to the parameters of a query that uses the filter condition
+func (f HostFilter) addParameters(params clickhouse.Parameters) clickhouse.Parameters {
+	params["only_src"] = formatIPArray(f.Src)
+	params["only_dst"] = formatIPArray(f.Dst)
+	return params
+}
+
+// formatIPArray formats a list of IPs as a clickhouse array of IPv6 addresses
+func formatIPArray(ips []net.IP) string {
+	values := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		// IPv4 addresses are stored as IPv4-mapped IPv6 addresses
+		if ip4 := ip.To4(); ip4 != nil {
+			values = append(values, "'::ffff:"+ip4.String()+"'")
+			continue
+		}
+		values = append(values, "'"+ip.String()+"'")
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostFilter(t *testing.T) {
+	tests := []struct {
+		name              string
+		filter            HostFilter
+		expectedSet       bool
+		expectedCondition string
+		expectedSrc       string
+		expectedDst       string
+	}{
+		{
+			name:              "No Hosts",
+			expectedCondition: "true",
+			expectedSrc:       "[]",
+			expectedDst:       "[]",
+		},
+		{
+			name:              "Source Hosts",
+			filter:            HostFilter{Src: []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("10.0.0.6")}},
+			expectedSet:       true,
+			expectedCondition: "(src IN {only_src:Array(IPv6)})",
+			expectedSrc:       "['::ffff:10.0.0.5','::ffff:10.0.0.6']",
+			expectedDst:       "[]",
+		},
+		{
+			name:              "Destination Hosts",
+			filter:            HostFilter{Dst: []net.IP{net.ParseIP("2001:db8::1")}},
+			expectedSet:       true,
+			expectedCondition: "(dst IN {only_dst:Array(IPv6)})",
+			expectedSrc:       "[]",
+			expectedDst:       "['2001:db8::1']",
+		},
+		{
+			name:              "Source And Destination Hosts",
+			filter:            HostFilter{Src: []net.IP{net.ParseIP("10.0.0.5")}, Dst: []net.IP{net.ParseIP("203.0.113.7")}},
+			expectedSet:       true,
+			expectedCondition: "(src IN {only_src:Array(IPv6)} AND dst IN {only_dst:Array(IPv6)})",
+			expectedSrc:       "['::ffff:10.0.0.5']",
+			expectedDst:       "['::ffff:203.0.113.7']",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expectedSet, test.filter.IsSet())
+			require.Equal(t, test.expectedCondition, test.filter.condition())
+
+			params := test.filter.addParameters(clickhouse.Parameters{"min_ts": "0"})
+			require.Equal(t, "0", params["min_ts"], "existing parameters should be kept")
+			require.Equal(t, test.expectedSrc, params["only_src"])
+			require.Equal(t, test.expectedDst, params["only_dst"])
+		})
+	}
+}
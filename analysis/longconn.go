@@ -0,0 +1,44 @@
+package analysis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// boundedDuration returns an expression that bounds the duration of a single connection to the durations that are
+// plausible for long connection scoring, so that one implausible connection can't inflate the total duration of the
+// connections it's summed with. Durations shorter than the minimum, including negative durations from clock skew, are
+// left out, and durations longer than the maximum are clamped to it.
+func boundedDuration(duration string) string {
+	return fmt.Sprintf(`if(%[1]s < {long_conn_min_duration:Float64}, 0,
+		if({long_conn_max_duration:Float64} > 0, least(%[1]s, {long_conn_max_duration:Float64}), %[1]s))`, duration)
+}
+
+// clampedDuration returns a condition that matches the durations that boundedDuration clamps to the maximum
+func clampedDuration(duration string) string {
+	return fmt.Sprintf(`({long_conn_max_duration:Float64} > 0 AND %[1]s > {long_conn_max_duration:Float64})`, duration)
+}
+
+// addLongConnDurationParameters adds the plausible connection durations used by boundedDuration and clampedDuration to
+// the parameters of a query
+func (analyzer *Analyzer) addLongConnDurationParameters(params clickhouse.Parameters) clickhouse.Parameters {
+	bounds := analyzer.Config.Scoring.LongConnectionDurationBounds
+	params["long_conn_min_duration"] = strconv.FormatFloat(bounds.Min, 'f', -1, 64)
+	params["long_conn_max_duration"] = strconv.FormatFloat(maxPlausibleDuration(bounds, analyzer.maxTS.Sub(analyzer.minTS)), 'f', -1, 64)
+	return params
+}
+
+// maxPlausibleDuration returns the longest duration of a single connection that is plausible for long connection
+// scoring. Without a configured maximum, it's the span of the dataset, since zeek can't see a connection for longer
+// than it captured traffic. A maximum of 0 leaves the durations unclamped.
+func maxPlausibleDuration(bounds config.DurationBounds, span time.Duration) float64 {
+	if bounds.Max > 0 {
+		return bounds.Max
+	}
+	return max(span.Seconds(), 0)
+}
@@ -0,0 +1,114 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// parentDomain is the expression that looks up the parent domain of a subdomain of an SNI connection in the table
+// created by CreateParentDomainTable, it's empty for domains that don't share their parent domain with another one
+const parentDomain = `joinGet({parent_domains_table:String}, 'parent', fqdn)`
+
+// groupedSubdomain is the condition that matches the subdomains that were grouped by their parent domain
+const groupedSubdomain = parentDomain + ` != ''`
+
+// ParentDomainCTEs are the CTEs that count the prevalence and find the first seen of the parent domains of SNI
+// connections. They're shared by the parent domain analysis and refresh-modifiers so that both group the subdomains
+// the same way and count them from the same tables. They need the min_ts parameter and the parameter added by
+// CreateParentDomainTable.
+const ParentDomainCTEs = `
+	-- number of internal hosts that connected to or looked up any subdomain of each parent domain
+	parent_domain_prevalence AS (
+		SELECT ` + parentDomain + ` AS parent, uniqExact(src) AS prevalence_total FROM (
+			SELECT DISTINCT fqdn, src FROM usni
+			WHERE src_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND ` + groupedSubdomain + `
+			UNION DISTINCT
+			SELECT DISTINCT fqdn, src FROM udns
+			WHERE src_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND ` + groupedSubdomain + `
+		)
+		GROUP BY parent
+	),
+	-- a parent domain was first seen when its oldest subdomain was
+	parent_domain_history AS (
+		SELECT ` + parentDomain + ` AS parent, nuid, min(first_seen) AS first_seen FROM {metadatabase:Identifier}.historical_first_seen
+		WHERE ` + groupedSubdomain + `
+		GROUP BY parent, nuid
+	)`
+
+// CreateParentDomainTable groups the SNI domains connected to since minTS by their parent domain and stores the groups
+// in a table that the queries using ParentDomainCTEs look them up in, adding its name to the parameters. The parent
+// domains are found with the public suffix list, so the subdomains are grouped before the queries that use them.
+// The table is dropped with database.DropParentDomainTable. Returns false if no parent domain has more than one subdomain.
+func CreateParentDomainTable(db *database.DB, minTS time.Time, params clickhouse.Parameters) (bool, error) {
+	chCtx := db.QueryParameters(clickhouse.Parameters{
+		"min_ts": fmt.Sprintf("%d", minTS.UTC().Unix()),
+	})
+
+	rows, err := db.Conn.Query(chCtx, `--sql
+		SELECT DISTINCT fqdn FROM usni
+		WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+	`)
+	if err != nil {
+		return false, fmt.Errorf("could not retrieve SNI domains: %w", err)
+	}
+	defer rows.Close()
+
+	var fqdns []string
+	for rows.Next() {
+		var fqdn string
+		if err := rows.Scan(&fqdn); err != nil {
+			return false, fmt.Errorf("could not read SNI domain: %w", err)
+		}
+		fqdns = append(fqdns, fqdn)
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("could not read SNI domains: %w", err)
+	}
+
+	subdomains, parents := groupSubdomains(fqdns)
+	table, err := db.CreateParentDomainTable(subdomains, parents)
+	if err != nil {
+		return false, fmt.Errorf("could not store parent domains: %w", err)
+	}
+	params["parent_domains_table"] = table
+
+	return len(subdomains) > 0, nil
+}
+
+// groupSubdomains returns the fqdns that share their parent domain with another fqdn, along with the parent domain of
+// each one. Fqdns that don't have a parent domain, or are the only subdomain of theirs, are left out.
+func groupSubdomains(fqdns []string) ([]string, []string) {
+	children := make(map[string][]string)
+	for _, fqdn := range fqdns {
+		if parent, ok := util.ParentDomain(fqdn); ok {
+			children[parent] = append(children[parent], fqdn)
+		}
+	}
+
+	// sort the parent domains so that the parameters are the same for every query
+	parentDomains := make([]string, 0, len(children))
+	for parent, subdomains := range children {
+		if len(subdomains) > 1 {
+			parentDomains = append(parentDomains, parent)
+		}
+	}
+	sort.Strings(parentDomains)
+
+	var subdomains, parents []string
+	for _, parent := range parentDomains {
+		sorted := slices.Clone(children[parent])
+		sort.Strings(sorted)
+		for _, subdomain := range sorted {
+			subdomains = append(subdomains, subdomain)
+			parents = append(parents, parent)
+		}
+	}
+	return subdomains, parents
+}
@@ -0,0 +1,74 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// portProtoServiceKey returns the SQL expression that formats a connection as port:proto:service. ICMP connections
+// don't have ports, so their type/code is used as the port, and they are listed as the icmp service when zeek
+// didn't detect one. The prefix is the table alias that the columns are selected from, if any. The port and service
+// are normalized with the rules added by addPortProtoServiceParameters.
+func portProtoServiceKey(prefix string) string {
+	return fmt.Sprintf(`if(%[1]sproto = 'icmp',
+		concat(%[1]sicmp_type, '/', %[1]sicmp_code, ':', %[1]sproto, ':', if(%[1]sservice = '', 'icmp', %[2]s)),
+		%[3]s
+	)`, prefix, normalizedService(prefix), portProtoServiceKeyNoICMP(prefix))
+}
+
+// portProtoServiceKeyNoICMP returns the SQL expression that formats a connection as port:proto:service for tables
+// that don't record ICMP type and code, such as http and ssl
+func portProtoServiceKeyNoICMP(prefix string) string {
+	return fmt.Sprintf(`concat(%[2]s, ':', %[1]sproto, ':', %[3]s)`, prefix, normalizedPort(prefix), normalizedService(prefix))
+}
+
+// normalizedPort returns the SQL expression for the destination port of a connection, where ports at or above the
+// ephemeral port start are collapsed into a single port range, ex: 49152+
+func normalizedPort(prefix string) string {
+	return fmt.Sprintf(`if({ephemeral_port_start:UInt16} > 0 AND %[1]sdst_port >= {ephemeral_port_start:UInt16},
+		concat(toString({ephemeral_port_start:UInt16}), '+'),
+		toString(%[1]sdst_port)
+	)`, prefix)
+}
+
+// normalizedService returns the SQL expression for the service of a connection, where each service in zeek's comma
+// separated list is replaced by its alias, and services that end up listed twice are only listed once
+func normalizedService(prefix string) string {
+	return fmt.Sprintf(`arrayStringConcat(arrayDistinct(arrayMap(
+		svc -> if(indexOf({service_alias_names:Array(String)}, svc) > 0, {service_alias_values:Array(String)}[indexOf({service_alias_names:Array(String)}, svc)], svc),
+		splitByChar(',', toString(%[1]sservice))
+	)), ',')`, prefix)
+}
+
+// addPortProtoServiceParameters adds the normalization rules to the parameters of a query that builds
+// port:proto:service keys
+func addPortProtoServiceParameters(rules config.PortProtoService, params clickhouse.Parameters) clickhouse.Parameters {
+	// sort the aliases so that the parameters are the same for every query
+	services := make([]string, 0, len(rules.ServiceAliases))
+	for service := range rules.ServiceAliases {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	names := make([]string, 0, len(services))
+	values := make([]string, 0, len(services))
+	for _, service := range services {
+		names = append(names, quoteString(service))
+		values = append(values, quoteString(rules.ServiceAliases[service]))
+	}
+
+	params["service_alias_names"] = "[" + strings.Join(names, ",") + "]"
+	params["service_alias_values"] = "[" + strings.Join(values, ",") + "]"
+	params["ephemeral_port_start"] = fmt.Sprint(rules.EphemeralPortStart)
+	return params
+}
+
+// quoteString formats a string as a quoted clickhouse string literal
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddPortProtoServiceParameters(t *testing.T) {
+	tests := []struct {
+		name                 string
+		rules                config.PortProtoService
+		expectedNames        string
+		expectedValues       string
+		expectedEphemeralStr string
+	}{
+		{
+			name:                 "No Rules",
+			expectedNames:        "[]",
+			expectedValues:       "[]",
+			expectedEphemeralStr: "0",
+		},
+		{
+			name:                 "Service Aliases",
+			rules:                config.PortProtoService{ServiceAliases: map[string]string{"tls": "ssl", "dce_rpc": "dcerpc"}},
+			expectedNames:        "['dce_rpc','tls']",
+			expectedValues:       "['dcerpc','ssl']",
+			expectedEphemeralStr: "0",
+		},
+		{
+			name:                 "Quoted Service Names",
+			rules:                config.PortProtoService{ServiceAliases: map[string]string{`it's`: `back\slash`}},
+			expectedNames:        `['it\'s']`,
+			expectedValues:       `['back\\slash']`,
+			expectedEphemeralStr: "0",
+		},
+		{
+			name:                 "Ephemeral Ports",
+			rules:                config.PortProtoService{EphemeralPortStart: 49152},
+			expectedNames:        "[]",
+			expectedValues:       "[]",
+			expectedEphemeralStr: "49152",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := addPortProtoServiceParameters(test.rules, clickhouse.Parameters{"min_ts": "0"})
+			require.Equal(t, "0", params["min_ts"], "existing parameters should be kept")
+			require.Equal(t, test.expectedNames, params["service_alias_names"])
+			require.Equal(t, test.expectedValues, params["service_alias_values"])
+			require.Equal(t, test.expectedEphemeralStr, params["ephemeral_port_start"])
+		})
+	}
+}
+
+func TestPortProtoServiceKey(t *testing.T) {
+	// every column of the key should be selected from the table alias, and every key should be normalized
+	for _, key := range []string{portProtoServiceKey("po."), portProtoServiceKeyNoICMP("po.")} {
+		for _, column := range []string{"dst_port", "proto", "service"} {
+			require.Contains(t, key, "po."+column)
+			require.NotContains(t, strings.ReplaceAll(key, "po."+column, ""), " "+column, "the %s column should always be prefixed", column)
+		}
+		require.Contains(t, key, "{ephemeral_port_start:UInt16}")
+		require.Contains(t, key, "{service_alias_names:Array(String)}")
+		require.Contains(t, key, "{service_alias_values:Array(String)}")
+	}
+
+	require.Contains(t, portProtoServiceKey("po."), "po.icmp_type")
+	require.NotContains(t, portProtoServiceKeyNoICMP("po."), "icmp_type", "tables without ICMP columns should not use them")
+}
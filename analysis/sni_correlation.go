@@ -0,0 +1,145 @@
+package analysis
+
+// SNI connections are grouped by the domain that they connect to, but connections to the same servers without an SNI
+// or host header are grouped by their destination IP. When a domain resolves to rotating IPs, like a CDN-fronted C2
+// domain, this splits one beacon into an SNI connection and an IP connection for each server. When DNS correlation is
+// enabled, the DNS answers in the pdns table are used to add the connections to the IPs that a host resolved for the
+// domain of one of its SNI connections to that SNI connection, and to leave them out of the IP connections, so that
+// every connection is still analyzed under a single hash.
+
+// dnsCorrelatedPairsCTEs finds the IPs that each host resolved for the domain of one of its SNI connections in this import.
+// IPs that a host resolved for more than one domain, like shared hosting and CDN edge servers, can't be tied to a
+// single domain, so they are left alone.
+const dnsCorrelatedPairsCTEs = `
+	dns_resolved_ips AS (
+		SELECT src, src_nuid, resolved_ip AS dst, any(fqdn) AS fqdn FROM pdns
+		WHERE day >= toStartOfDay(fromUnixTimestamp({min_ts:Int64})) AND resolved_ip != '::'
+		GROUP BY src, src_nuid, resolved_ip
+		HAVING uniqExact(fqdn) = 1
+	),
+	dns_correlated_pairs AS ( -- source and IP pairs whose connections belong to the SNI connection with the given hash
+		SELECT DISTINCT u.hash AS hash, d.src AS src, d.src_nuid AS src_nuid, d.dst AS dst, d.fqdn AS fqdn
+		FROM usni u
+		INNER JOIN dns_resolved_ips d ON u.src = d.src AND u.src_nuid = d.src_nuid AND u.fqdn = d.fqdn
+		WHERE u.hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND u.hash IN (SELECT hash FROM sniconn_tmp)
+	),
+`
+
+// dnsCorrelatedSNIConns returns the branches of the SNI connection query that add the connections and open connections
+// without an SNI or host header to the SNI connection of the domain that their destination was resolved for
+func dnsCorrelatedSNIConns(hostFilter string) string {
+	return `
+		UNION ALL
+
+		-- Get the connections to the IPs that were resolved for the domain of an SNI connection
+		SELECT p.hash AS hash, c.src AS src, c.src_nuid AS src_nuid, p.fqdn AS fqdn,
+				count() AS conn_count,
+				0 AS proxy_count,
+				0 AS open_count,
+				sum(` + boundedDuration("c.duration") + `) AS total_duration,
+				0 AS open_duration,
+				countIf(` + clampedDuration("c.duration") + `) AS clamped_duration_count,
+				uniqExactIf(c.ts, c.beacon_excluded = false) AS ts_unique,
+				arraySort(groupArrayIf(86400)(toUnixTimestamp(c.ts), c.beacon_excluded = false)) AS ts_list,
+				arraySort(groupArrayIf(86400)(c.src_ip_bytes, c.beacon_excluded = false AND c.datasize_excluded = false)) AS bytes,
+				arraySort(groupArrayIf(86400)(c.dst_ip_bytes, c.beacon_excluded = false AND c.datasize_excluded = false)) AS dst_bytes,
+				sum(c.src_ip_bytes + c.dst_ip_bytes) AS total_bytes,
+				groupUniqArray(10)(c.dst) AS server_ips,
+				[] AS proxy_ips,
+				max(c.ts) AS last_seen,
+				min(c.ts) AS first_seen,
+				groupUniqArray(c.sensor) AS sensors
+		FROM (
+			SELECT zeek_uid, ts, src, src_nuid, dst, duration, src_ip_bytes, dst_ip_bytes, beacon_excluded, datasize_excluded, sensor
+			FROM conn
+			WHERE ts >= fromUnixTimestamp({min_ts:Int64}) AND missing_host_header = false AND ` + hostFilter + `
+		) c
+		INNER JOIN dns_correlated_pairs p ON c.src = p.src AND c.src_nuid = p.src_nuid AND c.dst = p.dst
+		-- leave out the connections that are already part of an SNI connection
+		WHERE c.zeek_uid NOT IN (
+			SELECT zeek_uid FROM ssl WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+			UNION ALL
+			SELECT zeek_uid FROM http WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+		)
+		GROUP BY p.hash, c.src, c.src_nuid, p.fqdn
+
+		UNION ALL
+
+		-- Get the open connections to the IPs that were resolved for the domain of an SNI connection
+		SELECT p.hash AS hash, c.src AS src, c.src_nuid AS src_nuid, p.fqdn AS fqdn,
+				0 AS conn_count,
+				0 AS proxy_count,
+				count() AS open_count,
+				0 AS total_duration,
+				sum(` + boundedDuration("c.duration") + `) AS open_duration,
+				countIf(` + clampedDuration("c.duration") + `) AS clamped_duration_count,
+				0 AS ts_unique, -- set following to zero/empty since open connections are not included in beaconing
+				[] AS ts_list,
+				[] AS bytes,
+				[] AS dst_bytes,
+				sum(c.src_ip_bytes + c.dst_ip_bytes) AS total_bytes,
+				groupUniqArray(10)(c.dst) AS server_ips,
+				[] AS proxy_ips,
+				max(c.ts) AS last_seen,
+				min(c.ts) AS first_seen,
+				groupUniqArray(c.sensor) AS sensors
+		FROM (
+			SELECT zeek_uid, ts, src, src_nuid, dst, duration, src_ip_bytes, dst_ip_bytes, sensor
+			FROM openconn
+			WHERE missing_host_header = false AND ` + hostFilter + `
+		) c
+		INNER JOIN dns_correlated_pairs p ON c.src = p.src AND c.src_nuid = p.src_nuid AND c.dst = p.dst
+		WHERE c.zeek_uid NOT IN (SELECT zeek_uid FROM opensniconn_tmp)
+		GROUP BY p.hash, c.src, c.src_nuid, p.fqdn
+`
+}
+
+// dnsCorrelatedIPConnsFilter is a condition on the unique IP connections that leaves out the source and IP pairs whose
+// connections were added to an SNI connection, dnsCorrelatedIPConns adds back the connections of those pairs that weren't
+const dnsCorrelatedIPConnsFilter = `(src, src_nuid, dst) NOT IN (SELECT src, src_nuid, dst FROM dns_correlated_pairs)`
+
+// dnsCorrelatedOpenIPConnsFilter is a condition on the open connections that leaves out only the open connections that
+// were added to an SNI connection, so that the HTTP connections without a host header are still scored by their IP
+const dnsCorrelatedOpenIPConnsFilter = `NOT (missing_host_header = false
+	AND (src, src_nuid, dst) IN (SELECT src, src_nuid, dst FROM dns_correlated_pairs)
+	AND zeek_uid NOT IN (SELECT zeek_uid FROM opensniconn_tmp))`
+
+// dnsCorrelatedIPConns returns the branch of the IP connection query that adds the connections of the source and IP
+// pairs that dnsCorrelatedIPConnsFilter leaves out, but that weren't added to an SNI connection by dnsCorrelatedSNIConns.
+// These are the HTTP connections without a host header, which keep their missing host header modifier, and the
+// connections that are already part of an SNI connection, which are also scored by their IP like any other connection.
+func dnsCorrelatedIPConns(hostFilter string) string {
+	return `
+		UNION ALL
+
+		-- Get the connections of the source and IP pairs whose other connections were added to an SNI connection
+		SELECT  hash, src, src_nuid, dst, dst_nuid, src_local, dst_local,
+				countIf(missing_host_header = true) AS missing_host_count,
+				countIf(missing_host_header = false AND missing_dst_bytes = true) AS missing_bytes_count,
+				countIf(missing_host_header = false) AS conn_count,
+				0 AS open_count,
+				0 AS proxy_count,
+				sumIf(` + boundedDuration("duration") + `, missing_host_header = false) AS total_duration,
+				toFloat64(0) AS open_duration,
+				countIf(missing_host_header = false AND ` + clampedDuration("duration") + `) AS clamped_duration_count,
+				arraySort(groupArrayIf(86400)(toUnixTimestamp(ts), missing_host_header = false AND beacon_excluded = false)) AS ts_list,
+				uniqExactIf(ts, beacon_excluded = false) AS ts_unique,
+				arraySort(groupArrayIf(86400)(src_ip_bytes, missing_host_header = false AND beacon_excluded = false AND datasize_excluded = false)) AS bytes,
+				arraySort(groupArrayIf(86400)(dst_ip_bytes, missing_host_header = false AND beacon_excluded = false AND datasize_excluded = false)) AS dst_bytes,
+				sumIf(src_ip_bytes + dst_ip_bytes, missing_host_header = false) AS total_bytes,
+				max(ts) AS last_seen,
+				min(ts) AS first_seen,
+				groupUniqArray(sensor) AS sensors
+		FROM conn
+		RIGHT JOIN filtered_hashes USING hash
+		WHERE ts >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND ` + hostFilter + `
+			AND (src, src_nuid, dst) IN (SELECT src, src_nuid, dst FROM dns_correlated_pairs)
+			-- leave out only the connections that dnsCorrelatedSNIConns added to an SNI connection
+			AND NOT (missing_host_header = false AND ts >= fromUnixTimestamp({min_ts:Int64}) AND zeek_uid NOT IN (
+				SELECT zeek_uid FROM ssl WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+				UNION ALL
+				SELECT zeek_uid FROM http WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+			))
+		GROUP BY hash, src, src_nuid, dst, dst_nuid, src_local, dst_local
+`
+}
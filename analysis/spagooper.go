@@ -2,11 +2,14 @@ package analysis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/activecm/rita/v5/config"
 	zlog "github.com/activecm/rita/v5/logger"
 	"github.com/activecm/rita/v5/progressbar"
 	"github.com/activecm/rita/v5/util"
@@ -20,28 +23,33 @@ import (
 
 type AnalysisResult struct {
 	// Unique connections
-	Hash                util.FixedString `ch:"hash"`
-	Src                 net.IP           `ch:"src"`
-	SrcNUID             uuid.UUID        `ch:"src_nuid"`
-	Dst                 net.IP           `ch:"dst"`
-	DstNUID             uuid.UUID        `ch:"dst_nuid"`
-	FQDN                string           `ch:"fqdn"`
-	BeaconType          string           `ch:"beacon_type"` // (sni, ip, dns)
-	Count               uint64           `ch:"count"`
-	ProxyCount          uint64           `ch:"proxy_count"`
-	OpenCount           uint64           `ch:"open_count"`
-	TSUnique            uint64           `ch:"ts_unique"` // number of unique timestamps
-	TSList              []uint32         `ch:"ts_list"`
-	TotalDuration       float64          `ch:"total_duration"`
-	OpenTotalDuration   float64          `ch:"open_total_duration"`
-	BytesList           []float64        `ch:"bytes"`
-	TotalBytes          int64            `ch:"total_bytes"`
-	PortProtoService    []string         `ch:"port_proto_service"`
-	FirstSeenHistorical time.Time        `ch:"first_seen_historical"`
-	LastSeen            time.Time        `ch:"last_seen"`
-	ServerIPs           []net.IP         `ch:"server_ips"` // array of unique destination IPs for SNI conns
-	ProxyIPs            []net.IP         `ch:"proxy_ips"`  // array of unique proxy (destination IPs) for SNI conns
-	MissingHostCount    uint64           `ch:"missing_host_count"`
+	Hash              util.FixedString `ch:"hash"`
+	Src               net.IP           `ch:"src"`
+	SrcNUID           uuid.UUID        `ch:"src_nuid"`
+	Dst               net.IP           `ch:"dst"`
+	DstNUID           uuid.UUID        `ch:"dst_nuid"`
+	FQDN              string           `ch:"fqdn"`
+	BeaconType        string           `ch:"beacon_type"` // (sni, ip, dns, rdp, distributed, ip_port, sni_parent)
+	Count             uint64           `ch:"count"`
+	ProxyCount        uint64           `ch:"proxy_count"`
+	OpenCount         uint64           `ch:"open_count"`
+	TSUnique          uint64           `ch:"ts_unique"` // number of unique timestamps
+	TSList            []uint32         `ch:"ts_list"`
+	TotalDuration     float64          `ch:"total_duration"`
+	OpenTotalDuration float64          `ch:"open_total_duration"`
+	// number of connections whose duration was clamped to the longest plausible duration before it was totaled
+	ClampedDurationCount uint64    `ch:"clamped_duration_count"`
+	BytesList            []float64 `ch:"bytes"`     // data sizes sent by the source
+	DstBytesList         []float64 `ch:"dst_bytes"` // data sizes received by the source
+	TotalBytes           int64     `ch:"total_bytes"`
+	PortProtoService     []string  `ch:"port_proto_service"`
+	FirstSeenHistorical  time.Time `ch:"first_seen_historical"`
+	LastSeen             time.Time `ch:"last_seen"`
+	ServerIPs            []net.IP  `ch:"server_ips"` // array of unique destination IPs for SNI conns
+	ProxyIPs             []net.IP  `ch:"proxy_ips"`  // array of unique proxy (destination IPs) for SNI conns
+	MissingHostCount     uint64    `ch:"missing_host_count"`
+	MissingBytesCount    uint64    `ch:"missing_bytes_count"` // number of connections whose resp_ip_bytes were unset
+	Sensor               string    `ch:"sensor"`              // comma-separated list of the sensors that observed this connection
 
 	// C2 OVER DNS Connection Info
 	DirectConns []net.IP `ch:"direct_conns"`
@@ -72,8 +80,27 @@ func (analyzer *Analyzer) Spagoop(ctx context.Context) error {
 		progressbar.NewBar("SNI Connection Analysis", 1, progress.New(progress.WithDefaultGradient())),
 		progressbar.NewBar("IP Connection Analysis ", 2, progress.New(progress.WithDefaultGradient())),
 		progressbar.NewBar("DNS Analysis           ", 3, progress.New(progress.WithDefaultGradient())),
+		progressbar.NewBar("RDP Connection Analysis", 4, progress.New(progress.WithDefaultGradient())),
+		progressbar.NewBar("Distributed Analysis   ", 5, progress.New(progress.WithDefaultGradient())),
+		progressbar.NewBar("Per-Port Analysis      ", 6, progress.New(progress.WithDefaultGradient())),
+		progressbar.NewBar("Parent Domain Analysis ", 7, progress.New(progress.WithDefaultGradient())),
 	}, []progressbar.Spinner{})
 
+	// a deterministic analysis runs one query at a time, in the order below, so that the results are sent to the
+	// analysis workers in the same order every time. The other slot is taken by the progress bars.
+	if analyzer.deterministic {
+		queryGroup.SetLimit(2)
+	}
+
+	// the progress bars are started before the queries since the queries block until their progress is received
+	queryGroup.Go(func() error {
+		_, err := bars.Run()
+		if err != nil {
+			logger.Error().Err(err).Msg("error running program")
+		}
+		return err
+	})
+
 	// if !analyzer.minTS.IsZero() && !analyzer.maxTS.IsZero() {
 	logger.Debug().Msg("Starting to get unique SNI connections")
 
@@ -113,11 +140,51 @@ func (analyzer *Analyzer) Spagoop(ctx context.Context) error {
 		return err
 	})
 
+	logger.Debug().Msg("Starting to get unique RDP connections")
+
 	queryGroup.Go(func() error {
-		_, err := bars.Run()
-		if err != nil {
-			logger.Error().Err(err).Msg("error running program")
-		}
+		// get the unique rdp connections from the database
+		err := analyzer.ScoopRDPConns(ctx, bars)
+		// record end time
+		end := time.Since(start)
+		// log the time it took to finish
+		logger.Debug().Str("elapsed", fmt.Sprintf("%1.2fs", end.Seconds())).Msg("FINISHED RDP BEACON QUERY")
+		return err
+	})
+
+	logger.Debug().Msg("Starting to get distributed connections")
+
+	queryGroup.Go(func() error {
+		// get the connections that sources made to pools of destinations from the database
+		err := analyzer.ScoopDistributedConns(ctx, bars)
+		// record end time
+		end := time.Since(start)
+		// log the time it took to finish
+		logger.Debug().Str("elapsed", fmt.Sprintf("%1.2fs", end.Seconds())).Msg("FINISHED DISTRIBUTED BEACON QUERY")
+		return err
+	})
+
+	logger.Debug().Msg("Starting to get per-port connections")
+
+	queryGroup.Go(func() error {
+		// get the connections that were made on more than one port, split by port, from the database
+		err := analyzer.ScoopPortConns(ctx, bars)
+		// record end time
+		end := time.Since(start)
+		// log the time it took to finish
+		logger.Debug().Str("elapsed", fmt.Sprintf("%1.2fs", end.Seconds())).Msg("FINISHED PER-PORT BEACON QUERY")
+		return err
+	})
+
+	logger.Debug().Msg("Starting to get parent domain connections")
+
+	queryGroup.Go(func() error {
+		// get the SNI connections to more than one subdomain of a domain, grouped by domain, from the database
+		err := analyzer.ScoopParentDomainConns(ctx, bars)
+		// record end time
+		end := time.Since(start)
+		// log the time it took to finish
+		logger.Debug().Str("elapsed", fmt.Sprintf("%1.2fs", end.Seconds())).Msg("FINISHED PARENT DOMAIN BEACON QUERY")
 		return err
 	})
 
@@ -137,9 +204,22 @@ func (analyzer *Analyzer) Spagoop(ctx context.Context) error {
 	return nil
 }
 
+// connModulesEnabled returns whether any of the modules that score the SNI, IP, and RDP connections are enabled
+func (analyzer *Analyzer) connModulesEnabled() bool {
+	return analyzer.Config.ModuleEnabled(config.ModuleBeacons) ||
+		analyzer.Config.ModuleEnabled(config.ModuleLongConnections) ||
+		analyzer.Config.ModuleEnabled(config.ModuleStrobes)
+}
+
 func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program) error {
 	logger := zlog.GetLogger()
 
+	// skip the query if the beacon, long connection, and strobe modules are disabled
+	if !analyzer.connModulesEnabled() {
+		bars.Send(progressbar.ProgressMsg{ID: 1, Percent: 1})
+		return nil
+	}
+
 	// initialize progress bar variables
 	var totalSNI uint64
 	// get total number of unique hashes between sni and opensni
@@ -155,18 +235,30 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 	}
 
 	// use context to pass a call back for progress and profile info
-	chCtx := clickhouse.Context(analyzer.Database.GetContext(), clickhouse.WithParameters(clickhouse.Parameters{
+	chCtx := clickhouse.Context(analyzer.Database.GetContext(), clickhouse.WithParameters(analyzer.HostFilter.addParameters(addPortProtoServiceParameters(analyzer.Config.PortProtoService, analyzer.addLongConnDurationParameters(clickhouse.Parameters{
 		// use minTSBeacon because all SNI conns have a matching conn entry and openconn data is not limited by the hour since the tables are truncated before each import
 		"min_ts":                      fmt.Sprintf("%d", analyzer.minTSBeacon.UTC().Unix()),
-		"unique_connection_threshold": fmt.Sprint(analyzer.Config.Scoring.Beacon.UniqueConnectionThreshold),
+		"metadatabase":                analyzer.Database.GetMetaDatabase(),
+		"unique_connection_threshold": fmt.Sprint(analyzer.Config.Scoring.Beacon.GetUniqueConnectionThreshold("sni")),
 		"network_size":                fmt.Sprint(analyzer.networkSize),
 		"rolling":                     strconv.FormatBool(analyzer.Database.Rolling),
-	}))
+	})))))
+
+	// limit the analysis to the connections of the filtered hosts
+	hostFilter := analyzer.HostFilter.condition()
+
+	// add the connections to the IPs that were resolved for the domain of an SNI connection to that SNI connection
+	correlatedPairs, correlatedConns := "", ""
+	if analyzer.Config.Scoring.Beacon.CorrelateDNSResolvedIPs {
+		correlatedPairs = dnsCorrelatedPairsCTEs
+		correlatedConns = dnsCorrelatedSNIConns(hostFilter)
+	}
+
 	// panic(strconv.FormatBool(analyzer.Database.Rolling))
 	rows, err := analyzer.Database.Conn.Query(chCtx, `--sql
 	WITH unique_sni AS (
 		SELECT DISTINCT hash FROM sniconn_tmp
-	),
+	),`+correlatedPairs+`
 	prevalence_counts AS (
 	    SELECT fqdn, count() as prevalence_total FROM (
 			SELECT DISTINCT fqdn, src FROM usni
@@ -207,20 +299,24 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 			countMerge(count) AS conn_count, 
 			countMerge(proxy_count) AS proxy_count,
 			0 as open_count,
-			sumMerge(total_duration) AS total_duration,
+			-- each connection's duration is bounded before it's added to the total duration
+			arraySum(d -> `+boundedDuration("d")+`, groupArrayMerge(86400)(duration_list)) AS total_duration,
 			0 AS open_duration,
+			arrayCount(d -> `+clampedDuration("d")+`, groupArrayMerge(86400)(duration_list)) AS clamped_duration_count,
 			uniqExactMerge(unique_ts_count) AS ts_unique,
 			arraySort(groupArrayMerge(86400)(ts_list)) AS ts_list, 
 			arraySort(groupArrayMerge(86400)(src_ip_bytes_list)) AS bytes,
+			arraySort(groupArrayMerge(86400)(dst_ip_bytes_list)) AS dst_bytes,
 			sumMerge(total_ip_bytes) as total_bytes,
 			groupUniqArrayMerge(10)(server_ips) AS server_ips, 
 			groupUniqArrayMerge(10)(proxy_ips) AS proxy_ips, 
 			maxMerge(last_seen) AS last_seen,
-			minMerge(first_seen) as first_seen
+			minMerge(first_seen) as first_seen,
+			groupUniqArrayMerge(sensors) AS sensors
 		FROM usni
 		RIGHT JOIN unique_sni USING hash
 		-- Limit query to the last 24 hours of data
-		WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+		WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND `+hostFilter+`
 		GROUP BY hash, src, src_nuid, fqdn, proxy
 
 		UNION ALL 
@@ -231,17 +327,25 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 				countIf(method = 'CONNECT') as proxy_count,
 				countIf(multi_request = false) as open_count,
 				0 as total_duration,
-				sum(duration) as open_duration,
-				0 as ts_unique, -- set following to zero/empty since openhttp is not included in beaconing
-				[] as ts_list, 
+				sum(`+boundedDuration("duration")+`) as open_duration,
+				countIf(`+clampedDuration("duration")+`) as clamped_duration_count,
+				-- open connections are placed on the beacon timeline at the time they started, like the open IP
+				-- connections. Connections that started before the beacon window, or that were also logged as closed in
+				-- this import, are left out so that they aren't counted twice. Data sizes aren't known until they close.
+				uniqExactIf(ts, (multi_request = false AND ts >= fromUnixTimestamp({min_ts:Int64})
+					AND zeek_uid NOT IN (SELECT zeek_uid FROM sniconn_tmp)) AS open_beacon_ts) as ts_unique,
+				groupArrayIf(86400)(toUnixTimestamp(ts), open_beacon_ts) as ts_list,
 				[] as bytes,
+				[] as dst_bytes,
 				sum(src_ip_bytes + dst_ip_bytes) as total_bytes,
 				groupUniqArrayIf(10)(dst, method != 'CONNECT') as server_ips, 
 				groupUniqArrayIf(10)(dst, method = 'CONNECT') as proxy_ips,
 				max(ts) AS last_seen,
-				min(ts) AS first_seen
+				min(ts) AS first_seen,
+				groupUniqArray(sensor) AS sensors
 		FROM openhttp
 		-- Right join unique HTTP hashes to limit analysis to just the connections that updated in this import
+		WHERE `+hostFilter+`
 		GROUP BY hash, src, src_nuid, fqdn
 
 		UNION ALL
@@ -252,34 +356,41 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 				0 as proxy_count, 
 				count() as open_count,
 				0 as total_duration, -- openssl uses open_duration
-				sum(duration) as open_duration,
-				0 as ts_unique, -- set following to zero/empty since openssl is not included in beaconing
-				[] as ts_list,
+				sum(`+boundedDuration("duration")+`) as open_duration,
+				countIf(`+clampedDuration("duration")+`) as clamped_duration_count,
+				uniqExactIf(ts, (ts >= fromUnixTimestamp({min_ts:Int64})
+					AND zeek_uid NOT IN (SELECT zeek_uid FROM sniconn_tmp)) AS open_beacon_ts) as ts_unique,
+				groupArrayIf(86400)(toUnixTimestamp(ts), open_beacon_ts) as ts_list,
 				[] as bytes,
+				[] as dst_bytes,
 				sum(src_ip_bytes + dst_ip_bytes) as total_bytes,
 				groupUniqArray(10)(dst) as server_ips,
 				[] as proxy_ips,
 				max(ts) AS last_seen,
-				min(ts) AS first_seen
+				min(ts) AS first_seen,
+				groupUniqArray(sensor) AS sensors
 		FROM openssl
+		WHERE `+hostFilter+`
 		GROUP BY hash, src, src_nuid, fqdn
+		`+correlatedConns+`
 	),
+	-- first seen is tracked per internal network so that each network's novelty is independent
 	historical AS (
-		SELECT min(first_seen) AS first_seen, fqdn 
-		FROM metadatabase.historical_first_seen
+		SELECT min(first_seen) AS first_seen, fqdn, nuid
+		FROM {metadatabase:Identifier}.historical_first_seen
 		LEFT JOIN sniconns USING fqdn
-		GROUP BY fqdn
+		GROUP BY fqdn, nuid
 	),
 	port_proto AS (
 		SELECT hash, groupUniqArray(20)(port_proto_service) AS port_proto_service FROM (
-			SELECT DISTINCT hash, concat(po.dst_port, ':', po.proto, ':', po.service) as port_proto_service
+			SELECT DISTINCT hash, `+portProtoServiceKeyNoICMP("po.")+` as port_proto_service
 			FROM port_info po
 			LEFT JOIN sniconns s ON s.hash = po.hash
 			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
 			UNION DISTINCT
-			SELECT DISTINCT hash, concat(dst_port, ':', proto, ':', service) FROM openhttp
+			SELECT DISTINCT hash, `+portProtoServiceKeyNoICMP("")+` FROM openhttp
 			UNION DISTINCT
-			SELECT DISTINCT hash, concat(dst_port, ':', proto, ':', service) FROM openssl
+			SELECT DISTINCT hash, `+portProtoServiceKeyNoICMP("")+` FROM openssl
 		)
 		GROUP BY hash
 	),
@@ -291,14 +402,19 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 			sum(proxy_count) AS proxy_count,
 			sum(total_duration + open_duration) AS total_duration,
 			sum(open_duration) AS open_total_duration,
+			sum(clamped_duration_count) AS clamped_duration_count,
+			-- the unique timestamps of the closed and open connections are counted separately, so the max is used like
+			-- the IP connections
 			max(ts_unique) AS ts_unique,
-			groupArrayArray(86400)(ts_list) AS ts_list,
+			arraySort(groupArrayArray(86400)(ts_list)) AS ts_list, -- sorted again since open conns are appended
 			groupArrayArray(86400)(bytes) AS bytes,
+			groupArrayArray(86400)(dst_bytes) AS dst_bytes,
 			sum(total_bytes) AS total_bytes,
 			groupUniqArrayArray(10)(server_ips) AS server_ips,
 			groupUniqArrayArray(10)(proxy_ips) AS proxy_ips,
 			max(s.last_seen) AS last_seen,
-			min(s.first_seen) AS first_seen
+			min(s.first_seen) AS first_seen,
+			arrayStringConcat(arraySort(arrayFilter(x -> x != '', groupUniqArrayArray(sensors))), ',') AS sensor
 		FROM sniconns s
 		GROUP BY s.hash, s.src, s.src_nuid, s.fqdn
 	)
@@ -313,18 +429,21 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 			proxy_count,
 			total_duration,
 			open_total_duration,
+			clamped_duration_count,
 			ts_unique,
 			ts_list,
 			bytes,
+			dst_bytes,
 			total_bytes,
 			server_ips,
 			proxy_ips,
 			last_seen,
+			sensor,
 			po.port_proto_service as port_proto_service
 	FROM totaled_sniconns s
 	LEFT JOIN prevalence_counts USING fqdn
-	LEFT JOIN metadatabase.threat_intel t ON s.fqdn = t.fqdn 
-	LEFT JOIN historical h ON h.fqdn = s.fqdn
+	LEFT JOIN {metadatabase:Identifier}.threat_intel t ON s.fqdn = t.fqdn 
+	LEFT JOIN historical h ON h.fqdn = s.fqdn AND h.nuid = s.src_nuid
 	LEFT JOIN port_proto po ON s.hash = po.hash
 `)
 	if err != nil {
@@ -364,6 +483,12 @@ func (analyzer *Analyzer) ScoopSNIConns(ctx context.Context, bars *tea.Program)
 func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) error {
 	logger := zlog.GetLogger()
 
+	// skip the query if the beacon, long connection, and strobe modules are disabled
+	if !analyzer.connModulesEnabled() {
+		bars.Send(progressbar.ProgressMsg{ID: 2, Percent: 1})
+		return nil
+	}
+
 	totalRows := uint64(0)
 	hasSetTotal := false
 	chCtx := clickhouse.Context(analyzer.Database.GetContext(), clickhouse.WithProgress(func(p *clickhouse.Progress) {
@@ -381,19 +506,33 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 			}
 			bars.Send(progressbar.ProgressMsg{ID: 2, Percent: 1})
 		}
-	}), clickhouse.WithParameters(clickhouse.Parameters{
+	}), clickhouse.WithParameters(analyzer.HostFilter.addParameters(addPortProtoServiceParameters(analyzer.Config.PortProtoService, analyzer.addLongConnDurationParameters(clickhouse.Parameters{
 		// use minTSBeacon because all entries in conn are used in beaconing and openconn data is not limited by the hour since the tables are truncated before each import
 		"min_ts":                      fmt.Sprintf("%d", analyzer.minTSBeacon.UTC().Unix()),
-		"unique_connection_threshold": fmt.Sprint(analyzer.Config.Scoring.Beacon.UniqueConnectionThreshold),
+		"metadatabase":                analyzer.Database.GetMetaDatabase(),
+		"unique_connection_threshold": fmt.Sprint(analyzer.Config.Scoring.Beacon.GetUniqueConnectionThreshold("sni")), // finds the SNI beacons to exclude from IP beacons
 		"network_size":                fmt.Sprint(analyzer.networkSize),
 		"rolling":                     strconv.FormatBool(analyzer.Database.Rolling),
-	}))
+		"estimate_open_conn_bytes":    strconv.FormatBool(analyzer.Config.Scoring.Beacon.EstimateOpenConnBytes),
+	})))))
+
+	// limit the analysis to the connections of the filtered hosts
+	hostFilter := analyzer.HostFilter.condition()
+
+	// leave out the connections that were added to an SNI connection by their DNS answers
+	correlatedPairs, correlatedFilter, correlatedConns, correlatedOpenFilter := "", "true", "", "true"
+	if analyzer.Config.Scoring.Beacon.CorrelateDNSResolvedIPs {
+		correlatedPairs = dnsCorrelatedPairsCTEs
+		correlatedFilter = dnsCorrelatedIPConnsFilter
+		correlatedConns = dnsCorrelatedIPConns(hostFilter)
+		correlatedOpenFilter = dnsCorrelatedOpenIPConnsFilter
+	}
 
 	query := `--sql
 		WITH unique_http AS (
 			SELECT DISTINCT hash FROM sniconn_tmp
 			WHERE conn_type = 'http'
-		),
+		),` + correlatedPairs + `
 		prevalence_counts AS (
 			SELECT ip, count() as prevalence_total FROM (
 				SELECT DISTINCT if(src_local, dst, src) as ip, if(src_local, src, dst) as internal FROM uconn
@@ -417,7 +556,7 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 			SELECT DISTINCT zeek_uid FROM sniconn_tmp
 			INNER JOIN sniconns USING hash
 			UNION DISTINCT
-			-- open conns don't need to be joined on the potential beacons list bc open conns aren't used in beaconing
+			-- open conns don't need to be joined on the potential beacons list bc open SNI conns are never used in IP beacons
 			SELECT DISTINCT zeek_uid from opensniconn_tmp
 		), filtered_hashes AS ( -- list of unique hashes for uconns that were not used by SNI beacons in this import
 			SELECT DISTINCT hash FROM uconn_tmp u
@@ -435,63 +574,88 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 		-- Get IP connections
 		SELECT  hash, src, src_nuid,  dst, dst_nuid, src_local, dst_local,
 				countMerge(missing_host_header_count) AS missing_host_count, 
+				countMerge(missing_bytes_count) AS missing_bytes_count,
 				countMerge(count) as conn_count,
 				0 as open_count,     -- only used in openconn/openhttp
 				0 as proxy_count,    -- only used in sni/openhttp
-				sumMerge(total_duration) as total_duration,
+				-- each connection's duration is bounded before it's added to the total duration
+				arraySum(d -> ` + boundedDuration("d") + `, groupArrayMerge(86400)(duration_list)) as total_duration,
 				toFloat64(0) as open_duration,  -- only used for openconn/openhttp
+				arrayCount(d -> ` + clampedDuration("d") + `, groupArrayMerge(86400)(duration_list)) as clamped_duration_count,
 				arraySort(groupArrayMerge(86400)(ts_list)) as ts_list,
 				uniqExactMerge(unique_ts_count) as ts_unique, -- gets unique timestamp count for uconns
 				arraySort(groupArrayMerge(86400)(src_ip_bytes_list)) as bytes,
+				arraySort(groupArrayMerge(86400)(dst_ip_bytes_list)) as dst_bytes,
 				sumMerge(total_ip_bytes) as total_bytes,
 				maxMerge(last_seen) as last_seen,
-				minMerge(first_seen) as first_seen
+				minMerge(first_seen) as first_seen,
+				groupUniqArrayMerge(sensors) as sensors
 		FROM uconn
 		-- Limit IP connections to just connections not used by a SNI beacon
 		RIGHT JOIN filtered_hashes USING hash
 		-- Limit query to the last 24 hours of data
-		WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+		WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND ` + hostFilter + ` AND ` + correlatedFilter + `
 		GROUP BY hash, src, src_nuid, dst, dst_nuid, src_local, dst_local
-
+		` + correlatedConns + `
 		UNION ALL
 
 		-- Get open connections
 		SELECT  hash, src, src_nuid, dst, dst_nuid, src_local, dst_local,
 				countIf(missing_host_header = true) AS missing_host_count, 
+				countIf(missing_host_header = false AND missing_dst_bytes = true) AS missing_bytes_count,
 				0 as conn_count, -- open connections use open_count
 				count() as open_count,
 				0 as proxy_count, 
 				toFloat64(0) as total_duration, -- open connections use open_duration
-				sum(duration) as open_duration,
-				[] as ts_list, -- set to zero/empty since we aren't using open connections for beaconing
-				0 as ts_unique,
-				[] as bytes,
+				sum(` + boundedDuration("duration") + `) as open_duration,
+				countIf(` + clampedDuration("duration") + `) as clamped_duration_count,
+				-- open connections contribute the time they started (the conn ts), never the time of the log they were
+				-- found in, so a connection that is still open across log rotations lands on the same point of the beacon
+				-- timeline as it will once it closes. Connections that started before the beacon window, or that were also
+				-- logged as closed in this import, are left out so that they aren't counted twice.
+				groupArrayIf(86400)(toUnixTimestamp(ts), (missing_host_header = false AND beacon_excluded = false
+					AND ts >= fromUnixTimestamp({min_ts:Int64})
+					AND zeek_uid NOT IN (SELECT zeek_uid FROM uconn_tmp)) AS open_beacon_ts) as ts_list,
+				uniqExactIf(ts, open_beacon_ts) as ts_unique,
+				-- open connections have not finished, so their data sizes are only estimated from the bytes seen so far when enabled.
+				-- The IP bytes are used, like the closed connections in uconn, and connections that were also logged as closed
+				-- in this import are left out so that their data size isn't counted twice.
+				if({estimate_open_conn_bytes:Bool}, groupArrayIf(86400)(src_ip_bytes, src_ip_bytes > 0 AND datasize_excluded = false
+					AND zeek_uid NOT IN (SELECT zeek_uid FROM uconn_tmp)), []) as bytes,
+				if({estimate_open_conn_bytes:Bool}, groupArrayIf(86400)(dst_ip_bytes, dst_ip_bytes > 0 AND datasize_excluded = false
+					AND zeek_uid NOT IN (SELECT zeek_uid FROM uconn_tmp)), []) as dst_bytes,
 				sum(src_ip_bytes + dst_ip_bytes) as total_bytes,
 				min(ts) AS first_seen,
-				max(ts) AS last_seen
+				max(ts) AS last_seen,
+				groupUniqArray(sensor) as sensors
 		FROM openconn
 		RIGHT JOIN filtered_hashes USING hash -- exclude SNI connections
+		WHERE ` + hostFilter + ` AND ` + correlatedOpenFilter + `
 		GROUP BY hash, src, src_nuid, dst, dst_nuid, src_local, dst_local
 		),
 		-- Aggregate data between all union groups
 		totaled_ipconns AS (
 			SELECT  hash, src, src_nuid, dst, dst_nuid, src_local, dst_local,
 				sum(missing_host_count) as missing_host_count,
+				sum(missing_bytes_count) as missing_bytes_count,
 				sum(conn_count) as count,
 				sum(open_count) as open_count,
 				sum(proxy_count) as proxy_count,
 				sum(total_duration + open_duration) as total_duration,
 				sum(open_duration) as open_total_duration,
-				groupArrayArray(86400)(ts_list) as ts_list,
+				sum(clamped_duration_count) as clamped_duration_count,
+				arraySort(groupArrayArray(86400)(ts_list)) as ts_list, -- sorted again since open conns are appended
 				-- since the uniqExact AggregateFunctions are defined on uconn and usni (2 separate materialized views),
 				-- the unique ts count doesn't represent the unique set between both uconn and usni, so we must take the max of these two
 				-- and as long as that value is greater than the unique_connection_threshold (checked when we loop through the results), 
 				-- we will send it to the beacon analysis workers
 				max(ts_unique) as ts_unique,
 				groupArrayArray(86400)(bytes) as bytes,
+				groupArrayArray(86400)(dst_bytes) as dst_bytes,
 				sum(total_bytes) as total_bytes,
 				max(last_seen) as last_seen,
-				min(first_seen) as first_seen
+				min(first_seen) as first_seen,
+				arrayStringConcat(arraySort(arrayFilter(x -> x != '', groupUniqArrayArray(sensors))), ',') as sensor
 				-- any(po.port_proto_service) as port_proto_service
 		FROM ip_conns
 		GROUP BY hash, src, src_nuid, dst, dst_nuid, src_local, dst_local
@@ -499,19 +663,19 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 		-- historical and port_proto are split out here instead of just being joined on at the end in order to avoid
 		-- multiplying the results (cartesian product)
 		historical AS (
-			SELECT min(first_seen) AS first_seen, ip 
-			FROM metadatabase.historical_first_seen h
+			SELECT min(first_seen) AS first_seen, ip, nuid
+			FROM {metadatabase:Identifier}.historical_first_seen h
 			LEFT JOIN ip_conns i ON h.ip = multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) 
-			GROUP BY ip
+			GROUP BY ip, nuid
 		),
 		port_proto AS (
 			SELECT hash, groupUniqArray(20)(port_proto_service) AS port_proto_service FROM (
-				SELECT DISTINCT hash, if(po.proto = 'icmp', concat(po.proto, ':', po.icmp_type, '/', po.icmp_code), concat(po.dst_port, ':', po.proto, ':', po.service)) as port_proto_service
+				SELECT DISTINCT hash, ` + portProtoServiceKey("po.") + ` as port_proto_service
 				FROM port_info po
 				LEFT JOIN ip_conns i ON i.hash = po.hash
 				WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
 				UNION DISTINCT
-				SELECT DISTINCT hash, if(proto = 'icmp', concat(proto, ':', src_port, '/', dst_port), concat(dst_port, ':', proto, ':', service)) as port_proto_service
+				SELECT DISTINCT hash, ` + portProtoServiceKey("") + ` as port_proto_service
 				FROM openconn
 				WHERE missing_host_header = false
 			)
@@ -520,16 +684,20 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 		SELECT  i.hash AS hash, i.src as src, i.src_nuid as src_nuid, i.dst as dst, i.dst_nuid as dst_nuid, 
 				'ip' AS beacon_type,
 				missing_host_count,
+				missing_bytes_count,
 				count,
 				open_count,
 				proxy_count,
 				total_duration,
 				open_total_duration,
+				clamped_duration_count,
 				ts_list,
 				ts_unique,
 				bytes,
+				dst_bytes,
 				total_bytes,
 				last_seen,
+				sensor,
 				if(t.ip != '::', true, false) AS on_threat_intel,
 				prevalence_total, 
 				toFloat32(prevalence_total / {network_size:UInt64}) AS prevalence,
@@ -537,9 +705,10 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 				po.port_proto_service as port_proto_service
 		FROM totaled_ipconns i 
 		LEFT JOIN prevalence_counts p ON if(src_local = true, i.dst, i.src) = p.ip
-		LEFT JOIN metadatabase.threat_intel t ON multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) = t.ip
+		LEFT JOIN {metadatabase:Identifier}.threat_intel t ON multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) = t.ip
 		LEFT JOIN port_proto po ON i.hash = po.hash
 		LEFT JOIN historical h ON multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) = h.ip
+			AND multiIf(src_local = true, i.src_nuid, dst_local = true, i.dst_nuid, i.src_nuid) = h.nuid
 
 	`
 
@@ -575,6 +744,12 @@ func (analyzer *Analyzer) ScoopIPConns(ctx context.Context, bars *tea.Program) e
 func (analyzer *Analyzer) ScoopDNS(ctx context.Context, bars *tea.Program) error {
 	logger := zlog.GetLogger()
 
+	// skip the query if the C2 over DNS module is disabled
+	if !analyzer.Config.ModuleEnabled(config.ModuleC2OverDNS) {
+		bars.Send(progressbar.ProgressMsg{ID: 3, Percent: 1})
+		return nil
+	}
+
 	totalRows := uint64(0)
 	hasSetTotal := false
 
@@ -595,18 +770,29 @@ func (analyzer *Analyzer) ScoopDNS(ctx context.Context, bars *tea.Program) error
 			bars.Send(progressbar.ProgressMsg{ID: 3, Percent: 1})
 		}
 
-	}), clickhouse.WithParameters(clickhouse.Parameters{
+	}), clickhouse.WithParameters(analyzer.HostFilter.addParameters(clickhouse.Parameters{
 		// use minTS (not minTSBeacon) because DNS logs don't get correlated with conn logs
 		"min_ts":              fmt.Sprintf("%d", analyzer.minTS.UTC().Unix()),
+		"metadatabase":        analyzer.Database.GetMetaDatabase(),
 		"subdomain_threshold": fmt.Sprint(analyzer.Config.Scoring.C2ScoreThresholds.Base),
 		"rolling":             strconv.FormatBool(analyzer.Database.Rolling),
 		"network_size":        fmt.Sprint(analyzer.networkSize),
-	}))
+	})))
+
+	// limit the analysis to the domains queried by the filtered hosts
+	uniqueTLDs := "SELECT DISTINCT tld FROM dns_tmp"
+	if analyzer.HostFilter.IsSet() {
+		uniqueTLDs += `
+			WHERE tld IN (
+				SELECT DISTINCT tld FROM udns
+				WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND ` + analyzer.HostFilter.condition() + `
+			)`
+	}
 
 	rows, err := analyzer.Database.Conn.Query(chCtx, `--sql
 		-- use only the domains from this import to reduce computation cost
 		WITH unique_tld AS (
-			SELECT DISTINCT tld FROM dns_tmp
+			`+uniqueTLDs+`
 		), 
 		prevalence_counts AS (
 			SELECT tld, count() AS prevalence_total FROM (
@@ -675,9 +861,10 @@ func (analyzer *Analyzer) ScoopDNS(ctx context.Context, bars *tea.Program) error
 			GROUP BY tld
 		-- keep tlds which had zero non-dns-server ips in direct connections
 		),
+		-- exploded domains aren't attributed to a network, so they use the earliest first seen of any network
 		historical AS (
 			SELECT min(first_seen) AS first_seen, cutToFirstSignificantSubdomain(fqdn) as tld 
-			FROM metadatabase.historical_first_seen
+			FROM {metadatabase:Identifier}.historical_first_seen
 			LEFT JOIN exploded_dns USING tld
 			GROUP BY tld
 		),
@@ -703,7 +890,7 @@ func (analyzer *Analyzer) ScoopDNS(ctx context.Context, bars *tea.Program) error
 		LEFT JOIN historical h ON e.tld = h.tld
 		LEFT JOIN direct_connections d ON e.tld = d.tld
 		LEFT JOIN queried_by q ON e.tld = q.tld
-		LEFT JOIN metadatabase.threat_intel t ON e.tld = cutToFirstSignificantSubdomain(t.fqdn)	
+		LEFT JOIN {metadatabase:Identifier}.threat_intel t ON e.tld = cutToFirstSignificantSubdomain(t.fqdn)	
 	`)
 	if err != nil {
 		// return error and cancel all uconn analysis
@@ -731,3 +918,518 @@ func (analyzer *Analyzer) ScoopDNS(ctx context.Context, bars *tea.Program) error
 	rows.Close()
 	return nil
 }
+
+// ScoopRDPConns gets the internal to internal RDP connections for analysis. Other RDP connections are already
+// included in the IP connection analysis, but internal to internal connections are filtered out of the conn table.
+func (analyzer *Analyzer) ScoopRDPConns(ctx context.Context, bars *tea.Program) error {
+	logger := zlog.GetLogger()
+
+	// skip the query if the beacon, long connection, and strobe modules are disabled
+	if !analyzer.connModulesEnabled() {
+		bars.Send(progressbar.ProgressMsg{ID: 4, Percent: 1})
+		return nil
+	}
+
+	chCtx := analyzer.Database.QueryParameters(analyzer.HostFilter.addParameters(addPortProtoServiceParameters(analyzer.Config.PortProtoService, analyzer.addLongConnDurationParameters(clickhouse.Parameters{
+		// use minTSBeacon because rdp entries are linked with their conn entries
+		"min_ts":       fmt.Sprintf("%d", analyzer.minTSBeacon.UTC().Unix()),
+		"import_time":  fmt.Sprintf("%d", analyzer.Database.ImportStartedAt.UTC().Unix()),
+		"network_size": fmt.Sprint(analyzer.networkSize),
+		"rolling":      strconv.FormatBool(analyzer.Database.Rolling),
+	}))))
+
+	rows, err := analyzer.Database.Conn.Query(chCtx, `--sql
+		-- limit analysis to the rdp connections that were updated in this import
+		WITH unique_rdp AS (
+			SELECT DISTINCT hash FROM rdp
+			WHERE import_time = fromUnixTimestamp({import_time:Int64}) AND src_local AND dst_local AND `+analyzer.HostFilter.condition()+`
+		),
+		-- number of internal hosts that made an rdp connection to each destination
+		prevalence_counts AS (
+			SELECT dst, uniqExact(src) AS prevalence_total FROM rdp
+			WHERE src_local AND ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY dst
+		),
+		historical AS (
+			SELECT hash, min(ts) AS first_seen FROM rdp
+			RIGHT JOIN unique_rdp USING hash
+			GROUP BY hash
+		),
+		port_proto AS (
+			SELECT hash, groupUniqArray(20)(`+portProtoServiceKeyNoICMP("")+`) AS port_proto_service FROM rdp
+			RIGHT JOIN unique_rdp USING hash
+			WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY hash
+		),
+		rdp_conns AS (
+			SELECT hash, src, src_nuid, dst, dst_nuid,
+				count() AS count,
+				uniqExact(ts) AS ts_unique,
+				arraySort(groupArray(86400)(toUnixTimestamp(ts))) AS ts_list,
+				arraySort(groupArray(86400)(src_ip_bytes)) AS bytes,
+				arraySort(groupArray(86400)(dst_ip_bytes)) AS dst_bytes,
+				sum(src_ip_bytes + dst_ip_bytes) AS total_bytes,
+				-- each connection's duration is bounded before it's added to the total duration
+				sum(`+boundedDuration("duration")+`) AS total_duration,
+				countIf(`+clampedDuration("duration")+`) AS clamped_duration_count,
+				min(ts) AS first_seen,
+				max(ts) AS last_seen,
+				arrayStringConcat(arraySort(arrayFilter(x -> x != '', groupUniqArray(sensor))), ',') AS sensor
+			FROM rdp
+			RIGHT JOIN unique_rdp USING hash
+			WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY hash, src, src_nuid, dst, dst_nuid
+		)
+		SELECT r.hash AS hash, r.src AS src, r.src_nuid AS src_nuid, r.dst AS dst, r.dst_nuid AS dst_nuid,
+			'rdp' AS beacon_type,
+			count,
+			ts_unique,
+			ts_list,
+			bytes,
+			dst_bytes,
+			total_bytes,
+			total_duration,
+			clamped_duration_count,
+			last_seen,
+			sensor,
+			prevalence_total,
+			toFloat32(prevalence_total / {network_size:UInt64}) AS prevalence,
+			if({rolling:Bool}, h.first_seen, r.first_seen) AS first_seen_historical,
+			po.port_proto_service AS port_proto_service
+		FROM rdp_conns r
+		LEFT JOIN prevalence_counts p ON r.dst = p.dst
+		LEFT JOIN historical h ON r.hash = h.hash
+		LEFT JOIN port_proto po ON r.hash = po.hash
+	`)
+	if err != nil {
+		// return error and cancel all uconn analysis
+		return fmt.Errorf("could not retrieve unique RDP connections for analysis: %w", err)
+	}
+	logger.Debug().Msg("successfully retrieved RDP connections")
+
+	// loop over the rows
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling RDP uconns query for analysis")
+			rows.Close()
+			return ctx.Err()
+		default:
+			var res AnalysisResult
+			if err := rows.ScanStruct(&res); err != nil {
+				// return error and cancel all uconn analysis
+				return fmt.Errorf("could not read RDP connection during analysis: %w", err)
+			}
+
+			// send the unique rdp connection to the uconn analysis channel
+			analyzer.UconnChan <- res
+		}
+	}
+	rows.Close()
+	bars.Send(progressbar.ProgressMsg{ID: 4, Percent: 1})
+	return nil
+}
+
+// ScoopDistributedConns gets the outbound connections that each source made on the same port, protocol, and service
+// to a pool of destinations as a single connection for beacon analysis. Beacons that rotate between destination IPs
+// may not contact any one destination often enough to be scored, but the timestamps of the whole pool are still
+// periodic. These connections are only scored for beaconing since each destination is already scored on its own.
+func (analyzer *Analyzer) ScoopDistributedConns(ctx context.Context, bars *tea.Program) error {
+	logger := zlog.GetLogger()
+
+	distributed := analyzer.Config.Scoring.Beacon.DistributedBeacons
+
+	// skip the query if distributed beacons or the beacon module are disabled
+	if !distributed.Enabled || analyzer.skipBeaconing || !analyzer.Config.ModuleEnabled(config.ModuleBeacons) {
+		bars.Send(progressbar.ProgressMsg{ID: 5, Percent: 1})
+		return nil
+	}
+
+	chCtx := analyzer.Database.QueryParameters(analyzer.HostFilter.addParameters(addPortProtoServiceParameters(analyzer.Config.PortProtoService, clickhouse.Parameters{
+		"min_ts":           fmt.Sprintf("%d", analyzer.minTSBeacon.UTC().Unix()),
+		"min_destinations": fmt.Sprint(distributed.MinDestinations),
+		"max_destinations": fmt.Sprint(distributed.MaxDestinations),
+		"network_size":     fmt.Sprint(analyzer.networkSize),
+		"rolling":          strconv.FormatBool(analyzer.Database.Rolling),
+	})))
+
+	rows, err := analyzer.Database.Conn.Query(chCtx, `--sql
+		-- limit analysis to the sources that made connections in this import
+		WITH updated_srcs AS (
+			SELECT DISTINCT src, src_nuid FROM conn
+			LEFT SEMI JOIN uconn_tmp USING hash
+			WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+		),
+		-- group the outbound connections of each source by port, protocol, and service instead of by destination
+		pools AS (
+			SELECT src, src_nuid, `+portProtoServiceKey("")+` AS port_proto_service,
+				count() AS count,
+				uniqExact(dst) AS dst_count,
+				uniqExactIf(ts, beacon_excluded = false) AS ts_unique,
+				arraySort(groupArrayIf(86400)(toUnixTimestamp(ts), beacon_excluded = false)) AS ts_list,
+				arraySort(groupArrayIf(86400)(src_ip_bytes, beacon_excluded = false AND datasize_excluded = false)) AS bytes,
+				arraySort(groupArrayIf(86400)(dst_ip_bytes, beacon_excluded = false AND datasize_excluded = false)) AS dst_bytes,
+				sum(src_ip_bytes + dst_ip_bytes) AS total_bytes,
+				sum(duration) AS total_duration,
+				countIf(missing_dst_bytes = true) AS missing_bytes_count,
+				groupUniqArray(100)(dst) AS server_ips,
+				min(ts) AS first_seen,
+				max(ts) AS last_seen,
+				arrayStringConcat(arraySort(arrayFilter(x -> x != '', groupUniqArray(sensor))), ',') AS sensor
+			FROM conn
+			LEFT SEMI JOIN updated_srcs USING (src, src_nuid)
+			WHERE ts >= fromUnixTimestamp({min_ts:Int64}) AND missing_host_header = false AND src_local AND NOT dst_local AND `+analyzer.HostFilter.condition()+`
+			GROUP BY src, src_nuid, port_proto_service
+			HAVING dst_count >= {min_destinations:UInt64} AND ({max_destinations:UInt64} = 0 OR dst_count <= {max_destinations:UInt64})
+		),
+		-- number of internal hosts that connected to each destination
+		prevalence_counts AS (
+			SELECT dst, uniqExact(src) AS prevalence_total FROM uconn
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND src_local
+			GROUP BY dst
+		),
+		historical AS (
+			SELECT ip, nuid, min(first_seen) AS first_seen FROM {metadatabase:Identifier}.historical_first_seen
+			GROUP BY ip, nuid
+		),
+		-- a pool is as prevalent as its most prevalent destination and was first seen when its oldest destination was
+		pool_history AS (
+			SELECT d.src AS src, d.src_nuid AS src_nuid, d.port_proto_service AS port_proto_service,
+				max(p.prevalence_total) AS prevalence_total,
+				minIf(h.first_seen, toUnixTimestamp(h.first_seen) > 0) AS first_seen
+			FROM (
+				SELECT src, src_nuid, port_proto_service, arrayJoin(server_ips) AS dst FROM pools
+			) d
+			LEFT JOIN prevalence_counts p ON d.dst = p.dst
+			LEFT JOIN historical h ON d.dst = h.ip AND d.src_nuid = h.nuid
+			GROUP BY src, src_nuid, port_proto_service
+		)
+		SELECT p.src AS src, p.src_nuid AS src_nuid,
+			'distributed' AS beacon_type,
+			count,
+			ts_unique,
+			ts_list,
+			bytes,
+			dst_bytes,
+			total_bytes,
+			total_duration,
+			missing_bytes_count,
+			server_ips,
+			last_seen,
+			sensor,
+			ph.prevalence_total AS prevalence_total,
+			toFloat32(ph.prevalence_total / {network_size:UInt64}) AS prevalence,
+			if({rolling:Bool}, ph.first_seen, p.first_seen) AS first_seen_historical,
+			[p.port_proto_service] AS port_proto_service
+		FROM pools p
+		LEFT JOIN pool_history ph ON p.src = ph.src AND p.src_nuid = ph.src_nuid AND p.port_proto_service = ph.port_proto_service
+	`)
+	if err != nil {
+		// return error and cancel all uconn analysis
+		return fmt.Errorf("could not retrieve distributed connections for analysis: %w", err)
+	}
+	logger.Debug().Msg("successfully retrieved distributed connections")
+
+	// loop over the rows
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling distributed connections query for analysis")
+			rows.Close()
+			return ctx.Err()
+		default:
+			var res AnalysisResult
+			if err := rows.ScanStruct(&res); err != nil {
+				// return error and cancel all uconn analysis
+				return fmt.Errorf("could not read distributed connection during analysis: %w", err)
+			}
+
+			// the pool doesn't have a single destination, so it is identified by its source and port
+			res.Hash, err = distributedHash(res.Src, res.SrcNUID, res.PortProtoService)
+			if err != nil {
+				return fmt.Errorf("could not hash distributed connection during analysis: %w", err)
+			}
+
+			// send the distributed connection to the uconn analysis channel
+			analyzer.UconnChan <- res
+		}
+	}
+	rows.Close()
+	bars.Send(progressbar.ProgressMsg{ID: 5, Percent: 1})
+	return nil
+}
+
+// ScoopPortConns gets the IP connections that were made on more than one destination port, split into a connection
+// for each port, for beacon analysis. A source that beacons to a destination on more than one port can hide a clean
+// beacon on one port in the timestamps of the others. These connections are only scored for beaconing since the
+// connection as a whole is already scored for every indicator.
+func (analyzer *Analyzer) ScoopPortConns(ctx context.Context, bars *tea.Program) error {
+	logger := zlog.GetLogger()
+
+	// skip the query if splitting beacons by port or the beacon module are disabled
+	if !analyzer.Config.Scoring.Beacon.SplitByPort || analyzer.skipBeaconing || !analyzer.Config.ModuleEnabled(config.ModuleBeacons) {
+		bars.Send(progressbar.ProgressMsg{ID: 6, Percent: 1})
+		return nil
+	}
+
+	chCtx := analyzer.Database.QueryParameters(analyzer.HostFilter.addParameters(addPortProtoServiceParameters(analyzer.Config.PortProtoService, clickhouse.Parameters{
+		"min_ts":       fmt.Sprintf("%d", analyzer.minTSBeacon.UTC().Unix()),
+		"network_size": fmt.Sprint(analyzer.networkSize),
+		"rolling":      strconv.FormatBool(analyzer.Database.Rolling),
+	})))
+
+	// ports are grouped the same way as in the port:proto:service keys, ICMP connections don't have ports
+	port := normalizedPort("")
+	connFilter := `ts >= fromUnixTimestamp({min_ts:Int64}) AND proto != 'icmp' AND missing_host_header = false
+		AND zeek_uid NOT IN (SELECT zeek_uid FROM sni_uids) AND ` + analyzer.HostFilter.condition()
+
+	rows, err := analyzer.Database.Conn.Query(chCtx, `--sql
+		-- connections that are part of an SNI connection are scored with the SNI connection
+		WITH sni_uids AS (
+			SELECT DISTINCT zeek_uid FROM sniconn_tmp
+			UNION DISTINCT
+			SELECT DISTINCT zeek_uid FROM opensniconn_tmp
+		),
+		-- limit analysis to the IP connections that were updated in this import and were made on more than one port
+		multi_port AS (
+			SELECT hash FROM conn
+			LEFT SEMI JOIN uconn_tmp USING hash
+			WHERE `+connFilter+`
+			GROUP BY hash
+			HAVING uniqExact(`+port+`, proto) > 1
+		),
+		ports AS (
+			SELECT hash, src, src_nuid, dst, dst_nuid, src_local, dst_local, `+port+` AS port, proto,
+				groupUniqArray(20)(`+portProtoServiceKeyNoICMP("")+`) AS port_proto_service,
+				count() AS count,
+				uniqExactIf(ts, beacon_excluded = false) AS ts_unique,
+				arraySort(groupArrayIf(86400)(toUnixTimestamp(ts), beacon_excluded = false)) AS ts_list,
+				arraySort(groupArrayIf(86400)(src_ip_bytes, beacon_excluded = false AND datasize_excluded = false)) AS bytes,
+				arraySort(groupArrayIf(86400)(dst_ip_bytes, beacon_excluded = false AND datasize_excluded = false)) AS dst_bytes,
+				sum(src_ip_bytes + dst_ip_bytes) AS total_bytes,
+				sum(duration) AS total_duration,
+				countIf(missing_dst_bytes = true) AS missing_bytes_count,
+				min(ts) AS first_seen,
+				max(ts) AS last_seen,
+				arrayStringConcat(arraySort(arrayFilter(x -> x != '', groupUniqArray(sensor))), ',') AS sensor
+			FROM conn
+			LEFT SEMI JOIN multi_port USING hash
+			WHERE `+connFilter+`
+			GROUP BY hash, src, src_nuid, dst, dst_nuid, src_local, dst_local, port, proto
+		),
+		prevalence_counts AS (
+			SELECT ip, count() AS prevalence_total FROM (
+				SELECT DISTINCT if(src_local, dst, src) AS ip, if(src_local, src, dst) AS internal FROM uconn
+				WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			)
+			GROUP BY ip
+		),
+		historical AS (
+			SELECT ip, nuid, min(first_seen) AS first_seen FROM {metadatabase:Identifier}.historical_first_seen
+			GROUP BY ip, nuid
+		)
+		SELECT p.src AS src, p.src_nuid AS src_nuid, p.dst AS dst, p.dst_nuid AS dst_nuid,
+			'ip_port' AS beacon_type,
+			count,
+			ts_unique,
+			ts_list,
+			bytes,
+			dst_bytes,
+			total_bytes,
+			total_duration,
+			missing_bytes_count,
+			last_seen,
+			sensor,
+			pc.prevalence_total AS prevalence_total,
+			toFloat32(pc.prevalence_total / {network_size:UInt64}) AS prevalence,
+			if({rolling:Bool}, h.first_seen, p.first_seen) AS first_seen_historical,
+			arraySort(port_proto_service) AS port_proto_service
+		FROM ports p
+		LEFT JOIN prevalence_counts pc ON if(p.src_local, p.dst, p.src) = pc.ip
+		LEFT JOIN historical h ON multiIf(p.src_local, p.dst, p.dst_local, p.src, p.dst) = h.ip
+			AND multiIf(p.src_local, p.src_nuid, p.dst_local, p.dst_nuid, p.src_nuid) = h.nuid
+	`)
+	if err != nil {
+		// return error and cancel all uconn analysis
+		return fmt.Errorf("could not retrieve per-port connections for analysis: %w", err)
+	}
+	logger.Debug().Msg("successfully retrieved per-port connections")
+
+	// loop over the rows
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling per-port connections query for analysis")
+			rows.Close()
+			return ctx.Err()
+		default:
+			var res AnalysisResult
+			if err := rows.ScanStruct(&res); err != nil {
+				// return error and cancel all uconn analysis
+				return fmt.Errorf("could not read per-port connection during analysis: %w", err)
+			}
+
+			// each port of the connection needs its own hash so that it is kept apart from the connection as a whole
+			res.Hash, err = portHash(res.Src, res.SrcNUID, res.Dst, res.DstNUID, res.PortProtoService)
+			if err != nil {
+				return fmt.Errorf("could not hash per-port connection during analysis: %w", err)
+			}
+
+			// send the per-port connection to the uconn analysis channel
+			analyzer.UconnChan <- res
+		}
+	}
+	rows.Close()
+	bars.Send(progressbar.ProgressMsg{ID: 6, Percent: 1})
+	return nil
+}
+
+// ScoopParentDomainConns gets the SNI connections that each source made to more than one subdomain of the same
+// registrable domain as a single connection to that domain for beacon analysis. Beacons that rotate between
+// subdomains may not contact any one subdomain often enough to be scored, but the timestamps of the whole domain are
+// still periodic. These connections are only scored for beaconing and prevalence since each subdomain is already
+// scored on its own.
+func (analyzer *Analyzer) ScoopParentDomainConns(ctx context.Context, bars *tea.Program) error {
+	logger := zlog.GetLogger()
+
+	// skip the query if aggregating subdomains or the beacon module are disabled
+	if !analyzer.Config.Scoring.Beacon.AggregateSubdomains || analyzer.skipBeaconing || !analyzer.Config.ModuleEnabled(config.ModuleBeacons) {
+		bars.Send(progressbar.ProgressMsg{ID: 7, Percent: 1})
+		return nil
+	}
+
+	params := analyzer.HostFilter.addParameters(clickhouse.Parameters{
+		"min_ts":       fmt.Sprintf("%d", analyzer.minTSBeacon.UTC().Unix()),
+		"network_size": fmt.Sprint(analyzer.networkSize),
+		"rolling":      strconv.FormatBool(analyzer.Database.Rolling),
+	})
+	grouped, err := CreateParentDomainTable(analyzer.Database, analyzer.minTSBeacon, params)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := analyzer.Database.DropParentDomainTable(); err != nil {
+			logger.Warn().Err(err).Msg("could not drop the parent domain table")
+		}
+	}()
+	if !grouped {
+		bars.Send(progressbar.ProgressMsg{ID: 7, Percent: 1})
+		return nil
+	}
+	chCtx := analyzer.Database.QueryParameters(params)
+
+	rows, err := analyzer.Database.Conn.Query(chCtx, `--sql
+		WITH `+ParentDomainCTEs+`,
+		-- limit analysis to the sources that made SNI connections in this import
+		updated_srcs AS (
+			SELECT DISTINCT src, src_nuid FROM usni
+			LEFT SEMI JOIN (SELECT DISTINCT hash FROM sniconn_tmp) u USING hash
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+		),
+		-- group the SNI connections of each source by the parent domain of their subdomains
+		domains AS (
+			SELECT src, src_nuid, `+parentDomain+` AS parent,
+				uniqExact(fqdn) AS subdomains,
+				countMerge(count) AS conn_count,
+				countMerge(proxy_count) AS proxy_count,
+				uniqExactMerge(unique_ts_count) AS ts_unique,
+				arraySort(groupArrayMerge(86400)(ts_list)) AS ts_list,
+				arraySort(groupArrayMerge(86400)(src_ip_bytes_list)) AS bytes,
+				arraySort(groupArrayMerge(86400)(dst_ip_bytes_list)) AS dst_bytes,
+				sumMerge(total_ip_bytes) AS total_bytes,
+				sumMerge(total_duration) AS total_duration,
+				groupUniqArrayMerge(10)(server_ips) AS server_ips,
+				groupUniqArrayMerge(10)(proxy_ips) AS proxy_ips,
+				minMerge(first_seen) AS first_seen,
+				maxMerge(last_seen) AS last_seen,
+				arrayStringConcat(arraySort(arrayFilter(x -> x != '', groupUniqArrayMerge(sensors))), ',') AS sensor
+			FROM usni
+			LEFT SEMI JOIN updated_srcs USING (src, src_nuid)
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND `+groupedSubdomain+`
+				AND `+analyzer.HostFilter.condition()+`
+			GROUP BY src, src_nuid, parent
+			HAVING subdomains > 1
+		)
+		SELECT d.src AS src, d.src_nuid AS src_nuid, d.parent AS fqdn,
+			'sni_parent' AS beacon_type,
+			conn_count AS count,
+			proxy_count,
+			ts_unique,
+			ts_list,
+			bytes,
+			dst_bytes,
+			total_bytes,
+			total_duration,
+			server_ips,
+			proxy_ips,
+			last_seen,
+			sensor,
+			pc.prevalence_total AS prevalence_total,
+			toFloat32(pc.prevalence_total / {network_size:UInt64}) AS prevalence,
+			if({rolling:Bool}, h.first_seen, d.first_seen) AS first_seen_historical
+		FROM domains d
+		LEFT JOIN parent_domain_prevalence pc ON d.parent = pc.parent
+		LEFT JOIN parent_domain_history h ON d.parent = h.parent AND d.src_nuid = h.nuid
+	`)
+	if err != nil {
+		// return error and cancel all uconn analysis
+		return fmt.Errorf("could not retrieve parent domain connections for analysis: %w", err)
+	}
+	logger.Debug().Msg("successfully retrieved parent domain connections")
+
+	// loop over the rows
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling parent domain connections query for analysis")
+			rows.Close()
+			return ctx.Err()
+		default:
+			var res AnalysisResult
+			if err := rows.ScanStruct(&res); err != nil {
+				// return error and cancel all uconn analysis
+				return fmt.Errorf("could not read parent domain connection during analysis: %w", err)
+			}
+
+			// the parent domain needs its own hash so that it is kept apart from a connection to the domain itself
+			res.Hash, err = parentDomainHash(res.Src, res.SrcNUID, res.FQDN)
+			if err != nil {
+				return fmt.Errorf("could not hash parent domain connection during analysis: %w", err)
+			}
+
+			// send the parent domain connection to the uconn analysis channel
+			analyzer.UconnChan <- res
+		}
+	}
+	rows.Close()
+	bars.Send(progressbar.ProgressMsg{ID: 7, Percent: 1})
+	return nil
+}
+
+// parentDomainHash returns the hash that identifies the connections that a source made to the subdomains of a domain
+func parentDomainHash(src net.IP, srcNUID uuid.UUID, parent string) (util.FixedString, error) {
+	return util.NewBeaconHash("sni_parent", util.ConnectionIdentity{Src: src, SrcNUID: srcNUID, FQDN: parent})
+}
+
+// portHash returns the hash that identifies the connections between a source and destination on a single port. The
+// hash of the connection as a whole is extended with the port and protocol, which every port:proto:service key of the
+// connections on that port starts with.
+func portHash(src net.IP, srcNUID uuid.UUID, dst net.IP, dstNUID uuid.UUID, portProtoService []string) (util.FixedString, error) {
+	if len(portProtoService) == 0 {
+		return util.FixedString{}, errors.New("per-port connection has no port")
+	}
+	parts := strings.SplitN(portProtoService[0], ":", 3)
+	if len(parts) < 2 {
+		return util.FixedString{}, fmt.Errorf("invalid port:proto:service key: %s", portProtoService[0])
+	}
+	return util.NewBeaconHash("ip_port", util.ConnectionIdentity{Src: src, SrcNUID: srcNUID, Dst: dst, DstNUID: dstNUID, DstPort: parts[0], Proto: parts[1]})
+}
+
+// distributedHash returns the hash that identifies the pool of connections that a source made on a port:proto:service
+func distributedHash(src net.IP, srcNUID uuid.UUID, portProtoService []string) (util.FixedString, error) {
+	return util.NewBeaconHash("distributed", util.ConnectionIdentity{Src: src, SrcNUID: srcNUID, PortProtoService: portProtoService})
+}
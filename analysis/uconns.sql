@@ -120,10 +120,10 @@ WITH unique_http AS (
 		-- historical and port_proto are split out here instead of just being joined on at the end in order to avoid
 		-- multiplying the results (cartesian product)
 		historical AS (
-			SELECT min(first_seen) AS first_seen, ip 
+			SELECT min(first_seen) AS first_seen, ip, nuid
 			FROM metadatabase.historical_first_seen h
 			LEFT JOIN ip_conns i ON h.ip = multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) 
-			GROUP BY ip
+			GROUP BY ip, nuid
 		),
 		port_proto AS (
 			SELECT hash, groupUniqArray(20)(port_proto_service) AS port_proto_service FROM (
@@ -162,4 +162,5 @@ WITH unique_http AS (
 		LEFT JOIN metadatabase.threat_intel t ON multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) = t.ip
 		LEFT JOIN port_proto po ON i.hash = po.hash
 		LEFT JOIN historical h ON multiIf(src_local = true, i.dst, dst_local = true, i.src, i.dst) = h.ip
+			AND multiIf(src_local = true, i.src_nuid, dst_local = true, i.dst_nuid, i.src_nuid) = h.nuid
 		
\ No newline at end of file
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var AnnotateCommand = &cli.Command{
+	Name:        "annotate",
+	Usage:       "record the triage status of a result",
+	UsageText:   "rita annotate --database NAME --hash HASH --status " + strings.Join(database.AnnotationStatuses, "|") + " [--note TEXT]",
+	Description: "marks the results with a hash as " + strings.Join(database.AnnotationStatuses, ", ") + ", the annotation is keyed by the hash so it is kept when the dataset is re-imported, annotating a hash again replaces its annotation",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset that the result is in",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "hash",
+			Usage:    "hash of the result to annotate",
+			Required: true,
+			Action: func(_ *cli.Context, hash string) error {
+				return ValidateHash(hash)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "status",
+			Usage:    "triage status: " + strings.Join(database.AnnotationStatuses, ", "),
+			Required: true,
+			Action: func(_ *cli.Context, status string) error {
+				return database.ValidateAnnotationStatus(status)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "note",
+			Usage:    "free text note to store with the status",
+			Required: false,
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the annotate command, hashes are stored upper case to match ClickHouse's hex output
+		hash := strings.ToUpper(strings.TrimSpace(cCtx.String("hash")))
+		if err := runAnnotateCmd(cfg, cCtx.String("database"), hash, cCtx.String("status"), cCtx.String("note")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runAnnotateCmd(cfg *config.Config, dbName string, hash string, status string, note string) error {
+	// connect to server
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	// make sure the dataset exists so that typos in its name aren't silently annotated
+	exists, err := database.SensorDatabaseExists(server.GetContext(), server.Conn, server.GetMetaDatabase(), dbName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotFound, dbName)
+	}
+
+	// make sure the metadatabase tables exist
+	if err := server.CreateServerDBTables(); err != nil {
+		return err
+	}
+
+	if err := server.Annotate(dbName, hash, status, note); err != nil {
+		return err
+	}
+
+	fmt.Printf("Marked %s in %s as %s.\n", hash, dbName, status)
+	return nil
+}
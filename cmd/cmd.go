@@ -22,10 +22,24 @@ var ErrCheckingForUpdate = errors.New("error checking for newer version of RITA"
 func Commands() []*cli.Command {
 	return []*cli.Command{
 		ImportCommand,
+		IngestCommand,
 		ViewCommand,
 		DeleteCommand,
 		ListCommand,
+		DiffCommand,
+		CombineCommand,
+		ServeCommand,
 		ValidateConfigCommand,
+		ReportCommand,
+		QueryCommand,
+		ExportCommand,
+		MigrateCommand,
+		SetThresholdsCommand,
+		ZeekIntelCommand,
+		ReconfigureCommand,
+		RefreshModifiersCommand,
+		HealthCheckCommand,
+		AnnotateCommand,
 	}
 }
 
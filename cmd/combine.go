@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrTooFewCombineSources = errors.New("at least two source databases are required")
+var ErrDuplicateCombineSource = errors.New("source databases must be unique")
+var ErrCombineOutputIsSource = errors.New("output database cannot also be a source database")
+var ErrCombineOutputExists = errors.New("output database already exists, use --rebuild to replace it")
+
+var CombineCommand = &cli.Command{
+	Name:        "combine",
+	Usage:       "combine multiple datasets into a new dataset for cross-sensor analysis",
+	UsageText:   "combine --output <dataset name> --sources <dataset name>,<dataset name>[,...] [--rebuild]",
+	Description: "copies the log data of every source dataset into the output dataset and analyzes it as a whole, so that prevalence and first seen are computed across all sources",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "output",
+			Aliases:  []string{"o"},
+			Usage:    "dataset to create from the combined sources",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "sources",
+			Aliases:  []string{"s"},
+			Usage:    "comma separated list of datasets to combine",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:     "rebuild",
+			Aliases:  []string{"x"},
+			Usage:    "destroys the output dataset if it already exists",
+			Value:    false,
+			Required: false,
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		output := cCtx.String("output")
+		sources := ParseCombineSources(cCtx.String("sources"))
+
+		// validate the database names
+		if err := ValidateCombineDatabases(output, sources); err != nil {
+			return err
+		}
+
+		// set up file system interface
+		afs := afero.NewOsFs()
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the combine command
+		if err := runCombineCmd(time.Now(), cfg, afs, output, sources, cCtx.Bool("rebuild")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+// ParseCombineSources splits a comma separated list of database names, ignoring empty entries
+func ParseCombineSources(value string) []string {
+	var sources []string
+	for _, source := range strings.Split(value, ",") {
+		source = strings.TrimSpace(source)
+		if source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// ValidateCombineDatabases validates the names of the output and source databases
+func ValidateCombineDatabases(output string, sources []string) error {
+	if err := ValidateDatabaseName(output); err != nil {
+		return err
+	}
+
+	if len(sources) < 2 {
+		return ErrTooFewCombineSources
+	}
+
+	seen := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		if err := ValidateDatabaseName(source); err != nil {
+			return err
+		}
+		if source == output {
+			return ErrCombineOutputIsSource
+		}
+		if seen[source] {
+			return fmt.Errorf("%w: %s", ErrDuplicateCombineSource, source)
+		}
+		seen[source] = true
+	}
+
+	return nil
+}
+
+func runCombineCmd(startTime time.Time, cfg *config.Config, afs afero.Fs, output string, sources []string, rebuild bool) error {
+	logger := zlog.GetLogger()
+
+	logger.Info().Str("output", output).Strs("sources", sources).Bool("rebuild", rebuild).Msg("Combining datasets...")
+
+	// make sure every source can be combined before anything is written
+	if err := validateCombineSources(cfg, output, sources, rebuild); err != nil {
+		return err
+	}
+
+	// create the output database and connect to it
+	db, err := database.SetUpNewImport(afs, cfg, output, false, rebuild, false)
+	if err != nil {
+		return err
+	}
+
+	// remove the output database if the sources could not be fully combined
+	if err := combineSources(db, cfg, startTime, sources); err != nil {
+		server, connErr := database.ConnectToServer(context.Background(), cfg)
+		if connErr != nil {
+			return errors.Join(err, connErr)
+		}
+		defer server.Close()
+
+		if dropErr := server.DeleteSensorDB(output); dropErr != nil {
+			return errors.Join(err, dropErr)
+		}
+		return err
+	}
+
+	logger.Info().Str("elapsed_time", fmt.Sprintf("%1.1fs", time.Since(startTime).Seconds())).Str("output", output).Msg("🎊✨ Finished Combining Datasets! ✨🎊")
+
+	return nil
+}
+
+// validateCombineSources makes sure that every source database exists and has been analyzed, and that
+// the output database does not already exist unless it is being rebuilt
+func validateCombineSources(cfg *config.Config, output string, sources []string, rebuild bool) error {
+	ctx := context.Background()
+
+	server, err := database.ConnectToServer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	if !rebuild {
+		exists, err := database.DatabaseExists(ctx, server.Conn, output)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: %s", ErrCombineOutputExists, output)
+		}
+	}
+
+	for _, source := range sources {
+		exists, err := database.SensorDatabaseExists(ctx, server.Conn, server.GetMetaDatabase(), source)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrDatabaseNotFound, source)
+		}
+
+		db, err := database.ConnectToDB(ctx, source, cfg, nil)
+		if err != nil {
+			return err
+		}
+
+		analyzed, err := db.HasFinishedImport()
+		db.Close()
+		if err != nil {
+			return err
+		}
+		if !analyzed {
+			return fmt.Errorf("%w: %s", ErrDatabaseNotAnalyzed, source)
+		}
+	}
+
+	// the sources must share the same schema so that their data can be copied into a single database
+	for _, source := range sources[1:] {
+		if err := server.CheckSchemaCompatibility(sources[0], source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// combineSources copies the data of every source into the selected database and analyzes it as a single import
+func combineSources(db *database.DB, cfg *config.Config, startTime time.Time, sources []string) error {
+	logger := zlog.GetLogger()
+
+	// the output database was created with the current schema, which the sources must also match
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	if err := server.CheckSchemaCompatibility(db.GetSelectedDB(), sources[0]); err != nil {
+		return err
+	}
+
+	// create a unique import id using the start time
+	db.ImportStartedAt = startTime
+	importID, err := util.NewFixedStringHash(strconv.FormatInt(startTime.UnixMicro(), 10))
+	if err != nil {
+		return err
+	}
+
+	if err := db.AddImportStartRecordToMetaDB(importID); err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		logger.Info().Str("source", source).Msg("Copying source dataset")
+		if err := db.CopySourceData(source, importID); err != nil {
+			return err
+		}
+	}
+
+	// analyze the union of the source data
+	_, err = AnalyzeImport(db, cfg, importID)
+	return err
+}
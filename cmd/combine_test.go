@@ -0,0 +1,74 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCombineSources(t *testing.T) {
+	require.Equal(t, []string{"sensor1", "sensor2"}, cmd.ParseCombineSources("sensor1,sensor2"))
+	require.Equal(t, []string{"sensor1", "sensor2"}, cmd.ParseCombineSources(" sensor1 , sensor2 ,"), "whitespace and empty entries should be ignored")
+	require.Nil(t, cmd.ParseCombineSources(""))
+}
+
+func TestValidateCombineDatabases(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		sources     []string
+		expectedErr error
+	}{
+		{
+			name:    "Valid Sources",
+			output:  "combined",
+			sources: []string{"sensor1", "sensor2", "sensor3"},
+		},
+		{
+			name:        "Missing Output",
+			output:      "",
+			sources:     []string{"sensor1", "sensor2"},
+			expectedErr: cmd.ErrMissingDatabaseName,
+		},
+		{
+			name:        "Single Source",
+			output:      "combined",
+			sources:     []string{"sensor1"},
+			expectedErr: cmd.ErrTooFewCombineSources,
+		},
+		{
+			name:        "No Sources",
+			output:      "combined",
+			sources:     nil,
+			expectedErr: cmd.ErrTooFewCombineSources,
+		},
+		{
+			name:        "Output Is Source",
+			output:      "sensor1",
+			sources:     []string{"sensor1", "sensor2"},
+			expectedErr: cmd.ErrCombineOutputIsSource,
+		},
+		{
+			name:        "Duplicate Source",
+			output:      "combined",
+			sources:     []string{"sensor1", "sensor2", "sensor1"},
+			expectedErr: cmd.ErrDuplicateCombineSource,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := cmd.ValidateCombineDatabases(test.output, test.sources)
+			if test.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+
+	// invalid source names are rejected
+	require.Error(t, cmd.ValidateCombineDatabases("combined", []string{"sensor1", "Sensor-2"}))
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/activecm/rita/v5/config"
@@ -16,21 +18,29 @@ import (
 )
 
 var ErrTrimmedNameEmpty = errors.New("trimmed name cannot contain wildcards or be empty")
+var ErrMatchWithDatabaseName = errors.New("cannot specify both a dataset name and --match")
+var ErrInvalidMatchPattern = errors.New("invalid --match pattern")
 
 var DeleteCommand = &cli.Command{
 	Name:        "delete",
 	Usage:       "delete a dataset",
-	UsageText:   "delete [NAME]",
-	Description: "if <dataset name> ends in a wildcard, all datasets with that prefix will be deleted",
+	UsageText:   "delete [NAME] | delete --match PATTERN",
+	Description: "if <dataset name> ends in a wildcard, all datasets with that prefix will be deleted; --match deletes all datasets matching a glob pattern",
 	Args:        false,
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
 			Name:     "non-interactive",
-			Aliases:  []string{"ni"},
+			Aliases:  []string{"ni", "yes", "y"},
 			Usage:    "does not prompt for confirmation of deletion",
 			Value:    false,
 			Required: false,
 		},
+		&cli.StringFlag{
+			Name:     "match",
+			Aliases:  []string{"m"},
+			Usage:    "delete all datasets whose names match a glob `PATTERN` (e.g. 'sensor_*'); reserved databases are never matched",
+			Required: false,
+		},
 		ConfigFlag(false),
 	},
 	Action: func(cCtx *cli.Context) error {
@@ -39,6 +49,31 @@ var DeleteCommand = &cli.Command{
 			return ErrTooManyArguments
 		}
 
+		prompt := !cCtx.Bool("non-interactive")
+
+		// delete all datasets matching a glob pattern
+		if cCtx.IsSet("match") {
+			if cCtx.Args().Present() {
+				return ErrMatchWithDatabaseName
+			}
+
+			pattern := cCtx.String("match")
+			if err := ValidateMatchPattern(pattern); err != nil {
+				return err
+			}
+
+			cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+			if err != nil {
+				return err
+			}
+
+			if err := RunDeleteMatchCmd(cfg, pattern, prompt); err != nil {
+				return err
+			}
+
+			return CheckForUpdate(cfg)
+		}
+
 		// check if a database name was provided
 		if !cCtx.Args().Present() {
 			return ErrMissingDatabaseName
@@ -60,11 +95,6 @@ var DeleteCommand = &cli.Command{
 			return err
 		}
 
-		prompt := true
-		if cCtx.Bool("non-interactive") {
-			prompt = false
-		}
-
 		// load config file
 		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
 		if err != nil {
@@ -155,6 +185,90 @@ func RunDeleteCmd(cfg *config.Config, entry string, trimmedName string, ask bool
 	return nil
 }
 
+// RunDeleteMatchCmd deletes every non-reserved dataset whose name matches the glob pattern
+func RunDeleteMatchCmd(cfg *config.Config, pattern string, ask bool) error {
+	// connect to server
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	names, err := server.ListDatabaseNames()
+	if err != nil {
+		return err
+	}
+
+	matches, err := MatchDatabaseNames(names, pattern)
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("Found no matching datasets to delete.")
+		return nil
+	}
+
+	// list the datasets so that the user knows exactly what is being confirmed
+	fmt.Printf("Deleting %d datasets matching: %s\n", len(matches), pattern)
+	for _, name := range matches {
+		fmt.Printf("\t%s\n", name)
+	}
+
+	if ask {
+		prompt := promptui.Prompt{
+			Label:     "Delete Datasets",
+			IsConfirm: true,
+		}
+		if _, err := prompt.Run(); err != nil {
+			fmt.Println("Cancelling deletion...")
+			return err
+		}
+	}
+
+	for i, name := range matches {
+		if err := server.DeleteSensorDB(name); err != nil {
+			fmt.Println("Deleted", i, "datasets before failing")
+			return err
+		}
+	}
+
+	fmt.Println("Successfully deleted", len(matches), "datasets")
+	return nil
+}
+
+// ValidateMatchPattern checks that the pattern is a well-formed glob
+func ValidateMatchPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("%w: pattern cannot be empty", ErrInvalidMatchPattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMatchPattern, err)
+	}
+	return nil
+}
+
+// MatchDatabaseNames returns the sorted names that match the glob pattern, skipping any
+// that are not valid dataset names so that reserved and system databases are never matched
+func MatchDatabaseNames(names []string, pattern string) ([]string, error) {
+	if err := ValidateMatchPattern(pattern); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, name := range names {
+		if ValidateDatabaseName(name) != nil {
+			continue
+		}
+		// the pattern was validated above, so Match cannot fail here
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	slices.Sort(matches)
+
+	return matches, nil
+}
+
 // TrimWildcards removes leading and trailing wildcards from a database name
 func TrimWildcards(dbName string) (string, error) {
 	// regex to remove leading and trailing wildcards
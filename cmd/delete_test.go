@@ -239,6 +239,40 @@ func TestTrimWildcards(t *testing.T) {
 	}
 }
 
+func TestMatchDatabaseNames(t *testing.T) {
+	names := []string{"default", "system", "information_schema", "INFORMATION_SCHEMA", "metadatabase", "sensor_b", "sensor_a", "other", "sensor-c"}
+
+	tests := []struct {
+		name          string
+		pattern       string
+		want          []string
+		expectedError error
+	}{
+		{"Prefix Glob", "sensor_*", []string{"sensor_a", "sensor_b"}, nil},
+		{"Single Character Glob", "sensor_?", []string{"sensor_a", "sensor_b"}, nil},
+		{"Character Class", "sensor_[a]", []string{"sensor_a"}, nil},
+		{"Exact Name", "other", []string{"other"}, nil},
+		{"Match Everything Skips Reserved", "*", []string{"other", "sensor_a", "sensor_b"}, nil},
+		{"Reserved Name", "metadatabase", nil, nil},
+		{"Uppercase Reserved Name", "INFORMATION_*", nil, nil},
+		{"No Matches", "nothing*", nil, nil},
+		{"Empty Pattern", "", nil, cmd.ErrInvalidMatchPattern},
+		{"Bad Pattern", "sensor_[", nil, cmd.ErrInvalidMatchPattern},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches, err := cmd.MatchDatabaseNames(names, test.pattern)
+			if test.expectedError != nil {
+				require.ErrorIs(t, err, test.expectedError, "error should match expected value")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, matches, "matched names should match expected value")
+		})
+	}
+}
+
 //lint:ignore U1000 // will be used in the future
 func validateCommandsExist(t *testing.T, commands []*cli.Command, expected []string) {
 	t.Helper()
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrDatabaseNotAnalyzed = errors.New("database has not been analyzed")
+var ErrInvalidDiffThreshold = errors.New("threshold must be between 0 and 1")
+var ErrDiffSameDatabase = errors.New("base and compare databases must be different")
+
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+var DiffCommand = &cli.Command{
+	Name:        "diff",
+	Usage:       "compare the threat mixtapes of two datasets",
+	UsageText:   "diff --base <dataset name> --compare <dataset name>",
+	Description: "reports entries present in only one dataset and entries whose score changed by more than the threshold",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "base",
+			Aliases:  []string{"b"},
+			Usage:    "dataset to compare against",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "compare",
+			Usage:    "dataset to compare with the base dataset",
+			Required: true,
+		},
+		&cli.Float64Flag{
+			Name:     "threshold",
+			Aliases:  []string{"t"},
+			Usage:    "minimum change in final score (0-1) for an entry to be reported as changed",
+			Value:    0.05,
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "json",
+			Usage:    "output differences as JSON",
+			Required: false,
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		base := cCtx.String("base")
+		compare := cCtx.String("compare")
+
+		// validate the database names
+		if err := ValidateDatabaseName(base); err != nil {
+			return err
+		}
+		if err := ValidateDatabaseName(compare); err != nil {
+			return err
+		}
+		if base == compare {
+			return ErrDiffSameDatabase
+		}
+
+		// validate the threshold
+		threshold := cCtx.Float64("threshold")
+		if threshold < 0 || threshold > 1 {
+			return ErrInvalidDiffThreshold
+		}
+
+		// set up file system interface
+		afs := afero.NewOsFs()
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the diff command
+		if err := runDiffCmd(cfg, base, compare, float32(threshold), cCtx.Bool("json")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+// MixtapeDiff describes a single difference between two threat mixtapes
+type MixtapeDiff struct {
+	Src          string  `json:"src"`
+	Dst          string  `json:"dst"`
+	FQDN         string  `json:"fqdn"`
+	Change       string  `json:"change"`
+	BaseScore    float32 `json:"base_score"`
+	CompareScore float32 `json:"compare_score"`
+}
+
+func runDiffCmd(cfg *config.Config, base, compare string, threshold float32, asJSON bool) error {
+	baseItems, err := getMixtapeForDiff(cfg, base)
+	if err != nil {
+		return err
+	}
+
+	compareItems, err := getMixtapeForDiff(cfg, compare)
+	if err != nil {
+		return err
+	}
+
+	diffs := DiffMixtapes(baseItems, compareItems, threshold)
+
+	if asJSON {
+		out, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if len(diffs) == 0 {
+		fmt.Println("No differences found.")
+		return nil
+	}
+
+	fmt.Println(FormatDiffTable(diffs))
+	return nil
+}
+
+// getMixtapeForDiff returns all of the current threat mixtape results for the specified dataset
+func getMixtapeForDiff(cfg *config.Config, dbName string) ([]*viewer.Item, error) {
+	// connect to database
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure the dataset has finished at least one import
+	analyzed, err := db.HasFinishedImport()
+	if err != nil {
+		return nil, err
+	}
+	if !analyzed {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotAnalyzed, dbName)
+	}
+
+	minTimestamp, _, _, _, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		return nil, err
+	}
+
+	results, _, err := viewer.GetResults(db, nil, 0, math.MaxInt32, minTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*viewer.Item, 0, len(results))
+	for _, result := range results {
+		if item, ok := result.(*viewer.Item); ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// DiffMixtapes compares two sets of mixtape results on (src, dst, fqdn) and returns the entries that
+// only exist in one set, as well as the entries whose final score changed by more than the threshold
+func DiffMixtapes(base, compare []*viewer.Item, threshold float32) []MixtapeDiff {
+	type key struct{ src, dst, fqdn string }
+
+	baseMap := make(map[key]*viewer.Item, len(base))
+	for _, item := range base {
+		baseMap[key{item.Src.String(), item.Dst.String(), item.FQDN}] = item
+	}
+
+	var diffs []MixtapeDiff
+	seen := make(map[key]bool, len(compare))
+
+	for _, item := range compare {
+		k := key{item.Src.String(), item.Dst.String(), item.FQDN}
+		seen[k] = true
+
+		baseItem, ok := baseMap[k]
+		if !ok {
+			diffs = append(diffs, newMixtapeDiff(item, DiffAdded, 0, item.FinalScore))
+			continue
+		}
+
+		if float32(math.Abs(float64(item.FinalScore-baseItem.FinalScore))) > threshold {
+			diffs = append(diffs, newMixtapeDiff(item, DiffChanged, baseItem.FinalScore, item.FinalScore))
+		}
+	}
+
+	for _, item := range base {
+		if !seen[key{item.Src.String(), item.Dst.String(), item.FQDN}] {
+			diffs = append(diffs, newMixtapeDiff(item, DiffRemoved, item.FinalScore, 0))
+		}
+	}
+
+	// sort by largest score change first
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return math.Abs(float64(diffs[i].CompareScore-diffs[i].BaseScore)) > math.Abs(float64(diffs[j].CompareScore-diffs[j].BaseScore))
+	})
+
+	return diffs
+}
+
+func newMixtapeDiff(item *viewer.Item, change string, baseScore, compareScore float32) MixtapeDiff {
+	return MixtapeDiff{
+		Src:          item.GetSrc(),
+		Dst:          item.GetDst(),
+		FQDN:         item.FQDN,
+		Change:       change,
+		BaseScore:    baseScore,
+		CompareScore: compareScore,
+	}
+}
+
+func FormatDiffTable(diffs []MixtapeDiff) *table.Table {
+	var data [][]string
+
+	for _, d := range diffs {
+		baseScore, compareScore := fmt.Sprintf("%1.2f%%", d.BaseScore*100), fmt.Sprintf("%1.2f%%", d.CompareScore*100)
+		switch d.Change {
+		case DiffAdded:
+			baseScore = "-"
+		case DiffRemoved:
+			compareScore = "-"
+		}
+		data = append(data, []string{d.Change, d.Src, d.Dst, baseScore, compareScore})
+	}
+
+	re := lipgloss.NewRenderer(os.Stdout)
+	baseStyle := re.NewStyle().Padding(0, 1)
+	headerStyle := baseStyle.Foreground(lipgloss.Color("252")).Bold(true)
+
+	headers := []string{"Change", "Source", "Destination", "Base Score", "Compare Score"}
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(re.NewStyle().Foreground(lipgloss.Color("238"))).
+		Headers(headers...).
+		Rows(data...).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+
+			even := row%2 == 0
+
+			if even {
+				return baseStyle.Foreground(lipgloss.Color("245"))
+			}
+			return baseStyle.Foreground(lipgloss.Color("252"))
+		})
+	return t
+}
@@ -0,0 +1,48 @@
+package cmd_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffMixtapes(t *testing.T) {
+	base := []*viewer.Item{
+		{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("1.1.1.1"), FinalScore: 0.5},
+		{Src: net.ParseIP("10.0.0.2"), Dst: net.ParseIP("2.2.2.2"), FinalScore: 0.6},
+		{Src: net.ParseIP("10.0.0.3"), Dst: net.ParseIP("3.3.3.3"), FinalScore: 0.7},
+		{Src: net.ParseIP("::"), Dst: net.ParseIP("::"), FQDN: "example.com", FinalScore: 0.4},
+	}
+
+	compare := []*viewer.Item{
+		// unchanged within the threshold
+		{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("1.1.1.1"), FinalScore: 0.52},
+		// changed beyond the threshold
+		{Src: net.ParseIP("10.0.0.2"), Dst: net.ParseIP("2.2.2.2"), FinalScore: 0.9},
+		// only in compare
+		{Src: net.ParseIP("10.0.0.4"), Dst: net.ParseIP("4.4.4.4"), FinalScore: 0.1},
+		{Src: net.ParseIP("::"), Dst: net.ParseIP("::"), FQDN: "example.com", FinalScore: 0.4},
+	}
+
+	diffs := cmd.DiffMixtapes(base, compare, 0.05)
+	require.Len(t, diffs, 3, "diff should contain one changed, one added, and one removed entry")
+
+	// diffs are sorted by the size of the score change
+	require.Equal(t, cmd.MixtapeDiff{Src: "10.0.0.3", Dst: "3.3.3.3", Change: cmd.DiffRemoved, BaseScore: 0.7, CompareScore: 0}, diffs[0])
+	require.Equal(t, cmd.DiffChanged, diffs[1].Change)
+	require.Equal(t, "10.0.0.2", diffs[1].Src)
+	require.InDelta(t, 0.6, diffs[1].BaseScore, 0.0001)
+	require.InDelta(t, 0.9, diffs[1].CompareScore, 0.0001)
+	require.Equal(t, cmd.MixtapeDiff{Src: "10.0.0.4", Dst: "4.4.4.4", Change: cmd.DiffAdded, BaseScore: 0, CompareScore: 0.1}, diffs[2])
+
+	// a threshold of 0 reports any change in score
+	diffs = cmd.DiffMixtapes(base, compare, 0)
+	require.Len(t, diffs, 4)
+
+	// identical mixtapes have no differences
+	require.Empty(t, cmd.DiffMixtapes(base, base, 0))
+}
@@ -0,0 +1,107 @@
+package cmd_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportSink(t *testing.T) {
+	tests := []struct {
+		sink           string
+		expectedDriver string
+		expectedDSN    string
+		expectedErr    error
+	}{
+		{sink: "postgres://rita:secret@db:5432/bi", expectedDriver: "pgx", expectedDSN: "postgres://rita:secret@db:5432/bi"},
+		{sink: "postgresql://db/bi?sslmode=disable", expectedDriver: "pgx", expectedDSN: "postgresql://db/bi?sslmode=disable"},
+		{sink: "sqlite://rita.db", expectedDriver: "sqlite", expectedDSN: "rita.db"},
+		{sink: " sqlite:///var/lib/rita.db ", expectedDriver: "sqlite", expectedDSN: "/var/lib/rita.db"},
+		{sink: "sqlite://", expectedErr: cmd.ErrInvalidExportSink},
+		{sink: "mysql://db/bi", expectedErr: cmd.ErrInvalidExportSink},
+		{sink: "", expectedErr: cmd.ErrInvalidExportSink},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sink, func(t *testing.T) {
+			driver, dsn, err := cmd.ParseExportSink(test.sink)
+			require.ErrorIs(t, err, test.expectedErr)
+			require.Equal(t, test.expectedDriver, driver)
+			require.Equal(t, test.expectedDSN, dsn)
+		})
+	}
+}
+
+func TestConnectExportSinkUnreachable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// nothing listens on port 1, so the sink should be reported as unreachable before anything is written
+	_, err := cmd.ConnectExportSink(ctx, "postgres://rita@127.0.0.1:1/bi?connect_timeout=1")
+	require.ErrorContains(t, err, "could not connect to export sink")
+}
+
+func TestExportSinkWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rita.db")
+	ctx := context.Background()
+
+	sink, err := cmd.ConnectExportSink(ctx, "sqlite://"+path)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	firstSeen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	beacon := viewer.FlatResult{
+		Hash: "0123456789ABCDEF0123456789ABCDEF", ImportID: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+		Severity: "High", FinalScore: 0.75, Src: "10.55.100.111", Dst: "203.0.113.7", BeaconScore: 0.75,
+		FirstSeen: firstSeen, LastSeen: firstSeen.Add(23 * time.Hour), Count: 2574, TotalBytes: 1 << 20,
+		PortProtoService: "443:tcp:ssl,80:tcp:http", Modifiers: "rare_signature:curl/8.0", ASN: 64500,
+	}
+	strobe := viewer.FlatResult{
+		Hash: "FEDCBA9876543210FEDCBA9876543210", ImportID: beacon.ImportID,
+		Severity: "Critical", FinalScore: 0.9, Src: "10.55.100.112", FQDN: "example.com", Strobe: true, Count: 86400,
+	}
+
+	// the table is created the first time results are written
+	require.NoError(t, sink.Write(ctx, "mydataset", []viewer.FlatResult{beacon, strobe}))
+
+	// writing the same results again replaces them, a later import of the same result is added as another row
+	beacon.FinalScore, beacon.Severity = 0.85, "Critical"
+	reimported := beacon
+	reimported.ImportID = "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB"
+	require.NoError(t, sink.Write(ctx, "mydataset", []viewer.FlatResult{beacon, reimported}))
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM "+cmd.ExportSinkTable).Scan(&count))
+	require.Equal(t, 3, count, "results should be upserted by hash and import id")
+
+	var severity, dataset, portProtoService string
+	var finalScore float64
+	var connCount, asn int64
+	var strobed bool
+	require.NoError(t, db.QueryRow(
+		"SELECT severity, dataset, final_score, connection_count, asn, port_proto_service, strobe FROM "+cmd.ExportSinkTable+" WHERE hash = ? AND import_id = ?",
+		beacon.Hash, beacon.ImportID,
+	).Scan(&severity, &dataset, &finalScore, &connCount, &asn, &portProtoService, &strobed))
+	require.Equal(t, "Critical", severity, "the upserted row should have the newer values")
+	require.Equal(t, "mydataset", dataset)
+	require.InDelta(t, 0.85, finalScore, 0.0001)
+	require.EqualValues(t, 2574, connCount)
+	require.EqualValues(t, 64500, asn)
+	require.Equal(t, "443:tcp:ssl,80:tcp:http", portProtoService)
+	require.False(t, strobed)
+
+	var firstSeenStored time.Time
+	require.NoError(t, db.QueryRow("SELECT first_seen FROM "+cmd.ExportSinkTable+" WHERE hash = ? AND import_id = ?", beacon.Hash, beacon.ImportID).Scan(&firstSeenStored))
+	require.True(t, firstSeen.Equal(firstSeenStored), "first seen should be stored as a timestamp")
+}
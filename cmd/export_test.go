@@ -0,0 +1,88 @@
+package cmd_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExportTable(t *testing.T) {
+	require.NoError(t, cmd.ValidateExportTable("histogram"))
+	require.ErrorIs(t, cmd.ValidateExportTable("big_ol_histogram"), cmd.ErrInvalidExportTable)
+	require.ErrorIs(t, cmd.ValidateExportTable(""), cmd.ErrInvalidExportTable)
+}
+
+func TestValidateHash(t *testing.T) {
+	require.NoError(t, cmd.ValidateHash("0123456789ABCDEF0123456789abcdef"))
+	require.ErrorIs(t, cmd.ValidateHash("0123456789ABCDEF"), cmd.ErrInvalidHash, "short hashes should be rejected")
+	require.ErrorIs(t, cmd.ValidateHash("0123456789ABCDEF0123456789ABCDEZ"), cmd.ErrInvalidHash, "non-hex hashes should be rejected")
+	require.ErrorIs(t, cmd.ValidateHash(""), cmd.ErrInvalidHash)
+}
+
+func TestValidateHistogramFilter(t *testing.T) {
+	hash := "0123456789ABCDEF0123456789ABCDEF"
+	src := net.ParseIP("10.55.100.111")
+	dst := net.ParseIP("203.0.113.7")
+
+	require.NoError(t, cmd.ValidateHistogramFilter(database.HistogramFilter{Hash: hash}))
+	require.NoError(t, cmd.ValidateHistogramFilter(database.HistogramFilter{Src: src}))
+	require.NoError(t, cmd.ValidateHistogramFilter(database.HistogramFilter{Dst: dst}))
+	require.NoError(t, cmd.ValidateHistogramFilter(database.HistogramFilter{Src: src, Dst: dst}))
+
+	require.ErrorIs(t, cmd.ValidateHistogramFilter(database.HistogramFilter{}), cmd.ErrMissingExportFilter)
+	require.ErrorIs(t, cmd.ValidateHistogramFilter(database.HistogramFilter{Hash: hash, Src: src}), cmd.ErrConflictingExportFilter)
+}
+
+func TestValidateSessionsFilter(t *testing.T) {
+	hash := "0123456789ABCDEF0123456789ABCDEF"
+	src := net.ParseIP("10.55.100.111")
+	dst := net.ParseIP("203.0.113.7")
+
+	require.NoError(t, cmd.ValidateExportTable("sessions"))
+	require.NoError(t, cmd.ValidateSessionsFilter(database.HistogramFilter{Src: src, Dst: dst}))
+
+	require.ErrorIs(t, cmd.ValidateSessionsFilter(database.HistogramFilter{}), cmd.ErrMissingSessionsFilter)
+	require.ErrorIs(t, cmd.ValidateSessionsFilter(database.HistogramFilter{Src: src}), cmd.ErrMissingSessionsFilter)
+	require.ErrorIs(t, cmd.ValidateSessionsFilter(database.HistogramFilter{Dst: dst}), cmd.ErrMissingSessionsFilter)
+	require.ErrorIs(t, cmd.ValidateSessionsFilter(database.HistogramFilter{Hash: hash, Src: src, Dst: dst}), cmd.ErrSessionsHashFilter)
+}
+
+func TestNewSessionsExport(t *testing.T) {
+	src := net.ParseIP("10.55.100.111")
+	dst := net.ParseIP("203.0.113.7")
+
+	// one sustained session and three short ones
+	export := cmd.NewSessionsExport(src, dst, database.NewSessionBreakdown([]float64{30, 30, 30, 5 * 3600}))
+	require.Equal(t, cmd.SessionsExport{
+		Src:                 "10.55.100.111",
+		Dst:                 "203.0.113.7",
+		Sessions:            4,
+		LongestSession:      5 * 3600,
+		TotalDuration:       5*3600 + 90,
+		LongestSessionRatio: 5 * 3600 / (5*3600 + 90.0),
+		Histogram: []database.SessionDurationBucket{
+			{Label: "<1m", Count: 3},
+			{Label: "1m-10m", Count: 0},
+			{Label: "10m-1h", Count: 0},
+			{Label: "1h-4h", Count: 0},
+			{Label: "4h-12h", Count: 1},
+			{Label: ">=12h", Count: 0},
+		},
+	}, export)
+}
+
+func TestValidateExportSink(t *testing.T) {
+	require.NoError(t, cmd.ValidateExportTable("threat_mixtape"))
+
+	require.NoError(t, cmd.ValidateExportSink("threat_mixtape", "sqlite://rita.db", false))
+	require.NoError(t, cmd.ValidateExportSink("histogram", "", true))
+	require.NoError(t, cmd.ValidateExportSink("sessions", "", true))
+
+	require.ErrorIs(t, cmd.ValidateExportSink("threat_mixtape", "", false), cmd.ErrMissingExportSink)
+	require.ErrorIs(t, cmd.ValidateExportSink("histogram", "sqlite://rita.db", true), cmd.ErrExportSinkTable)
+	require.ErrorIs(t, cmd.ValidateExportSink("threat_mixtape", "sqlite://rita.db", true), cmd.ErrExportSinkFilter)
+}
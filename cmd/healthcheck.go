@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var HealthCheckCommand = &cli.Command{
+	Name:        "healthcheck",
+	Usage:       "check that the ClickHouse server is reachable and its datasets are usable",
+	UsageText:   "rita healthcheck [--timeout SECONDS]",
+	Description: "connects to the ClickHouse server and verifies the metadatabase and dataset schema versions, exiting non-zero if any check fails",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "timeout",
+			Usage: "give up on the server after `SECONDS`",
+			Value: 10,
+			Action: func(_ *cli.Context, timeout int) error {
+				if timeout < 1 {
+					return fmt.Errorf("timeout must be at least 1 second, got %d", timeout)
+				}
+				return nil
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// this command is meant for readiness probes, so it doesn't check for updates
+		return runHealthCheckCmd(cfg, time.Duration(cCtx.Int("timeout"))*time.Second)
+	},
+}
+
+func runHealthCheckCmd(cfg *config.Config, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	health, err := database.CheckHealth(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ClickHouse server at %s is healthy: latency %s, %d dataset(s) at schema version %d\n",
+		health.Addr, health.Latency.Round(time.Microsecond), health.Datasets, database.SchemaVersion)
+	return nil
+}
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -30,6 +32,40 @@ var (
 	numParsers   = 8 // largest impact
 	numDigesters = 8
 	numWriters   = 12 // 2nd largest impact
+
+	// beaconLookback limits beacon scoring to this amount of time before the newest beacon timestamp, 0 uses the full window
+	beaconLookback time.Duration
+
+	// importFiles is an explicit list of log files that are imported instead of walking the log directory
+	importFiles []string
+
+	// excludePatterns are glob patterns of log paths, relative to the log directory, that are skipped during the walk
+	excludePatterns []string
+
+	// hostFilter limits a reanalysis to the connections of these hosts, every connection is analyzed when it is empty.
+	// Imports refuse it, since the import would be finished with the results of only some of its connections.
+	hostFilter analysis.HostFilter
+
+	// refreshFeeds downloads every online threat intel feed in full instead of only the feeds that have changed
+	refreshFeeds bool
+
+	// skippedFiles are the files that were left out before the walk, such as tarball entries over max_log_file_bytes,
+	// which are counted along with the walk errors
+	skippedFiles []WalkError
+
+	// failOnWalkErrors stops the import before anything is imported if any file was left out during the walk
+	failOnWalkErrors bool
+
+	// verboseWalk logs how the walk classified each file that it found, and why any file was left out
+	verboseWalk bool
+
+	// showProgress reports the percentage of files parsed, the parsing rate, and the time left, see newImportProgress
+	showProgress bool
+
+	// deterministic imports files one at a time, in the order they were walked, with a single parser and writer for
+	// each log type, and analyzes them on a single thread, so that repeated imports of the same logs insert the same
+	// rows in the same order
+	deterministic bool
 )
 
 // util.Max(1, runtime.NumCPU()/2)
@@ -41,6 +77,17 @@ var ErrInvalidLogType = errors.New("incompatible log type")
 var ErrIncompatibleFileExtension = errors.New("incompatible file extension")
 var ErrSkippedDuplicateLog = errors.New("encountered file with same name but different extension, skipping file due to older last modified time")
 var ErrMissingLogDirectory = errors.New("log directory flag is required")
+var ErrInvalidImportConcurrency = errors.New("max import concurrency must be at least 0")
+var ErrInvalidBatchSize = fmt.Errorf("batch size must be between %d and %d", config.MinBatchSize, config.MaxBatchSize)
+var ErrInvalidBeaconLookback = errors.New("since must be a positive duration")
+var ErrInvalidExcludePattern = errors.New("invalid exclude pattern")
+var ErrExcludedByPattern = errors.New("file matched an exclude pattern, skipping file")
+var ErrLogFileTooLarge = errors.New("file is larger than max_log_file_bytes, skipping file")
+var ErrWalkErrors = errors.New("files were left out of the import")
+var ErrMissingConnLog = errors.New("no conn logs exist for the same hour, skipping file")
+var ErrMissingOpenConnLog = errors.New("no open conn logs exist for the same hour, skipping file")
+var ErrAnalysisTimeout = errors.New("analysis did not finish within analysis_timeout")
+var ErrHostFilterOnImport = errors.New("analysis can only be limited to some hosts when reanalyzing a dataset with reconfigure")
 
 type WalkError struct {
 	Path  string
@@ -48,10 +95,26 @@ type WalkError struct {
 }
 type HourlyZeekLogs []map[string][]string
 
+// ImportOptions are the import settings that are set by the import command's flags, for running imports from other
+// packages with SetImportOptions
+type ImportOptions struct {
+	BeaconLookback       time.Duration       // see --since
+	Files                []string            // see --files, absolute paths from ParseFileList
+	ExcludePatterns      []string            // see --exclude
+	SkippedFiles         []WalkError         // files left out of a tarball by ExtractTarballLogs
+	HostFilter           analysis.HostFilter // see reconfigure --only-src and --only-dst, imports refuse it
+	RefreshFeeds         bool                // see --refresh-feeds
+	FailOnWalkErrors     bool                // see --fail-on-walk-errors
+	VerboseWalk          bool                // see --verbose-walk
+	ShowProgress         bool                // see --quiet
+	Deterministic        bool                // see --deterministic
+	MaxImportConcurrency int                 // see --max-import-concurrency
+}
+
 var ImportCommand = &cli.Command{
 	Name:      "import",
 	Usage:     "import zeek logs into a target database",
-	UsageText: "rita import [--database NAME] [-logs DIRECTORY] [--rolling] [--rebuild]",
+	UsageText: "rita import [--database NAME] [-logs DIRECTORY|TARBALL|s3://BUCKET/PREFIX | --files FILE,... | --log-type TYPE -] [--rolling] [--rebuild] [--since DURATION] [--exclude PATTERN]... [--profile DIRECTORY] [--refresh-feeds] [--batch-size ROWS] [--fail-on-walk-errors] [--verbose-walk] [--quiet]",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:     "database",
@@ -65,12 +128,38 @@ var ImportCommand = &cli.Command{
 		&cli.StringFlag{
 			Name:     "logs",
 			Aliases:  []string{"l"},
-			Usage:    "path to log directory",
+			Usage:    "path to log directory or gzipped tarball of one, s3://bucket/prefix to read logs from object storage, or - to read logs from stdin",
 			Required: false,
 			Action: func(_ *cli.Context, path string) error {
+				if path == StdinPath {
+					return nil
+				}
+				if IsObjectStoragePath(path) {
+					return ValidateObjectStoragePath(path)
+				}
+				if IsTarballPath(path) {
+					return ValidateTarball(afero.NewOsFs(), path)
+				}
 				return ValidateLogDirectory(afero.NewOsFs(), path)
 			},
 		},
+		&cli.StringSliceFlag{
+			Name:     "files",
+			Usage:    "comma-separated list of log files to import instead of a log directory, the log type and hour of each file are taken from its name",
+			Required: false,
+			Action: func(_ *cli.Context, files []string) error {
+				_, err := ParseFileList(files)
+				return err
+			},
+		},
+		&cli.StringFlag{
+			Name:     "log-type",
+			Usage:    "type of the logs read from stdin, ex: conn",
+			Required: false,
+			Action: func(_ *cli.Context, logType string) error {
+				return ValidateStdinLogType(logType)
+			},
+		},
 		&cli.BoolFlag{
 			Name:     "rolling",
 			Aliases:  []string{"r"},
@@ -85,31 +174,183 @@ var ImportCommand = &cli.Command{
 			Value:    false,
 			Required: false,
 		},
+		&cli.IntFlag{
+			Name:     "max-import-concurrency",
+			Usage:    "maximum number of log files to parse at the same time, overrides max_import_concurrency in the config (0 uses the config value)",
+			Value:    0,
+			Required: false,
+			Action: func(_ *cli.Context, limit int) error {
+				if limit < 0 {
+					return ErrInvalidImportConcurrency
+				}
+				return nil
+			},
+		},
+		&cli.DurationFlag{
+			Name:     "since",
+			Usage:    "only score beacons over this much time before the newest connection, ex: 12h (defaults to the full beacon window)",
+			Value:    0,
+			Required: false,
+			Action: func(_ *cli.Context, since time.Duration) error {
+				if since <= 0 {
+					return ErrInvalidBeaconLookback
+				}
+				return nil
+			},
+		},
+		&cli.StringSliceFlag{
+			Name:     "exclude",
+			Usage:    "skip logs whose path relative to the log directory matches this glob pattern, ex: 2024-01-01/dns.*, can be repeated",
+			Required: false,
+			Action: func(_ *cli.Context, patterns []string) error {
+				return ValidateExcludePatterns(patterns)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "profile",
+			Usage:    "write a CPU profile of the import and a heap profile at its end to this directory, for use with go tool pprof",
+			Required: false,
+			Action: func(_ *cli.Context, dir string) error {
+				return ValidateProfileDirectory(afero.NewOsFs(), dir)
+			},
+		},
+		&cli.BoolFlag{
+			Name:     "refresh-feeds",
+			Usage:    "download every online threat intel feed in full, even if the cached copy is up to date",
+			Value:    false,
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "fail-on-walk-errors",
+			Usage:    "fail without importing anything if any file would be left out of the import, files skipped by --exclude are still allowed",
+			Value:    false,
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "verbose-walk",
+			Usage:    "log the log type, hour, and day that each file found in the log directory was classified as, or why it was skipped",
+			Value:    false,
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "deterministic",
+			Usage:    "import files one at a time in a stable order so that repeated imports insert the same rows in the same order, much slower and meant for testing",
+			Value:    false,
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "quiet",
+			Aliases:  []string{"q"},
+			Usage:    "don't print the import progress or the top findings when the import finishes",
+			Value:    false,
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "batch-size",
+			Usage:    "number of rows written to the database in each batch, overrides batch_size in the config",
+			Required: false,
+			Action: func(_ *cli.Context, size int) error {
+				return ValidateBatchSize(size)
+			},
+		},
 		ConfigFlag(false),
 	},
 	Action: func(cCtx *cli.Context) error {
 		afs := afero.NewOsFs()
 
+		// logs are read from stdin if - is passed as the log directory or as the only argument
+		logDir := cCtx.String("logs")
+		if cCtx.NArg() == 1 && cCtx.Args().First() == StdinPath && !cCtx.IsSet("logs") {
+			logDir = StdinPath
+		}
+		if logDir != StdinPath && cCtx.IsSet("log-type") {
+			return ErrLogTypeWithoutStdin
+		}
+		if cCtx.IsSet("files") && logDir != "" {
+			return ErrFilesWithLogs
+		}
+
+		// the file list was validated when the flag was parsed
+		files, _ := ParseFileList(cCtx.StringSlice("files"))
+
 		// load config file
 		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
 		if err != nil {
 			return err
 		}
 
-		// set the number of workers based on the number of CPUs
-		numParsers = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
-		numDigesters = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
-		numWriters = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+		// store the logs from stdin so that they can be imported like a log directory
+		if logDir == StdinPath {
+			afs, logDir, err = ReadStdinLogs(afs, os.Stdin, cCtx.String("log-type"))
+			if err != nil {
+				return err
+			}
+		}
+
+		// extract gzipped tarballs so that the tree inside can be imported like a log directory
+		var skipped []WalkError
+		if IsTarballPath(logDir) {
+			afs, logDir, skipped, err = ExtractTarballLogs(afs, logDir, cfg.MaxLogFileBytes)
+			if err != nil {
+				return err
+			}
+		}
+
+		// mount object storage buckets so that the objects under the prefix can be imported like a log directory
+		if IsObjectStoragePath(logDir) {
+			afs, logDir, err = OpenObjectStorageLogs(cCtx.Context, afs, cfg.ObjectStorage, logDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		// override the batch size for this run, the value was validated when the flag was parsed
+		if cCtx.IsSet("batch-size") {
+			cfg.BatchSize = cCtx.Int("batch-size")
+		}
+
+		SetImportOptions(cfg, ImportOptions{
+			BeaconLookback:       cCtx.Duration("since"),
+			Files:                files,
+			ExcludePatterns:      cCtx.StringSlice("exclude"),
+			SkippedFiles:         skipped,
+			RefreshFeeds:         cCtx.Bool("refresh-feeds"),
+			FailOnWalkErrors:     cCtx.Bool("fail-on-walk-errors"),
+			VerboseWalk:          cCtx.Bool("verbose-walk"),
+			ShowProgress:         !cCtx.Bool("quiet"),
+			Deterministic:        cCtx.Bool("deterministic"),
+			MaxImportConcurrency: cCtx.Int("max-import-concurrency"),
+		})
+
+		// profile the import if requested, the profiles are written to the real file system even for stdin imports
+		stopProfiling, err := StartProfiling(afero.NewOsFs(), cCtx.String("profile"))
+		if err != nil {
+			return err
+		}
 
 		// set the import start time in microseconds
 		startTime := time.Now()
 
 		// run import command
-		_, err = RunImportCmd(startTime, cfg, afs, cCtx.String("logs"), cCtx.String("database"), cCtx.Bool("rolling"), cCtx.Bool("rebuild"))
+		results, err := RunImportCmd(startTime, cfg, afs, logDir, cCtx.String("database"), cCtx.Bool("rolling"), cCtx.Bool("rebuild"))
+
+		// write the profiles even if the import failed, since they are most useful for diagnosing a bad import
+		if profileErr := stopProfiling(); profileErr != nil {
+			logger := zlog.GetLogger()
+			logger.Warn().Err(profileErr).Str("directory", cCtx.String("profile")).Msg("could not write import profiles")
+		}
 		if err != nil {
 			return err
 		}
 
+		// print the top findings of the import unless they were turned off
+		if !cCtx.Bool("quiet") && cfg.TopFindingsLimit > 0 {
+			if err := printTopFindings(cfg, cCtx.String("database"), results.ImportID); err != nil {
+				logger := zlog.GetLogger()
+				logger.Warn().Err(err).Msg("could not print the top findings of the import")
+			}
+		}
+
 		// check for updates after running the command
 		if err := CheckForUpdate(cfg); err != nil {
 			return err
@@ -131,42 +372,146 @@ type ImportResults struct {
 	ImportTimestamps []ImportTimestamps
 }
 
+// SetImportOptions sets the options of the imports that are run after it. The options are shared by every import in
+// the process, so imports with different options must not run at the same time.
+func SetImportOptions(cfg *config.Config, opts ImportOptions) {
+	// set the number of workers based on the number of CPUs
+	numParsers = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+	numDigesters = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+	numWriters = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+
+	// cap the number of log files that are parsed at the same time
+	numDigesters = GetImportConcurrency(numDigesters, opts.MaxImportConcurrency, cfg.MaxImportConcurrency)
+
+	// parse and write everything on a single thread so that repeated imports are identical
+	deterministic = opts.Deterministic
+	if deterministic {
+		numParsers, numDigesters, numWriters = 1, 1, 1
+	}
+
+	// limit the time range used for beacon scoring
+	beaconLookback = opts.BeaconLookback
+
+	// import the listed files instead of walking the log directory
+	importFiles = opts.Files
+
+	// skip logs that match any of the exclude patterns
+	excludePatterns = opts.ExcludePatterns
+
+	// count the files that were left out before the walk
+	skippedFiles = opts.SkippedFiles
+
+	// limit analysis to the connections of the given hosts
+	hostFilter = opts.HostFilter
+
+	// ignore the cached copies of the online threat intel feeds
+	refreshFeeds = opts.RefreshFeeds
+
+	// fail the import if any file would be left out of it
+	failOnWalkErrors = opts.FailOnWalkErrors
+
+	// log how each file was classified during the walk
+	verboseWalk = opts.VerboseWalk
+
+	// report the progress of the import as files are parsed
+	showProgress = opts.ShowProgress
+}
+
 func RunImportCmd(startTime time.Time, cfg *config.Config, afs afero.Fs, logDir string, dbName string, rolling bool, rebuild bool) (ImportResults, error) {
+	return RunImportContext(context.Background(), startTime, cfg, afs, logDir, dbName, rolling, rebuild)
+}
+
+// RunImportContext runs an import like RunImportCmd, stopping before the next hour of logs is imported once ctx is done.
+// If a list of files was set with SetImportOptions, those files are imported instead of the files in logDir.
+func RunImportContext(ctx context.Context, startTime time.Time, cfg *config.Config, afs afero.Fs, logDir string, dbName string, rolling bool, rebuild bool) (ImportResults, error) {
 
 	var importResults ImportResults
 	logger := zlog.GetLogger()
 
+	// an import that only analyzed some hosts would be finished with partial results
+	if hostFilter.IsSet() {
+		return importResults, ErrHostFilterOnImport
+	}
+
 	// keep track of the cumulative elapsed time
 	importStartedAt := startTime
 
-	logger.Info().Str("directory", logDir).Bool("rolling", rolling).Bool("rebuild", rebuild).Str("dataset", dbName).Str("started_at", importStartedAt.String()).Msg("Initiating new import...")
+	logger.Info().Str("directory", logDir).Int("files", len(importFiles)).Bool("rolling", rolling).Bool("rebuild", rebuild).Bool("deterministic", deterministic).Str("dataset", dbName).Int("batch_size", cfg.BatchSize).Str("started_at", importStartedAt.String()).Msg("Initiating new import...")
 
-	// load dataset relative to the current working directory
-	// this is done here instead of in the flag parsing so that anyone calling RunImportCmd will have the relative path
-	logDir, err := util.ParseRelativePath(logDir)
+	// get list of hourly log maps of all days of log files in directory, or of the listed files
+	// the walk is done before the dataset is set up so that a strict import fails before anything is changed
+	var logMap []HourlyZeekLogs
+	var walkErrors []WalkError
+	var err error
+	switch {
+	case len(importFiles) > 0 && verboseWalk:
+		var classifications []WalkClassification
+		logMap, walkErrors, classifications, err = ClassifyWalkFileList(afs, importFiles, excludePatterns, cfg.MaxLogFileBytes)
+		logWalkClassifications(classifications)
+	case len(importFiles) > 0:
+		logMap, walkErrors, err = WalkFileList(afs, importFiles, excludePatterns, cfg.MaxLogFileBytes)
+	default:
+		// load dataset relative to the current working directory
+		// this is done here instead of in the flag parsing so that anyone calling RunImportCmd will have the relative path
+		logDir, err = util.ParseRelativePath(logDir)
+		if err != nil {
+			return importResults, err
+		}
+
+		if verboseWalk {
+			var classifications []WalkClassification
+			logMap, walkErrors, classifications, err = ClassifyWalkFiles(afs, logDir, excludePatterns, cfg.MaxLogFileBytes)
+			logWalkClassifications(classifications)
+		} else {
+			logMap, walkErrors, err = WalkFiles(afs, logDir, excludePatterns, cfg.MaxLogFileBytes)
+		}
+	}
+
+	walkErrors = append(walkErrors, skippedFiles...)
+
+	// log any errors that occurred during the walk, files that were listed by name are warned about since they were
+	// expected to be imported
+	for _, walkErr := range walkErrors {
+		event := logger.Debug()
+		if len(importFiles) > 0 {
+			event = logger.Warn()
+		}
+		event.Str("path", walkErr.Path).Err(walkErr.Error).Msg("file was left out of import due to error or incompatibility")
+	}
 	if err != nil {
 		return importResults, err
 	}
 
+	if failOnWalkErrors {
+		if err := CheckWalkErrors(walkErrors); err != nil {
+			return importResults, err
+		}
+	}
+
 	// create import database if it doesn't already exist and connect to it
-	db, err := database.SetUpNewImport(afs, cfg, dbName, rolling, rebuild)
+	db, err := database.SetUpNewImport(afs, cfg, dbName, rolling, rebuild, refreshFeeds)
 	if err != nil {
 		return importResults, err
 	}
 
-	// get list of hourly log maps of all days of log files in directory
-	logMap, walkErrors, err := WalkFiles(afs, logDir)
-	if err != nil {
-		return importResults, err
+	// remove the records of imports that never finished, since the files that they read are imported again
+	if err := db.RemoveUnfinishedImports(); err != nil {
+		return importResults, fmt.Errorf("could not remove the records of unfinished imports: %w", err)
 	}
 
-	// log any errors that occurred during the walk
-	for _, walkErr := range walkErrors {
-		logger.Debug().Str("path", walkErr.Path).Err(walkErr.Error).Msg("file was left out of import due to error or incompatibility")
+	// log records are matched to the import that wrote them by the second that it started, so this import has to
+	// start in a later second than the imports before it
+	lastStartedAt, err := db.GetLastImportStartedAt()
+	if err != nil {
+		return importResults, err
 	}
+	importStartedAt = NextImportStart(importStartedAt, lastStartedAt)
 
 	var elapsedTime int64
 
+	// report the progress of parsing every file that was walked, across all of the hours
+	progress := newImportProgress(logMap)
+
 	// loop through each day
 	for day, hourlyLogs := range logMap {
 		if len(logMap) > 1 {
@@ -177,6 +522,10 @@ func RunImportCmd(startTime time.Time, cfg *config.Config, afs afero.Fs, logDir
 
 		// loop through each hour's log files
 		for hour, files := range hourlyLogs {
+			// hours that were already imported are kept, but no more are started once the import is canceled
+			if err := ctx.Err(); err != nil {
+				return importResults, err
+			}
 
 			logger.Debug().Msg(fmt.Sprintf("------------- STARTING HOUR %v!! -------------", hour))
 			hourStart := time.Now()
@@ -210,6 +559,10 @@ func RunImportCmd(startTime time.Time, cfg *config.Config, afs afero.Fs, logDir
 				return importResults, err
 			}
 
+			// set the log directory so that the sensor of each log can be determined from its path
+			importer.LogDirectory = logDir
+			importer.Progress = progress
+
 			// import the data
 			err = importer.Import(afs, files)
 			if err != nil && !errors.Is(err, i.ErrAllFilesPreviouslyImported) {
@@ -232,66 +585,30 @@ func RunImportCmd(startTime time.Time, cfg *config.Config, afs afero.Fs, logDir
 			importResults.SSL += importer.ResultCounts.SSL
 			importResults.OpenSSL += importer.ResultCounts.OpenSSL
 			importResults.ImportID = append(importResults.ImportID, importer.ImportID)
-			logger.Debug().Msg("------------- RUNNING ANALYSIS!! -------------")
-
-			// TODO pull useCurrentTime out of beacon?
-			minTSBeacon, maxTSBeacon, _, err := db.GetBeaconMinMaxTimestamps()
-			missingBeaconTS := errors.Is(err, database.ErrInvalidMinMaxTimestamp)
-			if err != nil && !missingBeaconTS {
-				return importResults, fmt.Errorf("could not find min/max timestamps for beaconing analysis: %w", err)
-			}
-
-			minTS, maxTS, _, useCurrentTime, err := db.GetTrueMinMaxTimestamps()
-			if err != nil {
-				return importResults, fmt.Errorf("could not find imported data. Be sure to include your internal subnets in 'filter.internal_subnets' in config.hjson.\n(err: %w)", err)
-			}
-
-			importResults.ImportTimestamps = append(importResults.ImportTimestamps, ImportTimestamps{
-				MinTS:       minTS,
-				MaxTS:       maxTS,
-				MinTSBeacon: minTSBeacon,
-				maxTSBeacon: maxTSBeacon,
-			})
-
-			logger.Debug().Time("min_ts", minTS).Time("max_ts", maxTS).Time("min_beacon_ts", minTSBeacon).Time("max_beacon_ts", maxTSBeacon).Bool("skip_beaconing", missingBeaconTS).Msg("timestamps used in analysis")
-
-			// set up new analyzer
-			analyzer, err := analysis.NewAnalyzer(db, cfg, importer.ImportID, minTS, maxTS, minTSBeacon, maxTSBeacon, useCurrentTime, missingBeaconTS)
-			if err != nil {
-				return importResults, err
-			}
-
-			// analyze the data
-			err = analyzer.Analyze()
-			if err != nil {
-				return importResults, err
-			}
 
-			// set up new modifier
-			modifier, err := m.NewModifier(db, cfg, importer.ImportID, minTS)
+			// analyze the imported data
+			timestamps, err := AnalyzeImport(db, cfg, importer.ImportID)
 			if err != nil {
 				return importResults, err
 			}
+			importResults.ImportTimestamps = append(importResults.ImportTimestamps, timestamps)
 
-			// modify the data
-			err = modifier.Modify()
-			if err != nil {
-				return importResults, err
-			}
+			// tag the rest of this hour's events with the import they belong to
+			importLogger := zlog.WithImport(dbName, importer.ImportID.Hex())
 
-			// add import finished record to metadatabase
-			err = db.AddImportFinishedRecordToMetaDB(importer.ImportID, minTS, maxTS)
-			if err != nil {
-				return importResults, err
+			// save a summary of this import so that it can be reported on later
+			if err := saveImportSummary(db, importer, len(walkErrors), time.Since(hourStart)); err != nil {
+				importLogger.Warn().Err(err).Msg("could not save import summary")
 			}
 
 			// get the elapsed time for this hour
 			elapsedTime += time.Since(hourStart).Nanoseconds()
 
 			// add the duration of this hour's import to the importStartedAt time for the next import
-			importStartedAt = importStartedAt.Add(time.Duration(elapsedTime) * time.Nanosecond)
+			importStartedAt = NextImportStart(importStartedAt.Add(time.Duration(elapsedTime)*time.Nanosecond), importStartedAt)
 
-			logger.Info().Str("elapsed_time", time.Since(hourStart).String()).Int("day", day).Int("hour", hour).Msg("Finished Importing Hour Chunk")
+			hourElapsed := time.Since(hourStart)
+			importLogger.Info().Str("phase", "import").Dur("duration", hourElapsed).Str("elapsed_time", hourElapsed.String()).Int("day", day).Int("hour", hour).Msg("Finished Importing Hour Chunk")
 
 		}
 
@@ -310,6 +627,176 @@ func RunImportCmd(startTime time.Time, cfg *config.Config, afs afero.Fs, logDir
 	return importResults, nil
 }
 
+// NextImportStart returns start if it is in a later second than previous, or else the start of the second after previous
+func NextImportStart(start time.Time, previous time.Time) time.Time {
+	next := previous.Truncate(time.Second).Add(time.Second)
+	if start.Before(next) {
+		return next
+	}
+	return start
+}
+
+// AnalyzeImport runs analysis and modifiers on the data from the given import and marks the import as finished
+func AnalyzeImport(db *database.DB, cfg *config.Config, importID util.FixedString) (ImportTimestamps, error) {
+	logger := zlog.WithImport(db.GetSelectedDB(), importID.Hex())
+	logger.Debug().Msg("------------- RUNNING ANALYSIS!! -------------")
+
+	// use the beacon score thresholds set for this dataset instead of the config file's, if there are any
+	cfg, err := applyScoreThresholds(db, cfg)
+	if err != nil {
+		return ImportTimestamps{}, err
+	}
+
+	// TODO pull useCurrentTime out of beacon?
+	minTSBeacon, maxTSBeacon, _, err := db.GetBeaconMinMaxTimestamps()
+	missingBeaconTS := errors.Is(err, database.ErrInvalidMinMaxTimestamp)
+	if err != nil && !missingBeaconTS {
+		return ImportTimestamps{}, fmt.Errorf("could not find min/max timestamps for beaconing analysis: %w", err)
+	}
+
+	// only score beacons over the requested lookback window
+	if !missingBeaconTS {
+		windowStart := GetBeaconLookbackStart(minTSBeacon, maxTSBeacon, beaconLookback)
+		if beaconLookback > 0 && windowStart.Equal(minTSBeacon) {
+			logger.Debug().Str("since", beaconLookback.String()).Time("min_beacon_ts", minTSBeacon).Msg("beacon lookback is longer than the dataset, using the full beacon window")
+		}
+		minTSBeacon = windowStart
+	}
+
+	minTS, maxTS, _, _, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		return ImportTimestamps{}, fmt.Errorf("could not find imported data. Be sure to include your internal subnets in 'filter.internal_subnets' in config.hjson.\n(err: %w)", err)
+	}
+
+	timestamps := ImportTimestamps{
+		MinTS:       minTS,
+		MaxTS:       maxTS,
+		MinTSBeacon: minTSBeacon,
+		maxTSBeacon: maxTSBeacon,
+	}
+
+	logger.Debug().Time("min_ts", minTS).Time("max_ts", maxTS).Time("min_beacon_ts", minTSBeacon).Time("max_beacon_ts", maxTSBeacon).Bool("skip_beaconing", missingBeaconTS).Msg("timestamps used in analysis")
+
+	// bound the analysis queries by the analysis timeout, if there is one
+	// results are still written with the original connection so that a timeout doesn't abort a batch mid-write
+	queryDB := db
+	if cfg.AnalysisTimeout > 0 {
+		ctx, cancel := context.WithTimeout(db.GetContext(), time.Duration(cfg.AnalysisTimeout)*time.Second)
+		defer cancel()
+		queryDB = db.WithContext(ctx)
+	}
+
+	// set up new analyzer
+	analyzer, err := analysis.NewAnalyzer(db, cfg, importID, minTS, maxTS, minTSBeacon, maxTSBeacon, missingBeaconTS, deterministic)
+	if err != nil {
+		return timestamps, err
+	}
+	analyzer.Database = queryDB
+	analyzer.HostFilter = hostFilter
+	if hostFilter.IsSet() {
+		logger.Info().Int("src_hosts", len(hostFilter.Src)).Int("dst_hosts", len(hostFilter.Dst)).Msg("limiting analysis to the connections of the given hosts")
+	}
+
+	// analyze the data
+	err = analyzer.Analyze()
+	if err != nil {
+		return timestamps, AnalysisPhaseError(queryDB, cfg, "analysis", err)
+	}
+
+	// set up new modifier
+	modifier, err := m.NewRunner(db, cfg, importID, minTS, deterministic)
+	if err != nil {
+		return timestamps, err
+	}
+	modifier.Database = queryDB
+
+	// modify the data
+	err = modifier.Modify()
+	if err != nil {
+		return timestamps, AnalysisPhaseError(queryDB, cfg, "modifiers", err)
+	}
+
+	// add import finished record to metadatabase
+	err = db.AddImportFinishedRecordToMetaDB(importID, minTS, maxTS)
+	if err != nil {
+		return timestamps, err
+	}
+
+	return timestamps, nil
+}
+
+// AnalysisPhaseError reports which phase was running when the analysis timeout was reached,
+// other errors are returned as is
+func AnalysisPhaseError(db *database.DB, cfg *config.Config, phase string, err error) error {
+	if !errors.Is(db.GetContext().Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: the %s phase was still running after %d seconds, the import was not marked as finished and its files will be imported again on the next run: %w",
+		ErrAnalysisTimeout, phase, cfg.AnalysisTimeout, err)
+}
+
+// saveImportSummary records the files, record counts, errors, and top beacons of an import in the metadatabase
+func saveImportSummary(db *database.DB, importer *i.Importer, numWalkErrors int, elapsedTime time.Duration) error {
+	topBeacons, err := db.GetTopBeacons(importer.ImportID, database.TopBeaconLimit)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]uint64)
+	for logType, paths := range importer.FileMap {
+		if len(paths) > 0 {
+			files[logType] = uint64(len(paths))
+		}
+	}
+
+	return db.AddImportSummaryToMetaDB(database.ImportSummary{
+		ImportID:    importer.ImportID,
+		Database:    db.GetSelectedDB(),
+		CreatedAt:   time.Now(),
+		ElapsedTime: elapsedTime,
+		Files:       files,
+		Records:     importer.ResultCounts.Records(),
+		WalkErrors:  uint64(numWalkErrors),
+		ParseErrors: importer.ResultCounts.ParseErrors,
+		TopBeacons:  topBeacons,
+	})
+}
+
+// GetBeaconLookbackStart returns the start of the time range used for beacon scoring. The range ends at maxTS and starts
+// lookback before it, but never before minTS. A lookback of 0 uses the full range.
+func GetBeaconLookbackStart(minTS time.Time, maxTS time.Time, lookback time.Duration) time.Time {
+	if lookback <= 0 {
+		return minTS
+	}
+
+	start := maxTS.Add(-lookback)
+	if start.Before(minTS) {
+		return minTS
+	}
+	return start
+}
+
+// GetImportConcurrency returns the number of log files to parse at the same time, preferring the flag value
+// over the config value, and falling back to the default if neither is set
+func GetImportConcurrency(defaultLimit int, flagLimit int, configLimit int) int {
+	switch {
+	case flagLimit > 0:
+		return flagLimit
+	case configLimit > 0:
+		return configLimit
+	default:
+		return defaultLimit
+	}
+}
+
+// ValidateBatchSize returns an error if the batch size is outside of the range allowed by the config
+func ValidateBatchSize(size int) error {
+	if size < config.MinBatchSize || size > config.MaxBatchSize {
+		return fmt.Errorf("%w, got %d", ErrInvalidBatchSize, size)
+	}
+	return nil
+}
+
 func ValidateLogDirectory(afs afero.Fs, logDir string) error {
 	if logDir == "" {
 		return ErrMissingLogDirectory
@@ -339,7 +826,7 @@ func ValidateDatabaseName(name string) error {
 	switch {
 	case len(name) > 63:
 		return fmt.Errorf("\n\t[!] database name cannot exceed 63 characters: %v", name)
-	case name == "default" || name == "system" || name == "information_schema" || name == "metadatabase":
+	case database.IsReservedDatabaseName(name):
 		return fmt.Errorf("\n\t[!] database name cannot be reserved word %v", name)
 	case unicode.IsUpper(rune(name[0])):
 		return fmt.Errorf("\n\t[!] database name must start with a lowercase letter %v", name)
@@ -370,20 +857,114 @@ func ParseFolderDate(folder string) (time.Time, error) {
 	return folderDate, nil
 }
 
+// ValidateExcludePatterns checks that each exclude pattern is a valid glob pattern
+func ValidateExcludePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidExcludePattern, pattern)
+		}
+	}
+	return nil
+}
+
+// ParseHostIPs parses a list of host IPs, erroring on the first value that isn't a valid IP
+func ParseHostIPs(hosts []string) ([]net.IP, error) {
+	ips := make([]net.IP, 0, len(hosts))
+	for _, host := range hosts {
+		ip := net.ParseIP(strings.TrimSpace(host))
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHostIP, host)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// isExcludedPath returns whether the path of a log, relative to root, matches any of the exclude patterns. When root
+// is the log itself, such as a file listed with --files, there is no log directory to be relative to, so the patterns
+// are matched against each trailing part of its path instead (ie, "dns.log", "sensor2/dns.log", and so on).
+func isExcludedPath(root string, path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
+	relPath, err := filepath.Rel(root, path)
+	if err == nil && relPath != "." {
+		return matchesAnyPattern(relPath, patterns)
+	}
+
+	path = filepath.Clean(path)
+	for i := len(path) - 1; i >= 0; i-- {
+		if i == 0 || path[i-1] == filepath.Separator {
+			if matchesAnyPattern(path[i:], patterns) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesAnyPattern returns whether the path matches any of the glob patterns
+func matchesAnyPattern(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckWalkErrors returns an error listing every file that was left out of the import during the walk, or nil if none
+// were. Files that matched an exclude pattern were skipped on purpose, so they aren't counted.
+func CheckWalkErrors(walkErrors []WalkError) error {
+	var skipped []string
+	for _, walkErr := range walkErrors {
+		if errors.Is(walkErr.Error, ErrExcludedByPattern) {
+			continue
+		}
+		skipped = append(skipped, fmt.Sprintf("%s: %v", walkErr.Path, walkErr.Error))
+	}
+
+	if len(skipped) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d file(s) would be skipped:\n\t%s", ErrWalkErrors, len(skipped), strings.Join(skipped, "\n\t"))
+}
+
 // WalkFiles starts a goroutine to walk the directory tree at root and send the
 // path of each regular file on the string channel.  It sends the result of the
 // walk on the error channel.  If done is closed, WalkFiles abandons its work.
-func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error) {
+// Files whose path relative to root matches one of the exclude patterns are skipped, as are files larger than
+// maxFileBytes when it is greater than 0.
+func WalkFiles(afs afero.Fs, root string, exclude []string, maxFileBytes int64) ([]HourlyZeekLogs, []WalkError, error) {
+	logMap, walkErrors, _, err := walkFiles(afs, []string{root}, false, exclude, maxFileBytes, false)
+	return logMap, walkErrors, err
+}
+
+// ClassifyWalkFiles walks the directory tree at root like WalkFiles, and also returns how each file that was found was
+// classified, sorted by path. This is used by --verbose-walk to explain why each file was or wasn't imported.
+func ClassifyWalkFiles(afs afero.Fs, root string, exclude []string, maxFileBytes int64) ([]HourlyZeekLogs, []WalkError, []WalkClassification, error) {
+	return walkFiles(afs, []string{root}, false, exclude, maxFileBytes, true)
+}
+
+// walkFiles walks each of the roots and groups the log files that it finds by day, hour, and log type. Each root is
+// a directory or a single log file. When listed is set, the roots are an explicit list of log files, so a root that
+// can't be read is left out of the import with a walk error instead of failing the walk.
+func walkFiles(afs afero.Fs, roots []string, listed bool, exclude []string, maxFileBytes int64, classify bool) ([]HourlyZeekLogs, []WalkError, []WalkClassification, error) {
 	logger := zlog.GetLogger()
 
-	// check if root is a valid directory or file
-	err := util.ValidateDirectory(afs, root)
-	if err != nil && !errors.Is(err, util.ErrPathIsNotDir) {
-		return nil, nil, err
-	}
-	if err != nil && errors.Is(err, util.ErrPathIsNotDir) {
-		if err := util.ValidateFile(afs, root); err != nil {
-			return nil, nil, err
+	// check if each root is a valid directory or file
+	if !listed {
+		for _, root := range roots {
+			err := util.ValidateDirectory(afs, root)
+			if err != nil && !errors.Is(err, util.ErrPathIsNotDir) {
+				return nil, nil, nil, err
+			}
+			if err != nil && errors.Is(err, util.ErrPathIsNotDir) {
+				if err := util.ValidateFile(afs, root); err != nil {
+					return nil, nil, nil, err
+				}
+			}
 		}
 	}
 
@@ -399,7 +980,7 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 
 	var walkErrors []WalkError
 
-	err = afero.Walk(afs, root, func(path string, info os.FileInfo, afErr error) error {
+	visit := func(root string, path string, info os.FileInfo, afErr error) error {
 
 		// check if afero failed to access or find a file or directory
 		if afErr != nil {
@@ -412,12 +993,24 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 			return nil
 		}
 
+		// skip if the user excluded the file
+		if isExcludedPath(root, path, exclude) {
+			walkErrors = append(walkErrors, WalkError{Path: path, Error: ErrExcludedByPattern})
+			return nil // log the issue and continue walking
+		}
+
 		// skip if file is not a compatible log file
-		if !(strings.HasSuffix(path, ".log") || strings.HasSuffix(path, ".gz")) {
+		if !(strings.HasSuffix(path, ".log") || strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".bz2")) {
 			walkErrors = append(walkErrors, WalkError{Path: path, Error: ErrIncompatibleFileExtension})
 			return nil // log the issue and continue walking
 		}
 
+		// skip if the file is over the size limit
+		if maxFileBytes > 0 && info.Size() > maxFileBytes {
+			walkErrors = append(walkErrors, WalkError{Path: path, Error: ErrLogFileTooLarge})
+			return nil // log the issue and continue walking
+		}
+
 		// check if the file is readable
 		_, err := afs.Open(path)
 		if err != nil || !(info.Mode().Perm()&0444 == 0444) {
@@ -426,7 +1019,7 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 		}
 
 		// trim the path name to remove the file extensions, only to leave .log
-		trimmedFileName := strings.TrimSuffix(path, ".gz")
+		trimmedFileName := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".bz2")
 
 		// check if path doesn't have .log suffix anymore and add it if not
 		if !strings.HasSuffix(trimmedFileName, ".log") {
@@ -445,7 +1038,9 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 			}
 		// if trimmed version of the file exists in the map and the currently marked file for import
 		// was last modified more recently than this current file, replace it with this file
-		case exists && fileData.lastModified.Before(info.ModTime()):
+		// if both files were modified at the same time, prefer plain text, then gzip, then bzip2
+		case exists && (fileData.lastModified.Before(info.ModTime()) ||
+			(fileData.lastModified.Equal(info.ModTime()) && compressionRank(path) < compressionRank(fileData.path))):
 
 			// warn the user so that this isn't a silent operation
 			walkErrors = append(walkErrors, WalkError{Path: fTracker[trimmedFileName].path, Error: ErrSkippedDuplicateLog})
@@ -462,11 +1057,30 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 		}
 
 		return nil
-	})
+	}
 
-	// return an error if the file walk failed completely
-	if err != nil {
-		return nil, nil, fmt.Errorf("file walk failed: %w", err)
+	for _, root := range roots {
+		// listed files are read as they are instead of being walked, so directories are left out
+		if listed {
+			info, err := afs.Stat(root)
+			if err != nil {
+				walkErrors = append(walkErrors, WalkError{Path: root, Error: err})
+				continue
+			}
+			if info.IsDir() {
+				walkErrors = append(walkErrors, WalkError{Path: root, Error: util.ErrPathIsDir})
+				continue
+			}
+		}
+
+		err := afero.Walk(afs, root, func(path string, info os.FileInfo, afErr error) error {
+			return visit(root, path, info, afErr)
+		})
+
+		// return an error if the file walk failed completely
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("file walk failed: %w", err)
+		}
 	}
 
 	// group files into arrays by their log type
@@ -474,23 +1088,8 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 		path := file.path
 
 		// check if the file is one of the accepted log types
-		var prefix string
-		switch {
-		case strings.HasPrefix(filepath.Base(path), i.ConnPrefix) && !strings.HasPrefix(filepath.Base(path), i.ConnSummaryPrefixUnderscore) && !strings.HasPrefix(filepath.Base(path), i.ConnSummaryPrefixHyphen):
-			prefix = i.ConnPrefix
-		case strings.HasPrefix(filepath.Base(path), i.OpenConnPrefix):
-			prefix = i.OpenConnPrefix
-		case strings.HasPrefix(filepath.Base(path), i.DNSPrefix):
-			prefix = i.DNSPrefix
-		case strings.HasPrefix(filepath.Base(path), i.HTTPPrefix):
-			prefix = i.HTTPPrefix
-		case strings.HasPrefix(filepath.Base(path), i.OpenHTTPPrefix):
-			prefix = i.OpenHTTPPrefix
-		case strings.HasPrefix(filepath.Base(path), i.SSLPrefix):
-			prefix = i.SSLPrefix
-		case strings.HasPrefix(filepath.Base(path), i.OpenSSLPrefix):
-			prefix = i.OpenSSLPrefix
-		default: // skip file if it doesn't match any of the accepted prefixes
+		prefix := logPrefix(path)
+		if prefix == "" { // skip file if it doesn't match any of the accepted prefixes
 			walkErrors = append(walkErrors, WalkError{Path: path, Error: ErrInvalidLogType})
 			continue
 		}
@@ -523,7 +1122,8 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 
 	}
 
-	// filter out invalid file combinations
+	// filter out invalid file combinations, the files that are left out are only reported when classifying the walk
+	var dropped []WalkError
 
 	// loop over each day in the log map
 	for day := range logMap {
@@ -534,13 +1134,22 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 			// if there are no conn logs in the hour, we have to skip any SSL and HTTP logs for that hour
 			if len(logMap[day][hour][i.ConnPrefix]) == 0 && (len(logMap[day][hour][i.SSLPrefix]) > 0 || len(logMap[day][hour][i.HTTPPrefix]) > 0) {
 				logger.Warn().Msg("SSL / HTTP logs are present, but no conn logs exist, skipping SSL / HTTP logs...")
+				dropped = appendWalkErrors(dropped, ErrMissingConnLog, logMap[day][hour][i.SSLPrefix], logMap[day][hour][i.HTTPPrefix])
 				delete(logMap[day][hour], i.SSLPrefix)
 				delete(logMap[day][hour], i.HTTPPrefix)
 			}
 
+			// rdp logs are also linked with conn logs, so they have to be skipped if there are no conn logs in the hour
+			if len(logMap[day][hour][i.ConnPrefix]) == 0 && len(logMap[day][hour][i.RDPPrefix]) > 0 {
+				logger.Warn().Msg("RDP logs are present, but no conn logs exist, skipping RDP logs...")
+				dropped = appendWalkErrors(dropped, ErrMissingConnLog, logMap[day][hour][i.RDPPrefix])
+				delete(logMap[day][hour], i.RDPPrefix)
+			}
+
 			// 	// if there are no open conn logs in the hour, we have to skip any open SSL and open HTTP logs for that hour
 			if len(logMap[day][hour][i.OpenConnPrefix]) == 0 && (len(logMap[day][hour][i.OpenSSLPrefix]) > 0 || len(logMap[day][hour][i.OpenHTTPPrefix]) > 0) {
 				logger.Warn().Msg("Open SSL / open HTTP logs are present, but no conn logs exist, skipping open SSL / open HTTP logs...")
+				dropped = appendWalkErrors(dropped, ErrMissingOpenConnLog, logMap[day][hour][i.OpenSSLPrefix], logMap[day][hour][i.OpenHTTPPrefix])
 				delete(logMap[day][hour], i.OpenSSLPrefix)
 				delete(logMap[day][hour], i.OpenHTTPPrefix)
 			}
@@ -559,7 +1168,11 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 
 	// return an error if no files were found
 	if totalFilesFound == 0 {
-		return nil, walkErrors, ErrNoValidFilesFound
+		var classifications []WalkClassification
+		if classify {
+			classifications = classifyWalk(nil, walkErrors, dropped)
+		}
+		return nil, walkErrors, classifications, ErrNoValidFilesFound
 	}
 
 	var importLogs []HourlyZeekLogs
@@ -574,13 +1187,64 @@ func WalkFiles(afs afero.Fs, root string) ([]HourlyZeekLogs, []WalkError, error)
 		importLogs = append(importLogs, logMap[day])
 	}
 
-	return importLogs, walkErrors, err
+	var classifications []WalkClassification
+	if classify {
+		classifications = classifyWalk(importLogs, walkErrors, dropped)
+	}
+
+	return importLogs, walkErrors, classifications, nil
+}
+
+// logPrefix returns the log type prefix of a file, or an empty string if it isn't one of the accepted log types
+func logPrefix(path string) string {
+	base := filepath.Base(path)
+	switch {
+	case strings.HasPrefix(base, i.ConnPrefix) && !strings.HasPrefix(base, i.ConnSummaryPrefixUnderscore) && !strings.HasPrefix(base, i.ConnSummaryPrefixHyphen):
+		return i.ConnPrefix
+	case strings.HasPrefix(base, i.OpenConnPrefix):
+		return i.OpenConnPrefix
+	case strings.HasPrefix(base, i.DNSPrefix):
+		return i.DNSPrefix
+	case strings.HasPrefix(base, i.HTTPPrefix):
+		return i.HTTPPrefix
+	case strings.HasPrefix(base, i.OpenHTTPPrefix):
+		return i.OpenHTTPPrefix
+	case strings.HasPrefix(base, i.SSLPrefix):
+		return i.SSLPrefix
+	case strings.HasPrefix(base, i.OpenSSLPrefix):
+		return i.OpenSSLPrefix
+	case strings.HasPrefix(base, i.RDPPrefix):
+		return i.RDPPrefix
+	case strings.HasPrefix(base, i.FTPPrefix):
+		return i.FTPPrefix
+	case strings.HasPrefix(base, i.X509Prefix):
+		return i.X509Prefix
+	case strings.HasPrefix(base, i.KerberosPrefix):
+		return i.KerberosPrefix
+	case strings.HasPrefix(base, i.NTLMPrefix):
+		return i.NTLMPrefix
+	default:
+		return ""
+	}
+}
+
+// compressionRank returns the preference of a log file based on its compression when choosing between
+// duplicate logs that were modified at the same time, lower ranks are preferred
+func compressionRank(path string) int {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return 1
+	case strings.HasSuffix(path, ".bz2"):
+		return 2
+	default:
+		return 0
+	}
 }
 
 // ParseHourFromFilename extracts the hour from a given filename
 func ParseHourFromFilename(filename string) (int, error) {
 	// define regex patterns to extract the hour from the filename
-	timePattern := `[A-Za-z]+\.(\d{2})[:/_]\d{2}`
+	timePattern := `[A-Za-z][A-Za-z0-9]*\.(\d{2})[:/_]\d{2}`
 
 	// compile the timeRegex
 	timeRegex := regexp.MustCompile(timePattern)
@@ -601,7 +1265,7 @@ func ParseHourFromFilename(filename string) (int, error) {
 
 		if matches == nil {
 			// regex to identify simple log files (ie, conn.log, open_conn.log, /logs/conn.log.gz, etc) without hour
-			simpleLogPattern := `^\w+\.log(\.gz)?$`
+			simpleLogPattern := `^\w+\.log(\.gz|\.bz2)?$`
 			simpleLogRegex := regexp.MustCompile(simpleLogPattern)
 
 			// if the filename matches the simple log pattern, consider file as 0 hour and return
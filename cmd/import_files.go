@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+)
+
+var ErrFilesWithLogs = errors.New("files flag cannot be used with a log directory, tarball, or stdin")
+var ErrEmptyFileList = errors.New("files flag must list at least one file")
+
+// ParseFileList returns the absolute paths of the files listed with --files, in the order they were listed. Blank
+// entries and repeated files are left out.
+func ParseFileList(files []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+
+	for _, file := range files {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+
+		// list files relative to the current working directory, like the log directory
+		path, err := util.ParseRelativePath(file)
+		if err != nil {
+			return nil, err
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+
+	if len(paths) == 0 {
+		return nil, ErrEmptyFileList
+	}
+	return paths, nil
+}
+
+// WalkFileList groups an explicit list of log files like WalkFiles groups the files in a log directory, without walking
+// any directories. The log type and hour of each file are taken from its name, and the day from the name of the folder
+// it is in. Each file that can't be imported, such as a file that doesn't exist, is a directory, or isn't named after
+// an accepted log type, is left out with a walk error.
+func WalkFileList(afs afero.Fs, files []string, exclude []string, maxFileBytes int64) ([]HourlyZeekLogs, []WalkError, error) {
+	logMap, walkErrors, _, err := walkFiles(afs, files, true, exclude, maxFileBytes, false)
+	return logMap, walkErrors, err
+}
+
+// ClassifyWalkFileList groups an explicit list of log files like WalkFileList, and also returns how each file was
+// classified, sorted by path
+func ClassifyWalkFileList(afs afero.Fs, files []string, exclude []string, maxFileBytes int64) ([]HourlyZeekLogs, []WalkError, []WalkClassification, error) {
+	return walkFiles(afs, files, true, exclude, maxFileBytes, true)
+}
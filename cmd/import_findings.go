@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// printTopFindings prints the highest scoring strobes, high beacons, and threat intel hits found by the imports, so
+// that the most important results can be seen without opening the viewer
+func printTopFindings(cfg *config.Config, dbName string, importIDs []util.FixedString) error {
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	findings, err := db.GetTopFindings(importIDs, cfg.TopFindingsLimit, cfg.TopFindingsMinScore)
+	if err != nil {
+		return err
+	}
+
+	if len(findings) == 0 {
+		fmt.Printf("No strobes, high beacons, or threat intel hits scored at least %1.2f%%.\n", cfg.TopFindingsMinScore*100)
+		return nil
+	}
+
+	fmt.Println("Top Findings")
+	fmt.Println(FormatTopFindingsTable(findings))
+	return nil
+}
+
+// FormatTopFindingsTable formats the top findings of an import as a table, in the order that they were ranked
+func FormatTopFindingsTable(findings []database.TopFinding) *table.Table {
+	var data [][]string
+
+	for i, f := range findings {
+		src, dst := f.Src, f.Dst
+		// results for a domain don't have a destination IP, and threat intel hits on a domain don't have a source either
+		if dst == "::" && f.FQDN != "" {
+			if src == "::" {
+				src = ""
+			}
+			dst = f.FQDN
+		}
+		data = append(data, []string{fmt.Sprint(i + 1), strings.Join(f.Types, ", "), src, dst, fmt.Sprintf("%1.2f%%", f.TotalScore*100), fmt.Sprint(f.Count)})
+	}
+
+	re := lipgloss.NewRenderer(os.Stdout)
+	baseStyle := re.NewStyle().Padding(0, 1)
+	headerStyle := baseStyle.Foreground(lipgloss.Color("252")).Bold(true)
+
+	headers := []string{"Rank", "Finding", "Source", "Destination", "Score", "Connections"}
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(re.NewStyle().Foreground(lipgloss.Color("238"))).
+		Headers(headers...).
+		Rows(data...).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == 0 {
+				return headerStyle
+			}
+
+			even := row%2 == 0
+
+			if even {
+				return baseStyle.Foreground(lipgloss.Color("245"))
+			}
+			return baseStyle.Foreground(lipgloss.Color("252"))
+		})
+	return t
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+
+	i "github.com/activecm/rita/v5/importer"
+	zlog "github.com/activecm/rita/v5/logger"
+)
+
+// newImportProgress returns the progress reporter for parsing the files in logMap, or nil if progress isn't reported.
+// Progress is shown on the progress bar when stdout is a terminal, and is logged as structured events instead when logs
+// are written as JSON, since those are read by other programs rather than watched. Progress isn't reported with --quiet.
+func newImportProgress(logMap []HourlyZeekLogs) *i.Progress {
+	if !showProgress {
+		return nil
+	}
+
+	logFormat, err := zlog.ParseFormat(os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		return nil
+	}
+	logEvents := logFormat == zlog.FormatJSON
+	if !logEvents && !isTerminal(os.Stdout) {
+		return nil
+	}
+
+	total := 0
+	for _, hourlyLogs := range logMap {
+		for _, files := range hourlyLogs {
+			for _, paths := range files {
+				total += len(paths)
+			}
+		}
+	}
+
+	return i.NewProgress(total, logEvents)
+}
+
+// isTerminal returns whether the file is a terminal rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
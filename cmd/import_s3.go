@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/objectstore"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+)
+
+// IsObjectStoragePath returns whether the path is a prefix of a bucket in an S3-compatible object store,
+// ex: s3://bucket/prefix
+func IsObjectStoragePath(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), "s3://")
+}
+
+// ValidateObjectStoragePath checks that the object storage path names a bucket, the bucket is only read once the
+// config is loaded
+func ValidateObjectStoragePath(path string) error {
+	_, _, err := objectstore.ParsePath(path)
+	return err
+}
+
+// OpenObjectStorageLogs mounts the bucket of an object storage path so that the objects under its prefix can be walked
+// and imported like a log directory. The returned file system reads the bucket with the object storage settings in cfg
+// and reads every other path from the base file system, so that files such as threat intel feeds can still be read
+// during the import. Objects are tracked by their path under the mount directory, so importing the same prefix again
+// skips the objects that were already imported.
+func OpenObjectStorageLogs(ctx context.Context, base afero.Fs, cfg config.ObjectStorage, path string) (afero.Fs, string, error) {
+	bucket, prefix, err := objectstore.ParsePath(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := objectstore.NewClient(cfg, http.DefaultClient)
+	if err != nil {
+		return nil, "", err
+	}
+
+	afs := objectstore.NewFs(ctx, base, client, bucket)
+	logDir := objectstore.MountPath(bucket, prefix)
+
+	// make sure the prefix can be read before the import starts, it can also name a single log
+	err = util.ValidateDirectory(afs, logDir)
+	if errors.Is(err, util.ErrPathIsNotDir) {
+		err = util.ValidateFile(afs, logDir)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return afs, logDir, nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	i "github.com/activecm/rita/v5/importer"
+
+	"github.com/spf13/afero"
+)
+
+// StdinPath is passed in place of the log directory to read logs from stdin
+const StdinPath = "-"
+
+// stdinLogDirectory is the in-memory directory that logs read from stdin are stored in while they are imported
+const stdinLogDirectory = "/rita-stdin"
+
+// StdinLogTypes are the log types that can be read from stdin. Log types that are linked with conn logs,
+// such as ssl and http, are left out since they can't be imported without the conn logs from the same hour.
+var StdinLogTypes = []string{i.ConnPrefix, i.OpenConnPrefix, i.DNSPrefix, i.FTPPrefix, i.X509Prefix, i.KerberosPrefix, i.NTLMPrefix}
+
+var ErrMissingStdinLogType = errors.New("log type flag is required when reading logs from stdin")
+var ErrInvalidStdinLogType = fmt.Errorf("log type must be one of %s", strings.Join(StdinLogTypes, ", "))
+var ErrLogTypeWithoutStdin = errors.New("log type flag can only be used when reading logs from stdin")
+var ErrEmptyStdin = errors.New("no logs were read from stdin")
+
+// ValidateStdinLogType checks that logs of the given type can be read from stdin
+func ValidateStdinLogType(logType string) error {
+	if logType == "" {
+		return ErrMissingStdinLogType
+	}
+	if !slices.Contains(StdinLogTypes, logType) {
+		return fmt.Errorf("%w, got %q", ErrInvalidStdinLogType, logType)
+	}
+	return nil
+}
+
+// ReadStdinLogs stores the logs read from stdin as a single log file of the given type, so that they can be imported
+// like any other log directory. The returned file system holds the log file in memory and reads every other path from
+// the base file system, so that files such as threat intel feeds can still be read during the import.
+// Gzip and bzip2 compressed logs are detected from their contents since there is no file name to go by.
+// Since imported files are tracked by their path, the log is stored in a directory named after the hash of its
+// contents, so that the same logs aren't imported twice but different logs piped into the same dataset are.
+func ReadStdinLogs(base afero.Fs, stdin io.Reader, logType string) (afero.Fs, string, error) {
+	if err := ValidateStdinLogType(logType); err != nil {
+		return nil, "", err
+	}
+
+	reader := bufio.NewReader(stdin)
+
+	// name the file with the extension of its compression so that it is decompressed when it is parsed
+	name := logType + ".log"
+	magic, err := reader.Peek(3)
+	switch {
+	case errors.Is(err, io.EOF) && len(magic) == 0:
+		return nil, "", ErrEmptyStdin
+	case err != nil && !errors.Is(err, io.EOF):
+		return nil, "", fmt.Errorf("could not read logs from stdin: %w", err)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		name += ".gz"
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		name += ".bz2"
+	}
+
+	afs := afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(base), afero.NewMemMapFs())
+	if err := afs.MkdirAll(stdinLogDirectory, 0o755); err != nil {
+		return nil, "", err
+	}
+
+	// copy the logs into memory while hashing them
+	tmpPath := filepath.Join(stdinLogDirectory, name)
+	file, err := afs.Create(tmpPath)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), reader)
+	file.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read logs from stdin: %w", err)
+	}
+
+	// move the log into the directory named after its contents
+	logDir := filepath.Join(stdinLogDirectory, hex.EncodeToString(hash.Sum(nil))[:16])
+	if err := afs.MkdirAll(logDir, 0o755); err != nil {
+		return nil, "", err
+	}
+	if err := afs.Rename(tmpPath, filepath.Join(logDir, name)); err != nil {
+		return nil, "", err
+	}
+
+	return afs, logDir, nil
+}
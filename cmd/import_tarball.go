@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+)
+
+// tarballLogDirectory is the in-memory directory that logs extracted from tarballs are stored in while they are imported
+const tarballLogDirectory = "/rita-tarball"
+
+// tarballExtensions are the extensions of the gzipped tarballs that can be imported in place of a log directory
+var tarballExtensions = []string{".tar.gz", ".tgz"}
+
+var ErrEmptyTarball = errors.New("no files were found in the tarball")
+
+// IsTarballPath returns whether the path is a gzipped tarball that should be extracted before it is imported
+func IsTarballPath(path string) bool {
+	for _, ext := range tarballExtensions {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTarball checks that the tarball exists and is not empty
+func ValidateTarball(afs afero.Fs, path string) error {
+	file, err := util.ParseRelativePath(path)
+	if err != nil {
+		return err
+	}
+	return util.ValidateFile(afs, file)
+}
+
+// ExtractTarballLogs extracts the files in a gzipped tarball of a log directory, so that the tree can be walked and
+// imported like any other log directory. The returned file system holds the extracted files in memory and reads every
+// other path from the base file system, so that files such as threat intel feeds can still be read during the import.
+// Since imported files are tracked by their path, the files are stored under a directory named after the path of the
+// tarball, so that importing the same tarball again skips the files that were already imported. If the tarball only
+// holds a single directory, that directory is returned as the log directory. Entries larger than maxFileBytes are
+// skipped when it is greater than 0, since they would otherwise be held in memory for the whole import, and are
+// returned as walk errors so that they are counted like the log files that the walk leaves out.
+func ExtractTarballLogs(base afero.Fs, path string, maxFileBytes int64) (afero.Fs, string, []WalkError, error) {
+	logger := zlog.GetLogger()
+
+	tarPath, err := util.ParseRelativePath(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := util.ValidateFile(base, tarPath); err != nil {
+		return nil, "", nil, err
+	}
+
+	file, err := base.Open(tarPath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("could not read tarball %s: %w", tarPath, err)
+	}
+	defer gzReader.Close()
+
+	// name the log directory after the tarball without its extension
+	trimmed := tarPath
+	for _, ext := range tarballExtensions {
+		if strings.HasSuffix(strings.ToLower(trimmed), ext) {
+			trimmed = trimmed[:len(trimmed)-len(ext)]
+			break
+		}
+	}
+	logDir := filepath.Join(tarballLogDirectory, trimmed)
+
+	afs := afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(base), afero.NewMemMapFs())
+	if err := afs.MkdirAll(logDir, 0o755); err != nil {
+		return nil, "", nil, err
+	}
+
+	numFiles := 0
+	var skipped []WalkError
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("could not read tarball %s: %w", tarPath, err)
+		}
+
+		// only regular files are extracted, directories are created along with the files in them
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// skip entries over the size limit before they are read into memory
+		if maxFileBytes > 0 && header.Size > maxFileBytes {
+			logger.Warn().Str("path", header.Name).Int64("size", header.Size).Int64("max_log_file_bytes", maxFileBytes).Msg("skipping tarball entry that is larger than max_log_file_bytes")
+			skipped = append(skipped, WalkError{Path: filepath.Join(tarPath, header.Name), Error: ErrLogFileTooLarge})
+			continue
+		}
+
+		// clean the path as if it were rooted so that entries can't be written outside of the log directory
+		dst := filepath.Join(logDir, filepath.Clean("/"+header.Name))
+		if err := afs.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, "", nil, err
+		}
+
+		out, err := afs.Create(dst)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		_, err = io.Copy(out, tarReader) //nolint:gosec // logs are expected to be large
+		out.Close()
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("could not extract %s from tarball %s: %w", header.Name, tarPath, err)
+		}
+
+		// keep the modification time since it decides which copy of a log is imported
+		if err := afs.Chtimes(dst, header.ModTime, header.ModTime); err != nil {
+			return nil, "", nil, err
+		}
+		numFiles++
+	}
+
+	if numFiles == 0 {
+		return nil, "", nil, fmt.Errorf("%w: %s", ErrEmptyTarball, tarPath)
+	}
+
+	// tarballs of a log directory usually hold the directory itself, so import from inside of it
+	// to keep its name from being recorded as the sensor of every log
+	entries, err := afero.ReadDir(afs, logDir)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		logDir = filepath.Join(logDir, entries[0].Name())
+	}
+
+	return afs, logDir, skipped, nil
+}
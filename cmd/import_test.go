@@ -1,11 +1,21 @@
 package cmd_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 
+	"github.com/activecm/rita/v5/analysis"
 	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
 	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/objectstore"
 	"github.com/activecm/rita/v5/util"
 
 	iofs "io/fs"
@@ -310,12 +320,12 @@ func (c *CmdTestSuite) TestRunImportCmd() {
 				require.Len(t, importResults.ImportID, db.expectedImport, "import results should have expected number of import IDs")
 
 				// check if the database exists
-				exists, err := database.SensorDatabaseExists(context.Background(), c.server.Conn, db.name)
+				exists, err := database.SensorDatabaseExists(context.Background(), c.server.Conn, c.server.GetMetaDatabase(), db.name)
 				require.NoError(t, err, "checking if sensor database exists should not produce an error")
 				require.True(t, exists, "sensor database should exist")
 
 				// check rolling status
-				isRolling, err := database.GetRollingStatus(context.Background(), c.server.Conn, db.name)
+				isRolling, err := database.GetRollingStatus(context.Background(), c.server.Conn, c.server.GetMetaDatabase(), db.name)
 				require.NoError(t, err, "checking if sensor database is rolling should not produce an error")
 				require.Equal(t, db.rolling, isRolling, "rolling status should match expected value")
 
@@ -631,6 +641,7 @@ func TestWalkFiles(t *testing.T) {
 		filePermissions      iofs.FileMode
 		subdirectories       []string
 		files                []string
+		excludePatterns      []string
 		expectedFiles        []cmd.HourlyZeekLogs
 		expectedWalkErrors   []cmd.WalkError
 		expectedError        error
@@ -641,7 +652,7 @@ func TestWalkFiles(t *testing.T) {
 			directoryPermissions: os.FileMode(0o775),
 			filePermissions:      os.FileMode(0o775),
 			files: []string{
-				"conn.log", "dns.log", "http.log", "ssl.log", "open_conn.log", "open_http.log", "open_ssl.log",
+				"conn.log", "dns.log", "http.log", "ssl.log", "open_conn.log", "open_http.log", "open_ssl.log", "rdp.log", "kerberos.log", "ntlm.log",
 				"conn_red.log", "dns_red.log", "http_red.log", "ssl_red.log",
 				"conn_blue.log.gz", "dns_blue.log.gz", "http_blue.log.gz", "ssl_blue.log.gz",
 				".DS_STORE", "capture_loss.16:00:00-17:00:00.log.gz", "stats.16:00:00-17:00:00.log.gz", "x509.16:00:00-17:00:00.log.gz",
@@ -657,6 +668,12 @@ func TestWalkFiles(t *testing.T) {
 						importer.OpenHTTPPrefix: []string{"/logs/open_http.log"},
 						importer.SSLPrefix:      []string{"/logs/ssl.log", "/logs/ssl_blue.log.gz", "/logs/ssl_red.log"},
 						importer.OpenSSLPrefix:  []string{"/logs/open_ssl.log"},
+						importer.RDPPrefix:      []string{"/logs/rdp.log"},
+						importer.KerberosPrefix: []string{"/logs/kerberos.log"},
+						importer.NTLMPrefix:     []string{"/logs/ntlm.log"},
+					},
+					16: {
+						importer.X509Prefix: []string{"/logs/x509.16:00:00-17:00:00.log.gz"},
 					},
 				},
 			}),
@@ -664,7 +681,6 @@ func TestWalkFiles(t *testing.T) {
 				{Path: "/logs/.DS_STORE", Error: cmd.ErrIncompatibleFileExtension},
 				{Path: "/logs/capture_loss.16:00:00-17:00:00.log.gz", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/stats.16:00:00-17:00:00.log.gz", Error: cmd.ErrInvalidLogType},
-				{Path: "/logs/x509.16:00:00-17:00:00.log.gz", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/known_certs.16:00:00-17:00:00.log.gz", Error: cmd.ErrInvalidLogType},
 			},
 			expectedError: nil,
@@ -797,6 +813,7 @@ func TestWalkFiles(t *testing.T) {
 			files: []string{
 				// missing conn and open conn
 				"dns.00:00:00-01:00:00.log", "http.00:00:00-01:00:00.log", "open_http.00:00:00-01:00:00.log", "ssl.00:00:00-01:00:00.log", "open_ssl.00:00:00-01:00:00.log",
+				"rdp.00:00:00-01:00:00.log",
 			},
 			expectedFiles: createExpectedResults([]cmd.HourlyZeekLogs{
 				0: {
@@ -1087,6 +1104,46 @@ func TestWalkFiles(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name:                 "Duplicate Logs - Same Name, One Newer - .log.bz2 File is Newer",
+			directory:            "/logs_dupe",
+			directoryPermissions: iofs.FileMode(0o775),
+			filePermissions:      iofs.FileMode(0o775),
+			files: []string{
+				"conn.log.gz", "conn.log", "conn.log.bz2",
+			},
+			expectedFiles: createExpectedResults([]cmd.HourlyZeekLogs{
+				0: {
+					0: {
+						importer.ConnPrefix: []string{"/logs_dupe/conn.log.bz2"},
+					},
+				},
+			}),
+			expectedWalkErrors: []cmd.WalkError{
+				{Path: "/logs_dupe/conn.log", Error: cmd.ErrSkippedDuplicateLog},
+				{Path: "/logs_dupe/conn.log.gz", Error: cmd.ErrSkippedDuplicateLog},
+			},
+			expectedError: nil,
+		},
+		{
+			name:                 "Bzip2 Compressed Logs",
+			directory:            "/logs",
+			directoryPermissions: iofs.FileMode(0o775),
+			filePermissions:      iofs.FileMode(0o775),
+			files: []string{
+				"conn.log.bz2", "dns.00:00:00-01:00:00.log.bz2",
+			},
+			expectedFiles: createExpectedResults([]cmd.HourlyZeekLogs{
+				0: {
+					0: {
+						importer.ConnPrefix: []string{"/logs/conn.log.bz2"},
+						importer.DNSPrefix:  []string{"/logs/dns.00:00:00-01:00:00.log.bz2"},
+					},
+				},
+			}),
+			expectedWalkErrors: nil,
+			expectedError:      nil,
+		},
 		{
 			name:                 "No Prefix on Files",
 			directory:            "/logs",
@@ -1141,7 +1198,7 @@ func TestWalkFiles(t *testing.T) {
 			directoryPermissions: iofs.FileMode(0o775),
 			filePermissions:      iofs.FileMode(0o775),
 			files: []string{
-				"files.log", "ntp.log", "radius.log", "sip.log", "x509.log.gz", "dhcp.log", "weird.log",
+				"files.log", "ntp.log", "radius.log", "sip.log", "dhcp.log", "weird.log",
 				"conn_summary.log", "conn-summary.log", "foo.log",
 			},
 			expectedWalkErrors: []cmd.WalkError{
@@ -1149,7 +1206,6 @@ func TestWalkFiles(t *testing.T) {
 				{Path: "/logs/ntp.log", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/radius.log", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/sip.log", Error: cmd.ErrInvalidLogType},
-				{Path: "/logs/x509.log.gz", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/dhcp.log", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/weird.log", Error: cmd.ErrInvalidLogType},
 				{Path: "/logs/conn_summary.log", Error: cmd.ErrInvalidLogType},
@@ -1158,6 +1214,46 @@ func TestWalkFiles(t *testing.T) {
 			},
 			expectedError: cmd.ErrNoValidFilesFound,
 		},
+		{
+			name:                 "Excluded Logs",
+			directory:            "/logs",
+			directoryPermissions: iofs.FileMode(0o775),
+			filePermissions:      iofs.FileMode(0o775),
+			subdirectories:       []string{"/sensor1", "/sensor2"},
+			files: []string{
+				"sensor1/conn.log", "sensor1/dns.log", "sensor1/http.log.gz",
+				"sensor2/conn.log", "sensor2/dns.log", "sensor2/http.log.gz",
+			},
+			// patterns are matched against the path relative to the log directory
+			excludePatterns: []string{"sensor2/dns.*", "*/http.log.gz", "dns.log"},
+			expectedFiles: createExpectedResults([]cmd.HourlyZeekLogs{
+				0: {
+					0: {
+						importer.ConnPrefix: []string{"/logs/sensor1/conn.log", "/logs/sensor2/conn.log"},
+						importer.DNSPrefix:  []string{"/logs/sensor1/dns.log"},
+					},
+				},
+			}),
+			expectedWalkErrors: []cmd.WalkError{
+				{Path: "/logs/sensor2/dns.log", Error: cmd.ErrExcludedByPattern},
+				{Path: "/logs/sensor1/http.log.gz", Error: cmd.ErrExcludedByPattern},
+				{Path: "/logs/sensor2/http.log.gz", Error: cmd.ErrExcludedByPattern},
+			},
+			expectedError: nil,
+		},
+		{
+			name:                 "All Logs Excluded",
+			directory:            "/logs",
+			directoryPermissions: iofs.FileMode(0o775),
+			filePermissions:      iofs.FileMode(0o775),
+			files:                []string{"conn.log", "dns.log"},
+			excludePatterns:      []string{"*"},
+			expectedWalkErrors: []cmd.WalkError{
+				{Path: "/logs/conn.log", Error: cmd.ErrExcludedByPattern},
+				{Path: "/logs/dns.log", Error: cmd.ErrExcludedByPattern},
+			},
+			expectedError: cmd.ErrNoValidFilesFound,
+		},
 		{
 			name:                 "No Read Permissions on Files",
 			directory:            "/logs",
@@ -1232,9 +1328,9 @@ func TestWalkFiles(t *testing.T) {
 			// since some of the tests are for files passed in to the import command instead of the root directory, we need to
 			// simulate that accordingly
 			if test.directory != "" {
-				logMap, walkErrors, err = cmd.WalkFiles(afs, test.directory)
+				logMap, walkErrors, err = cmd.WalkFiles(afs, test.directory, test.excludePatterns, 0)
 			} else {
-				logMap, walkErrors, err = cmd.WalkFiles(afs, strings.Join(test.files, " "))
+				logMap, walkErrors, err = cmd.WalkFiles(afs, strings.Join(test.files, " "), test.excludePatterns, 0)
 			}
 
 			// check if the error is expected
@@ -1262,6 +1358,233 @@ func TestWalkFiles(t *testing.T) {
 	}
 }
 
+func TestWalkFilesMaxLogFileBytes(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	logDir := "/logs/2024-01-01"
+	maxFileBytes := int64(64 * 1024)
+
+	// write a synthetic conn log that is well over the limit
+	var large strings.Builder
+	large.WriteString("#separator \\x09\n#path\tconn\n#fields\tts\tuid\tid.orig_h\tid.resp_h\n")
+	for i := 0; large.Len() <= 4*int(maxFileBytes); i++ {
+		large.WriteString(fmt.Sprintf("1704067200.%06d\tC%d\t10.0.0.1\t8.8.8.8\n", i, i))
+	}
+	largePath := filepath.Join(logDir, "conn.00:00:00-01:00:00.log")
+	require.NoError(t, afero.WriteFile(afs, largePath, []byte(large.String()), 0o644))
+
+	// write a log that is under the limit
+	smallPath := filepath.Join(logDir, "dns.00:00:00-01:00:00.log")
+	require.NoError(t, afero.WriteFile(afs, smallPath, []byte("#separator \\x09\n#path\tdns\n"), 0o644))
+
+	t.Run("Under Limit", func(t *testing.T) {
+		logMap, walkErrors, err := cmd.WalkFiles(afs, logDir, nil, maxFileBytes)
+		require.NoError(t, err)
+		require.Equal(t, []cmd.WalkError{{Path: largePath, Error: cmd.ErrLogFileTooLarge}}, walkErrors, "only the large file should be skipped")
+		require.Len(t, logMap, 1)
+		require.Empty(t, logMap[0][0][importer.ConnPrefix], "the large file should be left out of the import")
+		require.Equal(t, []string{smallPath}, logMap[0][0][importer.DNSPrefix])
+	})
+
+	t.Run("No Limit", func(t *testing.T) {
+		logMap, walkErrors, err := cmd.WalkFiles(afs, logDir, nil, 0)
+		require.NoError(t, err)
+		require.Empty(t, walkErrors)
+		require.Equal(t, []string{largePath}, logMap[0][0][importer.ConnPrefix], "files of any size should be imported when the limit is disabled")
+	})
+}
+
+func TestClassifyWalkFiles(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	for _, file := range []string{
+		"2024-05-01/conn.log",
+		"2024-05-01/dns.03:00:00-04:00:00.log",
+		"dns.09:00:00-10:00:00.log",
+		"dns.09:00:00-10:00:00.log.gz",
+		"dns.25:00:00-26:00:00.log",
+		"readme.txt",
+		"weird.log",
+		"sensor1/2025-06-29/ssl.04:00:00-05:00:00.log",
+		"sensor1/2025-06-29/open_http.04:00:00-05:00:00.log",
+		"skip/conn.log",
+	} {
+		require.NoError(t, afero.WriteFile(afs, filepath.Join("/logs", file), []byte("#path\tconn\n"), 0o644))
+	}
+	// the gzipped copy of the duplicate log is newer, so it is the one that is imported
+	modTime := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, afs.Chtimes("/logs/dns.09:00:00-10:00:00.log", modTime, modTime))
+	require.NoError(t, afs.Chtimes("/logs/dns.09:00:00-10:00:00.log.gz", modTime.Add(time.Hour), modTime.Add(time.Hour)))
+
+	logMap, walkErrors, classifications, err := cmd.ClassifyWalkFiles(afs, "/logs", []string{"skip/*"}, 0)
+	require.NoError(t, err)
+
+	// classifying the walk shouldn't change what is imported
+	expectedLogMap, expectedWalkErrors, err := cmd.WalkFiles(afs, "/logs", []string{"skip/*"}, 0)
+	require.NoError(t, err)
+	require.Equal(t, expectedLogMap, logMap)
+	require.ElementsMatch(t, expectedWalkErrors, walkErrors)
+
+	for i := range classifications {
+		classifications[i].Error = nil
+	}
+	require.Equal(t, []cmd.WalkClassification{
+		{Path: "/logs/2024-05-01/conn.log", Prefix: importer.ConnPrefix, Hour: 0, Day: 1},
+		{Path: "/logs/2024-05-01/dns.03:00:00-04:00:00.log", Prefix: importer.DNSPrefix, Hour: 3, Day: 1},
+		{Path: "/logs/dns.09:00:00-10:00:00.log", Prefix: importer.DNSPrefix, Hour: 9, Day: -1, Reason: "duplicate"},
+		{Path: "/logs/dns.09:00:00-10:00:00.log.gz", Prefix: importer.DNSPrefix, Hour: 9, Day: 0},
+		{Path: "/logs/dns.25:00:00-26:00:00.log", Prefix: importer.DNSPrefix, Hour: -1, Day: -1, Reason: "invalid_hour"},
+		{Path: "/logs/readme.txt", Prefix: "", Hour: -1, Day: -1, Reason: "incompatible_extension"},
+		{Path: "/logs/sensor1/2025-06-29/open_http.04:00:00-05:00:00.log", Prefix: importer.OpenHTTPPrefix, Hour: 4, Day: -1, Reason: "missing_open_conn_log"},
+		{Path: "/logs/sensor1/2025-06-29/ssl.04:00:00-05:00:00.log", Prefix: importer.SSLPrefix, Hour: 4, Day: -1, Reason: "missing_conn_log"},
+		{Path: "/logs/skip/conn.log", Prefix: importer.ConnPrefix, Hour: 0, Day: -1, Reason: "excluded"},
+		{Path: "/logs/weird.log", Prefix: "", Hour: 0, Day: -1, Reason: "unknown_log_type"},
+	}, classifications)
+
+	require.Equal(t, "inaccessible", cmd.WalkSkipReason(os.ErrPermission))
+}
+
+func TestWalkFileList(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	for _, file := range []string{
+		"/data/2024-05-01/conn.00:00:00-01:00:00.log",
+		"/data/2024-05-01/http.00:00:00-01:00:00.log",
+		"/data/notes.txt",
+		"/data/weird.log",
+		"/other/2024-05-01/dns.00:00:00-01:00:00.log.gz",
+		"/other/2024-05-02/conn.log",
+	} {
+		require.NoError(t, afero.WriteFile(afs, file, []byte("#path\tconn\n"), 0o644))
+	}
+
+	// the logs are scattered across directories, and the http log next to the listed conn log isn't listed
+	files := []string{
+		"/data/2024-05-01/conn.00:00:00-01:00:00.log",
+		"/other/2024-05-01/dns.00:00:00-01:00:00.log.gz",
+		"/other/2024-05-02/conn.log",
+		"/data/notes.txt",
+		"/data/weird.log",
+		"/missing/conn.log",
+		"/data",
+	}
+
+	logMap, walkErrors, err := cmd.WalkFileList(afs, files, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, logMap, 2, "the day of each file should be taken from its folder")
+	require.Equal(t, map[string][]string{
+		importer.ConnPrefix: {"/data/2024-05-01/conn.00:00:00-01:00:00.log"},
+		importer.DNSPrefix:  {"/other/2024-05-01/dns.00:00:00-01:00:00.log.gz"},
+	}, logMap[0][0])
+	require.Equal(t, map[string][]string{importer.ConnPrefix: {"/other/2024-05-02/conn.log"}}, logMap[1][0])
+	require.Len(t, walkErrors, 4, "each file that can't be imported should have its own walk error")
+
+	// each file that can't be imported is reported on its own
+	_, _, classifications, err := cmd.ClassifyWalkFileList(afs, files, nil, 0)
+	require.NoError(t, err)
+	reasons := make(map[string]string)
+	for _, classification := range classifications {
+		reasons[classification.Path] = classification.Reason
+	}
+	require.Equal(t, map[string]string{
+		"/data": "directory",
+		"/data/2024-05-01/conn.00:00:00-01:00:00.log": "",
+		"/data/notes.txt":   "incompatible_extension",
+		"/data/weird.log":   "unknown_log_type",
+		"/missing/conn.log": "not_found",
+		"/other/2024-05-01/dns.00:00:00-01:00:00.log.gz": "",
+		"/other/2024-05-02/conn.log":                     "",
+	}, reasons)
+
+	// listed files are still excluded by name
+	_, walkErrors, err = cmd.WalkFileList(afs, files[:3], []string{"dns.*"}, 0)
+	require.NoError(t, err)
+	require.Equal(t, []cmd.WalkError{{Path: files[1], Error: cmd.ErrExcludedByPattern}}, walkErrors)
+
+	// and by the folders they are in, or their full path
+	_, walkErrors, err = cmd.WalkFileList(afs, files[:3], []string{"2024-05-01/conn.*", "/other/2024-05-02/*"}, 0)
+	require.NoError(t, err)
+	require.Equal(t, []cmd.WalkError{{Path: files[0], Error: cmd.ErrExcludedByPattern}, {Path: files[2], Error: cmd.ErrExcludedByPattern}}, walkErrors)
+
+	// a list without any valid files can't be imported
+	_, walkErrors, err = cmd.WalkFileList(afs, []string{"/data/notes.txt"}, nil, 0)
+	require.ErrorIs(t, err, cmd.ErrNoValidFilesFound)
+	require.Len(t, walkErrors, 1)
+}
+
+func TestParseFileList(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	files, err := cmd.ParseFileList([]string{"/logs/conn.log", " ./dns.log", "", "/logs/conn.log"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/logs/conn.log", filepath.Join(wd, "dns.log")}, files, "blank and repeated files should be left out")
+
+	_, err = cmd.ParseFileList([]string{"", " "})
+	require.ErrorIs(t, err, cmd.ErrEmptyFileList)
+}
+
+func TestCheckWalkErrors(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	logDir := "/logs"
+	for _, file := range []string{"conn.log", "dns.log", "readme.txt", "notes.md", "sensor2/http.log"} {
+		require.NoError(t, afero.WriteFile(afs, filepath.Join(logDir, file), []byte("#path\tconn\n"), 0o644))
+	}
+
+	t.Run("Skipped Files", func(t *testing.T) {
+		_, walkErrors, err := cmd.WalkFiles(afs, logDir, []string{"sensor2/*"}, 0)
+		require.NoError(t, err)
+		require.Len(t, walkErrors, 3)
+
+		err = cmd.CheckWalkErrors(walkErrors)
+		require.ErrorIs(t, err, cmd.ErrWalkErrors)
+		require.Contains(t, err.Error(), "2 file(s)", "excluded files should not be counted")
+		require.Contains(t, err.Error(), filepath.Join(logDir, "readme.txt")+": "+cmd.ErrIncompatibleFileExtension.Error())
+		require.Contains(t, err.Error(), filepath.Join(logDir, "notes.md")+": "+cmd.ErrIncompatibleFileExtension.Error())
+		require.NotContains(t, err.Error(), "sensor2", "excluded files should not be listed")
+	})
+
+	t.Run("Only Excluded Files", func(t *testing.T) {
+		require.NoError(t, cmd.CheckWalkErrors([]cmd.WalkError{{Path: "/logs/sensor2/http.log", Error: cmd.ErrExcludedByPattern}}))
+	})
+
+	t.Run("No Walk Errors", func(t *testing.T) {
+		require.NoError(t, cmd.CheckWalkErrors(nil))
+	})
+}
+
+func TestValidateExcludePatterns(t *testing.T) {
+	require.NoError(t, cmd.ValidateExcludePatterns(nil))
+	require.NoError(t, cmd.ValidateExcludePatterns([]string{"dns.*", "sensor1/*", "2024-01-0[1-3]/conn.log"}))
+	require.ErrorIs(t, cmd.ValidateExcludePatterns([]string{"conn.log", "[-"}), cmd.ErrInvalidExcludePattern)
+	require.ErrorIs(t, cmd.ValidateExcludePatterns([]string{""}), cmd.ErrInvalidExcludePattern)
+}
+
+func TestParseHostIPs(t *testing.T) {
+	ips, err := cmd.ParseHostIPs(nil)
+	require.NoError(t, err)
+	require.Empty(t, ips)
+
+	ips, err = cmd.ParseHostIPs([]string{"10.0.0.5", " 10.0.0.6", "2001:db8::1"})
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("10.0.0.6"), net.ParseIP("2001:db8::1")}, ips)
+
+	_, err = cmd.ParseHostIPs([]string{"10.0.0.5", "10.0.0"})
+	require.ErrorIs(t, err, cmd.ErrInvalidHostIP)
+
+	_, err = cmd.ParseHostIPs([]string{"10.0.0.0/24"})
+	require.ErrorIs(t, err, cmd.ErrInvalidHostIP, "subnets should not be accepted as hosts")
+}
+
+func TestRunImportRefusesHostFilter(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	// an import limited to some hosts would be finished with partial results, so it fails before anything is imported
+	cmd.SetImportOptions(&cfg, cmd.ImportOptions{HostFilter: analysis.HostFilter{Src: []net.IP{net.ParseIP("10.0.0.5")}}})
+	defer cmd.SetImportOptions(&cfg, cmd.ImportOptions{})
+
+	_, err = cmd.RunImportCmd(time.Now(), &cfg, afero.NewMemMapFs(), "/logs", "host_filter", false, false)
+	require.ErrorIs(t, err, cmd.ErrHostFilterOnImport)
+}
+
 func TestParseHourFromFilename(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1275,6 +1598,12 @@ func TestParseHourFromFilename(t *testing.T) {
 			wantHour: 0,
 			wantErr:  nil,
 		},
+		{
+			name:     "Simple Bzip2 Log with No Hour Segment",
+			filename: "/logs/conn.log.bz2",
+			wantHour: 0,
+			wantErr:  nil,
+		},
 
 		{
 			name:     "Valid hour middle range",
@@ -1282,6 +1611,12 @@ func TestParseHourFromFilename(t *testing.T) {
 			wantHour: 15,
 			wantErr:  nil,
 		},
+		{
+			name:     "Valid hour with digits in log type",
+			filename: "/logs/x509.16:00:00-17:00:00.log.gz",
+			wantHour: 16,
+			wantErr:  nil,
+		},
 		{
 			name:     "Valid hour lower bound",
 			filename: "log.00:00",
@@ -1382,6 +1717,12 @@ func TestValidateDatabaseName(t *testing.T) {
 			require.Equal(t, test.shouldErr, err != nil, "expected error:%t, got error: %t", test.shouldErr, err)
 		})
 	}
+
+	t.Run("Name is reserved: configured metadatabase", func(t *testing.T) {
+		t.Setenv("DB_METADATABASE", "rita_metadatabase")
+		require.Error(t, cmd.ValidateDatabaseName("rita_metadatabase"), "the configured metadatabase name should be reserved")
+		require.Error(t, cmd.ValidateDatabaseName("metadatabase"), "the default metadatabase name should stay reserved")
+	})
 }
 
 func TestValidateLogDirectory(t *testing.T) {
@@ -1494,3 +1835,312 @@ func TestParseFolderDate(t *testing.T) {
 		})
 	}
 }
+
+func TestGetImportConcurrency(t *testing.T) {
+	require.Equal(t, 8, cmd.GetImportConcurrency(8, 0, 0), "default should be used when neither the flag nor the config is set")
+	require.Equal(t, 2, cmd.GetImportConcurrency(8, 0, 2), "config value should be used when the flag is not set")
+	require.Equal(t, 3, cmd.GetImportConcurrency(8, 3, 2), "flag value should take precedence over the config value")
+	require.Equal(t, 16, cmd.GetImportConcurrency(8, 16, 0), "flag value should be allowed to exceed the default")
+}
+
+func TestValidateBatchSize(t *testing.T) {
+	require.NoError(t, cmd.ValidateBatchSize(config.MinBatchSize), "minimum batch size should be allowed")
+	require.NoError(t, cmd.ValidateBatchSize(config.MaxBatchSize), "maximum batch size should be allowed")
+	require.NoError(t, cmd.ValidateBatchSize(500000))
+	require.ErrorIs(t, cmd.ValidateBatchSize(config.MinBatchSize-1), cmd.ErrInvalidBatchSize)
+	require.ErrorIs(t, cmd.ValidateBatchSize(config.MaxBatchSize+1), cmd.ErrInvalidBatchSize)
+	require.ErrorIs(t, cmd.ValidateBatchSize(0), cmd.ErrInvalidBatchSize)
+}
+
+func TestGetBeaconLookbackStart(t *testing.T) {
+	minTS := time.Date(2024, 4, 19, 0, 0, 0, 0, time.UTC)
+	maxTS := time.Date(2024, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	require.Equal(t, minTS, cmd.GetBeaconLookbackStart(minTS, maxTS, 0), "no lookback should use the full range")
+	require.Equal(t, maxTS.Add(-6*time.Hour), cmd.GetBeaconLookbackStart(minTS, maxTS, 6*time.Hour), "lookback should end at the max timestamp")
+	require.Equal(t, minTS, cmd.GetBeaconLookbackStart(minTS, maxTS, 24*time.Hour), "lookback equal to the range should use the full range")
+	require.Equal(t, minTS, cmd.GetBeaconLookbackStart(minTS, maxTS, 48*time.Hour), "lookback should be capped to the min timestamp")
+}
+
+func TestNextImportStart(t *testing.T) {
+	previous := time.Date(2024, 4, 19, 0, 0, 5, 250_000_000, time.UTC)
+
+	require.Equal(t, previous.Add(2*time.Second), cmd.NextImportStart(previous.Add(2*time.Second), previous), "a start in a later second should be kept")
+	require.Equal(t, time.Date(2024, 4, 19, 0, 0, 6, 0, time.UTC), cmd.NextImportStart(previous.Add(500*time.Millisecond), previous), "a start in the same second should move to the next second")
+	require.Equal(t, time.Date(2024, 4, 19, 0, 0, 6, 0, time.UTC), cmd.NextImportStart(previous.Add(-time.Minute), previous), "a start before the previous import should move to the next second")
+	require.Equal(t, previous, cmd.NextImportStart(previous, time.Unix(0, 0)), "a dataset without imports should keep the start")
+}
+
+func TestAnalysisPhaseError(t *testing.T) {
+	cfg := &config.Config{AnalysisTimeout: 30}
+	queryErr := errors.New("query failed")
+
+	// errors that happen before the deadline are returned as is
+	db := (&database.DB{}).WithContext(context.Background())
+	require.Equal(t, queryErr, cmd.AnalysisPhaseError(db, cfg, "analysis", queryErr))
+
+	// errors after the deadline name the phase that was running
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	db = db.WithContext(ctx)
+	err := cmd.AnalysisPhaseError(db, cfg, "modifiers", queryErr)
+	require.ErrorIs(t, err, cmd.ErrAnalysisTimeout)
+	require.ErrorIs(t, err, queryErr, "the query error should be kept")
+	require.Contains(t, err.Error(), "the modifiers phase was still running after 30 seconds")
+}
+
+func TestReadStdinLogs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(base, "/etc/rita/threat_intel_feeds/feed.txt", []byte("1.2.3.4\n"), 0o644))
+
+	t.Run("Plain Text", func(t *testing.T) {
+		afs, logDir, err := cmd.ReadStdinLogs(base, strings.NewReader("#separator \\x09\n#path\tconn\n"), importer.ConnPrefix)
+		require.NoError(t, err)
+
+		logMap, walkErrors, err := cmd.WalkFiles(afs, logDir, nil, 0)
+		require.NoError(t, err)
+		require.Empty(t, walkErrors)
+		require.Len(t, logMap, 1)
+		require.Equal(t, []string{filepath.Join(logDir, "conn.log")}, logMap[0][0][importer.ConnPrefix], "stdin should be imported as a single log of the given type")
+
+		// files outside of the stdin log directory should still be read from the base file system
+		feed, err := afero.ReadFile(afs, "/etc/rita/threat_intel_feeds/feed.txt")
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4\n", string(feed))
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		afs, logDir, err := cmd.ReadStdinLogs(base, strings.NewReader("\x1f\x8b\x08\x00"), importer.DNSPrefix)
+		require.NoError(t, err)
+		exists, err := afero.Exists(afs, filepath.Join(logDir, "dns.log.gz"))
+		require.NoError(t, err)
+		require.True(t, exists, "gzip compressed logs should be named so that they are decompressed")
+	})
+
+	t.Run("Bzip2", func(t *testing.T) {
+		afs, logDir, err := cmd.ReadStdinLogs(base, strings.NewReader("BZh91AY"), importer.ConnPrefix)
+		require.NoError(t, err)
+		exists, err := afero.Exists(afs, filepath.Join(logDir, "conn.log.bz2"))
+		require.NoError(t, err)
+		require.True(t, exists, "bzip2 compressed logs should be named so that they are decompressed")
+	})
+
+	t.Run("Log Directory", func(t *testing.T) {
+		_, first, err := cmd.ReadStdinLogs(base, strings.NewReader("#path\tconn\nfirst\n"), importer.ConnPrefix)
+		require.NoError(t, err)
+		_, same, err := cmd.ReadStdinLogs(base, strings.NewReader("#path\tconn\nfirst\n"), importer.ConnPrefix)
+		require.NoError(t, err)
+		_, second, err := cmd.ReadStdinLogs(base, strings.NewReader("#path\tconn\nsecond\n"), importer.ConnPrefix)
+		require.NoError(t, err)
+
+		// imported files are tracked by path, so the path should only change when the logs do
+		require.Equal(t, first, same, "the same logs should be stored at the same path")
+		require.NotEqual(t, first, second, "different logs should be stored at different paths")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		_, _, err := cmd.ReadStdinLogs(base, strings.NewReader(""), importer.ConnPrefix)
+		require.ErrorIs(t, err, cmd.ErrEmptyStdin)
+	})
+
+	t.Run("Invalid Log Type", func(t *testing.T) {
+		_, _, err := cmd.ReadStdinLogs(base, strings.NewReader("#path\tssl\n"), importer.SSLPrefix)
+		require.ErrorIs(t, err, cmd.ErrInvalidStdinLogType, "logs that must be linked with conn logs can't be read from stdin")
+
+		_, _, err = cmd.ReadStdinLogs(base, strings.NewReader("#path\tconn\n"), "")
+		require.ErrorIs(t, err, cmd.ErrMissingStdinLogType)
+	})
+}
+
+func TestIsTarballPath(t *testing.T) {
+	require.True(t, cmd.IsTarballPath("/logs/archive.tar.gz"))
+	require.True(t, cmd.IsTarballPath("./archive.TGZ"))
+	require.False(t, cmd.IsTarballPath("/logs/conn.log.gz"))
+	require.False(t, cmd.IsTarballPath("/logs"))
+}
+
+func TestIsObjectStoragePath(t *testing.T) {
+	require.True(t, cmd.IsObjectStoragePath("s3://logs/sensor1"))
+	require.True(t, cmd.IsObjectStoragePath("S3://logs"))
+	require.False(t, cmd.IsObjectStoragePath("/logs/s3:/sensor1"))
+	require.False(t, cmd.IsObjectStoragePath("logs"))
+	require.NoError(t, cmd.ValidateObjectStoragePath("s3://logs/sensor1"))
+	require.ErrorIs(t, cmd.ValidateObjectStoragePath("s3:///sensor1"), objectstore.ErrInvalidObjectPath)
+}
+
+func TestOpenObjectStorageLogs(t *testing.T) {
+	// the bucket is public, so the requests aren't signed
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	modTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Authorization"))
+		switch {
+		case r.URL.Path == "/logs/" && r.URL.Query().Get("prefix") == "sensor1/":
+			fmt.Fprint(w, `<ListBucketResult><CommonPrefixes><Prefix>sensor1/2024-01-01/</Prefix></CommonPrefixes></ListBucketResult>`)
+		case r.URL.Path == "/logs/" && r.URL.Query().Get("prefix") == "sensor1/2024-01-01/":
+			fmt.Fprint(w, `<ListBucketResult>
+				<Contents><Key>sensor1/2024-01-01/conn.00:00:00-01:00:00.log</Key><Size>12</Size><LastModified>2024-01-01T12:00:00.000Z</LastModified></Contents>
+				<Contents><Key>sensor1/2024-01-01/dns.00:00:00-01:00:00.log</Key><Size>11</Size><LastModified>2024-01-01T12:00:00.000Z</LastModified></Contents>
+			</ListBucketResult>`)
+		case r.URL.Path == "/logs/" && r.URL.Query().Get("prefix") == "missing/":
+			fmt.Fprint(w, `<ListBucketResult></ListBucketResult>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.ObjectStorage{Endpoint: server.URL, Region: "us-east-1", UsePathStyle: true}
+
+	afs, logDir, err := cmd.OpenObjectStorageLogs(context.Background(), afero.NewMemMapFs(), cfg, "s3://logs/sensor1/")
+	require.NoError(t, err)
+	require.Equal(t, "/s3/logs/sensor1", logDir)
+
+	// the objects under the prefix should be walked like a log directory
+	logMap, walkErrors, err := cmd.WalkFiles(afs, logDir, nil, 0)
+	require.NoError(t, err)
+	require.Empty(t, walkErrors)
+	require.Len(t, logMap, 1)
+	require.Equal(t, []string{"/s3/logs/sensor1/2024-01-01/conn.00:00:00-01:00:00.log"}, logMap[0][0][importer.ConnPrefix])
+	require.Equal(t, []string{"/s3/logs/sensor1/2024-01-01/dns.00:00:00-01:00:00.log"}, logMap[0][0][importer.DNSPrefix])
+
+	info, err := afs.Stat("/s3/logs/sensor1/2024-01-01/conn.00:00:00-01:00:00.log")
+	require.NoError(t, err)
+	require.True(t, modTime.Equal(info.ModTime()), "expected modification time %v, got %v", modTime, info.ModTime())
+
+	_, _, err = cmd.OpenObjectStorageLogs(context.Background(), afero.NewMemMapFs(), cfg, "s3://logs/missing")
+	require.ErrorIs(t, err, util.ErrDirDoesNotExist)
+}
+
+func TestExtractTarballLogs(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// createTarball writes a gzipped tarball with the given files to the file system
+	createTarball := func(t *testing.T, afs afero.Fs, path string, files map[string]string) {
+		t.Helper()
+		var buf bytes.Buffer
+		gzWriter := gzip.NewWriter(&buf)
+		tarWriter := tar.NewWriter(gzWriter)
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0o755}))
+		for name, contents := range files {
+			require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents)), ModTime: modTime}))
+			_, err := tarWriter.Write([]byte(contents))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tarWriter.Close())
+		require.NoError(t, gzWriter.Close())
+		require.NoError(t, afero.WriteFile(afs, path, buf.Bytes(), 0o644))
+	}
+
+	base := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(base, "/etc/rita/threat_intel_feeds/feed.txt", []byte("1.2.3.4\n"), 0o644))
+
+	t.Run("Log Directory", func(t *testing.T) {
+		createTarball(t, base, "/archives/logs.tar.gz", map[string]string{
+			"logs/2024-01-01/conn.00:00:00-01:00:00.log": "#path\tconn\n",
+			"logs/2024-01-01/dns.00:00:00-01:00:00.log":  "#path\tdns\n",
+			"logs/2024-01-01/readme.txt":                 "not a log",
+		})
+
+		afs, logDir, skipped, err := cmd.ExtractTarballLogs(base, "/archives/logs.tar.gz", 0)
+		require.Empty(t, skipped)
+		require.NoError(t, err)
+		require.Equal(t, "/rita-tarball/archives/logs/logs", logDir, "logs should be imported from inside of the only directory in the tarball")
+		require.Empty(t, importer.ParseSensor(logDir, filepath.Join(logDir, "2024-01-01/conn.00:00:00-01:00:00.log")), "the directory in the tarball should not be treated as a sensor")
+
+		logMap, walkErrors, err := cmd.WalkFiles(afs, logDir, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, walkErrors, 1, "files that aren't logs should be left out like in a log directory")
+		require.Len(t, logMap, 1)
+		require.Equal(t, []string{filepath.Join(logDir, "2024-01-01/conn.00:00:00-01:00:00.log")}, logMap[0][0][importer.ConnPrefix])
+		require.Equal(t, []string{filepath.Join(logDir, "2024-01-01/dns.00:00:00-01:00:00.log")}, logMap[0][0][importer.DNSPrefix])
+
+		// the modification time should be kept from the tarball
+		info, err := afs.Stat(filepath.Join(logDir, "2024-01-01/conn.00:00:00-01:00:00.log"))
+		require.NoError(t, err)
+		require.True(t, modTime.Equal(info.ModTime()), "expected modification time %v, got %v", modTime, info.ModTime())
+
+		// files outside of the tarball log directory should still be read from the base file system
+		feed, err := afero.ReadFile(afs, "/etc/rita/threat_intel_feeds/feed.txt")
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4\n", string(feed))
+
+		// the base file system should not be written to
+		exists, err := afero.Exists(base, logDir)
+		require.NoError(t, err)
+		require.False(t, exists, "logs should not be extracted to the base file system")
+	})
+
+	t.Run("Path Traversal", func(t *testing.T) {
+		createTarball(t, base, "/archives/escape.tgz", map[string]string{
+			"../../etc/conn.log": "#path\tconn\n",
+		})
+
+		afs, logDir, _, err := cmd.ExtractTarballLogs(base, "/archives/escape.tgz", 0)
+		require.NoError(t, err)
+		require.Equal(t, "/rita-tarball/archives/escape/etc", logDir)
+		exists, err := afero.Exists(afs, "/rita-tarball/archives/escape/etc/conn.log")
+		require.NoError(t, err)
+		require.True(t, exists, "entries should be extracted inside of the log directory")
+	})
+
+	t.Run("Max File Size", func(t *testing.T) {
+		createTarball(t, base, "/archives/large.tar.gz", map[string]string{
+			"logs/conn.00:00:00-01:00:00.log": "#path\tconn\n" + strings.Repeat("x", 1024),
+			"logs/dns.00:00:00-01:00:00.log":  "#path\tdns\n",
+		})
+
+		afs, logDir, skipped, err := cmd.ExtractTarballLogs(base, "/archives/large.tar.gz", 512)
+		require.NoError(t, err)
+		require.Equal(t, []cmd.WalkError{{Path: "/archives/large.tar.gz/logs/conn.00:00:00-01:00:00.log", Error: cmd.ErrLogFileTooLarge}}, skipped, "entries over the limit should be returned as walk errors")
+		exists, err := afero.Exists(afs, filepath.Join(logDir, "conn.00:00:00-01:00:00.log"))
+		require.NoError(t, err)
+		require.False(t, exists, "entries over the limit should not be extracted")
+		exists, err = afero.Exists(afs, filepath.Join(logDir, "dns.00:00:00-01:00:00.log"))
+		require.NoError(t, err)
+		require.True(t, exists, "entries under the limit should be extracted")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		createTarball(t, base, "/archives/empty.tar.gz", nil)
+		_, _, _, err := cmd.ExtractTarballLogs(base, "/archives/empty.tar.gz", 0)
+		require.ErrorIs(t, err, cmd.ErrEmptyTarball)
+	})
+
+	t.Run("Not Gzipped", func(t *testing.T) {
+		require.NoError(t, afero.WriteFile(base, "/archives/plain.tar.gz", []byte("not gzipped"), 0o644))
+		_, _, _, err := cmd.ExtractTarballLogs(base, "/archives/plain.tar.gz", 0)
+		require.Error(t, err)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, _, _, err := cmd.ExtractTarballLogs(base, "/archives/missing.tar.gz", 0)
+		require.ErrorIs(t, err, util.ErrFileDoesNotExist)
+	})
+}
+
+func TestFormatTopFindingsTable(t *testing.T) {
+	findings := []database.TopFinding{
+		{Types: []string{database.FindingBeacon, database.FindingThreatIntel}, Src: "10.0.0.1", Dst: "203.0.113.7", Count: 1440, TotalScore: 1.05},
+		{Types: []string{database.FindingStrobe}, Src: "10.0.0.2", Dst: "198.51.100.4", Count: 86400, TotalScore: 0.8},
+		{Types: []string{database.FindingThreatIntel}, Src: "::", Dst: "::", FQDN: "malicious.example.com", Count: 3, TotalScore: 0.6},
+	}
+
+	lines := strings.Split(cmd.FormatTopFindingsTable(findings).String(), "\n")
+	require.Len(t, lines, 4+len(findings), "table should have a header, borders, and a row for each finding")
+
+	require.Contains(t, lines[1], "Finding")
+	require.Contains(t, lines[3], "beacon, threat intel", "results with more than one finding type should list each of them")
+	require.Contains(t, lines[3], "105.00%")
+	require.Contains(t, lines[4], "strobe")
+	require.Contains(t, lines[4], "86400")
+	require.Contains(t, lines[5], "malicious.example.com", "domains should be shown as the destination")
+	require.NotContains(t, lines[5], "::", "missing addresses should not be shown")
+
+	// findings should be listed in the order they were ranked
+	for i := range findings {
+		require.True(t, strings.HasPrefix(strings.TrimLeft(lines[3+i], "│ "), fmt.Sprint(i+1)), "row %d should be ranked %d", i, i+1)
+	}
+}
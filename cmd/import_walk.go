@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+)
+
+// WalkClassification is how the walk of a log directory classified a file that it found
+type WalkClassification struct {
+	Path   string
+	Prefix string // log type of the file, empty if it isn't one of the accepted log types
+	Hour   int    // hour of the day that the file is imported with, -1 if it couldn't be parsed
+	Day    int    // index of the day that the file is imported with, -1 if the file is skipped
+	Reason string // why the file is skipped, see WalkSkipReason, empty if the file is imported
+	Error  error  // the walk error that the file was skipped for
+}
+
+// Skipped returns whether the file is left out of the import
+func (c WalkClassification) Skipped() bool {
+	return c.Reason != ""
+}
+
+// WalkSkipReason returns a short name for the reason that a walk error left a file out of the import
+func WalkSkipReason(err error) string {
+	switch {
+	case errors.Is(err, ErrExcludedByPattern):
+		return "excluded"
+	case errors.Is(err, ErrIncompatibleFileExtension):
+		return "incompatible_extension"
+	case errors.Is(err, ErrLogFileTooLarge):
+		return "too_large"
+	case errors.Is(err, ErrInsufficientReadPermissions):
+		return "unreadable"
+	case errors.Is(err, ErrSkippedDuplicateLog):
+		return "duplicate"
+	case errors.Is(err, ErrInvalidLogType):
+		return "unknown_log_type"
+	case errors.Is(err, ErrInvalidLogHourFormat), errors.Is(err, ErrInvalidLogHourRange):
+		return "invalid_hour"
+	case errors.Is(err, ErrMissingConnLog):
+		return "missing_conn_log"
+	case errors.Is(err, ErrMissingOpenConnLog):
+		return "missing_open_conn_log"
+	case errors.Is(err, os.ErrNotExist):
+		return "not_found"
+	case errors.Is(err, util.ErrPathIsDir):
+		return "directory"
+	default:
+		return "inaccessible"
+	}
+}
+
+// appendWalkErrors records that each of the files in the path lists was left out of the import for err
+func appendWalkErrors(walkErrors []WalkError, err error, pathLists ...[]string) []WalkError {
+	for _, paths := range pathLists {
+		for _, path := range paths {
+			walkErrors = append(walkErrors, WalkError{Path: path, Error: err})
+		}
+	}
+	return walkErrors
+}
+
+// classifyWalk lists every file that the walk found, along with the log type and hour it resolved to. Files that are
+// imported are listed with the index of their day in logMap, and skipped files are listed with the reason they were
+// skipped. The log type and hour of skipped files are still resolved where possible, since they often explain why a
+// file was skipped, such as a duplicate of another log.
+func classifyWalk(logMap []HourlyZeekLogs, walkErrors []WalkError, dropped []WalkError) []WalkClassification {
+	var classifications []WalkClassification
+	imported := make(map[string]bool)
+
+	for day, hourlyLogs := range logMap {
+		for hour, files := range hourlyLogs {
+			for prefix, paths := range files {
+				for _, path := range paths {
+					imported[path] = true
+					classifications = append(classifications, WalkClassification{Path: path, Prefix: prefix, Hour: hour, Day: day})
+				}
+			}
+		}
+	}
+
+	for _, walkErr := range append(slices.Clip(walkErrors), dropped...) {
+		// files with a folder that isn't a date are still imported, under a generic day
+		if imported[walkErr.Path] {
+			continue
+		}
+
+		hour, err := ParseHourFromFilename(walkErr.Path)
+		if err != nil {
+			hour = -1
+		}
+		classifications = append(classifications, WalkClassification{
+			Path:   walkErr.Path,
+			Prefix: logPrefix(walkErr.Path),
+			Hour:   hour,
+			Day:    -1,
+			Reason: WalkSkipReason(walkErr.Error),
+			Error:  walkErr.Error,
+		})
+	}
+
+	slices.SortStableFunc(classifications, func(a, b WalkClassification) int { return strings.Compare(a.Path, b.Path) })
+	return classifications
+}
+
+// logWalkClassifications logs how the walk classified each file, for --verbose-walk
+func logWalkClassifications(classifications []WalkClassification) {
+	logger := zlog.GetLogger()
+
+	for _, c := range classifications {
+		if c.Skipped() {
+			logger.Info().Str("path", c.Path).Str("prefix", c.Prefix).Int("hour", c.Hour).Str("reason", c.Reason).Err(c.Error).Msg("walk: skipping file")
+			continue
+		}
+		logger.Info().Str("path", c.Path).Str("prefix", c.Prefix).Int("hour", c.Hour).Int("day", c.Day).Msg("walk: importing file")
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	i "github.com/activecm/rita/v5/importer"
+	"github.com/activecm/rita/v5/ingest"
+	zlog "github.com/activecm/rita/v5/logger"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrStreamingDisabled = errors.New("streaming is not enabled in the config file")
+
+var IngestCommand = &cli.Command{
+	Name:      "ingest",
+	Usage:     "continuously import zeek records from the configured stream into a rolling database",
+	UsageText: "rita ingest [--database NAME]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "target database; database name should start with a lowercase letter, should contain only alphanumeric and underscores, and not end with an underscore",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		afs := afero.NewOsFs()
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// set the number of workers based on the number of CPUs
+		numParsers = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+		numDigesters = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+		numWriters = int(math.Floor(math.Max(4, float64(runtime.NumCPU())/2)))
+
+		// stop reading from the stream on interrupt
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// run ingest command
+		source, err := NewIngestSource(cfg)
+		if err != nil {
+			return err
+		}
+		if err := RunIngestCmd(ctx, cfg, afs, source, cCtx.String("database")); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+// NewIngestSource creates the stream source in the streaming config, or returns ErrStreamingDisabled if streaming isn't enabled
+func NewIngestSource(cfg *config.Config) (ingest.StreamSource, error) {
+	if !cfg.Streaming.Enabled {
+		return nil, ErrStreamingDisabled
+	}
+
+	source, err := ingest.NewKafkaSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// RunIngestCmd imports and analyzes batches of records from the stream source until the context is cancelled
+func RunIngestCmd(ctx context.Context, cfg *config.Config, afs afero.Fs, source ingest.StreamSource, dbName string) error {
+	logger := zlog.GetLogger()
+
+	defer source.Close()
+
+	if !cfg.Streaming.Enabled {
+		return ErrStreamingDisabled
+	}
+
+	// streamed data is always current, so the dataset is always rolling
+	db, err := database.SetUpNewImport(afs, cfg, dbName, true, false, false)
+	if err != nil {
+		return err
+	}
+
+	consumer, err := ingest.NewConsumer(source, cfg.BatchSize, time.Duration(cfg.Streaming.FlushIntervalSeconds)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Str("dataset", dbName).Str("topic", cfg.Streaming.Kafka.Topic).Msg("Waiting for streamed records...")
+
+	err = consumer.Run(ctx, func(records *i.Records) error {
+		batchStart := time.Now()
+
+		// reset temporary tables
+		if err := db.ResetTemporaryTables(); err != nil {
+			return err
+		}
+
+		// set up new importer
+		importer, err := i.NewImporter(db, cfg, batchStart, numDigesters, numParsers, numWriters)
+		if err != nil {
+			return err
+		}
+
+		// import the batch
+		if err := importer.ImportRecords(records); err != nil {
+			return err
+		}
+
+		// analyze the imported data
+		if _, err := AnalyzeImport(db, cfg, importer.ImportID); err != nil {
+			return err
+		}
+
+		logger.Info().Int("records", records.Len()).Str("elapsed_time", time.Since(batchStart).String()).Msg("Finished Importing Streamed Batch")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Str("dataset", dbName).Msg("Stopped reading streamed records")
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/ingest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIngestSource(t *testing.T) {
+	t.Run("Streaming Disabled", func(t *testing.T) {
+		// the default config has streaming disabled and no kafka brokers
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+
+		source, err := cmd.NewIngestSource(&cfg)
+		require.ErrorIs(t, err, cmd.ErrStreamingDisabled)
+		require.Nil(t, source)
+	})
+
+	t.Run("No Kafka Brokers", func(t *testing.T) {
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+		cfg.Streaming.Enabled = true
+		cfg.Streaming.Kafka.Brokers = []string{}
+
+		source, err := cmd.NewIngestSource(&cfg)
+		require.ErrorIs(t, err, ingest.ErrNoKafkaBrokers)
+		require.Nil(t, source)
+	})
+
+	t.Run("Streaming Enabled", func(t *testing.T) {
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+		cfg.Streaming.Enabled = true
+		cfg.Streaming.Kafka.Brokers = []string{"localhost:9092"}
+		cfg.Streaming.Kafka.Topic = "zeek"
+		cfg.Streaming.Kafka.GroupID = "rita"
+
+		// creating the reader doesn't connect to the brokers
+		source, err := cmd.NewIngestSource(&cfg)
+		require.NoError(t, err)
+		require.NotNil(t, source)
+		require.NoError(t, source.Close())
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/activecm/rita/v5/config"
 	"github.com/activecm/rita/v5/database"
@@ -80,14 +81,14 @@ func FormatListTable(dbs []database.ImportDatabase) *table.Table {
 	var data [][]string
 
 	for _, d := range dbs {
-		data = append(data, []string{d.Name, strconv.FormatBool(d.Rolling), fmt.Sprintf("%s - %s", d.MinTS.Format("2006-01-02 15:04"), d.MaxTS.Format("2006-01-02 15:04"))})
+		data = append(data, []string{d.Name, strconv.FormatBool(d.Rolling), fmt.Sprintf("%s - %s", d.MinTS.Format("2006-01-02 15:04"), d.MaxTS.Format("2006-01-02 15:04")), strings.Join(d.Sensors, ", ")})
 	}
 
 	re := lipgloss.NewRenderer(os.Stdout)
 	baseStyle := re.NewStyle().Padding(0, 1)
 	headerStyle := baseStyle.Foreground(lipgloss.Color("252")).Bold(true)
 
-	headers := []string{"Name", "Rolling", "Time Range (UTC)"}
+	headers := []string{"Name", "Rolling", "Time Range (UTC)", "Sensors"}
 	t := table.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(re.NewStyle().Foreground(lipgloss.Color("238"))).
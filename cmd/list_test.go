@@ -60,11 +60,12 @@ func (c *CmdTestSuite) TestFormatListTable() {
 	}
 	for i, line := range lines {
 		cols := strings.Split(line, "│")
-		require.Len(cols, 5)
-		cols = cols[1:4]
+		require.Len(cols, 6)
+		cols = cols[1:5]
 		require.Equal(expectedDBs[i].name, strings.TrimSpace(cols[0]))
 		require.Equal(expectedDBs[i].rolling, strings.TrimSpace(cols[1]))
 		require.Equal(expectedDBs[i].tsRange, strings.TrimSpace(cols[2]))
+		require.Empty(strings.TrimSpace(cols[3]), "datasets imported without sensor subdirectories should not list any sensors")
 	}
 
 	// clean up
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var MigrateCommand = &cli.Command{
+	Name:        "migrate",
+	Usage:       "upgrade the schema of a dataset created by an older version of RITA",
+	UsageText:   "rita migrate [--database NAME]",
+	Description: "applies the schema changes made since the dataset was created so that more data can be imported into it",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to migrate",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the migrate command
+		if err := runMigrateCmd(cfg, cCtx.String("database")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runMigrateCmd(cfg *config.Config, dbName string) error {
+	// connect to server
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	previous, err := server.MigrateSensorDB(cfg, dbName)
+	if err != nil {
+		return err
+	}
+
+	if previous == database.SchemaVersion {
+		fmt.Printf("%s is already at schema version %d, nothing to migrate.\n", dbName, database.SchemaVersion)
+		return nil
+	}
+
+	fmt.Printf("Migrated %s from schema version %d to %d.\n", dbName, previous, database.SchemaVersion)
+	return nil
+}
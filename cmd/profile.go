@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/spf13/afero"
+)
+
+var ErrInvalidProfileDirectory = errors.New("profile path must be a directory")
+
+// profile file names written to the profile directory
+const (
+	CPUProfileFile  = "cpu.pprof"
+	HeapProfileFile = "heap.pprof"
+)
+
+// ValidateProfileDirectory checks that the profile path is a directory if it already exists, missing directories are
+// created when profiling starts
+func ValidateProfileDirectory(afs afero.Fs, dir string) error {
+	if dir == "" {
+		return fmt.Errorf("%w: path cannot be empty", ErrInvalidProfileDirectory)
+	}
+
+	exists, err := afero.Exists(afs, dir)
+	if err != nil || !exists {
+		return err
+	}
+
+	isDir, err := afero.IsDir(afs, dir)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return fmt.Errorf("%w: %s", ErrInvalidProfileDirectory, dir)
+	}
+	return nil
+}
+
+// StartProfiling starts a CPU profile in dir and returns a function that stops it and writes a heap profile next to
+// it. Nothing is profiled when dir is empty, and the returned function does nothing.
+func StartProfiling(afs afero.Fs, dir string) (func() error, error) {
+	if dir == "" {
+		return func() error { return nil }, nil
+	}
+
+	if err := afs.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	cpuFile, err := afs.Create(filepath.Join(dir, CPUProfileFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pprof.StartCPUProfile(cpuFile); err != nil {
+		cpuFile.Close()
+		return nil, err
+	}
+
+	stop := func() error {
+		pprof.StopCPUProfile()
+		if err := cpuFile.Close(); err != nil {
+			return err
+		}
+
+		heapFile, err := afs.Create(filepath.Join(dir, HeapProfileFile))
+		if err != nil {
+			return err
+		}
+		defer heapFile.Close()
+
+		// collect garbage first so that the heap profile only shows memory that is still in use
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(heapFile); err != nil {
+			return err
+		}
+		return heapFile.Close()
+	}
+
+	return stop, nil
+}
@@ -0,0 +1,50 @@
+package cmd_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartProfiling(t *testing.T) {
+	t.Run("Writes Profiles", func(t *testing.T) {
+		afs := afero.NewMemMapFs()
+
+		stop, err := cmd.StartProfiling(afs, "/profiles/import")
+		require.NoError(t, err)
+		require.NoError(t, stop())
+
+		for _, name := range []string{cmd.CPUProfileFile, cmd.HeapProfileFile} {
+			info, err := afs.Stat(filepath.Join("/profiles/import", name))
+			require.NoError(t, err, "%s should be written", name)
+			require.Positive(t, info.Size(), "%s should not be empty", name)
+		}
+	})
+
+	t.Run("No Op Without Directory", func(t *testing.T) {
+		afs := afero.NewMemMapFs()
+
+		stop, err := cmd.StartProfiling(afs, "")
+		require.NoError(t, err)
+		require.NoError(t, stop())
+
+		files, err := afero.ReadDir(afs, "/")
+		require.NoError(t, err)
+		require.Empty(t, files, "nothing should be written when profiling is off")
+	})
+}
+
+func TestValidateProfileDirectory(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afs.MkdirAll("/profiles", 0o755))
+	require.NoError(t, afero.WriteFile(afs, "/profiles.txt", []byte("not a directory"), 0o644))
+
+	require.NoError(t, cmd.ValidateProfileDirectory(afs, "/profiles"))
+	require.NoError(t, cmd.ValidateProfileDirectory(afs, "/missing"), "missing directories are created when profiling starts")
+	require.ErrorIs(t, cmd.ValidateProfileDirectory(afs, "/profiles.txt"), cmd.ErrInvalidProfileDirectory)
+	require.ErrorIs(t, cmd.ValidateProfileDirectory(afs, ""), cmd.ErrInvalidProfileDirectory)
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrInvalidQueryTable = fmt.Errorf("table must be one of: %s", strings.Join(database.QueryTables, ", "))
+var ErrInvalidQueryFormat = errors.New("format must be csv or json")
+
+const (
+	QueryFormatCSV  = "csv"
+	QueryFormatJSON = "json"
+)
+
+var QueryCommand = &cli.Command{
+	Name:        "query",
+	Usage:       "print the contents of an analysis table",
+	UsageText:   "rita query --database NAME --table TABLE [--src IP] [--format csv|json]",
+	Description: "prints the aggregated rows of the rare_signatures, tls_proto, http_proto, or mime_type_uris table of a dataset",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to query",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "table",
+			Aliases:  []string{"t"},
+			Usage:    "table to query: " + strings.Join(database.QueryTables, ", "),
+			Required: true,
+			Action: func(_ *cli.Context, table string) error {
+				return ValidateQueryTable(table)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "src",
+			Usage:    "only print rows for this source IP",
+			Required: false,
+			Action: func(_ *cli.Context, src string) error {
+				_, err := ParseHostIPs([]string{src})
+				return err
+			},
+		},
+		&cli.StringFlag{
+			Name:     "format",
+			Aliases:  []string{"f"},
+			Usage:    "output format, csv or json",
+			Value:    QueryFormatCSV,
+			Required: false,
+			Action: func(_ *cli.Context, format string) error {
+				return ValidateQueryFormat(format)
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// the source was validated when the flag was parsed
+		var src net.IP
+		if cCtx.IsSet("src") {
+			src = net.ParseIP(strings.TrimSpace(cCtx.String("src")))
+		}
+
+		// run the query command
+		if err := runQueryCmd(cfg, cCtx.String("database"), cCtx.String("table"), src, cCtx.String("format")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runQueryCmd(cfg *config.Config, dbName string, table string, src net.IP, format string) error {
+	// connect to database
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.QueryTable(table, src)
+	if err != nil {
+		return err
+	}
+
+	return WriteQueryResults(os.Stdout, rows, format)
+}
+
+// ValidateQueryTable checks that the table is one of the tables that can be queried
+func ValidateQueryTable(table string) error {
+	if !slices.Contains(database.QueryTables, table) {
+		return ErrInvalidQueryTable
+	}
+	return nil
+}
+
+// ValidateQueryFormat checks that the output format is supported
+func ValidateQueryFormat(format string) error {
+	if format != QueryFormatCSV && format != QueryFormatJSON {
+		return ErrInvalidQueryFormat
+	}
+	return nil
+}
+
+// WriteQueryResults writes a slice of table rows to w in the given format. CSV columns are named after the
+// json tags of the row struct, and list values are joined with semicolons.
+func WriteQueryResults(w io.Writer, rows any, format string) error {
+	if err := ValidateQueryFormat(format); err != nil {
+		return err
+	}
+
+	if format == QueryFormatJSON {
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+	}
+
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot format %T as csv", rows)
+	}
+	rowType := value.Type().Elem()
+
+	writer := csv.NewWriter(w)
+
+	// write the header
+	header := make([]string, rowType.NumField())
+	for i := range header {
+		header[i] = strings.Split(rowType.Field(i).Tag.Get("json"), ",")[0]
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	// write each row
+	for i := 0; i < value.Len(); i++ {
+		row := value.Index(i)
+		record := make([]string, row.NumField())
+		for j := range record {
+			record[j] = formatQueryValue(row.Field(j).Interface())
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatQueryValue formats a single value of a table row for csv output
+func formatQueryValue(value any) string {
+	switch v := value.(type) {
+	case net.IP:
+		return v.String()
+	case []string:
+		return strings.Join(v, ";")
+	default:
+		return fmt.Sprint(v)
+	}
+}
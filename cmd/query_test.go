@@ -0,0 +1,72 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateQueryTable(t *testing.T) {
+	for _, table := range database.QueryTables {
+		require.NoError(t, cmd.ValidateQueryTable(table))
+	}
+	require.ErrorIs(t, cmd.ValidateQueryTable("threat_mixtape"), cmd.ErrInvalidQueryTable)
+	require.ErrorIs(t, cmd.ValidateQueryTable(""), cmd.ErrInvalidQueryTable)
+}
+
+func TestValidateQueryFormat(t *testing.T) {
+	require.NoError(t, cmd.ValidateQueryFormat("csv"))
+	require.NoError(t, cmd.ValidateQueryFormat("json"))
+	require.ErrorIs(t, cmd.ValidateQueryFormat("tsv"), cmd.ErrInvalidQueryFormat)
+}
+
+func TestWriteQueryResults(t *testing.T) {
+	rows := []database.HTTPProto{
+		{
+			Src:          net.ParseIP("10.55.100.111"),
+			FQDN:         "www.example.com",
+			Useragent:    "Mozilla/5.0 (KHTML, like Gecko)",
+			Method:       "GET",
+			URI:          "/index.html",
+			DstMIMETypes: []string{"text/html", "text/plain"},
+			Count:        12,
+		},
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, cmd.WriteQueryResults(&out, rows, "csv"))
+		require.Equal(t,
+			"src,fqdn,useragent,method,referrer,uri,dst_mime_types,count\n"+
+				"10.55.100.111,www.example.com,\"Mozilla/5.0 (KHTML, like Gecko)\",GET,,/index.html,text/html;text/plain,12\n",
+			out.String())
+	})
+
+	t.Run("CSV Without Rows", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, cmd.WriteQueryResults(&out, []database.RareSignature{}, "csv"))
+		require.Equal(t, "src,signature,is_ja3,times_used_dst,times_used_fqdn\n", out.String(), "the header should be written even without rows")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, cmd.WriteQueryResults(&out, rows, "json"))
+
+		var decoded []map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		require.Len(t, decoded, 1)
+		require.Equal(t, "10.55.100.111", decoded[0]["src"])
+		require.Equal(t, []any{"text/html", "text/plain"}, decoded[0]["dst_mime_types"])
+		require.EqualValues(t, 12, decoded[0]["count"])
+	})
+
+	t.Run("Invalid Format", func(t *testing.T) {
+		require.ErrorIs(t, cmd.WriteQueryResults(&bytes.Buffer{}, rows, "xml"), cmd.ErrInvalidQueryFormat)
+	})
+}
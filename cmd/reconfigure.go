@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	i "github.com/activecm/rita/v5/importer"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ReconfigureCommand = &cli.Command{
+	Name:        "reconfigure",
+	Usage:       "re-apply the filter and re-run analysis on an imported dataset using the current config",
+	UsageText:   "rita reconfigure --database NAME [--only-src IP,...] [--only-dst IP,...]",
+	Description: "removes the stored connections that the current filter excludes and analyzes the rest again without re-parsing the logs. Connections that were filtered out when they were imported were never stored, so loosening the filter still requires a re-import",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to reconfigure",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.StringSliceFlag{
+			Name:     "only-src",
+			Usage:    "only analyze the connections from these source IPs again and keep the other results, ex: 10.0.0.5,10.0.0.6",
+			Required: false,
+			Action: func(_ *cli.Context, hosts []string) error {
+				_, err := ParseHostIPs(hosts)
+				return err
+			},
+		},
+		&cli.StringSliceFlag{
+			Name:     "only-dst",
+			Usage:    "only analyze the connections to these destination IPs again and keep the other results, ex: 203.0.113.7",
+			Required: false,
+			Action: func(_ *cli.Context, hosts []string) error {
+				_, err := ParseHostIPs(hosts)
+				return err
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// the host lists were validated when the flags were parsed
+		onlySrc, _ := ParseHostIPs(cCtx.StringSlice("only-src"))
+		onlyDst, _ := ParseHostIPs(cCtx.StringSlice("only-dst"))
+		SetImportOptions(cfg, ImportOptions{HostFilter: analysis.HostFilter{Src: onlySrc, Dst: onlyDst}})
+
+		// run the reconfigure command
+		if err := runReconfigureCmd(time.Now(), cfg, cCtx.String("database")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runReconfigureCmd(startTime time.Time, cfg *config.Config, dbName string) error {
+	logger := zlog.GetLogger()
+
+	// connect to database
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	// make sure the dataset has finished at least one import
+	analyzed, err := db.HasFinishedImport()
+	if err != nil {
+		return err
+	}
+	if !analyzed {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotAnalyzed, dbName)
+	}
+
+	logger.Info().Str("dataset", dbName).Str("started_at", startTime.String()).Msg("Reconfiguring dataset...")
+
+	// remove the stored records that the current filter excludes
+	if err := removeFilteredRecords(db, &cfg.Filter); err != nil {
+		return err
+	}
+
+	// classify the stored hosts with the current internal subnets
+	if err := db.UpdateLocalHosts(cfg.Filter.InternalSubnets); err != nil {
+		return err
+	}
+
+	// analyze the remaining records again
+	if _, _, err := ReanalyzeDataset(db, cfg, startTime); err != nil {
+		return err
+	}
+
+	logger.Info().Str("elapsed_time", fmt.Sprintf("%1.1fs", time.Since(startTime).Seconds())).Msg("🎊✨ Finished Reconfiguring! ✨🎊")
+
+	return nil
+}
+
+// ReanalyzeDataset analyzes every record stored in the dataset again, replacing the results of the previous analyses
+// that fall within the analyzed window.
+// The analysis is recorded like an import that started at startTime so that its results can be told apart from the
+// previous ones. If a host filter was set with SetImportOptions, only the connections of those hosts are analyzed again
+// and only their results are replaced.
+func ReanalyzeDataset(db *database.DB, cfg *config.Config, startTime time.Time) (util.FixedString, ImportTimestamps, error) {
+	var err error
+
+	// analysis and the import records depend on the rolling status of the dataset
+	db.Rolling, err = database.GetRollingStatus(db.GetContext(), db.Conn, db.GetMetaDatabase(), db.GetSelectedDB())
+	if err != nil {
+		return util.FixedString{}, ImportTimestamps{}, err
+	}
+	db.ImportStartedAt = startTime
+
+	// analyze every stored hash instead of only those from the most recent import
+	if err := db.ReloadTemporaryTables(); err != nil {
+		return util.FixedString{}, ImportTimestamps{}, err
+	}
+
+	// the new analysis replaces the results of the previous ones within the window that it analyzes
+	minTS, _, _, _, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		return util.FixedString{}, ImportTimestamps{}, err
+	}
+	if err := db.ClearAnalysisResults(minTS, hostFilter.Src, hostFilter.Dst); err != nil {
+		return util.FixedString{}, ImportTimestamps{}, err
+	}
+
+	importID, err := i.NewImportID(startTime)
+	if err != nil {
+		return util.FixedString{}, ImportTimestamps{}, err
+	}
+	if err := db.AddImportStartRecordToMetaDB(importID); err != nil {
+		return importID, ImportTimestamps{}, err
+	}
+
+	timestamps, err := AnalyzeImport(db, cfg, importID)
+	return importID, timestamps, err
+}
+
+// removeFilteredRecords deletes the stored connections, SSL and HTTP connections, and DNS queries that the filter excludes
+func removeFilteredRecords(db *database.DB, filter *config.Filter) error {
+	logger := zlog.GetLogger()
+
+	connPairs, err := db.GetStoredConnPairs()
+	if err != nil {
+		return err
+	}
+	filteredConns := FilteredConnPairs(filter, connPairs)
+	if err := db.RemoveConnPairs(filteredConns); err != nil {
+		return err
+	}
+
+	sniPairs, err := db.GetStoredSNIPairs()
+	if err != nil {
+		return err
+	}
+	filteredSNI := FilteredSNIPairs(filter, sniPairs)
+	if err := db.RemoveSNIPairs(filteredSNI); err != nil {
+		return err
+	}
+
+	dnsPairs, err := db.GetStoredDNSPairs()
+	if err != nil {
+		return err
+	}
+	filteredDNS := FilteredDNSPairs(filter, dnsPairs)
+	if err := db.RemoveDNSPairs(filteredDNS); err != nil {
+		return err
+	}
+	// exploded_dns is aggregated from the queries, so it's counted again without the removed ones
+	if len(filteredDNS) > 0 {
+		if err := db.RebuildExplodedDNS(); err != nil {
+			return err
+		}
+	}
+
+	logger.Info().Int("conn_pairs", len(filteredConns)).Int("sni_pairs", len(filteredSNI)).Int("dns_pairs", len(filteredDNS)).Msg("removed the stored records that are excluded by the current filter")
+
+	return nil
+}
+
+// FilteredConnPairs returns the stored connection pairs that the filter excludes.
+// Port and protocol filters can't be re-applied since the unique connection pairs don't keep their ports or protocols.
+func FilteredConnPairs(filter *config.Filter, pairs []database.StoredPair) []database.StoredPair {
+	var filtered []database.StoredPair
+	for _, pair := range pairs {
+		if filter.FilterConnPair(pair.Src, pair.Dst) {
+			filtered = append(filtered, pair)
+		}
+	}
+	return filtered
+}
+
+// FilteredSNIPairs returns the stored SSL and HTTP connection pairs that the filter excludes, following the same
+// rules that the importer applies to SSL and HTTP records
+func FilteredSNIPairs(filter *config.Filter, pairs []database.StoredPair) []database.StoredPair {
+	var filtered []database.StoredPair
+	for _, pair := range pairs {
+		var excluded bool
+		if pair.Proxy {
+			// the destination of a proxied connection is the proxy, so the domain is checked in its place
+			fqdnAsIPAddress := net.ParseIP(pair.FQDN)
+			excluded = filter.FilterDomain(pair.FQDN) || filter.FilterSingleIP(pair.Src) || filter.FilterDestination(fqdnAsIPAddress) ||
+				filter.FilterInternalDomainPair(pair.Src, pair.FQDN) ||
+				(fqdnAsIPAddress != nil && filter.CheckIfInternal(pair.Dst) && filter.FilterConnPair(pair.Src, fqdnAsIPAddress))
+		} else {
+			excluded = filter.FilterDomain(pair.FQDN) || filter.FilterConnPair(pair.Src, pair.Dst) ||
+				filter.FilterSNIPair(pair.Src) || filter.FilterInternalDomainPair(pair.Src, pair.FQDN)
+		}
+
+		if excluded {
+			filtered = append(filtered, pair)
+		}
+	}
+	return filtered
+}
+
+// FilteredDNSPairs returns the stored DNS query pairs that the filter excludes
+func FilteredDNSPairs(filter *config.Filter, pairs []database.StoredPair) []database.StoredPair {
+	var filtered []database.StoredPair
+	for _, pair := range pairs {
+		if filter.FilterDomain(pair.FQDN) || filter.FilterDNSPair(pair.Src, pair.Dst) || filter.FilterInternalDomainPair(pair.Src, pair.FQDN) {
+			filtered = append(filtered, pair)
+		}
+	}
+	return filtered
+}
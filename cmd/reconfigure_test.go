@@ -0,0 +1,58 @@
+package cmd_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilteredStoredPairs(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(afs, "config.hjson", []byte(`{
+		filtering: {
+			internal_subnets: ["10.0.0.0/8"],
+			never_included_subnets: ["10.0.0.66/32"],
+			never_included_domains: ["ignored.example.com"],
+			internal_domains: ["*.corp.example.com"],
+		}
+	}`), 0o644))
+	cfg, err := config.ReadFileConfig(afs, "config.hjson")
+	require.NoError(t, err)
+
+	t.Run("Conn Pairs", func(t *testing.T) {
+		kept := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("203.0.113.5")}
+		excludedHost := database.StoredPair{Src: net.ParseIP("10.0.0.66"), Dst: net.ParseIP("203.0.113.5")}
+		// 172.16.0.0/12 is no longer internal, so this pair is external to external
+		noLongerInternal := database.StoredPair{Src: net.ParseIP("172.16.0.1"), Dst: net.ParseIP("203.0.113.5")}
+
+		filtered := cmd.FilteredConnPairs(&cfg.Filter, []database.StoredPair{kept, excludedHost, noLongerInternal})
+		require.ElementsMatch(t, []database.StoredPair{excludedHost, noLongerInternal}, filtered)
+	})
+
+	t.Run("SNI Pairs", func(t *testing.T) {
+		kept := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("203.0.113.5"), FQDN: "www.example.com"}
+		excludedDomain := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("203.0.113.5"), FQDN: "ignored.example.com"}
+		internalDomain := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("203.0.113.9"), FQDN: "app.corp.example.com", HTTP: true}
+		externalSrc := database.StoredPair{Src: net.ParseIP("198.51.100.1"), Dst: net.ParseIP("10.0.0.1"), FQDN: "www.example.com"}
+		// the internal proxy isn't the destination, so it doesn't filter the connection
+		proxied := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("10.0.0.2"), FQDN: "www.example.com", HTTP: true, Proxy: true}
+
+		filtered := cmd.FilteredSNIPairs(&cfg.Filter, []database.StoredPair{kept, excludedDomain, internalDomain, externalSrc, proxied})
+		require.ElementsMatch(t, []database.StoredPair{excludedDomain, internalDomain, externalSrc}, filtered)
+	})
+
+	t.Run("DNS Pairs", func(t *testing.T) {
+		kept := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("10.0.0.53"), FQDN: "www.example.com"}
+		excludedDomain := database.StoredPair{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("10.0.0.53"), FQDN: "ignored.example.com"}
+		excludedHost := database.StoredPair{Src: net.ParseIP("10.0.0.66"), Dst: net.ParseIP("10.0.0.53"), FQDN: "www.example.com"}
+
+		filtered := cmd.FilteredDNSPairs(&cfg.Filter, []database.StoredPair{kept, excludedDomain, excludedHost})
+		require.ElementsMatch(t, []database.StoredPair{excludedDomain, excludedHost}, filtered)
+	})
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	zlog "github.com/activecm/rita/v5/logger"
+	m "github.com/activecm/rita/v5/modifier"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrInvalidRefreshModifier = errors.New("modifier can't be refreshed, must be one of: prevalence, first_seen")
+
+// the modifiers that refresh-modifiers can recompute
+const (
+	refreshPrevalence = "prevalence"
+	refreshFirstSeen  = "first_seen"
+)
+
+var RefreshModifiersCommand = &cli.Command{
+	Name:        "refresh-modifiers",
+	Usage:       "recompute the prevalence and first seen modifiers of an imported dataset",
+	UsageText:   "rita refresh-modifiers --database NAME [--modifiers prevalence,first_seen]",
+	Description: "scores the prevalence and first seen of the existing results again against the data currently in the dataset, without re-running analysis. Every other score of the results is kept",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to refresh",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.StringSliceFlag{
+			Name:  "modifiers",
+			Usage: "modifiers to recompute, ex: prevalence,first_seen",
+			Value: cli.NewStringSlice(refreshPrevalence, refreshFirstSeen),
+			Action: func(_ *cli.Context, modifiers []string) error {
+				_, _, err := ParseRefreshModifiers(modifiers)
+				return err
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		prevalence, firstSeen, err := ParseRefreshModifiers(cCtx.StringSlice("modifiers"))
+		if err != nil {
+			return err
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the refresh-modifiers command
+		if err := runRefreshModifiersCmd(time.Now(), cfg, cCtx.String("database"), prevalence, firstSeen); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+// ParseRefreshModifiers returns whether the prevalence and first seen modifiers were listed, erroring on any other
+// modifier
+func ParseRefreshModifiers(modifiers []string) (bool, bool, error) {
+	var prevalence, firstSeen bool
+	for _, modifier := range modifiers {
+		switch strings.TrimSpace(modifier) {
+		case refreshPrevalence:
+			prevalence = true
+		case refreshFirstSeen:
+			firstSeen = true
+		default:
+			return false, false, fmt.Errorf("%w: %q", ErrInvalidRefreshModifier, modifier)
+		}
+	}
+	return prevalence, firstSeen, nil
+}
+
+func runRefreshModifiersCmd(startTime time.Time, cfg *config.Config, dbName string, prevalence bool, firstSeen bool) error {
+	logger := zlog.GetLogger()
+
+	// connect to database
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	// make sure the dataset has finished at least one import
+	analyzed, err := db.HasFinishedImport()
+	if err != nil {
+		return err
+	}
+	if !analyzed {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotAnalyzed, dbName)
+	}
+
+	logger.Info().Str("dataset", dbName).Bool("prevalence", prevalence).Bool("first_seen", firstSeen).Msg("Refreshing modifiers...")
+
+	refreshed, err := m.RefreshModifiers(db, cfg, prevalence, firstSeen)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Int("results", refreshed).Str("elapsed_time", fmt.Sprintf("%1.1fs", time.Since(startTime).Seconds())).Msg("🎊✨ Finished Refreshing Modifiers! ✨🎊")
+
+	return nil
+}
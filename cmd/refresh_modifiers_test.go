@@ -0,0 +1,54 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRefreshModifiers(t *testing.T) {
+	tests := []struct {
+		name               string
+		modifiers          []string
+		expectedPrevalence bool
+		expectedFirstSeen  bool
+		expectedError      error
+	}{
+		{
+			name:               "Both",
+			modifiers:          []string{"prevalence", "first_seen"},
+			expectedPrevalence: true,
+			expectedFirstSeen:  true,
+		},
+		{
+			name:               "Prevalence",
+			modifiers:          []string{"prevalence"},
+			expectedPrevalence: true,
+		},
+		{
+			name:              "First Seen With Spaces",
+			modifiers:         []string{" first_seen "},
+			expectedFirstSeen: true,
+		},
+		{
+			name:          "Unknown Modifier",
+			modifiers:     []string{"prevalence", "beacon"},
+			expectedError: cmd.ErrInvalidRefreshModifier,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prevalence, firstSeen, err := cmd.ParseRefreshModifiers(test.modifiers)
+			if test.expectedError != nil {
+				require.ErrorIs(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedPrevalence, prevalence)
+			require.Equal(t, test.expectedFirstSeen, firstSeen)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var ErrInvalidImportID = errors.New("import id must be a 32 character hex string")
+
+var ReportCommand = &cli.Command{
+	Name:        "report",
+	Usage:       "print the summary of an import",
+	UsageText:   "rita report --database NAME [--import-id ID]",
+	Description: "prints the saved summary of an import of a dataset, defaults to the most recent import",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to report on",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "import-id",
+			Aliases:  []string{"i"},
+			Usage:    "id of the import to report on, defaults to the most recent import",
+			Required: false,
+			Action: func(_ *cli.Context, id string) error {
+				return ValidateImportID(id)
+			},
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the report command
+		if err := runReportCmd(cfg, cCtx.String("database"), cCtx.String("import-id")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runReportCmd(cfg *config.Config, dbName string, importID string) error {
+	// connect to server
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	summary, err := server.GetImportSummary(dbName, importID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(FormatImportSummary(summary))
+	return nil
+}
+
+// ValidateImportID checks that the given import id is a valid hex encoded import id
+func ValidateImportID(id string) error {
+	if len(id) != 32 {
+		return ErrInvalidImportID
+	}
+	if _, err := util.NewFixedStringFromHex(id); err != nil {
+		return ErrInvalidImportID
+	}
+	return nil
+}
+
+// FormatImportSummary formats an import summary for printing
+func FormatImportSummary(summary database.ImportSummary) string {
+	// create formatter for adding commas in the counts
+	p := message.NewPrinter(language.English)
+
+	var b strings.Builder
+	b.WriteString("Import Summary\n")
+	fmt.Fprintf(&b, "  Dataset:       %s\n", summary.Database)
+	fmt.Fprintf(&b, "  Import ID:     %s\n", summary.ImportID.Hex())
+	fmt.Fprintf(&b, "  Imported At:   %s\n", summary.CreatedAt.UTC().Format("2006-01-02 15:04:05 UTC"))
+	fmt.Fprintf(&b, "  Elapsed Time:  %s\n", summary.ElapsedTime.Round(time.Second))
+	b.WriteString(p.Sprintf("  Walk Errors:   %d\n", summary.WalkErrors))
+	b.WriteString(p.Sprintf("  Parse Errors:  %d\n", summary.ParseErrors))
+
+	b.WriteString("\nFiles by Type\n")
+	b.WriteString(formatCounts(p, summary.Files))
+
+	b.WriteString("\nRecords Inserted\n")
+	b.WriteString(formatCounts(p, summary.Records))
+
+	b.WriteString("\nTop Beacons\n")
+	if len(summary.TopBeacons) == 0 {
+		b.WriteString("  none\n")
+	}
+	for i, beacon := range summary.TopBeacons {
+		// dns beacons don't have a source or destination, and sni beacons are identified by their fqdn
+		dst := beacon.Dst
+		if beacon.FQDN != "" {
+			dst = beacon.FQDN
+		}
+		if beacon.Src == "::" {
+			fmt.Fprintf(&b, "  %2d. %.3f  %s\n", i+1, beacon.Score, dst)
+			continue
+		}
+		fmt.Fprintf(&b, "  %2d. %.3f  %s -> %s\n", i+1, beacon.Score, beacon.Src, dst)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatCounts formats a map of counts as one line per key, sorted by key, skipping empty counts
+func formatCounts(p *message.Printer, counts map[string]uint64) string {
+	keys := make([]string, 0, len(counts))
+	for key, count := range counts {
+		if count > 0 {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	if len(keys) == 0 {
+		return "  none\n"
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(p.Sprintf("  %-10s %d\n", key+":", counts[key]))
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package cmd_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateImportID(t *testing.T) {
+	require.NoError(t, cmd.ValidateImportID("0123456789ABCDEF0123456789abcdef"))
+	require.ErrorIs(t, cmd.ValidateImportID(""), cmd.ErrInvalidImportID)
+	require.ErrorIs(t, cmd.ValidateImportID("0123456789ABCDEF"), cmd.ErrInvalidImportID)
+	require.ErrorIs(t, cmd.ValidateImportID("0123456789ABCDEF0123456789ABCDEG"), cmd.ErrInvalidImportID)
+}
+
+func TestFormatImportSummary(t *testing.T) {
+	importID, err := util.NewFixedStringFromHex("0123456789ABCDEF0123456789ABCDEF")
+	require.NoError(t, err)
+
+	summary := database.ImportSummary{
+		ImportID:    importID,
+		Database:    "dnscat",
+		CreatedAt:   time.Date(2024, 4, 19, 12, 30, 0, 0, time.UTC),
+		ElapsedTime: 95*time.Second + 400*time.Millisecond,
+		Files:       map[string]uint64{"dns": 2, "conn": 3, "http": 0},
+		Records:     map[string]uint64{"conn": 123456, "dns": 42},
+		WalkErrors:  1,
+		ParseErrors: 2,
+		TopBeacons: []database.SummaryBeacon{
+			{Src: "10.0.0.1", Dst: "1.1.1.1", Score: 0.987},
+			{Src: "10.0.0.2", Dst: "::", FQDN: "example.com", Score: 0.9},
+			{Src: "::", Dst: "::", FQDN: "tunnel.example.com", Score: 0.5},
+		},
+	}
+
+	output := cmd.FormatImportSummary(summary)
+
+	require.Contains(t, output, "Dataset:       dnscat")
+	require.Contains(t, output, "Import ID:     0123456789ABCDEF0123456789ABCDEF")
+	require.Contains(t, output, "Imported At:   2024-04-19 12:30:00 UTC")
+	require.Contains(t, output, "Elapsed Time:  1m35s")
+	require.Contains(t, output, "Walk Errors:   1")
+	require.Contains(t, output, "Parse Errors:  2")
+
+	// counts are sorted by log type, formatted with commas, and empty counts are skipped
+	files := output[strings.Index(output, "Files by Type"):strings.Index(output, "Records Inserted")]
+	require.Equal(t, "Files by Type\n  conn:      3\n  dns:       2\n\n", files)
+	require.Contains(t, output, "  conn:      123,456\n")
+
+	require.Contains(t, output, " 1. 0.987  10.0.0.1 -> 1.1.1.1\n")
+	require.Contains(t, output, " 2. 0.900  10.0.0.2 -> example.com\n")
+	require.True(t, strings.HasSuffix(output, " 3. 0.500  tunnel.example.com"), "dns beacons should only show the fqdn")
+
+	// summaries without beacons still print every section
+	output = cmd.FormatImportSummary(database.ImportSummary{ImportID: importID, Database: "empty"})
+	require.Contains(t, output, "Files by Type\n  none\n")
+	require.Contains(t, output, "Top Beacons\n  none")
+}
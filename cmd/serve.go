@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrInvalidMinScore = errors.New("min_score must be a number between 0 and 1")
+var ErrInvalidIncludeAllowlisted = errors.New("include_allowlisted must be true or false")
+var ErrInvalidAnnotated = errors.New("annotated must be true or false")
+var ErrInvalidHostIP = errors.New("host must be a valid IP address")
+var ErrDatabaseUnavailable = errors.New("unable to connect to ClickHouse")
+
+var ServeCommand = &cli.Command{
+	Name:        "serve",
+	Usage:       "serve analysis results over a read-only HTTP API",
+	UsageText:   "serve --listen <address>",
+	Description: "exposes read-only JSON endpoints for listing datasets and querying their results",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "listen",
+			Aliases:  []string{"l"},
+			Usage:    "address for the HTTP server to listen on",
+			Value:    ":8080",
+			Required: false,
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// set up file system interface
+		afs := afero.NewOsFs()
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// check for updates before serving, since the server runs until it is stopped
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		// run the serve command
+		return runServeCmd(cfg, cCtx.String("listen"))
+	},
+}
+
+func runServeCmd(cfg *config.Config, listen string) error {
+	logger := zlog.GetLogger()
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           NewAPIHandler(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	logger.Info().Str("address", listen).Msg("Serving RITA API")
+	fmt.Printf("Serving RITA API on %s\n", listen)
+
+	return server.ListenAndServe()
+}
+
+// APIResult is a single threat mixtape result returned by the HTTP API
+type APIResult struct {
+	Src              string              `json:"src"`
+	Dst              string              `json:"dst"`
+	FQDN             string              `json:"fqdn"`
+	Severity         string              `json:"severity"`
+	FinalScore       float32             `json:"final_score"`
+	CurrentScore     float32             `json:"current_score"` // final score decayed by the time since it was last seen, see score_decay_half_life_hours
+	LastSeen         time.Time           `json:"last_seen"`
+	BeaconScore      float32             `json:"beacon_score"`
+	BeaconComponents BeaconComponents    `json:"beacon_components"`
+	BeaconIntervals  BeaconIntervals     `json:"beacon_intervals"`
+	Strobe           bool                `json:"strobe"`
+	Count            uint64              `json:"count"`
+	TotalDuration    float32             `json:"total_duration"`
+	TotalBytes       uint64              `json:"total_bytes"`
+	PortProtoService []string            `json:"port_proto_service"`
+	Prevalence       float32             `json:"prevalence"`
+	FirstSeen        time.Time           `json:"first_seen"`
+	ThreatIntel      bool                `json:"threat_intel"`
+	Subdomains       uint64              `json:"subdomains"`
+	Modifiers        []map[string]string `json:"modifiers"`
+	Sensor           string              `json:"sensor"`
+	Allowlisted      bool                `json:"allowlisted"`
+	Country          string              `json:"country,omitempty"`
+	ASN              uint32              `json:"asn,omitempty"`
+	ASOrg            string              `json:"as_org,omitempty"`
+	Hash             string              `json:"hash"`
+	Annotation       string              `json:"annotation,omitempty"`
+	AnnotationNote   string              `json:"annotation_note,omitempty"`
+}
+
+// BeaconComponents are the subscores that were weighted to produce the beacon score
+type BeaconComponents struct {
+	Timestamp float32 `json:"timestamp"`
+	DataSize  float32 `json:"data_size"`
+	Duration  float32 `json:"duration"`
+	Histogram float32 `json:"histogram"`
+}
+
+// BeaconIntervals are the percentiles of the seconds between connections, which describe the cadence of the beacon
+type BeaconIntervals struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+}
+
+// HostResults contains the results in which a host was the source or the destination
+type HostResults struct {
+	IP          string      `json:"ip"`
+	Source      []APIResult `json:"source"`
+	Destination []APIResult `json:"destination"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiHandler struct {
+	cfg *config.Config
+	// results returns the threat mixtape results of a dataset that match a filter, it is replaced in tests
+	results func(ctx context.Context, dbName string, filter *viewer.Filter) ([]*viewer.Item, int, error)
+}
+
+// NewAPIHandler returns the handler for the read-only HTTP API
+func NewAPIHandler(cfg *config.Config) http.Handler {
+	h := &apiHandler{cfg: cfg}
+	h.results = h.queryResults
+	return h.routes()
+}
+
+// routes returns the router for the endpoints of the API
+func (h *apiHandler) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /databases", h.listDatabases)
+	mux.HandleFunc("GET /databases/{name}/beacons", h.getBeacons)
+	mux.HandleFunc("GET /databases/{name}/hosts/{ip}", h.getHost)
+
+	return mux
+}
+
+// queryContext returns a context that expires after the configured max query execution time
+func (h *apiHandler) queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), time.Duration(h.cfg.MaxQueryExecutionTime)*time.Second)
+}
+
+func (h *apiHandler) listDatabases(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	server, err := database.ConnectToServer(ctx, h.cfg)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, ErrDatabaseUnavailable)
+		return
+	}
+	defer server.Close()
+
+	dbs, err := server.ListImportDatabases()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if dbs == nil {
+		dbs = []database.ImportDatabase{}
+	}
+
+	writeJSON(w, http.StatusOK, dbs)
+}
+
+func (h *apiHandler) getBeacons(w http.ResponseWriter, r *http.Request) {
+	// default to returning every beacon
+	minScore := 0.0
+	if value := r.URL.Query().Get("min_score"); value != "" {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 1 {
+			writeAPIError(w, http.StatusBadRequest, ErrInvalidMinScore)
+			return
+		}
+		minScore = score
+	}
+
+	// leave out beacons to destinations on the beacon allowlist unless they were requested
+	includeAllowlisted := false
+	if value := r.URL.Query().Get("include_allowlisted"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, ErrInvalidIncludeAllowlisted)
+			return
+		}
+		includeAllowlisted = include
+	}
+
+	// only return beacons that an analyst has annotated if requested
+	annotated := false
+	if value := r.URL.Query().Get("annotated"); value != "" {
+		only, err := strconv.ParseBool(value)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, ErrInvalidAnnotated)
+			return
+		}
+		annotated = only
+	}
+
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	items, status, err := h.results(ctx, r.PathValue("name"), &viewer.Filter{
+		// pass the score through as it was parsed so that it isn't rounded to fewer digits than were requested
+		Beacon:             viewer.OperatorFilter{Operator: ">=", Value: strconv.FormatFloat(minScore, 'f', -1, 64)},
+		SortBeacon:         "DESC",
+		ExcludeAllowlisted: !includeAllowlisted,
+		Annotated:          annotated,
+	})
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+
+	beacons := []APIResult{}
+	for _, item := range items {
+		// skip results that weren't scored as beacons
+		if item.BeaconScore <= 0 && item.StrobeScore <= 0 {
+			continue
+		}
+		beacons = append(beacons, newAPIResult(item))
+	}
+
+	writeJSON(w, http.StatusOK, beacons)
+}
+
+func (h *apiHandler) getHost(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(r.PathValue("ip"))
+	if ip == nil {
+		writeAPIError(w, http.StatusBadRequest, ErrInvalidHostIP)
+		return
+	}
+
+	ctx, cancel := h.queryContext(r)
+	defer cancel()
+
+	name := r.PathValue("name")
+
+	srcItems, status, err := h.results(ctx, name, &viewer.Filter{Src: ip.String()})
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+
+	dstItems, status, err := h.results(ctx, name, &viewer.Filter{Dst: ip.String()})
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+
+	results := HostResults{IP: ip.String(), Source: []APIResult{}, Destination: []APIResult{}}
+	for _, item := range srcItems {
+		results.Source = append(results.Source, newAPIResult(item))
+	}
+	for _, item := range dstItems {
+		results.Destination = append(results.Destination, newAPIResult(item))
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// queryResults returns the threat mixtape results for the dataset that match the filter, along with
+// the HTTP status code to respond with if an error occurred
+func (h *apiHandler) queryResults(ctx context.Context, dbName string, filter *viewer.Filter) ([]*viewer.Item, int, error) {
+	if err := ValidateDatabaseName(dbName); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	server, err := database.ConnectToServer(ctx, h.cfg)
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, ErrDatabaseUnavailable
+	}
+	defer server.Close()
+
+	// make sure the dataset exists before connecting to it
+	exists, err := database.SensorDatabaseExists(ctx, server.Conn, server.GetMetaDatabase(), dbName)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if !exists {
+		return nil, http.StatusNotFound, fmt.Errorf("%w: %s", ErrDatabaseNotFound, dbName)
+	}
+
+	db, err := database.ConnectToDB(ctx, dbName, h.cfg, nil)
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, ErrDatabaseUnavailable
+	}
+	defer db.Close()
+
+	minTimestamp, _, _, _, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, http.StatusNotFound, fmt.Errorf("%w: %s", ErrDatabaseNotFound, dbName)
+		}
+		return nil, http.StatusInternalServerError, err
+	}
+
+	results, _, err := viewer.GetResults(db, filter, 0, math.MaxInt32, minTimestamp)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	items := make([]*viewer.Item, 0, len(results))
+	for _, result := range results {
+		if item, ok := result.(*viewer.Item); ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, http.StatusOK, nil
+}
+
+func newAPIResult(item *viewer.Item) APIResult {
+	return APIResult{
+		Src:          item.GetSrc(),
+		Dst:          item.GetDst(),
+		FQDN:         item.FQDN,
+		Severity:     item.GetSeverity(false),
+		FinalScore:   item.FinalScore,
+		CurrentScore: item.CurrentScore,
+		LastSeen:     item.LastSeen,
+		BeaconScore:  item.BeaconScore,
+		BeaconComponents: BeaconComponents{
+			Timestamp: item.BeaconTSScore,
+			DataSize:  item.BeaconDSScore,
+			Duration:  item.BeaconDurScore,
+			Histogram: item.BeaconHistScore,
+		},
+		BeaconIntervals: BeaconIntervals{
+			P50: item.BeaconIntervalP50,
+			P90: item.BeaconIntervalP90,
+			P99: item.BeaconIntervalP99,
+		},
+		Strobe:           item.StrobeScore > 0,
+		Count:            item.Count,
+		TotalDuration:    item.TotalDuration,
+		TotalBytes:       item.TotalBytes,
+		PortProtoService: item.PortProtoService,
+		Prevalence:       item.Prevalence,
+		FirstSeen:        item.FirstSeen,
+		ThreatIntel:      item.ThreatIntelScore > 0,
+		Subdomains:       item.Subdomains,
+		Modifiers:        item.Modifiers,
+		Sensor:           item.Sensor,
+		Allowlisted:      item.Allowlisted,
+		Country:          item.DstCountry,
+		ASN:              item.DstASN,
+		ASOrg:            item.DstASOrg,
+		Hash:             item.Hash,
+		Annotation:       item.AnnotationStatus,
+		AnnotationNote:   item.AnnotationNote,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger := zlog.GetLogger()
+		logger.Err(err).Msg("failed to write API response")
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
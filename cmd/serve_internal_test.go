@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/viewer"
+)
+
+// NewAPIHandlerWithResults returns the API handler with the results of each query coming from results instead of
+// ClickHouse, so that the filters the handlers build can be checked
+func NewAPIHandlerWithResults(cfg *config.Config, results func(ctx context.Context, dbName string, filter *viewer.Filter) ([]*viewer.Item, int, error)) http.Handler {
+	h := &apiHandler{cfg: cfg, results: results}
+	return h.routes()
+}
@@ -0,0 +1,166 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIHandler(t *testing.T) {
+	// point the config at an address that nothing is listening on
+	t.Setenv("DB_ADDRESS", "127.0.0.1:1")
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+	cfg.MaxQueryExecutionTime = 5
+
+	handler := cmd.NewAPIHandler(&cfg)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "List Databases Without ClickHouse",
+			method:         http.MethodGet,
+			path:           "/databases",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  cmd.ErrDatabaseUnavailable.Error(),
+		},
+		{
+			name:           "Beacons Without ClickHouse",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/beacons?min_score=0.5",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  cmd.ErrDatabaseUnavailable.Error(),
+		},
+		{
+			name:           "Beacons With Non-Numeric Min Score",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/beacons?min_score=high",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  cmd.ErrInvalidMinScore.Error(),
+		},
+		{
+			name:           "Beacons With Min Score Over 1",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/beacons?min_score=90",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  cmd.ErrInvalidMinScore.Error(),
+		},
+		{
+			name:           "Beacons Including Allowlisted Without ClickHouse",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/beacons?include_allowlisted=true",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  cmd.ErrDatabaseUnavailable.Error(),
+		},
+		{
+			name:           "Beacons With Invalid Include Allowlisted",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/beacons?include_allowlisted=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  cmd.ErrInvalidIncludeAllowlisted.Error(),
+		},
+		{
+			name:           "Beacons With Invalid Annotated",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/beacons?annotated=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  cmd.ErrInvalidAnnotated.Error(),
+		},
+		{
+			name:           "Beacons With Invalid Database Name",
+			method:         http.MethodGet,
+			path:           "/databases/My-Dataset/beacons",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Host With Invalid IP",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/hosts/not-an-ip",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  cmd.ErrInvalidHostIP.Error(),
+		},
+		{
+			name:           "Host Without ClickHouse",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/hosts/10.0.0.1",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  cmd.ErrDatabaseUnavailable.Error(),
+		},
+		{
+			name:           "Write Methods Not Allowed",
+			method:         http.MethodDelete,
+			path:           "/databases",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Unknown Endpoint",
+			method:         http.MethodGet,
+			path:           "/databases/mydataset/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+
+			require.Equal(t, test.expectedStatus, rec.Code, "status code should match expected value")
+
+			if test.expectedError != "" {
+				require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+				var body map[string]string
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				require.Equal(t, test.expectedError, body["error"], "error message should match expected value")
+			}
+		})
+	}
+}
+
+func TestAPIHandlerBeaconsMinScore(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	var filter *viewer.Filter
+	handler := cmd.NewAPIHandlerWithResults(&cfg, func(_ context.Context, _ string, f *viewer.Filter) ([]*viewer.Item, int, error) {
+		filter = f
+		return nil, http.StatusOK, nil
+	})
+
+	tests := []struct {
+		minScore      string
+		expectedValue string
+	}{
+		{minScore: "", expectedValue: "0"},
+		{minScore: "0.5", expectedValue: "0.5"},
+		{minScore: "0.555", expectedValue: "0.555"},
+		{minScore: "0.004", expectedValue: "0.004"},
+		{minScore: "1", expectedValue: "1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.minScore, func(t *testing.T) {
+			filter = nil
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/databases/mydataset/beacons?min_score="+test.minScore, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.NotNil(t, filter, "the results should have been queried")
+			require.Equal(t, ">=", filter.Beacon.Operator)
+			require.Equal(t, test.expectedValue, filter.Beacon.Value, "the min score shouldn't be rounded")
+		})
+	}
+}
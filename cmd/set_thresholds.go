@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrNoThresholdsProvided = errors.New("at least one of --base, --low, --medium, --high, or --reset must be provided")
+var ErrResetWithThresholds = errors.New("--reset cannot be used with --base, --low, --medium, or --high")
+
+var SetThresholdsCommand = &cli.Command{
+	Name:        "set-thresholds",
+	Usage:       "set the beacon score thresholds of a dataset",
+	UsageText:   "rita set-thresholds --database NAME [--base N] [--low N] [--medium N] [--high N] [--reset]",
+	Description: "stores beacon score thresholds for a dataset that are used by its analysis instead of the thresholds in the config file, thresholds that aren't passed keep their value from the config file",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to set the thresholds of",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.IntFlag{
+			Name:  "base",
+			Usage: "minimum beacon score (0-100) to be considered a beacon",
+		},
+		&cli.IntFlag{
+			Name:  "low",
+			Usage: "minimum beacon score (0-100) to be a low severity beacon",
+		},
+		&cli.IntFlag{
+			Name:  "medium",
+			Usage: "minimum beacon score (0-100) to be a medium severity beacon",
+		},
+		&cli.IntFlag{
+			Name:  "high",
+			Usage: "minimum beacon score (0-100) to be a high severity beacon",
+		},
+		&cli.BoolFlag{
+			Name:  "reset",
+			Usage: "remove the thresholds of the dataset so that the thresholds in the config file are used",
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		thresholdSet := cCtx.IsSet("base") || cCtx.IsSet("low") || cCtx.IsSet("medium") || cCtx.IsSet("high")
+		if cCtx.Bool("reset") && thresholdSet {
+			return ErrResetWithThresholds
+		}
+		if !cCtx.Bool("reset") && !thresholdSet {
+			return ErrNoThresholdsProvided
+		}
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afero.NewOsFs(), cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the set-thresholds command
+		if cCtx.Bool("reset") {
+			err = runResetThresholdsCmd(cfg, cCtx.String("database"))
+		} else {
+			// thresholds that weren't passed keep their value from the config file
+			thresholds := cfg.Scoring.Beacon.ScoreThresholds
+			if cCtx.IsSet("base") {
+				thresholds.Base = cCtx.Int("base")
+			}
+			if cCtx.IsSet("low") {
+				thresholds.Low = cCtx.Int("low")
+			}
+			if cCtx.IsSet("medium") {
+				thresholds.Med = cCtx.Int("medium")
+			}
+			if cCtx.IsSet("high") {
+				thresholds.High = cCtx.Int("high")
+			}
+			err = runSetThresholdsCmd(cfg, cCtx.String("database"), thresholds)
+		}
+		if err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runSetThresholdsCmd(cfg *config.Config, dbName string, thresholds config.ScoreThresholds) error {
+	// validate the thresholds before connecting to the server
+	if err := config.ValidateBeaconScoreThresholds(thresholds); err != nil {
+		return err
+	}
+
+	// connect to server
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	// make sure the metadatabase tables exist
+	if err := server.CreateServerDBTables(); err != nil {
+		return err
+	}
+
+	if err := server.SetBeaconScoreThresholds(dbName, thresholds); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set the beacon score thresholds of %s to base: %d, low: %d, medium: %d, high: %d.\n", dbName, thresholds.Base, thresholds.Low, thresholds.Med, thresholds.High)
+	fmt.Println("The thresholds will be used by the next import into the dataset.")
+	return nil
+}
+
+func runResetThresholdsCmd(cfg *config.Config, dbName string) error {
+	// connect to server
+	server, err := database.ConnectToServer(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	// make sure the metadatabase tables exist
+	if err := server.CreateServerDBTables(); err != nil {
+		return err
+	}
+
+	if err := server.ClearBeaconScoreThresholds(dbName); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reset the beacon score thresholds of %s to the thresholds in the config file.\n", dbName)
+	return nil
+}
+
+// applyScoreThresholds returns a copy of the config that uses the beacon score thresholds stored for the
+// selected database, or the config itself if no thresholds have been stored for it
+func applyScoreThresholds(db *database.DB, cfg *config.Config) (*config.Config, error) {
+	thresholds, found, err := db.GetBeaconScoreThresholds()
+	if err != nil {
+		return nil, fmt.Errorf("could not get beacon score thresholds of dataset: %w", err)
+	}
+	if !found {
+		return cfg, nil
+	}
+
+	datasetCfg := *cfg
+	datasetCfg.Scoring.Beacon.ScoreThresholds = thresholds
+	return &datasetCfg, nil
+}
@@ -20,6 +20,10 @@ var ErrMissingSearchStdout = errors.New("cannot apply search without --stdout")
 var ErrMissingLimitStdout = errors.New("cannot apply limit without --stdout")
 var ErrInvalidViewLimit = errors.New("limit must be a positive interger greater than 0")
 var ErrDatabaseNotFound = errors.New("database not found")
+var ErrMissingAnonymizeStdout = errors.New("cannot anonymize results without --stdout")
+var ErrMissingAnonymizeMapping = errors.New("cannot write an anonymization mapping file without --anonymize")
+var ErrMissingIncludeAllowlistedStdout = errors.New("cannot include allowlisted results without --stdout")
+var ErrMissingShowAnnotatedStdout = errors.New("cannot show only annotated results without --stdout")
 
 var ViewCommand = &cli.Command{
 	Name:  "view",
@@ -43,6 +47,26 @@ var ViewCommand = &cli.Command{
 			Usage:    "limit the number of results to display",
 			Required: false,
 		},
+		&cli.BoolFlag{
+			Name:     "anonymize",
+			Usage:    "replace internal IPs with pseudonyms derived from the anonymization_salt config setting, only works with --stdout/-o flag",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "anonymize-mapping",
+			Usage:    "path of a CSV file to record the IP of each pseudonym in so that the anonymization can be reversed, only works with --anonymize flag",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "include-allowlisted",
+			Usage:    "include results to destinations on the beacon_allowlist config setting, only works with --stdout/-o flag",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "show-annotated",
+			Usage:    "only show results that have been marked with rita annotate, only works with --stdout/-o flag",
+			Required: false,
+		},
 		ConfigFlag(false),
 	},
 	Action: func(cCtx *cli.Context) error {
@@ -77,6 +101,23 @@ var ViewCommand = &cli.Command{
 			}
 		}
 
+		// validate anonymize flags
+		if cCtx.Bool("anonymize") && !cCtx.Bool("stdout") {
+			return ErrMissingAnonymizeStdout
+		}
+
+		if cCtx.IsSet("anonymize-mapping") && !cCtx.Bool("anonymize") {
+			return ErrMissingAnonymizeMapping
+		}
+
+		if cCtx.Bool("include-allowlisted") && !cCtx.Bool("stdout") {
+			return ErrMissingIncludeAllowlistedStdout
+		}
+
+		if cCtx.Bool("show-annotated") && !cCtx.Bool("stdout") {
+			return ErrMissingShowAnnotatedStdout
+		}
+
 		// set up file system interface
 		afs := afero.NewOsFs()
 
@@ -87,7 +128,7 @@ var ViewCommand = &cli.Command{
 		}
 
 		// run the view command
-		if err := runViewCmd(cfg, cCtx.Args().First(), cCtx.Bool("stdout"), cCtx.String("search"), cCtx.Int("limit")); err != nil {
+		if err := runViewCmd(afs, cfg, cCtx.Args().First(), cCtx.Bool("stdout"), cCtx.String("search"), cCtx.Int("limit"), cCtx.Bool("anonymize"), cCtx.String("anonymize-mapping"), cCtx.Bool("include-allowlisted"), cCtx.Bool("show-annotated")); err != nil {
 			return err
 		}
 
@@ -100,7 +141,17 @@ var ViewCommand = &cli.Command{
 	},
 }
 
-func runViewCmd(cfg *config.Config, dbName string, stdout bool, search string, limit int) error {
+func runViewCmd(afs afero.Fs, cfg *config.Config, dbName string, stdout bool, search string, limit int, anonymize bool, mappingPath string, includeAllowlisted bool, showAnnotated bool) error {
+	// set up the anonymizer before connecting so that a missing salt is reported right away
+	var anonymizer *viewer.Anonymizer
+	if anonymize {
+		var err error
+		anonymizer, err = viewer.NewAnonymizer(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("Viewing database: %s\n", dbName)
 
 	// connect to database
@@ -122,11 +173,18 @@ func runViewCmd(cfg *config.Config, dbName string, stdout bool, search string, l
 	if stdout {
 
 		// get CSV output
-		csvData, err := viewer.GetCSVOutput(db, minTimestamp, util.GetRelativeFirstSeenTimestamp(useCurrentTime, maxTimestamp), search, limit)
+		csvData, err := viewer.GetCSVOutput(db, minTimestamp, util.GetRelativeFirstSeenTimestamp(useCurrentTime, maxTimestamp), search, limit, includeAllowlisted, showAnnotated, anonymizer)
 		if err != nil {
 			return err
 		}
 
+		// record the pseudonyms that were used so that they can be reversed later
+		if mappingPath != "" {
+			if err := anonymizer.WriteMapping(afs, mappingPath); err != nil {
+				return err
+			}
+		}
+
 		// print CSV data to stdout
 		fmt.Println(csvData)
 
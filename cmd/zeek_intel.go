@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/spf13/afero"
+	"github.com/urfave/cli/v2"
+)
+
+var ErrInvalidIntelScore = errors.New("min score must be between 0 and 1")
+
+// Zeek Intel Framework indicator types
+const (
+	ZeekIntelAddr   = "Intel::ADDR"
+	ZeekIntelDomain = "Intel::DOMAIN"
+)
+
+var ZeekIntelCommand = &cli.Command{
+	Name:        "zeek-intel",
+	Usage:       "export the high scoring destinations of a dataset as a Zeek intel file",
+	UsageText:   "rita zeek-intel --database NAME [--min-score SCORE] [--output FILE]",
+	Description: "writes the external IPs and domains of the results with a final score of at least the minimum score in the Zeek Intel Framework format, so that sensors can load them with Intel::read_files",
+	Args:        false,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "database",
+			Aliases:  []string{"d"},
+			Usage:    "dataset to export",
+			Required: true,
+			Action: func(_ *cli.Context, name string) error {
+				return ValidateDatabaseName(name)
+			},
+		},
+		&cli.Float64Flag{
+			Name:     "min-score",
+			Aliases:  []string{"s"},
+			Usage:    "minimum final score (0-1) of the results to export",
+			Value:    config.HIGH_CATEGORY_SCORE,
+			Required: false,
+			Action: func(_ *cli.Context, score float64) error {
+				if score < 0 || score > 1 {
+					return ErrInvalidIntelScore
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:     "output",
+			Aliases:  []string{"o"},
+			Usage:    "path of the intel file to write, defaults to stdout",
+			Required: false,
+		},
+		ConfigFlag(false),
+	},
+	Action: func(cCtx *cli.Context) error {
+		// check if too many arguments were provided
+		if cCtx.NArg() > 0 {
+			return ErrTooManyArguments
+		}
+
+		// set up file system interface
+		afs := afero.NewOsFs()
+
+		// load config file
+		cfg, err := config.ReadFileConfig(afs, cCtx.String("config"))
+		if err != nil {
+			return err
+		}
+
+		// run the zeek intel command
+		if err := runZeekIntelCmd(afs, cfg, cCtx.String("database"), float32(cCtx.Float64("min-score")), cCtx.String("output")); err != nil {
+			return err
+		}
+
+		// check for updates after running the command
+		if err := CheckForUpdate(cfg); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func runZeekIntelCmd(afs afero.Fs, cfg *config.Config, dbName string, minScore float32, outputPath string) error {
+	// connect to database
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	// make sure the dataset has finished at least one import
+	analyzed, err := db.HasFinishedImport()
+	if err != nil {
+		return err
+	}
+	if !analyzed {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotAnalyzed, dbName)
+	}
+
+	minTimestamp, _, _, _, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		return err
+	}
+
+	// known good destinations are never exported so that sensors don't alert on them
+	results, _, err := viewer.GetResults(db, &viewer.Filter{ExcludeAllowlisted: true}, 0, math.MaxInt32, minTimestamp)
+	if err != nil {
+		return err
+	}
+
+	items := make([]*viewer.Item, 0, len(results))
+	for _, result := range results {
+		if item, ok := result.(*viewer.Item); ok {
+			items = append(items, item)
+		}
+	}
+
+	source := "RITA " + dbName
+
+	if outputPath == "" {
+		return WriteZeekIntel(os.Stdout, items, minScore, &cfg.Filter, source)
+	}
+
+	file, err := afs.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := WriteZeekIntel(file, items, minScore, &cfg.Filter, source); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WriteZeekIntel writes the external destinations of the results with a final score of at least minScore to w as a
+// Zeek intel file. Destination IPs are written as Intel::ADDR indicators and domains as Intel::DOMAIN indicators,
+// each indicator is only written once. Internal destinations are left out so that sensors don't flag the local network.
+func WriteZeekIntel(w io.Writer, items []*viewer.Item, minScore float32, filter *config.Filter, source string) error {
+	if _, err := fmt.Fprintln(w, "#fields\tindicator\tindicator_type\tmeta.source"); err != nil {
+		return err
+	}
+
+	written := make(map[string]bool)
+	write := func(indicator, indicatorType string) error {
+		if written[indicator] {
+			return nil
+		}
+		written[indicator] = true
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", indicator, indicatorType, source)
+		return err
+	}
+
+	for _, item := range items {
+		if item.FinalScore < minScore {
+			continue
+		}
+
+		// SNI and C2 over DNS results don't have a destination IP
+		if item.Dst != nil && !item.Dst.IsUnspecified() && !filter.CheckIfInternal(item.Dst) {
+			if err := write(item.Dst.String(), ZeekIntelAddr); err != nil {
+				return err
+			}
+		}
+
+		if item.FQDN != "" && !filter.CheckIfInternalDomain(item.FQDN) {
+			if err := write(item.FQDN, ZeekIntelDomain); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteZeekIntel(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	items := []*viewer.Item{
+		// ip beacon to an external destination
+		{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("93.184.216.34"), FinalScore: 0.9},
+		// sni beacon, which doesn't have a destination IP
+		{Src: net.ParseIP("10.0.0.1"), Dst: net.IPv6unspecified, FQDN: "c2.example.com", FinalScore: 0.85},
+		// the same destination from another source is only written once
+		{Src: net.ParseIP("10.0.0.2"), Dst: net.ParseIP("93.184.216.34"), FinalScore: 0.8},
+		// internal destinations are never exported
+		{Src: net.ParseIP("203.0.113.5"), Dst: net.ParseIP("10.0.0.3"), FinalScore: 0.95},
+		// results under the minimum score are left out
+		{Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("198.51.100.7"), FQDN: "low.example.com", FinalScore: 0.5},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, cmd.WriteZeekIntel(&out, items, 0.8, &cfg.Filter, "RITA test"))
+	require.Equal(t,
+		"#fields\tindicator\tindicator_type\tmeta.source\n"+
+			"93.184.216.34\tIntel::ADDR\tRITA test\n"+
+			"c2.example.com\tIntel::DOMAIN\tRITA test\n",
+		out.String())
+
+	t.Run("Header Without Results", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, cmd.WriteZeekIntel(&out, nil, 0.8, &cfg.Filter, "RITA test"))
+		require.Equal(t, "#fields\tindicator\tindicator_type\tmeta.source\n", out.String(), "the header should be written even without results")
+	})
+}
@@ -3,7 +3,14 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/activecm/rita/v5/util"
 
@@ -15,6 +22,22 @@ var Version string
 
 const DefaultConfigPath = "./config.hjson"
 
+// DefaultMetaDatabase is the name of the database that holds the records shared by every dataset, such as imported
+// files and schema versions, when DB_METADATABASE isn't set
+const DefaultMetaDatabase = "metadatabase"
+
+// bounds of the number of rows that are written to the database in a single batch
+const (
+	MinBatchSize = 25000
+	MaxBatchSize = 2000000
+)
+
+// metaDatabasePattern matches the names that can be used for the metadatabase without quoting
+var metaDatabasePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// hostnamePattern matches RFC 1123 hostnames, which may be a single label such as localhost
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
 var errInvalidImpactCategory = errors.New("invalid impact category: must be 'critical', 'high', 'medium', 'low', or 'none'")
 
 const (
@@ -28,12 +51,65 @@ const (
 	MediumThreat   ImpactCategory = "medium"
 	LowThreat      ImpactCategory = "low"
 	NoneThreat     ImpactCategory = "none"
+
+	// directions of the data sizes that are used for the beacon data size score
+	// send is the default since it keeps the scores from before the direction could be chosen, while combined keeps
+	// the higher of the send and receive scores and can raise them
+	DataSizeDirectionSend     = "send"
+	DataSizeDirectionReceive  = "receive"
+	DataSizeDirectionCombined = "combined"
 )
 
 type (
 	ThreatIntel struct {
 		OnlineFeeds          []string `json:"online_feeds"`
+		FeedCacheDirectory   string   `json:"feed_cache_directory"` // online feeds are downloaded in full every import when empty
 		CustomFeedsDirectory string   `json:"custom_feeds_directory"`
+		TAXII                TAXII    `json:"taxii"`
+	}
+
+	// TAXII configures polling a TAXII 2.1 collection for threat intel indicators
+	TAXII struct {
+		DiscoveryURL        string `json:"discovery_url"`
+		CollectionID        string `json:"collection_id"`
+		Username            string `json:"username"`
+		Password            string `json:"password"`
+		PollIntervalMinutes int    `json:"poll_interval_minutes"`
+	}
+
+	// GeoIP configures optional MaxMind GeoLite2 databases used to annotate external destinations
+	GeoIP struct {
+		CountryDatabasePath string `json:"country_database_path"`
+		ASNDatabasePath     string `json:"asn_database_path"`
+	}
+
+	// ObjectStorage configures reading logs to import from an S3-compatible object store, ex: s3://bucket/prefix
+	ObjectStorage struct {
+		Endpoint        string `json:"endpoint"`          // URL of the store, AWS S3 in Region is used when empty
+		Region          string `json:"region"`            // region that requests are signed for
+		AccessKeyID     string `json:"access_key_id"`     // the AWS_ACCESS_KEY_ID environment variable is used when empty
+		SecretAccessKey string `json:"secret_access_key"` // the AWS_SECRET_ACCESS_KEY environment variable is used when empty
+		UsePathStyle    bool   `json:"use_path_style"`    // address buckets as endpoint/bucket instead of bucket.endpoint
+	}
+
+	// PortProtoService configures how connections are grouped into port:proto:service keys during analysis, so that
+	// equivalent services and ephemeral ports aren't split across separate keys
+	PortProtoService struct {
+		ServiceAliases     map[string]string `json:"service_aliases"`      // zeek service name -> service name it is treated as
+		EphemeralPortStart int               `json:"ephemeral_port_start"` // ports at or above this are collapsed into one key, 0 disables
+	}
+
+	// Streaming configures reading zeek records from a stream instead of from log files
+	Streaming struct {
+		Enabled              bool  `json:"enabled"`
+		FlushIntervalSeconds int   `json:"flush_interval_seconds"`
+		Kafka                Kafka `json:"kafka"`
+	}
+
+	Kafka struct {
+		Brokers []string `json:"brokers"`
+		Topic   string   `json:"topic"`
+		GroupID string   `json:"group_id"`
 	}
 
 	// ScoreThresholds is used for indicators that have prorated (graduated) values rather than
@@ -46,6 +122,12 @@ type (
 		High int `json:"high"`
 	}
 
+	// DurationBounds are the shortest and longest durations, in seconds, that are plausible for an indicator
+	DurationBounds struct {
+		Min float64 `json:"min"`
+		Max float64 `json:"max"`
+	}
+
 	ImpactCategory string
 
 	// ScoreImpact is used for indicators that have a binary outcomes but still need to express the
@@ -60,11 +142,19 @@ type (
 
 		LongConnectionScoreThresholds ScoreThresholds `json:"long_connection_score_thresholds"`
 
+		// total connection durations outside of these bounds are treated as artifacts, such as clock skew, rather than
+		// long connections. A Max of 0 uses the span of the dataset.
+		LongConnectionDurationBounds DurationBounds `json:"long_connection_duration_bounds"`
+
 		C2ScoreThresholds ScoreThresholds `json:"c2_score_thresholds"`
 
 		StrobeImpact ScoreImpact `json:"strobe_impact"`
 
 		ThreatIntelImpact ScoreImpact `json:"threat_intel_impact"`
+
+		// the current score of a result in a rolling dataset is halved for every this many hours that it went unseen
+		// before the newest log in the dataset, 0 disables the decay. The score of the result itself is kept.
+		ScoreDecayHalfLifeHours float64 `json:"score_decay_half_life_hours"`
 	}
 
 	Modifiers struct {
@@ -80,6 +170,7 @@ type (
 		FirstSeenIncreaseThreshold float32 `json:"first_seen_increase_threshold"`
 		FirstSeenScoreDecrease     float32 `json:"first_seen_score_decrease"`
 		FirstSeenDecreaseThreshold float32 `json:"first_seen_decrease_threshold"`
+		FirstSeenGraceHours        float32 `json:"first_seen_grace_hours"`
 
 		MissingHostCountScoreIncrease float32 `json:"missing_host_count_score_increase"`
 
@@ -88,41 +179,147 @@ type (
 		C2OverDNSDirectConnScoreIncrease float32 `json:"c2_over_dns_direct_conn_score_increase"`
 
 		MIMETypeMismatchScoreIncrease float32 `json:"mime_type_mismatch_score_increase"`
+
+		RDPFanOutScoreIncrease float32 `json:"rdp_fan_out_score_increase"`
+		RDPFanOutThreshold     int64   `json:"rdp_fan_out_threshold"`
+
+		FailedConnScoreIncrease float32 `json:"failed_conn_score_increase"`
+		FailedConnThreshold     float32 `json:"failed_conn_threshold"`
+
+		DGANXDomainScoreIncrease float32 `json:"dga_nxdomain_score_increase"`
+		DGANXDomainThreshold     float32 `json:"dga_nxdomain_threshold"`
+		DGANXDomainMinQueries    int64   `json:"dga_nxdomain_min_queries"`
+
+		DNSSubdomainEntropyScoreIncrease float32 `json:"dns_subdomain_entropy_score_increase"`
+		DNSSubdomainEntropyThreshold     float32 `json:"dns_subdomain_entropy_threshold"`
+
+		LongConnSustainedScoreIncrease float32 `json:"long_conn_sustained_score_increase"`
+		LongConnSustainedThreshold     float32 `json:"long_conn_sustained_threshold"`
+
+		FTPUploadVolumeScoreIncrease float32 `json:"ftp_upload_volume_score_increase"`
+		FTPUploadVolumeThreshold     int64   `json:"ftp_upload_volume_threshold"`
+
+		FTPScriptedUploadScoreIncrease float32 `json:"ftp_scripted_upload_score_increase"`
+		FTPScriptedUploadMinUploads    int64   `json:"ftp_scripted_upload_min_uploads"`
+
+		SuspiciousCertScoreIncrease float32 `json:"suspicious_cert_score_increase"`
+
+		KerberosAnomalyScoreIncrease float32 `json:"kerberos_anomaly_score_increase"`
+		KerberosFailureThreshold     int64   `json:"kerberos_failure_threshold"`
+
+		NTLMAnomalyScoreIncrease  float32 `json:"ntlm_anomaly_score_increase"`
+		NTLMDistinctUserThreshold int64   `json:"ntlm_distinct_user_threshold"`
+		NTLMDistinctHostThreshold int64   `json:"ntlm_distinct_host_threshold"`
+
+		DNSFloodScoreIncrease float32 `json:"dns_flood_score_increase"`
+
+		CNAMEChainScoreIncrease  float32 `json:"cname_chain_score_increase"`
+		CNAMEChainDepthThreshold int64   `json:"cname_chain_depth_threshold"`
+
+		AdaptiveBeaconScoreIncrease float32 `json:"adaptive_beacon_score_increase"`
+		AdaptiveBeaconSegments      int64   `json:"adaptive_beacon_segments"`
+		AdaptiveBeaconIntervalRatio float32 `json:"adaptive_beacon_interval_ratio"`
+	}
+
+	// BeaconTypeThresholds overrides a beacon setting for a specific beacon type, a value of 0 uses the default
+	BeaconTypeThresholds struct {
+		IP  int64 `json:"ip"`
+		SNI int64 `json:"sni"`
+		DNS int64 `json:"dns"` // DNS entries are only scored for C2 over DNS, so this must be 0
+	}
+
+	// BeaconTypeDurations overrides a beacon duration in hours for a specific beacon type, a value of 0 uses the default
+	BeaconTypeDurations struct {
+		IP  float64 `json:"ip"`
+		SNI float64 `json:"sni"`
+	}
+
+	// DistributedBeacons configures scoring all of a source's outbound connections on the same port, protocol, and
+	// service as one beacon, which finds beacons that rotate between a pool of destination IPs
+	DistributedBeacons struct {
+		Enabled         bool  `json:"enabled"`
+		MinDestinations int64 `json:"min_destinations"`
+		MaxDestinations int64 `json:"max_destinations"` // 0 disables the limit
 	}
 
 	Beacon struct {
-		UniqueConnectionThreshold       int64           `json:"unique_connection_threshold"`
-		TsWeight                        float64         `json:"timestamp_score_weight"`
-		DsWeight                        float64         `json:"datasize_score_weight"`
-		DurWeight                       float64         `json:"duration_score_weight"`
-		HistWeight                      float64         `json:"histogram_score_weight"`
-		DurMinHours                     int             `json:"duration_min_hours_seen"`
-		DurIdealNumberOfConsistentHours int             `json:"duration_consistency_ideal_hours_seen"`
-		HistModeSensitivity             float64         `json:"histogram_mode_sensitivity"`
-		HistBimodalOutlierRemoval       int             `json:"histogram_bimodal_outlier_removal"`
-		HistBimodalMinHours             int             `json:"histogram_bimodal_min_hours_seen"`
-		ScoreThresholds                 ScoreThresholds `json:"score_thresholds"`
+		UniqueConnectionThreshold        int64                `json:"unique_connection_threshold"`
+		UniqueConnectionThresholdPerType BeaconTypeThresholds `json:"unique_connection_threshold_per_type"`
+		MinBeaconDurationHours           float64              `json:"min_beacon_duration_hours"`
+		MinBeaconDurationHoursPerType    BeaconTypeDurations  `json:"min_beacon_duration_hours_per_type"`
+		TsWeight                         float64              `json:"timestamp_score_weight"`
+		DsWeight                         float64              `json:"datasize_score_weight"`
+		DsDirection                      string               `json:"datasize_direction"`
+		DsOutlierTrimPercent             float64              `json:"datasize_outlier_trim_percent"` // percentage of the largest and smallest data sizes left out of the score
+		EstimateOpenConnBytes            bool                 `json:"estimate_open_conn_bytes"`
+		ExcludeMissingRespBytes          bool                 `json:"exclude_missing_resp_bytes"`
+		CorrelateDNSResolvedIPs          bool                 `json:"correlate_dns_resolved_ips"`
+		DurWeight                        float64              `json:"duration_score_weight"`
+		HistWeight                       float64              `json:"histogram_score_weight"`
+		DurMinHours                      int                  `json:"duration_min_hours_seen"`
+		DurIdealNumberOfConsistentHours  int                  `json:"duration_consistency_ideal_hours_seen"`
+		HistModeSensitivity              float64              `json:"histogram_mode_sensitivity"`
+		HistBimodalOutlierRemoval        int                  `json:"histogram_bimodal_outlier_removal"`
+		HistBimodalMinHours              int                  `json:"histogram_bimodal_min_hours_seen"`
+		ConsistencyWindowHours           int                  `json:"consistency_window_hours"`
+		TsJitterTolerance                float64              `json:"timestamp_jitter_tolerance"`
+		DistributedBeacons               DistributedBeacons   `json:"distributed_beacons"`
+		SplitByPort                      bool                 `json:"split_by_port"`        // also score IP connections on more than one port per port
+		AggregateSubdomains              bool                 `json:"aggregate_subdomains"` // also score SNI connections to the subdomains of a domain together
+		ScorePrecision                   int                  `json:"score_precision"`
+		ScoreThresholds                  ScoreThresholds      `json:"score_thresholds"`
 	}
 
 	Config struct {
 		DBConnection       string // set by .env file
+		DBReadConnection   string // set by .env file, optional read replica for listing, viewing, and exporting queries
+		MetaDatabase       string // set by .env file, defaults to DefaultMetaDatabase
 		UpdateCheckEnabled bool   `json:"update_check_enabled"`
 		Filter             Filter `json:"filtering"`
 
 		HTTPExtensionsFilePath string `json:"http_extensions_file_path"`
 
 		// writer
-		BatchSize             int `json:"batch_size"`
-		MaxQueryExecutionTime int `json:"max_query_execution_time"`
+		BatchSize                 int `json:"batch_size"`
+		MaxQueryExecutionTime     int `json:"max_query_execution_time"`
+		MaxInFlightBatches        int `json:"max_in_flight_batches"`        // batches inserted at the same time across all writers
+		InsertMaxRetries          int `json:"insert_max_retries"`           // retries of a batch after a transient error, 0 disables retries
+		InsertRetryBackoffSeconds int `json:"insert_retry_backoff_seconds"` // wait before the first retry, doubled for each retry after it
+
+		// analysis
+		AnalysisTimeout int      `json:"analysis_timeout"` // seconds, 0 disables the deadline
+		EnabledModules  []string `json:"enabled_modules"`  // only these modules run when set, see ModuleEnabled
+		DisabledModules []string `json:"disabled_modules"`
+
+		PortProtoService PortProtoService `json:"port_proto_service"`
+
+		// importer
+		MaxImportConcurrency int   `json:"max_import_concurrency"`
+		DeduplicateConnUIDs  bool  `json:"deduplicate_conn_uids"`
+		MaxFQDNsPerSrc       int   `json:"max_fqdns_per_src"`  // 0 disables the limit
+		MaxLogFileBytes      int64 `json:"max_log_file_bytes"` // 0 disables the limit
+
+		// top findings printed at the end of an import
+		TopFindingsLimit    int     `json:"top_findings_limit"` // 0 disables the summary
+		TopFindingsMinScore float32 `json:"top_findings_min_score"`
 
 		// historical first seen
 		MonthsToKeepHistoricalFirstSeen int `json:"months_to_keep_historical_first_seen"`
 
+		// key used to pseudonymize internal IPs when anonymizing results
+		AnonymizationSalt string `json:"anonymization_salt"`
+
 		Scoring Scoring `json:"scoring"`
 
 		Modifiers Modifiers `json:"modifiers"`
 
 		ThreatIntel ThreatIntel `json:"threat_intel"`
+
+		GeoIP GeoIP `json:"geoip"`
+
+		ObjectStorage ObjectStorage `json:"object_storage"`
+
+		Streaming Streaming `json:"streaming"`
 	}
 )
 
@@ -207,6 +404,12 @@ func GetDefaultConfig() (Config, error) {
 	}
 	cfg.DBConnection = connection
 
+	// get the optional read replica connection string
+	cfg.DBReadConnection = os.Getenv("DB_READ_ADDRESS")
+
+	// get the name of the metadatabase, which is changed to keep RITA instances that share a server apart
+	cfg.MetaDatabase = MetaDatabaseName()
+
 	// set up the filter based on default values
 	// (must be done to convert strings in the default config variable to net.IPNet)
 	err := cfg.parseFilter()
@@ -217,6 +420,15 @@ func GetDefaultConfig() (Config, error) {
 	return cfg, nil
 }
 
+// MetaDatabaseName returns the name of the metadatabase set by the DB_METADATABASE environment variable, or
+// DefaultMetaDatabase if it isn't set
+func MetaDatabaseName() string {
+	if name := os.Getenv("DB_METADATABASE"); name != "" {
+		return name
+	}
+	return DefaultMetaDatabase
+}
+
 // readFile reads the config file at the specified path and returns its contents
 func readFile(afs afero.Fs, path string) ([]byte, error) {
 	// validate file
@@ -257,6 +469,20 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("DBConnection cannot be empty")
 	}
 
+	// the read replica is optional, but it must be a host and port like the primary connection
+	if cfg.DBReadConnection != "" && !validHostnamePort(cfg.DBReadConnection) {
+		return fmt.Errorf("DBReadConnection must be in the format hostname:port, got %v", cfg.DBReadConnection)
+	}
+
+	// the metadatabase can't share its name with the databases that belong to ClickHouse
+	if !metaDatabasePattern.MatchString(cfg.MetaDatabase) || slices.Contains([]string{"default", "system", "information_schema", "INFORMATION_SCHEMA"}, cfg.MetaDatabase) {
+		return fmt.Errorf("MetaDatabase must be a database name of up to 63 letters, digits, and underscores that isn't used by ClickHouse, got %v", cfg.MetaDatabase)
+	}
+
+	if cfg.Filter.MinConnectionBytes < 0 {
+		return fmt.Errorf("min_connection_bytes must be at least 0, got %v", cfg.Filter.MinConnectionBytes)
+	}
+
 	// validate that there is at least one internal subnet, or else we cannot do analysis
 	if len(cfg.Filter.InternalSubnets) < 1 {
 		return fmt.Errorf("the list of internal subnets is empty, got %v", cfg.Filter.InternalSubnets)
@@ -267,7 +493,7 @@ func (cfg *Config) verifyConfig() error {
 	}
 
 	// validate the batch size
-	if cfg.BatchSize < 25000 || cfg.BatchSize > 2000000 {
+	if cfg.BatchSize < MinBatchSize || cfg.BatchSize > MaxBatchSize {
 		return fmt.Errorf("the batch size for writing to the database must be between 25k and 2 million")
 	}
 
@@ -276,6 +502,47 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("the max database query execution time must be between 1 second and 2 million seconds")
 	}
 
+	// validate the insert concurrency and retry settings
+	if cfg.MaxInFlightBatches < 1 || cfg.MaxInFlightBatches > 64 {
+		return fmt.Errorf("the max in flight batches must be between 1 and 64, got %v", cfg.MaxInFlightBatches)
+	}
+	if cfg.InsertMaxRetries < 0 || cfg.InsertMaxRetries > 20 {
+		return fmt.Errorf("the insert max retries must be between 0 and 20, got %v", cfg.InsertMaxRetries)
+	}
+	if cfg.InsertRetryBackoffSeconds < 1 || cfg.InsertRetryBackoffSeconds > 60 {
+		return fmt.Errorf("the insert retry backoff must be between 1 and 60 seconds, got %v", cfg.InsertRetryBackoffSeconds)
+	}
+
+	// validate the analysis timeout (0 disables it)
+	if cfg.AnalysisTimeout < 0 {
+		return fmt.Errorf("the analysis timeout must be at least 0 seconds, got %v", cfg.AnalysisTimeout)
+	}
+
+	if err := cfg.validateModules(); err != nil {
+		return err
+	}
+
+	// validate the max import concurrency (0 uses the number of CPUs)
+	if cfg.MaxImportConcurrency < 0 {
+		return fmt.Errorf("the max import concurrency must be at least 0, got %v", cfg.MaxImportConcurrency)
+	}
+
+	if cfg.MaxFQDNsPerSrc < 0 {
+		return fmt.Errorf("the max fqdns per source must be at least 0, got %v", cfg.MaxFQDNsPerSrc)
+	}
+
+	if cfg.MaxLogFileBytes < 0 {
+		return fmt.Errorf("the max log file bytes must be at least 0, got %v", cfg.MaxLogFileBytes)
+	}
+
+	// validate the top findings summary
+	if cfg.TopFindingsLimit < 0 {
+		return fmt.Errorf("the top findings limit must be at least 0, got %v", cfg.TopFindingsLimit)
+	}
+	if cfg.TopFindingsMinScore < 0 || cfg.TopFindingsMinScore > 1 {
+		return fmt.Errorf("the top findings minimum score must be between 0 and 1, got %v", cfg.TopFindingsMinScore)
+	}
+
 	// validate historical first seen months
 	if cfg.MonthsToKeepHistoricalFirstSeen < 1 || cfg.MonthsToKeepHistoricalFirstSeen > 60 {
 		return fmt.Errorf("the historical first seen months must be between 1 and 60, got %v", cfg.MonthsToKeepHistoricalFirstSeen)
@@ -286,6 +553,41 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("the unique connection threshold must be at least 4, got %v", cfg.Scoring.Beacon.UniqueConnectionThreshold)
 	}
 
+	// validate the configured per beacon type unique connection thresholds (0 means the default threshold is used)
+	for beaconType, threshold := range map[string]int64{
+		"ip":  cfg.Scoring.Beacon.UniqueConnectionThresholdPerType.IP,
+		"sni": cfg.Scoring.Beacon.UniqueConnectionThresholdPerType.SNI,
+	} {
+		if threshold != 0 && threshold < 4 {
+			return fmt.Errorf("the %s unique connection threshold must be 0 (use the default) or at least 4, got %v", beaconType, threshold)
+		}
+	}
+
+	// DNS entries are scored by their subdomain count instead of as beacons, so they have no connections to threshold
+	if cfg.Scoring.Beacon.UniqueConnectionThresholdPerType.DNS != 0 {
+		return fmt.Errorf("the dns unique connection threshold must be 0, dns entries are not scored as beacons, got %v", cfg.Scoring.Beacon.UniqueConnectionThresholdPerType.DNS)
+	}
+
+	// validate the configured minimum beacon durations (0 disables the floor), beacons are only
+	// analyzed over the last 24 hours of a dataset so a longer floor would exclude every beacon
+	for beaconType, hours := range map[string]float64{
+		"default": cfg.Scoring.Beacon.MinBeaconDurationHours,
+		"ip":      cfg.Scoring.Beacon.MinBeaconDurationHoursPerType.IP,
+		"sni":     cfg.Scoring.Beacon.MinBeaconDurationHoursPerType.SNI,
+	} {
+		if hours < 0 || hours > 24 {
+			return fmt.Errorf("the %s minimum beacon duration must be between 0 and 24 hours, got %v", beaconType, hours)
+		}
+	}
+
+	// validate the distributed beacon pool sizes, a pool of one destination is already scored as an ip beacon
+	if cfg.Scoring.Beacon.DistributedBeacons.MinDestinations < 2 {
+		return fmt.Errorf("the distributed beacon minimum destinations must be at least 2, got %v", cfg.Scoring.Beacon.DistributedBeacons.MinDestinations)
+	}
+	if cfg.Scoring.Beacon.DistributedBeacons.MaxDestinations != 0 && cfg.Scoring.Beacon.DistributedBeacons.MaxDestinations < cfg.Scoring.Beacon.DistributedBeacons.MinDestinations {
+		return fmt.Errorf("the distributed beacon maximum destinations must be 0 (no limit) or at least the minimum destinations, got %v", cfg.Scoring.Beacon.DistributedBeacons.MaxDestinations)
+	}
+
 	// validate the configured score weights
 	totalWeight := 0.0
 	weights := []float64{
@@ -306,6 +608,14 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("the sum of the weights must equal 1, got %v", totalWeight)
 	}
 
+	// validate the configured data size direction
+	switch cfg.Scoring.Beacon.DsDirection {
+	case DataSizeDirectionSend, DataSizeDirectionReceive, DataSizeDirectionCombined:
+	default:
+		return fmt.Errorf("the data size direction must be '%s', '%s', or '%s', got %q",
+			DataSizeDirectionSend, DataSizeDirectionReceive, DataSizeDirectionCombined, cfg.Scoring.Beacon.DsDirection)
+	}
+
 	// validate the configured minimum hours seen for duration
 	if cfg.Scoring.Beacon.DurMinHours < 1 {
 		return fmt.Errorf("the minimum hours seen for duration must be at least 1, got %v", cfg.Scoring.Beacon.DurMinHours)
@@ -333,8 +643,29 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("the minimum hours seen for histogram must be at least 3, got %v", cfg.Scoring.Beacon.HistBimodalMinHours)
 	}
 
-	// validate the configured beacon score thresholds ( scores are between 0 and 100 )
-	if err := validateScoreThresholds(cfg.Scoring.Beacon.ScoreThresholds, 0, 100); err != nil {
+	// validate the configured consistency window, beacons are analyzed over at most 24 hours so a longer window is the full span
+	if cfg.Scoring.Beacon.ConsistencyWindowHours < 0 || cfg.Scoring.Beacon.ConsistencyWindowHours > 24 {
+		return fmt.Errorf("the consistency window must be between 0 and 24 hours, got %v", cfg.Scoring.Beacon.ConsistencyWindowHours)
+	}
+
+	// validate the configured data size outlier trimming, trimming half of the data sizes from each end would leave nothing
+	if cfg.Scoring.Beacon.DsOutlierTrimPercent < 0 || cfg.Scoring.Beacon.DsOutlierTrimPercent >= 50 {
+		return fmt.Errorf("the data size outlier trim percent must be at least 0 and less than 50, got %v", cfg.Scoring.Beacon.DsOutlierTrimPercent)
+	}
+
+	// validate the configured timestamp jitter tolerance
+	// a tolerance of 1 would ignore all jitter, so it must be less than 1
+	if cfg.Scoring.Beacon.TsJitterTolerance < 0 || cfg.Scoring.Beacon.TsJitterTolerance >= 1 {
+		return fmt.Errorf("the timestamp jitter tolerance must be at least 0 and less than 1, got %v", cfg.Scoring.Beacon.TsJitterTolerance)
+	}
+
+	// validate the number of decimal places that scores are rounded to
+	if cfg.Scoring.Beacon.ScorePrecision < 2 || cfg.Scoring.Beacon.ScorePrecision > 6 {
+		return fmt.Errorf("the score precision must be between 2 and 6, got %v", cfg.Scoring.Beacon.ScorePrecision)
+	}
+
+	// validate the configured beacon score thresholds
+	if err := ValidateBeaconScoreThresholds(cfg.Scoring.Beacon.ScoreThresholds); err != nil {
 		return err
 	}
 
@@ -348,6 +679,22 @@ func (cfg *Config) verifyConfig() error {
 		return err
 	}
 
+	// validate the configured plausible long connection durations
+	if cfg.Scoring.LongConnectionDurationBounds.Min < 0 {
+		return fmt.Errorf("the long connection minimum plausible duration must be at least 0, got %v", cfg.Scoring.LongConnectionDurationBounds.Min)
+	}
+	if cfg.Scoring.LongConnectionDurationBounds.Max < 0 {
+		return fmt.Errorf("the long connection maximum plausible duration must be at least 0, got %v", cfg.Scoring.LongConnectionDurationBounds.Max)
+	}
+	if cfg.Scoring.LongConnectionDurationBounds.Max > 0 && cfg.Scoring.LongConnectionDurationBounds.Max < cfg.Scoring.LongConnectionDurationBounds.Min {
+		return fmt.Errorf("the long connection maximum plausible duration must be 0 or at least the minimum plausible duration, got %v", cfg.Scoring.LongConnectionDurationBounds.Max)
+	}
+
+	// validate the score decay half life
+	if cfg.Scoring.ScoreDecayHalfLifeHours < 0 {
+		return fmt.Errorf("the score decay half life must be at least 0 hours, got %v", cfg.Scoring.ScoreDecayHalfLifeHours)
+	}
+
 	// validate the configured C2 subdomain threshold
 	if cfg.Scoring.C2ScoreThresholds.Base <= 0 {
 		return fmt.Errorf("the C2 subdomain threshold must be at least greater than 0, got %v", cfg.Scoring.C2ScoreThresholds.Base)
@@ -422,6 +769,11 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("the first seen modifier decrease threshold must be greater than the increase threshold, got %v", cfg.Modifiers.FirstSeenDecreaseThreshold)
 	}
 
+	// validate first seen grace window (must be a positive number)
+	if cfg.Modifiers.FirstSeenGraceHours < 0 {
+		return fmt.Errorf("the first seen modifier grace window must be a positive number of hours, got %v", cfg.Modifiers.FirstSeenGraceHours)
+	}
+
 	// validate the configured missing host count score increase (must be between 0 and 1)
 	if cfg.Modifiers.MissingHostCountScoreIncrease < 0 || cfg.Modifiers.MissingHostCountScoreIncrease > 1 {
 		return fmt.Errorf("the missing host count score increase must be between 0 and 1, got %v", cfg.Modifiers.MissingHostCountScoreIncrease)
@@ -442,9 +794,222 @@ func (cfg *Config) verifyConfig() error {
 		return fmt.Errorf("the MIME type/URI mismatch score increase must be between 0 and 1, got %v", cfg.Modifiers.MIMETypeMismatchScoreIncrease)
 	}
 
+	// validate the configured RDP fan out score increase and threshold
+	if cfg.Modifiers.RDPFanOutScoreIncrease < 0 || cfg.Modifiers.RDPFanOutScoreIncrease > 1 {
+		return fmt.Errorf("the RDP fan out score increase must be between 0 and 1, got %v", cfg.Modifiers.RDPFanOutScoreIncrease)
+	}
+	if cfg.Modifiers.RDPFanOutThreshold < 2 {
+		return fmt.Errorf("the RDP fan out threshold must be at least 2, got %v", cfg.Modifiers.RDPFanOutThreshold)
+	}
+
+	// validate the configured failed connection score increase and threshold
+	if cfg.Modifiers.FailedConnScoreIncrease < 0 || cfg.Modifiers.FailedConnScoreIncrease > 1 {
+		return fmt.Errorf("the failed connection score increase must be between 0 and 1, got %v", cfg.Modifiers.FailedConnScoreIncrease)
+	}
+	if cfg.Modifiers.FailedConnThreshold <= 0 || cfg.Modifiers.FailedConnThreshold > 1 {
+		return fmt.Errorf("the failed connection threshold must be greater than 0 and at most 1, got %v", cfg.Modifiers.FailedConnThreshold)
+	}
+
+	// validate DGA NXDOMAIN modifier values
+	if cfg.Modifiers.DGANXDomainScoreIncrease < 0 || cfg.Modifiers.DGANXDomainScoreIncrease > 1 {
+		return fmt.Errorf("the DGA NXDOMAIN score increase must be between 0 and 1, got %v", cfg.Modifiers.DGANXDomainScoreIncrease)
+	}
+	if cfg.Modifiers.DGANXDomainThreshold <= 0 || cfg.Modifiers.DGANXDomainThreshold > 1 {
+		return fmt.Errorf("the DGA NXDOMAIN threshold must be greater than 0 and at most 1, got %v", cfg.Modifiers.DGANXDomainThreshold)
+	}
+	if cfg.Modifiers.DGANXDomainMinQueries < 1 {
+		return fmt.Errorf("the DGA NXDOMAIN minimum queries must be at least 1, got %v", cfg.Modifiers.DGANXDomainMinQueries)
+	}
+
+	// validate DNS subdomain entropy modifier values
+	if cfg.Modifiers.DNSSubdomainEntropyScoreIncrease < 0 || cfg.Modifiers.DNSSubdomainEntropyScoreIncrease > 1 {
+		return fmt.Errorf("the DNS subdomain entropy score increase must be between 0 and 1, got %v", cfg.Modifiers.DNSSubdomainEntropyScoreIncrease)
+	}
+	if cfg.Modifiers.DNSSubdomainEntropyThreshold <= 0 || cfg.Modifiers.DNSSubdomainEntropyThreshold > 8 {
+		return fmt.Errorf("the DNS subdomain entropy threshold must be greater than 0 and at most 8, got %v", cfg.Modifiers.DNSSubdomainEntropyThreshold)
+	}
+
+	// validate long connection session modifier values
+	if cfg.Modifiers.LongConnSustainedScoreIncrease < 0 || cfg.Modifiers.LongConnSustainedScoreIncrease > 1 {
+		return fmt.Errorf("the long connection sustained score increase must be between 0 and 1, got %v", cfg.Modifiers.LongConnSustainedScoreIncrease)
+	}
+	if cfg.Modifiers.LongConnSustainedThreshold <= 0 || cfg.Modifiers.LongConnSustainedThreshold > 1 {
+		return fmt.Errorf("the long connection sustained threshold must be greater than 0 and at most 1, got %v", cfg.Modifiers.LongConnSustainedThreshold)
+	}
+
+	// validate FTP modifier values
+	if cfg.Modifiers.FTPUploadVolumeScoreIncrease < 0 || cfg.Modifiers.FTPUploadVolumeScoreIncrease > 1 {
+		return fmt.Errorf("the FTP upload volume score increase must be between 0 and 1, got %v", cfg.Modifiers.FTPUploadVolumeScoreIncrease)
+	}
+	if cfg.Modifiers.FTPUploadVolumeThreshold < 1 {
+		return fmt.Errorf("the FTP upload volume threshold must be at least 1 byte, got %v", cfg.Modifiers.FTPUploadVolumeThreshold)
+	}
+	if cfg.Modifiers.FTPScriptedUploadScoreIncrease < 0 || cfg.Modifiers.FTPScriptedUploadScoreIncrease > 1 {
+		return fmt.Errorf("the FTP scripted upload score increase must be between 0 and 1, got %v", cfg.Modifiers.FTPScriptedUploadScoreIncrease)
+	}
+	if cfg.Modifiers.FTPScriptedUploadMinUploads < 1 {
+		return fmt.Errorf("the FTP scripted upload minimum uploads must be at least 1, got %v", cfg.Modifiers.FTPScriptedUploadMinUploads)
+	}
+
+	// validate suspicious certificate modifier value
+	if cfg.Modifiers.SuspiciousCertScoreIncrease < 0 || cfg.Modifiers.SuspiciousCertScoreIncrease > 1 {
+		return fmt.Errorf("the suspicious certificate score increase must be between 0 and 1, got %v", cfg.Modifiers.SuspiciousCertScoreIncrease)
+	}
+
+	// validate kerberos anomaly modifier values
+	if cfg.Modifiers.KerberosAnomalyScoreIncrease < 0 || cfg.Modifiers.KerberosAnomalyScoreIncrease > 1 {
+		return fmt.Errorf("the kerberos anomaly score increase must be between 0 and 1, got %v", cfg.Modifiers.KerberosAnomalyScoreIncrease)
+	}
+	if cfg.Modifiers.KerberosFailureThreshold < 1 {
+		return fmt.Errorf("the kerberos failure threshold must be at least 1, got %v", cfg.Modifiers.KerberosFailureThreshold)
+	}
+
+	// validate ntlm anomaly modifier values
+	if cfg.Modifiers.NTLMAnomalyScoreIncrease < 0 || cfg.Modifiers.NTLMAnomalyScoreIncrease > 1 {
+		return fmt.Errorf("the ntlm anomaly score increase must be between 0 and 1, got %v", cfg.Modifiers.NTLMAnomalyScoreIncrease)
+	}
+	if cfg.Modifiers.NTLMDistinctUserThreshold < 1 {
+		return fmt.Errorf("the ntlm distinct user threshold must be at least 1, got %v", cfg.Modifiers.NTLMDistinctUserThreshold)
+	}
+	if cfg.Modifiers.NTLMDistinctHostThreshold < 1 {
+		return fmt.Errorf("the ntlm distinct host threshold must be at least 1, got %v", cfg.Modifiers.NTLMDistinctHostThreshold)
+	}
+
+	// validate dns flood modifier values
+	if cfg.Modifiers.DNSFloodScoreIncrease < 0 || cfg.Modifiers.DNSFloodScoreIncrease > 1 {
+		return fmt.Errorf("the dns flood score increase must be between 0 and 1, got %v", cfg.Modifiers.DNSFloodScoreIncrease)
+	}
+
+	// validate cname chain modifier values
+	if cfg.Modifiers.CNAMEChainScoreIncrease < 0 || cfg.Modifiers.CNAMEChainScoreIncrease > 1 {
+		return fmt.Errorf("the cname chain score increase must be between 0 and 1, got %v", cfg.Modifiers.CNAMEChainScoreIncrease)
+	}
+	if cfg.Modifiers.CNAMEChainDepthThreshold < 1 || cfg.Modifiers.CNAMEChainDepthThreshold > 254 {
+		return fmt.Errorf("the cname chain depth threshold must be between 1 and 254, got %v", cfg.Modifiers.CNAMEChainDepthThreshold)
+	}
+
+	// validate adaptive beacon modifier values
+	if cfg.Modifiers.AdaptiveBeaconScoreIncrease < 0 || cfg.Modifiers.AdaptiveBeaconScoreIncrease > 1 {
+		return fmt.Errorf("the adaptive beacon score increase must be between 0 and 1, got %v", cfg.Modifiers.AdaptiveBeaconScoreIncrease)
+	}
+	if cfg.Modifiers.AdaptiveBeaconSegments < 2 || cfg.Modifiers.AdaptiveBeaconSegments > 24 {
+		return fmt.Errorf("the adaptive beacon segments must be between 2 and 24, got %v", cfg.Modifiers.AdaptiveBeaconSegments)
+	}
+	if cfg.Modifiers.AdaptiveBeaconIntervalRatio <= 1 {
+		return fmt.Errorf("the adaptive beacon interval ratio must be greater than 1, got %v", cfg.Modifiers.AdaptiveBeaconIntervalRatio)
+	}
+
+	// validate the TAXII settings only if a TAXII server is configured
+	if cfg.ThreatIntel.TAXII.DiscoveryURL != "" {
+		discoveryURL, err := url.ParseRequestURI(cfg.ThreatIntel.TAXII.DiscoveryURL)
+		if err != nil || (discoveryURL.Scheme != "http" && discoveryURL.Scheme != "https") {
+			return fmt.Errorf("the TAXII discovery URL must be a valid http or https URL, got %v", cfg.ThreatIntel.TAXII.DiscoveryURL)
+		}
+		if cfg.ThreatIntel.TAXII.CollectionID == "" {
+			return fmt.Errorf("the TAXII collection id cannot be empty when a TAXII discovery URL is set")
+		}
+		if cfg.ThreatIntel.TAXII.PollIntervalMinutes < 1 {
+			return fmt.Errorf("the TAXII poll interval must be at least 1 minute, got %v", cfg.ThreatIntel.TAXII.PollIntervalMinutes)
+		}
+	}
+
+	if err := cfg.PortProtoService.validate(); err != nil {
+		return err
+	}
+
+	// validate the object storage settings
+	if cfg.ObjectStorage.Endpoint != "" {
+		endpoint, err := url.ParseRequestURI(cfg.ObjectStorage.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("the object storage endpoint must be a valid http or https URL, got %v", cfg.ObjectStorage.Endpoint)
+		}
+	}
+	if cfg.ObjectStorage.Region == "" {
+		return fmt.Errorf("the object storage region cannot be empty")
+	}
+	if (cfg.ObjectStorage.AccessKeyID == "") != (cfg.ObjectStorage.SecretAccessKey == "") {
+		return fmt.Errorf("the object storage access key id and secret access key must be set together")
+	}
+
+	// validate the streaming settings only if streaming is enabled
+	if cfg.Streaming.Enabled {
+		if cfg.Streaming.FlushIntervalSeconds < 1 {
+			return fmt.Errorf("the streaming flush interval must be at least 1 second, got %v", cfg.Streaming.FlushIntervalSeconds)
+		}
+		if len(cfg.Streaming.Kafka.Brokers) < 1 {
+			return fmt.Errorf("the list of kafka brokers is empty, got %v", cfg.Streaming.Kafka.Brokers)
+		}
+		if cfg.Streaming.Kafka.Topic == "" {
+			return fmt.Errorf("the kafka topic cannot be empty when streaming is enabled")
+		}
+		if cfg.Streaming.Kafka.GroupID == "" {
+			return fmt.Errorf("the kafka consumer group id cannot be empty when streaming is enabled")
+		}
+	}
+
+	return nil
+}
+
+// validate checks that the service aliases can be used in a port:proto:service key and that the ephemeral port start
+// is a valid port
+func (p *PortProtoService) validate() error {
+	for service, alias := range p.ServiceAliases {
+		for _, name := range []string{service, alias} {
+			if name == "" || strings.ContainsAny(name, ",:") {
+				return fmt.Errorf("port proto service aliases must be non-empty service names without commas or colons, got %q: %q", service, alias)
+			}
+		}
+	}
+
+	if p.EphemeralPortStart < 0 || p.EphemeralPortStart > 65535 {
+		return fmt.Errorf("the ephemeral port start must be between 0 and 65535, got %v", p.EphemeralPortStart)
+	}
+
 	return nil
 }
 
+// GetUniqueConnectionThreshold returns the unique connection threshold for the given beacon type (ip, sni),
+// falling back to the default threshold if the beacon type does not have its own threshold set. Per-port IP
+// connections (ip_port) use the ip threshold, and connections to the subdomains of a domain (sni_parent) use the sni
+// threshold.
+func (b *Beacon) GetUniqueConnectionThreshold(beaconType string) int64 {
+	var threshold int64
+	switch beaconType {
+	case "ip", "ip_port":
+		threshold = b.UniqueConnectionThresholdPerType.IP
+	case "sni", "sni_parent":
+		threshold = b.UniqueConnectionThresholdPerType.SNI
+	}
+
+	if threshold > 0 {
+		return threshold
+	}
+	return b.UniqueConnectionThreshold
+}
+
+// GetMinBeaconDuration returns the shortest span of time that a connection of the given beacon type must be
+// observed over to be scored as a beacon, using the per type override if there is one. Per-port IP connections
+// (ip_port) use the ip override, and connections to the subdomains of a domain (sni_parent) use the sni override.
+func (b *Beacon) GetMinBeaconDuration(beaconType string) time.Duration {
+	var hours float64
+	switch beaconType {
+	case "ip", "ip_port":
+		hours = b.MinBeaconDurationHoursPerType.IP
+	case "sni", "sni_parent":
+		hours = b.MinBeaconDurationHoursPerType.SNI
+	}
+
+	if hours <= 0 {
+		hours = b.MinBeaconDurationHours
+	}
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// ValidateBeaconScoreThresholds validates beacon score thresholds, which must be between 0 and 100
+func ValidateBeaconScoreThresholds(s ScoreThresholds) error {
+	return validateScoreThresholds(s, 0, 100)
+}
+
 // validateScoreThresholds validates the score thresholds based on the provided min and max values
 func validateScoreThresholds(s ScoreThresholds, min int, max int) error {
 	// check if values are in increasing order and unique
@@ -537,22 +1102,52 @@ func defaultConfig() Config {
 	return Config{
 		UpdateCheckEnabled: true,
 		Filter: Filter{
-			InternalSubnetsJSON:       []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fd00::/8"},
-			AlwaysIncludedSubnetsJSON: []string{},
-			NeverIncludedSubnetsJSON:  GetMandatoryNeverIncludeSubnets(),
-			AlwaysIncludedDomains:     []string{},
-			NeverIncludedDomains:      []string{},
-			FilterExternalToInternal:  true,
+			InternalSubnetsJSON:              []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fd00::/8"},
+			AlwaysIncludedSubnetsJSON:        []string{},
+			NeverIncludedSubnetsJSON:         GetMandatoryNeverIncludeSubnets(),
+			NeverIncludedRanges:              []string{},
+			AlwaysIncludedDomains:            []string{},
+			NeverIncludedDomains:             []string{},
+			InternalDomains:                  []string{},
+			BeaconAllowlist:                  []string{},
+			AlwaysIncludedPortsJSON:          []string{},
+			NeverIncludedPortsJSON:           []string{},
+			FilterExternalToInternal:         true,
+			FilterBroadcastMulticast:         true,
+			AnalyzeSpecialUseRanges:          false,
+			FilterOtherProtocols:             false,
+			MinConnectionBytes:               0,
+			CountLowByteConnectionsForStrobe: true,
+			FilterSelfConnections:            true,
 		},
 		HTTPExtensionsFilePath:          "./http_extensions_list.csv",
 		BatchSize:                       100000,
 		MaxQueryExecutionTime:           120,
+		MaxInFlightBatches:              4,
+		InsertMaxRetries:                5,
+		InsertRetryBackoffSeconds:       1,
+		AnalysisTimeout:                 0,
+		EnabledModules:                  []string{},
+		DisabledModules:                 []string{},
+		MaxImportConcurrency:            0,
+		DeduplicateConnUIDs:             false,
+		MaxFQDNsPerSrc:                  0,
+		MaxLogFileBytes:                 0,
+		TopFindingsLimit:                10,
+		TopFindingsMinScore:             0.5,
 		MonthsToKeepHistoricalFirstSeen: 3,
+		AnonymizationSalt:               "",
 		Scoring: Scoring{
 			Beacon: Beacon{
 				UniqueConnectionThreshold:       4,
+				MinBeaconDurationHours:          0,
 				TsWeight:                        0.25,
 				DsWeight:                        0.25,
+				DsDirection:                     DataSizeDirectionSend,
+				DsOutlierTrimPercent:            0,
+				EstimateOpenConnBytes:           false,
+				ExcludeMissingRespBytes:         false,
+				CorrelateDNSResolvedIPs:         false,
 				DurWeight:                       0.25,
 				HistWeight:                      0.25,
 				DurMinHours:                     6,
@@ -560,6 +1155,16 @@ func defaultConfig() Config {
 				HistModeSensitivity:             0.05,
 				HistBimodalOutlierRemoval:       1,
 				HistBimodalMinHours:             11,
+				ConsistencyWindowHours:          0,
+				TsJitterTolerance:               0,
+				DistributedBeacons: DistributedBeacons{
+					Enabled:         false,
+					MinDestinations: 3,
+					MaxDestinations: 20,
+				},
+				SplitByPort:         false,
+				AggregateSubdomains: false,
+				ScorePrecision:      3,
 				ScoreThresholds: ScoreThresholds{
 					Base: 50,
 					Low:  75,
@@ -575,6 +1180,11 @@ func defaultConfig() Config {
 				High: 12 * 3600,
 			},
 
+			LongConnectionDurationBounds: DurationBounds{
+				Min: 0, // only durations below 0 are left out
+				Max: 0, // clamp to the span of the dataset
+			},
+
 			C2ScoreThresholds: ScoreThresholds{
 				Base: 100,
 				Low:  500,
@@ -585,6 +1195,8 @@ func defaultConfig() Config {
 			StrobeImpact: ScoreImpact{Category: HighThreat, Score: HIGH_CATEGORY_SCORE},
 
 			ThreatIntelImpact: ScoreImpact{Category: HighThreat, Score: HIGH_CATEGORY_SCORE},
+
+			ScoreDecayHalfLifeHours: 0, // scores don't decay
 		},
 		Modifiers: Modifiers{
 			ThreatIntelScoreIncrease:     0.15,   // score +15% if data size >= 25 MB
@@ -600,6 +1212,7 @@ func defaultConfig() Config {
 			FirstSeenScoreDecrease:     0.15, // score -15% if first seen >= 30 days ago
 			FirstSeenDecreaseThreshold: 30,   // must be greater than the increase threshold
 			// because the longer a host has been seen on the network, the less sus it is
+			FirstSeenGraceHours: 0, // hours after the start of the dataset in which first seen connections are not boosted
 
 			MissingHostCountScoreIncrease: 0.10, // +10% score for any (>0) missing hosts
 
@@ -608,10 +1221,91 @@ func defaultConfig() Config {
 			C2OverDNSDirectConnScoreIncrease: 0.15, // +15% score for domains that were queried but had no direct connections
 
 			MIMETypeMismatchScoreIncrease: 0.15, // +15% score for connections with mismatched MIME type/URI
+
+			RDPFanOutScoreIncrease: 0.15, // +15% score for hosts that made RDP connections to many internal hosts
+			RDPFanOutThreshold:     10,   // number of unique internal hosts a host has to make RDP connections to
+
+			FailedConnScoreIncrease: 0.15, // +15% score for beacons made up mostly of rejected or half-open connections
+			FailedConnThreshold:     0.8,  // fraction of a beacon's closed connections that must have failed
+
+			DGANXDomainScoreIncrease: 0.15, // +15% score for hosts whose DNS queries mostly returned NXDOMAIN
+			DGANXDomainThreshold:     0.5,  // fraction of a host's DNS queries that must have returned NXDOMAIN
+			DGANXDomainMinQueries:    50,   // number of DNS queries a host must make before its NXDOMAIN ratio is scored
+
+			DNSSubdomainEntropyScoreIncrease: 0.15, // +15% score for C2 over DNS domains with random looking subdomains
+			DNSSubdomainEntropyThreshold:     3.5,  // mean Shannon entropy (bits per character) of the queried subdomain labels
+
+			LongConnSustainedScoreIncrease: 0.1, // +10% score for long connections made up of a single sustained session
+			LongConnSustainedThreshold:     0.9, // fraction of a long connection's total duration that must be in its longest session
+
+			FTPUploadVolumeScoreIncrease: 0.15,              // +15% score for hosts that uploaded a large amount of data to an external FTP server
+			FTPUploadVolumeThreshold:     100 * 1024 * 1024, // number of bytes uploaded to an external FTP server (100 MiB)
+
+			FTPScriptedUploadScoreIncrease: 0.1, // +10% score for FTP sessions that uploaded many files without listing a directory
+			FTPScriptedUploadMinUploads:    10,  // number of files an FTP session must upload to be scored
+
+			SuspiciousCertScoreIncrease: 0.15, // +15% score for beaconing SNIs that presented a self-signed or expired certificate
+
+			KerberosAnomalyScoreIncrease: 0.1, // +10% score for hosts that were issued weak cipher kerberos tickets or made many failed kerberos requests
+			KerberosFailureThreshold:     10,  // number of failed kerberos requests a host has to make
+
+			NTLMAnomalyScoreIncrease:  0.1, // +10% score for hosts that authenticated with ntlm as many distinct users or to many distinct hosts
+			NTLMDistinctUserThreshold: 5,   // number of distinct users a host has to authenticate as
+			NTLMDistinctHostThreshold: 20,  // number of distinct hosts a host has to authenticate to
+
+			DNSFloodScoreIncrease: 0.15, // +15% score for hosts that queried more than max_fqdns_per_src distinct domains
+
+			CNAMEChainScoreIncrease:  0.1, // +10% score for domains that were resolved through a long chain of CNAME records
+			CNAMEChainDepthThreshold: 4,   // number of CNAME records a domain's resolution has to exceed
+
+			AdaptiveBeaconScoreIncrease: 0.15, // +15% score for beacons whose interval shifted during the dataset
+			AdaptiveBeaconSegments:      4,    // number of segments a beacon's connections are split into
+			AdaptiveBeaconIntervalRatio: 2,    // factor that the modal interval has to change by between segments
 		},
 		ThreatIntel: ThreatIntel{
 			OnlineFeeds:          []string{},
+			FeedCacheDirectory:   "/var/cache/rita/threat_intel_feeds",
 			CustomFeedsDirectory: "/etc/rita/threat_intel_feeds",
+			TAXII: TAXII{
+				PollIntervalMinutes: 60,
+			},
+		},
+		PortProtoService: PortProtoService{
+			ServiceAliases:     map[string]string{},
+			EphemeralPortStart: 0,
+		},
+		GeoIP: GeoIP{
+			CountryDatabasePath: "",
+			ASNDatabasePath:     "",
+		},
+		ObjectStorage: ObjectStorage{
+			Endpoint:     "",
+			Region:       "us-east-1",
+			UsePathStyle: false,
+		},
+		Streaming: Streaming{
+			Enabled:              false,
+			FlushIntervalSeconds: 60,
+			Kafka: Kafka{
+				Brokers: []string{},
+				Topic:   "zeek",
+				GroupID: "rita",
+			},
 		},
 	}
 }
+
+// validHostnamePort returns whether value is a hostname or IP address followed by a valid port, ex: db:9000
+func validHostnamePort(value string) bool {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil || host == "" {
+		return false
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 1 || portNum > 65535 {
+		return false
+	}
+
+	return net.ParseIP(host) != nil || hostnamePattern.MatchString(host)
+}
@@ -5,7 +5,9 @@ import (
 	"log"
 	"net"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/activecm/rita/v5/util"
 
@@ -51,23 +53,64 @@ func TestReadFileConfig(t *testing.T) {
 						internal_subnets: ["11.0.0.0/8", "120.130.140.150/8"],
 						always_included_subnets: ["13.0.0.0/8", "160.140.150.160/8"],
 						never_included_subnets: ["12.0.0.0/8", "150.140.150.160/8"],
+						never_included_ranges: ["cgnat"],
 						always_included_domains: ["abc.com", "def.com"],
 						never_included_domains: ["ghi.com", "jkl.com"],
+						internal_domains: ["intranet.example.com", "*.corp.example.com"],
+						beacon_allowlist: ["*.windowsupdate.com", "203.0.113.0/24"],
+						always_included_ports: ["53:udp"],
+						never_included_ports: ["123:udp", "1-1024:udp"],
 						filter_external_to_internal: false,
+						filter_broadcast_multicast: false,
+						analyze_special_use_ranges: false,
+						filter_other_protocols: true,
+						min_connection_bytes: 64,
+						count_low_byte_connections_for_strobe: false,
+						filter_self_connections: false,
 					},
 					http_extensions_file_path: "/path/to/http/extensions",
 					batch_size: 75000,
 					max_query_execution_time: 120000,
+					max_in_flight_batches: 8,
+					insert_max_retries: 3,
+					insert_retry_backoff_seconds: 2,
+					analysis_timeout: 3600,
+					enabled_modules: ["beacons", "strobes", "threat_intel"],
+					disabled_modules: ["strobes"],
+					port_proto_service: {
+						service_aliases: { tls: "ssl", "dce_rpc": "dcerpc" },
+						ephemeral_port_start: 49152,
+					},
+					max_import_concurrency: 2,
+					deduplicate_conn_uids: true,
+					max_fqdns_per_src: 50000,
+					max_log_file_bytes: 1073741824,
+					top_findings_limit: 25,
+					top_findings_min_score: 0.7,
+					anonymization_salt: "pepper",
 					months_to_keep_historical_first_seen: 6,
 					threat_intel: {
 						online_feeds: ["https://example.com/feed1", "https://example.com/feed2"],
+						feed_cache_directory: "/path/to/feed/cache",
 						custom_feeds_directory: "/path/to/custom/feeds",
+						taxii: {
+							discovery_url: "https://taxii.example.com/taxii2/",
+							collection_id: "91a7b528-80eb-42ed-a74d-c6fbd5a26116",
+							username: "rita",
+							password: "secret",
+							poll_interval_minutes: 15,
+						},
 					},
 					scoring: {
 						beacon: {
 							unique_connection_threshold: 10,
 							timestamp_score_weight: 0.35,
 							datasize_score_weight: 0.20,
+							datasize_direction: "receive",
+							datasize_outlier_trim_percent: 5,
+							estimate_open_conn_bytes: true,
+							exclude_missing_resp_bytes: true,
+							correlate_dns_resolved_ips: true,
 							duration_score_weight: 0.35,
 							histogram_score_weight: 0.10,
 							duration_min_hours_seen: 10,
@@ -75,6 +118,16 @@ func TestReadFileConfig(t *testing.T) {
 							histogram_mode_sensitivity: 0.08,
 							histogram_bimodal_outlier_removal: 2,
 							histogram_bimodal_min_hours_seen: 15,
+							consistency_window_hours: 8,
+							timestamp_jitter_tolerance: 0.2,
+							distributed_beacons: {
+								enabled: true,
+								min_destinations: 4,
+								max_destinations: 0,
+							},
+							split_by_port: true,
+							aggregate_subdomains: true,
+							score_precision: 5,
 							score_thresholds: {
 								base: 0,
 								low: 1,
@@ -112,10 +165,38 @@ func TestReadFileConfig(t *testing.T) {
 						first_seen_increase_threshold: 10,
 						first_seen_score_decrease: 0.2,
 						first_seen_decrease_threshold: 50,
+						first_seen_grace_hours: 6,
 						missing_host_count_score_increase: 0.4,
 						rare_signature_score_increase: 0.4,
 						c2_over_dns_direct_conn_score_increase: 0.9,
-						mime_type_mismatch_score_increase: 0.6
+						mime_type_mismatch_score_increase: 0.6,
+						rdp_fan_out_score_increase: 0.3,
+						rdp_fan_out_threshold: 25,
+						failed_conn_score_increase: 0.25,
+						failed_conn_threshold: 0.6,
+						dga_nxdomain_score_increase: 0.35,
+						dga_nxdomain_threshold: 0.7,
+						dga_nxdomain_min_queries: 100,
+						dns_subdomain_entropy_score_increase: 0.2,
+						dns_subdomain_entropy_threshold: 4,
+						long_conn_sustained_score_increase: 0.25,
+						long_conn_sustained_threshold: 0.75,
+						ftp_upload_volume_score_increase: 0.3,
+						ftp_upload_volume_threshold: 5000000,
+						ftp_scripted_upload_score_increase: 0.05,
+						ftp_scripted_upload_min_uploads: 20,
+						suspicious_cert_score_increase: 0.35,
+						kerberos_anomaly_score_increase: 0.25,
+						kerberos_failure_threshold: 30,
+						ntlm_anomaly_score_increase: 0.2,
+						ntlm_distinct_user_threshold: 8,
+						ntlm_distinct_host_threshold: 40,
+						dns_flood_score_increase: 0.3,
+						cname_chain_score_increase: 0.2,
+						cname_chain_depth_threshold: 6,
+						adaptive_beacon_score_increase: 0.25,
+						adaptive_beacon_segments: 6,
+						adaptive_beacon_interval_ratio: 3
 					},
 			}`,
 			expectedConfig: Config{
@@ -131,8 +212,8 @@ func TestReadFileConfig(t *testing.T) {
 						{IP: net.IP{13, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
 						{IP: net.IP{160, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
 					},
-					// mandatoryNeverIncludeSubnets are always apended to any neverIncludedSubnet entries
-					NeverIncludedSubnetsJSON: append([]string{"12.0.0.0/8", "150.140.150.160/8"}, GetMandatoryNeverIncludeSubnets()...),
+					// mandatoryNeverIncludeSubnets and named ranges are always apended to any neverIncludedSubnet entries
+					NeverIncludedSubnetsJSON: append(append([]string{"12.0.0.0/8", "150.140.150.160/8"}, GetMandatoryNeverIncludeSubnets()...), "100.64.0.0/10"),
 					NeverIncludedSubnets: []*net.IPNet{
 						{IP: net.IP{12, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
 						{IP: net.IP{150, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
@@ -146,21 +227,57 @@ func TestReadFileConfig(t *testing.T) {
 						{IP: net.ParseIP("fe80::"), Mask: net.CIDRMask(10, 128)},
 						{IP: net.ParseIP("ff00::"), Mask: net.CIDRMask(8, 128)},
 						{IP: net.ParseIP("ff02::2"), Mask: net.CIDRMask(128, 128)},
+						{IP: net.IP{100, 64, 0, 0}, Mask: net.IPMask{255, 192, 0, 0}},
 					},
-
-					AlwaysIncludedDomains:    []string{"abc.com", "def.com"},
-					NeverIncludedDomains:     []string{"ghi.com", "jkl.com"},
-					FilterExternalToInternal: false,
+					NeverIncludedRanges: []string{"cgnat"},
+
+					AlwaysIncludedDomains:            []string{"abc.com", "def.com"},
+					NeverIncludedDomains:             []string{"ghi.com", "jkl.com"},
+					InternalDomains:                  []string{"intranet.example.com", "*.corp.example.com"},
+					BeaconAllowlist:                  []string{"*.windowsupdate.com", "203.0.113.0/24"},
+					AlwaysIncludedPortsJSON:          []string{"53:udp"},
+					AlwaysIncludedPorts:              []util.PortRange{{Start: 53, End: 53, Proto: "udp"}},
+					NeverIncludedPortsJSON:           []string{"123:udp", "1-1024:udp"},
+					NeverIncludedPorts:               []util.PortRange{{Start: 123, End: 123, Proto: "udp"}, {Start: 1, End: 1024, Proto: "udp"}},
+					FilterExternalToInternal:         false,
+					FilterBroadcastMulticast:         false,
+					AnalyzeSpecialUseRanges:          false,
+					FilterOtherProtocols:             true,
+					MinConnectionBytes:               64,
+					CountLowByteConnectionsForStrobe: false,
+					FilterSelfConnections:            false,
 				},
-				HTTPExtensionsFilePath:          "/path/to/http/extensions",
-				BatchSize:                       75000,
-				MaxQueryExecutionTime:           120000,
+				HTTPExtensionsFilePath:    "/path/to/http/extensions",
+				BatchSize:                 75000,
+				MaxQueryExecutionTime:     120000,
+				MaxInFlightBatches:        8,
+				InsertMaxRetries:          3,
+				InsertRetryBackoffSeconds: 2,
+				AnalysisTimeout:           3600,
+				EnabledModules:            []string{"beacons", "strobes", "threat_intel"},
+				DisabledModules:           []string{"strobes"},
+				PortProtoService: PortProtoService{
+					ServiceAliases:     map[string]string{"tls": "ssl", "dce_rpc": "dcerpc"},
+					EphemeralPortStart: 49152,
+				},
+				MaxImportConcurrency:            2,
+				DeduplicateConnUIDs:             true,
+				MaxFQDNsPerSrc:                  50000,
+				MaxLogFileBytes:                 1073741824,
+				TopFindingsLimit:                25,
+				TopFindingsMinScore:             0.7,
+				AnonymizationSalt:               "pepper",
 				MonthsToKeepHistoricalFirstSeen: 6,
 				Scoring: Scoring{
 					Beacon: Beacon{
 						UniqueConnectionThreshold:       10,
 						TsWeight:                        0.35,
 						DsWeight:                        0.20,
+						DsDirection:                     DataSizeDirectionReceive,
+						DsOutlierTrimPercent:            5,
+						EstimateOpenConnBytes:           true,
+						ExcludeMissingRespBytes:         true,
+						CorrelateDNSResolvedIPs:         true,
 						DurWeight:                       0.35,
 						HistWeight:                      0.10,
 						DurMinHours:                     10,
@@ -168,6 +285,16 @@ func TestReadFileConfig(t *testing.T) {
 						HistModeSensitivity:             0.08,
 						HistBimodalOutlierRemoval:       2,
 						HistBimodalMinHours:             15,
+						ConsistencyWindowHours:          8,
+						TsJitterTolerance:               0.2,
+						DistributedBeacons: DistributedBeacons{
+							Enabled:         true,
+							MinDestinations: 4,
+							MaxDestinations: 0,
+						},
+						SplitByPort:         true,
+						AggregateSubdomains: true,
+						ScorePrecision:      5,
 						ScoreThresholds: ScoreThresholds{
 							Base: 0,
 							Low:  1,
@@ -207,14 +334,50 @@ func TestReadFileConfig(t *testing.T) {
 					FirstSeenIncreaseThreshold:       10,
 					FirstSeenScoreDecrease:           0.2,
 					FirstSeenDecreaseThreshold:       50,
+					FirstSeenGraceHours:              6,
 					MissingHostCountScoreIncrease:    0.4,
 					RareSignatureScoreIncrease:       0.4,
 					C2OverDNSDirectConnScoreIncrease: 0.9,
 					MIMETypeMismatchScoreIncrease:    0.6,
+					RDPFanOutScoreIncrease:           0.3,
+					RDPFanOutThreshold:               25,
+					FailedConnScoreIncrease:          0.25,
+					FailedConnThreshold:              0.6,
+					DGANXDomainScoreIncrease:         0.35,
+					DGANXDomainThreshold:             0.7,
+					DGANXDomainMinQueries:            100,
+					DNSSubdomainEntropyScoreIncrease: 0.2,
+					DNSSubdomainEntropyThreshold:     4,
+					LongConnSustainedScoreIncrease:   0.25,
+					LongConnSustainedThreshold:       0.75,
+					FTPUploadVolumeScoreIncrease:     0.3,
+					FTPUploadVolumeThreshold:         5000000,
+					FTPScriptedUploadScoreIncrease:   0.05,
+					FTPScriptedUploadMinUploads:      20,
+					SuspiciousCertScoreIncrease:      0.35,
+					KerberosAnomalyScoreIncrease:     0.25,
+					KerberosFailureThreshold:         30,
+					NTLMAnomalyScoreIncrease:         0.2,
+					NTLMDistinctUserThreshold:        8,
+					NTLMDistinctHostThreshold:        40,
+					DNSFloodScoreIncrease:            0.3,
+					CNAMEChainScoreIncrease:          0.2,
+					CNAMEChainDepthThreshold:         6,
+					AdaptiveBeaconScoreIncrease:      0.25,
+					AdaptiveBeaconSegments:           6,
+					AdaptiveBeaconIntervalRatio:      3,
 				},
 				ThreatIntel: ThreatIntel{
 					OnlineFeeds:          []string{"https://example.com/feed1", "https://example.com/feed2"},
+					FeedCacheDirectory:   "/path/to/feed/cache",
 					CustomFeedsDirectory: "/path/to/custom/feeds",
+					TAXII: TAXII{
+						DiscoveryURL:        "https://taxii.example.com/taxii2/",
+						CollectionID:        "91a7b528-80eb-42ed-a74d-c6fbd5a26116",
+						Username:            "rita",
+						Password:            "secret",
+						PollIntervalMinutes: 15,
+					},
 				},
 			},
 			expectedError: false,
@@ -274,25 +437,60 @@ func TestReadFileConfig(t *testing.T) {
 
 			require.ElementsMatch(test.expectedConfig.Filter.NeverIncludedSubnetsJSON, cfg.Filter.NeverIncludedSubnetsJSON, "NeverIncludedSubnetsJSON should match expected value")
 			require.ElementsMatch(test.expectedConfig.Filter.NeverIncludedSubnets, cfg.Filter.NeverIncludedSubnets, "NeverIncludedSubnets should match expected value")
+			require.ElementsMatch(test.expectedConfig.Filter.NeverIncludedRanges, cfg.Filter.NeverIncludedRanges, "NeverIncludedRanges should match expected value")
 
 			require.ElementsMatch(test.expectedConfig.Filter.AlwaysIncludedDomains, cfg.Filter.AlwaysIncludedDomains, "AlwaysIncludedDomains should match expected value")
 			require.ElementsMatch(test.expectedConfig.Filter.NeverIncludedDomains, cfg.Filter.NeverIncludedDomains, "NeverIncludedDomains should match expected value")
+			require.ElementsMatch(test.expectedConfig.Filter.InternalDomains, cfg.Filter.InternalDomains, "InternalDomains should match expected value")
+			require.ElementsMatch(test.expectedConfig.Filter.BeaconAllowlist, cfg.Filter.BeaconAllowlist, "BeaconAllowlist should match expected value")
+
+			require.ElementsMatch(test.expectedConfig.Filter.AlwaysIncludedPortsJSON, cfg.Filter.AlwaysIncludedPortsJSON, "AlwaysIncludedPortsJSON should match expected value")
+			require.ElementsMatch(test.expectedConfig.Filter.AlwaysIncludedPorts, cfg.Filter.AlwaysIncludedPorts, "AlwaysIncludedPorts should match expected value")
+			require.ElementsMatch(test.expectedConfig.Filter.NeverIncludedPortsJSON, cfg.Filter.NeverIncludedPortsJSON, "NeverIncludedPortsJSON should match expected value")
+			require.ElementsMatch(test.expectedConfig.Filter.NeverIncludedPorts, cfg.Filter.NeverIncludedPorts, "NeverIncludedPorts should match expected value")
 
 			require.Equal(test.expectedConfig.Filter.FilterExternalToInternal, cfg.Filter.FilterExternalToInternal, "FilterExternalToInternal should match expected value")
+			require.Equal(test.expectedConfig.Filter.FilterBroadcastMulticast, cfg.Filter.FilterBroadcastMulticast, "FilterBroadcastMulticast should match expected value")
+			require.Equal(test.expectedConfig.Filter.AnalyzeSpecialUseRanges, cfg.Filter.AnalyzeSpecialUseRanges, "AnalyzeSpecialUseRanges should match expected value")
+			require.Equal(test.expectedConfig.Filter.FilterOtherProtocols, cfg.Filter.FilterOtherProtocols, "FilterOtherProtocols should match expected value")
+			require.Equal(test.expectedConfig.Filter.MinConnectionBytes, cfg.Filter.MinConnectionBytes, "MinConnectionBytes should match expected value")
+			require.Equal(test.expectedConfig.Filter.CountLowByteConnectionsForStrobe, cfg.Filter.CountLowByteConnectionsForStrobe, "CountLowByteConnectionsForStrobe should match expected value")
+			require.Equal(test.expectedConfig.Filter.FilterSelfConnections, cfg.Filter.FilterSelfConnections, "FilterSelfConnections should match expected value")
 
 			require.Equal(test.expectedConfig.HTTPExtensionsFilePath, cfg.HTTPExtensionsFilePath, "HTTPExtensionsFilePath should match expected value")
 
 			require.Equal(test.expectedConfig.BatchSize, cfg.BatchSize, "BatchSize should match expected value")
 			require.Equal(test.expectedConfig.MaxQueryExecutionTime, cfg.MaxQueryExecutionTime, "MaxQuertExecutionTime should match expected value")
+			require.Equal(test.expectedConfig.MaxInFlightBatches, cfg.MaxInFlightBatches, "MaxInFlightBatches should match expected value")
+			require.Equal(test.expectedConfig.InsertMaxRetries, cfg.InsertMaxRetries, "InsertMaxRetries should match expected value")
+			require.Equal(test.expectedConfig.InsertRetryBackoffSeconds, cfg.InsertRetryBackoffSeconds, "InsertRetryBackoffSeconds should match expected value")
+			require.Equal(test.expectedConfig.AnalysisTimeout, cfg.AnalysisTimeout, "AnalysisTimeout should match expected value")
+			require.Equal(test.expectedConfig.EnabledModules, cfg.EnabledModules, "EnabledModules should match expected value")
+			require.Equal(test.expectedConfig.DisabledModules, cfg.DisabledModules, "DisabledModules should match expected value")
+			require.Equal(test.expectedConfig.PortProtoService, cfg.PortProtoService, "PortProtoService should match expected value")
+			require.Equal(test.expectedConfig.MaxImportConcurrency, cfg.MaxImportConcurrency, "MaxImportConcurrency should match expected value")
+			require.Equal(test.expectedConfig.DeduplicateConnUIDs, cfg.DeduplicateConnUIDs, "DeduplicateConnUIDs should match expected value")
+			require.Equal(test.expectedConfig.MaxFQDNsPerSrc, cfg.MaxFQDNsPerSrc, "MaxFQDNsPerSrc should match expected value")
+			require.Equal(test.expectedConfig.MaxLogFileBytes, cfg.MaxLogFileBytes, "MaxLogFileBytes should match expected value")
+			require.Equal(test.expectedConfig.TopFindingsLimit, cfg.TopFindingsLimit, "TopFindingsLimit should match expected value")
+			require.InDelta(test.expectedConfig.TopFindingsMinScore, cfg.TopFindingsMinScore, 0.0001, "TopFindingsMinScore should match expected value")
+			require.Equal(test.expectedConfig.AnonymizationSalt, cfg.AnonymizationSalt, "AnonymizationSalt should match expected value")
 
 			require.Equal(test.expectedConfig.MonthsToKeepHistoricalFirstSeen, cfg.MonthsToKeepHistoricalFirstSeen, "MonthsToKeepHistoricalFirstSeen should match expected value")
 
 			require.Equal(test.expectedConfig.ThreatIntel.OnlineFeeds, cfg.ThreatIntel.OnlineFeeds, "OnlineFeeds should match expected value")
+			require.Equal(test.expectedConfig.ThreatIntel.FeedCacheDirectory, cfg.ThreatIntel.FeedCacheDirectory, "FeedCacheDirectory should match expected value")
 			require.Equal(test.expectedConfig.ThreatIntel.CustomFeedsDirectory, cfg.ThreatIntel.CustomFeedsDirectory, "CustomFeedsDirectory should match expected value")
+			require.Equal(test.expectedConfig.ThreatIntel.TAXII, cfg.ThreatIntel.TAXII, "TAXII should match expected value")
 
 			require.Equal(test.expectedConfig.Scoring.Beacon.UniqueConnectionThreshold, cfg.Scoring.Beacon.UniqueConnectionThreshold, "BeaconUniqueConnectionThreshold should match expected value")
 			require.InDelta(test.expectedConfig.Scoring.Beacon.TsWeight, cfg.Scoring.Beacon.TsWeight, 0.00001, "BeaconTsWeight should match expected value")
 			require.InDelta(test.expectedConfig.Scoring.Beacon.DsWeight, cfg.Scoring.Beacon.DsWeight, 0.00001, "BeaconDsWeight should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.DsDirection, cfg.Scoring.Beacon.DsDirection, "BeaconDsDirection should match expected value")
+			require.InDelta(test.expectedConfig.Scoring.Beacon.DsOutlierTrimPercent, cfg.Scoring.Beacon.DsOutlierTrimPercent, 0.00001, "BeaconDsOutlierTrimPercent should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.EstimateOpenConnBytes, cfg.Scoring.Beacon.EstimateOpenConnBytes, "BeaconEstimateOpenConnBytes should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.ExcludeMissingRespBytes, cfg.Scoring.Beacon.ExcludeMissingRespBytes, "BeaconExcludeMissingRespBytes should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.CorrelateDNSResolvedIPs, cfg.Scoring.Beacon.CorrelateDNSResolvedIPs, "BeaconCorrelateDNSResolvedIPs should match expected value")
 			require.InDelta(test.expectedConfig.Scoring.Beacon.DurWeight, cfg.Scoring.Beacon.DurWeight, 0.00001, "BeaconDurWeight should match expected value")
 			require.InDelta(test.expectedConfig.Scoring.Beacon.HistWeight, cfg.Scoring.Beacon.HistWeight, 0.00001, "BeaconHistWeight should match expected value")
 			require.Equal(test.expectedConfig.Scoring.Beacon.DurMinHours, cfg.Scoring.Beacon.DurMinHours, "BeaconDurMinHoursSeen should match expected value")
@@ -300,6 +498,12 @@ func TestReadFileConfig(t *testing.T) {
 			require.InDelta(test.expectedConfig.Scoring.Beacon.HistModeSensitivity, cfg.Scoring.Beacon.HistModeSensitivity, 0.00001, "BeaconHistModeSensitivity should match expected value")
 			require.Equal(test.expectedConfig.Scoring.Beacon.HistBimodalOutlierRemoval, cfg.Scoring.Beacon.HistBimodalOutlierRemoval, "BeaconHistBimodalOutlierRemoval should match expected value")
 			require.Equal(test.expectedConfig.Scoring.Beacon.HistBimodalMinHours, cfg.Scoring.Beacon.HistBimodalMinHours, "BeaconHistBimodalMinHoursSeen should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.ConsistencyWindowHours, cfg.Scoring.Beacon.ConsistencyWindowHours, "BeaconConsistencyWindowHours should match expected value")
+			require.InDelta(test.expectedConfig.Scoring.Beacon.TsJitterTolerance, cfg.Scoring.Beacon.TsJitterTolerance, 0.00001, "BeaconTsJitterTolerance should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.DistributedBeacons, cfg.Scoring.Beacon.DistributedBeacons, "BeaconDistributedBeacons should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.SplitByPort, cfg.Scoring.Beacon.SplitByPort, "BeaconSplitByPort should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.AggregateSubdomains, cfg.Scoring.Beacon.AggregateSubdomains, "BeaconAggregateSubdomains should match expected value")
+			require.Equal(test.expectedConfig.Scoring.Beacon.ScorePrecision, cfg.Scoring.Beacon.ScorePrecision, "BeaconScorePrecision should match expected value")
 			require.Equal(test.expectedConfig.Scoring.Beacon.ScoreThresholds.Base, cfg.Scoring.Beacon.ScoreThresholds.Base, "BeaconScoreThresholds.Base should match expected value")
 			require.Equal(test.expectedConfig.Scoring.Beacon.ScoreThresholds.Low, cfg.Scoring.Beacon.ScoreThresholds.Low, "BeaconScoreThresholds.Low should match expected value")
 			require.Equal(test.expectedConfig.Scoring.Beacon.ScoreThresholds.Med, cfg.Scoring.Beacon.ScoreThresholds.Med, "BeaconScoreThresholds.Med should match expected value")
@@ -331,10 +535,38 @@ func TestReadFileConfig(t *testing.T) {
 			require.InDelta(test.expectedConfig.Modifiers.FirstSeenIncreaseThreshold, cfg.Modifiers.FirstSeenIncreaseThreshold, 0.00001, "FirstSeenIncreaseThreshold should match expected value")
 			require.InDelta(test.expectedConfig.Modifiers.FirstSeenScoreDecrease, cfg.Modifiers.FirstSeenScoreDecrease, 0.00001, "FirstSeenScoreDecrease should match expected value")
 			require.InDelta(test.expectedConfig.Modifiers.FirstSeenDecreaseThreshold, cfg.Modifiers.FirstSeenDecreaseThreshold, 0.00001, "FirstSeenDecreaseThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.FirstSeenGraceHours, cfg.Modifiers.FirstSeenGraceHours, 0.00001, "FirstSeenGraceHours should match expected value")
 			require.InDelta(test.expectedConfig.Modifiers.MissingHostCountScoreIncrease, cfg.Modifiers.MissingHostCountScoreIncrease, 0.00001, "MissingHostCountScoreIncrease should match expected value")
 			require.InDelta(test.expectedConfig.Modifiers.RareSignatureScoreIncrease, cfg.Modifiers.RareSignatureScoreIncrease, 0.00001, "RareSignatureScoreIncrease should match expected value")
 			require.InDelta(test.expectedConfig.Modifiers.C2OverDNSDirectConnScoreIncrease, cfg.Modifiers.C2OverDNSDirectConnScoreIncrease, 0.00001, "C2OverDNSDirectConnScoreIncrease should match expected value")
 			require.InDelta(test.expectedConfig.Modifiers.MIMETypeMismatchScoreIncrease, cfg.Modifiers.MIMETypeMismatchScoreIncrease, 0.00001, "MIMETypeMismatchScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.RDPFanOutScoreIncrease, cfg.Modifiers.RDPFanOutScoreIncrease, 0.00001, "RDPFanOutScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.RDPFanOutThreshold, cfg.Modifiers.RDPFanOutThreshold, "RDPFanOutThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.FailedConnScoreIncrease, cfg.Modifiers.FailedConnScoreIncrease, 0.00001, "FailedConnScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.FailedConnThreshold, cfg.Modifiers.FailedConnThreshold, 0.00001, "FailedConnThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.DGANXDomainScoreIncrease, cfg.Modifiers.DGANXDomainScoreIncrease, 0.00001, "DGANXDomainScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.DGANXDomainThreshold, cfg.Modifiers.DGANXDomainThreshold, 0.00001, "DGANXDomainThreshold should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.DGANXDomainMinQueries, cfg.Modifiers.DGANXDomainMinQueries, "DGANXDomainMinQueries should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.DNSSubdomainEntropyScoreIncrease, cfg.Modifiers.DNSSubdomainEntropyScoreIncrease, 0.00001, "DNSSubdomainEntropyScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.DNSSubdomainEntropyThreshold, cfg.Modifiers.DNSSubdomainEntropyThreshold, 0.00001, "DNSSubdomainEntropyThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.LongConnSustainedScoreIncrease, cfg.Modifiers.LongConnSustainedScoreIncrease, 0.00001, "LongConnSustainedScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.LongConnSustainedThreshold, cfg.Modifiers.LongConnSustainedThreshold, 0.00001, "LongConnSustainedThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.FTPUploadVolumeScoreIncrease, cfg.Modifiers.FTPUploadVolumeScoreIncrease, 0.00001, "FTPUploadVolumeScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.FTPUploadVolumeThreshold, cfg.Modifiers.FTPUploadVolumeThreshold, "FTPUploadVolumeThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.FTPScriptedUploadScoreIncrease, cfg.Modifiers.FTPScriptedUploadScoreIncrease, 0.00001, "FTPScriptedUploadScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.FTPScriptedUploadMinUploads, cfg.Modifiers.FTPScriptedUploadMinUploads, "FTPScriptedUploadMinUploads should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.SuspiciousCertScoreIncrease, cfg.Modifiers.SuspiciousCertScoreIncrease, 0.00001, "SuspiciousCertScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.KerberosAnomalyScoreIncrease, cfg.Modifiers.KerberosAnomalyScoreIncrease, 0.00001, "KerberosAnomalyScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.KerberosFailureThreshold, cfg.Modifiers.KerberosFailureThreshold, "KerberosFailureThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.NTLMAnomalyScoreIncrease, cfg.Modifiers.NTLMAnomalyScoreIncrease, 0.00001, "NTLMAnomalyScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.NTLMDistinctUserThreshold, cfg.Modifiers.NTLMDistinctUserThreshold, "NTLMDistinctUserThreshold should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.NTLMDistinctHostThreshold, cfg.Modifiers.NTLMDistinctHostThreshold, "NTLMDistinctHostThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.DNSFloodScoreIncrease, cfg.Modifiers.DNSFloodScoreIncrease, 0.00001, "DNSFloodScoreIncrease should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.CNAMEChainScoreIncrease, cfg.Modifiers.CNAMEChainScoreIncrease, 0.00001, "CNAMEChainScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.CNAMEChainDepthThreshold, cfg.Modifiers.CNAMEChainDepthThreshold, "CNAMEChainDepthThreshold should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.AdaptiveBeaconScoreIncrease, cfg.Modifiers.AdaptiveBeaconScoreIncrease, 0.00001, "AdaptiveBeaconScoreIncrease should match expected value")
+			require.Equal(test.expectedConfig.Modifiers.AdaptiveBeaconSegments, cfg.Modifiers.AdaptiveBeaconSegments, "AdaptiveBeaconSegments should match expected value")
+			require.InDelta(test.expectedConfig.Modifiers.AdaptiveBeaconIntervalRatio, cfg.Modifiers.AdaptiveBeaconIntervalRatio, 0.00001, "AdaptiveBeaconIntervalRatio should match expected value")
 
 			// clean up after the test
 			err = afs.Remove(configPath)
@@ -363,6 +595,152 @@ func TestVerifyBeaconConfig(t *testing.T) {
 	require.InDelta(0.05, cfg.Scoring.Beacon.HistModeSensitivity, 0.00001, "BeaconHistModeSensitivity should match expected value")
 	require.Equal(1, cfg.Scoring.Beacon.HistBimodalOutlierRemoval, "BeaconHistBimodalOutlierRemoval should match expected value")
 	require.Equal(11, cfg.Scoring.Beacon.HistBimodalMinHours, "BeaconHistBimodalMinHoursSeen should match expected value")
+	require.Equal(0, cfg.Scoring.Beacon.ConsistencyWindowHours, "BeaconConsistencyWindowHours should match expected value")
+	require.InDelta(0, cfg.Scoring.Beacon.TsJitterTolerance, 0.00001, "BeaconTsJitterTolerance should match expected value")
+
+	// verify the bounds of the timestamp jitter tolerance
+	for _, tolerance := range []float64{-0.1, 1, 1.5} {
+		cfg.Scoring.Beacon.TsJitterTolerance = tolerance
+		require.Error(cfg.verifyConfig(), "a timestamp jitter tolerance of %v should produce an error", tolerance)
+	}
+	cfg.Scoring.Beacon.TsJitterTolerance = 0.99
+	require.NoError(cfg.verifyConfig(), "a timestamp jitter tolerance of 0.99 should not produce an error")
+
+	// verify the bounds of the distributed beacon pool sizes
+	require.Equal(DistributedBeacons{Enabled: false, MinDestinations: 3, MaxDestinations: 20}, cfg.Scoring.Beacon.DistributedBeacons, "BeaconDistributedBeacons should match expected value")
+	for _, pool := range []DistributedBeacons{{MinDestinations: 1}, {MinDestinations: 5, MaxDestinations: 4}} {
+		cfg.Scoring.Beacon.DistributedBeacons = pool
+		require.Error(cfg.verifyConfig(), "distributed beacon pool sizes %+v should produce an error", pool)
+	}
+	for _, pool := range []DistributedBeacons{{MinDestinations: 2}, {MinDestinations: 3, MaxDestinations: 3}} {
+		cfg.Scoring.Beacon.DistributedBeacons = pool
+		require.NoError(cfg.verifyConfig(), "distributed beacon pool sizes %+v should not produce an error", pool)
+	}
+
+	// verify the bounds of the consistency window
+	for _, hours := range []int{-1, 25} {
+		cfg.Scoring.Beacon.ConsistencyWindowHours = hours
+		require.Error(cfg.verifyConfig(), "a consistency window of %v hours should produce an error", hours)
+	}
+	for _, hours := range []int{0, 8, 24} {
+		cfg.Scoring.Beacon.ConsistencyWindowHours = hours
+		require.NoError(cfg.verifyConfig(), "a consistency window of %v hours should not produce an error", hours)
+	}
+
+	// verify the bounds of the score precision
+	require.Equal(3, cfg.Scoring.Beacon.ScorePrecision, "BeaconScorePrecision should match expected value")
+	for _, precision := range []int{0, 1, 7} {
+		cfg.Scoring.Beacon.ScorePrecision = precision
+		require.Error(cfg.verifyConfig(), "a score precision of %v should produce an error", precision)
+	}
+	for _, precision := range []int{2, 6} {
+		cfg.Scoring.Beacon.ScorePrecision = precision
+		require.NoError(cfg.verifyConfig(), "a score precision of %v should not produce an error", precision)
+	}
+
+	// verify the data size direction, the default preserves scoring on the sent data sizes only
+	require.Equal(DataSizeDirectionSend, cfg.Scoring.Beacon.DsDirection, "BeaconDsDirection should match expected value")
+	for _, direction := range []string{DataSizeDirectionSend, DataSizeDirectionReceive, DataSizeDirectionCombined} {
+		cfg.Scoring.Beacon.DsDirection = direction
+		require.NoError(cfg.verifyConfig(), "a data size direction of %v should not produce an error", direction)
+	}
+	for _, direction := range []string{"", "both", "Send"} {
+		cfg.Scoring.Beacon.DsDirection = direction
+		require.Error(cfg.verifyConfig(), "a data size direction of %q should produce an error", direction)
+	}
+	cfg.Scoring.Beacon.DsDirection = DataSizeDirectionSend
+
+	// verify the bounds of the data size outlier trimming, the default trims nothing
+	require.Zero(cfg.Scoring.Beacon.DsOutlierTrimPercent, "BeaconDsOutlierTrimPercent should match expected value")
+	for _, percent := range []float64{-1, 50, 75} {
+		cfg.Scoring.Beacon.DsOutlierTrimPercent = percent
+		require.Error(cfg.verifyConfig(), "a data size outlier trim percent of %v should produce an error", percent)
+	}
+	cfg.Scoring.Beacon.DsOutlierTrimPercent = 10
+	require.NoError(cfg.verifyConfig(), "a data size outlier trim percent of 10 should not produce an error")
+}
+
+func TestGetUniqueConnectionThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		perType     BeaconTypeThresholds
+		beaconType  string
+		expected    int64
+		expectedErr bool
+	}{
+		{name: "IP Uses Default When Unset", beaconType: "ip", expected: 4},
+		{name: "SNI Uses Default When Unset", beaconType: "sni", expected: 4},
+		{name: "IP Override", perType: BeaconTypeThresholds{IP: 8}, beaconType: "ip", expected: 8},
+		{name: "SNI Override", perType: BeaconTypeThresholds{SNI: 12}, beaconType: "sni", expected: 12},
+		{name: "Per-Port IP Uses IP Override", perType: BeaconTypeThresholds{IP: 8}, beaconType: "ip_port", expected: 8},
+		{name: "Parent Domain Uses SNI Override", perType: BeaconTypeThresholds{SNI: 6}, beaconType: "sni_parent", expected: 6},
+		{name: "Override Does Not Apply To Other Type", perType: BeaconTypeThresholds{IP: 8}, beaconType: "sni", expected: 4},
+		{name: "Unknown Type Uses Default", perType: BeaconTypeThresholds{IP: 8, SNI: 12}, beaconType: "dns", expected: 4},
+		{name: "IP Below Minimum", perType: BeaconTypeThresholds{IP: 3}, beaconType: "ip", expectedErr: true},
+		{name: "SNI Negative", perType: BeaconTypeThresholds{SNI: -1}, beaconType: "sni", expectedErr: true},
+		{name: "DNS Rejected", perType: BeaconTypeThresholds{DNS: 8}, beaconType: "dns", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			cfg, err := GetDefaultConfig()
+			require.NoError(err, "getting default config should not produce an error")
+			cfg.Scoring.Beacon.UniqueConnectionThresholdPerType = test.perType
+
+			err = cfg.verifyConfig()
+			if test.expectedErr {
+				require.Error(err, "verifyConfig should produce an error")
+				return
+			}
+			require.NoError(err, "verifyConfig should not produce an error")
+			require.Equal(test.expected, cfg.Scoring.Beacon.GetUniqueConnectionThreshold(test.beaconType), "unique connection threshold should match expected value")
+		})
+	}
+}
+
+func TestGetMinBeaconDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultHrs  float64
+		perType     BeaconTypeDurations
+		beaconType  string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{name: "Disabled By Default", beaconType: "ip", expected: 0},
+		{name: "Default Applies To IP", defaultHrs: 2, beaconType: "ip", expected: 2 * time.Hour},
+		{name: "Default Applies To SNI", defaultHrs: 0.5, beaconType: "sni", expected: 30 * time.Minute},
+		{name: "IP Override", defaultHrs: 2, perType: BeaconTypeDurations{IP: 6}, beaconType: "ip", expected: 6 * time.Hour},
+		{name: "SNI Override", perType: BeaconTypeDurations{SNI: 1.5}, beaconType: "sni", expected: 90 * time.Minute},
+		{name: "Per-Port IP Uses IP Override", defaultHrs: 2, perType: BeaconTypeDurations{IP: 6}, beaconType: "ip_port", expected: 6 * time.Hour},
+		{name: "Parent Domain Uses SNI Override", defaultHrs: 2, perType: BeaconTypeDurations{SNI: 4}, beaconType: "sni_parent", expected: 4 * time.Hour},
+		{name: "Override Does Not Apply To Other Type", defaultHrs: 2, perType: BeaconTypeDurations{IP: 6}, beaconType: "sni", expected: 2 * time.Hour},
+		{name: "Full Day", defaultHrs: 24, beaconType: "ip", expected: 24 * time.Hour},
+		{name: "Default Longer Than Beacon Window", defaultHrs: 25, beaconType: "ip", expectedErr: true},
+		{name: "SNI Longer Than Beacon Window", perType: BeaconTypeDurations{SNI: 48}, beaconType: "sni", expectedErr: true},
+		{name: "IP Negative", perType: BeaconTypeDurations{IP: -1}, beaconType: "ip", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			cfg, err := GetDefaultConfig()
+			require.NoError(err, "getting default config should not produce an error")
+			cfg.Scoring.Beacon.MinBeaconDurationHours = test.defaultHrs
+			cfg.Scoring.Beacon.MinBeaconDurationHoursPerType = test.perType
+
+			err = cfg.verifyConfig()
+			if test.expectedErr {
+				require.Error(err, "verifyConfig should produce an error")
+				return
+			}
+			require.NoError(err, "verifyConfig should not produce an error")
+			require.Equal(test.expected, cfg.Scoring.Beacon.GetMinBeaconDuration(test.beaconType), "minimum beacon duration should match expected value")
+		})
+	}
 }
 
 func TestResetConfig(t *testing.T) {
@@ -422,6 +800,7 @@ func TestGetDefaultConfig(t *testing.T) {
 	connection := os.Getenv("DB_ADDRESS")
 	require.NotEmpty(connection, "DB_ADDRESS should not be empty")
 	origConfigVar.DBConnection = connection
+	origConfigVar.MetaDatabase = DefaultMetaDatabase
 
 	// verify version got set
 	require.Equal("dev", Version, "version should be 'dev'")
@@ -574,6 +953,14 @@ func TestValidateScoreThresholds(t *testing.T) {
 	}
 }
 
+func TestValidateBeaconScoreThresholds(t *testing.T) {
+	require.NoError(t, ValidateBeaconScoreThresholds(ScoreThresholds{Base: 70, Low: 80, Med: 90, High: 100}))
+	require.NoError(t, ValidateBeaconScoreThresholds(ScoreThresholds{Base: 0, Low: 40, Med: 60, High: 75}))
+	require.Error(t, ValidateBeaconScoreThresholds(ScoreThresholds{Base: 70, Low: 90, Med: 80, High: 100}), "thresholds must be in increasing order")
+	require.Error(t, ValidateBeaconScoreThresholds(ScoreThresholds{Base: -10, Low: 80, Med: 90, High: 100}), "base must be at least 0")
+	require.Error(t, ValidateBeaconScoreThresholds(ScoreThresholds{Base: 70, Low: 80, Med: 90, High: 101}), "high must be at most 100")
+}
+
 func TestParseImpactCategoryScores(t *testing.T) {
 	t.Run("Valid Categories", func(t *testing.T) {
 		cfg := &Config{
@@ -766,3 +1153,161 @@ func TestGetImpactCategoryFromScore(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyTAXIIConfig(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+
+	// TAXII is disabled by default
+	require.Empty(cfg.ThreatIntel.TAXII.DiscoveryURL, "TAXII discovery URL should be empty by default")
+	require.Equal(60, cfg.ThreatIntel.TAXII.PollIntervalMinutes, "TAXII poll interval should match expected value")
+	require.NoError(cfg.verifyConfig(), "default config should not produce an error")
+
+	cfg.ThreatIntel.TAXII.DiscoveryURL = "https://taxii.example.com/taxii2/"
+	require.Error(cfg.verifyConfig(), "a TAXII discovery URL without a collection id should produce an error")
+
+	cfg.ThreatIntel.TAXII.CollectionID = "intel"
+	require.NoError(cfg.verifyConfig(), "a TAXII discovery URL with a collection id should not produce an error")
+
+	cfg.ThreatIntel.TAXII.PollIntervalMinutes = 0
+	require.Error(cfg.verifyConfig(), "a TAXII poll interval of 0 should produce an error")
+	cfg.ThreatIntel.TAXII.PollIntervalMinutes = 60
+
+	for _, discoveryURL := range []string{"taxii.example.com", "ftp://taxii.example.com/taxii2/"} {
+		cfg.ThreatIntel.TAXII.DiscoveryURL = discoveryURL
+		require.Error(cfg.verifyConfig(), "a TAXII discovery URL of %v should produce an error", discoveryURL)
+	}
+}
+
+func TestVerifyTopFindingsConfig(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+	require.Equal(10, cfg.TopFindingsLimit, "top findings limit should match expected value")
+	require.InDelta(float32(0.5), cfg.TopFindingsMinScore, 0.0001, "top findings minimum score should match expected value")
+
+	// a limit of 0 disables the summary
+	cfg.TopFindingsLimit = 0
+	require.NoError(cfg.verifyConfig(), "a top findings limit of 0 should not produce an error")
+	cfg.TopFindingsLimit = -1
+	require.Error(cfg.verifyConfig(), "a top findings limit of -1 should produce an error")
+	cfg.TopFindingsLimit = 10
+
+	for _, score := range []float32{0, 0.5, 1} {
+		cfg.TopFindingsMinScore = score
+		require.NoError(cfg.verifyConfig(), "a top findings minimum score of %v should not produce an error", score)
+	}
+	for _, score := range []float32{-0.1, 1.1} {
+		cfg.TopFindingsMinScore = score
+		require.Error(cfg.verifyConfig(), "a top findings minimum score of %v should produce an error", score)
+	}
+}
+
+func TestVerifyPortProtoServiceConfig(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+	require.Empty(cfg.PortProtoService.ServiceAliases, "there should be no service aliases by default")
+	require.Equal(0, cfg.PortProtoService.EphemeralPortStart, "ephemeral ports should not be collapsed by default")
+
+	cfg.PortProtoService.ServiceAliases = map[string]string{"tls": "ssl"}
+	require.NoError(cfg.verifyConfig(), "a valid service alias should not produce an error")
+
+	for service, alias := range map[string]string{"": "ssl", "tls": "", "http,tls": "ssl", "ssl": "tls:443"} {
+		cfg.PortProtoService.ServiceAliases = map[string]string{service: alias}
+		require.Error(cfg.verifyConfig(), "a service alias of %q to %q should produce an error", service, alias)
+	}
+	cfg.PortProtoService.ServiceAliases = map[string]string{}
+
+	for _, port := range []int{0, 1024, 49152, 65535} {
+		cfg.PortProtoService.EphemeralPortStart = port
+		require.NoError(cfg.verifyConfig(), "an ephemeral port start of %v should not produce an error", port)
+	}
+	for _, port := range []int{-1, 65536} {
+		cfg.PortProtoService.EphemeralPortStart = port
+		require.Error(cfg.verifyConfig(), "an ephemeral port start of %v should produce an error", port)
+	}
+}
+
+func TestVerifyDBReadConnection(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+
+	// the read replica is optional
+	cfg.DBReadConnection = ""
+	require.NoError(cfg.verifyConfig(), "an empty read connection should not produce an error")
+
+	for _, connection := range []string{"localhost:9000", "db:9000", "replica.example.com:9440", "10.0.0.5:9000", "[::1]:9000"} {
+		cfg.DBReadConnection = connection
+		require.NoError(cfg.verifyConfig(), "a read connection of %v should not produce an error", connection)
+	}
+
+	for _, connection := range []string{"localhost", ":9000", "db:", "db:port", "db:0", "db:65536", "::1:9000", "-db:9000", "db_replica:9000"} {
+		cfg.DBReadConnection = connection
+		require.Error(cfg.verifyConfig(), "a read connection of %v should produce an error", connection)
+	}
+}
+
+func TestMetaDatabase(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("DB_METADATABASE", "")
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+	require.Equal(DefaultMetaDatabase, cfg.MetaDatabase, "metadatabase should default to %v", DefaultMetaDatabase)
+
+	t.Setenv("DB_METADATABASE", "rita_metadatabase")
+	cfg, err = GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+	require.Equal("rita_metadatabase", cfg.MetaDatabase, "metadatabase should be set by DB_METADATABASE")
+
+	for _, name := range []string{"metadatabase", "rita_meta", "_meta2", "M"} {
+		cfg.MetaDatabase = name
+		require.NoError(cfg.verifyConfig(), "a metadatabase name of %v should not produce an error", name)
+	}
+
+	for _, name := range []string{"", "2meta", "meta-db", "meta.db", "meta db", "system", "default", "information_schema", strings.Repeat("a", 64)} {
+		cfg.MetaDatabase = name
+		require.Error(cfg.verifyConfig(), "a metadatabase name of %v should produce an error", name)
+	}
+}
+
+func TestVerifyLongConnectionDurationBounds(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+	require.Equal(DurationBounds{}, cfg.Scoring.LongConnectionDurationBounds, "the default bounds should only leave out negative durations and clamp to the dataset span")
+
+	for _, bounds := range []DurationBounds{{}, {Min: 60}, {Max: 86400}, {Min: 3600, Max: 3600}, {Min: 3600, Max: 172800}} {
+		cfg.Scoring.LongConnectionDurationBounds = bounds
+		require.NoError(cfg.verifyConfig(), "long connection duration bounds of %+v should not produce an error", bounds)
+	}
+
+	for _, bounds := range []DurationBounds{{Min: -1}, {Max: -1}, {Min: 7200, Max: 3600}} {
+		cfg.Scoring.LongConnectionDurationBounds = bounds
+		require.Error(cfg.verifyConfig(), "long connection duration bounds of %+v should produce an error", bounds)
+	}
+}
+
+func TestVerifyScoreDecayHalfLifeHours(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := GetDefaultConfig()
+	require.NoError(err, "getDefaultConfig should not produce an error")
+	require.Zero(cfg.Scoring.ScoreDecayHalfLifeHours, "scores should not decay by default")
+
+	for _, halfLife := range []float64{0, 0.5, 24, 168} {
+		cfg.Scoring.ScoreDecayHalfLifeHours = halfLife
+		require.NoError(cfg.verifyConfig(), "score decay half life of %v should not produce an error", halfLife)
+	}
+
+	cfg.Scoring.ScoreDecayHalfLifeHours = -1
+	require.Error(cfg.verifyConfig(), "a negative score decay half life should produce an error")
+}
@@ -1,11 +1,51 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
 	"github.com/activecm/rita/v5/util"
 
 	"net"
 )
 
+var ErrUnknownNamedRange = errors.New("unknown named range")
+var ErrInvalidInternalDomain = errors.New("internal domain must be a valid fqdn")
+var ErrInvalidBeaconAllowlistEntry = errors.New("beacon allowlist entries must be a valid fqdn, IP, or CIDR")
+
+// namedRanges are groups of well-known subnets that can be listed by name in never_included_ranges
+// instead of being entered as CIDRs
+var namedRanges = map[string][]string{
+	"cgnat": {"100.64.0.0/10"}, // shared address space RFC 6598
+	"rfc1918": {
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+	},
+	"documentation": {
+		"192.0.2.0/24",    // TEST-NET-1 RFC 5737
+		"198.51.100.0/24", // TEST-NET-2 RFC 5737
+		"203.0.113.0/24",  // TEST-NET-3 RFC 5737
+		"2001:db8::/32",   // RFC 3849
+	},
+	"benchmarking": {
+		"198.18.0.0/15", // RFC 2544
+		"2001:2::/48",   // RFC 5180
+	},
+}
+
+// specialUseSubnets are the loopback and link-local subnets in the mandatory never include list, which are analyzed
+// instead of filtered out when analyze_special_use_ranges is enabled
+var specialUseSubnets = []string{
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link local
+	"::1/128",        // loopback
+	"fe80::/10",      // link local
+}
+
 // Filter provides methods for excluding IP addresses, domains, and determining proxy servers during the import step
 // based on the user configuration
 type Filter struct {
@@ -17,11 +57,37 @@ type Filter struct {
 
 	NeverIncludedSubnetsJSON []string `json:"never_included_subnets"`
 	NeverIncludedSubnets     []*net.IPNet
+	NeverIncludedRanges      []string `json:"never_included_ranges"` // names of groups of subnets, see GetNamedRangeSubnets
 
 	AlwaysIncludedDomains []string `json:"always_included_domains"`
 	NeverIncludedDomains  []string `json:"never_included_domains"`
+	InternalDomains       []string `json:"internal_domains"` // fqdns that are treated as internal destinations, ex: split-horizon DNS
+
+	// beacon destinations that are still imported and scored, but are flagged as allowlisted and left out of exports
+	BeaconAllowlist        []string `json:"beacon_allowlist"` // fqdns, IPs, and CIDRs
+	BeaconAllowlistDomains []string
+	BeaconAllowlistSubnets []*net.IPNet
+
+	AlwaysIncludedPortsJSON []string `json:"always_included_ports"`
+	AlwaysIncludedPorts     []util.PortRange
+
+	NeverIncludedPortsJSON []string `json:"never_included_ports"`
+	NeverIncludedPorts     []util.PortRange
 
 	FilterExternalToInternal bool `json:"filter_external_to_internal"`
+	FilterBroadcastMulticast bool `json:"filter_broadcast_multicast"`
+	// keeps loopback and link-local connections, such as loopback-tunneled traffic in lab captures, which are
+	// otherwise always filtered out. Multicast and broadcast are still filtered out.
+	AnalyzeSpecialUseRanges bool `json:"analyze_special_use_ranges"`
+	// drops connections that use a transport other than tcp or udp, such as icmp
+	FilterOtherProtocols bool `json:"filter_other_protocols"`
+
+	// connections that transferred fewer payload bytes than this are left out of beaconing, 0 disables the floor
+	MinConnectionBytes               int64 `json:"min_connection_bytes"`
+	CountLowByteConnectionsForStrobe bool  `json:"count_low_byte_connections_for_strobe"`
+
+	// drops connections whose source and destination are the same host, such as proxy loopback or misconfigured sensors
+	FilterSelfConnections bool `json:"filter_self_connections"`
 }
 
 func GetMandatoryNeverIncludeSubnets() []string {
@@ -43,6 +109,25 @@ func GetMandatoryNeverIncludeSubnets() []string {
 	}
 }
 
+// GetNamedRangeNames returns the sorted names of the groups of subnets that can be listed in never_included_ranges
+func GetNamedRangeNames() []string {
+	names := make([]string, 0, len(namedRanges))
+	for name := range namedRanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetNamedRangeSubnets returns the CIDRs that make up the named group of subnets
+func GetNamedRangeSubnets(name string) ([]string, error) {
+	subnets, ok := namedRanges[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, must be one of %v", ErrUnknownNamedRange, name, GetNamedRangeNames())
+	}
+	return slices.Clone(subnets), nil
+}
+
 func (cfg *Config) parseFilter() error {
 	// parse internal subnets
 	internalSubnetList, err := util.ParseSubnets(cfg.Filter.InternalSubnetsJSON)
@@ -59,7 +144,23 @@ func (cfg *Config) parseFilter() error {
 	cfg.Filter.AlwaysIncludedSubnets = alwaysIncludedSubnetList
 
 	// validate that all mandatory never include subnets are present
-	cfg.Filter.NeverIncludedSubnetsJSON = util.EnsureSliceContainsAll(cfg.Filter.NeverIncludedSubnetsJSON, GetMandatoryNeverIncludeSubnets())
+	mandatoryNeverIncludeSubnets := GetMandatoryNeverIncludeSubnets()
+	if cfg.Filter.AnalyzeSpecialUseRanges {
+		// the default never include list already has these subnets, so they are removed from both lists
+		isSpecialUse := func(subnet string) bool { return slices.Contains(specialUseSubnets, subnet) }
+		mandatoryNeverIncludeSubnets = slices.DeleteFunc(mandatoryNeverIncludeSubnets, isSpecialUse)
+		cfg.Filter.NeverIncludedSubnetsJSON = slices.DeleteFunc(slices.Clone(cfg.Filter.NeverIncludedSubnetsJSON), isSpecialUse)
+	}
+	cfg.Filter.NeverIncludedSubnetsJSON = util.EnsureSliceContainsAll(cfg.Filter.NeverIncludedSubnetsJSON, mandatoryNeverIncludeSubnets)
+
+	// expand named ranges into their subnets
+	for _, name := range cfg.Filter.NeverIncludedRanges {
+		subnets, err := GetNamedRangeSubnets(name)
+		if err != nil {
+			return err
+		}
+		cfg.Filter.NeverIncludedSubnetsJSON = util.EnsureSliceContainsAll(cfg.Filter.NeverIncludedSubnetsJSON, subnets)
+	}
 
 	// parse never included subnets
 	neverIncludedSubnetList, err := util.ParseSubnets(cfg.Filter.NeverIncludedSubnetsJSON)
@@ -68,6 +169,45 @@ func (cfg *Config) parseFilter() error {
 	}
 	cfg.Filter.NeverIncludedSubnets = neverIncludedSubnetList
 
+	// validate internal domains, which may start with a wildcard like the other domain lists
+	for _, domain := range cfg.Filter.InternalDomains {
+		if !hostnamePattern.MatchString(strings.TrimPrefix(domain, "*.")) {
+			return fmt.Errorf("%w: %q", ErrInvalidInternalDomain, domain)
+		}
+	}
+
+	// split the beacon allowlist into subnets and domains
+	cfg.Filter.BeaconAllowlistDomains = nil
+	cfg.Filter.BeaconAllowlistSubnets = nil
+	for _, entry := range cfg.Filter.BeaconAllowlist {
+		if strings.Contains(entry, "/") || net.ParseIP(entry) != nil {
+			subnets, err := util.ParseSubnets([]string{entry})
+			if err != nil {
+				return fmt.Errorf("%w: %q", ErrInvalidBeaconAllowlistEntry, entry)
+			}
+			cfg.Filter.BeaconAllowlistSubnets = append(cfg.Filter.BeaconAllowlistSubnets, subnets...)
+			continue
+		}
+		if !hostnamePattern.MatchString(strings.TrimPrefix(entry, "*.")) {
+			return fmt.Errorf("%w: %q", ErrInvalidBeaconAllowlistEntry, entry)
+		}
+		cfg.Filter.BeaconAllowlistDomains = append(cfg.Filter.BeaconAllowlistDomains, entry)
+	}
+
+	// parse always included ports
+	alwaysIncludedPortList, err := util.ParsePortRanges(cfg.Filter.AlwaysIncludedPortsJSON)
+	if err != nil {
+		return err
+	}
+	cfg.Filter.AlwaysIncludedPorts = alwaysIncludedPortList
+
+	// parse never included ports
+	neverIncludedPortList, err := util.ParsePortRanges(cfg.Filter.NeverIncludedPortsJSON)
+	if err != nil {
+		return err
+	}
+	cfg.Filter.NeverIncludedPorts = neverIncludedPortList
+
 	return nil
 }
 
@@ -83,6 +223,10 @@ func (fs *Filter) FilterSNIPair(srcIP net.IP) bool {
 // FilterConnPairForHTTP returns true if a connection pair is filtered
 // based on criteria that should apply regardless of whether or not there is a proxy connection for it
 func (fs *Filter) FilterConnPairForHTTP(srcIP net.IP, dstIP net.IP) bool {
+	// broadcast and multicast destinations are never scored
+	if fs.FilterDestination(dstIP) {
+		return true
+	}
 
 	// check if on always included list
 	isSrcIncluded := util.ContainsIP(fs.AlwaysIncludedSubnets, srcIP)
@@ -116,13 +260,18 @@ func (fs *Filter) FilterConnPairForHTTP(srcIP net.IP, dstIP net.IP) bool {
 
 // filterConnPair returns true if a connection pair is filtered/excluded.
 // This is determined by the following rules, in order:
-//  1. Not filtered if either IP is on the AlwaysInclude list
-//  2. Filtered if either IP is on the NeverInclude list
-//  3. Not filtered if InternalSubnets is empty
-//  4. Filtered if both IPs are internal or both are external
-//  5. Filtered if the source IP is external and the destination IP is internal and FilterExternalToInternal has been set in the configuration file
-//  6. Not filtered in all other cases
+//  1. Filtered if the destination IP is a broadcast or multicast address and FilterBroadcastMulticast has been set in the configuration file
+//  2. Not filtered if either IP is on the AlwaysInclude list
+//  3. Filtered if either IP is on the NeverInclude list
+//  4. Not filtered if InternalSubnets is empty
+//  5. Filtered if both IPs are internal or both are external
+//  6. Filtered if the source IP is external and the destination IP is internal and FilterExternalToInternal has been set in the configuration file
+//  7. Not filtered in all other cases
 func (fs *Filter) FilterConnPair(srcIP net.IP, dstIP net.IP) bool {
+	// broadcast and multicast destinations are never scored
+	if fs.FilterDestination(dstIP) {
+		return true
+	}
 
 	// check if on always included list
 	isSrcIncluded := util.ContainsIP(fs.AlwaysIncludedSubnets, srcIP)
@@ -176,13 +325,19 @@ func (fs *Filter) FilterConnPair(srcIP net.IP, dstIP net.IP) bool {
 // DNS is treated specially since we need to capture internal -> internal DNS traffic
 // in order to detect C2 over DNS with an internal resolver.
 // This is determined by the following rules, in order:
-//  1. Not filtered if either IP is on the AlwaysInclude list
-//  2. Filtered if either IP is on the NeverInclude list
-//  3. Not filtered if InternalSubnets is empty
-//  4. Filtered if both IPs are external (this is different from filterConnPair which filters internal to internal connections)
-//  5. Filtered if the source IP is external and the destination IP is internal and FilterExternalToInternal has been set in the configuration file
-//  6. Not filtered in all other cases
+//  1. Filtered if the destination IP is a broadcast or multicast address and FilterBroadcastMulticast has been set in the configuration file
+//  2. Not filtered if either IP is on the AlwaysInclude list
+//  3. Filtered if either IP is on the NeverInclude list
+//  4. Not filtered if InternalSubnets is empty
+//  5. Filtered if both IPs are external (this is different from filterConnPair which filters internal to internal connections)
+//  6. Filtered if the source IP is external and the destination IP is internal and FilterExternalToInternal has been set in the configuration file
+//  7. Not filtered in all other cases
 func (fs *Filter) FilterDNSPair(srcIP net.IP, dstIP net.IP) bool {
+	// broadcast and multicast destinations are never scored, this catches mDNS and LLMNR queries
+	if fs.FilterDestination(dstIP) {
+		return true
+	}
+
 	// check if on always included list
 	isSrcIncluded := util.ContainsIP(fs.AlwaysIncludedSubnets, srcIP)
 	isDstIncluded := util.ContainsIP(fs.AlwaysIncludedSubnets, dstIP)
@@ -225,25 +380,45 @@ func (fs *Filter) FilterDNSPair(srcIP net.IP, dstIP net.IP) bool {
 	return false
 }
 
+// FilterRDPPair returns true if an RDP connection pair is filtered/excluded.
+// RDP follows the same rules as FilterDNSPair since most RDP sessions are internal -> internal
+// (lateral movement), which would otherwise be filtered out by FilterConnPair.
+func (fs *Filter) FilterRDPPair(srcIP net.IP, dstIP net.IP) bool {
+	return fs.FilterDNSPair(srcIP, dstIP)
+}
+
+// FilterKerberosPair returns true if a Kerberos connection pair is filtered/excluded.
+// Kerberos follows the same rules as FilterDNSPair since most Kerberos requests are made to internal
+// domain controllers, which would otherwise be filtered out by FilterConnPair.
+func (fs *Filter) FilterKerberosPair(srcIP net.IP, dstIP net.IP) bool {
+	return fs.FilterDNSPair(srcIP, dstIP)
+}
+
+// FilterNTLMPair returns true if an NTLM authentication pair is filtered/excluded.
+// NTLM follows the same rules as FilterDNSPair since most NTLM authentication is made to internal
+// servers and domain controllers, which would otherwise be filtered out by FilterConnPair.
+func (fs *Filter) FilterNTLMPair(srcIP net.IP, dstIP net.IP) bool {
+	return fs.FilterDNSPair(srcIP, dstIP)
+}
+
+// FilterDestination returns true if FilterBroadcastMulticast has been set in the configuration file and the
+// destination IP is the limited broadcast address or a multicast group. Unlike the NeverInclude list, this
+// cannot be overridden by the AlwaysInclude list, since traffic to these addresses is never a single peer.
+func (fs *Filter) FilterDestination(dstIP net.IP) bool {
+	if !fs.FilterBroadcastMulticast || dstIP == nil {
+		return false
+	}
+	return dstIP.IsMulticast() || dstIP.Equal(net.IPv4bcast)
+}
+
 // filterSingleIP returns true if an IP is filtered/excluded.
 // This is determined by the following rules, in order:
 //  1. Not filtered IP is on the AlwaysInclude list
 //  2. Filtered IP is on the NeverInclude list
 //  3. Not filtered in all other cases
 func (fs *Filter) FilterSingleIP(ip net.IP) bool {
-
-	// check if on always included list
-	if util.ContainsIP(fs.AlwaysIncludedSubnets, ip) {
-		return false
-	}
-
-	// check if on never included list
-	if util.ContainsIP(fs.NeverIncludedSubnets, ip) {
-		return true
-	}
-
-	// default to not filter the IP address
-	return false
+	_, _, excluded := fs.ClassifyIP(ip)
+	return excluded
 }
 
 // FilterDomain returns true if a domain is filtered/excluded.
@@ -272,6 +447,113 @@ func (fs *Filter) FilterDomain(domain string) bool {
 	return false
 }
 
+// CheckIfBeaconAllowlisted returns true if a beacon to the destination IP or fqdn is on the BeaconAllowlist.
+// Either the fqdn or the IP may be empty, since SNI beacons don't have a single destination IP.
+func (fs *Filter) CheckIfBeaconAllowlisted(dstIP net.IP, fqdn string) bool {
+	if fqdn != "" && util.ContainsDomain(fs.BeaconAllowlistDomains, fqdn) {
+		return true
+	}
+	return dstIP != nil && !dstIP.IsUnspecified() && util.ContainsIP(fs.BeaconAllowlistSubnets, dstIP)
+}
+
+// CheckIfInternalDomain returns true if the fqdn is on the InternalDomains list
+func (fs *Filter) CheckIfInternalDomain(fqdn string) bool {
+	return fqdn != "" && util.ContainsDomain(fs.InternalDomains, fqdn)
+}
+
+// FilterInternalDomainPair returns true if a connection from the source IP to an fqdn on the InternalDomains list
+// is filtered/excluded. The fqdn is treated as an internal destination, so this is determined by the following rules, in order:
+//  1. Not filtered if the fqdn is not on the InternalDomains list
+//  2. Not filtered if the fqdn or the source IP is on the AlwaysInclude list
+//  3. Filtered if the source IP is internal
+//  4. Filtered if the source IP is external and FilterExternalToInternal has been set in the configuration file
+//  5. Not filtered in all other cases
+func (fs *Filter) FilterInternalDomainPair(srcIP net.IP, fqdn string) bool {
+	if !fs.CheckIfInternalDomain(fqdn) {
+		return false
+	}
+
+	if util.ContainsDomain(fs.AlwaysIncludedDomains, fqdn) {
+		return false
+	}
+
+	return fs.FilterInternalDomainIPPair(srcIP)
+}
+
+// FilterInternalDomainIPPair returns true if a connection from the source IP to an IP that an fqdn on the
+// InternalDomains list resolved to is filtered/excluded. The IP is treated as an internal destination, so this is
+// determined by the following rules, in order:
+//  1. Not filtered if the source IP is on the AlwaysInclude list
+//  2. Filtered if the source IP is internal
+//  3. Filtered if the source IP is external and FilterExternalToInternal has been set in the configuration file
+//  4. Not filtered in all other cases
+func (fs *Filter) FilterInternalDomainIPPair(srcIP net.IP) bool {
+	if util.ContainsIP(fs.AlwaysIncludedSubnets, srcIP) {
+		return false
+	}
+
+	if fs.CheckIfInternal(srcIP) {
+		return true
+	}
+
+	return fs.FilterExternalToInternal
+}
+
+// FilterPort returns true if a connection to the destination port and protocol is filtered/excluded.
+// This is determined by the following rules, in order:
+//  1. Not filtered if port is on the AlwaysInclude list
+//  2. Filtered if port is on the NeverInclude list
+//  3. Not filtered in all other cases
+func (fs *Filter) FilterPort(port uint16, proto string) bool {
+	// check if on always included list
+	if util.ContainsPort(fs.AlwaysIncludedPorts, port, proto) {
+		return false
+	}
+
+	// check if on never included list
+	if util.ContainsPort(fs.NeverIncludedPorts, port, proto) {
+		return true
+	}
+
+	// default to not filter the port
+	return false
+}
+
+// FilterProto returns true if connections over the protocol are filtered/excluded. Only protocols other than
+// tcp and udp can be filtered, and only if FilterOtherProtocols is enabled.
+func (fs *Filter) FilterProto(proto string) bool {
+	return fs.FilterOtherProtocols && proto != "tcp" && proto != "udp"
+}
+
+// BelowMinConnectionBytes returns whether a connection transferred fewer payload bytes (orig_bytes + resp_bytes)
+// than the configured floor
+func (fs *Filter) BelowMinConnectionBytes(srcBytes, dstBytes int64) bool {
+	return fs.MinConnectionBytes > 0 && srcBytes+dstBytes < fs.MinConnectionBytes
+}
+
+// FilterSelfConnection returns true if the connection is from a host to itself and self connections are filtered
+func (fs *Filter) FilterSelfConnection(srcIP net.IP, dstIP net.IP) bool {
+	return fs.FilterSelfConnections && srcIP.Equal(dstIP)
+}
+
 func (fs *Filter) CheckIfInternal(host net.IP) bool {
 	return util.ContainsIP(fs.InternalSubnets, host)
 }
+
+// ClassifyIP returns how the filter treats a single IP address, using the same precedence as the import filters:
+//   - internal is true if the IP is in one of the InternalSubnets
+//   - included is true if the IP is on the AlwaysInclude list
+//   - excluded is true if the IP is on the NeverInclude list and not on the AlwaysInclude list
+//
+// IPv4-mapped IPv6 addresses are classified as their IPv4 equivalent.
+func (fs *Filter) ClassifyIP(ip net.IP) (internal bool, included bool, excluded bool) {
+	internal = util.ContainsIP(fs.InternalSubnets, ip)
+
+	// the AlwaysInclude list takes precedence over the NeverInclude list
+	included = util.ContainsIP(fs.AlwaysIncludedSubnets, ip)
+	if !included {
+		excluded = util.ContainsIP(fs.NeverIncludedSubnets, ip)
+	}
+
+	return internal, included, excluded
+}
@@ -4,6 +4,8 @@ import (
 	"net"
 	"testing"
 
+	"github.com/activecm/rita/v5/util"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -148,6 +150,109 @@ func TestFilterDNSPair(t *testing.T) {
 	})
 }
 
+func TestFilterRDPPair(t *testing.T) {
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	cfg.Filter.InternalSubnets = []*net.IPNet{
+		{IP: net.IP{10, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
+	}
+	cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{}
+	cfg.Filter.NeverIncludedSubnets = []*net.IPNet{
+		{IP: net.IP{10, 55, 0, 0}, Mask: net.IPMask{255, 255, 0, 0}},
+	}
+	cfg.Filter.FilterExternalToInternal = false
+
+	tests := []struct {
+		name                     string
+		src                      net.IP
+		dst                      net.IP
+		filterExternalToInternal bool
+		expected                 bool
+	}{
+		{name: "Internal to Internal", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 0, 0, 2}, expected: false},
+		{name: "Internal to External", src: net.IP{10, 0, 0, 1}, dst: net.IP{8, 8, 8, 8}, expected: false},
+		{name: "External to External", src: net.IP{1, 1, 1, 1}, dst: net.IP{8, 8, 8, 8}, expected: true},
+		{name: "External to Internal", src: net.IP{8, 8, 8, 8}, dst: net.IP{10, 0, 0, 1}, expected: false},
+		{name: "External to Internal, FilterExternalToInternal Set", src: net.IP{8, 8, 8, 8}, dst: net.IP{10, 0, 0, 1}, filterExternalToInternal: true, expected: true},
+		{name: "Internal to Internal, FilterExternalToInternal Set", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 0, 0, 2}, filterExternalToInternal: true, expected: false},
+		{name: "Never Included Destination", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 55, 0, 1}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg.Filter.FilterExternalToInternal = test.filterExternalToInternal
+			require.Equal(t, test.expected, cfg.Filter.FilterRDPPair(test.src, test.dst), "filter state should match expected value")
+		})
+	}
+}
+
+func TestFilterKerberosPair(t *testing.T) {
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	cfg.Filter.InternalSubnets = []*net.IPNet{
+		{IP: net.IP{10, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
+	}
+	cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{}
+	cfg.Filter.NeverIncludedSubnets = []*net.IPNet{
+		{IP: net.IP{10, 55, 0, 0}, Mask: net.IPMask{255, 255, 0, 0}},
+	}
+	cfg.Filter.FilterExternalToInternal = true
+
+	tests := []struct {
+		name     string
+		src      net.IP
+		dst      net.IP
+		expected bool
+	}{
+		{name: "Internal to Domain Controller", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 0, 0, 2}, expected: false},
+		{name: "Internal to External", src: net.IP{10, 0, 0, 1}, dst: net.IP{8, 8, 8, 8}, expected: false},
+		{name: "External to External", src: net.IP{1, 1, 1, 1}, dst: net.IP{8, 8, 8, 8}, expected: true},
+		{name: "External to Internal", src: net.IP{8, 8, 8, 8}, dst: net.IP{10, 0, 0, 1}, expected: true},
+		{name: "Never Included Destination", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 55, 0, 1}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, cfg.Filter.FilterKerberosPair(test.src, test.dst), "filter state should match expected value")
+		})
+	}
+}
+
+func TestFilterNTLMPair(t *testing.T) {
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	cfg.Filter.InternalSubnets = []*net.IPNet{
+		{IP: net.IP{10, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
+	}
+	cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{}
+	cfg.Filter.NeverIncludedSubnets = []*net.IPNet{
+		{IP: net.IP{10, 55, 0, 0}, Mask: net.IPMask{255, 255, 0, 0}},
+	}
+	cfg.Filter.FilterExternalToInternal = true
+
+	tests := []struct {
+		name     string
+		src      net.IP
+		dst      net.IP
+		expected bool
+	}{
+		{name: "Internal to File Server", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 0, 0, 2}, expected: false},
+		{name: "Internal to External", src: net.IP{10, 0, 0, 1}, dst: net.IP{8, 8, 8, 8}, expected: false},
+		{name: "External to External", src: net.IP{1, 1, 1, 1}, dst: net.IP{8, 8, 8, 8}, expected: true},
+		{name: "External to Internal", src: net.IP{8, 8, 8, 8}, dst: net.IP{10, 0, 0, 1}, expected: true},
+		{name: "Never Included Destination", src: net.IP{10, 0, 0, 1}, dst: net.IP{10, 55, 0, 1}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, cfg.Filter.FilterNTLMPair(test.src, test.dst), "filter state should match expected value")
+		})
+	}
+}
+
 func TestFilterSingleIP(t *testing.T) {
 	alwaysIncludedSubnetList := []*net.IPNet{
 		{IP: net.IP{35, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
@@ -208,6 +313,166 @@ func TestFilterDomain(t *testing.T) {
 	})
 }
 
+func TestCheckIfBeaconAllowlisted(t *testing.T) {
+	// load config
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	cfg.Filter.BeaconAllowlist = []string{"*.windowsupdate.com", "telemetry.example.com", "203.0.113.0/24", "198.51.100.7"}
+	require.NoError(t, cfg.parseFilter())
+
+	require.ElementsMatch(t, []string{"*.windowsupdate.com", "telemetry.example.com"}, cfg.Filter.BeaconAllowlistDomains)
+	require.Len(t, cfg.Filter.BeaconAllowlistSubnets, 2)
+
+	tests := []struct {
+		name     string
+		dstIP    net.IP
+		fqdn     string
+		expected bool
+	}{
+		{name: "Wildcard Domain", dstIP: net.IPv6unspecified, fqdn: "download.windowsupdate.com", expected: true},
+		{name: "Exact Domain", dstIP: net.IPv6unspecified, fqdn: "telemetry.example.com", expected: true},
+		{name: "Other Domain", dstIP: net.IPv6unspecified, fqdn: "example.com", expected: false},
+		{name: "IP In CIDR", dstIP: net.ParseIP("203.0.113.50"), expected: true},
+		{name: "Single IP", dstIP: net.ParseIP("198.51.100.7"), expected: true},
+		{name: "IP Outside Allowlist", dstIP: net.ParseIP("198.51.100.8"), expected: false},
+		{name: "IPv4-Mapped IP In CIDR", dstIP: net.ParseIP("::ffff:203.0.113.50"), expected: true},
+		{name: "No Destination", dstIP: nil, fqdn: "", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, cfg.Filter.CheckIfBeaconAllowlisted(test.dstIP, test.fqdn))
+		})
+	}
+
+	t.Run("Invalid Entries", func(t *testing.T) {
+		for _, entry := range []string{"", "bad domain.com", "10.0.0.0/33", "https://example.com"} {
+			cfg.Filter.BeaconAllowlist = []string{entry}
+			require.ErrorIs(t, cfg.parseFilter(), ErrInvalidBeaconAllowlistEntry, "beacon allowlist entry %q should produce an error", entry)
+		}
+	})
+}
+
+func TestFilterInternalDomainPair(t *testing.T) {
+	// load config
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+	require.NoError(t, cfg.parseFilter())
+
+	cfg.Filter.InternalDomains = []string{"intranet.example.com", "*.corp.example.com"}
+
+	internalIP := net.IP{10, 0, 0, 5}
+	externalIP := net.IP{65, 0, 0, 5}
+
+	tests := []struct {
+		name                     string
+		srcIP                    net.IP
+		fqdn                     string
+		filterExternalToInternal bool
+		alwaysIncludedDomains    []string
+		expectedInternal         bool
+		expectedFiltered         bool
+	}{
+		{name: "Internal To Internal Domain", srcIP: internalIP, fqdn: "intranet.example.com", expectedInternal: true, expectedFiltered: true},
+		{name: "Internal To Wildcard Subdomain", srcIP: internalIP, fqdn: "wiki.corp.example.com", expectedInternal: true, expectedFiltered: true},
+		{name: "Internal To Wildcard Top Domain", srcIP: internalIP, fqdn: "corp.example.com", expectedInternal: true, expectedFiltered: true},
+		{name: "Internal To External Domain", srcIP: internalIP, fqdn: "example.com", expectedInternal: false, expectedFiltered: false},
+		{name: "Empty FQDN", srcIP: internalIP, fqdn: "", expectedInternal: false, expectedFiltered: false},
+		{name: "External To Internal Domain, Filtered", srcIP: externalIP, fqdn: "intranet.example.com", filterExternalToInternal: true, expectedInternal: true, expectedFiltered: true},
+		{name: "External To Internal Domain, Not Filtered", srcIP: externalIP, fqdn: "intranet.example.com", filterExternalToInternal: false, expectedInternal: true, expectedFiltered: false},
+		{name: "Always Included Domain", srcIP: internalIP, fqdn: "intranet.example.com", alwaysIncludedDomains: []string{"intranet.example.com"}, expectedInternal: true, expectedFiltered: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg.Filter.FilterExternalToInternal = test.filterExternalToInternal
+			cfg.Filter.AlwaysIncludedDomains = test.alwaysIncludedDomains
+
+			require.Equal(t, test.expectedInternal, cfg.Filter.CheckIfInternalDomain(test.fqdn), "internal state should match expected value")
+			require.Equal(t, test.expectedFiltered, cfg.Filter.FilterInternalDomainPair(test.srcIP, test.fqdn), "filter state should match expected value")
+		})
+	}
+
+	t.Run("Resolved IPs Of Internal Domains", func(t *testing.T) {
+		cfg.Filter.AlwaysIncludedDomains = nil
+
+		cfg.Filter.FilterExternalToInternal = false
+		require.True(t, cfg.Filter.FilterInternalDomainIPPair(internalIP), "connections from internal hosts should be filtered")
+		require.False(t, cfg.Filter.FilterInternalDomainIPPair(externalIP), "connections from external hosts should be kept")
+
+		cfg.Filter.FilterExternalToInternal = true
+		require.True(t, cfg.Filter.FilterInternalDomainIPPair(externalIP), "connections from external hosts should be filtered with filter_external_to_internal")
+
+		cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{{IP: internalIP, Mask: net.CIDRMask(32, 32)}}
+		require.False(t, cfg.Filter.FilterInternalDomainIPPair(internalIP), "connections from always included hosts should be kept")
+		cfg.Filter.AlwaysIncludedSubnets = nil
+	})
+
+	t.Run("Invalid Internal Domains", func(t *testing.T) {
+		for _, domain := range []string{"", "bad domain.com", "-corp.example.com", "corp..example.com", "https://corp.example.com"} {
+			cfg.Filter.InternalDomains = []string{domain}
+			require.ErrorIs(t, cfg.parseFilter(), ErrInvalidInternalDomain, "internal domain %q should produce an error", domain)
+		}
+		cfg.Filter.InternalDomains = []string{"intranet", "*.corp.example.com"}
+		require.NoError(t, cfg.parseFilter())
+	})
+}
+
+func TestFilterPort(t *testing.T) {
+	// load config
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	cfg.Filter.NeverIncludedPorts = []util.PortRange{
+		{Start: 123, End: 123, Proto: "udp"},
+		{Start: 9000, End: 9200, Proto: "tcp"},
+	}
+	cfg.Filter.AlwaysIncludedPorts = []util.PortRange{
+		{Start: 9100, End: 9100, Proto: "tcp"},
+	}
+
+	t.Run("NeverInclude list test", func(t *testing.T) {
+		require.True(t, cfg.Filter.FilterPort(123, "udp"), "filter state should match expected value")
+		require.True(t, cfg.Filter.FilterPort(9001, "tcp"), "filter state should match expected value")
+	})
+
+	t.Run("Different protocol", func(t *testing.T) {
+		require.False(t, cfg.Filter.FilterPort(123, "tcp"), "filter state should match expected value")
+	})
+
+	t.Run("AlwaysInclude overrides NeverInclude", func(t *testing.T) {
+		require.False(t, cfg.Filter.FilterPort(9100, "tcp"), "filter state should match expected value")
+	})
+
+	t.Run("Port not on any list", func(t *testing.T) {
+		require.False(t, cfg.Filter.FilterPort(443, "tcp"), "filter state should match expected value")
+	})
+}
+
+func TestFilterProto(t *testing.T) {
+	// load config
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	t.Run("Other protocols included by default", func(t *testing.T) {
+		require.False(t, cfg.Filter.FilterProto("icmp"), "filter state should match expected value")
+		require.False(t, cfg.Filter.FilterProto("unknown_transport"), "filter state should match expected value")
+	})
+
+	cfg.Filter.FilterOtherProtocols = true
+
+	t.Run("Other protocols filtered", func(t *testing.T) {
+		require.True(t, cfg.Filter.FilterProto("icmp"), "filter state should match expected value")
+		require.True(t, cfg.Filter.FilterProto("unknown_transport"), "filter state should match expected value")
+	})
+
+	t.Run("TCP and UDP never filtered", func(t *testing.T) {
+		require.False(t, cfg.Filter.FilterProto("tcp"), "filter state should match expected value")
+		require.False(t, cfg.Filter.FilterProto("udp"), "filter state should match expected value")
+	})
+}
+
 func TestFilterNeverInclude(t *testing.T) {
 	// load config
 	cfg, err := GetDefaultConfig()
@@ -234,6 +499,144 @@ func TestFilterNeverInclude(t *testing.T) {
 	})
 }
 
+func TestNeverIncludedRanges(t *testing.T) {
+	t.Run("Named Ranges Are Expanded", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+
+		cfg.Filter.NeverIncludedRanges = []string{"cgnat", "documentation", "benchmarking"}
+		require.NoError(t, cfg.parseFilter())
+
+		// expanded subnets are listed alongside the mandatory never included subnets
+		require.Subset(t, cfg.Filter.NeverIncludedSubnetsJSON, GetMandatoryNeverIncludeSubnets())
+		require.Subset(t, cfg.Filter.NeverIncludedSubnetsJSON, []string{
+			"100.64.0.0/10", "192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", "2001:db8::/32", "198.18.0.0/15", "2001:2::/48",
+		})
+		require.Len(t, cfg.Filter.NeverIncludedSubnets, len(cfg.Filter.NeverIncludedSubnetsJSON))
+
+		for _, ip := range []string{"100.64.1.1", "100.127.255.254", "192.0.2.10", "2001:db8::1", "198.19.0.1"} {
+			require.True(t, cfg.Filter.FilterSingleIP(net.ParseIP(ip)), "%s should be filtered", ip)
+		}
+		require.False(t, cfg.Filter.FilterSingleIP(net.ParseIP("100.128.0.1")), "100.128.0.1 should not be filtered")
+	})
+
+	t.Run("Parsing Twice Does Not Duplicate Subnets", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+
+		cfg.Filter.NeverIncludedRanges = []string{"rfc1918"}
+		require.NoError(t, cfg.parseFilter())
+		count := len(cfg.Filter.NeverIncludedSubnetsJSON)
+		require.NoError(t, cfg.parseFilter())
+		require.Len(t, cfg.Filter.NeverIncludedSubnetsJSON, count)
+	})
+
+	t.Run("Unknown Named Range", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+
+		cfg.Filter.NeverIncludedRanges = []string{"cgnat", "bogus"}
+		require.ErrorIs(t, cfg.parseFilter(), ErrUnknownNamedRange)
+	})
+}
+
+func TestAnalyzeSpecialUseRanges(t *testing.T) {
+	specialUseIPs := []string{"127.0.0.1", "169.254.10.20", "::1", "fe80::1"}
+
+	t.Run("Disabled", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+
+		for _, ip := range specialUseIPs {
+			require.True(t, cfg.Filter.FilterSingleIP(net.ParseIP(ip)), "%s should be filtered", ip)
+		}
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+
+		cfg.Filter.AnalyzeSpecialUseRanges = true
+		require.NoError(t, cfg.parseFilter())
+
+		for _, ip := range specialUseIPs {
+			require.False(t, cfg.Filter.FilterSingleIP(net.ParseIP(ip)), "%s should not be filtered", ip)
+		}
+		require.False(t, cfg.Filter.FilterConnPair(net.ParseIP("10.0.0.5"), net.ParseIP("169.254.169.254")), "link-local connections should be analyzed")
+
+		// the rest of the mandatory never included subnets are still filtered
+		for _, ip := range []string{"224.0.0.251", "255.255.255.255", "0.0.0.0", "ff02::1", "::"} {
+			require.True(t, cfg.Filter.FilterSingleIP(net.ParseIP(ip)), "%s should still be filtered", ip)
+		}
+		require.NotContains(t, cfg.Filter.NeverIncludedSubnetsJSON, "127.0.0.0/8")
+		require.Len(t, cfg.Filter.NeverIncludedSubnets, len(cfg.Filter.NeverIncludedSubnetsJSON))
+	})
+
+	t.Run("Parsing Twice Keeps Them Removed", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+
+		cfg.Filter.AnalyzeSpecialUseRanges = true
+		require.NoError(t, cfg.parseFilter())
+		require.NoError(t, cfg.parseFilter())
+		require.False(t, cfg.Filter.FilterSingleIP(net.ParseIP("fe80::1")))
+	})
+}
+
+func TestClassifyIP(t *testing.T) {
+	// load config
+	cfg, err := GetDefaultConfig()
+	require.NoError(t, err)
+
+	cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{
+		{IP: net.IP{10, 55, 0, 0}, Mask: net.IPMask{255, 255, 0, 0}},
+		{IP: net.IP{169, 254, 169, 254}, Mask: net.IPMask{255, 255, 255, 255}},
+	}
+	cfg.Filter.NeverIncludedSubnets = append(cfg.Filter.NeverIncludedSubnets,
+		&net.IPNet{IP: net.IP{10, 55, 0, 0}, Mask: net.IPMask{255, 255, 0, 0}},
+		&net.IPNet{IP: net.IP{52, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
+	)
+
+	tests := []struct {
+		name     string
+		ip       net.IP
+		internal bool
+		included bool
+		excluded bool
+	}{
+		{name: "Internal IPv4", ip: net.ParseIP("192.168.1.10"), internal: true},
+		{name: "External IPv4", ip: net.ParseIP("8.8.8.8")},
+		{name: "IPv4-Mapped IPv6 Internal", ip: net.ParseIP("::ffff:10.1.2.3"), internal: true},
+		{name: "IPv4-Mapped IPv6 External", ip: net.ParseIP("::ffff:8.8.8.8")},
+		{name: "IPv4-Mapped IPv6 Never Included", ip: net.ParseIP("::ffff:52.1.2.3"), excluded: true},
+		{name: "Internal IPv6", ip: net.ParseIP("fd00::1"), internal: true},
+		{name: "Never Included", ip: net.ParseIP("52.1.2.3"), excluded: true},
+		{name: "Always Included Overrides Never Included", ip: net.ParseIP("10.55.1.1"), internal: true, included: true},
+		{name: "Always Included Overrides Mandatory Never Included", ip: net.ParseIP("169.254.169.254"), included: true},
+		{name: "Mandatory Loopback", ip: net.ParseIP("127.0.0.1"), excluded: true},
+		{name: "Mandatory Link Local", ip: net.ParseIP("169.254.1.1"), excluded: true},
+		{name: "Mandatory Multicast", ip: net.ParseIP("224.0.0.251"), excluded: true},
+		{name: "Mandatory Broadcast", ip: net.IPv4bcast, excluded: true},
+		{name: "Mandatory Current Host", ip: net.IPv4zero, excluded: true},
+		{name: "Mandatory IPv6 Loopback", ip: net.IPv6loopback, excluded: true},
+		{name: "Mandatory IPv6 Unspecified", ip: net.IPv6unspecified, excluded: true},
+		{name: "Mandatory IPv6 Link Local", ip: net.ParseIP("fe80::1"), excluded: true},
+		{name: "Mandatory IPv6 Multicast", ip: net.ParseIP("ff02::1"), excluded: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			internal, included, excluded := cfg.Filter.ClassifyIP(test.ip)
+			require.Equal(t, test.internal, internal, "internal state should match expected value")
+			require.Equal(t, test.included, included, "included state should match expected value")
+			require.Equal(t, test.excluded, excluded, "excluded state should match expected value")
+
+			// FilterSingleIP should agree with the classification
+			require.Equal(t, test.excluded, cfg.Filter.FilterSingleIP(test.ip), "filter state should match expected value")
+		})
+	}
+}
+
 func TestCheckIfInternal(t *testing.T) {
 	internalSubnetList := []*net.IPNet{
 		{IP: net.IP{11, 0, 0, 0}, Mask: net.IPMask{255, 0, 0, 0}},
@@ -278,3 +681,13 @@ func TestCheckIfInternal(t *testing.T) {
 	})
 
 }
+
+func TestBelowMinConnectionBytes(t *testing.T) {
+	filter := Filter{MinConnectionBytes: 100}
+	require.True(t, filter.BelowMinConnectionBytes(0, 0))
+	require.True(t, filter.BelowMinConnectionBytes(60, 39))
+	require.False(t, filter.BelowMinConnectionBytes(60, 40), "connections at the floor should be kept")
+
+	filter.MinConnectionBytes = 0
+	require.False(t, filter.BelowMinConnectionBytes(0, 0), "a floor of 0 should keep every connection")
+}
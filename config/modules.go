@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// names of the analysis modules that can be listed in enabled_modules and disabled_modules
+const (
+	ModuleBeacons         = "beacons"
+	ModuleLongConnections = "long_connections"
+	ModuleStrobes         = "strobes"
+	ModuleC2OverDNS       = "c2_over_dns"
+	ModuleThreatIntel     = "threat_intel"
+	ModulePrevalence      = "prevalence"
+	ModuleFirstSeen       = "first_seen"
+	ModuleMIMEMismatch    = "mime_mismatch"
+	ModuleRareSignatures  = "rare_signatures"
+)
+
+var ErrUnknownModule = errors.New("unknown analysis module")
+
+// analysisModules are the analysis modules in the order that they are listed in errors
+var analysisModules = []string{
+	ModuleBeacons,
+	ModuleLongConnections,
+	ModuleStrobes,
+	ModuleC2OverDNS,
+	ModuleThreatIntel,
+	ModulePrevalence,
+	ModuleFirstSeen,
+	ModuleMIMEMismatch,
+	ModuleRareSignatures,
+}
+
+// GetAnalysisModules returns the names of the analysis modules that can be enabled or disabled
+func GetAnalysisModules() []string {
+	return slices.Clone(analysisModules)
+}
+
+// ModuleEnabled returns whether the analysis module should run. If enabled_modules is set, only the modules in it run,
+// and any module in disabled_modules never runs.
+func (cfg *Config) ModuleEnabled(module string) bool {
+	if len(cfg.EnabledModules) > 0 && !slices.Contains(cfg.EnabledModules, module) {
+		return false
+	}
+	return !slices.Contains(cfg.DisabledModules, module)
+}
+
+// validateModules checks that every module in enabled_modules and disabled_modules is a known analysis module
+func (cfg *Config) validateModules() error {
+	for _, list := range []struct {
+		name    string
+		modules []string
+	}{
+		{"enabled_modules", cfg.EnabledModules},
+		{"disabled_modules", cfg.DisabledModules},
+	} {
+		for _, module := range list.modules {
+			if !slices.Contains(analysisModules, module) {
+				return fmt.Errorf("%w %q in %s, must be one of %v", ErrUnknownModule, module, list.name, analysisModules)
+			}
+		}
+	}
+	return nil
+}
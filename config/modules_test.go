@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleEnabled(t *testing.T) {
+	t.Run("All Modules Run By Default", func(t *testing.T) {
+		cfg, err := GetDefaultConfig()
+		require.NoError(t, err)
+		for _, module := range GetAnalysisModules() {
+			require.True(t, cfg.ModuleEnabled(module), "module %s should be enabled by default", module)
+		}
+	})
+
+	t.Run("Disabled Modules", func(t *testing.T) {
+		cfg := Config{DisabledModules: []string{ModuleBeacons}}
+		require.False(t, cfg.ModuleEnabled(ModuleBeacons))
+		require.True(t, cfg.ModuleEnabled(ModuleStrobes), "disabling beacons should not disable strobes")
+	})
+
+	t.Run("Enabled Modules", func(t *testing.T) {
+		cfg := Config{EnabledModules: []string{ModuleStrobes, ModuleThreatIntel}}
+		require.True(t, cfg.ModuleEnabled(ModuleStrobes))
+		require.True(t, cfg.ModuleEnabled(ModuleThreatIntel))
+		require.False(t, cfg.ModuleEnabled(ModuleBeacons), "modules that aren't listed should not run")
+	})
+
+	t.Run("Disabled Modules Take Precedence", func(t *testing.T) {
+		cfg := Config{EnabledModules: []string{ModuleStrobes, ModulePrevalence}, DisabledModules: []string{ModulePrevalence}}
+		require.True(t, cfg.ModuleEnabled(ModuleStrobes))
+		require.False(t, cfg.ModuleEnabled(ModulePrevalence))
+	})
+}
+
+func TestValidateModules(t *testing.T) {
+	cfg := Config{EnabledModules: GetAnalysisModules(), DisabledModules: []string{ModuleFirstSeen}}
+	require.NoError(t, cfg.validateModules())
+
+	cfg.EnabledModules = []string{ModuleBeacons, "beacon"}
+	require.ErrorIs(t, cfg.validateModules(), ErrUnknownModule)
+
+	cfg.EnabledModules = nil
+	cfg.DisabledModules = []string{"bogus"}
+	require.ErrorIs(t, cfg.validateModules(), ErrUnknownModule)
+}
@@ -22,6 +22,7 @@ func (db *DB) createThreatMixtapeTable(ctx context.Context) error {
 			total_bytes UInt64,
 			last_seen DateTime(),
 			port_proto_service Array(String),
+			sensor String,
 
 			-- counts
 			count UInt64,
@@ -36,6 +37,10 @@ func (db *DB) createThreatMixtapeTable(ctx context.Context) error {
 			-- **** THREAT INDICATORS ****
 			-- BEACONING
 			beacon_type LowCardinality(String),
+			allowlisted Bool,
+			dst_country LowCardinality(String),
+			dst_asn UInt32,
+			dst_as_org String,
 			beacon_score Float32,
 			beacon_threat_score Float32,
 			ts_score Float32,
@@ -44,8 +49,13 @@ func (db *DB) createThreatMixtapeTable(ctx context.Context) error {
 			hist_score Float32,
 			ts_intervals Array(Int64),
 			ts_interval_counts Array(Int64),
+			ts_interval_p50 Int64,
+			ts_interval_p90 Int64,
+			ts_interval_p99 Int64,
 			ds_sizes Array(Int64),
 			ds_size_counts Array(Int64),
+			hist_bin_edges Array(Float64),
+			hist_counts Array(Int64),
 			
 			-- LONG CONNECTIONS
 			total_duration Float64,
@@ -82,7 +92,10 @@ func (db *DB) createThreatMixtapeTable(ctx context.Context) error {
 
 			-- MISSING HOST HEADER
 			missing_host_count UInt64,
-			missing_host_header_score Float32
+			missing_host_header_score Float32,
+
+			-- MISSING RESPONDER BYTES
+			missing_bytes_count UInt64
 
 		) ENGINE = MergeTree()
 		PRIMARY KEY (analyzed_at, dst_nuid, src_nuid, src, fqdn, dst, hash)
@@ -91,101 +104,110 @@ func (db *DB) createThreatMixtapeTable(ctx context.Context) error {
 	return err
 }
 
+// createHistoricalFirstSeenMaterializedViews records the first and last time each external host and domain was seen,
+// keyed by the network UUID of the internal host that talked to it
 func (db *DB) createHistoricalFirstSeenMaterializedViews(ctx context.Context) error {
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_conn_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				if(src_local = true, dst, src) as ip,
 				'' as fqdn,
+				if(src_local = true, src_nuid, dst_nuid) as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.conn
-		GROUP BY (fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_openconn_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				if(src_local = true, dst, src) as ip,
 				'' as fqdn,
+				if(src_local = true, src_nuid, dst_nuid) as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.openconn
-		GROUP BY (fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_ssl_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				'::' as ip,
 				server_name as fqdn,
+				src_nuid as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.ssl
-		GROUP BY ( fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_openssl_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				'::' as ip,
 				server_name as fqdn,
+				src_nuid as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.openssl
-		GROUP BY ( fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_http_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				'::' as ip,
 				host as fqdn,
+				src_nuid as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.http
-		GROUP BY ( fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_openhttp_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				'::' as ip,
 				host as fqdn,
+				src_nuid as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.openhttp
-		GROUP BY ( fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.historical_first_seen_dns_mv
-		TO metadatabase.historical_first_seen AS
+		TO {metadatabase:Identifier}.historical_first_seen AS
 			SELECT
 				'::' as ip,
 				query as fqdn,
+				src_nuid as nuid,
 				minSimpleState(ts) as first_seen,
 				maxSimpleState(ts) as last_seen
 		FROM {database:Identifier}.dns
-		GROUP BY ( fqdn, ip)
+		GROUP BY (fqdn, ip, nuid)
 	`); err != nil {
 		return err
 	}
@@ -213,6 +235,12 @@ func (db *DB) createMIMETypeURIsTable(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+
+	// only fill the table while the mime_mismatch module is enabled, since it is the only module that reads it
+	if !db.mimeMismatch {
+		return db.dropMaterializedViews("mime_type_uris_mv")
+	}
+
 	// This view is used to detect MIME type/URI mismatches
 	// If a HTTP connection's MIME type matches a MIME type in the metadatabase.valid_mime_types table
 	// and its extension does not match the associated values for that MIME type, then it should be added to this table
@@ -238,7 +266,7 @@ func (db *DB) createMIMETypeURIsTable(ctx context.Context) error {
 		-- for each uri, get the extension and join it with the valid mime types, 
 		-- keeping only the rows where the extension does not match the valid extension
 	    ARRAY JOIN dst_mime_types
-		LEFT SEMI JOIN metadatabase.valid_mime_types v ON dst_mime_types = v.mime_type
+		LEFT SEMI JOIN {metadatabase:Identifier}.valid_mime_types v ON dst_mime_types = v.mime_type
 		WHERE uri != '/' AND extension != v.extension
 		GROUP BY import_hour, hour, hash, uri, path, extension, mime_type
 	`)
@@ -273,6 +301,11 @@ func (db *DB) createRareSignatureTable(ctx context.Context) error {
 		return err
 	}
 
+	// only fill the table while the rare_signatures module is enabled, since it is the only module that reads it
+	if !db.rareSignatures {
+		return db.dropMaterializedViews("rare_signatures_http_mv", "rare_signatures_ssl_mv", "rare_signatures_missing_host_mv")
+	}
+
 	err = db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.rare_signatures_http_mv
 		TO {database:Identifier}.rare_signatures AS
@@ -340,6 +373,22 @@ func (db *DB) createRareSignatureTable(ctx context.Context) error {
 	return err
 
 }
+
+// dropMaterializedViews removes the materialized views of an analysis module that was disabled after they were created,
+// so that they stop filling their tables. The views are created again when the module is enabled.
+func (db *DB) dropMaterializedViews(views ...string) error {
+	for _, view := range views {
+		ctx := db.QueryParameters(clickhouse.Parameters{
+			"database": db.selected,
+			"view":     view,
+		})
+		if err := db.Conn.Exec(ctx, "DROP VIEW IF EXISTS {database:Identifier}.{view:Identifier}"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *DB) createPortInfoTable(ctx context.Context) error {
 
 	if err := db.Conn.Exec(ctx, `--sql
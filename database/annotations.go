@@ -0,0 +1,108 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+const (
+	AnnotationStatusInvestigated = "investigated"
+	AnnotationStatusBenign       = "benign"
+	AnnotationStatusMalicious    = "malicious"
+)
+
+// AnnotationStatuses are the triage statuses that an analyst can give a result
+var AnnotationStatuses = []string{AnnotationStatusInvestigated, AnnotationStatusBenign, AnnotationStatusMalicious}
+
+var ErrInvalidAnnotationStatus = fmt.Errorf("status must be one of: %s", strings.Join(AnnotationStatuses, ", "))
+
+// Annotation is the triage status and note that an analyst gave the results with a hash
+type Annotation struct {
+	Hash      string    `ch:"hash_hex" json:"-"` // hex encoded
+	Status    string    `ch:"status" json:"status"`
+	Note      string    `ch:"note" json:"note"`
+	UpdatedAt time.Time `ch:"updated_at" json:"updated_at"`
+}
+
+// createMetaDatabaseAnnotationsTable creates the metadatabase.annotations table, which stores the triage status of
+// results. Annotations are keyed by the result's hash rather than its import so that they survive re-imports and
+// rebuilds of the dataset.
+func (server *ServerConn) createMetaDatabaseAnnotationsTable() error {
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `--sql
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.annotations (
+			database String,
+			hash FixedString(16),
+			-- updated_at is measured in microseconds so that the most recent annotation wins
+			updated_at DateTime64(6),
+			status LowCardinality(String),
+			note String
+		)
+		ENGINE = ReplacingMergeTree(updated_at)
+		ORDER BY (database, hash)
+	`)
+	return err
+}
+
+// ValidateAnnotationStatus checks that the status is one of the triage statuses
+func ValidateAnnotationStatus(status string) error {
+	if !slices.Contains(AnnotationStatuses, status) {
+		return ErrInvalidAnnotationStatus
+	}
+	return nil
+}
+
+// Annotate stores the triage status and note of the results with the hex encoded hash in the specified database,
+// replacing any earlier annotation of those results
+func (server *ServerConn) Annotate(database, hash, status, note string) error {
+	if err := ValidateAnnotationStatus(status); err != nil {
+		return err
+	}
+
+	ctx := server.QueryParameters(clickhouse.Parameters{
+		"database": database,
+		"hash":     hash,
+		"status":   status,
+		"note":     note,
+	})
+
+	err := server.Conn.Exec(ctx, `
+		INSERT INTO {metadatabase:Identifier}.annotations (database, hash, updated_at, status, note)
+		VALUES ({database:String}, unhex({hash:String}), now64(6), {status:String}, {note:String})
+	`)
+	return err
+}
+
+// GetAnnotations returns the most recent annotation of each annotated hash in the selected database, keyed by the
+// upper case hex encoded hash
+func (db *DB) GetAnnotations() (map[string]Annotation, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{"database": db.selected})
+
+	rows, err := db.ReadConn.Query(ctx, `--sql
+		SELECT hex(hash) AS hash_hex,
+			argMax(status, updated_at) AS status,
+			argMax(note, updated_at) AS note,
+			max(updated_at) AS updated_at
+		FROM {metadatabase:Identifier}.annotations
+		WHERE database = {database:String}
+		GROUP BY hash
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not query annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := make(map[string]Annotation)
+	for rows.Next() {
+		var annotation Annotation
+		if err := rows.ScanStruct(&annotation); err != nil {
+			return nil, fmt.Errorf("could not read annotation: %w", err)
+		}
+		annotations[annotation.Hash] = annotation
+	}
+
+	return annotations, rows.Err()
+}
@@ -0,0 +1,17 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAnnotationStatus(t *testing.T) {
+	for _, status := range AnnotationStatuses {
+		require.NoError(t, ValidateAnnotationStatus(status), "%s should be a valid status", status)
+	}
+
+	require.ErrorIs(t, ValidateAnnotationStatus(""), ErrInvalidAnnotationStatus, "empty status should be invalid")
+	require.ErrorIs(t, ValidateAnnotationStatus("Benign"), ErrInvalidAnnotationStatus, "statuses should be case sensitive")
+	require.ErrorIs(t, ValidateAnnotationStatus("suspicious"), ErrInvalidAnnotationStatus)
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+var ErrIncompatibleSchema = errors.New("source database schema is not compatible")
+
+// CombineSourceTables are the tables that hold the parsed log data for a dataset, along with the hosts whose DNS
+// queries were dropped while parsing it. Every other table in a sensor database is derived from these by materialized
+// views or by analysis, so copying them into a new database is enough to rebuild its aggregates.
+var CombineSourceTables = []string{"conn", "openconn", "http", "openhttp", "ssl", "openssl", "dns", "pdns_raw", "rdp", "ftp_proto", "x509", "kerberos_proto", "ntlm_proto", "dns_floods"}
+
+// combineImportIDTables are the combine source tables that also record the import that wrote each record
+var combineImportIDTables = []string{"dns_floods"}
+
+// TableColumn is a single column definition of a table
+type TableColumn struct {
+	Name string `ch:"name"`
+	Type string `ch:"type"`
+}
+
+// GetTableColumns returns the column definitions of a table, in order
+func (server *ServerConn) GetTableColumns(dbName, table string) ([]TableColumn, error) {
+	ctx := server.QueryParameters(clickhouse.Parameters{
+		"database": dbName,
+		"table":    table,
+	})
+
+	var columns []TableColumn
+	err := server.Conn.Select(ctx, &columns, `
+		SELECT name, type FROM system.columns
+		WHERE database = {database:String} AND table = {table:String}
+		ORDER BY position
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return columns, nil
+}
+
+// CheckSchemaCompatibility returns an error if any of the combine source tables in the source database
+// are missing or have columns that differ from the same table in the target database
+func (server *ServerConn) CheckSchemaCompatibility(target, source string) error {
+	for _, table := range CombineSourceTables {
+		targetColumns, err := server.GetTableColumns(target, table)
+		if err != nil {
+			return err
+		}
+
+		sourceColumns, err := server.GetTableColumns(source, table)
+		if err != nil {
+			return err
+		}
+
+		if len(sourceColumns) == 0 {
+			return fmt.Errorf("%w: %s is missing the %s table", ErrIncompatibleSchema, source, table)
+		}
+
+		if len(sourceColumns) != len(targetColumns) {
+			return fmt.Errorf("%w: %s.%s has %d columns, expected %d", ErrIncompatibleSchema, source, table, len(sourceColumns), len(targetColumns))
+		}
+
+		for i := range sourceColumns {
+			if sourceColumns[i] != targetColumns[i] {
+				return fmt.Errorf("%w: %s.%s column %s %s does not match %s %s", ErrIncompatibleSchema, source, table,
+					sourceColumns[i].Name, sourceColumns[i].Type, targetColumns[i].Name, targetColumns[i].Type)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CopySourceData copies the log data of the combine source tables from the source database into the
+// selected database. The import time and import id of every copied record are set to the current import's
+// so that the copied data is analyzed as part of the current import.
+func (db *DB) CopySourceData(source string, importID util.FixedString) error {
+	for _, table := range CombineSourceTables {
+		ctx := db.QueryParameters(clickhouse.Parameters{
+			"database":   db.selected,
+			"source":     source,
+			"table":      table,
+			"importTime": strconv.FormatInt(db.ImportStartedAt.UTC().Unix(), 10),
+			"importID":   importID.Hex(),
+		})
+
+		replace := "fromUnixTimestamp({importTime:Int64}) AS import_time"
+		if slices.Contains(combineImportIDTables, table) {
+			replace += ", unhex({importID:String}) AS import_id"
+		}
+
+		err := db.Conn.Exec(ctx, `
+			INSERT INTO {database:Identifier}.{table:Identifier}
+			SELECT * REPLACE (`+replace+`)
+			FROM {source:Identifier}.{table:Identifier}
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to copy %s from %s: %w", table, source, err)
+		}
+	}
+
+	return nil
+}
@@ -21,13 +21,24 @@ var ErrInvalidMinMaxTimestamp = fmt.Errorf("invalid min or max timestamp")
 
 // DB is the workhorse container for messing with the database
 type DB struct {
-	Conn            driver.Conn
-	selected        string
-	Rolling         bool
-	rebuild         bool
-	ctx             context.Context
-	cancel          context.CancelFunc
-	ImportStartedAt time.Time
+	Conn driver.Conn
+	// ReadConn is used for read-only viewing, querying, and exporting queries. It connects to the read replica
+	// if one is configured, otherwise it is the same connection as Conn. Queries that read data written earlier in
+	// the same run, like analysis and modifiers, use Conn since the replica may not have caught up yet.
+	ReadConn     driver.Conn
+	selected     string
+	metaDatabase string
+	// scoreDecayHalfLife is the half life, in hours, of the current score of the results in rolling datasets
+	scoreDecayHalfLife float64
+	Rolling            bool
+	rebuild            bool
+	ctx                context.Context
+	cancel             context.CancelFunc
+	ImportStartedAt    time.Time
+	// rareSignatures and mimeMismatch are whether the analysis modules that read the rare_signatures and
+	// mime_type_uris tables are enabled, the materialized views that fill those tables are only kept while they are
+	rareSignatures bool
+	mimeMismatch   bool
 }
 
 // GetSelectedDB returns the name of the target database of db connection
@@ -37,7 +48,32 @@ func (db *DB) GetSelectedDB() string {
 
 // QueryParameters generates ClickHouse query parameters by creating a context with the specified parameters in it
 func (db *DB) QueryParameters(params clickhouse.Parameters) context.Context {
-	return clickhouse.Context(db.ctx, clickhouse.WithParameters(params))
+	return clickhouse.Context(db.ctx, clickhouse.WithParameters(withMetaDatabase(params, db.metaDatabase)))
+}
+
+// GetMetaDatabase returns the name of the metadatabase that db records its imports in
+func (db *DB) GetMetaDatabase() string {
+	return db.metaDatabase
+}
+
+// GetScoreDecayHalfLife returns the number of hours that it takes for the current score of a result in a rolling
+// dataset to halve, 0 if scores don't decay
+func (db *DB) GetScoreDecayHalfLife() float64 {
+	return db.scoreDecayHalfLife
+}
+
+// WithContext returns a copy of db that shares its connections but runs queries with ctx,
+// which allows a group of queries to be cancelled without closing the connection
+func (db *DB) WithContext(ctx context.Context) *DB {
+	bounded := *db
+	bounded.ctx = ctx
+	return &bounded
+}
+
+// SingleThreaded returns a copy of db whose queries are run on a single thread, so that the rows of a query
+// without an ORDER BY are returned in the same order each time that the same data is read
+func (db *DB) SingleThreaded() *DB {
+	return db.WithContext(clickhouse.Context(db.ctx, clickhouse.WithSettings(clickhouse.Settings{"max_threads": 1})))
 }
 
 // GetContext returns the context for the database connection
@@ -50,6 +86,11 @@ func (db *DB) getConn() driver.Conn {
 	return db.Conn
 }
 
+// Close closes the connection to the database and to the read replica, if one is configured
+func (db *DB) Close() error {
+	return closeConns(db.Conn, db.ReadConn)
+}
+
 func (db *DB) GetBeaconMinMaxTimestamps() (time.Time, time.Time, bool, error) {
 
 	var minTS, maxTS time.Time
@@ -68,7 +109,7 @@ func (db *DB) GetBeaconMinMaxTimestamps() (time.Time, time.Time, bool, error) {
 	// max timestamp: max timestamp in the logs
 	err := db.Conn.QueryRow(ctx, `
 		SELECT greatest(min_ts, timestamp_sub(HOUR, 24, max_ts)) as min_ts, max_ts FROM (
-			SELECT min(min_ts) AS min_ts, max(max_ts) AS max_ts FROM metadatabase.min_max
+			SELECT min(min_ts) AS min_ts, max(max_ts) AS max_ts FROM {metadatabase:Identifier}.min_max
 			WHERE database = {database:String} AND beacon = true
 			GROUP BY database
 		)
@@ -92,6 +133,33 @@ func (db *DB) GetBeaconMinMaxTimestamps() (time.Time, time.Time, bool, error) {
 
 }
 
+// GetDatasetMinTimestamp returns the earliest timestamp that has been imported into the dataset,
+// unlike GetTrueMinMaxTimestamps, this is not limited to the last 24 hours of the dataset
+func (db *DB) GetDatasetMinTimestamp() (time.Time, error) {
+	if db.Conn == nil {
+		return time.Unix(0, 0), ErrInvalidDatabaseConnection
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	var minTS time.Time
+	err := db.Conn.QueryRow(ctx, `
+		SELECT min(min_ts) AS min_ts FROM {metadatabase:Identifier}.min_max
+		WHERE database = {database:String}
+	`).Scan(&minTS)
+	if err != nil {
+		return time.Unix(0, 0), err
+	}
+
+	if minTS.IsZero() || minTS.Unix() == 0 {
+		return time.Unix(0, 0), ErrInvalidMinMaxTimestamp
+	}
+
+	return minTS, nil
+}
+
 func (db *DB) GetTrueMinMaxTimestamps() (time.Time, time.Time, bool, bool, error) {
 	logger := zlog.GetLogger()
 
@@ -103,7 +171,7 @@ func (db *DB) GetTrueMinMaxTimestamps() (time.Time, time.Time, bool, bool, error
 		return time.Unix(0, 0), time.Unix(0, 0), false, false, ErrInvalidDatabaseConnection
 	}
 
-	rolling, err := GetRollingStatus(db.GetContext(), db.Conn, db.GetSelectedDB())
+	rolling, err := GetRollingStatus(db.GetContext(), db.Conn, db.GetMetaDatabase(), db.GetSelectedDB())
 	if err != nil && !errors.Is(err, ErrDatabaseNotFound) {
 		return time.Unix(0, 0), time.Unix(0, 0), false, false, err
 	}
@@ -118,7 +186,7 @@ func (db *DB) GetTrueMinMaxTimestamps() (time.Time, time.Time, bool, bool, error
 	// max timestamp: max timestamp in the logs
 	err = db.Conn.QueryRow(ctx, `
 		SELECT greatest(min_ts, timestamp_sub(HOUR, 24, max_ts)) as min_ts, max_ts FROM (
-			SELECT min(min_ts) AS min_ts, max(max_ts) AS max_ts FROM metadatabase.min_max
+			SELECT min(min_ts) AS min_ts, max(max_ts) AS max_ts FROM {metadatabase:Identifier}.min_max
 			WHERE database = {database:String} 
 			GROUP BY database
 		)
@@ -246,6 +314,12 @@ func (db *DB) TruncateTmpLinkTables() error {
 	`); err != nil {
 		return err
 	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		TRUNCATE TABLE IF EXISTS {database:Identifier}.rdp_tmp
+	`); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -309,9 +383,41 @@ func (db *DB) ResetTemporaryTables() error {
 
 // ConnectToDB sets up a new connection to the specified database
 func ConnectToDB(ctx context.Context, db string, cfg *config.Config, cancel context.CancelFunc) (*DB, error) {
+	conn, err := openDBConn(ctx, cfg.DBConnection, db, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// send read-only queries to the read replica if one is configured
+	readConn := conn
+	if cfg.DBReadConnection != "" {
+		readConn, err = openDBConn(ctx, cfg.DBReadConnection, db, cfg)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	// fmt.Println("Validated connection to database", db)
+
+	return &DB{
+		Conn:               conn,
+		ReadConn:           readConn,
+		ctx:                ctx,
+		cancel:             cancel,
+		selected:           db,
+		metaDatabase:       metaDatabaseName(cfg),
+		scoreDecayHalfLife: cfg.Scoring.ScoreDecayHalfLifeHours,
+		rareSignatures:     cfg.ModuleEnabled(config.ModuleRareSignatures),
+		mimeMismatch:       cfg.ModuleEnabled(config.ModuleMIMEMismatch),
+	}, nil
+}
+
+// openDBConn opens and verifies a connection to the specified database on the ClickHouse server at addr
+func openDBConn(ctx context.Context, addr string, db string, cfg *config.Config) (driver.Conn, error) {
 	// connect to the database
 	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{cfg.DBConnection},
+		Addr: []string{addr},
 		Auth: clickhouse.Auth{
 			Database: db,
 			Username: "default",
@@ -364,20 +470,25 @@ func ConnectToDB(ctx context.Context, db string, cfg *config.Config, cancel cont
 		return nil, err
 	}
 
-	// fmt.Println("Validated connection to database", db)
+	return conn, nil
+}
 
-	return &DB{
-		Conn:     conn,
-		ctx:      ctx,
-		cancel:   cancel,
-		selected: db,
-	}, nil
+// closeConns closes the primary connection and the read connection if it is a separate connection
+func closeConns(conn driver.Conn, readConn driver.Conn) error {
+	var errs []error
+	if conn != nil {
+		errs = append(errs, conn.Close())
+	}
+	if readConn != nil && readConn != conn {
+		errs = append(errs, readConn.Close())
+	}
+	return errors.Join(errs...)
 }
 
 // GetFirstSeenTimestamp gets the relative timestamp to use for calculating/displaying first seen.
 // Returns max timestamp, whether or not to use the current time, and error
 // func (db *DB) GetFirstSeenTimestamp() (time.Time, time.Time, bool, error) {
-// 	rolling, err := GetRollingStatus(db.GetContext(), db.Conn, db.GetSelectedDB())
+// 	rolling, err := GetRollingStatus(db.GetContext(), db.Conn, db.GetMetaDatabase(), db.GetSelectedDB())
 // 	if err != nil {
 // 		return time.Unix(0, 0), time.Unix(0, 0), false, err
 // 	}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"net"
+	"time"
+
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+)
+
+// DNSFlood is a host whose DNS queries for new domains were skipped during an import because it queried more
+// than max_fqdns_per_src distinct domains
+type DNSFlood struct {
+	ImportTime   time.Time        `ch:"import_time"`
+	ImportID     util.FixedString `ch:"import_id"`
+	LastSeen     time.Time        `ch:"last_seen"`
+	Src          net.IP           `ch:"src"`
+	SrcNUID      uuid.UUID        `ch:"src_nuid"`
+	FQDNCount    uint64           `ch:"fqdn_count"`
+	DroppedCount uint64           `ch:"dropped_count"`
+}
+
+// AddDNSFloods inserts the hosts that hit the distinct domain limit during an import into the dns_floods table
+func (db *DB) AddDNSFloods(floods []DNSFlood) error {
+	if len(floods) == 0 {
+		return nil
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+	batch, err := db.Conn.PrepareBatch(ctx, "INSERT INTO {database:Identifier}.dns_floods")
+	if err != nil {
+		return err
+	}
+
+	for i := range floods {
+		if err := batch.AppendStruct(&floods[i]); err != nil {
+			return err
+		}
+	}
+
+	return batch.Send()
+}
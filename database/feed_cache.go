@@ -0,0 +1,205 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+)
+
+var ErrFeedRequestFailed = errors.New("online feed request failed")
+
+// feedCache keeps a copy of each online threat intel feed on disk along with the validators that the server sent
+// with it, so that feeds are only downloaded again once they have changed
+type feedCache struct {
+	afs    afero.Fs
+	client *http.Client
+	dir    string // feeds are not cached when empty
+}
+
+// feedValidators are the response headers that are sent back in conditional requests for a cached feed
+type feedValidators struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+// newFeedCache returns a cache that stores online feeds in dir, creating it if it doesn't exist
+func newFeedCache(afs afero.Fs, client *http.Client, dir string) (*feedCache, error) {
+	if dir == "" {
+		return &feedCache{afs: afs, client: client}, nil
+	}
+
+	dir, err := util.ParseRelativePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := afs.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &feedCache{afs: afs, client: client, dir: dir}, nil
+}
+
+// fetch returns the feed at url and whether it changed since it was last cached. Cached feeds are requested with
+// If-None-Match and If-Modified-Since unless refresh is set, and the cached copy is returned when the server
+// reports that the feed hasn't changed. If the feed can't be downloaded, the cached copy is used in its place.
+func (c *feedCache) fetch(ctx context.Context, url string, refresh bool) (io.ReadCloser, bool, error) {
+	logger := zlog.GetLogger()
+
+	cached, validators := c.load(url)
+
+	// only ask the server to skip the download if there is a cached copy to fall back on
+	var conditional feedValidators
+	if cached && !refresh {
+		conditional = validators
+	}
+
+	body, validators, notModified, err := c.download(ctx, url, conditional)
+	if err != nil {
+		if !cached {
+			return nil, false, err
+		}
+		logger.Warn().Err(err).Str("feed_url", url).Msg("[THREAT INTEL] Could not download online feed, using the cached copy")
+		feed, err := c.open(url)
+		return feed, false, err
+	}
+
+	if notModified {
+		feed, err := c.open(url)
+		return feed, false, err
+	}
+
+	// a feed that can't be cached is still used, it is just downloaded in full again next time
+	if err := c.store(url, body, validators); err != nil {
+		logger.Warn().Err(err).Str("feed_url", url).Str("directory", c.dir).Msg("[THREAT INTEL] Could not cache online feed")
+	}
+
+	return io.NopCloser(bytes.NewReader(body)), true, nil
+}
+
+// download requests the feed at url, making the request conditional on any validators that are set.
+// notModified is set if the server responded that the feed hasn't changed, in which case body is empty.
+func (c *feedCache) download(ctx context.Context, url string, conditional feedValidators) (body []byte, validators feedValidators, notModified bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, validators, false, err
+	}
+	if conditional.ETag != "" {
+		req.Header.Set("If-None-Match", conditional.ETag)
+	}
+	if conditional.LastModified != "" {
+		req.Header.Set("If-Modified-Since", conditional.LastModified)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, validators, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, conditional, true, nil
+	case http.StatusOK:
+	default:
+		return nil, validators, false, fmt.Errorf("%w: %s returned %s", ErrFeedRequestFailed, url, resp.Status)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, validators, false, err
+	}
+
+	validators = feedValidators{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return body, validators, false, nil
+}
+
+// paths returns the paths of the cached copy of the feed at url and of its validators
+func (c *feedCache) paths(url string) (string, string, error) {
+	hash, err := util.NewFixedStringHash(url)
+	if err != nil {
+		return "", "", err
+	}
+	name := filepath.Join(c.dir, hash.Hex())
+	return name + ".txt", name + ".json", nil
+}
+
+// load returns whether the feed at url is cached and the validators it was cached with
+func (c *feedCache) load(url string) (bool, feedValidators) {
+	var validators feedValidators
+	if c.dir == "" {
+		return false, validators
+	}
+
+	feedPath, validatorsPath, err := c.paths(url)
+	if err != nil {
+		return false, validators
+	}
+
+	if exists, err := afero.Exists(c.afs, feedPath); err != nil || !exists {
+		return false, validators
+	}
+
+	contents, err := afero.ReadFile(c.afs, validatorsPath)
+	if err != nil || json.Unmarshal(contents, &validators) != nil {
+		return false, validators
+	}
+
+	return true, validators
+}
+
+// open returns the cached copy of the feed at url
+func (c *feedCache) open(url string) (io.ReadCloser, error) {
+	feedPath, _, err := c.paths(url)
+	if err != nil {
+		return nil, err
+	}
+	return c.afs.Open(feedPath)
+}
+
+// store caches the feed at url along with its validators
+func (c *feedCache) store(url string, body []byte, validators feedValidators) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	feedPath, validatorsPath, err := c.paths(url)
+	if err != nil {
+		return err
+	}
+
+	contents, err := json.Marshal(validators)
+	if err != nil {
+		return err
+	}
+
+	// the old validators are removed and the feed is written before the new ones so that a partially written feed
+	// is never treated as cached
+	if err := c.afs.Remove(validatorsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := afero.WriteFile(c.afs, feedPath, body, 0o644); err != nil {
+		return err
+	}
+	return afero.WriteFile(c.afs, validatorsPath, contents, 0o644)
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedCache(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 01 Jul 2024 00:00:00 GMT"
+
+	var full, conditional int
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = io.WriteString(w, "198.51.100.1\nevil.example.com\n")
+	}))
+	defer server.Close()
+
+	readFeed := func(feed io.ReadCloser) string {
+		t.Helper()
+		defer feed.Close()
+		contents, err := io.ReadAll(feed)
+		require.NoError(t, err)
+		return string(contents)
+	}
+
+	afs := afero.NewMemMapFs()
+	cache, err := newFeedCache(afs, server.Client(), "/cache")
+	require.NoError(t, err)
+
+	t.Run("Downloads And Caches New Feed", func(t *testing.T) {
+		feed, modified, err := cache.fetch(context.Background(), server.URL, false)
+		require.NoError(t, err)
+		require.True(t, modified, "new feed should be reported as modified")
+		require.Equal(t, "198.51.100.1\nevil.example.com\n", readFeed(feed))
+		require.Equal(t, 1, full)
+
+		cached, validators := cache.load(server.URL)
+		require.True(t, cached, "feed should be cached")
+		require.Equal(t, feedValidators{URL: server.URL, ETag: etag, LastModified: lastModified}, validators)
+	})
+
+	t.Run("Skips Unchanged Feed", func(t *testing.T) {
+		feed, modified, err := cache.fetch(context.Background(), server.URL, false)
+		require.NoError(t, err)
+		require.False(t, modified, "unchanged feed should not be reported as modified")
+		require.Equal(t, "198.51.100.1\nevil.example.com\n", readFeed(feed), "cached copy should be returned")
+		require.Equal(t, 1, full, "feed should not be downloaded again")
+		require.Equal(t, 1, conditional)
+	})
+
+	t.Run("Refresh Downloads Feed", func(t *testing.T) {
+		feed, modified, err := cache.fetch(context.Background(), server.URL, true)
+		require.NoError(t, err)
+		require.True(t, modified)
+		require.Equal(t, "198.51.100.1\nevil.example.com\n", readFeed(feed))
+		require.Equal(t, 2, full, "feed should be downloaded in full")
+	})
+
+	t.Run("Falls Back To Cached Copy", func(t *testing.T) {
+		available = false
+		defer func() { available = true }()
+
+		feed, modified, err := cache.fetch(context.Background(), server.URL, false)
+		require.NoError(t, err, "cached copy should be used instead of failing")
+		require.False(t, modified)
+		require.Equal(t, "198.51.100.1\nevil.example.com\n", readFeed(feed))
+
+		// a feed that was never cached has nothing to fall back on
+		_, _, err = cache.fetch(context.Background(), server.URL+"/uncached", false)
+		require.ErrorIs(t, err, ErrFeedRequestFailed)
+	})
+
+	t.Run("Caching Disabled", func(t *testing.T) {
+		uncached, err := newFeedCache(afs, server.Client(), "")
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			feed, modified, err := uncached.fetch(context.Background(), server.URL+"/uncached", false)
+			require.NoError(t, err)
+			require.True(t, modified, "feed should be downloaded every time")
+			require.Equal(t, "198.51.100.1\nevil.example.com\n", readFeed(feed))
+		}
+	})
+}
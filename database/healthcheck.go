@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+var ErrServerUnreachable = errors.New("ClickHouse server is unreachable")
+var ErrServerAuthFailed = errors.New("ClickHouse server rejected the credentials")
+var ErrMetaDatabaseNotFound = errors.New("metadatabase does not exist")
+var ErrSchemaMismatch = errors.New("dataset schema version does not match this version of RITA")
+
+// ClickHouse error codes that mean the server was reached but refused to authenticate
+var authFailureCodes = []int32{
+	192, // UNKNOWN_USER
+	193, // WRONG_PASSWORD
+	194, // REQUIRED_PASSWORD
+	516, // AUTHENTICATION_FAILED
+}
+
+// HealthCheck is the result of a successful health check of the ClickHouse server
+type HealthCheck struct {
+	Addr     string
+	Latency  time.Duration // round-trip time of a ping to the server
+	Datasets int           // number of datasets with a recorded schema version
+}
+
+// CheckHealth verifies that the ClickHouse server at cfg.DBConnection is reachable and accepts the
+// credentials, that the metadatabase exists, and that every dataset is at the schema version of this binary.
+// Each failure is wrapped in its own error so that callers can tell them apart.
+func CheckHealth(ctx context.Context, cfg *config.Config) (*HealthCheck, error) {
+	if cfg == nil {
+		return nil, ErrMissingConfig
+	}
+
+	conn, err := openServerConn(ctx, cfg.DBConnection)
+	if err != nil {
+		return nil, classifyConnectionError(cfg.DBConnection, err)
+	}
+	defer conn.Close()
+
+	// time a ping on the open connection so that the latency doesn't include the handshake
+	start := time.Now()
+	if err := conn.Ping(ctx); err != nil {
+		return nil, classifyConnectionError(cfg.DBConnection, err)
+	}
+	latency := time.Since(start)
+
+	metaDB := metaDatabaseName(cfg)
+	exists, err := DatabaseExists(ctx, conn, metaDB)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrMetaDatabaseNotFound, metaDB)
+	}
+
+	metaCtx := clickhouse.Context(ctx, clickhouse.WithParameters(clickhouse.Parameters{"metadatabase": metaDB}))
+
+	// metadatabases created before schema versions were recorded don't have the table
+	var tables uint64
+	err = conn.QueryRow(metaCtx, `
+		SELECT count() FROM system.tables WHERE database = {metadatabase:String} AND name = 'schema_versions'
+	`).Scan(&tables)
+	if err != nil {
+		return nil, err
+	}
+	if tables == 0 {
+		return nil, fmt.Errorf("%w: metadatabase has no schema versions, run 'rita migrate' on each dataset", ErrSchemaMismatch)
+	}
+
+	// only check the versions of datasets that still exist
+	rows, err := conn.Query(metaCtx, `
+		SELECT database, max(version) FROM {metadatabase:Identifier}.schema_versions
+		WHERE database IN (SELECT name FROM system.databases)
+		GROUP BY database
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]uint32)
+	for rows.Next() {
+		var database string
+		var version uint32
+		if err := rows.Scan(&database, &version); err != nil {
+			return nil, err
+		}
+		versions[database] = version
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := checkSchemaVersions(versions); err != nil {
+		return nil, err
+	}
+
+	return &HealthCheck{
+		Addr:     cfg.DBConnection,
+		Latency:  latency,
+		Datasets: len(versions),
+	}, nil
+}
+
+// classifyConnectionError wraps an error from connecting to the server at addr as either an authentication
+// failure or an unreachable server
+func classifyConnectionError(addr string, err error) error {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) && slices.Contains(authFailureCodes, exception.Code) {
+		return fmt.Errorf("%w: %s: %w", ErrServerAuthFailed, addr, err)
+	}
+	return fmt.Errorf("%w: %s: %w", ErrServerUnreachable, addr, err)
+}
+
+// checkSchemaVersions returns an error listing every dataset whose schema version isn't SchemaVersion
+func checkSchemaVersions(versions map[string]uint32) error {
+	var mismatched []string
+	for database, version := range versions {
+		if version != SchemaVersion {
+			mismatched = append(mismatched, fmt.Sprintf("%s (version %d)", database, version))
+		}
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+
+	slices.Sort(mismatched)
+	return fmt.Errorf("%w: expected version %d, found %s", ErrSchemaMismatch, SchemaVersion, strings.Join(mismatched, ", "))
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyConnectionError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "Authentication Failed",
+			err:      &clickhouse.Exception{Code: 516, Name: "AUTHENTICATION_FAILED"},
+			expected: ErrServerAuthFailed,
+		},
+		{
+			name:     "Wrong Password",
+			err:      &clickhouse.Exception{Code: 193, Name: "WRONG_PASSWORD"},
+			expected: ErrServerAuthFailed,
+		},
+		{
+			name:     "Other Exception",
+			err:      &clickhouse.Exception{Code: 81, Name: "UNKNOWN_DATABASE"},
+			expected: ErrServerUnreachable,
+		},
+		{
+			name:     "Connection Refused",
+			err:      errors.New("dial tcp 127.0.0.1:9000: connect: connection refused"),
+			expected: ErrServerUnreachable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := classifyConnectionError("localhost:9000", test.err)
+			require.ErrorIs(t, err, test.expected)
+			require.ErrorIs(t, err, test.err, "original error should be wrapped")
+			require.Contains(t, err.Error(), "localhost:9000", "error should name the server")
+		})
+	}
+}
+
+func TestCheckSchemaVersions(t *testing.T) {
+	require.NoError(t, checkSchemaVersions(nil), "no datasets should pass")
+	require.NoError(t, checkSchemaVersions(map[string]uint32{"a": SchemaVersion, "b": SchemaVersion}))
+
+	err := checkSchemaVersions(map[string]uint32{"a": SchemaVersion, "c": SchemaVersion + 1, "b": 0})
+	require.ErrorIs(t, err, ErrSchemaMismatch)
+	require.ErrorContains(t, err, fmt.Sprintf("b (version 0), c (version %d)", SchemaVersion+1), "mismatched datasets should be listed in order")
+	require.NotContains(t, err.Error(), "a (", "datasets at the current version should not be listed")
+}
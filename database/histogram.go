@@ -0,0 +1,134 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// HistogramBucketSeconds is the width of the time buckets in big_ol_histogram
+const HistogramBucketSeconds = 15 * 60
+
+// HistogramFilter selects the connections whose histograms are exported, either by hash or by source and destination
+type HistogramFilter struct {
+	Hash string // hex encoded
+	Src  net.IP
+	Dst  net.IP
+}
+
+// HistogramBucket is the number of connections and bytes sent in one time bucket of a connection's histogram
+type HistogramBucket struct {
+	Bucket     int64  `json:"bucket"` // unix timestamp of the start of the bucket
+	Count      uint64 `json:"count"`
+	SrcIPBytes int64  `json:"src_ip_bytes"`
+}
+
+// HistogramSeries is the histogram of the connections with one hash, ordered by time
+type HistogramSeries struct {
+	Hash          string            `json:"hash"`
+	BucketSeconds int64             `json:"bucket_seconds"`
+	Buckets       []HistogramBucket `json:"buckets"`
+	Annotation    *Annotation       `json:"annotation,omitempty"`
+}
+
+// histogramRow is a single bucket of a histogram as it is read from big_ol_histogram
+type histogramRow struct {
+	Hash       string `ch:"hash_hex"`
+	Bucket     int64  `ch:"bucket_ts"`
+	SrcIPBytes int64  `ch:"src_ip_bytes"`
+	Count      uint64 `ch:"count"`
+}
+
+// ExportHistogram returns the time bucketed connection counts of the connections matched by the filter, with one
+// series per hash. A source or destination matches both the IP connections and the SNI connections of the host.
+// Every histogram is exported when the filter is empty. Series whose hash has been annotated include the annotation.
+func (db *DB) ExportHistogram(filter HistogramFilter) ([]HistogramSeries, error) {
+	params := clickhouse.Parameters{}
+	var conditions []string
+	if filter.Hash != "" {
+		conditions = append(conditions, "hash = unhex({hash:String})")
+		params["hash"] = filter.Hash
+	}
+
+	// the histograms are keyed by hash, so look up the hashes of the host's connections
+	var hostConditions []string
+	if filter.Src != nil {
+		hostConditions = append(hostConditions, "src = {src:String}")
+		params["src"] = filter.Src.String()
+	}
+	if filter.Dst != nil {
+		hostConditions = append(hostConditions, "dst = {dst:String}")
+		params["dst"] = filter.Dst.String()
+	}
+	if len(hostConditions) > 0 {
+		hostFilter := strings.Join(hostConditions, " AND ")
+		conditions = append(conditions, fmt.Sprintf(
+			"hash IN (SELECT hash FROM uconn WHERE %[1]s UNION ALL SELECT hash FROM usni WHERE %[1]s)", hostFilter,
+		))
+	}
+
+	if len(conditions) == 0 {
+		conditions = append(conditions, "true")
+	}
+
+	ctx := db.QueryParameters(params)
+
+	// the counts are stored as aggregate states, so they must be merged to get the number of connections
+	rows, err := db.ReadConn.Query(ctx, `--sql
+		SELECT hex(hash) AS hash_hex, toInt64(toUnixTimestamp(bucket)) AS bucket_ts,
+			sum(src_ip_bytes) AS src_ip_bytes, countMerge(count) AS count
+		FROM big_ol_histogram
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY hash, bucket
+		ORDER BY hash_hex, bucket_ts
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not query big_ol_histogram: %w", err)
+	}
+	defer rows.Close()
+
+	var results []histogramRow
+	for rows.Next() {
+		var res histogramRow
+		if err := rows.ScanStruct(&res); err != nil {
+			return nil, fmt.Errorf("could not read big_ol_histogram row: %w", err)
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	annotations, err := db.GetAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	series := groupHistogramRows(results)
+	annotateHistogramSeries(series, annotations)
+	return series, nil
+}
+
+// groupHistogramRows groups rows that are ordered by hash and bucket into one series per hash
+func groupHistogramRows(rows []histogramRow) []HistogramSeries {
+	series := []HistogramSeries{}
+	for _, row := range rows {
+		if len(series) == 0 || series[len(series)-1].Hash != row.Hash {
+			series = append(series, HistogramSeries{Hash: row.Hash, BucketSeconds: HistogramBucketSeconds})
+		}
+		current := &series[len(series)-1]
+		current.Buckets = append(current.Buckets, HistogramBucket{Bucket: row.Bucket, Count: row.Count, SrcIPBytes: row.SrcIPBytes})
+	}
+	return series
+}
+
+// annotateHistogramSeries attaches the analyst's annotation of each hash to its series
+func annotateHistogramSeries(series []HistogramSeries, annotations map[string]Annotation) {
+	for i := range series {
+		if annotation, ok := annotations[series[i].Hash]; ok {
+			series[i].Annotation = &annotation
+		}
+	}
+}
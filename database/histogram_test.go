@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupHistogramRows(t *testing.T) {
+	require.Empty(t, groupHistogramRows(nil), "no rows should have no series")
+	require.NotNil(t, groupHistogramRows(nil), "no rows should be exported as an empty list")
+
+	rows := []histogramRow{
+		{Hash: "AA", Bucket: 1717236000, Count: 4, SrcIPBytes: 400},
+		{Hash: "AA", Bucket: 1717236900, Count: 3, SrcIPBytes: 300},
+		{Hash: "BB", Bucket: 1717236000, Count: 1, SrcIPBytes: 100},
+	}
+
+	series := groupHistogramRows(rows)
+	require.Equal(t, []HistogramSeries{
+		{
+			Hash:          "AA",
+			BucketSeconds: HistogramBucketSeconds,
+			Buckets: []HistogramBucket{
+				{Bucket: 1717236000, Count: 4, SrcIPBytes: 400},
+				{Bucket: 1717236900, Count: 3, SrcIPBytes: 300},
+			},
+		},
+		{
+			Hash:          "BB",
+			BucketSeconds: HistogramBucketSeconds,
+			Buckets:       []HistogramBucket{{Bucket: 1717236000, Count: 1, SrcIPBytes: 100}},
+		},
+	}, series)
+}
+
+func TestAnnotateHistogramSeries(t *testing.T) {
+	series := []HistogramSeries{{Hash: "AA"}, {Hash: "BB"}}
+	annotatedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	annotateHistogramSeries(series, map[string]Annotation{
+		"BB": {Hash: "BB", Status: AnnotationStatusBenign, Note: "backup agent", UpdatedAt: annotatedAt},
+		"CC": {Hash: "CC", Status: AnnotationStatusMalicious},
+	})
+
+	require.Nil(t, series[0].Annotation, "series without an annotation should not be annotated")
+	require.Equal(t, &Annotation{Hash: "BB", Status: AnnotationStatusBenign, Note: "backup agent", UpdatedAt: annotatedAt}, series[1].Annotation)
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+var ErrImportSummaryNotFound = errors.New("no import summary was found")
+
+// TopBeaconLimit is the number of beacons that are saved in an import summary
+const TopBeaconLimit = 10
+
+// SummaryBeacon is one of the highest scoring beacons found by an import
+type SummaryBeacon struct {
+	Src   string
+	Dst   string
+	FQDN  string
+	Score float32
+}
+
+// ImportSummary describes what was imported and found by a single import, so that it can be reported on
+// after the original console output is gone
+type ImportSummary struct {
+	ImportID    util.FixedString
+	Database    string
+	CreatedAt   time.Time
+	ElapsedTime time.Duration
+	Files       map[string]uint64
+	Records     map[string]uint64
+	WalkErrors  uint64
+	ParseErrors uint64
+	TopBeacons  []SummaryBeacon
+}
+
+// createMetaDatabaseImportSummariesTable creates the metadatabase.import_summaries table
+func (server *ServerConn) createMetaDatabaseImportSummariesTable() error {
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `--sql
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.import_summaries (
+			import_id FixedString(16),
+			database String,
+			created_at DateTime(),
+			elapsed_seconds Float64,
+			files Map(String, UInt64),
+			records Map(String, UInt64),
+			walk_errors UInt64,
+			parse_errors UInt64,
+			-- the top beacons are stored as parallel arrays in descending order of score
+			top_beacon_srcs Array(String),
+			top_beacon_dsts Array(String),
+			top_beacon_fqdns Array(String),
+			top_beacon_scores Array(Float32)
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (database, created_at, import_id)
+	`)
+	return err
+}
+
+// GetTopBeacons returns the highest scoring beacons found by the specified import, leaving out allowlisted destinations
+func (db *DB) GetTopBeacons(importID util.FixedString, limit int) ([]SummaryBeacon, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"import_id": importID.Hex(),
+		"limit":     fmt.Sprintf("%d", limit),
+	})
+
+	// the results were just written by the import, so they're read from the primary instead of the replica
+	rows, err := db.Conn.Query(ctx, `--sql
+		SELECT src, dst, fqdn, beacon_score FROM threat_mixtape
+		WHERE import_id = unhex({import_id:String}) AND modifier_name = '' AND beacon_score > 0 AND NOT allowlisted
+		ORDER BY beacon_score DESC
+		LIMIT {limit:UInt32}
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var beacons []SummaryBeacon
+	for rows.Next() {
+		// scan the IPs as net.IP so that IPv4 addresses aren't printed as IPv4-mapped IPv6 addresses
+		var src, dst net.IP
+		var beacon SummaryBeacon
+		if err := rows.Scan(&src, &dst, &beacon.FQDN, &beacon.Score); err != nil {
+			return nil, err
+		}
+		beacon.Src, beacon.Dst = src.String(), dst.String()
+		beacons = append(beacons, beacon)
+	}
+
+	return beacons, rows.Err()
+}
+
+// AddImportSummaryToMetaDB inserts the summary of an import into the metadatabase.import_summaries table
+func (db *DB) AddImportSummaryToMetaDB(summary ImportSummary) error {
+	batch, err := db.Conn.PrepareBatch(db.QueryParameters(clickhouse.Parameters{}), "INSERT INTO {metadatabase:Identifier}.import_summaries")
+	if err != nil {
+		return err
+	}
+
+	srcs := make([]string, 0, len(summary.TopBeacons))
+	dsts := make([]string, 0, len(summary.TopBeacons))
+	fqdns := make([]string, 0, len(summary.TopBeacons))
+	scores := make([]float32, 0, len(summary.TopBeacons))
+	for _, beacon := range summary.TopBeacons {
+		srcs = append(srcs, beacon.Src)
+		dsts = append(dsts, beacon.Dst)
+		fqdns = append(fqdns, beacon.FQDN)
+		scores = append(scores, beacon.Score)
+	}
+
+	err = batch.Append(
+		summary.ImportID,
+		summary.Database,
+		summary.CreatedAt.UTC(),
+		summary.ElapsedTime.Seconds(),
+		summary.Files,
+		summary.Records,
+		summary.WalkErrors,
+		summary.ParseErrors,
+		srcs,
+		dsts,
+		fqdns,
+		scores,
+	)
+	if err != nil {
+		return err
+	}
+
+	return batch.Send()
+}
+
+// GetImportSummary returns the summary of the specified import of a dataset. If no import ID is given,
+// the summary of the most recent import is returned.
+func (server *ServerConn) GetImportSummary(database string, importID string) (ImportSummary, error) {
+	// if the summaries table does not exist, there are no summaries
+	exists, err := server.importSummariesTableExists()
+	if err != nil {
+		return ImportSummary{}, err
+	}
+	if !exists {
+		return ImportSummary{}, ErrImportSummaryNotFound
+	}
+
+	ctx := server.QueryParameters(clickhouse.Parameters{
+		"database":  database,
+		"import_id": importID,
+	})
+
+	var (
+		summary   ImportSummary
+		hexID     string
+		elapsed   float64
+		srcs      []string
+		dsts      []string
+		fqdns     []string
+		scores    []float32
+		filesMap  map[string]uint64
+		recordMap map[string]uint64
+	)
+
+	err = server.ReadConn.QueryRow(ctx, `--sql
+		SELECT hex(import_id), database, created_at, elapsed_seconds, files, records, walk_errors, parse_errors,
+			top_beacon_srcs, top_beacon_dsts, top_beacon_fqdns, top_beacon_scores
+		FROM {metadatabase:Identifier}.import_summaries
+		WHERE database = {database:String} AND ({import_id:String} = '' OR hex(import_id) = upper({import_id:String}))
+		ORDER BY created_at DESC
+		LIMIT 1
+	`).Scan(&hexID, &summary.Database, &summary.CreatedAt, &elapsed, &filesMap, &recordMap, &summary.WalkErrors, &summary.ParseErrors,
+		&srcs, &dsts, &fqdns, &scores)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ImportSummary{}, ErrImportSummaryNotFound
+	}
+	if err != nil {
+		return ImportSummary{}, err
+	}
+
+	summary.ImportID, err = util.NewFixedStringFromHex(hexID)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+	summary.ElapsedTime = time.Duration(elapsed * float64(time.Second))
+	summary.Files = filesMap
+	summary.Records = recordMap
+
+	// rebuild the beacons from the parallel arrays
+	for i := range srcs {
+		if i >= len(dsts) || i >= len(fqdns) || i >= len(scores) {
+			break
+		}
+		summary.TopBeacons = append(summary.TopBeacons, SummaryBeacon{Src: srcs[i], Dst: dsts[i], FQDN: fqdns[i], Score: scores[i]})
+	}
+
+	return summary, nil
+}
+
+// clearImportSummariesFromMetaDB deletes entries in the import_summaries table for the specified database
+func (server *ServerConn) clearImportSummariesFromMetaDB(database string) error {
+	// metadatabases created by older versions don't have the summaries table
+	exists, err := server.importSummariesTableExists()
+	if err != nil || !exists {
+		return err
+	}
+
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": database})
+	err = server.Conn.Exec(ctx, `
+		DELETE FROM {metadatabase:Identifier}.import_summaries WHERE database = {database:String}
+	`)
+	return err
+}
+
+// importSummariesTableExists returns whether the metadatabase.import_summaries table exists
+func (server *ServerConn) importSummariesTableExists() (bool, error) {
+	var exists uint8
+	err := server.Conn.QueryRow(server.QueryParameters(clickhouse.Parameters{}), `--sql
+		EXISTS TABLE {metadatabase:Identifier}.import_summaries
+	`).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists == 1, nil
+}
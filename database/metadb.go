@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/activecm/rita/v5/config"
+	zlog "github.com/activecm/rita/v5/logger"
 	"github.com/activecm/rita/v5/util"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -36,8 +37,8 @@ type MetaDBImportRecord struct {
 
 // createMetaDatabase creates the metadatabase and its tables if any part of it doesn't exist
 func (server *ServerConn) createMetaDatabase() error {
-	err := server.Conn.Exec(server.ctx, `
-		CREATE DATABASE IF NOT EXISTS metadatabase
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE DATABASE IF NOT EXISTS {metadatabase:Identifier}
 	`)
 	if err != nil {
 		return err
@@ -58,6 +59,11 @@ func (server *ServerConn) createMetaDatabase() error {
 		return err
 	}
 
+	err = server.createMetaDatabaseImportSummariesTable()
+	if err != nil {
+		return err
+	}
+
 	err = server.createThreatIntelTables()
 	if err != nil {
 		return err
@@ -73,31 +79,63 @@ func (server *ServerConn) createMetaDatabase() error {
 		return err
 	}
 
+	err = server.createMetaDatabaseSchemaVersionsTable()
+	if err != nil {
+		return err
+	}
+
+	err = server.createMetaDatabaseScoreThresholdsTable()
+	if err != nil {
+		return err
+	}
+
+	err = server.createMetaDatabaseAnnotationsTable()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // createMetaDatabaseFilesTable creates the metadatabase.files table
 func (server *ServerConn) createMetaDatabaseFilesTable() error {
-	err := server.Conn.Exec(server.ctx, `
-		CREATE TABLE IF NOT EXISTS metadatabase.files (
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.files (
 			hash FixedString(16),
 			database String,
 			import_id FixedString(16),
 			rolling Bool,
 			ts DateTime(),
-			path String
+			path String,
+			checksum FixedString(16),
+			size UInt64
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (database, import_id, hash, path)
 	`)
+	if err != nil {
+		return err
+	}
+
+	// the checksum and size columns were added after the table was first released, so they need to be added to existing tables
+	err = server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		ALTER TABLE {metadatabase:Identifier}.files ADD COLUMN IF NOT EXISTS checksum FixedString(16) AFTER path
+	`)
+	if err != nil {
+		return err
+	}
+
+	err = server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		ALTER TABLE {metadatabase:Identifier}.files ADD COLUMN IF NOT EXISTS size UInt64 AFTER checksum
+	`)
 
 	return err
 }
 
 // createMetaDatabaseImportsTable creates the metadatabase.imports table
 func (server *ServerConn) createMetaDatabaseImportsTable() error {
-	err := server.Conn.Exec(server.ctx, `
-		CREATE TABLE IF NOT EXISTS metadatabase.imports (
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.imports (
 			import_id FixedString(16),
 			rolling Bool,
 			database String,
@@ -134,8 +172,8 @@ func (server *ServerConn) createMetaDatabaseMinMaxTable() error {
 	// 	return err
 	// }
 
-	err := server.Conn.Exec(server.ctx, `--sql
-		CREATE TABLE IF NOT EXISTS metadatabase.min_max (
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `--sql
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.min_max (
 			database String,
 			rolling Bool,
 			beacon Bool,
@@ -166,8 +204,15 @@ func (server *ServerConn) createMetaDatabaseMinMaxTable() error {
 	return nil
 }
 
-// MarkFileImportedInMetaDB adds the given path to the metadatabase.files table to mark it as being used
-func (db *DB) MarkFileImportedInMetaDB(hash util.FixedString, importID util.FixedString, path string) error {
+// FileContents identifies the contents of a log file by the checksum and size that it had when it was imported
+type FileContents struct {
+	Checksum util.FixedString
+	Size     int64
+}
+
+// MarkFileImportedInMetaDB adds the given path to the metadatabase.files table to mark it as being used, along with
+// the checksum and size of its contents. The file is only considered committed once the import with the given import id has a finished record.
+func (db *DB) MarkFileImportedInMetaDB(hash util.FixedString, importID util.FixedString, path string, contents FileContents) error {
 	ctx := db.QueryParameters(clickhouse.Parameters{
 		"hash":      hash.Hex(),
 		"importID":  importID.Hex(),
@@ -175,11 +220,13 @@ func (db *DB) MarkFileImportedInMetaDB(hash util.FixedString, importID util.Fixe
 		"timestamp": strconv.FormatInt(time.Now().UTC().Unix(), 10),
 		"path":      path,
 		"rolling":   strconv.FormatBool(db.Rolling),
+		"checksum":  contents.Checksum.Hex(),
+		"size":      strconv.FormatInt(contents.Size, 10),
 	})
 
 	err := db.Conn.Exec(ctx, `
-		INSERT INTO metadatabase.files (hash, import_id, database, rolling, ts, path)
-		VALUES (unhex({hash:String}), unhex({importID:String}), {database:String}, {rolling:Bool}, {timestamp:Int32}, {path:String})
+		INSERT INTO {metadatabase:Identifier}.files (hash, import_id, database, rolling, ts, path, checksum, size)
+		VALUES (unhex({hash:String}), unhex({importID:String}), {database:String}, {rolling:Bool}, {timestamp:Int32}, {path:String}, unhex({checksum:String}), {size:UInt64})
 	`)
 	return err
 }
@@ -205,7 +252,7 @@ func (db *DB) AddImportStartRecordToMetaDB(importID util.FixedString) error {
 	})
 
 	err := db.Conn.Exec(ctx, `
-		INSERT INTO metadatabase.imports (import_id, rolling, database, rebuild, started_at)
+		INSERT INTO {metadatabase:Identifier}.imports (import_id, rolling, database, rebuild, started_at)
 		VALUES (unhex({importID:String}), {rolling:Bool}, {database:String}, {rebuild:Bool}, fromUnixTimestamp64Micro({importStartedAt:Int64}))
 	`)
 
@@ -244,7 +291,7 @@ func (db *DB) AddImportFinishedRecordToMetaDB(importID util.FixedString, minTS,
 	})
 
 	err = db.Conn.Exec(ctx, `
-		INSERT INTO metadatabase.imports (import_id, rolling, database, started_at, ended_at, min_timestamp, max_timestamp, min_open_timestamp, max_open_timestamp)
+		INSERT INTO {metadatabase:Identifier}.imports (import_id, rolling, database, started_at, ended_at, min_timestamp, max_timestamp, min_open_timestamp, max_open_timestamp)
 		VALUES (
 			unhex({importID:String}), 
 			{rolling:Bool}, 
@@ -260,24 +307,65 @@ func (db *DB) AddImportFinishedRecordToMetaDB(importID util.FixedString, minTS,
 	return err
 }
 
-// CheckIfFilesWereAlreadyImported calls checkFileHashes for each log type
-func (db *DB) CheckIfFilesWereAlreadyImported(fileMap map[string][]string) (int, error) {
+// HasFinishedImport returns whether at least one import (and its analysis) has completed for this dataset
+func (db *DB) HasFinishedImport() (bool, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	var count uint64
+	err := db.Conn.QueryRow(ctx, `
+		SELECT count() FROM {metadatabase:Identifier}.imports
+		WHERE database = {database:String} AND ended_at > toDateTime(0)
+	`).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetLastImportStartedAt returns the time that the most recent import of this dataset started, or the Unix epoch if
+// it has no imports
+func (db *DB) GetLastImportStartedAt() (time.Time, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	var startedAt time.Time
+	err := db.Conn.QueryRow(ctx, `
+		SELECT max(started_at) FROM {metadatabase:Identifier}.imports
+		WHERE database = {database:String}
+	`).Scan(&startedAt)
+
+	return startedAt, err
+}
+
+// CheckIfFilesWereAlreadyImported calls checkFileHashes for each log type. The contents are the current checksums and
+// sizes of the files, keyed by path. It returns the number of files left to import and the contents that the committed
+// files among them had when they were committed, since those files changed and are imported again.
+func (db *DB) CheckIfFilesWereAlreadyImported(fileMap map[string][]string, contents map[string]FileContents) (int, map[string]FileContents, error) {
 	totalFileCount := 0
+	changedFiles := make(map[string]FileContents)
 	// loop over each log type in the hour's filemap
 	for logType, logList := range fileMap {
-		results, err := db.checkFileHashes(logList)
+		results, changed, err := db.checkFileHashes(logList, contents)
 		if err != nil {
-			return totalFileCount, err
+			return totalFileCount, changedFiles, err
 		}
 		fileMap[logType] = results
 		totalFileCount += len(results)
+		for path, committed := range changed {
+			changedFiles[path] = committed
+		}
 	}
 
-	return totalFileCount, nil
+	return totalFileCount, changedFiles, nil
 }
 
-// checkFileHashes filters fileList to only files that haven't already been imported for this dataset
-func (db *DB) checkFileHashes(fileList []string) ([]string, error) {
+// checkFileHashes filters fileList to only files that haven't already been fully imported for this dataset,
+// or whose contents changed since they were imported
+func (db *DB) checkFileHashes(fileList []string, contents map[string]FileContents) ([]string, map[string]FileContents, error) {
 	// format array for clickhouse parameters
 	files := "["
 	for _, file := range fileList {
@@ -290,45 +378,81 @@ func (db *DB) checkFileHashes(fileList []string) ([]string, error) {
 		"files":    files,
 	})
 
-	var importedFiles []struct {
-		Path string `ch:"path"`
-	}
+	var importedFiles []importedFile
 
-	// query for files in this fileList that have already been imported
+	// query for files in this fileList that have already been imported, whether any of the imports
+	// that read them finished, and the checksum and size of the file when it was last committed
+	// an import that was interrupted never gets a finished record
 	err := db.Conn.Select(ctx, &importedFiles, `
-		SELECT path FROM metadatabase.files WHERE database = {database:String} AND path IN {files:Array(String)}
+		SELECT path, max(committed) AS committed, argMaxIf(checksum, ts, committed) AS checksum, argMaxIf(size, ts, committed) AS size FROM (
+			SELECT path, checksum, size, ts, import_id IN (
+				SELECT import_id FROM {metadatabase:Identifier}.imports
+				WHERE database = {database:String} AND ended_at > toDateTime(0)
+			) AS committed
+			FROM {metadatabase:Identifier}.files
+			WHERE database = {database:String} AND path IN {files:Array(String)}
+		)
+		GROUP BY path
 	`)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	remaining, changed := filterCommittedFiles(fileList, importedFiles, contents)
+	return remaining, changed, nil
+}
+
+// importedFile is a file that was read by an import of this dataset
+type importedFile struct {
+	Path      string           `ch:"path"`
+	Committed bool             `ch:"committed"`
+	Checksum  util.FixedString `ch:"checksum"`
+	Size      uint64           `ch:"size"`
+}
+
+// filterCommittedFiles returns the files in fileList that were not fully committed by a previous import, along with
+// the contents that the committed files among them had when they were committed. Files that were only read by imports
+// that never finished are imported again, as are committed files whose checksum changed since they were imported.
+// Files imported before checksums were recorded are never imported again.
+func filterCommittedFiles(fileList []string, importedFiles []importedFile, contents map[string]FileContents) ([]string, map[string]FileContents) {
+	logger := zlog.GetLogger()
+
 	// convert imported files array into a map
-	importedFilesMap := make(map[string]bool)
+	importedFilesMap := make(map[string]importedFile)
 	for _, file := range importedFiles {
-		importedFilesMap[file.Path] = true
+		importedFilesMap[file.Path] = file
 	}
 
 	var nonImportedFiles []string
+	changedFiles := make(map[string]FileContents)
 
-	// build a list of files that haven't been imported
+	// build a list of files that haven't been fully imported
 	for _, file := range fileList {
-		if !importedFilesMap[file] {
-			nonImportedFiles = append(nonImportedFiles, file)
+		imported, ok := importedFilesMap[file]
+		if ok && imported.Committed {
+			current, hasChecksum := contents[file]
+			if !hasChecksum || imported.Checksum.Data == [16]byte{} || imported.Checksum.Data == current.Checksum.Data {
+				continue
+			}
+			changedFiles[file] = FileContents{Checksum: imported.Checksum, Size: int64(imported.Size)}
+		} else if ok {
+			logger.Debug().Str("path", file).Msg("resuming import of file from an unfinished import")
 		}
+		nonImportedFiles = append(nonImportedFiles, file)
 	}
 
-	return nonImportedFiles, err
+	return nonImportedFiles, changedFiles
 }
 
 // ClearMetaDBEntriesForDatabase deletes all file and import record entries in the metadatabase for the specified database
 func (server *ServerConn) ClearMetaDBEntriesForDatabase(database string) error {
 	// verify that the metadatabase exists
-	exists, err := DatabaseExists(server.ctx, server.Conn, "metadatabase")
+	exists, err := DatabaseExists(server.ctx, server.Conn, server.metaDatabase)
 	if err != nil {
 		return err
 	}
 
-	// clear the imported files and min_max records for the specified database if metadatabase exists
+	// clear the imported files, min_max records, import summaries, and schema versions for the specified database if metadatabase exists
 	if exists {
 		if err := server.clearImportedFilesFromMetaDB(database); err != nil {
 			return err
@@ -337,6 +461,14 @@ func (server *ServerConn) ClearMetaDBEntriesForDatabase(database string) error {
 		if err := server.clearDatabaseFromMetaDB(database); err != nil {
 			return err
 		}
+
+		if err := server.clearImportSummariesFromMetaDB(database); err != nil {
+			return err
+		}
+
+		if err := server.clearSchemaVersionsFromMetaDB(database); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -344,17 +476,17 @@ func (server *ServerConn) ClearMetaDBEntriesForDatabase(database string) error {
 
 // clearImportedFilesFromMetaDB deletes entries in files table for specified database
 func (server *ServerConn) clearImportedFilesFromMetaDB(database string) error {
-	ctx := clickhouse.Context(server.ctx, clickhouse.WithParameters(clickhouse.Parameters{"database": database}))
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": database})
 	err := server.Conn.Exec(ctx, `
-		DELETE FROM metadatabase.files WHERE database = {database:String}
+		DELETE FROM {metadatabase:Identifier}.files WHERE database = {database:String}
 	`, database)
 	return err
 }
 
 func (server *ServerConn) clearDatabaseFromMetaDB(database string) error {
-	ctx := clickhouse.Context(server.ctx, clickhouse.WithParameters(clickhouse.Parameters{"database": database}))
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": database})
 	err := server.Conn.Exec(ctx, `
-		DELETE FROM metadatabase.min_max WHERE database = {database:String}
+		DELETE FROM {metadatabase:Identifier}.min_max WHERE database = {database:String}
 	`, database)
 	return err
 }
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterCommittedFiles(t *testing.T) {
+	fileList := []string{"/logs/conn.log", "/logs/dns.log", "/logs/http.log", "/logs/ssl.log"}
+
+	original := util.FixedString{Data: [16]byte{1}}
+	modified := util.FixedString{Data: [16]byte{2}}
+
+	checksums := map[string]FileContents{
+		"/logs/conn.log": {Checksum: original, Size: 200},
+		"/logs/dns.log":  {Checksum: original, Size: 200},
+		"/logs/http.log": {Checksum: original, Size: 200},
+		"/logs/ssl.log":  {Checksum: original, Size: 200},
+	}
+
+	tests := []struct {
+		name          string
+		importedFiles []importedFile
+		checksums     map[string]FileContents
+		expected      []string
+		changed       map[string]FileContents
+	}{
+		{
+			name:          "No Files Previously Imported",
+			importedFiles: nil,
+			checksums:     checksums,
+			expected:      fileList,
+		},
+		{
+			name: "All Files Committed",
+			importedFiles: []importedFile{
+				{Path: "/logs/conn.log", Committed: true, Checksum: original},
+				{Path: "/logs/dns.log", Committed: true, Checksum: original},
+				{Path: "/logs/http.log", Committed: true, Checksum: original},
+				{Path: "/logs/ssl.log", Committed: true, Checksum: original},
+			},
+			checksums: checksums,
+			expected:  nil,
+		},
+		{
+			name: "Interrupted Import",
+			importedFiles: []importedFile{
+				{Path: "/logs/conn.log", Committed: true, Checksum: original},
+				{Path: "/logs/dns.log", Committed: false},
+			},
+			checksums: checksums,
+			expected:  []string{"/logs/dns.log", "/logs/http.log", "/logs/ssl.log"},
+		},
+		{
+			name: "Imported Files Not In List",
+			importedFiles: []importedFile{
+				{Path: "/other/conn.log", Committed: true, Checksum: original},
+			},
+			checksums: checksums,
+			expected:  fileList,
+		},
+		{
+			name: "Committed File Was Modified",
+			importedFiles: []importedFile{
+				{Path: "/logs/conn.log", Committed: true, Checksum: modified, Size: 100},
+				{Path: "/logs/dns.log", Committed: true, Checksum: original},
+				{Path: "/logs/http.log", Committed: true, Checksum: original},
+				{Path: "/logs/ssl.log", Committed: true, Checksum: original},
+			},
+			checksums: checksums,
+			expected:  []string{"/logs/conn.log"},
+			changed:   map[string]FileContents{"/logs/conn.log": {Checksum: modified, Size: 100}},
+		},
+		{
+			name: "Committed Before Checksums Were Recorded",
+			importedFiles: []importedFile{
+				{Path: "/logs/conn.log", Committed: true},
+				{Path: "/logs/dns.log", Committed: true},
+				{Path: "/logs/http.log", Committed: true},
+				{Path: "/logs/ssl.log", Committed: true},
+			},
+			checksums: checksums,
+			expected:  nil,
+		},
+		{
+			name: "File Could Not Be Checksummed",
+			importedFiles: []importedFile{
+				{Path: "/logs/conn.log", Committed: true, Checksum: original},
+			},
+			checksums: map[string]FileContents{},
+			expected:  []string{"/logs/dns.log", "/logs/http.log", "/logs/ssl.log"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remaining, changed := filterCommittedFiles(fileList, test.importedFiles, test.checksums)
+			require.Equal(t, test.expected, remaining)
+			if test.changed == nil {
+				test.changed = map[string]FileContents{}
+			}
+			require.Equal(t, test.changed, changed, "the committed contents of the modified files should be returned")
+		})
+	}
+}
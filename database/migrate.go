@@ -0,0 +1,393 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/activecm/rita/v5/config"
+	zlog "github.com/activecm/rita/v5/logger"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+var ErrSchemaNewerThanBinary = errors.New("dataset schema is newer than this version of RITA supports, refusing to downgrade")
+var ErrSchemaOutdated = errors.New("dataset schema is out of date, run 'rita migrate --database <name>' to upgrade it")
+
+// SchemaVersion is the version of the sensor database schema created by this version of RITA.
+// It must be bumped along with a new entry in Migrations whenever a column is added to an existing table.
+const SchemaVersion uint32 = 16
+
+// Migration is a single step that upgrades a sensor database schema to Version
+type Migration struct {
+	Version     uint32
+	Description string
+	// Views are materialized views whose definitions changed in this version. They are dropped before
+	// the columns are added and recreated once all steps have been applied.
+	Views   []string
+	Columns []MigrationColumn
+}
+
+// MigrationColumn is a column that is added to an existing table
+type MigrationColumn struct {
+	Table      string
+	Name       string
+	Definition string
+	// After is the column that the new column is placed after so that migrated tables have the
+	// same column order as newly created ones, the column is added to the end of the table if empty
+	After string
+}
+
+// Migrations are the ordered steps that upgrade a sensor database schema created by the first
+// release of RITA v5 (version 0) to SchemaVersion. Tables that didn't exist in an older version
+// are not listed here, they are created along with the dropped views after the steps are applied.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "record the sensor of each connection",
+		Views:       []string{"uconn_mv", "usni_ssl_mv", "usni_http_mv"},
+		Columns: []MigrationColumn{
+			{Table: "conn_tmp", Name: "sensor", Definition: "String"},
+			{Table: "openconn_tmp", Name: "sensor", Definition: "String"},
+			{Table: "conn", Name: "sensor", Definition: "String"},
+			{Table: "openconn", Name: "sensor", Definition: "String"},
+			{Table: "http", Name: "sensor", Definition: "String"},
+			{Table: "openhttp", Name: "sensor", Definition: "String"},
+			{Table: "ssl", Name: "sensor", Definition: "String"},
+			{Table: "openssl", Name: "sensor", Definition: "String"},
+			{Table: "uconn", Name: "sensors", Definition: "AggregateFunction(groupUniqArray, String)", After: "last_seen"},
+			{Table: "usni", Name: "sensors", Definition: "AggregateFunction(groupUniqArray, String)", After: "last_seen"},
+			{Table: "threat_mixtape", Name: "sensor", Definition: "String", After: "port_proto_service"},
+		},
+	},
+	{
+		Version:     2,
+		Description: "count failed connections",
+		Views:       []string{"uconn_mv"},
+		Columns: []MigrationColumn{
+			{Table: "uconn", Name: "failed_count", Definition: "AggregateFunction(count, Int64)", After: "missing_host_header_count"},
+		},
+	},
+	{
+		Version:     3,
+		Description: "store beacon histograms in the mixtape",
+		Columns: []MigrationColumn{
+			{Table: "threat_mixtape", Name: "hist_bin_edges", Definition: "Array(Float64)", After: "ds_size_counts"},
+			{Table: "threat_mixtape", Name: "hist_counts", Definition: "Array(Int64)", After: "hist_bin_edges"},
+		},
+	},
+	{
+		Version:     4,
+		Description: "count NXDOMAIN responses",
+		Views:       []string{"udns_mv"},
+		Columns: []MigrationColumn{
+			{Table: "udns", Name: "nxdomain_count", Definition: "AggregateFunction(count, UInt64)", After: "visits"},
+		},
+	},
+	{
+		Version:     5,
+		Description: "store connection session durations",
+		Views:       []string{"uconn_mv"},
+		Columns: []MigrationColumn{
+			{Table: "uconn", Name: "duration_list", Definition: "AggregateFunction(groupArray(86400), Float64)", After: "total_duration"},
+		},
+	},
+	{
+		Version:     6,
+		Description: "store bytes received for beacon data size scoring",
+		Views:       []string{"uconn_mv", "usni_ssl_mv", "usni_http_mv"},
+		Columns: []MigrationColumn{
+			{Table: "uconn", Name: "dst_ip_bytes_list", Definition: "AggregateFunction(groupArray(86400), Int64)", After: "src_ip_bytes_list"},
+			{Table: "usni", Name: "dst_ip_bytes_list", Definition: "AggregateFunction(groupArray(86400), Int64)", After: "src_ip_bytes_list"},
+		},
+	},
+	{
+		Version:     7,
+		Description: "store server certificate fingerprints to join ssl connections with x509 certificates",
+		Columns: []MigrationColumn{
+			{Table: "ssl_tmp", Name: "server_cert_fps", Definition: "Array(String)", After: "server_cert_fuids"},
+			{Table: "openssl_tmp", Name: "server_cert_fps", Definition: "Array(String)", After: "server_cert_fuids"},
+			{Table: "ssl", Name: "server_cert_fps", Definition: "Array(String)", After: "server_cert_fuids"},
+			{Table: "openssl", Name: "server_cert_fps", Definition: "Array(String)", After: "server_cert_fuids"},
+		},
+	},
+	{
+		Version:     8,
+		Description: "store beacon interval percentiles in the mixtape",
+		Columns: []MigrationColumn{
+			{Table: "threat_mixtape", Name: "ts_interval_p50", Definition: "Int64", After: "ts_interval_counts"},
+			{Table: "threat_mixtape", Name: "ts_interval_p90", Definition: "Int64", After: "ts_interval_p50"},
+			{Table: "threat_mixtape", Name: "ts_interval_p99", Definition: "Int64", After: "ts_interval_p90"},
+		},
+	},
+	{
+		Version:     9,
+		Description: "flag results to destinations on the beacon allowlist",
+		Columns: []MigrationColumn{
+			{Table: "threat_mixtape", Name: "allowlisted", Definition: "Bool", After: "beacon_type"},
+		},
+	},
+	{
+		Version:     10,
+		Description: "store the country and ASN of external destinations in the mixtape",
+		Columns: []MigrationColumn{
+			{Table: "threat_mixtape", Name: "dst_country", Definition: "LowCardinality(String)", After: "allowlisted"},
+			{Table: "threat_mixtape", Name: "dst_asn", Definition: "UInt32", After: "dst_country"},
+			{Table: "threat_mixtape", Name: "dst_as_org", Definition: "String", After: "dst_asn"},
+		},
+	},
+	{
+		Version:     11,
+		Description: "leave connections under min_connection_bytes out of beaconing",
+		Views:       []string{"uconn_mv"},
+		Columns: []MigrationColumn{
+			{Table: "conn_tmp", Name: "beacon_excluded", Definition: "Bool", After: "sensor"},
+			{Table: "openconn_tmp", Name: "beacon_excluded", Definition: "Bool", After: "sensor"},
+			{Table: "conn", Name: "beacon_excluded", Definition: "Bool", After: "sensor"},
+			{Table: "openconn", Name: "beacon_excluded", Definition: "Bool", After: "sensor"},
+		},
+	},
+	{
+		Version:     12,
+		Description: "count connections with missing responder bytes and optionally leave them out of datasize scoring",
+		Views:       []string{"uconn_mv"},
+		Columns: []MigrationColumn{
+			{Table: "conn_tmp", Name: "missing_dst_bytes", Definition: "Bool", After: "beacon_excluded"},
+			{Table: "conn_tmp", Name: "datasize_excluded", Definition: "Bool", After: "missing_dst_bytes"},
+			{Table: "openconn_tmp", Name: "missing_dst_bytes", Definition: "Bool", After: "beacon_excluded"},
+			{Table: "openconn_tmp", Name: "datasize_excluded", Definition: "Bool", After: "missing_dst_bytes"},
+			{Table: "conn", Name: "missing_dst_bytes", Definition: "Bool", After: "beacon_excluded"},
+			{Table: "conn", Name: "datasize_excluded", Definition: "Bool", After: "missing_dst_bytes"},
+			{Table: "openconn", Name: "missing_dst_bytes", Definition: "Bool", After: "beacon_excluded"},
+			{Table: "openconn", Name: "datasize_excluded", Definition: "Bool", After: "missing_dst_bytes"},
+			{Table: "uconn", Name: "missing_bytes_count", Definition: "AggregateFunction(count, Int64)", After: "failed_count"},
+			{Table: "threat_mixtape", Name: "missing_bytes_count", Definition: "UInt64", After: "missing_host_header_score"},
+		},
+	},
+	{
+		Version:     13,
+		Description: "store the CNAME chain depth of DNS queries",
+		Views:       []string{"udns_mv"},
+		Columns: []MigrationColumn{
+			{Table: "dns", Name: "cname_depth", Definition: "UInt8", After: "rejected"},
+			{Table: "udns", Name: "max_cname_depth", Definition: "AggregateFunction(max, UInt8)", After: "nxdomain_count"},
+		},
+	},
+	{
+		Version:     14,
+		Description: "track the historical first seen dates of each internal network separately",
+		Views: []string{
+			"historical_first_seen_conn_mv", "historical_first_seen_openconn_mv",
+			"historical_first_seen_ssl_mv", "historical_first_seen_openssl_mv",
+			"historical_first_seen_http_mv", "historical_first_seen_openhttp_mv",
+			"historical_first_seen_dns_mv",
+		},
+	},
+	{
+		Version:     15,
+		Description: "store the community id of connections",
+		Columns: []MigrationColumn{
+			{Table: "conn_tmp", Name: "community_id", Definition: "String", After: "datasize_excluded"},
+			{Table: "openconn_tmp", Name: "community_id", Definition: "String", After: "datasize_excluded"},
+			{Table: "conn", Name: "community_id", Definition: "String", After: "datasize_excluded"},
+			{Table: "openconn", Name: "community_id", Definition: "String", After: "datasize_excluded"},
+		},
+	},
+	{
+		Version:     16,
+		Description: "store SNI connection session durations",
+		Views:       []string{"usni_ssl_mv", "usni_http_mv"},
+		Columns: []MigrationColumn{
+			{Table: "usni", Name: "duration_list", Definition: "AggregateFunction(groupArray(86400), Float64)", After: "total_duration"},
+		},
+	},
+}
+
+// PendingMigrations returns the migrations that must be applied, in order, to upgrade a schema from
+// the given version to SchemaVersion. It returns an error if the schema is newer than SchemaVersion.
+func PendingMigrations(current uint32) ([]Migration, error) {
+	if current > SchemaVersion {
+		return nil, fmt.Errorf("%w: dataset is at version %d, this version of RITA supports version %d", ErrSchemaNewerThanBinary, current, SchemaVersion)
+	}
+
+	var pending []Migration
+	for _, migration := range Migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// statement returns the ALTER TABLE statement that adds the column if it doesn't exist yet
+func (c MigrationColumn) statement() string {
+	query := fmt.Sprintf("ALTER TABLE {database:Identifier}.{table:Identifier} ADD COLUMN IF NOT EXISTS %s %s", c.Name, c.Definition)
+	if c.After != "" {
+		query += " AFTER " + c.After
+	}
+	return query
+}
+
+// createMetaDatabaseSchemaVersionsTable creates the metadatabase.schema_versions table
+func (server *ServerConn) createMetaDatabaseSchemaVersionsTable() error {
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.schema_versions (
+			database String,
+			version UInt32,
+			migrated_at DateTime(),
+			rita_version String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (database, version)
+	`)
+	return err
+}
+
+// GetSchemaVersion returns the schema version recorded for the specified database. Databases that were
+// created before schema versions were recorded are at version 0.
+func (server *ServerConn) GetSchemaVersion(database string) (uint32, error) {
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": database})
+
+	var version uint32
+	err := server.Conn.QueryRow(ctx, `
+		SELECT max(version) FROM {metadatabase:Identifier}.schema_versions WHERE database = {database:String}
+	`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// recordSchemaVersion records that the specified database is at the given schema version. The version is
+// stored as a single row so that it is either fully recorded or not recorded at all.
+func (server *ServerConn) recordSchemaVersion(database string, version uint32) error {
+	ctx := server.QueryParameters(clickhouse.Parameters{
+		"database":    database,
+		"version":     strconv.FormatUint(uint64(version), 10),
+		"ritaVersion": config.Version,
+	})
+
+	err := server.Conn.Exec(ctx, `
+		INSERT INTO {metadatabase:Identifier}.schema_versions (database, version, migrated_at, rita_version)
+		VALUES ({database:String}, {version:UInt32}, now(), {ritaVersion:String})
+	`)
+	return err
+}
+
+// clearSchemaVersionsFromMetaDB deletes the recorded schema versions for the specified database
+func (server *ServerConn) clearSchemaVersionsFromMetaDB(database string) error {
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": database})
+	err := server.Conn.Exec(ctx, `
+		DELETE FROM {metadatabase:Identifier}.schema_versions WHERE database = {database:String}
+	`)
+	return err
+}
+
+// checkSchemaVersion returns an error if an existing database needs to be migrated before it can be imported into
+func (server *ServerConn) checkSchemaVersion(database string) error {
+	version, err := server.GetSchemaVersion(database)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version > SchemaVersion:
+		return fmt.Errorf("%w: dataset is at version %d, this version of RITA supports version %d", ErrSchemaNewerThanBinary, version, SchemaVersion)
+	case version < SchemaVersion:
+		return fmt.Errorf("%w: dataset is at version %d, expected version %d", ErrSchemaOutdated, version, SchemaVersion)
+	}
+
+	return nil
+}
+
+// MigrateSensorDB upgrades the schema of the specified database to SchemaVersion and returns the version it
+// was upgraded from. Running it on a database that is already up to date does nothing.
+func (server *ServerConn) MigrateSensorDB(cfg *config.Config, database string) (uint32, error) {
+	logger := zlog.GetLogger()
+
+	exists, err := DatabaseExists(server.ctx, server.Conn, database)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrDatabaseNotFound
+	}
+
+	// make sure the schema versions table exists for metadatabases created by older versions
+	if err := server.CreateServerDBTables(); err != nil {
+		return 0, err
+	}
+
+	current, err := server.GetSchemaVersion(database)
+	if err != nil {
+		return 0, err
+	}
+
+	pending, err := PendingMigrations(current)
+	if err != nil {
+		return current, err
+	}
+
+	if len(pending) == 0 {
+		return current, nil
+	}
+
+	rolling, err := GetRollingStatus(server.ctx, server.Conn, server.metaDatabase, database)
+	if err != nil && !errors.Is(err, ErrDatabaseNotFound) && !errors.Is(err, sql.ErrNoRows) {
+		return current, err
+	}
+
+	db, err := ConnectToDB(server.ctx, database, cfg, server.cancel)
+	if err != nil {
+		return current, err
+	}
+	defer db.Close()
+	db.Rolling = rolling
+
+	for _, migration := range pending {
+		logger.Info().Str("database", database).Uint32("version", migration.Version).Msg("Migrating schema: " + migration.Description)
+
+		// all statements are idempotent so that a migration which failed part way through can be run again
+		for _, view := range migration.Views {
+			ctx := server.QueryParameters(clickhouse.Parameters{"database": database, "table": view})
+			if err := server.Conn.Exec(ctx, "DROP VIEW IF EXISTS {database:Identifier}.{table:Identifier}"); err != nil {
+				return current, err
+			}
+		}
+
+		for _, column := range migration.Columns {
+			ctx := server.QueryParameters(clickhouse.Parameters{"database": database, "table": column.Table})
+			if err := server.Conn.Exec(ctx, column.statement()); err != nil {
+				return current, fmt.Errorf("failed to add column %s to %s.%s: %w", column.Name, database, column.Table, err)
+			}
+		}
+	}
+
+	// recreate the dropped views and any tables that didn't exist in older versions
+	if err := db.createSensorDBTables(); err != nil {
+		return current, err
+	}
+
+	if err := db.createSensorDBAnalysisTables(); err != nil {
+		return current, err
+	}
+
+	if db.Rolling {
+		if err := db.createLogTableTTLs(); err != nil {
+			return current, err
+		}
+
+		if err := db.createSnapshotTableTTLs(); err != nil {
+			return current, err
+		}
+	}
+
+	if err := server.recordSchemaVersion(database, SchemaVersion); err != nil {
+		return current, err
+	}
+
+	return current, nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	require.NotEmpty(t, Migrations)
+
+	// migrations must be in order, with no gaps, and end at the current schema version
+	for i, migration := range Migrations {
+		require.Equal(t, uint32(i+1), migration.Version, "migration %d should have the next version", i)
+		require.NotEmpty(t, migration.Description, "migration %d should have a description", migration.Version)
+
+		for _, column := range migration.Columns {
+			require.NotEmpty(t, column.Table, "migration %d columns should have a table", migration.Version)
+			require.NotEmpty(t, column.Name, "migration %d columns should have a name", migration.Version)
+			require.NotEmpty(t, column.Definition, "migration %d columns should have a definition", migration.Version)
+		}
+	}
+	require.Equal(t, SchemaVersion, Migrations[len(Migrations)-1].Version, "last migration should be the current schema version")
+}
+
+func TestPendingMigrations(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     uint32
+		expected    []uint32
+		expectedErr error
+	}{
+		{
+			name:     "Unversioned Dataset",
+			current:  0,
+			expected: []uint32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		},
+		{
+			name:     "Partially Migrated Dataset",
+			current:  4,
+			expected: []uint32{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		},
+		{
+			name:     "Up To Date Dataset",
+			current:  SchemaVersion,
+			expected: nil,
+		},
+		{
+			name:        "Newer Dataset",
+			current:     SchemaVersion + 1,
+			expectedErr: ErrSchemaNewerThanBinary,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pending, err := PendingMigrations(test.current)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var versions []uint32
+			for _, migration := range pending {
+				versions = append(versions, migration.Version)
+			}
+			require.Equal(t, test.expected, versions)
+		})
+	}
+}
+
+func TestMigrationColumnStatement(t *testing.T) {
+	column := MigrationColumn{Table: "uconn", Name: "failed_count", Definition: "AggregateFunction(count, Int64)", After: "missing_host_header_count"}
+	require.Equal(t, "ALTER TABLE {database:Identifier}.{table:Identifier} ADD COLUMN IF NOT EXISTS failed_count AggregateFunction(count, Int64) AFTER missing_host_header_count", column.statement())
+
+	column = MigrationColumn{Table: "conn", Name: "sensor", Definition: "String"}
+	require.Equal(t, "ALTER TABLE {database:Identifier}.{table:Identifier} ADD COLUMN IF NOT EXISTS sensor String", column.statement())
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// CreateParentDomainTable stores the parent domain of each subdomain in a Join table, so that the analysis queries can
+// look the parent domain of an SNI connection up with joinGet instead of passing every subdomain as a query parameter.
+// The table is replaced on every call, and its name is returned qualified by the database for joinGet.
+func (db *DB) CreateParentDomainTable(subdomains []string, parents []string) (string, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	if err := db.DropParentDomainTable(); err != nil {
+		return "", err
+	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE {database:Identifier}.parent_domains_tmp (
+			fqdn String,
+			parent String
+		) ENGINE = Join(ANY, LEFT, fqdn)
+	`); err != nil {
+		return "", err
+	}
+
+	// the table is still created when there aren't any subdomains, since joinGet needs it to exist
+	table := db.selected + ".parent_domains_tmp"
+	if len(subdomains) == 0 {
+		return table, nil
+	}
+
+	batch, err := db.Conn.PrepareBatch(ctx, "INSERT INTO {database:Identifier}.parent_domains_tmp")
+	if err != nil {
+		return "", err
+	}
+	for i := range subdomains {
+		if err := batch.Append(subdomains[i], parents[i]); err != nil {
+			return "", err
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return "", err
+	}
+
+	return table, nil
+}
+
+// DropParentDomainTable removes the table of parent domains once the queries that look them up have finished
+func (db *DB) DropParentDomainTable() error {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	return db.Conn.Exec(ctx, `--sql
+		DROP TABLE IF EXISTS {database:Identifier}.parent_domains_tmp
+	`)
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// StoredPair is a unique pair of hosts in the connection data that was stored by previous imports. FQDN is only set
+// for SNI and DNS pairs, HTTP and Proxy are only set for SNI pairs.
+type StoredPair struct {
+	Src   net.IP `ch:"src"`
+	Dst   net.IP `ch:"dst"`
+	FQDN  string `ch:"fqdn"`
+	HTTP  bool   `ch:"http"`
+	Proxy bool   `ch:"proxy"`
+}
+
+// the tables that hold the records of each kind of stored pair, mapped to the column that holds the domain of the pair
+var (
+	connPairTables = map[string]string{"conn": "", "uconn": "", "openconn": ""}
+	sniPairTables  = map[string]string{"ssl": "server_name", "openssl": "server_name", "http": "host", "openhttp": "host", "usni": "fqdn"}
+	dnsPairTables  = map[string]string{"dns": "query", "udns": "fqdn", "pdns_raw": "query", "pdns": "fqdn"}
+
+	// localHostTables are the stored tables with src_local and dst_local columns that can be updated in place
+	localHostTables = []string{
+		"conn", "openconn", "uconn", "http", "openhttp", "ssl", "openssl", "rdp", "ftp_proto", "kerberos_proto",
+		"ntlm_proto", "dns", "udns", "pdns_raw", "pdns",
+	}
+)
+
+// GetStoredConnPairs returns the unique source and destination pairs of the stored connections
+func (db *DB) GetStoredConnPairs() ([]StoredPair, error) {
+	return db.getStoredPairs(`--sql
+		SELECT DISTINCT src, dst, '' AS fqdn, false AS http, false AS proxy FROM (
+			SELECT src, dst FROM {database:Identifier}.uconn
+			UNION ALL
+			SELECT src, dst FROM {database:Identifier}.openconn
+		)
+	`)
+}
+
+// GetStoredSNIPairs returns the unique source, destination, and domain combinations of the stored SSL and HTTP connections
+func (db *DB) GetStoredSNIPairs() ([]StoredPair, error) {
+	return db.getStoredPairs(`--sql
+		SELECT DISTINCT src, dst, fqdn, http, proxy FROM (
+			SELECT src, dst, fqdn, http, proxy FROM {database:Identifier}.usni
+			UNION ALL
+			SELECT src, dst, server_name AS fqdn, false AS http, false AS proxy FROM {database:Identifier}.openssl
+			UNION ALL
+			SELECT src, dst, host AS fqdn, true AS http, method = 'CONNECT' AS proxy FROM {database:Identifier}.openhttp
+		)
+	`)
+}
+
+// GetStoredDNSPairs returns the unique source, resolver, and queried domain combinations of the stored DNS queries
+func (db *DB) GetStoredDNSPairs() ([]StoredPair, error) {
+	return db.getStoredPairs(`--sql
+		SELECT DISTINCT src, dst, fqdn, false AS http, false AS proxy FROM {database:Identifier}.udns
+	`)
+}
+
+func (db *DB) getStoredPairs(query string) ([]StoredPair, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	var pairs []StoredPair
+	if err := db.Conn.Select(ctx, &pairs, query); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// RemoveConnPairs deletes the stored connections between the given pairs of hosts
+func (db *DB) RemoveConnPairs(pairs []StoredPair) error {
+	return db.removeStoredPairs(pairs, connPairTables)
+}
+
+// RemoveSNIPairs deletes the stored SSL and HTTP connections of the given pairs of hosts to their domain
+func (db *DB) RemoveSNIPairs(pairs []StoredPair) error {
+	return db.removeStoredPairs(pairs, sniPairTables)
+}
+
+// RemoveDNSPairs deletes the stored DNS queries of the given pairs of hosts for their domain
+func (db *DB) RemoveDNSPairs(pairs []StoredPair) error {
+	return db.removeStoredPairs(pairs, dnsPairTables)
+}
+
+// removeStoredPairs loads the pairs into a scratch table and deletes the records of each table that match one of them
+func (db *DB) removeStoredPairs(pairs []StoredPair, tables map[string]string) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	if err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.removed_pairs_tmp (
+			src IPv6,
+			dst IPv6,
+			fqdn String,
+			http Bool,
+			proxy Bool
+		) ENGINE = Memory
+	`); err != nil {
+		return err
+	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		TRUNCATE TABLE {database:Identifier}.removed_pairs_tmp
+	`); err != nil {
+		return err
+	}
+
+	batch, err := db.Conn.PrepareBatch(ctx, "INSERT INTO {database:Identifier}.removed_pairs_tmp")
+	if err != nil {
+		return err
+	}
+	for i := range pairs {
+		if err := batch.AppendStruct(&pairs[i]); err != nil {
+			return err
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return err
+	}
+
+	for table, fqdnColumn := range tables {
+		condition := "(src, dst) IN (SELECT src, dst FROM {database:Identifier}.removed_pairs_tmp)"
+		if fqdnColumn != "" {
+			condition = "(src, dst, " + fqdnColumn + ") IN (SELECT src, dst, fqdn FROM {database:Identifier}.removed_pairs_tmp)"
+		}
+
+		if err := db.Conn.Exec(ctx, "DELETE FROM {database:Identifier}."+table+" WHERE "+condition); err != nil {
+			return err
+		}
+	}
+
+	return db.Conn.Exec(ctx, `--sql
+		DROP TABLE IF EXISTS {database:Identifier}.removed_pairs_tmp
+	`)
+}
+
+// ReloadTemporaryTables fills the tmp tables that list the hashes to analyze with every hash in the stored connection
+// data, instead of only the hashes of the most recent import, so that analysis can be re-run over the whole dataset
+func (db *DB) ReloadTemporaryTables() error {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	for _, table := range []string{"uconn_tmp", "openconnhash_tmp", "sniconn_tmp", "opensniconn_tmp", "dns_tmp"} {
+		if err := db.Conn.Exec(ctx, "TRUNCATE TABLE IF EXISTS {database:Identifier}."+table); err != nil {
+			return err
+		}
+	}
+
+	// these mirror the materialized views that fill the tmp tables during an import
+	queries := []string{
+		`INSERT INTO {database:Identifier}.uconn_tmp (hash, zeek_uid, count)
+		SELECT hash, zeek_uid, countState() FROM {database:Identifier}.conn GROUP BY (hash, zeek_uid)`,
+
+		`INSERT INTO {database:Identifier}.openconnhash_tmp (hash, zeek_uid, count)
+		SELECT hash, zeek_uid, countState() FROM {database:Identifier}.openconn GROUP BY (hash, zeek_uid)`,
+
+		`INSERT INTO {database:Identifier}.sniconn_tmp (conn_type, hash, zeek_uid, count)
+		SELECT 'ssl' AS conn_type, hash, zeek_uid, countState() FROM {database:Identifier}.ssl GROUP BY (conn_type, hash, zeek_uid)`,
+
+		`INSERT INTO {database:Identifier}.sniconn_tmp (conn_type, hash, zeek_uid, count)
+		SELECT 'http' AS conn_type, hash, zeek_uid, countState() FROM {database:Identifier}.http GROUP BY (conn_type, hash, zeek_uid)`,
+
+		`INSERT INTO {database:Identifier}.opensniconn_tmp (conn_type, hash, zeek_uid, count)
+		SELECT 'ssl' AS conn_type, hash, zeek_uid, countState() FROM {database:Identifier}.openssl GROUP BY (conn_type, hash, zeek_uid)`,
+
+		`INSERT INTO {database:Identifier}.opensniconn_tmp (conn_type, hash, zeek_uid, count)
+		SELECT 'http' AS conn_type, hash, zeek_uid, countState() FROM {database:Identifier}.openhttp GROUP BY (conn_type, hash, zeek_uid)`,
+
+		`INSERT INTO {database:Identifier}.dns_tmp (tld, count)
+		SELECT cutToFirstSignificantSubdomain(query) AS tld, countState() FROM {database:Identifier}.dns GROUP BY (tld)`,
+	}
+
+	for _, query := range queries {
+		if err := db.Conn.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClearAnalysisResults removes the results of previous analyses that were last seen since the given time, which is the
+// start of the window that is analyzed again, for the connections from the src hosts to the dst hosts. Results from
+// before the window are kept, since they aren't analyzed again. An empty list of hosts matches every host.
+func (db *DB) ClearAnalysisResults(since time.Time, src []net.IP, dst []net.IP) error {
+	conds := []string{"last_seen >= fromUnixTimestamp({since:Int64})"}
+	if len(src) > 0 {
+		conds = append(conds, "src IN {only_src:Array(IPv6)}")
+	}
+	if len(dst) > 0 {
+		conds = append(conds, "dst IN {only_dst:Array(IPv6)}")
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+		"since":    fmt.Sprintf("%d", since.Unix()),
+		"only_src": formatIPv6Array(src),
+		"only_dst": formatIPv6Array(dst),
+	})
+
+	return db.Conn.Exec(ctx, `--sql
+		DELETE FROM {database:Identifier}.threat_mixtape WHERE `+strings.Join(conds, " AND "))
+}
+
+// UpdateLocalHosts recomputes the src_local and dst_local columns of the stored records from the internal subnets, so
+// that changes to internal_subnets apply to the records that were imported before. usni keeps src_local in its sorting
+// key, which can't be updated in place, so its records are inserted again with the new values and the old ones are
+// deleted.
+func (db *DB) UpdateLocalHosts(internalSubnets []*net.IPNet) error {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	// the subnets are written into the statements since the mutations can't take query parameters, they were already
+	// parsed as CIDRs, so they can't hold anything else
+	subnets := make([]string, 0, len(internalSubnets))
+	for _, subnet := range internalSubnets {
+		subnets = append(subnets, "'"+formatIPv6Subnet(subnet)+"'")
+	}
+	isLocal := func(column string) string {
+		return "arrayExists(subnet -> isIPAddressInRange(toString(" + column + "), subnet), [" + strings.Join(subnets, ",") + "])"
+	}
+	srcLocal, dstLocal := isLocal("src"), isLocal("dst")
+	changed := "(src_local != " + srcLocal + " OR dst_local != " + dstLocal + ")"
+
+	for _, table := range localHostTables {
+		if err := db.Conn.Exec(ctx, "ALTER TABLE {database:Identifier}."+table+
+			" UPDATE src_local = "+srcLocal+", dst_local = "+dstLocal+" WHERE "+changed+" SETTINGS mutations_sync = 1"); err != nil {
+			return fmt.Errorf("could not update the local hosts of %s: %w", table, err)
+		}
+	}
+
+	if err := db.Conn.Exec(ctx, "INSERT INTO {database:Identifier}.usni SELECT * REPLACE ("+
+		srcLocal+" AS src_local, "+dstLocal+" AS dst_local) FROM {database:Identifier}.usni WHERE "+changed); err != nil {
+		return fmt.Errorf("could not update the local hosts of usni: %w", err)
+	}
+	// the records that were just inserted have the new values, so only the old ones are deleted
+	if err := db.Conn.Exec(ctx, "DELETE FROM {database:Identifier}.usni WHERE "+changed); err != nil {
+		return fmt.Errorf("could not update the local hosts of usni: %w", err)
+	}
+
+	return nil
+}
+
+// RebuildExplodedDNS counts the subdomains and visits of each domain in exploded_dns again from the stored DNS queries,
+// so that the queries that were removed from the dataset are no longer counted
+func (db *DB) RebuildExplodedDNS() error {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	if err := db.Conn.Exec(ctx, `--sql
+		TRUNCATE TABLE IF EXISTS {database:Identifier}.exploded_dns
+	`); err != nil {
+		return err
+	}
+
+	return db.Conn.Exec(ctx, `INSERT INTO {database:Identifier}.exploded_dns (import_hour, hour, tld, subdomains, visits, fqdn)`+explodedDNSQuery)
+}
+
+// formatIPv6Subnet formats a subnet in the notation of the IPv6 addresses that are stored, with IPv4 subnets written as
+// IPv4-mapped IPv6 subnets
+func formatIPv6Subnet(subnet *net.IPNet) string {
+	ones, bits := subnet.Mask.Size()
+	if ip4 := subnet.IP.To4(); ip4 != nil && bits == net.IPv4len*8 {
+		return fmt.Sprintf("::ffff:%s/%d", ip4.String(), ones+96)
+	}
+	return subnet.String()
+}
+
+// formatIPv6Array formats a list of IPs as a clickhouse array of IPv6 addresses
+func formatIPv6Array(ips []net.IP) string {
+	values := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		// IPv4 addresses are stored as IPv4-mapped IPv6 addresses
+		if ip4 := ip.To4(); ip4 != nil {
+			values = append(values, "'::ffff:"+ip4.String()+"'")
+			continue
+		}
+		values = append(values, "'"+ip.String()+"'")
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
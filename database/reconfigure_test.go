@@ -0,0 +1,28 @@
+package database
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatIPv6Subnet(t *testing.T) {
+	tests := []struct {
+		subnet   string
+		expected string
+	}{
+		{subnet: "10.0.0.0/8", expected: "::ffff:10.0.0.0/104"},
+		{subnet: "192.168.1.0/24", expected: "::ffff:192.168.1.0/120"},
+		{subnet: "0.0.0.0/0", expected: "::ffff:0.0.0.0/96"},
+		{subnet: "fd00::/8", expected: "fd00::/8"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.subnet, func(t *testing.T) {
+			_, subnet, err := net.ParseCIDR(test.subnet)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, formatIPv6Subnet(subnet))
+		})
+	}
+}
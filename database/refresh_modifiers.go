@@ -0,0 +1,173 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// RefreshedModifiers are the recomputed prevalence and first seen values and scores of an analysis result, which is
+// identified by its hash and the import that analyzed it
+type RefreshedModifiers struct {
+	Hash                util.FixedString `ch:"hash"`
+	ImportID            util.FixedString `ch:"import_id"`
+	PrevalenceTotal     uint64           `ch:"prevalence_total"`
+	Prevalence          float32          `ch:"prevalence"`
+	PrevalenceScore     float32          `ch:"prevalence_score"`
+	FirstSeenHistorical time.Time        `ch:"first_seen_historical"`
+	FirstSeenScore      float32          `ch:"first_seen_score"`
+}
+
+// UpdateRefreshedModifiers overwrites the prevalence and/or first seen columns of the analysis results in
+// threat_mixtape with the refreshed values. The results are updated in place, so they keep their analyzed_at time
+// and every other score. Modifier rows are left alone since they don't hold these columns.
+func (db *DB) UpdateRefreshedModifiers(results []RefreshedModifiers, prevalence bool, firstSeen bool) error {
+	if len(results) == 0 || (!prevalence && !firstSeen) {
+		return nil
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database":   db.selected,
+		"join_table": db.selected + ".refreshed_modifiers_tmp",
+	})
+
+	// the refreshed values are looked up by joinGet, which needs a Join table
+	if err := db.Conn.Exec(ctx, `--sql
+		DROP TABLE IF EXISTS {database:Identifier}.refreshed_modifiers_tmp
+	`); err != nil {
+		return err
+	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE {database:Identifier}.refreshed_modifiers_tmp (
+			hash FixedString(16),
+			import_id FixedString(16),
+			prevalence_total UInt64,
+			prevalence Float32,
+			prevalence_score Float32,
+			first_seen_historical DateTime(),
+			first_seen_score Float32
+		) ENGINE = Join(ANY, LEFT, hash, import_id)
+	`); err != nil {
+		return err
+	}
+
+	batch, err := db.Conn.PrepareBatch(ctx, "INSERT INTO {database:Identifier}.refreshed_modifiers_tmp")
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		if err := batch.AppendStruct(&results[i]); err != nil {
+			return err
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return err
+	}
+
+	var columns []string
+	if prevalence {
+		columns = append(columns, "prevalence_total", "prevalence", "prevalence_score")
+	}
+	if firstSeen {
+		columns = append(columns, "first_seen_historical", "first_seen_score")
+	}
+
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		assignments = append(assignments, column+" = joinGet({join_table:String}, '"+column+"', hash, import_id)")
+	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.threat_mixtape
+		UPDATE `+strings.Join(assignments, ", ")+`
+		WHERE modifier_name = '' AND (hash, import_id) IN (SELECT hash, import_id FROM {database:Identifier}.refreshed_modifiers_tmp)
+	`); err != nil {
+		return err
+	}
+
+	return db.Conn.Exec(ctx, `--sql
+		DROP TABLE IF EXISTS {database:Identifier}.refreshed_modifiers_tmp
+	`)
+}
+
+// ResultScores are the scores of an analysis result that are stored in its own columns, in the same order as the
+// columns that they are written to
+type ResultScores struct {
+	Hash   util.FixedString
+	Scores []float32
+}
+
+// UpdateResultScores overwrites the given score columns of the analysis results of an import in threat_mixtape. The
+// results are updated in place, so that the viewer and exports can keep breaking the final score down by these columns.
+func (db *DB) UpdateResultScores(importID util.FixedString, columns []string, results []ResultScores) error {
+	if len(results) == 0 || len(columns) == 0 {
+		return nil
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database":   db.selected,
+		"join_table": db.selected + ".result_scores_tmp",
+		"import_id":  importID.Hex(),
+	})
+
+	// the scores are looked up by joinGet, which needs a Join table
+	if err := db.Conn.Exec(ctx, `--sql
+		DROP TABLE IF EXISTS {database:Identifier}.result_scores_tmp
+	`); err != nil {
+		return err
+	}
+
+	definitions := make([]string, 0, len(columns)+1)
+	definitions = append(definitions, "hash FixedString(16)")
+	for _, column := range columns {
+		definitions = append(definitions, column+" Float32")
+	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE {database:Identifier}.result_scores_tmp (
+			`+strings.Join(definitions, ", ")+`
+		) ENGINE = Join(ANY, LEFT, hash)
+	`); err != nil {
+		return err
+	}
+
+	batch, err := db.Conn.PrepareBatch(ctx, "INSERT INTO {database:Identifier}.result_scores_tmp")
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		values := make([]any, 0, len(res.Scores)+1)
+		values = append(values, res.Hash)
+		for _, score := range res.Scores {
+			values = append(values, score)
+		}
+		if err := batch.Append(values...); err != nil {
+			return err
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return err
+	}
+
+	assignments := make([]string, 0, len(columns))
+	for _, column := range columns {
+		assignments = append(assignments, column+" = joinGet({join_table:String}, '"+column+"', hash)")
+	}
+
+	if err := db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.threat_mixtape
+		UPDATE `+strings.Join(assignments, ", ")+`
+		WHERE modifier_name = '' AND import_id = unhex({import_id:String})
+		AND hash IN (SELECT hash FROM {database:Identifier}.result_scores_tmp)
+	`); err != nil {
+		return err
+	}
+
+	return db.Conn.Exec(ctx, `--sql
+		DROP TABLE IF EXISTS {database:Identifier}.result_scores_tmp
+	`)
+}
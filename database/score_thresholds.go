@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// createMetaDatabaseScoreThresholdsTable creates the metadatabase.score_thresholds table, which stores the
+// beacon score thresholds that have been set for individual datasets
+func (server *ServerConn) createMetaDatabaseScoreThresholdsTable() error {
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `--sql
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.score_thresholds (
+			database String,
+			-- updated_at is measured in microseconds so that the most recent thresholds win
+			updated_at DateTime64(6),
+			base Int32,
+			low Int32,
+			medium Int32,
+			high Int32
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (database, updated_at)
+	`)
+	return err
+}
+
+// SetBeaconScoreThresholds stores beacon score thresholds for the specified database, which are used by its
+// analysis instead of the thresholds in the config file
+func (server *ServerConn) SetBeaconScoreThresholds(database string, thresholds config.ScoreThresholds) error {
+	if err := config.ValidateBeaconScoreThresholds(thresholds); err != nil {
+		return err
+	}
+
+	ctx := server.QueryParameters(clickhouse.Parameters{
+		"database": database,
+		"base":     strconv.Itoa(thresholds.Base),
+		"low":      strconv.Itoa(thresholds.Low),
+		"medium":   strconv.Itoa(thresholds.Med),
+		"high":     strconv.Itoa(thresholds.High),
+	})
+
+	err := server.Conn.Exec(ctx, `
+		INSERT INTO {metadatabase:Identifier}.score_thresholds (database, updated_at, base, low, medium, high)
+		VALUES ({database:String}, now64(6), {base:Int32}, {low:Int32}, {medium:Int32}, {high:Int32})
+	`)
+	return err
+}
+
+// ClearBeaconScoreThresholds deletes the beacon score thresholds stored for the specified database so that
+// its analysis uses the thresholds in the config file again
+func (server *ServerConn) ClearBeaconScoreThresholds(database string) error {
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": database})
+	err := server.Conn.Exec(ctx, `
+		DELETE FROM {metadatabase:Identifier}.score_thresholds WHERE database = {database:String}
+	`)
+	return err
+}
+
+// GetBeaconScoreThresholds returns the most recent beacon score thresholds stored for the selected database.
+// The returned bool is false if no thresholds have been stored for it.
+func (db *DB) GetBeaconScoreThresholds() (config.ScoreThresholds, bool, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{"database": db.selected})
+
+	var base, low, medium, high int32
+	err := db.Conn.QueryRow(ctx, `
+		SELECT base, low, medium, high FROM {metadatabase:Identifier}.score_thresholds
+		WHERE database = {database:String}
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`).Scan(&base, &low, &medium, &high)
+	if errors.Is(err, sql.ErrNoRows) {
+		return config.ScoreThresholds{}, false, nil
+	}
+	if err != nil {
+		return config.ScoreThresholds{}, false, err
+	}
+
+	return config.ScoreThresholds{Base: int(base), Low: int(low), Med: int(medium), High: int(high)}, true, nil
+}
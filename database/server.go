@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/activecm/rita/v5/config"
 	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
 
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -16,22 +18,27 @@ import (
 )
 
 type ServerConn struct {
-	Conn   driver.Conn
-	addr   string
-	ctx    context.Context
-	cancel context.CancelFunc
+	Conn driver.Conn
+	// ReadConn is used for read-only listing queries. It connects to the read replica
+	// if one is configured, otherwise it is the same connection as Conn.
+	ReadConn     driver.Conn
+	addr         string
+	metaDatabase string
+	ctx          context.Context
+	cancel       context.CancelFunc
 }
 
 var ErrNoMetaDBImportRecordForDatabase = errors.New("no import record found for database")
 var ErrDatabaseNotFound = errors.New("database does not exist")
 var ErrDatabaseNameEmpty = errors.New("database name cannot be empty")
+var ErrDatabaseNameIsMetaDatabase = errors.New("database name cannot be the same as the metadatabase")
 var ErrMissingConfig = errors.New("config cannot be nil")
 var ErrImportTwiceNonRolling = errors.New("cannot import more than once to a non-rolling database")
 var errRollingStatusFailure = errors.New("failed to detect rolling status of given import database")
 var errRollingFlagMissing = errors.New("cannot import non-rolling data to a rolling database")
 
 // SetUpNewImport creates the database requested for this import and returns a new DB struct for connection to said database
-func SetUpNewImport(afs afero.Fs, cfg *config.Config, dbName string, rollingFlag bool, rebuildFlag bool) (*DB, error) {
+func SetUpNewImport(afs afero.Fs, cfg *config.Config, dbName string, rollingFlag bool, rebuildFlag bool, refreshFeedsFlag bool) (*DB, error) {
 	logger := zlog.GetLogger()
 
 	// validate parameters
@@ -43,6 +50,11 @@ func SetUpNewImport(afs afero.Fs, cfg *config.Config, dbName string, rollingFlag
 		return nil, ErrDatabaseNameEmpty
 	}
 
+	// importing into the metadatabase would mix the dataset's tables with the records of every other dataset
+	if dbName == metaDatabaseName(cfg) {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNameIsMetaDatabase, dbName)
+	}
+
 	ctx := context.Background()
 
 	// connect to ClickHouse server
@@ -71,6 +83,17 @@ func SetUpNewImport(afs afero.Fs, cfg *config.Config, dbName string, rollingFlag
 		logger.Info().Str("database", dbName).Msg("Successfully rebuilt import database")
 	}
 
+	// existing datasets must be migrated to the current schema before more data can be imported into them
+	exists, err := DatabaseExists(ctx, server.Conn, dbName)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := server.checkSchemaVersion(dbName); err != nil {
+			return nil, err
+		}
+	}
+
 	rolling, err := server.checkRolling(dbName, rollingFlag, rebuildFlag)
 	if err != nil {
 		return nil, err
@@ -81,12 +104,19 @@ func SetUpNewImport(afs afero.Fs, cfg *config.Config, dbName string, rollingFlag
 		return nil, err
 	}
 
+	// record the schema version of newly created datasets
+	if !exists {
+		if err := server.recordSchemaVersion(dbName, SchemaVersion); err != nil {
+			return nil, err
+		}
+	}
+
 	err = db.ResetTemporaryTables()
 	if err != nil {
 		return nil, err
 	}
 
-	err = server.syncThreatIntelFeedsFromConfig(afs, cfg)
+	err = server.syncThreatIntelFeedsFromConfig(afs, cfg, refreshFeedsFlag)
 	if err != nil {
 		return nil, err
 	}
@@ -113,7 +143,12 @@ func SetUpNewImport(afs afero.Fs, cfg *config.Config, dbName string, rollingFlag
 
 // QueryParameters generates ClickHouse query parameters by creating a context with the specified parameters in it
 func (server *ServerConn) QueryParameters(params clickhouse.Parameters) context.Context {
-	return clickhouse.Context(server.ctx, clickhouse.WithParameters(params))
+	return clickhouse.Context(server.ctx, clickhouse.WithParameters(withMetaDatabase(params, server.metaDatabase)))
+}
+
+// GetMetaDatabase returns the name of the metadatabase on the server
+func (server *ServerConn) GetMetaDatabase() string {
+	return server.metaDatabase
 }
 
 // GetContext returns the context for the database connection
@@ -126,17 +161,47 @@ func (server *ServerConn) getConn() driver.Conn {
 	return server.Conn
 }
 
+// createHistoricalFirstSeenTable creates the table that tracks when each host and domain was first seen by each
+// internal network, so that the novelty of a host or domain is independent for every network
 func (server *ServerConn) createHistoricalFirstSeenTable() error {
-	err := server.Conn.Exec(context.Background(), `--sql
-		CREATE TABLE IF NOT EXISTS metadatabase.historical_first_seen (
+	ctx := server.QueryParameters(clickhouse.Parameters{
+		"legacy_nuid": util.UnknownPrivateNetworkUUID.String(),
+	})
+
+	err := server.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.historical_first_seen (
 			ip IPv6,
 			fqdn String,
+			nuid UUID,
 			first_seen SimpleAggregateFunction(min, DateTime()),
 			last_seen SimpleAggregateFunction(max, DateTime())
 		) ENGINE = AggregatingMergeTree()
 		PRIMARY KEY (fqdn, ip)
+		ORDER BY (fqdn, ip, nuid)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// tables created before first seen was tracked per network are missing the network UUID
+	var hasNUID bool
+	if err := server.Conn.QueryRow(ctx, `--sql
+		SELECT count() > 0 FROM system.columns
+		WHERE database = {metadatabase:String} AND table = 'historical_first_seen' AND name = 'nuid'
+	`).Scan(&hasNUID); err != nil {
+		return err
+	}
+	if hasNUID {
+		return nil
+	}
+
+	// the existing rows can't be attributed to a network, so they are assigned to the network that hosts
+	// without an agent UUID belong to, which keeps the history of single network deployments intact
+	return server.Conn.Exec(ctx, `--sql
+		ALTER TABLE {metadatabase:Identifier}.historical_first_seen
+			ADD COLUMN nuid UUID DEFAULT toUUID({legacy_nuid:String}) AFTER fqdn,
+			MODIFY ORDER BY (fqdn, ip, nuid)
 	`)
-	return err
 }
 
 // createSensorDatabase creates a database for the specified sensor and returns a connection to it
@@ -199,8 +264,60 @@ func (server *ServerConn) createSensorDatabase(cfg *config.Config, dbName string
 	return db, nil
 }
 
+// ReservedDatabaseNames are databases that belong to ClickHouse or RITA itself and must never be dropped as datasets
+var ReservedDatabaseNames = []string{"default", "system", "information_schema", "INFORMATION_SCHEMA", "metadatabase"}
+
+// IsReservedDatabaseName returns whether the specified database name is reserved, which includes the metadatabase
+// name set by DB_METADATABASE
+func IsReservedDatabaseName(name string) bool {
+	return slices.Contains(ReservedDatabaseNames, name) || name == config.MetaDatabaseName()
+}
+
+// metaDatabaseName returns the name of the metadatabase set in cfg, falling back to the default name for configs
+// that weren't created by GetDefaultConfig
+func metaDatabaseName(cfg *config.Config) string {
+	if cfg.MetaDatabase == "" {
+		return config.DefaultMetaDatabase
+	}
+	return cfg.MetaDatabase
+}
+
+// withMetaDatabase returns a copy of params that also holds the name of the metadatabase, so that every query can
+// refer to it as {metadatabase:Identifier}
+func withMetaDatabase(params clickhouse.Parameters, metaDB string) clickhouse.Parameters {
+	withMeta := make(clickhouse.Parameters, len(params)+1)
+	for key, value := range params {
+		withMeta[key] = value
+	}
+	if _, ok := withMeta["metadatabase"]; !ok {
+		withMeta["metadatabase"] = metaDB
+	}
+	return withMeta
+}
+
+// ListDatabaseNames returns the names of all databases on the server
+func (server *ServerConn) ListDatabaseNames() ([]string, error) {
+	rows, err := server.Conn.Query(server.ctx, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
 // DropMultipleSensorDatabases drops the databases that match the specified wildcard
 // a wildcard can be in the beginning, end, or both
+// reserved databases are never dropped, even if they match
 func (server *ServerConn) DropMultipleSensorDatabases(dbName string, wildcardStart, wildcardEnd bool) (int, error) {
 	var query string
 	// switch {
@@ -227,7 +344,7 @@ func (server *ServerConn) DropMultipleSensorDatabases(dbName string, wildcardSta
 	}
 
 	// execute the query
-	paramsCtx := clickhouse.Context(server.ctx, clickhouse.WithParameters(clickhouse.Parameters{"database": dbName}))
+	paramsCtx := server.QueryParameters(clickhouse.Parameters{"database": dbName})
 	rows, err := server.Conn.Query(paramsCtx, query)
 	if err != nil {
 		return 0, err
@@ -246,6 +363,11 @@ func (server *ServerConn) DropMultipleSensorDatabases(dbName string, wildcardSta
 			return numDeleted, err
 		}
 
+		// never drop ClickHouse's or RITA's own databases
+		if IsReservedDatabaseName(foundDB) {
+			continue
+		}
+
 		// drop the database
 		err = server.DeleteSensorDB(foundDB)
 		if err != nil {
@@ -285,14 +407,14 @@ func (server *ServerConn) DeleteSensorDB(database string) error {
 	return nil
 }
 
-// GetRollingStatus gets the rolling status of a database
-func GetRollingStatus(dbCtx context.Context, conn driver.Conn, dbName string) (bool, error) {
+// GetRollingStatus gets the rolling status of a database from the records in the metaDB metadatabase
+func GetRollingStatus(dbCtx context.Context, conn driver.Conn, metaDB string, dbName string) (bool, error) {
 	var result struct {
 		Rolling bool `ch:"rolling"`
 	}
 
 	// if import database does not exist, return an error
-	exists, err := SensorDatabaseExists(dbCtx, conn, dbName)
+	exists, err := SensorDatabaseExists(dbCtx, conn, metaDB, dbName)
 	if err != nil {
 		return false, err
 	}
@@ -301,9 +423,9 @@ func GetRollingStatus(dbCtx context.Context, conn driver.Conn, dbName string) (b
 	}
 
 	// check the rolling status by looking at the most recent rebuild
-	ctx := clickhouse.Context(dbCtx, clickhouse.WithParameters(clickhouse.Parameters{"database": dbName}))
+	ctx := clickhouse.Context(dbCtx, clickhouse.WithParameters(clickhouse.Parameters{"database": dbName, "metadatabase": metaDB}))
 	err = conn.QueryRow(ctx, `
-			SELECT rolling FROM metadatabase.min_max WHERE database = {database:String}
+			SELECT rolling FROM {metadatabase:Identifier}.min_max WHERE database = {database:String}
 			ORDER BY max_ts DESC
 			LIMIT 1
 	`).ScanStruct(&result)
@@ -320,7 +442,7 @@ func (server *ServerConn) checkRolling(dbName string, rollingFlag bool, rebuildF
 	logger := zlog.GetLogger()
 
 	// get the current rolling status of the database from the imports table (if db already exists)
-	rolling, err := GetRollingStatus(server.ctx, server.Conn, dbName)
+	rolling, err := GetRollingStatus(server.ctx, server.Conn, server.metaDatabase, dbName)
 
 	switch {
 	// if database doesn't exist, just return the desired rolling status from flag
@@ -351,17 +473,18 @@ func (server *ServerConn) checkRolling(dbName string, rollingFlag bool, rebuildF
 }
 
 type ImportDatabase struct {
-	Name    string    `ch:"database"`
-	Rolling bool      `ch:"rolling"`
-	MinTS   time.Time `ch:"min_ts"`
-	MaxTS   time.Time `ch:"max_ts"`
+	Name    string    `ch:"database" json:"name"`
+	Rolling bool      `ch:"rolling" json:"rolling"`
+	MinTS   time.Time `ch:"min_ts" json:"min_ts"`
+	MaxTS   time.Time `ch:"max_ts" json:"max_ts"`
+	Sensors []string  `json:"sensors"`
 }
 
 func (server *ServerConn) ListImportDatabases() ([]ImportDatabase, error) {
 	logger := zlog.GetLogger()
 
 	// if metadatabase does not exist, return an empty list
-	exists, err := DatabaseExists(server.ctx, server.Conn, "metadatabase")
+	exists, err := DatabaseExists(server.ctx, server.Conn, server.metaDatabase)
 	if err != nil {
 		return nil, err
 	}
@@ -374,21 +497,60 @@ func (server *ServerConn) ListImportDatabases() ([]ImportDatabase, error) {
 	// return list of databases based on min_max table
 	query := `
 		SELECT database, rolling, greatest(min_ts, timestamp_sub(WEEK, 2, max_ts)) as min_ts, max_ts FROM (
-			SELECT database, rolling, min(min_ts) AS min_ts, max(max_ts) AS max_ts FROM metadatabase.min_max
+			SELECT database, rolling, min(min_ts) AS min_ts, max(max_ts) AS max_ts FROM {metadatabase:Identifier}.min_max
 			GROUP BY database, rolling
 			ORDER BY max_ts DESC
 		)
     `
-	err = server.Conn.Select(server.ctx, &sensorDBs, query)
+	err = server.ReadConn.Select(server.QueryParameters(clickhouse.Parameters{}), &sensorDBs, query)
 	if err != nil {
 		logger.Err(err).Str("database connection", server.addr).Msg("failed to execute import database list query")
 		return nil, err
 	}
 
+	// get the sensors that each dataset was imported from
+	for i := range sensorDBs {
+		sensors, err := server.listDatabaseSensors(sensorDBs[i].Name)
+		if err != nil {
+			// datasets created by older versions don't have a sensor column, so don't fail the whole listing
+			logger.Debug().Err(err).Str("database", sensorDBs[i].Name).Msg("could not list sensors for dataset")
+			continue
+		}
+		sensorDBs[i].Sensors = sensors
+	}
+
 	return sensorDBs, nil
 }
 
-func SensorDatabaseExists(ctx context.Context, conn driver.Conn, dbName string) (bool, error) {
+// listDatabaseSensors returns the sorted list of sensors that have results in the specified database
+func (server *ServerConn) listDatabaseSensors(dbName string) ([]string, error) {
+	var sensors []string
+
+	ctx := server.QueryParameters(clickhouse.Parameters{"database": dbName})
+	rows, err := server.ReadConn.Query(ctx, `
+		SELECT DISTINCT arrayJoin(splitByChar(',', sensor)) AS sensor
+		FROM {database:Identifier}.threat_mixtape
+		WHERE sensor != ''
+		ORDER BY sensor
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sensor string
+		if err := rows.Scan(&sensor); err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, sensor)
+	}
+
+	return sensors, rows.Err()
+}
+
+// SensorDatabaseExists returns whether the database exists and has imports recorded in the metaDB metadatabase
+func SensorDatabaseExists(ctx context.Context, conn driver.Conn, metaDB string, dbName string) (bool, error) {
 	logger := zlog.GetLogger()
 	// check if database actually exists
 	dbExists, err := DatabaseExists(ctx, conn, dbName)
@@ -401,10 +563,10 @@ func SensorDatabaseExists(ctx context.Context, conn driver.Conn, dbName string)
 	}
 
 	// check if database is listed in metadatabase
-	paramsCtx := clickhouse.Context(ctx, clickhouse.WithParameters(clickhouse.Parameters{"database": dbName}))
+	paramsCtx := clickhouse.Context(ctx, clickhouse.WithParameters(clickhouse.Parameters{"database": dbName, "metadatabase": metaDB}))
 
 	var exists uint64
-	err = conn.QueryRow(paramsCtx, "SELECT count() FROM metadatabase.min_max WHERE database = {database:String}").Scan(&exists)
+	err = conn.QueryRow(paramsCtx, "SELECT count() FROM {metadatabase:Identifier}.min_max WHERE database = {database:String}").Scan(&exists)
 	if err != nil {
 		logger.Err(err).Str("database", dbName).Msg("failed to check if database exists in metadatabase")
 		return false, err
@@ -450,10 +612,36 @@ func dropDatabase(ctx context.Context, conn driver.Conn, dbName string) error {
 
 // ConnectToServer connects to the clickhouse server as the default user
 func ConnectToServer(ctx context.Context, cfg *config.Config) (*ServerConn, error) {
+	conn, err := openServerConn(ctx, cfg.DBConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	// send read-only queries to the read replica if one is configured
+	readConn := conn
+	if cfg.DBReadConnection != "" {
+		readConn, err = openServerConn(ctx, cfg.DBReadConnection)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return &ServerConn{
+		Conn:         conn,
+		ReadConn:     readConn,
+		addr:         cfg.DBConnection,
+		metaDatabase: metaDatabaseName(cfg),
+		ctx:          ctx,
+	}, nil
+}
+
+// openServerConn opens and verifies a connection to the ClickHouse server at addr as the default user
+func openServerConn(ctx context.Context, addr string) (driver.Conn, error) {
 	logger := zlog.GetLogger()
 
 	conn, err := clickhouse.Open(&clickhouse.Options{
-		Addr: []string{cfg.DBConnection}, // read from env instead
+		Addr: []string{addr}, // read from env instead
 		Auth: clickhouse.Auth{
 			Database: "default",
 			Username: "default",
@@ -463,7 +651,7 @@ func ConnectToServer(ctx context.Context, cfg *config.Config) (*ServerConn, erro
 
 	if err != nil {
 		logger.Err(err).Str("database", "default").
-			Str("database connection", cfg.DBConnection).
+			Str("database connection", addr).
 			Msg("failed to connect to ClickHouse server")
 		return nil, err
 	}
@@ -473,9 +661,10 @@ func ConnectToServer(ctx context.Context, cfg *config.Config) (*ServerConn, erro
 		return nil, err
 	}
 
-	return &ServerConn{
-		Conn: conn,
-		addr: cfg.DBConnection,
-		ctx:  ctx,
-	}, nil
+	return conn, nil
+}
+
+// Close closes the connection to the server and to the read replica, if one is configured
+func (server *ServerConn) Close() error {
+	return closeConns(server.Conn, server.ReadConn)
 }
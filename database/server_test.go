@@ -365,7 +365,7 @@ func (d *DatabaseTestSuite) TestGetRollingStatus() {
 		_, err := cmd.RunImportCmd(time.Now(), d.cfg, afero.NewOsFs(), "../test_data/valid_tsv", "testDB", true, false)
 		require.NoError(t, err, "importing data should not produce an error")
 
-		status, err := database.GetRollingStatus(context.Background(), d.server.Conn, "testDB")
+		status, err := database.GetRollingStatus(context.Background(), d.server.Conn, d.server.GetMetaDatabase(), "testDB")
 		require.NoError(t, err, "getting status of rolling database should not produce an error")
 		fmt.Print("status: ", status)
 		require.True(t, status, "status of rolling database should be true")
@@ -376,14 +376,14 @@ func (d *DatabaseTestSuite) TestGetRollingStatus() {
 		_, err := cmd.RunImportCmd(time.Now(), d.cfg, afero.NewOsFs(), "../test_data/valid_tsv", "testDB", false, false)
 		require.NoError(t, err, "importing data should not produce an error")
 
-		status, err := database.GetRollingStatus(context.Background(), d.server.Conn, "testDB")
+		status, err := database.GetRollingStatus(context.Background(), d.server.Conn, d.server.GetMetaDatabase(), "testDB")
 		require.NoError(t, err, "getting status of non-rolling database should not produce an error")
 		require.False(t, status, "status of non-rolling database should be false")
 	})
 
 	d.Run("Get Status of Non-Existent Database", func() {
 		t := d.T()
-		status, err := database.GetRollingStatus(context.Background(), d.server.Conn, "testDB")
+		status, err := database.GetRollingStatus(context.Background(), d.server.Conn, d.server.GetMetaDatabase(), "testDB")
 		require.Error(t, err, "getting status of non-existent database should produce an error")
 		require.Equal(t, err, database.ErrDatabaseNotFound, "error should be database not found")
 		require.False(t, status, "status of non-existent database should be false")
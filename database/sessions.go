@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// SessionDurationBuckets are the upper bounds, in seconds, of the buckets of a session duration histogram.
+// Sessions that are at least as long as the last bound are counted in a final bucket.
+var SessionDurationBuckets = []float64{60, 10 * 60, 3600, 4 * 3600, 12 * 3600}
+
+// SessionDurationLabels are the names of the buckets of a session duration histogram, in order
+var SessionDurationLabels = []string{"<1m", "1m-10m", "10m-1h", "1h-4h", "4h-12h", ">=12h"}
+
+// SessionBreakdown describes the individual sessions that make up the total duration of a connection pair
+type SessionBreakdown struct {
+	Count          int
+	LongestSession float64
+	TotalDuration  float64
+	Histogram      []uint64
+}
+
+// SessionDurationBucket is the number of sessions in one bucket of a session duration histogram
+type SessionDurationBucket struct {
+	Label string `json:"label"`
+	Count uint64 `json:"count"`
+}
+
+// NewSessionBreakdown summarizes a list of session durations, in seconds
+func NewSessionBreakdown(durations []float64) SessionBreakdown {
+	breakdown := SessionBreakdown{
+		Count:     len(durations),
+		Histogram: make([]uint64, len(SessionDurationBuckets)+1),
+	}
+
+	for _, duration := range durations {
+		breakdown.TotalDuration += duration
+		if duration > breakdown.LongestSession {
+			breakdown.LongestSession = duration
+		}
+
+		// find the first bucket whose upper bound is greater than the duration
+		bucket := len(SessionDurationBuckets)
+		for i, bound := range SessionDurationBuckets {
+			if duration < bound {
+				bucket = i
+				break
+			}
+		}
+		breakdown.Histogram[bucket]++
+	}
+
+	return breakdown
+}
+
+// LongestSessionRatio returns the fraction of the total duration that was spent in the longest session.
+// A ratio close to 1 means that the pair was connected in a single sustained session.
+func (b SessionBreakdown) LongestSessionRatio() float64 {
+	if b.TotalDuration <= 0 {
+		return 0
+	}
+	return b.LongestSession / b.TotalDuration
+}
+
+// Buckets returns the session duration histogram with the label of each bucket
+func (b SessionBreakdown) Buckets() []SessionDurationBucket {
+	buckets := make([]SessionDurationBucket, 0, len(b.Histogram))
+	for i, count := range b.Histogram {
+		buckets = append(buckets, SessionDurationBucket{Label: SessionDurationLabels[i], Count: count})
+	}
+	return buckets
+}
+
+// String formats the breakdown for display, ex: 3 sessions, longest 4h0m0s
+func (b SessionBreakdown) String() string {
+	sessions := "sessions"
+	if b.Count == 1 {
+		sessions = "session"
+	}
+	longest := (time.Duration(b.LongestSession) * time.Second).String()
+	return fmt.Sprintf("%d %s, longest %s", b.Count, sessions, longest)
+}
+
+// GetSessionBreakdown returns the durations of the closed and open sessions between two hosts since minTS
+func (db *DB) GetSessionBreakdown(minTS time.Time, src, dst net.IP) (SessionBreakdown, error) {
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"min_ts": fmt.Sprintf("%d", minTS.UTC().Unix()),
+		"src":    src.String(),
+		"dst":    dst.String(),
+	})
+
+	var durations []float64
+	err := db.ReadConn.QueryRow(ctx, `--sql
+		SELECT groupArrayArray(durations) AS durations FROM (
+			SELECT groupArrayMerge(86400)(duration_list) AS durations
+			FROM uconn
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			AND src = {src:String} AND dst = {dst:String}
+
+			UNION ALL
+
+			-- open connections are sessions that are still in progress
+			SELECT groupArray(86400)(duration) AS durations
+			FROM openconn
+			WHERE missing_host_header = false AND src = {src:String} AND dst = {dst:String}
+		)
+	`).Scan(&durations)
+	if err != nil {
+		return SessionBreakdown{}, err
+	}
+
+	return NewSessionBreakdown(durations), nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionBreakdown(t *testing.T) {
+	tests := []struct {
+		name              string
+		durations         []float64
+		expectedHistogram []uint64
+		expectedLongest   float64
+		expectedTotal     float64
+		expectedRatio     float64
+		expectedString    string
+	}{
+		{
+			name:              "No Sessions",
+			durations:         nil,
+			expectedHistogram: []uint64{0, 0, 0, 0, 0, 0},
+			expectedString:    "0 sessions, longest 0s",
+		},
+		{
+			name:              "Single Sustained Session",
+			durations:         []float64{12 * 3600},
+			expectedHistogram: []uint64{0, 0, 0, 0, 0, 1},
+			expectedLongest:   12 * 3600,
+			expectedTotal:     12 * 3600,
+			expectedRatio:     1,
+			expectedString:    "1 session, longest 12h0m0s",
+		},
+		{
+			name:              "Many Short Sessions",
+			durations:         []float64{1800, 1800, 1800, 1800, 1800, 1800, 1800, 1800},
+			expectedHistogram: []uint64{0, 0, 8, 0, 0, 0},
+			expectedLongest:   1800,
+			expectedTotal:     4 * 3600,
+			expectedRatio:     0.125,
+			expectedString:    "8 sessions, longest 30m0s",
+		},
+		{
+			name:              "Bucket Bounds",
+			durations:         []float64{0, 59.9, 60, 600, 3600, 4 * 3600, 12 * 3600},
+			expectedHistogram: []uint64{2, 1, 1, 1, 1, 1},
+			expectedLongest:   12 * 3600,
+			expectedTotal:     0 + 59.9 + 60 + 600 + 3600 + 4*3600 + 12*3600,
+			expectedRatio:     12 * 3600 / (0 + 59.9 + 60 + 600 + 3600 + 4*3600 + 12*3600),
+			expectedString:    "7 sessions, longest 12h0m0s",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breakdown := NewSessionBreakdown(test.durations)
+			require.Equal(t, len(test.durations), breakdown.Count)
+			require.Equal(t, test.expectedHistogram, breakdown.Histogram)
+			require.Len(t, breakdown.Histogram, len(SessionDurationLabels), "there should be a label for each bucket")
+			require.InDelta(t, test.expectedLongest, breakdown.LongestSession, 0.0001)
+			require.InDelta(t, test.expectedTotal, breakdown.TotalDuration, 0.0001)
+			require.InDelta(t, test.expectedRatio, breakdown.LongestSessionRatio(), 0.0001)
+			require.Equal(t, test.expectedString, breakdown.String())
+
+			buckets := breakdown.Buckets()
+			require.Len(t, buckets, len(SessionDurationLabels))
+			for i, bucket := range buckets {
+				require.Equal(t, SessionDurationLabels[i], bucket.Label, "buckets should be labelled in order")
+				require.Equal(t, test.expectedHistogram[i], bucket.Count)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+var ErrUnknownQueryTable = errors.New("table cannot be queried")
+
+// QueryTables are the tables that can be queried with QueryTable
+var QueryTables = []string{"rare_signatures", "tls_proto", "http_proto", "mime_type_uris"}
+
+// RareSignature is the number of destinations and domains that a source used a useragent or JA3 signature with
+type RareSignature struct {
+	Src           net.IP `ch:"src" json:"src"`
+	Signature     string `ch:"signature" json:"signature"`
+	IsJA3         bool   `ch:"is_ja3" json:"is_ja3"`
+	TimesUsedDst  uint64 `ch:"times_used_dst" json:"times_used_dst"`
+	TimesUsedFQDN uint64 `ch:"times_used_fqdn" json:"times_used_fqdn"`
+}
+
+// TLSProto is the number of TLS connections from a source to a server name with a JA3, version, and validation status
+type TLSProto struct {
+	Src              net.IP `ch:"src" json:"src"`
+	FQDN             string `ch:"fqdn" json:"fqdn"`
+	JA3              string `ch:"ja3" json:"ja3"`
+	Version          string `ch:"version" json:"version"`
+	ValidationStatus string `ch:"validation_status" json:"validation_status"`
+	Count            uint64 `ch:"count" json:"count"`
+}
+
+// HTTPProto is the number of HTTP requests from a source to a host with a useragent, method, referrer, and URI
+type HTTPProto struct {
+	Src          net.IP   `ch:"src" json:"src"`
+	FQDN         string   `ch:"fqdn" json:"fqdn"`
+	Useragent    string   `ch:"useragent" json:"useragent"`
+	Method       string   `ch:"method" json:"method"`
+	Referrer     string   `ch:"referrer" json:"referrer"`
+	URI          string   `ch:"uri" json:"uri"`
+	DstMIMETypes []string `ch:"dst_mime_types" json:"dst_mime_types"`
+	Count        uint64   `ch:"count" json:"count"`
+}
+
+// MIMETypeURI is the number of HTTP responses from a host whose MIME type didn't match the extension of the URI
+type MIMETypeURI struct {
+	Src           net.IP `ch:"src" json:"src"`
+	FQDN          string `ch:"fqdn" json:"fqdn"`
+	URI           string `ch:"uri" json:"uri"`
+	Path          string `ch:"path" json:"path"`
+	Extension     string `ch:"extension" json:"extension"`
+	MIMEType      string `ch:"mime_type" json:"mime_type"`
+	MismatchCount uint64 `ch:"mismatch_count" json:"mismatch_count"`
+}
+
+// queryTableStatements are the aggregations used to read each table that can be queried. The proto tables are
+// keyed by the hash of the source and server name, so they're joined with usni to get the source and server name.
+// The source filter is inserted in place of %s.
+var queryTableStatements = map[string]string{
+	"rare_signatures": `--sql
+		SELECT src, signature, is_ja3,
+			uniqExactMerge(times_used_dst) AS times_used_dst,
+			uniqExactMerge(times_used_fqdn) AS times_used_fqdn
+		FROM rare_signatures
+		WHERE %s
+		GROUP BY src, src_nuid, signature, is_ja3
+		ORDER BY src, is_ja3, times_used_dst, signature
+	`,
+	"tls_proto": `--sql
+		SELECT u.src AS src, u.fqdn AS fqdn, ja3, version, validation_status, countMerge(count) AS count
+		FROM tls_proto
+		INNER JOIN (SELECT DISTINCT hash, src, fqdn FROM usni WHERE %s) u USING hash
+		GROUP BY hash, src, fqdn, ja3, version, validation_status
+		ORDER BY src, fqdn, count DESC
+	`,
+	"http_proto": `--sql
+		SELECT u.src AS src, u.fqdn AS fqdn, useragent, method, referrer, uri,
+			groupUniqArrayMerge(dst_mime_types) AS dst_mime_types,
+			countMerge(count) AS count
+		FROM http_proto
+		INNER JOIN (SELECT DISTINCT hash, src, fqdn FROM usni WHERE %s) u USING hash
+		GROUP BY hash, src, fqdn, useragent, method, referrer, uri
+		ORDER BY src, fqdn, count DESC
+	`,
+	"mime_type_uris": `--sql
+		SELECT u.src AS src, u.fqdn AS fqdn, uri, path, extension, mime_type,
+			countMerge(mismatch_count) AS mismatch_count
+		FROM mime_type_uris
+		INNER JOIN (SELECT DISTINCT hash, src, fqdn FROM usni WHERE %s) u USING hash
+		GROUP BY hash, src, fqdn, uri, path, extension, mime_type
+		ORDER BY src, fqdn, mismatch_count DESC
+	`,
+}
+
+// QueryTable returns the aggregated rows of one of the QueryTables, optionally limited to the given source.
+// The rows are returned as a slice of the struct that describes the table, ie []RareSignature.
+func (db *DB) QueryTable(table string, src net.IP) (any, error) {
+	switch table {
+	case "rare_signatures":
+		return queryTable[RareSignature](db, table, src)
+	case "tls_proto":
+		return queryTable[TLSProto](db, table, src)
+	case "http_proto":
+		return queryTable[HTTPProto](db, table, src)
+	case "mime_type_uris":
+		return queryTable[MIMETypeURI](db, table, src)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueryTable, table)
+	}
+}
+
+// queryTable runs the aggregation of the table and scans each row into T
+func queryTable[T any](db *DB, table string, src net.IP) ([]T, error) {
+	filter := "true"
+	params := clickhouse.Parameters{}
+	if src != nil {
+		filter = "src = {src:String}"
+		params["src"] = src.String()
+	}
+	ctx := db.QueryParameters(params)
+
+	rows, err := db.ReadConn.Query(ctx, fmt.Sprintf(queryTableStatements[table], filter))
+	if err != nil {
+		return nil, fmt.Errorf("could not query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	results := []T{}
+	for rows.Next() {
+		var res T
+		if err := rows.ScanStruct(&res); err != nil {
+			return nil, fmt.Errorf("could not read %s row: %w", table, err)
+		}
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
@@ -14,7 +14,7 @@ func (db *DB) createMinMaxMaterializedView() error {
 	})
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.min_max_conn_mv
-		TO metadatabase.min_max AS
+		TO {metadatabase:Identifier}.min_max AS
 		SELECT
 			{database:String} as database,
 			{rolling:Bool} as rolling,
@@ -30,7 +30,7 @@ func (db *DB) createMinMaxMaterializedView() error {
 	// add proxy connections to min_max since their matching conn records get filtered out
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.min_max_http_mv
-		TO metadatabase.min_max AS
+		TO {metadatabase:Identifier}.min_max AS
 		SELECT
 			{database:String} as database,
 			{rolling:Bool} as rolling,
@@ -46,7 +46,7 @@ func (db *DB) createMinMaxMaterializedView() error {
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.min_max_openconn_mv
-		TO metadatabase.min_max AS
+		TO {metadatabase:Identifier}.min_max AS
 		SELECT
 			{database:String} as database,
 			{rolling:Bool} as rolling,
@@ -62,7 +62,7 @@ func (db *DB) createMinMaxMaterializedView() error {
 	// add proxy connections to min_max since their matching conn records get filtered out
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.min_max_openhttp_mv
-		TO metadatabase.min_max AS
+		TO {metadatabase:Identifier}.min_max AS
 		SELECT
 			{database:String} as database,
 			{rolling:Bool} as rolling,
@@ -78,7 +78,7 @@ func (db *DB) createMinMaxMaterializedView() error {
 
 	if err := db.Conn.Exec(ctx, `--sql
 		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.min_max_dns_mv
-		TO metadatabase.min_max AS
+		TO {metadatabase:Identifier}.min_max AS
 		SELECT
 			{database:String} as database,
 			{rolling:Bool} as rolling,
@@ -126,7 +126,12 @@ func (db *DB) createOpenConnTmpTable(ctx context.Context) error {
 			src_packets Int64,
 			dst_packets Int64,
 			missed_bytes Int64,
-			zeek_history String
+			zeek_history String,
+			sensor String,
+			beacon_excluded Bool,
+			missing_dst_bytes Bool,
+			datasize_excluded Bool,
+			community_id String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (filtered, dst_nuid, src_nuid, src, dst, zeek_uid)
@@ -170,7 +175,12 @@ func (db *DB) createConnTmpTable(ctx context.Context) error {
 			src_packets Int64,
 			dst_packets Int64,
 			missed_bytes Int64,
-			zeek_history String
+			zeek_history String,
+			sensor String,
+			beacon_excluded Bool,
+			missing_dst_bytes Bool,
+			datasize_excluded Bool,
+			community_id String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (filtered, dst_nuid, src_nuid, src, dst, zeek_uid)
@@ -213,7 +223,12 @@ func (db *DB) createConnTable(ctx context.Context) error {
 			src_packets Int64,
 			dst_packets Int64,
 			missed_bytes Int64,
-			zeek_history String
+			zeek_history String,
+			sensor String,
+			beacon_excluded Bool,
+			missing_dst_bytes Bool,
+			datasize_excluded Bool,
+			community_id String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (import_id, missing_host_header, dst_nuid, src_nuid, src, dst, hash)
@@ -303,8 +318,11 @@ func (db *DB) createUconnTable(ctx context.Context) error {
 		count AggregateFunction(count, Int64),
 		unique_ts_count AggregateFunction(uniqExact, DateTime()),
 		missing_host_header_count AggregateFunction(count, Int64),
+		failed_count AggregateFunction(count, Int64),
+		missing_bytes_count AggregateFunction(count, Int64),
 		ts_list AggregateFunction(groupArray(86400), UInt32),
 		src_ip_bytes_list AggregateFunction(groupArray(86400), Int64),
+		dst_ip_bytes_list AggregateFunction(groupArray(86400), Int64),
 		total_src_ip_bytes AggregateFunction(sum, Int64),
 		total_dst_ip_bytes AggregateFunction(sum, Int64),
 		total_src_bytes AggregateFunction(sum, Int64),
@@ -313,8 +331,10 @@ func (db *DB) createUconnTable(ctx context.Context) error {
 		total_src_packets AggregateFunction(sum, Int64),
 		total_dst_packets AggregateFunction(sum, Int64),
 		total_duration AggregateFunction(sum, Float64),
+		duration_list AggregateFunction(groupArray(86400), Float64),
 		first_seen AggregateFunction(min, DateTime()),
-		last_seen AggregateFunction(max, DateTime())
+		last_seen AggregateFunction(max, DateTime()),
+		sensors AggregateFunction(groupUniqArray, String)
 	) ENGINE = AggregatingMergeTree()
 	ORDER BY (hour, dst_nuid, src_nuid, src, dst, hash)
 	`)
@@ -336,10 +356,18 @@ func (db *DB) createUconnTable(ctx context.Context) error {
 		src_local,
 		dst_local,
 		countStateIf(missing_host_header = false) as count, -- count only regular conn entries to avoid inflating the count
-		uniqExactState(ts) as unique_ts_count,
+		-- connections under min_connection_bytes are left out of the beacon timestamps and data sizes, and connections
+		-- with missing byte counts are left out of the data sizes if exclude_missing_resp_bytes is enabled
+		uniqExactStateIf(ts, beacon_excluded = false) as unique_ts_count,
 		countStateIf(missing_host_header = true) as missing_host_header_count,
-		groupArrayStateIf(86400)(toUnixTimestamp(ts), missing_host_header = false) as ts_list,
-		groupArrayStateIf(86400)(c.src_ip_bytes, missing_host_header = false) as src_ip_bytes_list,
+		-- count connections that were rejected or never fully established, open connections
+		-- are not written to this table so they never count as failed
+		countStateIf(missing_host_header = false AND conn_state IN ('S0', 'REJ', 'RSTOS0', 'RSTRH', 'SH', 'SHR')) as failed_count,
+		-- count connections whose resp_ip_bytes were unset in the log
+		countStateIf(missing_host_header = false AND missing_dst_bytes = true) as missing_bytes_count,
+		groupArrayStateIf(86400)(toUnixTimestamp(ts), missing_host_header = false AND beacon_excluded = false) as ts_list,
+		groupArrayStateIf(86400)(c.src_ip_bytes, missing_host_header = false AND beacon_excluded = false AND datasize_excluded = false) as src_ip_bytes_list,
+		groupArrayStateIf(86400)(c.dst_ip_bytes, missing_host_header = false AND beacon_excluded = false AND datasize_excluded = false) as dst_ip_bytes_list,
 		sumStateIf(c.src_ip_bytes, missing_host_header = false) as total_src_ip_bytes,
 		sumStateIf(c.dst_ip_bytes, missing_host_header = false) as total_dst_ip_bytes,
 		sumStateIf(c.src_bytes, missing_host_header = false) as total_src_bytes,
@@ -348,8 +376,11 @@ func (db *DB) createUconnTable(ctx context.Context) error {
 		sumStateIf(c.src_packets, missing_host_header = false) as total_src_packets,
 		sumStateIf(c.dst_packets, missing_host_header = false) as total_dst_packets,
 		sumStateIf(duration, missing_host_header = false) as total_duration,
+		-- keep the duration of each session so that one sustained connection can be told apart from many short ones
+		groupArrayStateIf(86400)(duration, missing_host_header = false) as duration_list,
 		minState(ts) as first_seen,
-		maxState(ts) as last_seen
+		maxState(ts) as last_seen,
+		groupUniqArrayState(sensor) as sensors
 	FROM {database:Identifier}.conn c
 	GROUP BY (import_hour, hour, src, src_nuid, dst, dst_nuid, hash, src_local, dst_local)
 	`)
@@ -505,7 +536,12 @@ func (db *DB) createOpenConnTable(ctx context.Context) error {
 			src_packets Int64,
 			dst_packets Int64,
 			missed_bytes Int64,
-			zeek_history String
+			zeek_history String,
+			sensor String,
+			beacon_excluded Bool,
+			missing_dst_bytes Bool,
+			datasize_excluded Bool,
+			community_id String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (missing_host_header, dst_nuid, src_nuid, src, dst, hash, zeek_uid)
@@ -668,7 +704,8 @@ func (db *DB) createHTTPTable(ctx context.Context) error {
 			src_mime_types Array(String),
 			dst_fuids Array(String),
 			dst_file_names Array(String),
-			dst_mime_types Array(String)
+			dst_mime_types Array(String),
+			sensor String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (dst_nuid, src_nuid, src, host, dst, hash)
@@ -724,7 +761,8 @@ func (db *DB) createOpenHTTPTable(ctx context.Context) error {
 			src_mime_types Array(String),
 			dst_fuids Array(String),
 			dst_file_names Array(String),
-			dst_mime_types Array(String)
+			dst_mime_types Array(String),
+			sensor String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (dst_nuid, src_nuid, src, host, dst, hash, zeek_uid)
@@ -767,6 +805,7 @@ func (db *DB) createSSLTmpTable(ctx context.Context) error {
 			next_protocol LowCardinality(String),
 			established Bool,
 			server_cert_fuids Array(String),
+			server_cert_fps Array(String),
 			client_cert_fuids Array(String),
 			server_subject String,
 			server_issuer String,
@@ -816,6 +855,7 @@ func (db *DB) createOpenSSLTmpTable(ctx context.Context) error {
 			next_protocol LowCardinality(String),
 			established Bool,
 			server_cert_fuids Array(String),
+			server_cert_fps Array(String),
 			client_cert_fuids Array(String),
 			server_subject String,
 			server_issuer String,
@@ -865,6 +905,7 @@ func (db *DB) createSSLTable(ctx context.Context) error {
 			next_protocol LowCardinality(String),
 			established Bool,
 			server_cert_fuids Array(String),
+			server_cert_fps Array(String),
 			client_cert_fuids Array(String),
 			server_subject String,
 			server_issuer String,
@@ -872,7 +913,8 @@ func (db *DB) createSSLTable(ctx context.Context) error {
 			client_issuer String,
 			validation_status LowCardinality(String),
 			ja3 String,
-			ja3s String
+			ja3s String,
+			sensor String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (dst_nuid, src_nuid, src, server_name, dst, hash)
@@ -982,6 +1024,7 @@ func (db *DB) createUSNIConnTable(ctx context.Context) error {
 		unique_ts_count AggregateFunction(uniqExact, DateTime()),
 		ts_list AggregateFunction(groupArray(86400), UInt32),
 		src_ip_bytes_list AggregateFunction(groupArray(86400), Int64),
+		dst_ip_bytes_list AggregateFunction(groupArray(86400), Int64),
 		total_src_ip_bytes AggregateFunction(sum, Int64),
 		total_dst_ip_bytes AggregateFunction(sum, Int64),
 		total_src_bytes AggregateFunction(sum, Int64),
@@ -990,10 +1033,12 @@ func (db *DB) createUSNIConnTable(ctx context.Context) error {
 		total_src_packets AggregateFunction(sum, Int64),
 		total_dst_packets AggregateFunction(sum, Int64),
 		total_duration AggregateFunction(sum, Float64),
+		duration_list AggregateFunction(groupArray(86400), Float64),
 		server_ips AggregateFunction(groupUniqArray(10), IPv6),
 		proxy_ips AggregateFunction(groupUniqArray(10), IPv6),
 		first_seen AggregateFunction(min, DateTime()),
-    	last_seen AggregateFunction(max, DateTime())
+    	last_seen AggregateFunction(max, DateTime()),
+		sensors AggregateFunction(groupUniqArray, String)
 	)
 	ENGINE = AggregatingMergeTree()
 	ORDER BY (hour, http, src, src_nuid, src_local, fqdn, hash)
@@ -1021,6 +1066,7 @@ func (db *DB) createUSNIConnTable(ctx context.Context) error {
 		uniqExactState(ts) as unique_ts_count,
 		groupArrayState(86400)(toUnixTimestamp(ts)) as ts_list,
 		groupArrayState(86400)(s.src_ip_bytes) as src_ip_bytes_list,
+		groupArrayState(86400)(s.dst_ip_bytes) as dst_ip_bytes_list,
 		sumState(s.src_ip_bytes) as total_src_ip_bytes,
 		sumState(s.dst_ip_bytes) as total_dst_ip_bytes,
 		sumState(s.src_bytes) as total_src_bytes,
@@ -1029,9 +1075,11 @@ func (db *DB) createUSNIConnTable(ctx context.Context) error {
 		sumState(s.src_packets) as total_src_packets,
 		sumState(s.dst_packets) as total_dst_packets,
 		sumState(duration) as total_duration,
+		groupArrayState(86400)(duration) as duration_list,
 		groupUniqArrayState(10)(dst) as server_ips,
 		minState(ts) as first_seen,
-		maxState(ts) as last_seen
+		maxState(ts) as last_seen,
+		groupUniqArrayState(sensor) as sensors
 	FROM {database:Identifier}.ssl s
 	GROUP BY (import_hour, hour, src, src_nuid, src_local, dst_local, dst, dst_nuid, fqdn, hash);
 	`)
@@ -1060,6 +1108,7 @@ func (db *DB) createUSNIConnTable(ctx context.Context) error {
 		uniqExactState(ts) as unique_ts_count,
 		groupArrayState(86400)(toUnixTimestamp(ts)) as ts_list,
 		groupArrayState(86400)(h.src_ip_bytes) as src_ip_bytes_list,
+		groupArrayState(86400)(h.dst_ip_bytes) as dst_ip_bytes_list,
 		sumState(h.src_ip_bytes) as total_src_ip_bytes,
 		sumState(h.dst_ip_bytes) as total_dst_ip_bytes,
 		sumState(h.src_bytes) as total_src_bytes,
@@ -1068,10 +1117,12 @@ func (db *DB) createUSNIConnTable(ctx context.Context) error {
 		sumState(h.src_packets) as total_src_packets,
 		sumState(h.dst_packets) as total_dst_packets,
 		sumState(duration) as total_duration,
+		groupArrayState(86400)(duration) as duration_list,
 		groupUniqArrayStateIf(10)(dst, method != 'CONNECT') as server_ips,
 		groupUniqArrayStateIf(10)(dst, method = 'CONNECT') as proxy_ips,
 		minState(ts) as first_seen,
-		maxState(ts) as last_seen
+		maxState(ts) as last_seen,
+		groupUniqArrayState(sensor) as sensors
 	FROM {database:Identifier}.http h
 	WHERE h.multi_request == false
 	GROUP BY (import_hour, hour, src, src_nuid, src_local, dst_local, dst, dst_nuid, fqdn, hash, proxy);
@@ -1114,6 +1165,7 @@ func (db *DB) createOpenSSLTable(ctx context.Context) error {
 			next_protocol LowCardinality(String),
 			established Bool,
 			server_cert_fuids Array(String),
+			server_cert_fps Array(String),
 			client_cert_fuids Array(String),
 			server_subject String,
 			server_issuer String,
@@ -1121,7 +1173,8 @@ func (db *DB) createOpenSSLTable(ctx context.Context) error {
 			client_issuer String,
 			validation_status LowCardinality(String),
 			ja3 String,
-			ja3s String
+			ja3s String,
+			sensor String
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (dst_nuid, src_nuid, src, server_name, dst, hash, zeek_uid)
@@ -1131,6 +1184,195 @@ func (db *DB) createOpenSSLTable(ctx context.Context) error {
 	return err
 }
 
+func (db *DB) createRDPTmpTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.rdp_tmp (
+			import_time DateTime(),
+			zeek_uid FixedString(16),
+			hash FixedString(16),
+			ts DateTime(),
+			src IPv6,
+			dst IPv6,
+			src_nuid UUID,
+			dst_nuid UUID,
+			src_port UInt16,
+			dst_port UInt16,
+			src_local Bool,
+			dst_local Bool,
+			cookie String,
+			result LowCardinality(String),
+			security_protocol LowCardinality(String),
+			keyboard_layout LowCardinality(String),
+			client_build LowCardinality(String),
+			client_name String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (dst_nuid, src_nuid, src, dst, zeek_uid)
+	`)
+
+	return err
+}
+
+func (db *DB) createRDPTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.rdp (
+			import_time DateTime(),
+			zeek_uid FixedString(16),
+			hash FixedString(16),
+			ts DateTime(),
+			src IPv6,
+			dst IPv6,
+			src_nuid UUID,
+			dst_nuid UUID,
+			src_port UInt16,
+			dst_port UInt16,
+			duration Float64,
+			src_local Bool,
+			dst_local Bool,
+			src_bytes Int64,
+			src_ip_bytes Int64,
+			dst_bytes Int64,
+			dst_ip_bytes Int64,
+			src_packets Int64,
+			dst_packets Int64,
+			conn_state LowCardinality(String),
+			proto LowCardinality(String),
+			service LowCardinality(String),
+			cookie String,
+			result LowCardinality(String),
+			security_protocol LowCardinality(String),
+			keyboard_layout LowCardinality(String),
+			client_build LowCardinality(String),
+			client_name String,
+			sensor String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (dst_nuid, src_nuid, src, dst, hash)
+		ORDER BY (dst_nuid, src_nuid, src, dst, hash, ts)
+	`)
+
+	return err
+}
+
+func (db *DB) createFTPProtoTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.ftp_proto (
+			import_time DateTime(),
+			zeek_uid FixedString(16),
+			hash FixedString(16),
+			ts DateTime(),
+			src IPv6,
+			dst IPv6,
+			src_nuid UUID,
+			dst_nuid UUID,
+			src_port UInt16,
+			dst_port UInt16,
+			src_local Bool,
+			dst_local Bool,
+			user String,
+			command LowCardinality(String),
+			arg String,
+			mime_type LowCardinality(String),
+			file_size UInt64,
+			reply_code UInt16,
+			reply_msg String,
+			sensor String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (dst_nuid, src_nuid, src, dst, hash)
+		ORDER BY (dst_nuid, src_nuid, src, dst, hash, ts)
+	`)
+
+	return err
+}
+
+func (db *DB) createX509Table(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.x509 (
+			import_time DateTime(),
+			ts DateTime(),
+			-- the fingerprint of the certificate, or its file ID for older zeek versions
+			cert_id String,
+			fingerprint String,
+			fuid String,
+			subject String,
+			subject_cn String,
+			issuer String,
+			not_valid_before DateTime(),
+			not_valid_after DateTime(),
+			self_signed Bool,
+			host_cert Bool,
+			sensor String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (cert_id)
+		ORDER BY (cert_id, ts)
+	`)
+
+	return err
+}
+
+func (db *DB) createKerberosProtoTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.kerberos_proto (
+			import_time DateTime(),
+			zeek_uid FixedString(16),
+			hash FixedString(16),
+			ts DateTime(),
+			src IPv6,
+			dst IPv6,
+			src_nuid UUID,
+			dst_nuid UUID,
+			src_port UInt16,
+			dst_port UInt16,
+			src_local Bool,
+			dst_local Bool,
+			request_type LowCardinality(String),
+			client String,
+			service String,
+			success Bool,
+			error_msg LowCardinality(String),
+			cipher LowCardinality(String),
+			weak_cipher Bool,
+			sensor String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (src_nuid, src, dst_nuid, dst, hash)
+		ORDER BY (src_nuid, src, dst_nuid, dst, hash, ts)
+	`)
+
+	return err
+}
+
+func (db *DB) createNTLMProtoTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.ntlm_proto (
+			import_time DateTime(),
+			zeek_uid FixedString(16),
+			hash FixedString(16),
+			ts DateTime(),
+			src IPv6,
+			dst IPv6,
+			src_nuid UUID,
+			dst_nuid UUID,
+			src_port UInt16,
+			dst_port UInt16,
+			src_local Bool,
+			dst_local Bool,
+			domain LowCardinality(String),
+			username String,
+			hostname String,
+			success Bool,
+			sensor String
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (src_nuid, src, dst_nuid, dst, hash)
+		ORDER BY (src_nuid, src, dst_nuid, dst, hash, ts)
+	`)
+
+	return err
+}
+
 func (db *DB) createSNIConnTmpImportTable(ctx context.Context) error {
 
 	err := db.Conn.Exec(ctx, `--sql
@@ -1212,7 +1454,8 @@ func (db *DB) createDNSTable(ctx context.Context) error {
 			z UInt8,
 			answers Array(String),
 			ttls Array(UInt32),
-			rejected Bool
+			rejected Bool,
+			cname_depth UInt8
 		)
 		ENGINE = MergeTree()
 		PRIMARY KEY (dst_nuid, src_nuid, src, query, dst, hash)
@@ -1239,6 +1482,8 @@ func (db *DB) createUDNSTable(ctx context.Context) error {
 			src_local Bool,
 			dst_local Bool,
 			visits AggregateFunction(count, UInt64),
+			nxdomain_count AggregateFunction(count, UInt64),
+			max_cname_depth AggregateFunction(max, UInt8),
 			first_seen AggregateFunction(min, DateTime()),
 			last_seen AggregateFunction(max, DateTime())
 		)
@@ -1269,6 +1514,8 @@ func (db *DB) createUDNSTable(ctx context.Context) error {
 		src_local,
 		dst_local,
 		countState() as visits,
+		countStateIf(response_code_name = 'NXDOMAIN') as nxdomain_count,
+		maxState(cname_depth) as max_cname_depth,
 		minState(ts) as first_seen,
 		maxState(ts) as last_seen
 	FROM {database:Identifier}.dns
@@ -1367,25 +1614,9 @@ func (db *DB) createPDNSTable(ctx context.Context) error {
 
 }
 
-func (db *DB) createExplodedDNSTable(ctx context.Context) error {
-	err := db.Conn.Exec(ctx, `--sql
-	CREATE TABLE IF NOT EXISTS {database:Identifier}.exploded_dns (
-		import_hour DateTime(),
-		hour DateTime(),
-		tld String,
-		fqdn String,
-		subdomains AggregateFunction(uniqExact, String),
-		visits AggregateFunction(count, UInt64)
-	) ENGINE = AggregatingMergeTree()
-	PRIMARY KEY (hour, tld, fqdn) 
-	`)
-	if err != nil {
-		return err
-	}
-
-	err = db.Conn.Exec(ctx, `--sql
-		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.exploded_dns_mv
-		TO {database:Identifier}.exploded_dns AS
+// explodedDNSQuery counts the subdomains and visits of every level of each queried domain in udns. It is shared by the
+// materialized view that fills exploded_dns during an import and RebuildExplodedDNS.
+const explodedDNSQuery = `
 		SELECT
 			import_hour,
 			hour,
@@ -1411,7 +1642,28 @@ func (db *DB) createExplodedDNSTable(ctx context.Context) error {
 		ON p.fqdn = t.fqdn
 		WHERE tld != '' AND NOT endsWith(tld, '.arpa') AND NOT endsWith(tld, '.local')
 		GROUP BY (import_hour, hour, t.exploded_dns, tld)
+`
+
+func (db *DB) createExplodedDNSTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+	CREATE TABLE IF NOT EXISTS {database:Identifier}.exploded_dns (
+		import_hour DateTime(),
+		hour DateTime(),
+		tld String,
+		fqdn String,
+		subdomains AggregateFunction(uniqExact, String),
+		visits AggregateFunction(count, UInt64)
+	) ENGINE = AggregatingMergeTree()
+	PRIMARY KEY (hour, tld, fqdn) 
 	`)
+	if err != nil {
+		return err
+	}
+
+	err = db.Conn.Exec(ctx, `--sql
+		CREATE MATERIALIZED VIEW IF NOT EXISTS {database:Identifier}.exploded_dns_mv
+		TO {database:Identifier}.exploded_dns AS
+	`+explodedDNSQuery)
 
 	if err != nil {
 		return err
@@ -1446,6 +1698,25 @@ func (db *DB) createExplodedDNSTable(ctx context.Context) error {
 	return err
 }
 
+func (db *DB) createDNSFloodTable(ctx context.Context) error {
+	err := db.Conn.Exec(ctx, `--sql
+		CREATE TABLE IF NOT EXISTS {database:Identifier}.dns_floods (
+			import_time DateTime(),
+			import_id FixedString(16),
+			last_seen DateTime(),
+			src IPv6,
+			src_nuid UUID,
+			fqdn_count UInt64,
+			dropped_count UInt64
+		)
+		ENGINE = MergeTree()
+		PRIMARY KEY (src_nuid, src)
+		ORDER BY (src_nuid, src, last_seen)
+	`)
+
+	return err
+}
+
 func (db *DB) createSensorDBTables() error {
 	ctx := db.QueryParameters(clickhouse.Parameters{
 		"database": db.selected,
@@ -1469,6 +1740,9 @@ func (db *DB) createSensorDBTables() error {
 	if err := db.createOpenHTTPTmpTable(ctx); err != nil {
 		return err
 	}
+	if err := db.createRDPTmpTable(ctx); err != nil {
+		return err
+	}
 
 	if err := db.createConnTable(ctx); err != nil {
 		return err
@@ -1508,6 +1782,31 @@ func (db *DB) createSensorDBTables() error {
 		return err
 	}
 
+	err = db.createRDPTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = db.createFTPProtoTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = db.createX509Table(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = db.createKerberosProtoTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = db.createNTLMProtoTable(ctx)
+	if err != nil {
+		return err
+	}
+
 	err = db.createUSNIConnTable(ctx)
 	if err != nil {
 		return err
@@ -1568,6 +1867,11 @@ func (db *DB) createSensorDBTables() error {
 		return err
 	}
 
+	err = db.createDNSFloodTable(ctx)
+	if err != nil {
+		return err
+	}
+
 	if err := db.createMinMaxMaterializedView(); err != nil {
 		return err
 	}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+)
+
+const taxiiMediaType = "application/taxii+json;version=2.1"
+
+var ErrTAXIINoAPIRoot = errors.New("TAXII discovery response did not list an API root")
+var ErrTAXIIRequestFailed = errors.New("TAXII request failed")
+
+// stixPatternValue matches the IP and domain comparisons in a STIX pattern, ex: [ipv4-addr:value = '198.51.100.1']
+var stixPatternValue = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name):value\s*=\s*'([^']+)'`)
+
+// taxiiDiscovery is the response of a TAXII server discovery request
+type taxiiDiscovery struct {
+	Default  string   `json:"default"`
+	APIRoots []string `json:"api_roots"`
+}
+
+// taxiiEnvelope is a page of objects returned by a TAXII collection
+type taxiiEnvelope struct {
+	More    bool         `json:"more"`
+	Next    string       `json:"next"`
+	Objects []stixObject `json:"objects"`
+}
+
+// stixObject contains the fields of a STIX object that are used to extract indicators
+type stixObject struct {
+	Type        string `json:"type"`
+	Pattern     string `json:"pattern"`
+	PatternType string `json:"pattern_type"`
+	Value       string `json:"value"`
+	Revoked     bool   `json:"revoked"`
+}
+
+// TAXIIFeedPath returns the path that identifies the configured TAXII collection as a threat intel feed
+func TAXIIFeedPath(taxii config.TAXII) string {
+	return fmt.Sprintf("taxii:%s#%s", taxii.DiscoveryURL, taxii.CollectionID)
+}
+
+// getTAXIIFeed polls the configured TAXII collection and returns its indicators as a feed with one indicator per line
+func getTAXIIFeed(ctx context.Context, taxii config.TAXII) (io.ReadCloser, error) {
+	indicators, err := getTAXIIIndicators(ctx, http.DefaultClient, taxii)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(strings.Join(indicators, "\n"))), nil
+}
+
+// getTAXIIIndicators finds the API root of the TAXII server and returns the IP and domain indicators in the configured collection
+func getTAXIIIndicators(ctx context.Context, client *http.Client, taxii config.TAXII) ([]string, error) {
+	discoveryURL, err := url.Parse(taxii.DiscoveryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery taxiiDiscovery
+	if err := taxiiGet(ctx, client, taxii, discoveryURL.String(), &discovery); err != nil {
+		return nil, err
+	}
+
+	// use the default API root, falling back to the first one listed
+	apiRoot := discovery.Default
+	if apiRoot == "" && len(discovery.APIRoots) > 0 {
+		apiRoot = discovery.APIRoots[0]
+	}
+	if apiRoot == "" {
+		return nil, ErrTAXIINoAPIRoot
+	}
+
+	// API roots may be relative to the discovery URL
+	apiRootURL, err := discoveryURL.Parse(apiRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	objectsURL := apiRootURL.JoinPath("collections", taxii.CollectionID, "objects").String() + "/"
+
+	var objects []stixObject
+	next := ""
+	for {
+		pageURL := objectsURL
+		if next != "" {
+			pageURL += "?next=" + url.QueryEscape(next)
+		}
+
+		var envelope taxiiEnvelope
+		if err := taxiiGet(ctx, client, taxii, pageURL, &envelope); err != nil {
+			return nil, err
+		}
+		objects = append(objects, envelope.Objects...)
+
+		// keep requesting pages until the server reports that there are no more objects
+		if !envelope.More || envelope.Next == "" {
+			break
+		}
+		next = envelope.Next
+	}
+
+	return extractSTIXIndicators(objects), nil
+}
+
+// taxiiGet makes an authenticated request to a TAXII endpoint and decodes the JSON response into out
+func taxiiGet(ctx context.Context, client *http.Client, taxii config.TAXII, endpoint string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", taxiiMediaType)
+	if taxii.Username != "" {
+		req.SetBasicAuth(taxii.Username, taxii.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %s", ErrTAXIIRequestFailed, endpoint, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// extractSTIXIndicators returns the unique IP addresses and domains from STIX indicator patterns and cyber observables
+func extractSTIXIndicators(objects []stixObject) []string {
+	seen := make(map[string]bool)
+	var indicators []string
+
+	add := func(value string) {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			indicators = append(indicators, value)
+		}
+	}
+
+	for _, object := range objects {
+		if object.Revoked {
+			continue
+		}
+
+		switch object.Type {
+		case "indicator":
+			// only STIX patterns are supported, other pattern languages such as snort or yara are skipped
+			if object.PatternType != "" && object.PatternType != "stix" {
+				continue
+			}
+			for _, match := range stixPatternValue.FindAllStringSubmatch(object.Pattern, -1) {
+				add(match[2])
+			}
+		case "ipv4-addr", "ipv6-addr", "domain-name":
+			add(object.Value)
+		}
+	}
+
+	return indicators
+}
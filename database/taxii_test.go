@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTAXIIIndicators(t *testing.T) {
+	pages := map[string]taxiiEnvelope{
+		"": {
+			More: true,
+			Next: "page-2",
+			Objects: []stixObject{
+				{Type: "indicator", PatternType: "stix", Pattern: "[ipv4-addr:value = '198.51.100.1']"},
+				{Type: "indicator", PatternType: "stix", Pattern: "[domain-name:value = 'evil.example.com'] OR [ipv6-addr:value = '2001:db8::1']"},
+				// revoked indicators and other pattern languages are skipped
+				{Type: "indicator", PatternType: "stix", Pattern: "[ipv4-addr:value = '198.51.100.2']", Revoked: true},
+				{Type: "indicator", PatternType: "snort", Pattern: "alert tcp any any -> 198.51.100.3 any"},
+				{Type: "malware", Value: "not-an-indicator"},
+			},
+		},
+		"page-2": {
+			More: false,
+			Objects: []stixObject{
+				{Type: "domain-name", Value: "bad.example.org"},
+				{Type: "ipv4-addr", Value: "203.0.113.7"},
+				// duplicates are only returned once
+				{Type: "indicator", Pattern: "[ipv4-addr:value = '198.51.100.1']"},
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/taxii2/", func(w http.ResponseWriter, _ *http.Request) {
+		// the API root is relative to the discovery URL
+		writeTAXIIResponse(t, w, taxiiDiscovery{Default: "/api1/", APIRoots: []string{"/api1/"}})
+	})
+	mux.HandleFunc("/api1/collections/intel/objects/", func(w http.ResponseWriter, r *http.Request) {
+		writeTAXIIResponse(t, w, pages[r.URL.Query().Get("next")])
+	})
+
+	server := httptest.NewServer(requireTAXIIAuth(t, mux))
+	defer server.Close()
+
+	taxii := config.TAXII{
+		DiscoveryURL: server.URL + "/taxii2/",
+		CollectionID: "intel",
+		Username:     "rita",
+		Password:     "secret",
+	}
+
+	indicators, err := getTAXIIIndicators(context.Background(), server.Client(), taxii)
+	require.NoError(t, err)
+	require.Equal(t, []string{"198.51.100.1", "evil.example.com", "2001:db8::1", "bad.example.org", "203.0.113.7"}, indicators)
+
+	// the indicators should be readable as a feed
+	feed, err := getTAXIIFeed(context.Background(), taxii)
+	require.NoError(t, err)
+	contents, err := io.ReadAll(feed)
+	require.NoError(t, err)
+	require.Equal(t, "198.51.100.1\nevil.example.com\n2001:db8::1\nbad.example.org\n203.0.113.7", string(contents))
+
+	// invalid credentials should return an error so that the last loaded indicators are kept
+	taxii.Password = "wrong"
+	_, err = getTAXIIIndicators(context.Background(), server.Client(), taxii)
+	require.ErrorIs(t, err, ErrTAXIIRequestFailed)
+}
+
+func TestGetTAXIIIndicatorsNoAPIRoot(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeTAXIIResponse(t, w, taxiiDiscovery{})
+	}))
+	defer server.Close()
+
+	_, err := getTAXIIIndicators(context.Background(), server.Client(), config.TAXII{DiscoveryURL: server.URL, CollectionID: "intel"})
+	require.ErrorIs(t, err, ErrTAXIINoAPIRoot)
+}
+
+func TestTAXIIFeedPath(t *testing.T) {
+	path := TAXIIFeedPath(config.TAXII{DiscoveryURL: "https://taxii.example.com/taxii2/", CollectionID: "intel"})
+	require.Equal(t, "taxii:https://taxii.example.com/taxii2/#intel", path)
+
+	// the TAXII collection should be included with the other feeds when it is configured
+	cfg := &config.Config{}
+	cfg.ThreatIntel.CustomFeedsDirectory = "/does/not/exist"
+	cfg.ThreatIntel.OnlineFeeds = []string{"https://example.com/feed.txt"}
+	feeds, err := getThreatIntelFeeds(afero.NewMemMapFs(), cfg)
+	require.NoError(t, err)
+	require.Len(t, feeds, 1, "TAXII collection should not be included when it isn't configured")
+
+	cfg.ThreatIntel.TAXII = config.TAXII{DiscoveryURL: "https://taxii.example.com/taxii2/", CollectionID: "intel"}
+	feeds, err = getThreatIntelFeeds(afero.NewMemMapFs(), cfg)
+	require.NoError(t, err)
+	require.Len(t, feeds, 2)
+	require.Equal(t, threatIntelFeed{Online: true, TAXII: true}, feeds[path])
+}
+
+// requireTAXIIAuth wraps a TAXII handler to check the request headers
+func requireTAXIIAuth(t *testing.T, next http.Handler) http.Handler {
+	t.Helper()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, taxiiMediaType, r.Header.Get("Accept"))
+
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "rita" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeTAXIIResponse(t *testing.T, w http.ResponseWriter, body any) {
+	t.Helper()
+	w.Header().Set("Content-Type", taxiiMediaType)
+	require.NoError(t, json.NewEncoder(w).Encode(body))
+}
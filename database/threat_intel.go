@@ -2,7 +2,6 @@ package database
 
 import (
 	"bufio"
-	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -25,6 +24,7 @@ import (
 type threatIntelFeed struct {
 	LastModified time.Time
 	Online       bool
+	TAXII        bool
 	Existing     bool
 }
 
@@ -48,8 +48,8 @@ type threatIntelFeedEntry struct {
 func (server *ServerConn) createThreatIntelTables() error {
 
 	// create table to store threat intel entries
-	err := server.Conn.Exec(server.ctx, `
-		CREATE TABLE IF NOT EXISTS metadatabase.threat_intel (
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.threat_intel (
 		hash FixedString(16),
 		ip IPv6,
 		fqdn String,
@@ -61,8 +61,8 @@ func (server *ServerConn) createThreatIntelTables() error {
 	}
 
 	// create table to store threat intel feeds and their last modified date
-	err = server.Conn.Exec(server.ctx, `
-		CREATE TABLE IF NOT EXISTS metadatabase.threat_intel_feeds(
+	err = server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.threat_intel_feeds(
 		hash FixedString(16),
 		path String,
 		online Bool,
@@ -79,7 +79,8 @@ func (server *ServerConn) createThreatIntelTables() error {
 }
 
 // syncThreatIntelFeedsFromConfig updates the threat intel feeds in the metadatabase based on the config
-func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *config.Config) error {
+// online feeds are downloaded in full if refreshFeeds is set, otherwise they are only downloaded if they have changed
+func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *config.Config, refreshFeeds bool) error {
 	logger := zlog.GetLogger()
 
 	// get the list of threat intel feeds from the config
@@ -88,11 +89,17 @@ func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *conf
 		return err
 	}
 
+	// the cache is kept on the real file system since afs may only hold an in-memory copy of the logs
+	cache, err := newFeedCache(afero.NewOsFs(), http.DefaultClient, cfg.ThreatIntel.FeedCacheDirectory)
+	if err != nil {
+		return err
+	}
+
 	// get list of all feeds from the metadatabase
-	rows, err := server.Conn.Query(server.ctx, `
+	rows, err := server.Conn.Query(server.QueryParameters(clickhouse.Parameters{}), `
 		SELECT hash, path, online, most_recent_last_modified AS last_modified, last_modified_on_disk FROM (
 			SELECT  hash, path, online, max(last_modified) AS most_recent_last_modified, argMax(last_modified_on_disk, last_modified) AS last_modified_on_disk 
-			FROM metadatabase.threat_intel_feeds
+			FROM {metadatabase:Identifier}.threat_intel_feeds
 			GROUP BY hash, path, online
 		)
 	`)
@@ -104,7 +111,7 @@ func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *conf
 	limiter := rate.NewLimiter(5, 5)
 
 	// create a channel to write feed entries to the database
-	writer := NewBulkWriter(server, cfg, 1, "metadatabase", "threat_intel", "INSERT INTO metadatabase.threat_intel", limiter, false)
+	writer := NewBulkWriter(server, cfg, 1, server.metaDatabase, "threat_intel", "INSERT INTO {metadatabase:Identifier}.threat_intel", limiter, false)
 	writer.Start(0)
 
 	// iterate over each existing feed in the database
@@ -141,15 +148,35 @@ func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *conf
 			// skip to next feed
 			continue
 
+		// if feed is a TAXII collection, only poll it once the poll interval has passed
+		case feeds[entry.Path].TAXII:
+			if time.Since(entry.LastModified) < time.Duration(cfg.ThreatIntel.TAXII.PollIntervalMinutes)*time.Minute {
+				continue
+			}
+			logger.Info().Str("feed_url", entry.Path).Msg("[THREAT INTEL] Polling TAXII collection...")
+
+			// keep the indicators from the last successful poll if the collection can't be polled
+			feed, err = getTAXIIFeed(server.GetContext(), cfg.ThreatIntel.TAXII)
+			if err != nil {
+				logger.Warn().Err(err).Str("feed_url", entry.Path).Msg("[THREAT INTEL] Could not poll TAXII collection, keeping previously loaded indicators")
+				continue
+			}
+
 		// if feed has no last modified date on disk, update as online feed
 		case entry.Online:
-			logger.Info().Str("feed_url", entry.Path).Msg("[THREAT INTEL] Updating online feed...")
-
-			// download the feed
-			feed, err = getOnlineFeed(server.GetContext(), entry.Path)
+			// download the feed, keeping the previously loaded entries if it can't be downloaded or hasn't changed
+			var modified bool
+			feed, modified, err = cache.fetch(server.GetContext(), entry.Path, refreshFeeds)
 			if err != nil {
-				return err
+				logger.Warn().Err(err).Str("feed_url", entry.Path).Msg("[THREAT INTEL] Could not download online feed, keeping previously loaded entries")
+				continue
+			}
+			if !modified {
+				feed.Close()
+				logger.Debug().Str("feed_url", entry.Path).Msg("[THREAT INTEL] Online feed has not changed")
+				continue
 			}
+			logger.Info().Str("feed_url", entry.Path).Msg("[THREAT INTEL] Updating online feed...")
 
 		// if feed has has an oudated last modified date, update as custom feed
 		case !entry.LastModifiedOnDisk.Equal(feeds[entry.Path].LastModified):
@@ -177,11 +204,21 @@ func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *conf
 		entry := feeds[path]
 		if !entry.Existing {
 			var feed io.ReadCloser
-			if entry.Online {
-				// download the feed
-				feed, err = getOnlineFeed(server.GetContext(), path)
+			if entry.TAXII {
+				// poll the collection, skipping it until the next import if it can't be polled
+				feed, err = getTAXIIFeed(server.GetContext(), cfg.ThreatIntel.TAXII)
 				if err != nil {
-					return err
+					logger.Warn().Err(err).Str("feed_url", path).Msg("[THREAT INTEL] Could not poll TAXII collection, skipping it for now")
+					continue
+				}
+				logger.Info().Str("feed_url", path).Msg("[THREAT INTEL] Adding new TAXII collection...")
+
+			} else if entry.Online {
+				// download the feed, falling back to the cached copy and skipping it until the next import if neither is available
+				feed, _, err = cache.fetch(server.GetContext(), path, refreshFeeds)
+				if err != nil {
+					logger.Warn().Err(err).Str("feed_url", path).Msg("[THREAT INTEL] Could not download online feed, skipping it for now")
+					continue
 				}
 				logger.Info().Str("feed_url", path).Msg("[THREAT INTEL] Adding new online feed...")
 
@@ -201,8 +238,7 @@ func (server *ServerConn) syncThreatIntelFeedsFromConfig(afs afero.Fs, cfg *conf
 			}
 		}
 	}
-	writer.Close()
-	return nil
+	return writer.Close()
 }
 
 // fs := afero.NewOsFs()
@@ -217,6 +253,14 @@ func getThreatIntelFeeds(afs afero.Fs, cfg *config.Config) (map[string]threatInt
 	// add online feed sources (with last modified time set to zero)
 	getOnlineFeedsList(feeds, cfg.ThreatIntel.OnlineFeeds)
 
+	// add the TAXII collection if one is configured
+	if cfg.ThreatIntel.TAXII.DiscoveryURL != "" {
+		feeds[TAXIIFeedPath(cfg.ThreatIntel.TAXII)] = threatIntelFeed{
+			Online: true,
+			TAXII:  true,
+		}
+	}
+
 	return feeds, nil
 }
 
@@ -271,22 +315,6 @@ func getOnlineFeedsList(feeds map[string]threatIntelFeed, onlineFeedsList []stri
 	}
 }
 
-// getOnlineFeed gets the feed at the specified URL and returns an io.ReadCloser
-func getOnlineFeed(ctx context.Context, url string) (io.ReadCloser, error) {
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	return resp.Body, nil
-}
-
 // getCustomFeed opens the custom feed from the specified path and returns an io.ReadCloser
 func getCustomFeed(path string) (io.ReadCloser, error) {
 	file, err := os.Open(path)
@@ -362,8 +390,8 @@ func (server *ServerConn) removeFeed(hash util.FixedString) error {
 func (server *ServerConn) createFeedRecord(record *threatIntelFeedRecord) error {
 	record.LastModified = time.Now().UTC()
 
-	err := server.Conn.Exec(server.ctx, `
-		INSERT INTO metadatabase.threat_intel_feeds (
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		INSERT INTO {metadatabase:Identifier}.threat_intel_feeds (
 			hash, path, online, last_modified_on_disk, last_modified
 		) VALUES (
 			unhex(?), ?, ?, ?, ?
@@ -375,10 +403,10 @@ func (server *ServerConn) createFeedRecord(record *threatIntelFeedRecord) error
 // removeFeedRecord removes a threat intel feed record from the metadatabase collection for threat intel feeds
 func (server *ServerConn) removeFeedRecord(hash util.FixedString) error {
 	// set context parameters
-	ctx := clickhouse.Context(server.ctx, clickhouse.WithParameters(clickhouse.Parameters{"hash": hash.Hex()}))
+	ctx := server.QueryParameters(clickhouse.Parameters{"hash": hash.Hex()})
 
 	err := server.Conn.Exec(ctx, `
-		DELETE FROM metadatabase.threat_intel_feeds
+		DELETE FROM {metadatabase:Identifier}.threat_intel_feeds
 		WHERE hash = unhex({hash:String})
 	`)
 
@@ -441,10 +469,10 @@ func parseFeedEntries(feedHash util.FixedString, feed io.ReadCloser, writeChan c
 // removeFeedEntries removes entries associated with a threat intel feed from the metadatabase
 func (server *ServerConn) removeFeedEntries(hash util.FixedString) error {
 	// set context parameters
-	ctx := clickhouse.Context(server.ctx, clickhouse.WithParameters(clickhouse.Parameters{"hash": hash.Hex()}))
+	ctx := server.QueryParameters(clickhouse.Parameters{"hash": hash.Hex()})
 
 	err := server.Conn.Exec(ctx, `
-		DELETE FROM metadatabase.threat_intel
+		DELETE FROM {metadatabase:Identifier}.threat_intel
 		WHERE hash = unhex({hash:String})
 	`)
 
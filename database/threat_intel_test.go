@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"io"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,10 +13,15 @@ import (
 
 	"github.com/activecm/rita/v5/util"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
 func TestParseOnlineFeeds(t *testing.T) {
+	// download the feeds without caching them
+	cache, err := newFeedCache(afero.NewMemMapFs(), http.DefaultClient, "")
+	require.NoError(t, err)
+
 	// TEST IP ONLINE FEED
 	t.Run("IP Online Feed", func(t *testing.T) {
 		// should be able to parse Feodo tracker
@@ -34,7 +40,7 @@ func TestParseOnlineFeeds(t *testing.T) {
 		}()
 
 		// get expected total from last line of feed
-		feed, err := getOnlineFeed(context.Background(), "https://feodotracker.abuse.ch/downloads/ipblocklist.txt")
+		feed, _, err := cache.fetch(context.Background(), "https://feodotracker.abuse.ch/downloads/ipblocklist.txt", false)
 		require.NoError(t, err, "getting online feed should not error")
 		reader := bufio.NewReader(feed)
 		for {
@@ -62,7 +68,7 @@ func TestParseOnlineFeeds(t *testing.T) {
 
 		// read feed again
 		url := "https://feodotracker.abuse.ch/downloads/ipblocklist.txt"
-		feed, err = getOnlineFeed(context.Background(), url)
+		feed, _, err = cache.fetch(context.Background(), url, false)
 		require.NoError(t, err, "getting online feed should not produce an error")
 
 		// get hash
@@ -100,7 +106,7 @@ func TestParseOnlineFeeds(t *testing.T) {
 
 		// get feed
 		url := "https://publicsuffix.org/list/public_suffix_list.dat"
-		feed, err := getOnlineFeed(context.Background(), url)
+		feed, _, err := cache.fetch(context.Background(), url, false)
 		require.NoError(t, err, "getting online feed should not error")
 
 		// get hash
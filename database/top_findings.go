@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+const (
+	FindingStrobe      = "strobe"
+	FindingBeacon      = "beacon"
+	FindingThreatIntel = "threat intel"
+)
+
+// TopFinding is one of the highest scoring results of an import that is a strobe, a high beacon, or a threat intel hit
+type TopFinding struct {
+	Types      []string `ch:"types"` // sorted, a result can be a finding of more than one type
+	Src        string   `ch:"src"`
+	Dst        string   `ch:"dst"`
+	FQDN       string   `ch:"fqdn"`
+	Count      uint64   `ch:"count"`
+	TotalScore float32  `ch:"total_score"`
+}
+
+// GetTopFindings returns up to limit of the highest scoring strobes, high beacons, and threat intel hits found by the
+// specified imports, leaving out allowlisted results and results that scored below minScore. Results that belong to
+// more than one of these categories are only returned once, with each of their finding types.
+func (db *DB) GetTopFindings(importIDs []util.FixedString, limit int, minScore float32) ([]TopFinding, error) {
+	if len(importIDs) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	// format array for clickhouse parameters
+	ids := make([]string, 0, len(importIDs))
+	for _, id := range importIDs {
+		ids = append(ids, fmt.Sprintf("'%s'", id.Hex()))
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"import_ids":   "[" + strings.Join(ids, ",") + "]",
+		"high_score":   fmt.Sprintf("%f", config.HIGH_CATEGORY_SCORE),
+		"min_score":    fmt.Sprintf("%f", minScore),
+		"limit":        fmt.Sprintf("%d", limit),
+		"strobe":       FindingStrobe,
+		"beacon":       FindingBeacon,
+		"threat_intel": FindingThreatIntel,
+	})
+
+	// the IPs are scanned as net.IP so that IPv4 addresses aren't printed as IPv4-mapped IPv6 addresses
+	var rows []struct {
+		Types      []string `ch:"types"`
+		Src        net.IP   `ch:"src"`
+		Dst        net.IP   `ch:"dst"`
+		FQDN       string   `ch:"fqdn"`
+		Count      uint64   `ch:"count"`
+		TotalScore float32  `ch:"total_score"`
+	}
+
+	// the results were just written by the imports, so they're read from the primary instead of the replica
+	err := db.Conn.Select(ctx, &rows, `--sql
+		WITH imported AS (
+			SELECT * FROM threat_mixtape
+			WHERE import_id IN (SELECT unhex(arrayJoin({import_ids:Array(String)})))
+		),
+		-- each result is scored by the most recent of these imports that found it, like in the viewer
+		latest AS (
+			SELECT hash, argMax(import_id, last_seen) AS import_id FROM imported GROUP BY hash
+		),
+		findings AS (
+			SELECT hash, {strobe:String} AS finding FROM imported WHERE strobe_score > 0
+			UNION ALL
+			SELECT hash, {beacon:String} AS finding FROM imported WHERE beacon_threat_score >= {high_score:Float32}
+			UNION ALL
+			SELECT hash, {threat_intel:String} AS finding FROM imported WHERE threat_intel_score > 0
+		),
+		scored AS (
+			SELECT hash, src, dst, fqdn,
+				max(count) AS count,
+				max(allowlisted) AS allowlisted,
+				toFloat32(
+					greatest(sum(beacon_threat_score), sum(long_conn_score), sum(strobe_score), sum(c2_over_dns_score), sum(threat_intel_score))
+					+ sum(modifier_score) + sum(prevalence_score) + sum(first_seen_score) + sum(missing_host_header_score)
+					+ sum(threat_intel_data_size_score) + sum(c2_over_dns_direct_conn_score)
+				) AS total_score
+			FROM imported
+			WHERE (hash, import_id) IN (SELECT hash, import_id FROM latest) AND hash IN (SELECT hash FROM findings)
+			GROUP BY hash, src, dst, fqdn
+		)
+		SELECT f.types AS types, s.src AS src, s.dst AS dst, s.fqdn AS fqdn, s.count AS count, s.total_score AS total_score
+		FROM (SELECT hash, arraySort(groupUniqArray(finding)) AS types FROM findings GROUP BY hash) f
+		INNER JOIN scored s ON f.hash = s.hash
+		WHERE NOT s.allowlisted AND s.total_score >= {min_score:Float32}
+		ORDER BY s.total_score DESC
+		LIMIT {limit:UInt32}
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not query top findings: %w", err)
+	}
+
+	findings := make([]TopFinding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, TopFinding{
+			Types: row.Types, Src: row.Src.String(), Dst: row.Dst.String(), FQDN: row.FQDN, Count: row.Count, TotalScore: row.TotalScore,
+		})
+	}
+
+	return findings, nil
+}
@@ -14,7 +14,7 @@ import (
 // FROM system.parts
 // WHERE database='chickenstrip' and table = 'conn'
 
-var LogTableTTLs = []string{"conn", "http", "ssl", "dns", "pdns_raw"}
+var LogTableTTLs = []string{"conn", "http", "ssl", "dns", "pdns_raw", "rdp", "ftp_proto", "x509", "kerberos_proto", "ntlm_proto", "dns_floods"}
 var LogTableViewsHourTTLs = []string{"usni", "udns", "uconn", "mime_type_uris"}
 var LogTableViewsDayTTLs = []string{"pdns"}
 var AnalysisSnapshotHourTTLs = []string{"big_ol_histogram", "tls_proto", "http_proto", "exploded_dns", "rare_signatures", "port_info"}
@@ -60,6 +60,42 @@ func (db *DB) createLogTableTTLs() error {
 		return err
 	}
 
+	err = db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.rdp MODIFY TTL import_time + INTERVAL 26 HOURS`)
+	if err != nil {
+		return err
+	}
+
+	err = db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.ftp_proto MODIFY TTL import_time + INTERVAL 26 HOURS`)
+	if err != nil {
+		return err
+	}
+
+	err = db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.x509 MODIFY TTL import_time + INTERVAL 26 HOURS`)
+	if err != nil {
+		return err
+	}
+
+	err = db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.kerberos_proto MODIFY TTL import_time + INTERVAL 26 HOURS`)
+	if err != nil {
+		return err
+	}
+
+	err = db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.ntlm_proto MODIFY TTL import_time + INTERVAL 26 HOURS`)
+	if err != nil {
+		return err
+	}
+
+	err = db.Conn.Exec(ctx, `--sql
+		ALTER TABLE {database:Identifier}.dns_floods MODIFY TTL import_time + INTERVAL 26 HOURS`)
+	if err != nil {
+		return err
+	}
+
 	// tables populated by materialized views [ TTL on import_hour ]
 	err = db.Conn.Exec(ctx, `--sql
 		ALTER TABLE {database:Identifier}.usni MODIFY TTL import_hour + INTERVAL 26 HOURS`)
@@ -148,25 +184,26 @@ func (db *DB) createSnapshotTableTTLs() error {
 }
 
 func (server *ServerConn) createMetaDatabaseTTLs(monthsToKeepHistoricalFirstSeen int) error {
-	ctx := clickhouse.Context(server.ctx, clickhouse.WithParameters(clickhouse.Parameters{
+	ctx := server.QueryParameters(clickhouse.Parameters{
 		"days": strconv.Itoa(monthsToKeepHistoricalFirstSeen * 30),
-	}))
+	})
 
+	// rows are keyed by network UUID, so each network's first seen dates expire independently
 	err := server.Conn.Exec(ctx, `--sql
-		ALTER TABLE metadatabase.historical_first_seen MODIFY TTL last_seen + toIntervalDay({days:Int32})`)
+		ALTER TABLE {metadatabase:Identifier}.historical_first_seen MODIFY TTL last_seen + toIntervalDay({days:Int32})`)
 	if err != nil {
 		return err
 	}
 
 	err = server.Conn.Exec(ctx, `--sql
-		ALTER TABLE metadatabase.files MODIFY TTL ts + INTERVAL 180 DAYS DELETE WHERE rolling = true`)
+		ALTER TABLE {metadatabase:Identifier}.files MODIFY TTL ts + INTERVAL 180 DAYS DELETE WHERE rolling = true`)
 	if err != nil {
 		return err
 	}
 
 	// DO NOT SET TTL ON ended_at, WILL BREAK
 	err = server.Conn.Exec(ctx, `--sql
-		ALTER TABLE metadatabase.imports MODIFY TTL toDateTime(started_at) + INTERVAL 1 YEAR`)
+		ALTER TABLE {metadatabase:Identifier}.imports MODIFY TTL toDateTime(started_at) + INTERVAL 1 YEAR`)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+/* *** UNFINISHED IMPORTS ***
+An import that was interrupted, or whose analysis failed, never gets a finished record, so the files that it read are
+imported again by the next import. Before that happens, the records that it wrote have to be removed or they would be
+counted twice. The log records of an import can be found by their import time, which is the second that the import
+started, but the hourly aggregate tables that the materialized views fill can't be split by import. Instead, every hour
+that an unfinished import wrote to is deleted from the aggregate tables and rebuilt by inserting the records of the
+finished imports for that hour into their log tables again.
+*/
+
+// replayedLogTables are the log tables whose materialized views fill the hourly aggregate tables
+var replayedLogTables = []string{"conn", "http", "ssl", "dns"}
+
+// hourlyAggregateTables are the tables filled by the materialized views of the replayed log tables, mapped to the
+// hour of their records
+var hourlyAggregateTables = map[string]string{
+	"uconn":            "hour",
+	"usni":             "hour",
+	"tls_proto":        "hour",
+	"http_proto":       "hour",
+	"udns":             "hour",
+	"exploded_dns":     "hour",
+	"mime_type_uris":   "hour",
+	"port_info":        "hour",
+	"big_ol_histogram": "toStartOfHour(bucket)",
+}
+
+// the tables whose records are only deleted, either by their import time or by their import id
+// the aggregates filled from pdns_raw and the rest of the replayed tables' views only keep the min, max, or unique
+// values of their records, so records that are imported twice don't change them
+var (
+	importTimeTables = []string{"pdns_raw", "rdp", "x509", "ftp_proto", "kerberos_proto", "ntlm_proto"}
+	importIDTables   = []string{"threat_mixtape", "dns_floods"}
+)
+
+// unfinishedImport is an import of this dataset that never got a finished record
+type unfinishedImport struct {
+	ImportID  util.FixedString `ch:"import_id"`
+	StartedAt time.Time        `ch:"started_at"`
+}
+
+// RemoveUnfinishedImports deletes the records written by the imports of this dataset that never finished, along with
+// their import and file records in the metadatabase, so that the files that they read are imported as if they never were
+func (db *DB) RemoveUnfinishedImports() error {
+	logger := zlog.GetLogger()
+
+	imports, err := db.getUnfinishedImports()
+	if err != nil || len(imports) == 0 {
+		return err
+	}
+
+	importIDs := make([]string, 0, len(imports))
+	importTimes := make([]string, 0, len(imports))
+	for _, unfinished := range imports {
+		importIDs = append(importIDs, "'"+unfinished.ImportID.Hex()+"'")
+		importTimes = append(importTimes, strconv.FormatInt(unfinished.StartedAt.Unix(), 10))
+	}
+
+	importTimeList := "[" + strings.Join(importTimes, ",") + "]"
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database":     db.selected,
+		"import_ids":   "[" + strings.Join(importIDs, ",") + "]",
+		"import_times": importTimeList,
+	})
+
+	logger.Info().Str("database", db.selected).Int("imports", len(imports)).Msg("removing the records of unfinished imports before their files are imported again")
+
+	// find the hours that the unfinished imports wrote to
+	unfinishedRecords := make([]string, 0, len(replayedLogTables))
+	for _, table := range replayedLogTables {
+		unfinishedRecords = append(unfinishedRecords, "SELECT ts FROM {database:Identifier}."+table+" WHERE toUnixTimestamp(import_time) IN {import_times:Array(UInt32)}")
+	}
+
+	hours, err := db.queryHours(ctx, "SELECT DISTINCT toUnixTimestamp(toStartOfHour(ts)) AS hour FROM ("+strings.Join(unfinishedRecords, " UNION ALL ")+")")
+	if err != nil {
+		return err
+	}
+
+	hours, err = db.removeExpiredHours(hours)
+	if err != nil {
+		return err
+	}
+
+	if len(hours) > 0 {
+		if err := db.rebuildHours(importTimeList, hours); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range importTimeTables {
+		if err := db.Conn.Exec(ctx, "DELETE FROM {database:Identifier}."+table+" WHERE toUnixTimestamp(import_time) IN {import_times:Array(UInt32)}"); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range importIDTables {
+		if err := db.Conn.Exec(ctx, "DELETE FROM {database:Identifier}."+table+" WHERE hex(import_id) IN {import_ids:Array(String)}"); err != nil {
+			return err
+		}
+	}
+
+	// the import and file records are removed last so that the cleanup is retried if any of it fails
+	for _, table := range []string{"files", "imports"} {
+		if err := db.Conn.Exec(ctx, "DELETE FROM {metadatabase:Identifier}."+table+" WHERE database = {database:String} AND hex(import_id) IN {import_ids:Array(String)}"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getUnfinishedImports returns the imports of this dataset that never got a finished record. Imports that started in
+// the same second as a finished import are left out, since their log records can't be told apart by import time.
+func (db *DB) getUnfinishedImports() ([]unfinishedImport, error) {
+	logger := zlog.GetLogger()
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+	})
+
+	var imports []unfinishedImport
+	err := db.Conn.Select(ctx, &imports, `
+		SELECT import_id, toDateTime(min(started_at)) AS started_at FROM {metadatabase:Identifier}.imports
+		WHERE database = {database:String}
+		GROUP BY import_id
+		HAVING max(ended_at) = toDateTime(0)
+	`)
+	if err != nil || len(imports) == 0 {
+		return nil, err
+	}
+
+	var finishedSeconds []struct {
+		StartedAt time.Time `ch:"started_at"`
+	}
+	err = db.Conn.Select(ctx, &finishedSeconds, `
+		SELECT DISTINCT toDateTime(started_at) AS started_at FROM {metadatabase:Identifier}.imports
+		WHERE database = {database:String} AND ended_at > toDateTime(0)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var separable []unfinishedImport
+	for _, unfinished := range imports {
+		shared := false
+		for _, second := range finishedSeconds {
+			if second.StartedAt.Equal(unfinished.StartedAt) {
+				shared = true
+				break
+			}
+		}
+		if shared {
+			logger.Warn().Str("database", db.selected).Str("import_id", unfinished.ImportID.Hex()).Time("started_at", unfinished.StartedAt).Msg("unfinished import started in the same second as a finished import, its records can't be removed before its files are imported again")
+			continue
+		}
+		separable = append(separable, unfinished)
+	}
+
+	return separable, nil
+}
+
+// removeExpiredHours returns the given hours without the ones that can't be rebuilt from the stored log records.
+// The log tables of rolling datasets expire their records sooner than the snapshot tables, so an hour can't be
+// rebuilt once a snapshot table holds records for it from an import whose log records have expired.
+func (db *DB) removeExpiredHours(hours []uint32) ([]uint32, error) {
+	logger := zlog.GetLogger()
+
+	if !db.Rolling || len(hours) == 0 {
+		return hours, nil
+	}
+
+	hourList := make([]string, 0, len(hours))
+	for _, hour := range hours {
+		hourList = append(hourList, strconv.FormatUint(uint64(hour), 10))
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database": db.selected,
+		"hours":    "[" + strings.Join(hourList, ",") + "]",
+	})
+
+	var snapshotRecords []string
+	for _, table := range AnalysisSnapshotHourTTLs {
+		if hour, ok := hourlyAggregateTables[table]; ok {
+			snapshotRecords = append(snapshotRecords, "SELECT "+hour+" AS hour, import_hour FROM {database:Identifier}."+table)
+		}
+	}
+
+	// log records expire 26 hours after their import, see createLogTableTTLs
+	expired, err := db.queryHours(ctx, `
+		SELECT DISTINCT toUnixTimestamp(hour) AS hour FROM (`+strings.Join(snapshotRecords, " UNION ALL ")+`)
+		WHERE toUnixTimestamp(hour) IN {hours:Array(UInt32)} AND import_hour < now() - INTERVAL 26 HOUR
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []uint32
+	for _, hour := range hours {
+		if slices.Contains(expired, hour) {
+			logger.Warn().Str("database", db.selected).Time("hour", time.Unix(int64(hour), 0).UTC()).Msg("some log records of this hour have expired, so it can't be rebuilt and the records that the unfinished imports wrote to it are kept")
+			continue
+		}
+		kept = append(kept, hour)
+	}
+
+	return kept, nil
+}
+
+// queryHours returns the hours selected by query, as Unix timestamps in a column named hour
+func (db *DB) queryHours(ctx context.Context, query string) ([]uint32, error) {
+	var results []struct {
+		Hour uint32 `ch:"hour"`
+	}
+	if err := db.Conn.Select(ctx, &results, query); err != nil {
+		return nil, err
+	}
+
+	hours := make([]uint32, 0, len(results))
+	for _, result := range results {
+		hours = append(hours, result.Hour)
+	}
+	return hours, nil
+}
+
+// rebuildHours deletes the given hours from the replayed log tables and the hourly aggregate tables, then inserts
+// the log records of those hours that weren't written by an unfinished import again, which refills the aggregate
+// tables through their materialized views. The kept records are copied to a scratch table for each log table first.
+func (db *DB) rebuildHours(importTimes string, hours []uint32) error {
+	hourList := make([]string, 0, len(hours))
+	for _, hour := range hours {
+		hourList = append(hourList, strconv.FormatUint(uint64(hour), 10))
+	}
+
+	ctx := db.QueryParameters(clickhouse.Parameters{
+		"database":     db.selected,
+		"import_times": importTimes,
+		"hours":        "[" + strings.Join(hourList, ",") + "]",
+	})
+
+	inHours := "toUnixTimestamp(toStartOfHour(ts)) IN {hours:Array(UInt32)}"
+
+	for _, table := range replayedLogTables {
+		replay := table + "_replay_tmp"
+		if err := db.Conn.Exec(ctx, "DROP TABLE IF EXISTS {database:Identifier}."+replay); err != nil {
+			return err
+		}
+		if err := db.Conn.Exec(ctx, "CREATE TABLE {database:Identifier}."+replay+" AS {database:Identifier}."+table+" ENGINE = MergeTree() ORDER BY tuple()"); err != nil {
+			return err
+		}
+		if err := db.Conn.Exec(ctx, "INSERT INTO {database:Identifier}."+replay+" SELECT * FROM {database:Identifier}."+table+
+			" WHERE "+inHours+" AND toUnixTimestamp(import_time) NOT IN {import_times:Array(UInt32)}"); err != nil {
+			return err
+		}
+	}
+
+	for table, hour := range hourlyAggregateTables {
+		if err := db.Conn.Exec(ctx, "DELETE FROM {database:Identifier}."+table+" WHERE toUnixTimestamp("+hour+") IN {hours:Array(UInt32)}"); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range replayedLogTables {
+		replay := table + "_replay_tmp"
+		if err := db.Conn.Exec(ctx, "DELETE FROM {database:Identifier}."+table+" WHERE "+inHours); err != nil {
+			return err
+		}
+		if err := db.Conn.Exec(ctx, "INSERT INTO {database:Identifier}."+table+" SELECT * FROM {database:Identifier}."+replay); err != nil {
+			return err
+		}
+		if err := db.Conn.Exec(ctx, "DROP TABLE IF EXISTS {database:Identifier}."+replay); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
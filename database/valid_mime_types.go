@@ -10,6 +10,7 @@ import (
 	"github.com/activecm/rita/v5/config"
 	"github.com/activecm/rita/v5/util"
 
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
 	"golang.org/x/time/rate"
 )
 
@@ -20,14 +21,14 @@ type ValidMIMEType struct {
 
 // createValidMIMETypeTable creates a table that stores MIME types beginning with "text" (text/css) and their associated extensions
 func (server *ServerConn) createValidMIMETypeTable() error {
-	err := server.Conn.Exec(server.ctx, `
-		DROP TABLE IF EXISTS metadatabase.valid_mime_types
+	err := server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		DROP TABLE IF EXISTS {metadatabase:Identifier}.valid_mime_types
 	`)
 	if err != nil {
 		return err
 	}
-	err = server.Conn.Exec(server.ctx, `
-		CREATE TABLE IF NOT EXISTS metadatabase.valid_mime_types (
+	err = server.Conn.Exec(server.QueryParameters(clickhouse.Parameters{}), `
+		CREATE TABLE IF NOT EXISTS {metadatabase:Identifier}.valid_mime_types (
 			mime_type String,
 			extension String
 		) ENGINE = MergeTree()
@@ -43,7 +44,7 @@ func (server *ServerConn) importValidMIMETypes(cfg *config.Config) error {
 	limiter := rate.NewLimiter(5, 5)
 
 	// create a channel to write mime type entries to the database
-	writer := NewBulkWriter(server, cfg, 1, "metadatabase", "valid_mime_types", "INSERT INTO metadatabase.valid_mime_types", limiter, false)
+	writer := NewBulkWriter(server, cfg, 1, server.metaDatabase, "valid_mime_types", "INSERT INTO {metadatabase:Identifier}.valid_mime_types", limiter, false)
 	writer.Start(0)
 
 	extFile, err := util.ParseRelativePath(cfg.HTTPExtensionsFilePath)
@@ -54,9 +55,7 @@ func (server *ServerConn) importValidMIMETypes(cfg *config.Config) error {
 		return err
 	}
 
-	writer.Close()
-
-	return nil
+	return writer.Close()
 }
 
 func readValidTextMIMETypeFile(filePath string, writeChan chan Data) error {
@@ -2,7 +2,14 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/activecm/rita/v5/config"
 	zlog "github.com/activecm/rita/v5/logger"
@@ -10,9 +17,33 @@ import (
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 	"golang.org/x/time/rate"
 )
 
+var ErrInsertRetriesExhausted = errors.New("could not insert batch into the database")
+
+// maxInsertBackoff is the longest time to wait before retrying a batch
+const maxInsertBackoff = time.Minute
+
+// retryableInsertCodes are the ClickHouse error codes of transient conditions that clear up on their own
+var retryableInsertCodes = []int32{
+	159, // TIMEOUT_EXCEEDED
+	202, // TOO_MANY_SIMULTANEOUS_QUERIES
+	209, // SOCKET_TIMEOUT
+	210, // NETWORK_ERROR
+	241, // MEMORY_LIMIT_EXCEEDED
+	242, // TABLE_IS_READ_ONLY
+	252, // TOO_MANY_PARTS
+	999, // KEEPER_EXCEPTION
+}
+
+var (
+	insertSlotsMu sync.Mutex
+	// insertSlots holds the semaphores that limit the number of batches in flight, by limit
+	insertSlots = make(map[int]*semaphore.Weighted)
+)
+
 type (
 	Data any
 
@@ -36,32 +67,30 @@ type (
 		withProgress bool
 		database     string
 		closed       bool
-		ctx          context.Context
 		numWorkers   int
 		batches      []int
 		mu           sync.Mutex
 		cond         *sync.Cond
+		err          error // the first error that a worker encountered, guarded by mu
 	}
 )
 
 // NewBulkWriter creates a new writer object to write output data to collections
 func NewBulkWriter(db Database, conf *config.Config, numWorkers int, database string, writerName string, query string, limiter *rate.Limiter, withProgress bool) *BulkWriter {
 
-	analysisErrGroup, ctx := errgroup.WithContext(context.Background())
 	writer := &BulkWriter{
 		db:           db,
 		conf:         conf,
 		database:     database,
 		WriteChannel: make(chan Data),
 		ProgChannel:  make(chan int),
-		WriteWg:      analysisErrGroup,
+		WriteWg:      &errgroup.Group{},
 		writerName:   writerName,
 		batchSize:    conf.BatchSize,
 		query:        query,
 		limiter:      limiter,
 		withProgress: withProgress,
 		numWorkers:   numWorkers,
-		ctx:          ctx,
 		batches:      make([]int, numWorkers), // keeps track of the batch count for each worker
 	}
 	writer.cond = sync.NewCond(&writer.mu)
@@ -99,21 +128,42 @@ func (w *BulkWriter) shouldReadData(id int, empty bool) bool {
 	return numInProgress == 0 || w.batches[id] > 0
 }
 
-// Close waits for the write threads to finish
-func (w *BulkWriter) Close() {
-	logger := zlog.GetLogger()
+// Close waits for the write threads to finish and returns the first error that any of them encountered
+func (w *BulkWriter) Close() error {
 	// tell workers that no more data will be sent on this channel
 	close(w.WriteChannel)
 	// mark the channel as closed
+	w.mu.Lock()
 	w.closed = true
+	w.mu.Unlock()
 	// notify workers that the channel is closed
 	w.cond.Broadcast()
 	// wait for the errgroup
-	if err := w.WriteWg.Wait(); err != nil {
-		logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "close_writer").Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
-	}
+	err := w.WriteWg.Wait()
 
 	close(w.ProgChannel)
+
+	if err != nil {
+		return fmt.Errorf("could not write to %s: %w", w.writerName, err)
+	}
+	return nil
+}
+
+// fail records the first error that a worker encountered. Once a worker fails, the rest of the data sent to the writer
+// is discarded instead of being written, so that whatever is sending it isn't blocked before it can close the writer.
+func (w *BulkWriter) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// failed returns whether any worker has encountered an error
+func (w *BulkWriter) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
 }
 
 // Start kicks off a new write thread
@@ -142,13 +192,6 @@ func (w *BulkWriter) Start(id int) {
 				w.cond.Wait()
 			}
 
-			// check if any other workers errored out and made the context finish
-			select {
-			case <-w.ctx.Done():
-				return w.ctx.Err()
-			default:
-			}
-
 			// attempt to read data from the channel
 			change, ok := <-w.WriteChannel
 
@@ -172,38 +215,24 @@ func (w *BulkWriter) Start(id int) {
 				// a free worker can be allowed to start making a new batch
 				w.cond.Broadcast()
 
-				// initialize batch
-				batch, err := conn.PrepareBatch(chCtx, w.query)
-				if err != nil {
-					logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "prepare").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
-				}
-
-				// add each item in batch to this batch
-				for _, item := range items {
-					err := batch.AppendStruct(item)
-					if err != nil {
-						logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "append").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
+				// the batch is dropped if any worker failed, since the import or analysis is failing anyway
+				if !w.failed() {
+					// wait for the rate limiter so that not too many batches are inserted at a time
+					// ClickHouse recommends to send 1 batch per second, but it appears to work just fine for 5 batches per second
+					if err := w.limiter.Wait(w.db.GetContext()); err != nil {
+						logger.Error().Err(err).Str("database", w.writerName).Str("stage", "limiter").Int("batch_size", batchCount).Msg("Encountered an unrecoverable issue when trying to write to the database")
+						w.fail(err)
+					} else if err := w.insertBatch(conn, chCtx, items); err != nil {
+						// send batch
+						logger.Error().Err(err).Str("database", w.writerName).Str("stage", "insert").Int("batch_size", batchCount).Msg("Encountered an unrecoverable issue when trying to write to the database")
+						w.fail(err)
+					} else if w.withProgress {
+						// if progress updates are enabled, send the number of records
+						// this batch handled on the progress channel
+						w.ProgChannel <- batchCount
 					}
 				}
 
-				// wait for the rate limiter so that not too many batches are inserted at a time
-				// ClickHouse recommends to send 1 batch per second, but it appears to work just fine for 5 batches per second
-				if err := w.limiter.Wait(w.db.GetContext()); err != nil {
-					logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "limiter").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
-				}
-
-				// send batch
-				err = batch.Send()
-				if err != nil {
-					logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "send").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
-				}
-
-				// if progress updates are enabled, send the number of records
-				// this batch handled on the progress channel
-				if w.withProgress {
-					w.ProgChannel <- batchCount
-				}
-
 				// update worker state batch count and alert other workers that this
 				// worker is empty
 				w.mu.Lock()
@@ -217,28 +246,110 @@ func (w *BulkWriter) Start(id int) {
 		}
 
 		// handle batch when number of items is less than the batch size
-		if batchCount > 0 {
-			batch, err := conn.PrepareBatch(chCtx, w.query)
-			if err != nil {
-				logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "final_prepare").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
+		if batchCount > 0 && !w.failed() {
+			if err := w.insertBatch(conn, chCtx, items); err != nil {
+				logger.Error().Err(err).Str("database", w.writerName).Str("stage", "final_insert").Int("batch_size", batchCount).Msg("Encountered an unrecoverable issue when trying to write to the database")
+				w.fail(err)
+			} else if w.withProgress {
+				w.ProgChannel <- batchCount
 			}
+		}
 
-			for _, item := range items {
-				err := batch.AppendStruct(item)
-				if err != nil {
-					logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "final_append").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
-				}
-			}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.err
+	})
+}
 
-			err = batch.Send()
-			if err != nil {
-				logger.Fatal().Err(err).Str("database", w.writerName).Str("stage", "final_send").Int("batch_size", w.batches[id]).Msg("Encountered an unrecoverable issue when trying to write to the database, exiting")
-			}
+// insertBatch inserts the items as a single batch. A batch can't be sent again once sending it failed, so each attempt
+// prepares a new batch. Transient errors are retried with backoff, and the number of batches that are inserted at the
+// same time across all writers is limited to the configured number of in flight batches.
+func (w *BulkWriter) insertBatch(conn driver.Conn, chCtx context.Context, items []Data) error {
+	slots := getInsertSlots(w.conf.MaxInFlightBatches)
+	ctx := w.db.GetContext()
 
-			if w.withProgress {
-				w.ProgChannel <- batchCount
+	return w.retryInsert(ctx, func() error {
+		if err := slots.Acquire(ctx, 1); err != nil {
+			return err
+		}
+		defer slots.Release(1)
+
+		batch, err := conn.PrepareBatch(chCtx, w.query)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			if err := batch.AppendStruct(item); err != nil {
+				_ = batch.Abort()
+				return fmt.Errorf("could not append item to batch: %w", err)
 			}
 		}
-		return nil
+
+		return batch.Send()
 	})
 }
+
+// retryInsert calls insert until it succeeds, it returns an error that isn't retryable, or it has been retried
+// insert_max_retries times, doubling the wait before each retry
+func (w *BulkWriter) retryInsert(ctx context.Context, insert func() error) error {
+	logger := zlog.GetLogger()
+
+	backoff := time.Duration(w.conf.InsertRetryBackoffSeconds) * time.Second
+	for retry := 0; ; retry++ {
+		err := insert()
+		if err == nil || !isRetryableInsertError(err) {
+			return err
+		}
+		if retry >= w.conf.InsertMaxRetries {
+			return fmt.Errorf("%w after %d attempts: %w", ErrInsertRetriesExhausted, retry+1, err)
+		}
+
+		wait := getInsertBackoff(backoff, retry)
+		logger.Warn().Err(err).Str("database", w.writerName).Int("attempt", retry+1).Str("retry_in", wait.String()).Msg("Transient error when inserting batch, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// getInsertBackoff returns how long to wait before the given retry, doubling the backoff for each retry up to maxInsertBackoff
+func getInsertBackoff(backoff time.Duration, retry int) time.Duration {
+	for i := 0; i < retry && backoff < maxInsertBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxInsertBackoff)
+}
+
+// isRetryableInsertError returns whether an insert that failed with err may succeed if it is tried again,
+// such as when ClickHouse is behind on merging parts or the connection was dropped
+func isRetryableInsertError(err error) bool {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return slices.Contains(retryableInsertCodes, exception.Code)
+	}
+
+	// connection problems are retried since each attempt acquires a connection from the pool
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, clickhouse.ErrAcquireConnTimeout)
+}
+
+// getInsertSlots returns the semaphore that limits the number of batches inserted at the same time. Writers that are
+// configured with the same limit share a semaphore so that the limit applies across all of them.
+func getInsertSlots(size int) *semaphore.Weighted {
+	insertSlotsMu.Lock()
+	defer insertSlotsMu.Unlock()
+
+	size = max(size, 1)
+	slots, ok := insertSlots[size]
+	if !ok {
+		slots = semaphore.NewWeighted(int64(size))
+		insertSlots[size] = slots
+	}
+	return slots
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestIsRetryableInsertError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Too Many Parts", err: &clickhouse.Exception{Code: 252, Name: "TOO_MANY_PARTS"}, expected: true},
+		{name: "Memory Limit Exceeded", err: &clickhouse.Exception{Code: 241, Name: "MEMORY_LIMIT_EXCEEDED"}, expected: true},
+		{name: "Wrapped Exception", err: fmt.Errorf("send: %w", &clickhouse.Exception{Code: 252}), expected: true},
+		{name: "Connection Reset", err: fmt.Errorf("write: %w", syscall.ECONNRESET), expected: true},
+		{name: "Unexpected EOF", err: io.ErrUnexpectedEOF, expected: true},
+		{name: "Acquire Conn Timeout", err: clickhouse.ErrAcquireConnTimeout, expected: true},
+		{name: "Unknown Table", err: &clickhouse.Exception{Code: 60, Name: "UNKNOWN_TABLE"}, expected: false},
+		{name: "Type Mismatch", err: &clickhouse.Exception{Code: 53, Name: "TYPE_MISMATCH"}, expected: false},
+		{name: "Append Error", err: errors.New("could not append item to batch: converting string to IPv6 is unsupported"), expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, isRetryableInsertError(test.err))
+		})
+	}
+}
+
+func TestGetInsertBackoff(t *testing.T) {
+	require.Equal(t, time.Second, getInsertBackoff(time.Second, 0), "the first retry should wait for the backoff")
+	require.Equal(t, 2*time.Second, getInsertBackoff(time.Second, 1))
+	require.Equal(t, 16*time.Second, getInsertBackoff(time.Second, 4))
+	require.Equal(t, maxInsertBackoff, getInsertBackoff(time.Second, 10), "the backoff should be capped")
+	require.Equal(t, maxInsertBackoff, getInsertBackoff(time.Second, 1000), "the backoff should not overflow")
+}
+
+func TestRetryInsert(t *testing.T) {
+	writer := &BulkWriter{writerName: "test", conf: &config.Config{InsertMaxRetries: 2}}
+	tooManyParts := &clickhouse.Exception{Code: 252, Name: "TOO_MANY_PARTS"}
+
+	t.Run("Recovers From Transient Error", func(t *testing.T) {
+		attempts := 0
+		err := writer.retryInsert(context.Background(), func() error {
+			attempts++
+			if attempts < 3 {
+				return tooManyParts
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("Gives Up After Max Retries", func(t *testing.T) {
+		attempts := 0
+		err := writer.retryInsert(context.Background(), func() error {
+			attempts++
+			return tooManyParts
+		})
+		require.ErrorIs(t, err, ErrInsertRetriesExhausted)
+		require.ErrorIs(t, err, tooManyParts, "the last error should be wrapped")
+		require.ErrorContains(t, err, "after 3 attempts")
+		require.Equal(t, 3, attempts, "the insert should be tried once and retried twice")
+	})
+
+	t.Run("Fatal Error Is Not Retried", func(t *testing.T) {
+		fatal := &clickhouse.Exception{Code: 60, Name: "UNKNOWN_TABLE"}
+		attempts := 0
+		err := writer.retryInsert(context.Background(), func() error {
+			attempts++
+			return fatal
+		})
+		require.ErrorIs(t, err, fatal)
+		require.NotErrorIs(t, err, ErrInsertRetriesExhausted)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("Stops When Cancelled", func(t *testing.T) {
+		slow := &BulkWriter{writerName: "test", conf: &config.Config{InsertMaxRetries: 5, InsertRetryBackoffSeconds: 60}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := slow.retryInsert(ctx, func() error { return tooManyParts })
+		require.ErrorIs(t, err, context.Canceled, "a cancelled import should not wait for the backoff")
+	})
+}
+
+func TestGetInsertSlots(t *testing.T) {
+	slots := getInsertSlots(2)
+	require.Same(t, slots, getInsertSlots(2), "writers with the same limit should share the slots")
+	require.NotSame(t, slots, getInsertSlots(3))
+
+	require.True(t, slots.TryAcquire(2))
+	require.False(t, slots.TryAcquire(1), "no more than the limit should be in flight")
+	slots.Release(2)
+}
+
+// failingDatabase is a database whose batches can't be prepared
+type failingDatabase struct {
+	driver.Conn
+	err error
+}
+
+func (db *failingDatabase) getConn() driver.Conn        { return db }
+func (db *failingDatabase) GetContext() context.Context { return context.Background() }
+func (db *failingDatabase) QueryParameters(clickhouse.Parameters) context.Context {
+	return context.Background()
+}
+func (db *failingDatabase) PrepareBatch(context.Context, string, ...driver.PrepareBatchOption) (driver.Batch, error) {
+	return nil, db.err
+}
+
+func TestBulkWriterReturnsInsertErrors(t *testing.T) {
+	unknownTable := &clickhouse.Exception{Code: 60, Name: "UNKNOWN_TABLE"}
+	cfg := &config.Config{BatchSize: 2, MaxInFlightBatches: 1}
+	writer := NewBulkWriter(&failingDatabase{err: unknownTable}, cfg, 2, "test", "test_table", "INSERT INTO test_table", rate.NewLimiter(rate.Inf, 1), false)
+	for i := 0; i < 2; i++ {
+		writer.Start(i)
+	}
+
+	// the writer keeps reading after the first batch fails, so that sending the rest of the data doesn't block
+	for i := 0; i < 9; i++ {
+		writer.WriteChannel <- i
+	}
+
+	err := writer.Close()
+	require.ErrorIs(t, err, unknownTable, "the insert error should be returned when the writer is closed")
+	require.ErrorContains(t, err, "test_table")
+}
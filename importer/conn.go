@@ -3,6 +3,8 @@ package importer
 import (
 	"errors"
 	"net"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -51,6 +53,11 @@ type ConnEntry struct {
 	ConnState            string           `ch:"conn_state"`
 	MissedBytes          int64            `ch:"missed_bytes"`
 	ZeekHistory          string           `ch:"zeek_history"`
+	Sensor               string           `ch:"sensor"`            // name of the sensor subdirectory the log was imported from, if any
+	BeaconExcluded       bool             `ch:"beacon_excluded"`   // transferred fewer bytes than min_connection_bytes, so it is left out of beaconing
+	MissingDstBytes      bool             `ch:"missing_dst_bytes"` // resp_ip_bytes was unset in the log, so dst_ip_bytes is 0
+	DatasizeExcluded     bool             `ch:"datasize_excluded"` // left out of the datasize score because its byte counts are missing
+	CommunityID          string           `ch:"community_id"`      // community id flow hash, if the sensor logged one
 }
 
 type UniqueConn struct {
@@ -82,13 +89,44 @@ type ZeekUIDRecord struct {
 	Service           string
 }
 
+// uidSet keeps track of the zeek UIDs seen during an import so that connections logged more than once can be skipped
+type uidSet struct {
+	mu   sync.Mutex
+	uids map[string]struct{}
+}
+
+func newUIDSet() *uidSet {
+	return &uidSet{uids: make(map[string]struct{})}
+}
+
+// add records the uid and returns false if it was already in the set
+func (s *uidSet) add(uid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.uids[uid]; ok {
+		return false
+	}
+	s.uids[uid] = struct{}{}
+	return true
+}
+
 // parseConn listens on a channel of raw conn/openconn log records, formats them and sends them to be written to the database
-func parseConn(cfg *config.Config, conn <-chan zeektypes.Conn, output chan<- database.Data, importID util.FixedString, importTime time.Time, numConns *uint64) {
+// if seenUIDs is not nil, records with a zeek uid that was already parsed are skipped and counted in numDuplicates
+// open is set when parsing open_conn records, which are never held to min_connection_bytes since they haven't finished
+// connections from a host to itself are filtered out if filter_self_connections is set and counted in numSelfConns
+func parseConn(cfg *config.Config, conn <-chan zeektypes.Conn, output chan<- database.Data, importID util.FixedString, importTime time.Time, logDir string, open bool, seenUIDs *uidSet, numConns *uint64, numDuplicates *uint64, numSelfConns *uint64) {
 	logger := zlog.GetLogger()
 
 	// loop over raw conn/openconn channel
 	for c := range conn {
 
+		// skip connections that were already logged by another zeek worker
+		if seenUIDs != nil && c.UID != "" && !seenUIDs.add(c.UID) {
+			atomic.AddUint64(numDuplicates, 1)
+			continue
+		}
+
 		// parse raw record as a conn/openconn entry
 		entry, err := formatConnRecord(cfg, &c, importID, importTime)
 		if err != nil {
@@ -108,6 +146,25 @@ func parseConn(cfg *config.Config, conn <-chan zeektypes.Conn, output chan<- dat
 			continue
 		}
 
+		// record which sensor this connection was seen by so that linked logs can inherit it
+		entry.Sensor = ParseSensor(logDir, c.LogPath)
+
+		// a host talking to itself, like proxy loopback, can't be a beacon, even if it is on the always included list;
+		// the entry is still written so that other logs can link to it by zeek uid
+		if cfg.Filter.FilterSelfConnection(entry.Src, entry.Dst) {
+			entry.Filtered = true
+			entry.BeaconExcluded = true
+			atomic.AddUint64(numSelfConns, 1)
+		}
+
+		// keep connections that barely transferred any data, like scans and health checks, out of beaconing
+		if !open && cfg.Filter.BelowMinConnectionBytes(entry.SrcBytes, entry.DstBytes) {
+			entry.BeaconExcluded = true
+			if !cfg.Filter.CountLowByteConnectionsForStrobe {
+				entry.Filtered = true
+			}
+		}
+
 		output <- entry // send to log writer
 		if !entry.Filtered {
 			atomic.AddUint64(numConns, 1) // increment record counter
@@ -143,7 +200,7 @@ func formatConnRecord(cfg *config.Config, parseConn *zeektypes.Conn, importID ut
 	srcNUID := util.ParseNetworkID(srcIP, parseConn.AgentUUID)
 	dstNUID := util.ParseNetworkID(dstIP, parseConn.AgentUUID)
 
-	hash, err := util.NewFixedStringHash(srcIP.To16().String() + srcNUID.String() + dstIP.To16().String() + dstNUID.String())
+	hash, err := util.NewBeaconHash("ip", util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID})
 	if err != nil {
 		return nil, err
 	}
@@ -153,14 +210,15 @@ func formatConnRecord(cfg *config.Config, parseConn *zeektypes.Conn, importID ut
 		return nil, err
 	}
 
-	filtered := cfg.Filter.FilterConnPair(srcIP, dstIP)
+	filtered := cfg.Filter.FilterConnPair(srcIP, dstIP) || cfg.Filter.FilterPort(uint16(parseConn.DestinationPort), parseConn.Proto) ||
+		cfg.Filter.FilterProto(parseConn.Proto)
 
 	entry := &ConnEntry{
 		ImportTime:  importTime,
 		ZeekUID:     zeekUID,
 		Filtered:    filtered,
 		Hash:        hash,
-		Timestamp:   time.Unix(int64(parseConn.TimeStamp), 0),
+		Timestamp:   time.Unix(int64(parseConn.TimeStamp), 0), // when the connection started, even for open conns, since beaconing uses it
 		ImportID:    importID,
 		Src:         srcIP,
 		Dst:         dstIP,
@@ -180,10 +238,19 @@ func formatConnRecord(cfg *config.Config, parseConn *zeektypes.Conn, importID ut
 		SrcBytes:    parseConn.OrigBytes,
 		DstBytes:    parseConn.RespBytes,
 		SrcIPBytes:  parseConn.OrigIPBytes,
-		DstIPBytes:  parseConn.RespIPBytes,
 		SrcPackets:  parseConn.OrigPackets,
 		DstPackets:  parseConn.RespPackets,
 		ConnState:   parseConn.ConnState,
+		CommunityID: parseConn.CommunityID,
+	}
+
+	// an unset resp_ip_bytes field is different from a connection that received nothing, so it is tracked
+	// separately and can be left out of the datasize score
+	if parseConn.RespIPBytes != nil {
+		entry.DstIPBytes = *parseConn.RespIPBytes
+	} else {
+		entry.MissingDstBytes = true
+		entry.DatasizeExcluded = cfg.Scoring.Beacon.ExcludeMissingRespBytes
 	}
 
 	// conn is treated differently than the rest of the logs since some other logs might need to correlate
@@ -201,6 +268,8 @@ func formatConnRecord(cfg *config.Config, parseConn *zeektypes.Conn, importID ut
 }
 
 // writeUnfilteredConns copies connections from conn_tmp to conn that were not marked as being filtered
+// connections to the IPs that internal domains resolved to are classified as internal and filtered like the queries for
+// those domains
 func (importer *Importer) writeUnfilteredConns(progress *tea.Program, open bool, spinnerID int) error {
 
 	tmpTable := "conn_tmp"
@@ -209,25 +278,79 @@ func (importer *Importer) writeUnfilteredConns(progress *tea.Program, open bool,
 		tmpTable = "openconn_tmp"
 		table = "openconn"
 	}
+
+	internalIPs, filteredSrcs, err := importer.getInternalDomainIPFilter(tmpTable)
+	if err != nil {
+		return err
+	}
+
 	chCtx := importer.Database.QueryParameters(clickhouse.Parameters{
-		"tmp_table": tmpTable,
-		"table":     table,
+		"tmp_table":     tmpTable,
+		"table":         table,
+		"internal_ips":  internalIPs,
+		"filtered_srcs": filteredSrcs,
 	})
 
-	err := importer.Database.Conn.Exec(chCtx, `
+	err = importer.Database.Conn.Exec(chCtx, `
 		INSERT INTO {table:Identifier} (
 			import_time, import_id, zeek_uid, hash, ts, src, dst, src_nuid, dst_nuid,
 			src_port, dst_port, missing_host_header, missing_host_useragent, proto, service,
 			conn_state, duration, src_local, dst_local, icmp_type, icmp_code, src_bytes, dst_bytes,
-			src_ip_bytes, dst_ip_bytes, src_packets, dst_packets, missed_bytes, zeek_history
-		) SELECT import_time, import_id, zeek_uid, hash, ts, src, dst, src_nuid, dst_nuid,
+			src_ip_bytes, dst_ip_bytes, src_packets, dst_packets, missed_bytes, zeek_history, sensor, beacon_excluded,
+			missing_dst_bytes, datasize_excluded, community_id
+		)
+		SELECT import_time, import_id, zeek_uid, hash, ts, src, dst, src_nuid, dst_nuid,
 			src_port, dst_port, missing_host_header, missing_host_useragent, proto, service,
-			conn_state, duration, src_local, dst_local, icmp_type, icmp_code, src_bytes, dst_bytes,
-			src_ip_bytes, dst_ip_bytes, src_packets, dst_packets, missed_bytes, zeek_history
+			conn_state, duration, src_local, dst_local OR dst IN (SELECT toIPv6(arrayJoin({internal_ips:Array(String)}))), icmp_type, icmp_code, src_bytes, dst_bytes,
+			src_ip_bytes, dst_ip_bytes, src_packets, dst_packets, missed_bytes, zeek_history, sensor, beacon_excluded,
+			missing_dst_bytes, datasize_excluded, community_id
 		FROM {tmp_table:Identifier}
 		WHERE filtered = false
+		AND NOT (dst IN (SELECT toIPv6(arrayJoin({internal_ips:Array(String)}))) AND src IN (SELECT toIPv6(arrayJoin({filtered_srcs:Array(String)}))))
 	`)
 
 	progress.Send(progressbar.ProgressSpinnerMsg(spinnerID))
 	return err
 }
+
+// getInternalDomainIPFilter returns the IPs that internal domains resolved to during the import and the sources whose
+// connections to them are filtered out, formatted as query parameters
+func (importer *Importer) getInternalDomainIPFilter(tmpTable string) (string, string, error) {
+	if importer.internalIPs == nil {
+		return "[]", "[]", nil
+	}
+
+	ips := importer.internalIPs.list()
+	if len(ips) == 0 {
+		return "[]", "[]", nil
+	}
+	internalIPs := "['" + strings.Join(ips, "','") + "']"
+
+	chCtx := importer.Database.QueryParameters(clickhouse.Parameters{
+		"tmp_table":    tmpTable,
+		"internal_ips": internalIPs,
+	})
+
+	var srcs []struct {
+		Src net.IP `ch:"src"`
+	}
+	err := importer.Database.Conn.Select(chCtx, &srcs, `
+		SELECT DISTINCT src FROM {tmp_table:Identifier}
+		WHERE filtered = false AND dst IN (SELECT toIPv6(arrayJoin({internal_ips:Array(String)})))
+	`)
+	if err != nil {
+		return "", "", err
+	}
+
+	var filtered []string
+	for _, src := range srcs {
+		if importer.Cfg.Filter.FilterInternalDomainIPPair(src.Src) {
+			filtered = append(filtered, src.Src.String())
+		}
+	}
+	if len(filtered) == 0 {
+		return internalIPs, "[]", nil
+	}
+
+	return internalIPs, "['" + strings.Join(filtered, "','") + "']", nil
+}
@@ -0,0 +1,410 @@
+package importer
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	"github.com/activecm/rita/v5/util"
+	"github.com/joho/godotenv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConnDeduplicatesUIDs(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	importID, err := util.NewFixedStringHash("dedup")
+	require.NoError(t, err)
+
+	// the same connections logged by two zeek workers
+	records := []zeektypes.Conn{
+		{UID: "C1", Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", LogPath: "/logs/worker-1/conn.log"},
+		{UID: "C2", Source: "10.0.0.2", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", LogPath: "/logs/worker-1/conn.log"},
+		{UID: "C1", Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", LogPath: "/logs/worker-2/conn.log"},
+		{UID: "C3", Source: "10.0.0.3", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", LogPath: "/logs/worker-2/conn.log"},
+		{UID: "C2", Source: "10.0.0.2", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", LogPath: "/logs/worker-2/conn.log"},
+	}
+
+	tests := []struct {
+		name               string
+		seenUIDs           *uidSet
+		expectedSrcs       []string
+		expectedDuplicates uint64
+	}{
+		{name: "Deduplication Disabled", seenUIDs: nil, expectedSrcs: []string{"10.0.0.1", "10.0.0.1", "10.0.0.2", "10.0.0.2", "10.0.0.3"}, expectedDuplicates: 0},
+		{name: "Deduplication Enabled", seenUIDs: newUIDSet(), expectedSrcs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, expectedDuplicates: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := make(chan zeektypes.Conn, len(records))
+			output := make(chan database.Data, len(records))
+			for _, record := range records {
+				input <- record
+			}
+			close(input)
+
+			// run multiple parsers at once like the importer does
+			var numConns, numDuplicates, numSelfConns uint64
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					parseConn(&cfg, input, output, importID, time.Now(), "/logs", false, test.seenUIDs, &numConns, &numDuplicates, &numSelfConns)
+				}()
+			}
+			wg.Wait()
+			close(output)
+
+			// each uid belongs to a different source, so the sources show which connections were kept
+			var srcs []string
+			for entry := range output {
+				conn, ok := entry.(*ConnEntry)
+				require.True(t, ok)
+				srcs = append(srcs, conn.Src.String())
+			}
+
+			require.ElementsMatch(t, test.expectedSrcs, srcs)
+			require.Equal(t, uint64(len(test.expectedSrcs)), numConns)
+			require.Equal(t, test.expectedDuplicates, numDuplicates)
+		})
+	}
+}
+
+func TestParseConnMinConnectionBytes(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	importID, err := util.NewFixedStringHash("minbytes")
+	require.NoError(t, err)
+
+	// health checks and scans that didn't transfer any data mixed in with real connections
+	records := []zeektypes.Conn{
+		{UID: "C1", Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 0, RespBytes: 0},
+		{UID: "C2", Source: "10.0.0.2", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 20, RespBytes: 0},
+		{UID: "C3", Source: "10.0.0.3", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 517, RespBytes: 4312},
+		{UID: "C4", Source: "10.0.0.4", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 32, RespBytes: 32},
+		{UID: "C5", Source: "10.0.0.5", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 0, RespBytes: 0},
+	}
+
+	tests := []struct {
+		name                   string
+		minConnectionBytes     int64
+		countForStrobe         bool
+		open                   bool
+		expectedBeaconExcluded []string
+		expectedFiltered       []string
+		expectedNumConns       uint64
+	}{
+		{
+			name:               "Disabled By Default",
+			minConnectionBytes: 0,
+			countForStrobe:     true,
+			expectedNumConns:   5,
+		},
+		{
+			name:                   "Excluded From Beaconing But Counted",
+			minConnectionBytes:     64,
+			countForStrobe:         true,
+			expectedBeaconExcluded: []string{"10.0.0.1", "10.0.0.2", "10.0.0.5"},
+			expectedNumConns:       5,
+		},
+		{
+			name:                   "Excluded From Beaconing And Strobes",
+			minConnectionBytes:     64,
+			countForStrobe:         false,
+			expectedBeaconExcluded: []string{"10.0.0.1", "10.0.0.2", "10.0.0.5"},
+			expectedFiltered:       []string{"10.0.0.1", "10.0.0.2", "10.0.0.5"},
+			expectedNumConns:       2,
+		},
+		{
+			name:               "Open Connections Are Not Affected",
+			minConnectionBytes: 64,
+			countForStrobe:     false,
+			open:               true,
+			expectedNumConns:   5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := config.GetDefaultConfig()
+			require.NoError(t, err)
+			cfg.Filter.MinConnectionBytes = test.minConnectionBytes
+			cfg.Filter.CountLowByteConnectionsForStrobe = test.countForStrobe
+
+			input := make(chan zeektypes.Conn, len(records))
+			output := make(chan database.Data, len(records))
+			for _, record := range records {
+				input <- record
+			}
+			close(input)
+
+			var numConns, numDuplicates, numSelfConns uint64
+			parseConn(&cfg, input, output, importID, time.Now(), "/logs", test.open, nil, &numConns, &numDuplicates, &numSelfConns)
+			close(output)
+
+			// low byte connections are still written so that other logs can link to them by zeek uid
+			var beaconExcluded, filtered []string
+			var total int
+			for entry := range output {
+				conn, ok := entry.(*ConnEntry)
+				require.True(t, ok)
+				total++
+				if conn.BeaconExcluded {
+					beaconExcluded = append(beaconExcluded, conn.Src.String())
+				}
+				if conn.Filtered {
+					filtered = append(filtered, conn.Src.String())
+				}
+			}
+
+			require.Equal(t, len(records), total, "every connection should be written")
+			require.ElementsMatch(t, test.expectedBeaconExcluded, beaconExcluded, "connections excluded from beaconing should match")
+			require.ElementsMatch(t, test.expectedFiltered, filtered, "filtered connections should match")
+			require.Equal(t, test.expectedNumConns, numConns)
+		})
+	}
+}
+
+func TestParseConnMissingRespBytes(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	importID, err := util.NewFixedStringHash("missingbytes")
+	require.NoError(t, err)
+
+	zero, sent := int64(0), int64(4312)
+
+	// C3 comes from a sensor that didn't see the responder's side of the connection
+	records := []zeektypes.Conn{
+		{UID: "C1", Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigIPBytes: 120, RespIPBytes: &sent},
+		{UID: "C2", Source: "10.0.0.2", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigIPBytes: 120, RespIPBytes: &zero},
+		{UID: "C3", Source: "10.0.0.3", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigIPBytes: 120},
+	}
+
+	tests := []struct {
+		name                     string
+		excludeMissingRespBytes  bool
+		expectedDatasizeExcluded []string
+	}{
+		{
+			name: "Tracked But Scored By Default",
+		},
+		{
+			name:                     "Excluded From Datasize Scoring",
+			excludeMissingRespBytes:  true,
+			expectedDatasizeExcluded: []string{"10.0.0.3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := config.GetDefaultConfig()
+			require.NoError(t, err)
+			cfg.Scoring.Beacon.ExcludeMissingRespBytes = test.excludeMissingRespBytes
+
+			input := make(chan zeektypes.Conn, len(records))
+			output := make(chan database.Data, len(records))
+			for _, record := range records {
+				input <- record
+			}
+			close(input)
+
+			var numConns, numDuplicates, numSelfConns uint64
+			parseConn(&cfg, input, output, importID, time.Now(), "/logs", false, nil, &numConns, &numDuplicates, &numSelfConns)
+			close(output)
+
+			dstBytes := make(map[string]int64)
+			var missing, datasizeExcluded []string
+			for entry := range output {
+				conn, ok := entry.(*ConnEntry)
+				require.True(t, ok)
+				dstBytes[conn.Src.String()] = conn.DstIPBytes
+				if conn.MissingDstBytes {
+					missing = append(missing, conn.Src.String())
+				}
+				if conn.DatasizeExcluded {
+					datasizeExcluded = append(datasizeExcluded, conn.Src.String())
+				}
+			}
+
+			require.Equal(t, map[string]int64{"10.0.0.1": 4312, "10.0.0.2": 0, "10.0.0.3": 0}, dstBytes)
+			require.ElementsMatch(t, []string{"10.0.0.3"}, missing, "only connections with an unset resp_ip_bytes should be marked as missing bytes")
+			require.ElementsMatch(t, test.expectedDatasizeExcluded, datasizeExcluded, "connections excluded from datasize scoring should match")
+		})
+	}
+}
+
+func TestParseConnOtherProtocols(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	importTime := time.Unix(1713500000, 0)
+	importID, err := util.NewFixedStringHash("otherprotocols")
+	require.NoError(t, err)
+
+	// zeek logs the icmp type and code in the port fields
+	icmp := &zeektypes.Conn{UID: "C1", Source: "10.0.0.1", Destination: "1.1.1.1", SourcePort: 8, DestinationPort: 0, Proto: "icmp", ConnState: "OTH"}
+	unknown := &zeektypes.Conn{UID: "C2", Source: "10.0.0.1", Destination: "1.1.1.1", Proto: "unknown_transport", ConnState: "OTH"}
+	tcp := &zeektypes.Conn{UID: "C3", Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp"}
+
+	t.Run("Included By Default", func(t *testing.T) {
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+
+		entry, err := formatConnRecord(&cfg, icmp, importID, importTime)
+		require.NoError(t, err)
+		require.False(t, entry.Filtered, "icmp connection should not be filtered")
+		require.Equal(t, 8, entry.ICMPType)
+		require.Equal(t, 0, entry.ICMPCode)
+
+		entry, err = formatConnRecord(&cfg, unknown, importID, importTime)
+		require.NoError(t, err)
+		require.False(t, entry.Filtered, "unknown transport connection should not be filtered")
+		require.Equal(t, -1, entry.ICMPType)
+		require.Equal(t, -1, entry.ICMPCode)
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		cfg, err := config.GetDefaultConfig()
+		require.NoError(t, err)
+		cfg.Filter.FilterOtherProtocols = true
+
+		for _, conn := range []*zeektypes.Conn{icmp, unknown} {
+			entry, err := formatConnRecord(&cfg, conn, importID, importTime)
+			require.NoError(t, err)
+			require.True(t, entry.Filtered, "%s connection should be filtered", conn.Proto)
+		}
+
+		entry, err := formatConnRecord(&cfg, tcp, importID, importTime)
+		require.NoError(t, err)
+		require.False(t, entry.Filtered, "tcp connection should not be filtered")
+	})
+}
+
+func TestParseConnSelfConnections(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	importID, err := util.NewFixedStringHash("selfconns")
+	require.NoError(t, err)
+
+	// C2 is a proxy looping a connection back to itself, it is always included so that it isn't filtered out
+	// as an internal to internal connection
+	records := []zeektypes.Conn{
+		{UID: "C1", Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 500, RespBytes: 500},
+		{UID: "C2", Source: "10.0.0.2", Destination: "10.0.0.2", DestinationPort: 3128, Proto: "tcp", OrigBytes: 500, RespBytes: 500},
+		{UID: "C3", Source: "10.0.0.3", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 500, RespBytes: 500},
+	}
+
+	tests := []struct {
+		name                   string
+		filterSelfConnections  bool
+		expectedBeaconExcluded []string
+		expectedFiltered       []string
+		expectedNumConns       uint64
+		expectedNumSelfConns   uint64
+	}{
+		{
+			name:                   "Filtered By Default",
+			filterSelfConnections:  true,
+			expectedBeaconExcluded: []string{"10.0.0.2"},
+			expectedFiltered:       []string{"10.0.0.2"},
+			expectedNumConns:       2,
+			expectedNumSelfConns:   1,
+		},
+		{
+			name:             "Kept When Disabled",
+			expectedNumConns: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := config.GetDefaultConfig()
+			require.NoError(t, err)
+			require.True(t, cfg.Filter.FilterSelfConnections, "self connections should be filtered by default")
+			cfg.Filter.FilterSelfConnections = test.filterSelfConnections
+			cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{{IP: net.IP{10, 0, 0, 2}, Mask: net.CIDRMask(32, 32)}}
+
+			input := make(chan zeektypes.Conn, len(records))
+			output := make(chan database.Data, len(records))
+			for _, record := range records {
+				input <- record
+			}
+			close(input)
+
+			var numConns, numDuplicates, numSelfConns uint64
+			parseConn(&cfg, input, output, importID, time.Now(), "/logs", false, nil, &numConns, &numDuplicates, &numSelfConns)
+			close(output)
+
+			// self connections are still written so that other logs can link to them by zeek uid
+			var beaconExcluded, filtered []string
+			var total int
+			for entry := range output {
+				conn, ok := entry.(*ConnEntry)
+				require.True(t, ok)
+				total++
+				if conn.BeaconExcluded {
+					beaconExcluded = append(beaconExcluded, conn.Src.String())
+				}
+				if conn.Filtered {
+					filtered = append(filtered, conn.Src.String())
+				}
+			}
+
+			require.Equal(t, len(records), total, "every connection should be written")
+			require.ElementsMatch(t, test.expectedBeaconExcluded, beaconExcluded, "connections excluded from beaconing should match")
+			require.ElementsMatch(t, test.expectedFiltered, filtered, "filtered connections should match")
+			require.Equal(t, test.expectedNumConns, numConns)
+			require.Equal(t, test.expectedNumSelfConns, numSelfConns)
+		})
+	}
+}
+
+func TestParseOpenConnStartTime(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	importID, err := util.NewFixedStringHash("openconnstart")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	// the connection started at 08:15 and was still open when it was logged in open_conn.10:00:00-11:00:00.log
+	started := time.Date(2024, 5, 1, 8, 15, 0, 0, time.UTC)
+	logged := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	record := zeektypes.Conn{
+		UID: "C1", TimeStamp: zeektypes.Timestamp(started.Unix()), Duration: logged.Sub(started).Seconds(),
+		Source: "10.0.0.1", Destination: "1.1.1.1", DestinationPort: 443, Proto: "tcp", OrigBytes: 500, RespBytes: 500,
+	}
+
+	for _, open := range []bool{true, false} {
+		input := make(chan zeektypes.Conn, 1)
+		output := make(chan database.Data, 1)
+		input <- record
+		close(input)
+
+		var numConns, numDuplicates, numSelfConns uint64
+		parseConn(&cfg, input, output, importID, logged, "/logs/2024-05-01", open, nil, &numConns, &numDuplicates, &numSelfConns)
+		close(output)
+
+		entry, ok := (<-output).(*ConnEntry)
+		require.True(t, ok)
+		require.Equal(t, started.Unix(), entry.Timestamp.Unix(), "a connection should contribute its start time to beaconing, open: %t", open)
+		require.Equal(t, logged, entry.ImportTime, "the log time should only be kept as the import time, open: %t", open)
+		require.False(t, entry.BeaconExcluded, "the connection should be used in beaconing, open: %t", open)
+	}
+}
@@ -2,8 +2,10 @@ package importer
 
 import (
 	"errors"
+	"math"
 	"net"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -47,6 +49,7 @@ type DNSEntry struct {
 	Answers             []string         `ch:"answers"`
 	TTLs                []float64        `ch:"ttls"`
 	Rejected            bool             `ch:"rejected"`
+	CNAMEDepth          uint8            `ch:"cname_depth"`
 	// PDNS field
 	ResolvedIP net.IP `ch:"resolved_ip"`
 }
@@ -60,13 +63,137 @@ type UniqueFQDN struct {
 	DstNUID uuid.UUID        `ch:"dst_nuid"`
 }
 
+// fqdnSource identifies the host that made a dns query
+type fqdnSource struct {
+	src  string
+	nuid uuid.UUID
+}
+
+// fqdnLimiter caps the number of distinct fqdns kept for each host during an import so that a host doing massive
+// DNS enumeration can't exhaust memory during analysis, the hosts that hit the cap are kept to be flagged
+type fqdnLimiter struct {
+	mu        sync.Mutex
+	max       int
+	fqdns     map[fqdnSource]map[string]struct{}
+	truncated map[fqdnSource]*database.DNSFlood
+}
+
+func newFQDNLimiter(max int) *fqdnLimiter {
+	return &fqdnLimiter{
+		max:       max,
+		fqdns:     make(map[fqdnSource]map[string]struct{}),
+		truncated: make(map[fqdnSource]*database.DNSFlood),
+	}
+}
+
+// allow records the query of the entry and returns false if its host already queried the maximum number of
+// distinct fqdns and the query is for a new fqdn
+func (l *fqdnLimiter) allow(entry *DNSEntry) bool {
+	key := fqdnSource{src: entry.Src.String(), nuid: entry.SrcNUID}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fqdns, ok := l.fqdns[key]
+	if !ok {
+		fqdns = make(map[string]struct{})
+		l.fqdns[key] = fqdns
+	}
+
+	// queries for fqdns that are already tracked are always kept
+	if _, ok := fqdns[entry.Query]; ok {
+		return true
+	}
+
+	if len(fqdns) < l.max {
+		fqdns[entry.Query] = struct{}{}
+		return true
+	}
+
+	flood, ok := l.truncated[key]
+	if !ok {
+		flood = &database.DNSFlood{Src: entry.Src, SrcNUID: entry.SrcNUID, FQDNCount: uint64(l.max)}
+		l.truncated[key] = flood
+	}
+	flood.DroppedCount++
+	if entry.Timestamp.After(flood.LastSeen) {
+		flood.LastSeen = entry.Timestamp
+	}
+
+	return false
+}
+
+// floods returns the hosts that hit the maximum number of distinct fqdns
+func (l *fqdnLimiter) floods() []database.DNSFlood {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	floods := make([]database.DNSFlood, 0, len(l.truncated))
+	for _, flood := range l.truncated {
+		floods = append(floods, *flood)
+	}
+	return floods
+}
+
+// internalDomainIPs keeps track of the IPs that the internal domains resolved to during an import. The queries for
+// internal domains are filtered out, so the IPs are collected while parsing the dns logs and the connections to them are
+// classified as internal when the connections are written.
+type internalDomainIPs struct {
+	mu  sync.Mutex
+	ips map[string]struct{}
+}
+
+func newInternalDomainIPs() *internalDomainIPs {
+	return &internalDomainIPs{ips: make(map[string]struct{})}
+}
+
+// add records the resolved IPs of an A or AAAA query for an internal domain. Domains and IPs on the always included
+// lists aren't recorded, since connections to them are never treated as internal.
+func (s *internalDomainIPs) add(cfg *config.Config, record *zeektypes.DNS) {
+	if !cfg.Filter.CheckIfInternalDomain(record.Query) || util.ContainsDomain(cfg.Filter.AlwaysIncludedDomains, record.Query) {
+		return
+	}
+	if record.QTypeName != "A" && record.QTypeName != "AAAA" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, answer := range record.Answers {
+		ip := net.ParseIP(answer)
+		if ip == nil || util.ContainsIP(cfg.Filter.AlwaysIncludedSubnets, ip) {
+			continue
+		}
+		s.ips[ip.String()] = struct{}{}
+	}
+}
+
+// list returns the recorded IPs
+func (s *internalDomainIPs) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ips := make([]string, 0, len(s.ips))
+	for ip := range s.ips {
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
 // parseDNS listens on a channel of raw dns log records, formats them into dns and pdns entries and and sends them to be written to the database
-func parseDNS(cfg *config.Config, dns <-chan zeektypes.DNS, dnsOutput, pdnsOutput chan<- database.Data, numDNS, numPDNSRaw *uint64, importTime time.Time) {
+// if fqdnLimit is not nil, queries for new fqdns by hosts that already queried too many distinct fqdns are skipped and counted in numTruncated
+// if internalIPs is not nil, the IPs that internal domains resolved to are recorded in it
+func parseDNS(cfg *config.Config, dns <-chan zeektypes.DNS, dnsOutput, pdnsOutput chan<- database.Data, fqdnLimit *fqdnLimiter, internalIPs *internalDomainIPs, numDNS, numPDNSRaw, numTruncated *uint64, importTime time.Time) {
 	logger := zlog.GetLogger()
 
 	// loop over raw dns channel
 	for d := range dns {
 
+		// the queries for internal domains are filtered out below, but the connections to their IPs are still classified
+		if internalIPs != nil {
+			internalIPs.add(cfg, &d)
+		}
+
 		// parse raw record as a dns entry
 		entry, err := formatDNSRecord(cfg, &d, importTime)
 		if err != nil {
@@ -86,6 +213,12 @@ func parseDNS(cfg *config.Config, dns <-chan zeektypes.DNS, dnsOutput, pdnsOutpu
 			continue
 		}
 
+		// stop tracking new fqdns for hosts that queried too many
+		if fqdnLimit != nil && !fqdnLimit.allow(entry) {
+			atomic.AddUint64(numTruncated, 1)
+			continue
+		}
+
 		dnsOutput <- entry // send to dns log writer
 
 		// addToUDNS(uDNSMap, entry)   // add to unique dns map
@@ -125,7 +258,9 @@ func formatDNSRecord(cfg *config.Config, parseDNS *zeektypes.DNS, importTime tim
 
 	// Run query through filter to filter out certain domains and
 	// filter out traffic which is external -> external or external -> internal (if specified in the config file)
-	ignore := (cfg.Filter.FilterDomain(parseDNS.Query) || cfg.Filter.FilterDNSPair(srcIP, dstIP))
+	// queries for internal domains are treated as internal traffic
+	ignore := (cfg.Filter.FilterDomain(parseDNS.Query) || cfg.Filter.FilterDNSPair(srcIP, dstIP) ||
+		cfg.Filter.FilterInternalDomainPair(srcIP, parseDNS.Query))
 
 	// If domain is not subject to filtering, process
 	if ignore {
@@ -174,11 +309,36 @@ func formatDNSRecord(cfg *config.Config, parseDNS *zeektypes.DNS, importTime tim
 		Answers:             parseDNS.Answers,
 		TTLs:                parseDNS.TTLs,
 		Rejected:            parseDNS.Rejected,
+		CNAMEDepth:          cnameChainDepth(parseDNS.QTypeName, parseDNS.Answers),
 	}
 
 	return entry, nil
 }
 
+// cnameChainDepth returns the number of CNAME records that an address query was redirected through before it was
+// resolved. Zeek lists the answers of A, AAAA, and CNAME queries as the canonical name of each CNAME record followed
+// by the resolved IPs, so every answer that isn't an IP is a link in the chain. The answers of other query types
+// aren't hostnames, so they are never counted.
+func cnameChainDepth(queryType string, answers []string) uint8 {
+	if queryType != "A" && queryType != "AAAA" && queryType != "CNAME" {
+		return 0
+	}
+
+	names := make(map[string]struct{})
+	for _, answer := range answers {
+		if answer == "" || strings.ContainsRune(answer, ' ') || net.ParseIP(answer) != nil {
+			continue
+		}
+		names[strings.ToLower(answer)] = struct{}{}
+	}
+
+	// the depth is stored as a UInt8, which is more than enough for any real chain
+	if len(names) > math.MaxUint8 {
+		return math.MaxUint8
+	}
+	return uint8(len(names))
+}
+
 // parsePDNSRecord takes a single dns entry and splits it into multiple entries, one for each answer with a resolved ip in the dns record.
 func parsePDNSRecord(dnsRecord *DNSEntry, writeChan chan<- database.Data, numDNS *uint64) {
 
@@ -0,0 +1,212 @@
+package importer
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	"github.com/joho/godotenv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDNSMaxFQDNsPerSrc(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	// 10.0.0.1 queries five distinct domains, one of them twice after hitting the limit
+	records := []zeektypes.DNS{
+		{UID: "D1", TimeStamp: 1717243200, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "a.example.com"},
+		{UID: "D2", TimeStamp: 1717243201, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "b.example.com"},
+		{UID: "D3", TimeStamp: 1717243202, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "c.example.com"},
+		{UID: "D4", TimeStamp: 1717243203, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "d.example.com"},
+		{UID: "D5", TimeStamp: 1717243204, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "e.example.com"},
+		{UID: "D6", TimeStamp: 1717243205, Source: "10.0.0.2", Destination: "10.0.0.53", Query: "a.example.com"},
+	}
+
+	tests := []struct {
+		name              string
+		fqdnLimit         *fqdnLimiter
+		expectedRecords   int
+		expectedTruncated uint64
+		expectedFloods    []string
+	}{
+		{name: "Limit Disabled", fqdnLimit: nil, expectedRecords: 6, expectedTruncated: 0},
+		{name: "Limit Not Reached", fqdnLimit: newFQDNLimiter(5), expectedRecords: 6, expectedTruncated: 0, expectedFloods: []string{}},
+		{name: "Limit Reached", fqdnLimit: newFQDNLimiter(3), expectedRecords: 4, expectedTruncated: 2, expectedFloods: []string{"10.0.0.1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := make(chan zeektypes.DNS, len(records))
+			dnsOutput := make(chan database.Data, len(records))
+			pdnsOutput := make(chan database.Data, len(records))
+			for _, record := range records {
+				input <- record
+			}
+			close(input)
+
+			// run multiple parsers at once like the importer does
+			var numDNS, numPDNS, numTruncated uint64
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					parseDNS(&cfg, input, dnsOutput, pdnsOutput, test.fqdnLimit, nil, &numDNS, &numPDNS, &numTruncated, time.Now())
+				}()
+			}
+			wg.Wait()
+			close(dnsOutput)
+
+			// count the distinct domains kept for each source
+			fqdns := make(map[string]map[string]struct{})
+			for entry := range dnsOutput {
+				dns, ok := entry.(*DNSEntry)
+				require.True(t, ok)
+				if fqdns[dns.Src.String()] == nil {
+					fqdns[dns.Src.String()] = make(map[string]struct{})
+				}
+				fqdns[dns.Src.String()][dns.Query] = struct{}{}
+			}
+
+			require.Equal(t, uint64(test.expectedRecords), numDNS)
+			require.Equal(t, test.expectedTruncated, numTruncated)
+			if test.fqdnLimit == nil {
+				return
+			}
+
+			for src, queried := range fqdns {
+				require.LessOrEqual(t, len(queried), test.fqdnLimit.max, "no more than the maximum number of domains should be kept for %s", src)
+			}
+
+			floods := test.fqdnLimit.floods()
+			srcs := make([]string, 0, len(floods))
+			for _, flood := range floods {
+				srcs = append(srcs, flood.Src.String())
+				require.Equal(t, uint64(test.fqdnLimit.max), flood.FQDNCount)
+				require.Equal(t, test.expectedTruncated, flood.DroppedCount)
+				require.False(t, flood.LastSeen.IsZero(), "the last skipped query should be recorded")
+			}
+			require.ElementsMatch(t, test.expectedFloods, srcs)
+		})
+	}
+}
+
+func TestFQDNLimiterKeepsTrackedFQDNs(t *testing.T) {
+	limiter := newFQDNLimiter(1)
+	tracked := &DNSEntry{Src: net.ParseIP("10.0.0.1"), Query: "a.example.com"}
+	untracked := &DNSEntry{Src: net.ParseIP("10.0.0.1"), Query: "b.example.com"}
+
+	require.True(t, limiter.allow(tracked))
+	require.False(t, limiter.allow(untracked), "queries for new domains should be skipped after the limit is reached")
+	require.True(t, limiter.allow(tracked), "queries for a tracked domain should be kept after the limit is reached")
+	require.Len(t, limiter.floods(), 1)
+}
+
+func TestParseDNSInternalDomainIPs(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+	cfg.Filter.InternalDomains = []string{"*.corp.example.com"}
+	cfg.Filter.AlwaysIncludedDomains = []string{"vpn.corp.example.com"}
+	cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{{IP: net.IP{203, 0, 113, 0}, Mask: net.CIDRMask(24, 32)}}
+
+	records := []zeektypes.DNS{
+		// split-horizon domains that resolve to external looking IPs
+		{UID: "D1", TimeStamp: 1717243200, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "wiki.corp.example.com", QTypeName: "A", Answers: []string{"wiki-lb.corp.example.com", "198.51.100.10"}},
+		{UID: "D2", TimeStamp: 1717243201, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "mail.corp.example.com", QTypeName: "AAAA", Answers: []string{"2001:db8::25"}},
+		// the answers of other query types aren't IPs that connections are made to
+		{UID: "D3", TimeStamp: 1717243202, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "corp.example.com", QTypeName: "TXT", Answers: []string{"198.51.100.99"}},
+		// always included domains and IPs are never treated as internal
+		{UID: "D4", TimeStamp: 1717243203, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "vpn.corp.example.com", QTypeName: "A", Answers: []string{"198.51.100.20"}},
+		{UID: "D5", TimeStamp: 1717243204, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "git.corp.example.com", QTypeName: "A", Answers: []string{"203.0.113.5"}},
+		// external domains
+		{UID: "D6", TimeStamp: 1717243205, Source: "10.0.0.1", Destination: "10.0.0.53", Query: "www.example.com", QTypeName: "A", Answers: []string{"93.184.216.34"}},
+	}
+
+	input := make(chan zeektypes.DNS, len(records))
+	dnsOutput := make(chan database.Data, len(records))
+	pdnsOutput := make(chan database.Data, len(records))
+	for _, record := range records {
+		input <- record
+	}
+	close(input)
+
+	internalIPs := newInternalDomainIPs()
+	var numDNS, numPDNS, numTruncated uint64
+	parseDNS(&cfg, input, dnsOutput, pdnsOutput, nil, internalIPs, &numDNS, &numPDNS, &numTruncated, time.Now())
+
+	require.ElementsMatch(t, []string{"198.51.100.10", "2001:db8::25"}, internalIPs.list(), "only the IPs that internal domains resolved to should be recorded")
+	require.Equal(t, uint64(2), numDNS, "the queries for internal domains should still be filtered out")
+}
+
+func TestParseDNSCNAMEChainDepth(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		queryType     string
+		answers       []string
+		expectedDepth uint8
+	}{
+		{name: "Resolved Directly", queryType: "A", answers: []string{"93.184.216.34", "93.184.216.35"}, expectedDepth: 0},
+		{name: "No Answers", queryType: "A", answers: nil, expectedDepth: 0},
+		{
+			name:          "CDN Chain",
+			queryType:     "A",
+			answers:       []string{"www.example.com.cdn.net", "edge.cdn.net", "93.184.216.34"},
+			expectedDepth: 2,
+		},
+		{
+			name:      "Long Chain",
+			queryType: "AAAA",
+			answers: []string{
+				"hop1.redirect.example", "hop2.redirect.example", "hop3.redirect.example", "hop4.redirect.example",
+				"hop5.redirect.example", "2001:db8::1",
+			},
+			expectedDepth: 5,
+		},
+		{
+			name:          "Repeated Names Are Counted Once",
+			queryType:     "CNAME",
+			answers:       []string{"alias.example.com", "ALIAS.example.com"},
+			expectedDepth: 1,
+		},
+		{
+			name:          "Non Address Query",
+			queryType:     "TXT",
+			answers:       []string{"TXT 27 v=spf1 include:example.com", "mx.example.com"},
+			expectedDepth: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := make(chan zeektypes.DNS, 1)
+			dnsOutput := make(chan database.Data, 1)
+			pdnsOutput := make(chan database.Data, len(test.answers))
+			input <- zeektypes.DNS{
+				UID: "D1", TimeStamp: 1717243200, Source: "10.0.0.1", Destination: "10.0.0.53",
+				Query: "www.example.com", QTypeName: test.queryType, Answers: test.answers,
+			}
+			close(input)
+
+			var numDNS, numPDNS, numTruncated uint64
+			parseDNS(&cfg, input, dnsOutput, pdnsOutput, nil, nil, &numDNS, &numPDNS, &numTruncated, time.Now())
+			close(dnsOutput)
+
+			entry, ok := (<-dnsOutput).(*DNSEntry)
+			require.True(t, ok)
+			require.Equal(t, test.expectedDepth, entry.CNAMEDepth)
+			require.Equal(t, test.answers, entry.Answers, "the answers should be stored as they were logged")
+		})
+	}
+}
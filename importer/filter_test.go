@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	"github.com/activecm/rita/v5/util"
+	"github.com/joho/godotenv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcastMulticastDestinationsAreFiltered(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	// always including the source would normally override the never included list, but broadcast
+	// and multicast destinations must be dropped regardless
+	cfg.Filter.AlwaysIncludedSubnets = []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}}
+
+	importTime := time.Unix(1713500000, 0)
+	importID, err := util.NewFixedStringHash("multicast")
+	require.NoError(t, err)
+
+	destinations := []string{"224.0.0.251", "239.255.255.250", "255.255.255.255", "ff02::fb", "::ffff:224.0.0.252"}
+
+	for _, dst := range destinations {
+		t.Run(dst, func(t *testing.T) {
+			t.Run("Conn", func(t *testing.T) {
+				entry, err := formatConnRecord(&cfg, &zeektypes.Conn{UID: "CMC1", Source: "10.0.0.5", Destination: dst, DestinationPort: 5353, Proto: "udp"}, importID, importTime)
+				require.NoError(t, err)
+				require.Nil(t, entry, "conn record should be dropped")
+			})
+
+			t.Run("DNS", func(t *testing.T) {
+				entry, err := formatDNSRecord(&cfg, &zeektypes.DNS{UID: "CMC2", Source: "10.0.0.5", Destination: dst, DestinationPort: 5353, Query: "printer.local"}, importTime)
+				require.NoError(t, err)
+				require.Nil(t, entry, "dns record should be dropped")
+			})
+
+			t.Run("HTTP", func(t *testing.T) {
+				entry, err := formatHTTPRecord(&cfg, &zeektypes.HTTP{UID: "CMC3", Source: "10.0.0.5", Destination: dst, DestinationPort: 1900, Method: "NOTIFY", Host: "example.com"}, importTime)
+				require.NoError(t, err)
+				require.Nil(t, entry, "http record should be dropped")
+			})
+
+			t.Run("HTTP Proxy", func(t *testing.T) {
+				entry, err := formatHTTPRecord(&cfg, &zeektypes.HTTP{UID: "CMC4", Source: "10.0.0.5", Destination: "10.0.0.1", DestinationPort: 3128, Method: "CONNECT", Host: dst}, importTime)
+				require.NoError(t, err)
+				require.Nil(t, entry, "proxied http record should be dropped")
+			})
+
+			t.Run("SSL", func(t *testing.T) {
+				entry, err := formatSSLRecord(&cfg, &zeektypes.SSL{UID: "CMC5", Source: "10.0.0.5", Destination: dst, DestinationPort: 443, ServerName: "example.com"}, importTime)
+				require.NoError(t, err)
+				require.Nil(t, entry, "ssl record should be dropped")
+			})
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		cfg := cfg
+		cfg.Filter.FilterBroadcastMulticast = false
+
+		// without the setting, the AlwaysInclude list takes precedence as it does for any other never included subnet
+		entry, err := formatConnRecord(&cfg, &zeektypes.Conn{UID: "CMC6", Source: "10.0.0.5", Destination: "224.0.0.251", DestinationPort: 5353, Proto: "udp"}, importID, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		require.False(t, entry.Filtered)
+	})
+}
+
+func TestInternalDomainsAreTreatedAsInternal(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+	cfg.Filter.InternalDomains = []string{"*.corp.example.com"}
+
+	importTime := time.Unix(1713500000, 0)
+
+	t.Run("Internal Source", func(t *testing.T) {
+		// the server of an internal domain may have an external looking address with split-horizon DNS
+		ssl, err := formatSSLRecord(&cfg, &zeektypes.SSL{UID: "CID1", Source: "10.0.0.5", Destination: "203.0.113.10", DestinationPort: 443, ServerName: "wiki.corp.example.com"}, importTime)
+		require.NoError(t, err)
+		require.Nil(t, ssl, "ssl record should be dropped")
+
+		http, err := formatHTTPRecord(&cfg, &zeektypes.HTTP{UID: "CID2", Source: "10.0.0.5", Destination: "203.0.113.10", DestinationPort: 80, Method: "GET", Host: "wiki.corp.example.com"}, importTime)
+		require.NoError(t, err)
+		require.Nil(t, http, "http record should be dropped")
+
+		dns, err := formatDNSRecord(&cfg, &zeektypes.DNS{UID: "CID3", Source: "10.0.0.5", Destination: "10.0.0.1", DestinationPort: 53, Query: "wiki.corp.example.com"}, importTime)
+		require.NoError(t, err)
+		require.Nil(t, dns, "dns record should be dropped")
+
+		// other domains are unaffected
+		ssl, err = formatSSLRecord(&cfg, &zeektypes.SSL{UID: "CID4", Source: "10.0.0.5", Destination: "203.0.113.10", DestinationPort: 443, ServerName: "example.com"}, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, ssl)
+		require.False(t, ssl.DstLocal)
+	})
+
+	t.Run("External Source", func(t *testing.T) {
+		cfg := cfg
+		cfg.Filter.FilterExternalToInternal = true
+
+		dns, err := formatDNSRecord(&cfg, &zeektypes.DNS{UID: "CID5", Source: "65.0.0.5", Destination: "10.0.0.1", DestinationPort: 53, Query: "wiki.corp.example.com"}, importTime)
+		require.NoError(t, err)
+		require.Nil(t, dns, "dns record should be dropped when filtering external to internal traffic")
+
+		cfg.Filter.FilterExternalToInternal = false
+		dns, err = formatDNSRecord(&cfg, &zeektypes.DNS{UID: "CID6", Source: "65.0.0.5", Destination: "10.0.0.1", DestinationPort: 53, Query: "wiki.corp.example.com"}, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, dns)
+	})
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/google/uuid"
+)
+
+var errMissingFTPCommand = "blank or missing command field in ftp log entry, skipping entry"
+
+type FTPEntry struct {
+	ImportTime time.Time        `ch:"import_time"`
+	ZeekUID    util.FixedString `ch:"zeek_uid"`
+	Hash       util.FixedString `ch:"hash"`
+	Timestamp  time.Time        `ch:"ts"`
+	Src        net.IP           `ch:"src"`
+	Dst        net.IP           `ch:"dst"`
+	SrcNUID    uuid.UUID        `ch:"src_nuid"`
+	DstNUID    uuid.UUID        `ch:"dst_nuid"`
+	SrcPort    uint16           `ch:"src_port"`
+	DstPort    uint16           `ch:"dst_port"`
+	SrcLocal   bool             `ch:"src_local"`
+	DstLocal   bool             `ch:"dst_local"`
+	User       string           `ch:"user"`
+	Command    string           `ch:"command"`
+	Arg        string           `ch:"arg"`
+	MIMEType   string           `ch:"mime_type"`
+	FileSize   uint64           `ch:"file_size"`
+	ReplyCode  uint16           `ch:"reply_code"`
+	ReplyMsg   string           `ch:"reply_msg"`
+	Sensor     string           `ch:"sensor"`
+}
+
+// parseFTP listens on a channel of raw ftp log records, formats them and sends them to be written to the database
+func parseFTP(cfg *config.Config, ftp <-chan zeektypes.FTP, output chan<- database.Data, importTime time.Time, logDir string, numFTP *uint64) {
+	logger := zlog.GetLogger()
+
+	// loop over raw ftp channel
+	for f := range ftp {
+
+		// parse raw record as an ftp entry
+		entry, err := formatFTPRecord(cfg, &f, importTime)
+		if err != nil {
+			logger.Debug().Err(err).
+				Str("log_path", f.LogPath).
+				Str("zeek_uid", f.UID).
+				Str("timestamp", (time.Unix(int64(f.TimeStamp), 0)).String()).
+				Str("src", f.Source).
+				Str("dst", f.Destination).
+				Send()
+			continue
+		}
+
+		// entry was subject to filtering
+		if entry == nil {
+			continue
+		}
+
+		// record which sensor this command was seen by
+		entry.Sensor = ParseSensor(logDir, f.LogPath)
+
+		output <- entry
+		// increment record counter
+		atomic.AddUint64(numFTP, 1)
+	}
+}
+
+// formatFTPRecord takes a raw ftp record and formats it into the structure needed by the database
+func formatFTPRecord(cfg *config.Config, parseFTP *zeektypes.FTP, importTime time.Time) (*FTPEntry, error) {
+
+	// parse source and destination
+	srcIP := net.ParseIP(parseFTP.Source)
+	dstIP := net.ParseIP(parseFTP.Destination)
+
+	// verify that both addresses were parsed successfully
+	if (srcIP == nil) || (dstIP == nil) {
+		return nil, errors.New(errParseSrcDst)
+	}
+
+	// verify that the command field is set
+	if parseFTP.Command == "" {
+		return nil, errors.New(errMissingFTPCommand)
+	}
+
+	// ftp follows the same rules as conn records
+	ignore := cfg.Filter.FilterConnPair(srcIP, dstIP) || cfg.Filter.FilterPort(uint16(parseFTP.DestinationPort), "tcp")
+	if ignore {
+		return nil, nil
+	}
+
+	srcNUID := util.ParseNetworkID(srcIP, parseFTP.AgentUUID)
+	dstNUID := util.ParseNetworkID(dstIP, parseFTP.AgentUUID)
+
+	zeekUID, err := util.NewFixedStringHash(parseFTP.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same hash as the unique connection for this pair
+	hash, err := util.NewBeaconHash("ip", util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID})
+	if err != nil {
+		return nil, err
+	}
+
+	// zeek leaves the file size unset for commands that didn't transfer a file
+	var fileSize uint64
+	if parseFTP.FileSize > 0 {
+		fileSize = uint64(parseFTP.FileSize)
+	}
+
+	entry := &FTPEntry{
+		ImportTime: importTime,
+		ZeekUID:    zeekUID,
+		Hash:       hash,
+		Timestamp:  time.Unix(int64(parseFTP.TimeStamp), 0),
+		Src:        srcIP,
+		Dst:        dstIP,
+		SrcNUID:    srcNUID,
+		DstNUID:    dstNUID,
+		SrcPort:    uint16(parseFTP.SourcePort),
+		DstPort:    uint16(parseFTP.DestinationPort),
+		SrcLocal:   cfg.Filter.CheckIfInternal(srcIP),
+		DstLocal:   cfg.Filter.CheckIfInternal(dstIP),
+		User:       parseFTP.User,
+		Command:    strings.ToUpper(parseFTP.Command),
+		Arg:        parseFTP.Arg,
+		MIMEType:   parseFTP.MIMEType,
+		FileSize:   fileSize,
+		ReplyCode:  uint16(parseFTP.ReplyCode),
+		ReplyMsg:   parseFTP.ReplyMsg,
+	}
+
+	return entry, nil
+}
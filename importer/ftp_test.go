@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	"github.com/activecm/rita/v5/util"
+	"github.com/joho/godotenv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatFTPRecord(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	importTime := time.Unix(1713500000, 0)
+	importID, err := util.NewFixedStringHash("ftp")
+	require.NoError(t, err)
+
+	upload := zeektypes.FTP{
+		TimeStamp:       1713499000,
+		UID:             "CFTP1",
+		Source:          "10.0.0.5",
+		SourcePort:      51234,
+		Destination:     "203.0.113.10",
+		DestinationPort: 21,
+		User:            "backup",
+		Command:         "stor",
+		Arg:             "ftp://203.0.113.10/archive.7z",
+		FileSize:        52428800,
+		ReplyCode:       226,
+		ReplyMsg:        "Transfer complete.",
+	}
+
+	t.Run("Upload", func(t *testing.T) {
+		entry, err := formatFTPRecord(&cfg, &upload, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+
+		require.Equal(t, "10.0.0.5", entry.Src.String())
+		require.Equal(t, "203.0.113.10", entry.Dst.String())
+		require.Equal(t, uint16(51234), entry.SrcPort)
+		require.Equal(t, uint16(21), entry.DstPort)
+		require.True(t, entry.SrcLocal)
+		require.False(t, entry.DstLocal)
+		require.Equal(t, "STOR", entry.Command, "commands should be normalized to uppercase")
+		require.Equal(t, "ftp://203.0.113.10/archive.7z", entry.Arg)
+		require.Equal(t, uint64(52428800), entry.FileSize)
+		require.Equal(t, uint16(226), entry.ReplyCode)
+		require.Equal(t, time.Unix(1713499000, 0), entry.Timestamp)
+		require.Equal(t, importTime, entry.ImportTime)
+
+		// the hash should match the unique connection of the same pair so that results can be joined
+		conn, err := formatConnRecord(&cfg, &zeektypes.Conn{UID: "CFTP1", Source: "10.0.0.5", Destination: "203.0.113.10", DestinationPort: 21, Proto: "tcp"}, importID, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, conn)
+		require.Equal(t, conn.Hash, entry.Hash)
+	})
+
+	t.Run("Unset File Size", func(t *testing.T) {
+		record := upload
+		record.Command = "CWD"
+		record.FileSize = -1
+		entry, err := formatFTPRecord(&cfg, &record, importTime)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), entry.FileSize)
+	})
+
+	t.Run("Missing Command", func(t *testing.T) {
+		record := upload
+		record.Command = ""
+		entry, err := formatFTPRecord(&cfg, &record, importTime)
+		require.Error(t, err)
+		require.Nil(t, entry)
+	})
+
+	t.Run("Invalid Address", func(t *testing.T) {
+		record := upload
+		record.Source = "not an ip"
+		entry, err := formatFTPRecord(&cfg, &record, importTime)
+		require.Error(t, err)
+		require.Nil(t, entry)
+	})
+}
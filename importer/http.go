@@ -64,6 +64,7 @@ type HTTPEntry struct {
 	DstFUIDs     []string         `ch:"dst_fuids"`
 	DstFileNames []string         `ch:"dst_file_names"`
 	DstMIMETypes []string         `ch:"dst_mime_types"`
+	Sensor       string           `ch:"sensor"`
 }
 
 // parseHTTP listens on a channel of raw http/openhttp log records, formats them and sends them to be linked with conn/openconn records and written to the database
@@ -145,21 +146,29 @@ func formatHTTPRecord(cfg *config.Config, parseHTTP *zeektypes.HTTP, importTime
 
 	if dstIsProxy {
 
-		if cfg.Filter.FilterDomain(fqdn) || cfg.Filter.FilterSingleIP(srcIP) {
+		fqdnAsIPAddress := net.ParseIP(fqdn)
 
+		// the proxied destination may be a broadcast or multicast address even though the proxy isn't
+		if cfg.Filter.FilterDomain(fqdn) || cfg.Filter.FilterSingleIP(srcIP) || cfg.Filter.FilterDestination(fqdnAsIPAddress) ||
+			cfg.Filter.FilterInternalDomainPair(srcIP, fqdn) {
 			return nil, nil
 		}
-		fqdnAsIPAddress := net.ParseIP(fqdn)
 
 		if fqdnAsIPAddress != nil && dstLocal && cfg.Filter.FilterConnPair(srcIP, fqdnAsIPAddress) {
 			return nil, nil
 		}
 	} else if cfg.Filter.FilterDomain(fqdn) || cfg.Filter.FilterConnPair(srcIP, dstIP) ||
+		cfg.Filter.FilterPort(uint16(parseHTTP.DestinationPort), "tcp") || cfg.Filter.FilterInternalDomainPair(srcIP, fqdn) ||
 		// filter out connections where the src is external if the host isn't missing
 		(cfg.Filter.FilterSNIPair(srcIP) && parseHTTP.Host != "") {
 		return nil, nil
 	}
 
+	// servers of internal domains are internal, unless the destination is a proxy for the domain
+	if !dstIsProxy && cfg.Filter.CheckIfInternalDomain(fqdn) {
+		dstLocal = true
+	}
+
 	srcNUID := util.ParseNetworkID(srcIP, parseHTTP.AgentUUID)
 	dstNUID := util.ParseNetworkID(dstIP, parseHTTP.AgentUUID)
 
@@ -168,7 +177,8 @@ func formatHTTPRecord(cfg *config.Config, parseHTTP *zeektypes.HTTP, importTime
 		return nil, err
 	}
 
-	hash, err := util.NewFixedStringHash(srcIP.To16().String(), srcNUID.String(), dstIP.To16().String(), dstNUID.String(), fqdn)
+	// only identifies the record until it is linked to its connection, which replaces it with the hash of its beacon type
+	hash, err := util.NewConnectionHash(util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID, FQDN: fqdn}, util.SNIHashComponents...)
 	if err != nil {
 		return nil, err
 	}
@@ -253,7 +263,7 @@ func (importer *Importer) writeLinkedHTTP(ctx context.Context, progress *tea.Pro
 		-- set proto and service regardless of whether it was linked already or not
 		-- since multi-requests can use different dst ports and still have the same UID, so
 		-- it is useful to be able to see the dst ports coming from multi request entries as well
-		c.proto as proto, c.service as service,
+		c.proto as proto, c.service as service, c.sensor as sensor,
 		if( h.rn = 1, c.src_ip_bytes, 0) as src_ip_bytes,
 		if( h.rn = 1, c.dst_ip_bytes, 0) as dst_ip_bytes,
 		if( h.rn = 1, c.src_bytes, 0) as src_bytes,
@@ -292,7 +302,7 @@ func (importer *Importer) writeLinkedHTTP(ctx context.Context, progress *tea.Pro
 			}
 			entry.ImportTime = importer.Database.ImportStartedAt
 
-			hash, err := util.NewFixedStringHash(entry.Src.To16().String(), entry.SrcNUID.String(), entry.Host)
+			hash, err := util.NewBeaconHash("sni", util.ConnectionIdentity{Src: entry.Src, SrcNUID: entry.SrcNUID, FQDN: entry.Host})
 			if err != nil {
 				return err
 			}
@@ -329,6 +339,7 @@ func (importer *Importer) writeLinkedHTTP(ctx context.Context, progress *tea.Pro
 					DstLocal:             entry.DstLocal,
 					ICMPType:             icmpType,
 					ICMPCode:             icmpCode,
+					Sensor:               entry.Sensor,
 				}
 				connWriter.WriteChannel <- connEntry
 			default:
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/activecm/rita/v5/config"
@@ -31,7 +32,7 @@ import (
 var ErrAllFilesPreviouslyImported = errors.New("all files were previously imported")
 
 type zeekRecord interface {
-	zeektypes.Conn | zeektypes.DNS | zeektypes.HTTP | zeektypes.SSL
+	zeektypes.Conn | zeektypes.DNS | zeektypes.HTTP | zeektypes.SSL | zeektypes.RDP | zeektypes.FTP | zeektypes.X509 | zeektypes.Kerberos | zeektypes.NTLM
 }
 
 type Importer struct {
@@ -51,18 +52,31 @@ type Importer struct {
 	ProgressBar              *mpb.Progress
 	FileProgressBar          *mpb.Bar
 	ProgressLogger           *log.Logger
+	Progress                 *Progress // progress of the whole import that this hour is part of, nil if it isn't reported
 	HTTPLinkMutex            sync.Mutex
 	OpenHTTPLinkMutex        sync.Mutex
 	NumParsers               int
 	NumDigesters             int
 	NumWriters               int
 	ResultCounts             ResultCounts
+	seenConnUIDs             *uidSet
+	seenOpenConnUIDs         *uidSet
+	fqdnLimit                *fqdnLimiter
+	internalIPs              *internalDomainIPs
 	wg                       WaitGroups
 	importStartedCallback    func(util.FixedString) error
-	validateLogFilesCallback func(map[string][]string) (int, error)
+	validateLogFilesCallback func(map[string][]string, map[string]database.FileContents) (int, map[string]database.FileContents, error)
 	startWritersCallback     func(int)
-	closeWritersCallback     func()
-	markFileImportedCallback func(util.FixedString, util.FixedString, string) error
+	closeWritersCallback     func() error
+	markFileImportedCallback func(util.FixedString, util.FixedString, string, database.FileContents) error
+	recordDNSFloodsCallback  func([]database.DNSFlood) error
+	digestFileCallback       func(afero.Fs, string)
+
+	// checksums and sizes of the contents of the files in this import, keyed by path
+	fileContents map[string]database.FileContents
+
+	// offsets of the files that grew since they were imported, keyed by path, the records before them are skipped
+	resumeOffsets map[string]int64
 }
 
 type EntryChans struct {
@@ -73,6 +87,11 @@ type EntryChans struct {
 	OpenHTTP chan zeektypes.HTTP
 	SSL      chan zeektypes.SSL
 	OpenSSL  chan zeektypes.SSL
+	RDP      chan zeektypes.RDP
+	FTP      chan zeektypes.FTP
+	X509     chan zeektypes.X509
+	Kerberos chan zeektypes.Kerberos
+	NTLM     chan zeektypes.NTLM
 }
 
 type writers struct {
@@ -84,6 +103,11 @@ type writers struct {
 	OpenHTTPTmp *database.BulkWriter
 	SSLTmp      *database.BulkWriter
 	OpenSSLTmp  *database.BulkWriter
+	RDPTmp      *database.BulkWriter
+	FTP         *database.BulkWriter
+	X509        *database.BulkWriter
+	Kerberos    *database.BulkWriter
+	NTLM        *database.BulkWriter
 }
 
 type DoneChans struct {
@@ -95,6 +119,11 @@ type DoneChans struct {
 	dns       chan struct{}
 	ssl       chan struct{}
 	openssl   chan struct{}
+	rdp       chan struct{}
+	ftp       chan struct{}
+	x509      chan struct{}
+	kerberos  chan struct{}
+	ntlm      chan struct{}
 }
 
 type ResultCounts struct {
@@ -103,17 +132,49 @@ type ResultCounts struct {
 	UnfilteredConn uint64
 	Conn           uint64
 	OpenConn       uint64
+	DuplicateConn  uint64
+	DuplicateOpen  uint64
+	SelfConn       uint64
+	SelfOpenConn   uint64
 	HTTP           uint64
 	OpenHTTP       uint64
 	DNS            uint64
+	TruncatedDNS   uint64
 	UDNS           int64
 	PDNSRaw        uint64
 	SSL            uint64
 	OpenSSL        uint64
+	RDP            uint64
+	FTP            uint64
+	X509           uint64
+	Kerberos       uint64
+	NTLM           uint64
+	ParseErrors    uint64
+	SkippedLines   uint64
+}
+
+// Records returns the number of records that were imported for each log type
+func (counts *ResultCounts) Records() map[string]uint64 {
+	return map[string]uint64{
+		ConnPrefix:     atomic.LoadUint64(&counts.Conn),
+		OpenConnPrefix: atomic.LoadUint64(&counts.OpenConn),
+		DNSPrefix:      atomic.LoadUint64(&counts.DNS),
+		"pdns":         atomic.LoadUint64(&counts.PDNSRaw),
+		HTTPPrefix:     atomic.LoadUint64(&counts.HTTP),
+		OpenHTTPPrefix: atomic.LoadUint64(&counts.OpenHTTP),
+		SSLPrefix:      atomic.LoadUint64(&counts.SSL),
+		OpenSSLPrefix:  atomic.LoadUint64(&counts.OpenSSL),
+		RDPPrefix:      atomic.LoadUint64(&counts.RDP),
+		FTPPrefix:      atomic.LoadUint64(&counts.FTP),
+		X509Prefix:     atomic.LoadUint64(&counts.X509),
+		KerberosPrefix: atomic.LoadUint64(&counts.Kerberos),
+		NTLMPrefix:     atomic.LoadUint64(&counts.NTLM),
+	}
 }
 
 type WaitGroups struct {
 	Digester sync.WaitGroup
+	Errors   sync.WaitGroup
 	MetaDB   sync.WaitGroup
 	OpenConn sync.WaitGroup
 	Conn     sync.WaitGroup
@@ -122,6 +183,11 @@ type WaitGroups struct {
 	OpenHTTP sync.WaitGroup
 	SSL      sync.WaitGroup
 	OpenSSL  sync.WaitGroup
+	RDP      sync.WaitGroup
+	FTP      sync.WaitGroup
+	X509     sync.WaitGroup
+	Kerberos sync.WaitGroup
+	NTLM     sync.WaitGroup
 }
 
 // NewImporter creates and returns a new Importer object
@@ -137,6 +203,11 @@ func NewImporter(db *database.DB, cfg *config.Config, importStartedAt time.Time,
 		OpenHTTP: make(chan zeektypes.HTTP, 1000),
 		SSL:      make(chan zeektypes.SSL, 1000),
 		OpenSSL:  make(chan zeektypes.SSL, 1000),
+		RDP:      make(chan zeektypes.RDP, 1000),
+		FTP:      make(chan zeektypes.FTP, 1000),
+		X509:     make(chan zeektypes.X509, 1000),
+		Kerberos: make(chan zeektypes.Kerberos, 1000),
+		NTLM:     make(chan zeektypes.NTLM, 1000),
 	}
 
 	// create channels to keep track of log files being successfully imported
@@ -149,6 +220,11 @@ func NewImporter(db *database.DB, cfg *config.Config, importStartedAt time.Time,
 		dns:       make(chan struct{}, numDigesters),
 		ssl:       make(chan struct{}, numDigesters),
 		openssl:   make(chan struct{}, numDigesters),
+		rdp:       make(chan struct{}, numDigesters),
+		ftp:       make(chan struct{}, numDigesters),
+		x509:      make(chan struct{}, numDigesters),
+		kerberos:  make(chan struct{}, numDigesters),
+		ntlm:      make(chan struct{}, numDigesters),
 	}
 
 	// create a rate limiter to control the rate of writing to the database
@@ -164,6 +240,11 @@ func NewImporter(db *database.DB, cfg *config.Config, importStartedAt time.Time,
 		OpenHTTPTmp: database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "openhttp_tmp", "INSERT INTO {database:Identifier}.openhttp_tmp", limiter, false),
 		SSLTmp:      database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "ssl_tmp", "INSERT INTO {database:Identifier}.ssl_tmp", limiter, false),
 		OpenSSLTmp:  database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "openssl_tmp", "INSERT INTO {database:Identifier}.openssl_tmp", limiter, false),
+		RDPTmp:      database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "rdp_tmp", "INSERT INTO {database:Identifier}.rdp_tmp", limiter, false),
+		FTP:         database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "ftp_proto", "INSERT INTO {database:Identifier}.ftp_proto", limiter, false),
+		X509:        database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "x509", "INSERT INTO {database:Identifier}.x509", limiter, false),
+		Kerberos:    database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "kerberos_proto", "INSERT INTO {database:Identifier}.kerberos_proto", limiter, false),
+		NTLM:        database.NewBulkWriter(db, cfg, numWriters, db.GetSelectedDB(), "ntlm_proto", "INSERT INTO {database:Identifier}.ntlm_proto", limiter, false),
 	}
 
 	// create progressBar bar
@@ -173,7 +254,7 @@ func NewImporter(db *database.DB, cfg *config.Config, importStartedAt time.Time,
 	db.ImportStartedAt = importStartedAt
 
 	// create a unique import id using the start time
-	importID, err := util.NewFixedStringHash(strconv.FormatInt(importStartedAt.UnixMicro(), 10))
+	importID, err := NewImportID(importStartedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -181,8 +262,8 @@ func NewImporter(db *database.DB, cfg *config.Config, importStartedAt time.Time,
 	// log the import id
 	logger.Debug().Str("import_id", importID.Hex()).Send()
 
-	// return the importer object
-	return &Importer{
+	// create the importer object
+	importer := &Importer{
 		Database: db,
 		Cfg:      cfg,
 		ImportID: importID,
@@ -206,21 +287,61 @@ func NewImporter(db *database.DB, cfg *config.Config, importStartedAt time.Time,
 		startWritersCallback:     logWriters.startWriters,
 		closeWritersCallback:     logWriters.closeWriters,
 		markFileImportedCallback: db.MarkFileImportedInMetaDB,
-	}, nil
+		recordDNSFloodsCallback:  db.AddDNSFloods,
+	}
+	importer.digestFileCallback = importer.digestFile
+
+	// track the uids of parsed connections so that logs split across zeek workers aren't imported twice
+	if cfg.DeduplicateConnUIDs {
+		importer.seenConnUIDs = newUIDSet()
+		importer.seenOpenConnUIDs = newUIDSet()
+	}
+
+	// cap the number of distinct fqdns tracked for each host so that massive DNS enumeration can't exhaust memory
+	if cfg.MaxFQDNsPerSrc > 0 {
+		importer.fqdnLimit = newFQDNLimiter(cfg.MaxFQDNsPerSrc)
+	}
+
+	// collect the IPs that internal domains resolve to so that the connections to them are classified as internal
+	if len(cfg.Filter.InternalDomains) > 0 {
+		importer.internalIPs = newInternalDomainIPs()
+	}
+
+	return importer, nil
+}
+
+// NewImportID returns the id of the import that started at importStartedAt
+func NewImportID(importStartedAt time.Time) (util.FixedString, error) {
+	return util.NewFixedStringHash(strconv.FormatInt(importStartedAt.UnixMicro(), 10))
 }
 
 func (importer *Importer) Import(afs afero.Fs, files map[string][]string) error {
-	logger := zlog.GetLogger()
+	logger := zlog.WithImport(importer.Database.GetSelectedDB(), importer.ImportID.Hex())
 
 	// record the hourlyImportStart time of this import chunk
 	hourlyImportStart := time.Now()
 
+	// checksum the files so that files that were modified since they were imported are imported again
+	importer.fileContents = checksumFiles(afs, files)
+
+	// count the files before the ones that were already imported are removed
+	walkedFileCount := 0
+	for _, paths := range files {
+		walkedFileCount += len(paths)
+	}
+
 	// check if files have already been imported make a map of the remaining files
-	totalFileCount, err := importer.validateLogFilesCallback(files)
+	totalFileCount, changedFiles, err := importer.validateLogFilesCallback(files, importer.fileContents)
 	if err != nil {
 		return err
 	}
 
+	// only the records that were appended to committed files are imported, the rest were imported already
+	importer.resumeOffsets = appendedFileOffsets(afs, changedFiles, importer.fileContents)
+
+	// files that were already imported won't be parsed, so they don't count towards the time left
+	importer.Progress.Skip(walkedFileCount - totalFileCount)
+
 	// verify that there are still files left to import and set file count
 	if totalFileCount < 1 {
 		return ErrAllFilesPreviouslyImported
@@ -236,7 +357,11 @@ func (importer *Importer) Import(afs afero.Fs, files map[string][]string) error
 		return err
 	}
 
-	// initialize progress bar
+	// initialize progress bar, the progress of the whole import is shown after this hour's count if it is reported
+	appendDecorators := []decor.Decorator{decor.CountersNoUnit("%d / %d")}
+	if progress := importer.Progress.decorator(); progress != nil {
+		appendDecorators = append(appendDecorators, progress)
+	}
 	importer.FileProgressBar = importer.ProgressBar.New(int64(importer.TotalFileCount),
 		mpb.BarStyle().Lbound("╢").Filler("▌").Tip("▌").Padding("░").Rbound("╟"),
 		mpb.PrependDecorators(
@@ -245,38 +370,66 @@ func (importer *Importer) Import(afs afero.Fs, files map[string][]string) error
 			// replace ETA decorator with "done" message, OnComplete event
 			decor.OnComplete(decor.Elapsed(decor.ET_STYLE_GO), "🎉"),
 		),
-		mpb.AppendDecorators(decor.CountersNoUnit("%d / %d")),
+		mpb.AppendDecorators(appendDecorators...),
 	)
 
 	// start the import
-	importer.process(afs)
+	if err := importer.process(afs); err != nil {
+		return err
+	}
+
+	// record the hosts whose dns queries were truncated so that they can be flagged during analysis
+	if err := importer.recordDNSFloods(); err != nil {
+		return err
+	}
 
 	// record import time to logger
 	hourlyImportEnd := time.Now()
-	logger.Info().Time("parsing_began", hourlyImportStart).Time("parsing_finished", hourlyImportEnd).Str("elapsed_time", time.Since(hourlyImportStart).String()).Msg("Finished Parsing Logs! 🎉")
+	if skipped := atomic.LoadUint64(&importer.ResultCounts.SkippedLines); skipped > 0 {
+		logger.Warn().Uint64("skipped_lines", skipped).Msg("Skipped malformed log lines")
+	}
+	logger.Info().Str("phase", "parse").Dur("duration", hourlyImportEnd.Sub(hourlyImportStart)).Time("parsing_began", hourlyImportStart).Time("parsing_finished", hourlyImportEnd).Str("elapsed_time", time.Since(hourlyImportStart).String()).Msg("Finished Parsing Logs! 🎉")
 
 	if err := importer.season(); err != nil {
 		return err
 	}
 	seasoningEnd := time.Now()
-	logger.Info().Time("seasoning_began", hourlyImportEnd).Time("seasoning_finished", seasoningEnd).Str("elapsed_time", time.Since(hourlyImportEnd).String()).Msg("Finished Seasoning Logs! 🎉")
+	logger.Info().Str("phase", "season").Dur("duration", seasoningEnd.Sub(hourlyImportEnd)).Time("seasoning_began", hourlyImportEnd).Time("seasoning_finished", seasoningEnd).Str("elapsed_time", time.Since(hourlyImportEnd).String()).Msg("Finished Seasoning Logs! 🎉")
 
 	// create formatter for adding commas in the counts
 	p := message.NewPrinter(language.English)
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.Conn)).Msg("Imported conn records")
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.OpenConn)).Msg("Imported open conn records")
+	if importer.Cfg.DeduplicateConnUIDs {
+		logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.DuplicateConn)).Msg("Skipped duplicate conn records")
+		logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.DuplicateOpen)).Msg("Skipped duplicate open conn records")
+	}
+	if importer.Cfg.Filter.FilterSelfConnections {
+		logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.SelfConn)).Msg("Filtered conn records from a host to itself")
+		logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.SelfOpenConn)).Msg("Filtered open conn records from a host to itself")
+	}
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.DNS)).Msg("Imported dns records")
+	if importer.fqdnLimit != nil {
+		logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.TruncatedDNS)).Msg("Skipped dns records over max_fqdns_per_src")
+	}
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.PDNSRaw)).Msg("Imported pdns raw records")
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.HTTP)).Msg("Imported http records")
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.OpenHTTP)).Msg("Imported open http records")
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.SSL)).Msg("Imported ssl records")
 	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.OpenSSL)).Msg("Imported open ssl records")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.RDP)).Msg("Imported rdp records")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.FTP)).Msg("Imported ftp records")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.X509)).Msg("Imported x509 records")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.Kerberos)).Msg("Imported kerberos records")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.NTLM)).Msg("Imported ntlm records")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.ParseErrors)).Msg("Encountered log parsing errors")
+	logger.Debug().Str("count", p.Sprintf("%d", importer.ResultCounts.SkippedLines)).Msg("Skipped malformed log lines")
 
 	return nil
 }
 
-// process loads the files and parses the raw log entries
-func (importer *Importer) process(afs afero.Fs) {
+// process loads the files and parses the raw log entries, returning an error if the parsed entries couldn't be written
+func (importer *Importer) process(afs afero.Fs) error {
 	// initialize writers
 	importer.startWritersCallback(importer.NumWriters)
 
@@ -305,6 +458,11 @@ func (importer *Importer) process(afs afero.Fs) {
 		close(importer.EntryChannels.OpenHTTP)
 		close(importer.EntryChannels.SSL)
 		close(importer.EntryChannels.OpenSSL)
+		close(importer.EntryChannels.RDP)
+		close(importer.EntryChannels.FTP)
+		close(importer.EntryChannels.X509)
+		close(importer.EntryChannels.Kerberos)
+		close(importer.EntryChannels.NTLM)
 
 		// close paths channel
 		close(importer.Paths)
@@ -320,6 +478,11 @@ func (importer *Importer) process(afs afero.Fs) {
 	importer.wg.OpenHTTP.Wait()
 	importer.wg.SSL.Wait()
 	importer.wg.OpenSSL.Wait()
+	importer.wg.RDP.Wait()
+	importer.wg.FTP.Wait()
+	importer.wg.X509.Wait()
+	importer.wg.Kerberos.Wait()
+	importer.wg.NTLM.Wait()
 
 	close(importer.DoneChannels.conn)
 	close(importer.DoneChannels.openconn)
@@ -328,12 +491,18 @@ func (importer *Importer) process(afs afero.Fs) {
 	close(importer.DoneChannels.ssl)
 	close(importer.DoneChannels.openssl)
 	close(importer.DoneChannels.dns)
+	close(importer.DoneChannels.rdp)
+	close(importer.DoneChannels.ftp)
+	close(importer.DoneChannels.x509)
+	close(importer.DoneChannels.kerberos)
+	close(importer.DoneChannels.ntlm)
 	close(importer.DoneChannels.filesDone)
 
 	close(importer.ErrChannel)
+	importer.wg.Errors.Wait()
 
 	// close writers
-	importer.closeWritersCallback()
+	return importer.closeWritersCallback()
 }
 
 // startParseRoutines starts a fixed number of goroutines to parse lines from logs into data to be written to the db.
@@ -345,21 +514,26 @@ func (importer *Importer) startParseRoutines() {
 	importer.wg.OpenHTTP.Add(importer.NumParsers)
 	importer.wg.SSL.Add(importer.NumParsers)
 	importer.wg.OpenSSL.Add(importer.NumParsers)
+	importer.wg.RDP.Add(importer.NumParsers)
+	importer.wg.FTP.Add(importer.NumParsers)
+	importer.wg.X509.Add(importer.NumParsers)
+	importer.wg.Kerberos.Add(importer.NumParsers)
+	importer.wg.NTLM.Add(importer.NumParsers)
 
 	for i := 0; i < importer.NumParsers; i++ {
 		go func(_ int) {
 			// parseConn(importer.EntryChannels.Conn, importer.Writers.Conn.WriteChannel, importer.UniqueMaps.Uconn, importer.UniqueMaps.ZeekUIDs, importer.ImportID, &importer.ResultCounts.Conn)
-			parseConn(importer.Cfg, importer.EntryChannels.Conn, importer.Writers.ConnTmp.WriteChannel, importer.ImportID, importer.Database.ImportStartedAt, &importer.ResultCounts.Conn)
+			parseConn(importer.Cfg, importer.EntryChannels.Conn, importer.Writers.ConnTmp.WriteChannel, importer.ImportID, importer.Database.ImportStartedAt, importer.LogDirectory, false, importer.seenConnUIDs, &importer.ResultCounts.Conn, &importer.ResultCounts.DuplicateConn, &importer.ResultCounts.SelfConn)
 			importer.wg.Conn.Done()
 		}(i)
 		go func(_ int) {
 			// parseConn(importer.EntryChannels.OpenConn, importer.Writers.OpenConn.WriteChannel, importer.UniqueMaps.OpenConn, importer.UniqueMaps.OpenZeekUIDs, importer.ImportID, &importer.ResultCounts.OpenConn)
-			parseConn(importer.Cfg, importer.EntryChannels.OpenConn, importer.Writers.OpenConnTmp.WriteChannel, importer.ImportID, importer.Database.ImportStartedAt, &importer.ResultCounts.OpenConn)
+			parseConn(importer.Cfg, importer.EntryChannels.OpenConn, importer.Writers.OpenConnTmp.WriteChannel, importer.ImportID, importer.Database.ImportStartedAt, importer.LogDirectory, true, importer.seenOpenConnUIDs, &importer.ResultCounts.OpenConn, &importer.ResultCounts.DuplicateOpen, &importer.ResultCounts.SelfOpenConn)
 			importer.wg.OpenConn.Done()
 		}(i)
 
 		go func(_ int) {
-			parseDNS(importer.Cfg, importer.EntryChannels.DNS, importer.Writers.DNS.WriteChannel, importer.Writers.PDNS.WriteChannel, &importer.ResultCounts.DNS, &importer.ResultCounts.PDNSRaw, importer.Database.ImportStartedAt)
+			parseDNS(importer.Cfg, importer.EntryChannels.DNS, importer.Writers.DNS.WriteChannel, importer.Writers.PDNS.WriteChannel, importer.fqdnLimit, importer.internalIPs, &importer.ResultCounts.DNS, &importer.ResultCounts.PDNSRaw, &importer.ResultCounts.TruncatedDNS, importer.Database.ImportStartedAt)
 			importer.wg.DNS.Done()
 		}(i)
 
@@ -382,27 +556,114 @@ func (importer *Importer) startParseRoutines() {
 			parseSSL(importer.Cfg, importer.EntryChannels.OpenSSL, importer.Writers.OpenSSLTmp.WriteChannel, importer.Database.ImportStartedAt, &importer.ResultCounts.OpenSSL)
 			importer.wg.OpenSSL.Done()
 		}(i)
+
+		go func(_ int) {
+			parseRDP(importer.Cfg, importer.EntryChannels.RDP, importer.Writers.RDPTmp.WriteChannel, importer.Database.ImportStartedAt, &importer.ResultCounts.RDP)
+			importer.wg.RDP.Done()
+		}(i)
+
+		go func(_ int) {
+			parseFTP(importer.Cfg, importer.EntryChannels.FTP, importer.Writers.FTP.WriteChannel, importer.Database.ImportStartedAt, importer.LogDirectory, &importer.ResultCounts.FTP)
+			importer.wg.FTP.Done()
+		}(i)
+
+		go func(_ int) {
+			parseX509(importer.EntryChannels.X509, importer.Writers.X509.WriteChannel, importer.Database.ImportStartedAt, importer.LogDirectory, &importer.ResultCounts.X509)
+			importer.wg.X509.Done()
+		}(i)
+
+		go func(_ int) {
+			parseKerberos(importer.Cfg, importer.EntryChannels.Kerberos, importer.Writers.Kerberos.WriteChannel, importer.Database.ImportStartedAt, importer.LogDirectory, &importer.ResultCounts.Kerberos)
+			importer.wg.Kerberos.Done()
+		}(i)
+
+		go func(_ int) {
+			parseNTLM(importer.Cfg, importer.EntryChannels.NTLM, importer.Writers.NTLM.WriteChannel, importer.Database.ImportStartedAt, importer.LogDirectory, &importer.ResultCounts.NTLM)
+			importer.wg.NTLM.Done()
+		}(i)
 	}
 }
 
 // startDigesters starts a fixed number of goroutines to read and digest files.
 func (importer *Importer) startDigesters(afs afero.Fs) {
+	// read entries from err channel, handle specific errors if necessary
+	// currently, this err channel is primarily used for checking errors in tests and counting parse errors
+	importer.wg.Errors.Add(1)
+	go func() {
+		for err := range importer.ErrChannel {
+			atomic.AddUint64(&importer.ResultCounts.ParseErrors, 1)
+			if errors.Is(err, errMalformedLine) {
+				atomic.AddUint64(&importer.ResultCounts.SkippedLines, 1)
+			}
+		}
+		importer.wg.Errors.Done()
+	}()
+
+	// the number of digesters limits how many files are parsed at the same time
 	importer.wg.Digester.Add(importer.NumDigesters)
 	for i := 0; i < importer.NumDigesters; i++ {
 		go func(_ int) {
-			digester(afs, importer.DoneChannels, importer.Paths, importer.ErrChannel, importer.EntryChannels, importer.MetaDBChannel, importer.Database.GetSelectedDB(), importer.ImportID, importer.ProgressLogger)
+			importer.digester(afs)
 			importer.wg.Digester.Done()
 		}(i)
 	}
 }
 
+// checksumFiles returns the checksum and size of the contents of each file in the file map, keyed by path.
+// Files that can't be checksummed are left out and are treated as unchanged if they were already imported.
+func checksumFiles(afs afero.Fs, files map[string][]string) map[string]database.FileContents {
+	logger := zlog.GetLogger()
+
+	contents := make(map[string]database.FileContents)
+	for _, paths := range files {
+		for _, path := range paths {
+			// the checksum covers the size that the file had when it was checked, in case it is still growing
+			info, err := afs.Stat(path)
+			if err != nil {
+				logger.Debug().Err(err).Str("path", path).Msg("could not checksum file")
+				continue
+			}
+			checksum, err := util.FilePrefixChecksum(afs, path, info.Size())
+			if err != nil {
+				logger.Debug().Err(err).Str("path", path).Msg("could not checksum file")
+				continue
+			}
+			contents[path] = database.FileContents{Checksum: checksum, Size: info.Size()}
+		}
+	}
+	return contents
+}
+
+// appendedFileOffsets returns the size that each of the changed files had when it was committed, keyed by path, for
+// the files that were only appended to since then. Those files are imported from that offset so that the records that
+// were already imported aren't imported twice. Files that were rewritten, or that are compressed, are imported again
+// in full, and the records from their earlier import are kept.
+func appendedFileOffsets(afs afero.Fs, changedFiles map[string]database.FileContents, contents map[string]database.FileContents) map[string]int64 {
+	logger := zlog.GetLogger()
+
+	offsets := make(map[string]int64)
+	for path, committed := range changedFiles {
+		compressed := strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".bz2")
+		if !compressed && committed.Size > 0 && contents[path].Size > committed.Size {
+			prefix, err := util.FilePrefixChecksum(afs, path, committed.Size)
+			if err == nil && prefix == committed.Checksum {
+				logger.Info().Str("path", path).Int64("offset", committed.Size).Msg("file grew since it was last imported, importing the records that were appended to it")
+				offsets[path] = committed.Size
+				continue
+			}
+		}
+		logger.Warn().Str("path", path).Msg("file was modified since it was last imported, importing it again")
+	}
+	return offsets
+}
+
 // startMetaDBFileTracker starts a goroutine to mark files as imported in MetaDB
 func (importer *Importer) startMetaDBFileTracker() {
 
 	importer.wg.MetaDB.Add(1)
 	go func() {
 		for metaDB := range importer.MetaDBChannel {
-			err := importer.markFileImportedCallback(metaDB.fileHash, metaDB.importID, metaDB.path)
+			err := importer.markFileImportedCallback(metaDB.fileHash, metaDB.importID, metaDB.path, importer.fileContents[metaDB.path])
 			if err != nil {
 				importer.ProgressLogger.Println("[WARNING] could not mark file as imported, path:", metaDB.path, err)
 			}
@@ -428,6 +689,11 @@ func (importer *Importer) feedAndListenForFileCompletion() {
 			case <-importer.DoneChannels.ssl:
 			case <-importer.DoneChannels.openssl:
 			case <-importer.DoneChannels.dns:
+			case <-importer.DoneChannels.rdp:
+			case <-importer.DoneChannels.ftp:
+			case <-importer.DoneChannels.x509:
+			case <-importer.DoneChannels.kerberos:
+			case <-importer.DoneChannels.ntlm:
 
 			// increment progress bar
 			case <-importer.DoneChannels.filesDone:
@@ -448,6 +714,9 @@ func (importer *Importer) feedAndListenForFileCompletion() {
 		for _, sslLog := range importer.FileMap[SSLPrefix] {
 			importer.Paths <- sslLog
 		}
+		for _, rdpLog := range importer.FileMap[RDPPrefix] {
+			importer.Paths <- rdpLog
+		}
 	}
 	if len(importer.FileMap[OpenConnPrefix]) > 0 {
 		for _, openConnLog := range importer.FileMap[OpenConnPrefix] {
@@ -463,47 +732,72 @@ func (importer *Importer) feedAndListenForFileCompletion() {
 	for _, dnsLog := range importer.FileMap[DNSPrefix] {
 		importer.Paths <- dnsLog
 	}
+	for _, ftpLog := range importer.FileMap[FTPPrefix] {
+		importer.Paths <- ftpLog
+	}
+	for _, x509Log := range importer.FileMap[X509Prefix] {
+		importer.Paths <- x509Log
+	}
+	for _, kerberosLog := range importer.FileMap[KerberosPrefix] {
+		importer.Paths <- kerberosLog
+	}
+	for _, ntlmLog := range importer.FileMap[NTLMPrefix] {
+		importer.Paths <- ntlmLog
+	}
 }
 
-// digester loops over the paths, checks the file prefix, and sends each path to the parser with its corresponding entryChannel until either paths or done is closed.
-func digester(afs afero.Fs, done DoneChans, paths <-chan string, errc chan error, entryChannels EntryChans, metaDBChan chan<- MetaDBFile, dbName string, importID util.FixedString, progressLogger *log.Logger) {
-	// errc := make(chan error)
-
-	// read entries from err channel, handle specific errors if necessary
-	// currently, this err channel is primarily used for checking errors in tests
-	go func() {
-		for err := range errc {
-			_ = err
-		}
-	}()
+// digester loops over the paths and digests each file, sending a done signal for each completed file until paths is closed.
+func (importer *Importer) digester(afs afero.Fs) {
+	for path := range importer.Paths {
+		importer.ProgressLogger.Println("[-] Parsing: ", path)
+		importer.digestFileCallback(afs, path)
+		importer.Progress.FileDone()
+		importer.DoneChannels.filesDone <- struct{}{}
+	}
+}
 
-	// loop over paths and send to parseFiles with the correct corresponding entryChannels, sending a done signal for each completed file
-	for path := range paths {
-		progressLogger.Println("[-] Parsing: ", path)
-		switch {
-		case strings.HasPrefix(filepath.Base(path), ConnPrefix):
-			parseFile(afs, path, entryChannels.Conn, errc, metaDBChan, dbName, importID)
-			done.conn <- struct{}{}
-		case strings.HasPrefix(filepath.Base(path), OpenConnPrefix):
-			parseFile(afs, path, entryChannels.OpenConn, errc, metaDBChan, dbName, importID)
-			done.openconn <- struct{}{}
-		case strings.HasPrefix(filepath.Base(path), DNSPrefix):
-			parseFile(afs, path, entryChannels.DNS, errc, metaDBChan, dbName, importID)
-			done.dns <- struct{}{}
-		case strings.HasPrefix(filepath.Base(path), HTTPPrefix):
-			parseFile(afs, path, entryChannels.HTTP, errc, metaDBChan, dbName, importID)
-			done.http <- struct{}{}
-		case strings.HasPrefix(filepath.Base(path), OpenHTTPPrefix):
-			parseFile(afs, path, entryChannels.OpenHTTP, errc, metaDBChan, dbName, importID)
-			done.openhttp <- struct{}{}
-		case strings.HasPrefix(filepath.Base(path), SSLPrefix):
-			parseFile(afs, path, entryChannels.SSL, errc, metaDBChan, dbName, importID)
-			done.ssl <- struct{}{}
-		case strings.HasPrefix(filepath.Base(path), OpenSSLPrefix):
-			parseFile(afs, path, entryChannels.OpenSSL, errc, metaDBChan, dbName, importID)
-			done.openssl <- struct{}{}
-		}
-		done.filesDone <- struct{}{}
+// digestFile checks the file prefix and sends the path to the parser with its corresponding entryChannel
+func (importer *Importer) digestFile(afs afero.Fs, path string) {
+	dbName := importer.Database.GetSelectedDB()
+	offset := importer.resumeOffsets[path]
+
+	switch {
+	case strings.HasPrefix(filepath.Base(path), ConnPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.Conn, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.conn <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), OpenConnPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.OpenConn, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.openconn <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), DNSPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.DNS, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.dns <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), HTTPPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.HTTP, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.http <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), OpenHTTPPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.OpenHTTP, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.openhttp <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), SSLPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.SSL, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.ssl <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), OpenSSLPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.OpenSSL, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.openssl <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), RDPPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.RDP, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.rdp <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), FTPPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.FTP, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.ftp <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), X509Prefix):
+		parseFile(afs, path, offset, importer.EntryChannels.X509, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.x509 <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), KerberosPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.Kerberos, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.kerberos <- struct{}{}
+	case strings.HasPrefix(filepath.Base(path), NTLMPrefix):
+		parseFile(afs, path, offset, importer.EntryChannels.NTLM, importer.ErrChannel, importer.MetaDBChannel, dbName, importer.ImportID)
+		importer.DoneChannels.ntlm <- struct{}{}
 	}
 }
 
@@ -518,22 +812,60 @@ func (writer *writers) startWriters(numWriters int) {
 		writer.OpenHTTPTmp.Start(i)
 		writer.SSLTmp.Start(i)
 		writer.OpenSSLTmp.Start(i)
+		writer.RDPTmp.Start(i)
+		writer.FTP.Start(i)
+		writer.X509.Start(i)
+		writer.Kerberos.Start(i)
+		writer.NTLM.Start(i)
 	}
 }
 
-// closeWriters close each writer
-func (writer *writers) closeWriters() {
-	writer.ConnTmp.Close()
-	writer.OpenConnTmp.Close()
-	writer.DNS.Close()
-	writer.PDNS.Close()
-	writer.HTTPTmp.Close()
-	writer.OpenHTTPTmp.Close()
-	writer.SSLTmp.Close()
-	writer.OpenSSLTmp.Close()
+// closeWriters close each writer, returning the errors of any writers that failed
+func (writer *writers) closeWriters() error {
+	return errors.Join(
+		writer.ConnTmp.Close(),
+		writer.OpenConnTmp.Close(),
+		writer.DNS.Close(),
+		writer.PDNS.Close(),
+		writer.HTTPTmp.Close(),
+		writer.OpenHTTPTmp.Close(),
+		writer.SSLTmp.Close(),
+		writer.OpenSSLTmp.Close(),
+		writer.RDPTmp.Close(),
+		writer.FTP.Close(),
+		writer.X509.Close(),
+		writer.Kerberos.Close(),
+		writer.NTLM.Close(),
+	)
+}
+
+// recordDNSFloods logs and stores the hosts that queried more than max_fqdns_per_src distinct fqdns during the import
+func (importer *Importer) recordDNSFloods() error {
+	if importer.fqdnLimit == nil {
+		return nil
+	}
+
+	floods := importer.fqdnLimit.floods()
+	if len(floods) == 0 {
+		return nil
+	}
+
+	logger := zlog.WithImport(importer.Database.GetSelectedDB(), importer.ImportID.Hex())
+	for i := range floods {
+		floods[i].ImportTime = importer.Database.ImportStartedAt
+		floods[i].ImportID = importer.ImportID
+		logger.Warn().Str("src", floods[i].Src.String()).Int("max_fqdns_per_src", importer.Cfg.MaxFQDNsPerSrc).
+			Uint64("skipped_records", floods[i].DroppedCount).
+			Msg("Host queried more distinct domains than max_fqdns_per_src, its queries for new domains were skipped")
+	}
+
+	if err := importer.recordDNSFloodsCallback(floods); err != nil {
+		return fmt.Errorf("could not record hosts over max_fqdns_per_src: %w", err)
+	}
+	return nil
 }
 
-// season links the http & ssl logs with the conn logs and adds data to those connections
+// season links the http, ssl & rdp logs with the conn logs and adds data to those connections
 func (importer *Importer) season() error {
 	logger := zlog.GetLogger()
 
@@ -570,6 +902,7 @@ func (importer *Importer) season() error {
 		openHTTPID
 		sslID
 		openSSLID
+		rdpID
 	)
 
 	if importer.ResultCounts.OpenConn > 0 {
@@ -588,6 +921,9 @@ func (importer *Importer) season() error {
 	barList = append(barList,
 		progressbar.NewBar(sslBarName, sslID, progress.New(gradient)),
 		progressbar.NewBar(httpBarName, httpID, progress.New(gradient)))
+	if importer.ResultCounts.RDP > 0 {
+		barList = append(barList, progressbar.NewBar("🧂 Seasoning RDP connections ", rdpID, progress.New(gradient)))
+	}
 	spinners = append(spinners, progressbar.NewSpinner("Sifting IP connections...", connSpinnerID))
 	bars := progressbar.New(ctx, barList, spinners)
 
@@ -624,6 +960,16 @@ func (importer *Importer) season() error {
 		return err
 	})
 
+	if importer.ResultCounts.RDP > 0 {
+		linkingErrGroup.Go(func() error {
+			err := importer.writeLinkedRDP(ctx, bars, rdpID)
+			if err != nil {
+				logger.Error().Err(err).Msg("unable to link rdp connections")
+			}
+			return err
+		})
+	}
+
 	if importer.ResultCounts.OpenConn > 0 {
 		linkingErrGroup.Go(func() error {
 			err := importer.writeUnfilteredConns(bars, true, openConnSpinnerID)
@@ -656,12 +1002,16 @@ func (importer *Importer) season() error {
 		return fmt.Errorf("could not perform connection linking: %w", err)
 	}
 
-	sslWriter.Close()
-	openSSLWriter.Close()
-	httpWriter.Close()
-	openHTTPWriter.Close()
-	connWriter.Close()
-	openConnWriter.Close()
+	if err := errors.Join(
+		sslWriter.Close(),
+		openSSLWriter.Close(),
+		httpWriter.Close(),
+		openHTTPWriter.Close(),
+		connWriter.Close(),
+		openConnWriter.Close(),
+	); err != nil {
+		return fmt.Errorf("could not write linked connections: %w", err)
+	}
 
 	// // don't truncate tmp tables in debug mode
 	// // these tables should be truncated before each import
@@ -0,0 +1,135 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartDigestersBoundsConcurrency(t *testing.T) {
+	totalFiles := 20
+
+	for _, limit := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("Limit %d", limit), func(t *testing.T) {
+			importer := &Importer{
+				Paths:          make(chan string),
+				ErrChannel:     make(chan error),
+				DoneChannels:   DoneChans{filesDone: make(chan struct{})},
+				ProgressLogger: log.New(io.Discard, "", 0),
+				NumDigesters:   limit,
+			}
+
+			// track how many files are being digested at the same time
+			var inFlight, maxInFlight atomic.Int32
+			importer.digestFileCallback = func(_ afero.Fs, _ string) {
+				current := inFlight.Add(1)
+				for {
+					highest := maxInFlight.Load()
+					if current <= highest || maxInFlight.CompareAndSwap(highest, current) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				inFlight.Add(-1)
+			}
+
+			importer.startDigesters(afero.NewMemMapFs())
+
+			go func() {
+				for i := 0; i < totalFiles; i++ {
+					importer.Paths <- fmt.Sprintf("conn.%02d:00:00-%02d:00:00.log", i, i+1)
+				}
+				close(importer.Paths)
+			}()
+
+			for i := 0; i < totalFiles; i++ {
+				<-importer.DoneChannels.filesDone
+			}
+			importer.wg.Digester.Wait()
+			close(importer.ErrChannel)
+
+			require.LessOrEqual(t, int(maxInFlight.Load()), limit, "no more than the digester limit should be parsed at once")
+			require.Positive(t, maxInFlight.Load(), "files should have been digested")
+		})
+	}
+}
+
+func TestSingleDigesterKeepsFileOrder(t *testing.T) {
+	importer := &Importer{
+		Paths:          make(chan string),
+		ErrChannel:     make(chan error),
+		DoneChannels:   DoneChans{filesDone: make(chan struct{})},
+		ProgressLogger: log.New(io.Discard, "", 0),
+		NumDigesters:   1,
+	}
+
+	// record the order that the files are digested in
+	var digested []string
+	importer.digestFileCallback = func(_ afero.Fs, path string) {
+		digested = append(digested, path)
+	}
+
+	importer.startDigesters(afero.NewMemMapFs())
+
+	var fed []string
+	for i := 0; i < 24; i++ {
+		fed = append(fed, fmt.Sprintf("conn.%02d:00:00-%02d:00:00.log", i, i+1))
+	}
+	go func() {
+		for _, path := range fed {
+			importer.Paths <- path
+		}
+		close(importer.Paths)
+	}()
+
+	for range fed {
+		<-importer.DoneChannels.filesDone
+	}
+	importer.wg.Digester.Wait()
+	close(importer.ErrChannel)
+
+	require.Equal(t, fed, digested, "a single digester should parse the files in the order they were fed")
+}
+
+func TestAppendedFileOffsets(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	afs := afero.NewMemMapFs()
+	original := []byte("#separator \\x09\n1715640000.000001\tC1\t10.0.0.1\n")
+
+	committed := func(path string) database.FileContents {
+		require.NoError(t, afero.WriteFile(afs, path, original, 0o644))
+		checksum, err := util.FileChecksum(afs, path)
+		require.NoError(t, err)
+		return database.FileContents{Checksum: checksum, Size: int64(len(original))}
+	}
+	changedFiles := map[string]database.FileContents{
+		"/logs/appended.log":      committed("/logs/appended.log"),
+		"/logs/rewritten.log":     committed("/logs/rewritten.log"),
+		"/logs/compressed.log.gz": committed("/logs/compressed.log.gz"),
+	}
+
+	appended := append(append([]byte{}, original...), "1715640001.000001\tC2\t10.0.0.1\n"...)
+	rewritten := append([]byte("#separator \\x09\n1715640000.000001\tC9\t10.0.0.1\n"), "1715640001.000001\tC2\t10.0.0.1\n"...)
+	require.NoError(t, afero.WriteFile(afs, "/logs/appended.log", appended, 0o644))
+	require.NoError(t, afero.WriteFile(afs, "/logs/rewritten.log", rewritten, 0o644))
+	require.NoError(t, afero.WriteFile(afs, "/logs/compressed.log.gz", appended, 0o644))
+
+	contents := checksumFiles(afs, map[string][]string{
+		ConnPrefix: {"/logs/appended.log", "/logs/rewritten.log", "/logs/compressed.log.gz"},
+	})
+
+	offsets := appendedFileOffsets(afs, changedFiles, contents)
+	require.Equal(t, map[string]int64{"/logs/appended.log": int64(len(original))}, offsets, "only files that were appended to should be imported from where their earlier import ended")
+}
@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/google/uuid"
+)
+
+var errMissingKerberosRequestType = "blank or missing request type field in kerberos log entry, skipping entry"
+
+type KerberosEntry struct {
+	ImportTime  time.Time        `ch:"import_time"`
+	ZeekUID     util.FixedString `ch:"zeek_uid"`
+	Hash        util.FixedString `ch:"hash"`
+	Timestamp   time.Time        `ch:"ts"`
+	Src         net.IP           `ch:"src"`
+	Dst         net.IP           `ch:"dst"`
+	SrcNUID     uuid.UUID        `ch:"src_nuid"`
+	DstNUID     uuid.UUID        `ch:"dst_nuid"`
+	SrcPort     uint16           `ch:"src_port"`
+	DstPort     uint16           `ch:"dst_port"`
+	SrcLocal    bool             `ch:"src_local"`
+	DstLocal    bool             `ch:"dst_local"`
+	RequestType string           `ch:"request_type"`
+	Client      string           `ch:"client"`
+	Service     string           `ch:"service"`
+	Success     bool             `ch:"success"`
+	ErrorMsg    string           `ch:"error_msg"`
+	Cipher      string           `ch:"cipher"`
+	WeakCipher  bool             `ch:"weak_cipher"`
+	Sensor      string           `ch:"sensor"`
+}
+
+// parseKerberos listens on a channel of raw kerberos log records, formats them and sends them to be written to the database
+func parseKerberos(cfg *config.Config, kerberos <-chan zeektypes.Kerberos, output chan<- database.Data, importTime time.Time, logDir string, numKerberos *uint64) {
+	logger := zlog.GetLogger()
+
+	// loop over raw kerberos channel
+	for k := range kerberos {
+
+		// parse raw record as a kerberos entry
+		entry, err := formatKerberosRecord(cfg, &k, importTime)
+		if err != nil {
+			logger.Debug().Err(err).
+				Str("log_path", k.LogPath).
+				Str("zeek_uid", k.UID).
+				Str("timestamp", (time.Unix(int64(k.TimeStamp), 0)).String()).
+				Str("src", k.Source).
+				Str("dst", k.Destination).
+				Send()
+			continue
+		}
+
+		// entry was subject to filtering
+		if entry == nil {
+			continue
+		}
+
+		// record which sensor this request was seen by
+		entry.Sensor = ParseSensor(logDir, k.LogPath)
+
+		output <- entry
+		// increment record counter
+		atomic.AddUint64(numKerberos, 1)
+	}
+}
+
+// formatKerberosRecord takes a raw kerberos record and formats it into the structure needed by the database
+func formatKerberosRecord(cfg *config.Config, parseKerberos *zeektypes.Kerberos, importTime time.Time) (*KerberosEntry, error) {
+
+	// parse source and destination
+	srcIP := net.ParseIP(parseKerberos.Source)
+	dstIP := net.ParseIP(parseKerberos.Destination)
+
+	// verify that both addresses were parsed successfully
+	if (srcIP == nil) || (dstIP == nil) {
+		return nil, errors.New(errParseSrcDst)
+	}
+
+	// verify that the request type is set
+	if parseKerberos.RequestType == "" {
+		return nil, errors.New(errMissingKerberosRequestType)
+	}
+
+	// kerberos uses its own pair filter since most requests are made to internal domain controllers.
+	// The port filter isn't applied since kerberos is carried over both tcp and udp, and the log doesn't record which.
+	if cfg.Filter.FilterKerberosPair(srcIP, dstIP) {
+		return nil, nil
+	}
+
+	srcNUID := util.ParseNetworkID(srcIP, parseKerberos.AgentUUID)
+	dstNUID := util.ParseNetworkID(dstIP, parseKerberos.AgentUUID)
+
+	zeekUID, err := util.NewFixedStringHash(parseKerberos.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same hash as the unique connection for this pair
+	hash, err := util.NewBeaconHash("ip", util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID})
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &KerberosEntry{
+		ImportTime:  importTime,
+		ZeekUID:     zeekUID,
+		Hash:        hash,
+		Timestamp:   time.Unix(int64(parseKerberos.TimeStamp), 0),
+		Src:         srcIP,
+		Dst:         dstIP,
+		SrcNUID:     srcNUID,
+		DstNUID:     dstNUID,
+		SrcPort:     uint16(parseKerberos.SourcePort),
+		DstPort:     uint16(parseKerberos.DestinationPort),
+		SrcLocal:    cfg.Filter.CheckIfInternal(srcIP),
+		DstLocal:    cfg.Filter.CheckIfInternal(dstIP),
+		RequestType: strings.ToUpper(parseKerberos.RequestType),
+		Client:      parseKerberos.Client,
+		Service:     parseKerberos.Service,
+		Success:     parseKerberos.Success,
+		ErrorMsg:    parseKerberos.ErrorMsg,
+		Cipher:      strings.ToLower(parseKerberos.Cipher),
+		WeakCipher:  IsWeakKerberosCipher(parseKerberos.Cipher),
+	}
+
+	return entry, nil
+}
+
+// IsWeakKerberosCipher returns whether the encryption type of a kerberos ticket is one of the legacy RC4 or DES
+// encryption types, which are requested by attacks such as kerberoasting since their tickets are easier to crack
+func IsWeakKerberosCipher(cipher string) bool {
+	cipher = strings.ToLower(cipher)
+	return strings.HasPrefix(cipher, "rc4-") || strings.HasPrefix(cipher, "des-") || strings.HasPrefix(cipher, "des3-")
+}
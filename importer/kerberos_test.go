@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	"github.com/joho/godotenv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatKerberosRecord(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	importTime := time.Unix(1713500000, 0)
+
+	request := zeektypes.Kerberos{
+		TimeStamp:       1713499000,
+		UID:             "CKRB1",
+		Source:          "10.0.0.5",
+		SourcePort:      51234,
+		Destination:     "10.0.0.2",
+		DestinationPort: 88,
+		RequestType:     "tgs",
+		Client:          "jdoe/CORP.EXAMPLE.COM",
+		Service:         "MSSQLSvc/sql01.corp.example.com:1433",
+		Success:         true,
+		Cipher:          "RC4-HMAC",
+	}
+
+	t.Run("Internal Request", func(t *testing.T) {
+		entry, err := formatKerberosRecord(&cfg, &request, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, entry, "internal to internal kerberos requests should not be filtered")
+
+		require.Equal(t, "10.0.0.5", entry.Src.String())
+		require.Equal(t, "10.0.0.2", entry.Dst.String())
+		require.Equal(t, uint16(88), entry.DstPort)
+		require.True(t, entry.SrcLocal)
+		require.True(t, entry.DstLocal)
+		require.Equal(t, "TGS", entry.RequestType, "request types should be normalized to uppercase")
+		require.Equal(t, "MSSQLSvc/sql01.corp.example.com:1433", entry.Service)
+		require.True(t, entry.Success)
+		require.Equal(t, "rc4-hmac", entry.Cipher, "ciphers should be normalized to lowercase")
+		require.True(t, entry.WeakCipher)
+		require.Equal(t, time.Unix(1713499000, 0), entry.Timestamp)
+		require.Equal(t, importTime, entry.ImportTime)
+	})
+
+	t.Run("External to External", func(t *testing.T) {
+		record := request
+		record.Source = "1.1.1.1"
+		record.Destination = "8.8.8.8"
+		entry, err := formatKerberosRecord(&cfg, &record, importTime)
+		require.NoError(t, err)
+		require.Nil(t, entry)
+	})
+
+	t.Run("Missing Request Type", func(t *testing.T) {
+		record := request
+		record.RequestType = ""
+		entry, err := formatKerberosRecord(&cfg, &record, importTime)
+		require.Error(t, err)
+		require.Nil(t, entry)
+	})
+
+	t.Run("Invalid Address", func(t *testing.T) {
+		record := request
+		record.Source = "not an ip"
+		entry, err := formatKerberosRecord(&cfg, &record, importTime)
+		require.Error(t, err)
+		require.Nil(t, entry)
+	})
+}
+
+func TestIsWeakKerberosCipher(t *testing.T) {
+	require.True(t, IsWeakKerberosCipher("rc4-hmac"))
+	require.True(t, IsWeakKerberosCipher("RC4-HMAC-EXP"))
+	require.True(t, IsWeakKerberosCipher("des-cbc-md5"))
+	require.True(t, IsWeakKerberosCipher("des3-cbc-sha1"))
+	require.False(t, IsWeakKerberosCipher("aes256-cts-hmac-sha1-96"))
+	require.False(t, IsWeakKerberosCipher("aes128-cts-hmac-sha256-128"))
+	require.False(t, IsWeakKerberosCipher(""))
+}
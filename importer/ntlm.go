@@ -0,0 +1,132 @@
+package importer
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/google/uuid"
+)
+
+var errMissingNTLMUsername = "blank or missing username field in ntlm log entry, skipping entry"
+
+type NTLMEntry struct {
+	ImportTime time.Time        `ch:"import_time"`
+	ZeekUID    util.FixedString `ch:"zeek_uid"`
+	Hash       util.FixedString `ch:"hash"`
+	Timestamp  time.Time        `ch:"ts"`
+	Src        net.IP           `ch:"src"`
+	Dst        net.IP           `ch:"dst"`
+	SrcNUID    uuid.UUID        `ch:"src_nuid"`
+	DstNUID    uuid.UUID        `ch:"dst_nuid"`
+	SrcPort    uint16           `ch:"src_port"`
+	DstPort    uint16           `ch:"dst_port"`
+	SrcLocal   bool             `ch:"src_local"`
+	DstLocal   bool             `ch:"dst_local"`
+	Domain     string           `ch:"domain"`
+	Username   string           `ch:"username"`
+	Hostname   string           `ch:"hostname"`
+	Success    bool             `ch:"success"`
+	Sensor     string           `ch:"sensor"`
+}
+
+// parseNTLM listens on a channel of raw ntlm log records, formats them and sends them to be written to the database
+func parseNTLM(cfg *config.Config, ntlm <-chan zeektypes.NTLM, output chan<- database.Data, importTime time.Time, logDir string, numNTLM *uint64) {
+	logger := zlog.GetLogger()
+
+	// loop over raw ntlm channel
+	for n := range ntlm {
+
+		// parse raw record as an ntlm entry
+		entry, err := formatNTLMRecord(cfg, &n, importTime)
+		if err != nil {
+			logger.Debug().Err(err).
+				Str("log_path", n.LogPath).
+				Str("zeek_uid", n.UID).
+				Str("timestamp", (time.Unix(int64(n.TimeStamp), 0)).String()).
+				Str("src", n.Source).
+				Str("dst", n.Destination).
+				Send()
+			continue
+		}
+
+		// entry was subject to filtering
+		if entry == nil {
+			continue
+		}
+
+		// record which sensor this authentication attempt was seen by
+		entry.Sensor = ParseSensor(logDir, n.LogPath)
+
+		output <- entry
+		// increment record counter
+		atomic.AddUint64(numNTLM, 1)
+	}
+}
+
+// formatNTLMRecord takes a raw ntlm record and formats it into the structure needed by the database
+func formatNTLMRecord(cfg *config.Config, parseNTLM *zeektypes.NTLM, importTime time.Time) (*NTLMEntry, error) {
+
+	// parse source and destination
+	srcIP := net.ParseIP(parseNTLM.Source)
+	dstIP := net.ParseIP(parseNTLM.Destination)
+
+	// verify that both addresses were parsed successfully
+	if (srcIP == nil) || (dstIP == nil) {
+		return nil, errors.New(errParseSrcDst)
+	}
+
+	// the username is what authentication tracking is keyed on, so entries without one aren't useful
+	if parseNTLM.Username == "" {
+		return nil, errors.New(errMissingNTLMUsername)
+	}
+
+	// ntlm uses its own pair filter since most authentication happens between internal hosts
+	if cfg.Filter.FilterNTLMPair(srcIP, dstIP) {
+		return nil, nil
+	}
+
+	srcNUID := util.ParseNetworkID(srcIP, parseNTLM.AgentUUID)
+	dstNUID := util.ParseNetworkID(dstIP, parseNTLM.AgentUUID)
+
+	zeekUID, err := util.NewFixedStringHash(parseNTLM.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same hash as the unique connection for this pair
+	hash, err := util.NewBeaconHash("ip", util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID})
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &NTLMEntry{
+		ImportTime: importTime,
+		ZeekUID:    zeekUID,
+		Hash:       hash,
+		Timestamp:  time.Unix(int64(parseNTLM.TimeStamp), 0),
+		Src:        srcIP,
+		Dst:        dstIP,
+		SrcNUID:    srcNUID,
+		DstNUID:    dstNUID,
+		SrcPort:    uint16(parseNTLM.SourcePort),
+		DstPort:    uint16(parseNTLM.DestinationPort),
+		SrcLocal:   cfg.Filter.CheckIfInternal(srcIP),
+		DstLocal:   cfg.Filter.CheckIfInternal(dstIP),
+		// windows domain and account names are case insensitive
+		Domain:   strings.ToUpper(parseNTLM.DomainName),
+		Username: strings.ToLower(parseNTLM.Username),
+		Hostname: parseNTLM.Hostname,
+		Success:  parseNTLM.Success,
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	"github.com/joho/godotenv"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatNTLMRecord(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	importTime := time.Unix(1713500000, 0)
+
+	attempt := zeektypes.NTLM{
+		TimeStamp:       1713499000,
+		UID:             "CNTLM1",
+		Source:          "10.0.0.5",
+		SourcePort:      51234,
+		Destination:     "10.0.0.3",
+		DestinationPort: 445,
+		Username:        "JDoe",
+		Hostname:        "WKSTN-05",
+		DomainName:      "corp",
+		Success:         true,
+	}
+
+	t.Run("Internal Authentication", func(t *testing.T) {
+		entry, err := formatNTLMRecord(&cfg, &attempt, importTime)
+		require.NoError(t, err)
+		require.NotNil(t, entry, "internal to internal ntlm authentication should not be filtered")
+
+		require.Equal(t, "10.0.0.5", entry.Src.String())
+		require.Equal(t, "10.0.0.3", entry.Dst.String())
+		require.Equal(t, uint16(445), entry.DstPort)
+		require.True(t, entry.SrcLocal)
+		require.True(t, entry.DstLocal)
+		require.Equal(t, "CORP", entry.Domain, "domains should be normalized to uppercase")
+		require.Equal(t, "jdoe", entry.Username, "usernames should be normalized to lowercase")
+		require.Equal(t, "WKSTN-05", entry.Hostname)
+		require.True(t, entry.Success)
+		require.Equal(t, time.Unix(1713499000, 0), entry.Timestamp)
+		require.Equal(t, importTime, entry.ImportTime)
+	})
+
+	t.Run("External to External", func(t *testing.T) {
+		record := attempt
+		record.Source = "1.1.1.1"
+		record.Destination = "8.8.8.8"
+		entry, err := formatNTLMRecord(&cfg, &record, importTime)
+		require.NoError(t, err)
+		require.Nil(t, entry)
+	})
+
+	t.Run("Missing Username", func(t *testing.T) {
+		record := attempt
+		record.Username = ""
+		entry, err := formatNTLMRecord(&cfg, &record, importTime)
+		require.Error(t, err)
+		require.Nil(t, entry)
+	})
+
+	t.Run("Invalid Address", func(t *testing.T) {
+		record := attempt
+		record.Source = "not an ip"
+		entry, err := formatNTLMRecord(&cfg, &record, importTime)
+		require.Error(t, err)
+		require.Nil(t, entry)
+	})
+}
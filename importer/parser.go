@@ -2,9 +2,11 @@ package importer
 
 import (
 	"bufio"
+	"compress/bzip2"
 	"compress/gzip"
 	"errors"
 	"fmt"
+	"net"
 	"path/filepath"
 	"reflect"
 	"strconv"
@@ -21,6 +23,7 @@ import (
 var errTruncated = errors.New("log file is potentially truncated")
 var errUnknownFileType = errors.New("failed to parse log file: unknown file type or malformed header")
 var errMismatchedPathField = errors.New("TSV 'path' field does not match file pathname prefix")
+var errMalformedLine = errors.New("skipped malformed log line")
 
 // ZeekHeader stores vars in the header of the zeek log
 type ZeekHeader[Z zeekRecord] struct {
@@ -56,15 +59,47 @@ const HTTPPrefix = "http"
 const OpenHTTPPrefix = "open_http"
 const SSLPrefix = "ssl"
 const OpenSSLPrefix = "open_ssl"
+const RDPPrefix = "rdp"
+const FTPPrefix = "ftp"
+const X509Prefix = "x509"
+const KerberosPrefix = "kerberos"
+const NTLMPrefix = "ntlm"
 const ConnSummaryPrefixUnderscore = "conn_summary"
 const ConnSummaryPrefixHyphen = "conn-summary"
 
 const lineErrorLimit = 25
 
+// ParseSensor returns the name of the sensor that recorded the log file at path, which is the name of the top-level
+// subdirectory of logDir that the file is in (ie, /logs/sensor1/2024-01-01/conn.log). An empty string is returned if the
+// file is directly in logDir or if the top-level subdirectory is a date folder, since there is only one sensor in those layouts.
+func ParseSensor(logDir string, path string) string {
+	if logDir == "" {
+		return ""
+	}
+
+	rel, err := filepath.Rel(logDir, path)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	// the file must be in a subdirectory of the log directory
+	if len(parts) < 2 || parts[0] == ".." {
+		return ""
+	}
+
+	// daily folders don't indicate a sensor
+	if _, err := time.Parse(time.DateOnly, parts[0]); err == nil {
+		return ""
+	}
+
+	return parts[0]
+}
+
 // parseFile is a generic function that determines if a passed in path belongs to a tsv or json file, parses the file header and scans through each subsequent line,
 // parsing/unmarshaling it into its associated zeektype and sending it on the passed in generic channel. The generic type is based on the path's prefix in the calling
-// function.
-func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc chan<- error, metaDBChan chan<- MetaDBFile, database string, importID util.FixedString) {
+// function. Records that end at or before offset were imported from the file by an earlier import and are skipped.
+func parseFile[Z zeekRecord](afs afero.Fs, path string, offset int64, entryChan chan<- Z, errc chan<- error, metaDBChan chan<- MetaDBFile, database string, importID util.FixedString) {
 	logger := zlog.GetLogger()
 
 	// open file for reading
@@ -102,7 +137,8 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 
 	// set up a new scanner to read from file
 	var scanner *bufio.Scanner
-	if strings.HasSuffix(path, ".gz") {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
 		// create gzip reader if the file extension insinuates that the file is compressed
 		gzipReader, err := gzip.NewReader(file)
 		if err != nil { // handle error from scanner
@@ -111,7 +147,10 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 		}
 		scanner = bufio.NewScanner(gzipReader)
 		defer gzipReader.Close()
-	} else {
+	case strings.HasSuffix(path, ".bz2"):
+		// create bzip2 reader, the bzip2 stream is not validated until the scanner starts reading it
+		scanner = bufio.NewScanner(bzip2.NewReader(file))
+	default:
 		scanner = bufio.NewScanner(file)
 	}
 
@@ -120,6 +159,14 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 	maxBufferSize := 1024 * 1024   // 1MiB
 	scanner.Buffer(make([]byte, 0, initialBufferSize), maxBufferSize)
 
+	// track where each line ends in the file so that the lines before the offset can be skipped
+	var lineEnd int64
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		lineEnd += int64(advance)
+		return advance, token, err
+	})
+
 	// declare new header object for parsing tsv headers
 	var header ZeekHeader[Z]
 	header.headerToStructMapping = make(map[string]int)
@@ -135,8 +182,16 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 
 	previousLineHadError := false
 
+	// track the line number so that malformed lines can be found in the file
+	lineNumber := 0
+
+	// find the address fields of the record so that they can be validated in JSON logs
+	addrFields := getAddrFields[Z]()
+
 	// iterate over lines in file
 	for scanner.Scan() {
+		lineNumber++
+
 		// handle error from scanner
 		if scanner.Err() != nil {
 			logger.Err(err).Str("path", path).Msg("failed to parse log file: could not scan the file")
@@ -202,12 +257,23 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 			}
 		}
 
+		// skip the records that were already imported, the header is still parsed above
+		if lineEnd <= offset {
+			continue
+		}
+
 		// parse this line as JSON if we've determined this file is in JSON format
 		if header.isJSON {
 			previousLineHadError = false
 			// unmarshal line
-			if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(scanner.Bytes(), &entry); err != nil {
-				logger.Err(err).Str("path", path).Bytes("record", scanner.Bytes()).Msg("failed to unmarshal line from JSON")
+			err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(scanner.Bytes(), &entry)
+			if err == nil {
+				err = validateAddrFields(reflect.ValueOf(&entry).Elem(), addrFields)
+			}
+			if err != nil {
+				logger.Warn().Err(err).Str("path", path).Int("line", lineNumber).Bytes("record", scanner.Bytes()).Msg("failed to unmarshal line from JSON, skipping line")
+				errc <- fmt.Errorf("%w: %s:%d: %w", errMalformedLine, path, lineNumber, err)
+				resetZeekRecord(&entry)
 				lineErrorCounter++
 				previousLineHadError = true
 				if lineErrorCounter > lineErrorLimit {
@@ -271,6 +337,7 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 						if err != nil {
 							logger.Warn().Err(err).
 								Str("path", path).
+								Int("line", lineNumber).
 								Str("field_name", header.fieldOrder[idx]).
 								Str("field_value", line).
 								Msg("failed to parse field in TSV Zeek log")
@@ -288,14 +355,16 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 				idx++
 			}
 
-			if fieldEndIndex == -1 && idx < len(header.fieldOrder)-2 {
-				logger.Err(errTruncated).Str("path", path).Send()
-				errc <- errTruncated
-				break
+			// a line that is missing fields was only partially written, so none of its fields can be trusted
+			lineTruncated := fieldEndIndex == -1 && idx < len(header.fieldOrder)-2
+			if lineTruncated {
+				logger.Warn().Err(errTruncated).Str("path", path).Int("line", lineNumber).Msg("log line is missing fields")
+				lineHadError = true
+				previousLineHadError = true
 			}
 
 			// parse in last field
-			if idx < len(header.fieldOrder) && line != header.emptyField && line != header.unsetField &&
+			if !lineTruncated && idx < len(header.fieldOrder) && line != header.emptyField && line != header.unsetField &&
 				header.headerToStructMapping[header.fieldOrder[idx]] > -1 {
 				err := header.parseField(
 					line,         // the last field, now the only thing left in line
@@ -305,6 +374,7 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 				if err != nil {
 					logger.Warn().Err(err).
 						Str("path", path).
+						Int("line", lineNumber).
 						Str("field_name", header.fieldOrder[idx]).
 						Str("field_value", line).
 						Msg("failed to parse field in TSV Zeek log")
@@ -313,17 +383,22 @@ func parseFile[Z zeekRecord](afs afero.Fs, path string, entryChan chan<- Z, errc
 				}
 			}
 
-			// increment file parsing error count if there were errors during field parsing
+			// skip the line and increment file parsing error count if there were errors during field parsing,
+			// since sending a partially parsed record would import it with missing or incorrect fields
 			if lineHadError {
+				errc <- fmt.Errorf("%w: %s:%d", errMalformedLine, path, lineNumber)
 				lineErrorCounter++
-			}
 
-			// return if parsing error limit for file was reached
-			if lineErrorCounter > lineErrorLimit {
-				logger.Warn().Str("path", path).Msg("log file is potentially corrupted")
-				// set this flag to false so that we don't log that this file could be truncated
-				previousLineHadError = false
-				break
+				// return if parsing error limit for file was reached
+				if lineErrorCounter > lineErrorLimit {
+					logger.Warn().Str("path", path).Msg("log file is potentially corrupted")
+					// set this flag to false so that we don't log that this file could be truncated
+					previousLineHadError = false
+					break
+				}
+
+				resetZeekRecord(&entry)
+				continue
 			}
 
 			// set log path field
@@ -399,6 +474,31 @@ func (header *ZeekHeader[Z]) parseHeader(line string) (typeArr []string, err err
 	return typeArr, nil
 }
 
+// getAddrFields returns the indexes of the fields of the zeek record that hold addresses
+func getAddrFields[Z zeekRecord]() []int {
+	var entry Z
+	structType := reflect.TypeOf(entry)
+
+	var fields []int
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).Tag.Get("zeektype") == "addr" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// validateAddrFields returns an error if any of the set address fields of a zeek record isn't a valid address
+func validateAddrFields(data reflect.Value, addrFields []int) error {
+	for _, i := range addrFields {
+		value := data.Field(i).String()
+		if value != "" && net.ParseIP(value) == nil {
+			return fmt.Errorf("invalid address in %s field: %q", data.Type().Field(i).Tag.Get("zeek"), value)
+		}
+	}
+	return nil
+}
+
 // mapHeader maps the names of the fields found in the log header to the corresponding
 // struct field's "index". This allows the struct to be dynamically populated using reflection.
 func (header *ZeekHeader[Z]) mapHeader() error {
@@ -469,18 +569,27 @@ func (header *ZeekHeader[Z]) parseField(value string, zeekType string, resultFie
 		}
 		tval := reflect.ValueOf(intervalFloat)
 		resultField.Set(tval)
+	case "addr":
+		// validate addresses here so that a partially written address fails with the rest of its line
+		if net.ParseIP(value) == nil {
+			return fmt.Errorf("couldn't convert zeektype addr: invalid address %q", value)
+		}
+		resultField.SetString(value)
 	case "string":
 		fallthrough
 	case "enum":
-		fallthrough
-	case "addr":
 		resultField.SetString(value)
 	case "count":
 		countInt, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
 		if err != nil {
 			return fmt.Errorf("couldn't convert zeektype count: %v", err.Error())
 		}
-		resultField.SetInt(countInt)
+		// counts that have to be told apart from an unset field are stored as pointers
+		if resultField.Kind() == reflect.Pointer {
+			resultField.Set(reflect.ValueOf(&countInt))
+		} else {
+			resultField.SetInt(countInt)
+		}
 	case "port":
 		portInt, err := strconv.Atoi(strings.TrimSpace(value))
 		if err != nil {
@@ -550,6 +659,26 @@ func (header *ZeekHeader[Z]) validatePathPrefix() (err error) {
 		if header.path != OpenSSLPrefix {
 			return errMismatchedPathField
 		}
+	case strings.HasPrefix(filepath.Base(header.fsPath), RDPPrefix):
+		if header.path != RDPPrefix {
+			return errMismatchedPathField
+		}
+	case strings.HasPrefix(filepath.Base(header.fsPath), FTPPrefix):
+		if header.path != FTPPrefix {
+			return errMismatchedPathField
+		}
+	case strings.HasPrefix(filepath.Base(header.fsPath), X509Prefix):
+		if header.path != X509Prefix {
+			return errMismatchedPathField
+		}
+	case strings.HasPrefix(filepath.Base(header.fsPath), KerberosPrefix):
+		if header.path != KerberosPrefix {
+			return errMismatchedPathField
+		}
+	case strings.HasPrefix(filepath.Base(header.fsPath), NTLMPrefix):
+		if header.path != NTLMPrefix {
+			return errMismatchedPathField
+		}
 	}
 	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/activecm/rita/v5/config"
 	"github.com/activecm/rita/v5/importer/zeektypes"
 	"github.com/activecm/rita/v5/util"
 	"github.com/joho/godotenv"
@@ -31,7 +32,7 @@ func TestTruncatedTSV(t *testing.T) {
 	require.NoError(t, err)
 
 	go func() {
-		parseFile(afero.NewOsFs(), path, entries, errc, metaDBChan, "test", importID)
+		parseFile(afero.NewOsFs(), path, 0, entries, errc, metaDBChan, "test", importID)
 		close(errc)
 		close(entries)
 		close(metaDBChan)
@@ -80,7 +81,7 @@ func TestTruncatedHeader(t *testing.T) {
 	require.NoError(t, err)
 
 	go func() {
-		parseFile(afero.NewOsFs(), path, entries, errc, metaDBChan, "test", importID)
+		parseFile(afero.NewOsFs(), path, 0, entries, errc, metaDBChan, "test", importID)
 		close(errc)
 		close(entries)
 		close(metaDBChan)
@@ -127,7 +128,7 @@ func TestTruncatedJSON(t *testing.T) {
 	require.NoError(t, err)
 
 	go func() {
-		parseFile(afero.NewOsFs(), path, entries, errc, metaDBChan, "test", importID)
+		parseFile(afero.NewOsFs(), path, 0, entries, errc, metaDBChan, "test", importID)
 		close(errc)
 		close(entries)
 		close(metaDBChan)
@@ -177,7 +178,7 @@ func TestHasUnknownFieldTSV(t *testing.T) {
 	require.NoError(t, err)
 
 	go func() {
-		parseFile(afero.NewOsFs(), path, entries, errc, metaDBChan, "test", importID)
+		parseFile(afero.NewOsFs(), path, 0, entries, errc, metaDBChan, "test", importID)
 		close(errc)
 		close(entries)
 		close(metaDBChan)
@@ -231,7 +232,7 @@ func TestPlainTextFile(t *testing.T) {
 	require.NoError(t, err)
 
 	go func() {
-		parseFile(afero.NewOsFs(), path, entries, errc, metaDBChan, "test", importID)
+		parseFile(afero.NewOsFs(), path, 0, entries, errc, metaDBChan, "test", importID)
 		close(errc)
 		close(entries)
 		close(metaDBChan)
@@ -263,3 +264,402 @@ func TestPlainTextFile(t *testing.T) {
 	}
 	require.True(t, receivedErr, "should receive unknown file type error")
 }
+
+func TestParseSensor(t *testing.T) {
+	tests := []struct {
+		name     string
+		logDir   string
+		path     string
+		expected string
+	}{
+		{name: "Sensor Directory", logDir: "/logs", path: "/logs/sensor1/conn.log", expected: "sensor1"},
+		{name: "Sensor Directory With Daily Folders", logDir: "/logs", path: "/logs/sensor1/2024-01-01/conn.00:00:00-01:00:00.log.gz", expected: "sensor1"},
+		{name: "Trailing Slash On Log Directory", logDir: "/logs/", path: "/logs/sensor2/dns.log", expected: "sensor2"},
+		{name: "File In Log Directory", logDir: "/logs", path: "/logs/conn.log", expected: ""},
+		{name: "Daily Folder", logDir: "/logs", path: "/logs/2024-01-01/conn.log", expected: ""},
+		{name: "File Outside Log Directory", logDir: "/logs", path: "/other/sensor1/conn.log", expected: ""},
+		{name: "Log Directory Is File", logDir: "/logs/conn.log", path: "/logs/conn.log", expected: ""},
+		{name: "No Log Directory", logDir: "", path: "kafka://zeek/0", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, ParseSensor(test.logDir, test.path))
+		})
+	}
+}
+
+func TestMalformedLinesAreSkipped(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	header := "#separator \\x09\n" +
+		"#set_separator\t,\n" +
+		"#empty_field\t(empty)\n" +
+		"#unset_field\t-\n" +
+		"#path\tconn\n" +
+		"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\tduration\n" +
+		"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\tinterval\n"
+
+	tests := []struct {
+		name                 string
+		contents             string
+		expectedRecords      int
+		expectedSkipped      []string
+		expectedTruncatedErr bool
+	}{
+		{
+			name: "TSV",
+			contents: header +
+				"1715640000.000001\tC1\t10.0.0.1\t51000\t10.0.0.2\t443\ttcp\t1.0\n" +
+				"1715640001.000001\tC2\t10.0.\n" + // partially written line
+				"1715640002.000001\tC3\t10.0.0.1\t51002\t10.0.0.2\t443\ttcp\t1.0\n" +
+				"1715640003.000001\tC4\t10.0.0.1\t51003\t10.0.0\t443\ttcp\t1.0\n" + // partially written address
+				"1715640004.000001\tC5\t10.0.0.1\t51004\t10.0.0.2\t443\ttcp\t1.0\n",
+			expectedRecords: 3,
+			expectedSkipped: []string{"conn.log:9", "conn.log:11"},
+		},
+		{
+			name: "TSV Truncated Last Line",
+			contents: header +
+				"1715640000.000001\tC1\t10.0.0.1\t51000\t10.0.0.2\t443\ttcp\t1.0\n" +
+				"1715640001.000001\tC2\t10.0.0.1\t510",
+			expectedRecords:      1,
+			expectedSkipped:      []string{"conn.log:9"},
+			expectedTruncatedErr: true,
+		},
+		{
+			name: "JSON",
+			contents: `{"ts":1715640000.000001,"uid":"C1","id.orig_h":"10.0.0.1","id.orig_p":51000,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp"}` + "\n" +
+				`{"ts":1715640001.000001,"uid":"C2","id.orig_h":"10.0.0.1","id.orig_p":51001,"id.resp_h":"10.0.0","id.resp_p":443,"proto":"tcp"}` + "\n" +
+				`{"ts":1715640002.000001,"uid":"C3","id.orig_h":"10.0.0.1","id.orig_p":51002,"id.resp_h":"10.0.` + "\n" +
+				`{"ts":1715640003.000001,"uid":"C4","id.orig_h":"10.0.0.1","id.orig_p":51003,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp"}` + "\n",
+			expectedRecords: 2,
+			expectedSkipped: []string{"conn.log:2", "conn.log:3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			afs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(afs, "/logs/conn.log", []byte(test.contents), 0o644))
+
+			importID, err := util.NewFixedStringHash(test.name)
+			require.NoError(t, err)
+
+			entries := make(chan zeektypes.Conn)
+			errc := make(chan error)
+			metaDBChan := make(chan MetaDBFile)
+
+			go func() {
+				parseFile(afs, "/logs/conn.log", 0, entries, errc, metaDBChan, "test", importID)
+				close(errc)
+				close(entries)
+				close(metaDBChan)
+			}()
+
+			var uids []string
+			var skipped []string
+			receivedTruncatedErr := false
+			openChannels := 3
+			for openChannels > 0 {
+				select {
+				case entry, ok := <-entries:
+					if !ok {
+						openChannels--
+					} else {
+						uids = append(uids, entry.UID)
+					}
+				case _, ok := <-metaDBChan:
+					if !ok {
+						openChannels--
+					}
+				case err, ok := <-errc:
+					if !ok {
+						openChannels--
+					} else if errors.Is(err, errMalformedLine) {
+						skipped = append(skipped, err.Error())
+					} else if errors.Is(err, errTruncated) {
+						receivedTruncatedErr = true
+					}
+				}
+			}
+
+			require.Len(t, uids, test.expectedRecords, "valid lines should be imported, got %v", uids)
+			require.Len(t, skipped, len(test.expectedSkipped))
+			for i, location := range test.expectedSkipped {
+				require.Contains(t, skipped[i], location, "skipped line error should include the file and line number")
+			}
+			require.Equal(t, test.expectedTruncatedErr, receivedTruncatedErr)
+		})
+	}
+}
+
+func TestParseFileFromOffset(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	header := "#separator \\x09\n" +
+		"#set_separator\t,\n" +
+		"#empty_field\t(empty)\n" +
+		"#unset_field\t-\n" +
+		"#path\tconn\n" +
+		"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\tduration\n" +
+		"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\tinterval\n"
+	tsvRecord := func(uid string) string {
+		return "1715640000.000001\t" + uid + "\t10.0.0.1\t51000\t10.0.0.2\t443\ttcp\t1.0\n"
+	}
+	jsonRecord := func(uid string) string {
+		return `{"ts":1715640000.000001,"uid":"` + uid + `","id.orig_h":"10.0.0.1","id.orig_p":51000,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp"}` + "\n"
+	}
+
+	tests := []struct {
+		name         string
+		imported     string
+		appended     string
+		expectedUIDs []string
+	}{
+		{
+			name:         "TSV",
+			imported:     header + tsvRecord("C1") + tsvRecord("C2"),
+			appended:     tsvRecord("C3") + tsvRecord("C4"),
+			expectedUIDs: []string{"C3", "C4"},
+		},
+		{
+			name:         "JSON",
+			imported:     jsonRecord("C1") + jsonRecord("C2"),
+			appended:     jsonRecord("C3") + jsonRecord("C4"),
+			expectedUIDs: []string{"C3", "C4"},
+		},
+		{
+			// the line that was still being written when the file was imported is imported in full
+			name:         "Partially Written Line",
+			imported:     header + tsvRecord("C1") + tsvRecord("C2")[:20],
+			appended:     tsvRecord("C2")[20:] + tsvRecord("C3"),
+			expectedUIDs: []string{"C2", "C3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			afs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(afs, "/logs/conn.log", []byte(test.imported+test.appended), 0o644))
+
+			importID, err := util.NewFixedStringHash(test.name)
+			require.NoError(t, err)
+
+			entries := make(chan zeektypes.Conn)
+			errc := make(chan error)
+			metaDBChan := make(chan MetaDBFile)
+
+			go func() {
+				parseFile(afs, "/logs/conn.log", int64(len(test.imported)), entries, errc, metaDBChan, "test", importID)
+				close(errc)
+				close(entries)
+				close(metaDBChan)
+			}()
+
+			var uids []string
+			markedImported := false
+			openChannels := 3
+			for openChannels > 0 {
+				select {
+				case entry, ok := <-entries:
+					if !ok {
+						openChannels--
+					} else {
+						uids = append(uids, entry.UID)
+					}
+				case _, ok := <-metaDBChan:
+					if !ok {
+						openChannels--
+					} else {
+						markedImported = true
+					}
+				case _, ok := <-errc:
+					if !ok {
+						openChannels--
+					}
+				}
+			}
+
+			require.Equal(t, test.expectedUIDs, uids, "only the records after the offset should be imported")
+			require.True(t, markedImported, "the file should be marked as imported")
+		})
+	}
+}
+
+func TestUnsetCountField(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{
+			name: "TSV",
+			contents: "#separator \\x09\n" +
+				"#set_separator\t,\n" +
+				"#empty_field\t(empty)\n" +
+				"#unset_field\t-\n" +
+				"#path\tconn\n" +
+				"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\torig_ip_bytes\tresp_ip_bytes\n" +
+				"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\tcount\tcount\n" +
+				"1715640000.000001\tC1\t10.0.0.1\t51000\t10.0.0.2\t443\ttcp\t120\t4312\n" +
+				"1715640001.000001\tC2\t10.0.0.1\t51001\t10.0.0.2\t443\ttcp\t120\t0\n" +
+				"1715640002.000001\tC3\t10.0.0.1\t51002\t10.0.0.2\t443\ttcp\t120\t-\n" +
+				"1715640003.000001\tC4\t10.0.0.1\t51003\t10.0.0.2\t443\ttcp\t120\t-\t\n",
+		},
+		{
+			name: "JSON",
+			contents: `{"ts":1715640000.000001,"uid":"C1","id.orig_h":"10.0.0.1","id.orig_p":51000,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp","orig_ip_bytes":120,"resp_ip_bytes":4312}` + "\n" +
+				`{"ts":1715640001.000001,"uid":"C2","id.orig_h":"10.0.0.1","id.orig_p":51001,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp","orig_ip_bytes":120,"resp_ip_bytes":0}` + "\n" +
+				// zeek leaves unset fields out of JSON logs
+				`{"ts":1715640002.000001,"uid":"C3","id.orig_h":"10.0.0.1","id.orig_p":51002,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp","orig_ip_bytes":120}` + "\n" +
+				`{"ts":1715640003.000001,"uid":"C4","id.orig_h":"10.0.0.1","id.orig_p":51003,"id.resp_h":"10.0.0.2","id.resp_p":443,"proto":"tcp","orig_ip_bytes":120,"resp_ip_bytes":null}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			afs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(afs, "/logs/conn.log", []byte(test.contents), 0o644))
+
+			importID, err := util.NewFixedStringHash(test.name)
+			require.NoError(t, err)
+
+			entries := make(chan zeektypes.Conn)
+			errc := make(chan error)
+			metaDBChan := make(chan MetaDBFile)
+
+			go func() {
+				parseFile(afs, "/logs/conn.log", 0, entries, errc, metaDBChan, "test", importID)
+				close(errc)
+				close(entries)
+				close(metaDBChan)
+			}()
+
+			records := make(map[string]zeektypes.Conn)
+			openChannels := 3
+			for openChannels > 0 {
+				select {
+				case entry, ok := <-entries:
+					if !ok {
+						openChannels--
+					} else {
+						records[entry.UID] = entry
+					}
+				case _, ok := <-metaDBChan:
+					if !ok {
+						openChannels--
+					}
+				case err, ok := <-errc:
+					if !ok {
+						openChannels--
+					} else {
+						require.NoError(t, err)
+					}
+				}
+			}
+
+			require.Len(t, records, 4)
+
+			require.NotNil(t, records["C1"].RespIPBytes)
+			require.Equal(t, int64(4312), *records["C1"].RespIPBytes)
+
+			require.NotNil(t, records["C2"].RespIPBytes, "a count of 0 should be told apart from an unset field")
+			require.Equal(t, int64(0), *records["C2"].RespIPBytes)
+
+			require.Nil(t, records["C3"].RespIPBytes, "an unset count should be nil")
+			require.Nil(t, records["C4"].RespIPBytes, "an unset count should be nil")
+
+			// counts that don't have to be told apart from an unset field are still read as values
+			require.Equal(t, int64(120), records["C3"].OrigIPBytes)
+		})
+	}
+}
+
+func TestCorelightJSONConn(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	// a conn record exported by a Corelight sensor, which adds its own fields to zeek's and writes ISO 8601 timestamps
+	contents := `{"_path":"conn","_system_name":"corelight-sensor-1","_write_ts":"2024-05-13T22:40:05.311411Z",` +
+		`"ts":"2024-05-13T22:40:00.123456Z","uid":"CHhAvVGS1DHFjwGM9","id.orig_h":"10.55.100.111","id.orig_p":51432,` +
+		`"id.resp_h":"52.84.125.33","id.resp_p":443,"id.vlan":10,"proto":"tcp","service":"ssl","duration":1.503411,` +
+		`"orig_bytes":517,"resp_bytes":4312,"conn_state":"SF","local_orig":true,"local_resp":false,"missed_bytes":0,` +
+		`"history":"ShADadFf","orig_pkts":9,"orig_ip_bytes":897,"resp_pkts":8,"resp_ip_bytes":4740,` +
+		`"orig_l2_addr":"00:50:56:a1:2b:3c","resp_l2_addr":"00:1b:17:00:01:12","community_id":"1:LQU9qZlK+B5F3KDmev6m5PMibrg=",` +
+		`"app":["tls"],"corelight_shunted":false,"id.orig_h_name.src":"DNS_AAAA","id.resp_h_name.vals":["d1.example.com"]}` + "\n"
+
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(afs, "/logs/conn_20240513_22:00:00-23:00:00-0000.log", []byte(contents), 0o644))
+
+	importID, err := util.NewFixedStringHash("corelight")
+	require.NoError(t, err)
+
+	entries := make(chan zeektypes.Conn)
+	errc := make(chan error)
+	metaDBChan := make(chan MetaDBFile)
+
+	go func() {
+		parseFile(afs, "/logs/conn_20240513_22:00:00-23:00:00-0000.log", 0, entries, errc, metaDBChan, "test", importID)
+		close(errc)
+		close(entries)
+		close(metaDBChan)
+	}()
+
+	var records []zeektypes.Conn
+	openChannels := 3
+	for openChannels > 0 {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				openChannels--
+			} else {
+				records = append(records, entry)
+			}
+		case _, ok := <-metaDBChan:
+			if !ok {
+				openChannels--
+			}
+		case err, ok := <-errc:
+			if !ok {
+				openChannels--
+			} else {
+				require.NoError(t, err, "corelight's extra fields should be ignored")
+			}
+		}
+	}
+
+	require.Len(t, records, 1)
+	record := records[0]
+
+	require.Equal(t, zeektypes.Timestamp(time.Date(2024, 5, 13, 22, 40, 0, 0, time.UTC).Unix()), record.TimeStamp)
+	require.Equal(t, "CHhAvVGS1DHFjwGM9", record.UID)
+	require.Equal(t, "10.55.100.111", record.Source)
+	require.Equal(t, 51432, record.SourcePort)
+	require.Equal(t, "52.84.125.33", record.Destination)
+	require.Equal(t, 443, record.DestinationPort)
+	require.Equal(t, "tcp", record.Proto)
+	require.Equal(t, "ssl", record.Service)
+	require.InDelta(t, 1.503411, record.Duration, 0.000001)
+	require.Equal(t, int64(517), record.OrigBytes)
+	require.Equal(t, int64(4312), record.RespBytes)
+	require.Equal(t, "SF", record.ConnState)
+	require.Equal(t, "ShADadFf", record.History)
+	require.Equal(t, int64(897), record.OrigIPBytes)
+	require.NotNil(t, record.RespIPBytes)
+	require.Equal(t, int64(4740), *record.RespIPBytes)
+	require.Equal(t, "1:LQU9qZlK+B5F3KDmev6m5PMibrg=", record.CommunityID)
+
+	// the community id is stored with the connection so that it can be joined with other tools' records of the flow
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	entry, err := formatConnRecord(&cfg, &record, importID, time.Now())
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	require.Equal(t, "1:LQU9qZlK+B5F3KDmev6m5PMibrg=", entry.CommunityID)
+}
@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	zlog "github.com/activecm/rita/v5/logger"
+
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// progressLogInterval is how often progress events are logged, the event for the last file is always logged
+const progressLogInterval = 10 * time.Second
+
+// Progress tracks how many of the files of an import have been parsed across every hour of the import, and reports the
+// percentage complete, the parsing rate, and the estimated time left. Progress is shown on the progress bar of each
+// hour, or logged as structured events when logEvents is set, such as when logs are written as JSON. It is safe for
+// concurrent use, and a nil Progress reports nothing.
+type Progress struct {
+	total     int64
+	done      int64
+	started   time.Time
+	logEvents bool
+	now       func() time.Time
+
+	mu         sync.Mutex
+	lastLogged time.Time
+}
+
+// ProgressStats is a snapshot of the progress of an import
+type ProgressStats struct {
+	Done           int64
+	Total          int64
+	Percent        float64
+	FilesPerSecond float64
+	ETA            time.Duration // 0 until the first file is parsed
+}
+
+// NewProgress returns a Progress for an import of total files that starts now
+func NewProgress(total int, logEvents bool) *Progress {
+	return newProgress(total, logEvents, time.Now)
+}
+
+func newProgress(total int, logEvents bool, now func() time.Time) *Progress {
+	return &Progress{total: int64(total), started: now(), logEvents: logEvents, now: now}
+}
+
+// FileDone records that a file was parsed
+func (p *Progress) FileDone() {
+	if p == nil {
+		return
+	}
+	done := atomic.AddInt64(&p.done, 1)
+	p.logEvent(done == atomic.LoadInt64(&p.total))
+}
+
+// Skip removes files that won't be parsed, such as files that were already imported, so that they don't count
+// towards the estimated time left
+func (p *Progress) Skip(n int) {
+	if p == nil || n <= 0 {
+		return
+	}
+	atomic.AddInt64(&p.total, -int64(n))
+}
+
+// Stats returns a snapshot of the progress
+func (p *Progress) Stats() ProgressStats {
+	if p == nil {
+		return ProgressStats{}
+	}
+
+	stats := ProgressStats{Done: atomic.LoadInt64(&p.done), Total: atomic.LoadInt64(&p.total)}
+	if stats.Total > 0 {
+		stats.Percent = min(100, float64(stats.Done)/float64(stats.Total)*100)
+	}
+
+	elapsed := p.now().Sub(p.started).Seconds()
+	if stats.Done > 0 && elapsed > 0 {
+		stats.FilesPerSecond = float64(stats.Done) / elapsed
+		remaining := max(0, stats.Total-stats.Done)
+		stats.ETA = (time.Duration(float64(remaining)/stats.FilesPerSecond) * time.Second).Round(time.Second)
+	}
+	return stats
+}
+
+// String formats the progress for the console, ex: 42% | 3.5 files/s | ETA 1m5s
+func (p *Progress) String() string {
+	stats := p.Stats()
+	if stats.Done == 0 {
+		return fmt.Sprintf("%.0f%% | ETA unknown", stats.Percent)
+	}
+	return fmt.Sprintf("%.0f%% | %.1f files/s | ETA %s", stats.Percent, stats.FilesPerSecond, stats.ETA)
+}
+
+// decorator returns a progress bar decorator that shows the progress of the whole import, or nil if the progress is
+// logged as events instead
+func (p *Progress) decorator() decor.Decorator {
+	if p == nil || p.logEvents {
+		return nil
+	}
+	return decor.Any(func(decor.Statistics) string { return p.String() }, decor.WC{C: decor.DextraSpace})
+}
+
+// logEvent logs the progress as a structured event if events are enabled and one wasn't logged recently
+func (p *Progress) logEvent(final bool) {
+	if !p.logEvents {
+		return
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	if !final && now.Sub(p.lastLogged) < progressLogInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastLogged = now
+	p.mu.Unlock()
+
+	stats := p.Stats()
+	logger := zlog.GetLogger()
+	logger.Info().Str("phase", "parse").
+		Int64("files_done", stats.Done).
+		Int64("files_total", stats.Total).
+		Float64("percent", stats.Percent).
+		Float64("files_per_second", stats.FilesPerSecond).
+		Dur("eta", stats.ETA).
+		Msg("import progress")
+}
@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	err := godotenv.Load("../.env")
+	require.NoError(t, err)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	var clockMu sync.Mutex
+	clock := func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	}
+
+	for _, logEvents := range []bool{false, true} {
+		progress := newProgress(100, logEvents, clock)
+		require.Equal(t, "0% | ETA unknown", progress.String(), "the rate isn't known until a file is parsed")
+
+		// files are parsed by many digesters at once
+		var wg sync.WaitGroup
+		for d := 0; d < 8; d++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for f := 0; f < 5; f++ {
+					progress.FileDone()
+				}
+			}()
+		}
+		wg.Wait()
+
+		// 40 files in 20 seconds is 2 files/s, so the other 60 files take 30 more seconds
+		clockMu.Lock()
+		now = start.Add(20 * time.Second)
+		clockMu.Unlock()
+		require.Equal(t, ProgressStats{Done: 40, Total: 100, Percent: 40, FilesPerSecond: 2, ETA: 30 * time.Second}, progress.Stats())
+		require.Equal(t, "40% | 2.0 files/s | ETA 30s", progress.String())
+
+		// files that were already imported aren't waited on
+		progress.Skip(20)
+		require.Equal(t, ProgressStats{Done: 40, Total: 80, Percent: 50, FilesPerSecond: 2, ETA: 20 * time.Second}, progress.Stats())
+
+		clockMu.Lock()
+		now = start
+		clockMu.Unlock()
+	}
+
+	// a nil progress reports nothing
+	var disabled *Progress
+	disabled.FileDone()
+	disabled.Skip(1)
+	require.Equal(t, ProgressStats{}, disabled.Stats())
+	require.Nil(t, disabled.decorator())
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/progressbar"
+	"github.com/activecm/rita/v5/util"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+type RDPEntry struct {
+	ImportTime       time.Time        `ch:"import_time"`
+	ZeekUID          util.FixedString `ch:"zeek_uid"`
+	Hash             util.FixedString `ch:"hash"`
+	Timestamp        time.Time        `ch:"ts"`
+	Src              net.IP           `ch:"src"`
+	Dst              net.IP           `ch:"dst"`
+	SrcNUID          uuid.UUID        `ch:"src_nuid"`
+	DstNUID          uuid.UUID        `ch:"dst_nuid"`
+	SrcPort          uint16           `ch:"src_port"`
+	DstPort          uint16           `ch:"dst_port"`
+	SrcLocal         bool             `ch:"src_local"`
+	DstLocal         bool             `ch:"dst_local"`
+	Cookie           string           `ch:"cookie"`
+	Result           string           `ch:"result"`
+	SecurityProtocol string           `ch:"security_protocol"`
+	KeyboardLayout   string           `ch:"keyboard_layout"`
+	ClientBuild      string           `ch:"client_build"`
+	ClientName       string           `ch:"client_name"`
+}
+
+// parseRDP listens on a channel of raw rdp log records, formats them and sends them to be linked with conn records and written to the database
+func parseRDP(cfg *config.Config, rdp <-chan zeektypes.RDP, output chan database.Data, importTime time.Time, numRDP *uint64) {
+	logger := zlog.GetLogger()
+
+	// loop over raw rdp channel
+	for r := range rdp {
+
+		// parse raw record as an rdp entry
+		entry, err := formatRDPRecord(cfg, &r, importTime)
+		if err != nil {
+			logger.Debug().Err(err).
+				Str("log_path", r.LogPath).
+				Str("zeek_uid", r.UID).
+				Str("timestamp", (time.Unix(int64(r.TimeStamp), 0)).String()).
+				Str("src", r.Source).
+				Str("dst", r.Destination).
+				Send()
+			continue
+		}
+
+		// entry was subject to filtering
+		if entry == nil {
+			continue
+		}
+
+		output <- entry
+		// increment record counter
+		atomic.AddUint64(numRDP, 1)
+	}
+}
+
+// formatRDPRecord takes a raw rdp record and formats it into the structure needed by the database
+func formatRDPRecord(cfg *config.Config, parseRDP *zeektypes.RDP, importTime time.Time) (*RDPEntry, error) {
+
+	// parse source and destination
+	srcIP := net.ParseIP(parseRDP.Source)
+	dstIP := net.ParseIP(parseRDP.Destination)
+
+	// verify that both addresses were parsed successfully
+	if (srcIP == nil) || (dstIP == nil) {
+		return nil, errors.New(errParseSrcDst)
+	}
+
+	// rdp uses its own pair filter since most rdp sessions are internal -> internal
+	ignore := cfg.Filter.FilterRDPPair(srcIP, dstIP) || cfg.Filter.FilterPort(uint16(parseRDP.DestinationPort), "tcp")
+	if ignore {
+		return nil, nil
+	}
+
+	srcNUID := util.ParseNetworkID(srcIP, parseRDP.AgentUUID)
+	dstNUID := util.ParseNetworkID(dstIP, parseRDP.AgentUUID)
+
+	zeekUID, err := util.NewFixedStringHash(parseRDP.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same hash as the unique connection for this pair
+	hash, err := util.NewBeaconHash("ip", util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID})
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &RDPEntry{
+		ImportTime:       importTime,
+		ZeekUID:          zeekUID,
+		Hash:             hash,
+		Timestamp:        time.Unix(int64(parseRDP.TimeStamp), 0),
+		Src:              srcIP,
+		Dst:              dstIP,
+		SrcNUID:          srcNUID,
+		DstNUID:          dstNUID,
+		SrcPort:          uint16(parseRDP.SourcePort),
+		DstPort:          uint16(parseRDP.DestinationPort),
+		SrcLocal:         cfg.Filter.CheckIfInternal(srcIP),
+		DstLocal:         cfg.Filter.CheckIfInternal(dstIP),
+		Cookie:           parseRDP.Cookie,
+		Result:           parseRDP.Result,
+		SecurityProtocol: parseRDP.SecurityProtocol,
+		KeyboardLayout:   parseRDP.KeyboardLayout,
+		ClientBuild:      parseRDP.ClientBuild,
+		ClientName:       parseRDP.ClientName,
+	}
+
+	return entry, nil
+}
+
+// writeLinkedRDP copies the rdp records from rdp_tmp to rdp, adding the connection data from their matching conn records
+func (importer *Importer) writeLinkedRDP(ctx context.Context, progress *tea.Program, barID int) error {
+	logger := zlog.GetLogger()
+
+	// conn_tmp includes the connections that are filtered by the conn pair filter (such as internal -> internal),
+	// so rdp records can be linked even when their conn record won't be written to the conn table
+	err := importer.Database.Conn.Exec(importer.Database.GetContext(), `
+		INSERT INTO rdp (
+			import_time, zeek_uid, hash, ts, src, dst, src_nuid, dst_nuid, src_port, dst_port, src_local, dst_local,
+			proto, service, conn_state, duration, src_bytes, dst_bytes, src_ip_bytes, dst_ip_bytes, src_packets, dst_packets,
+			cookie, result, security_protocol, keyboard_layout, client_build, client_name, sensor
+		) SELECT r.import_time, r.zeek_uid, r.hash, c.ts, r.src, r.dst, r.src_nuid, r.dst_nuid, r.src_port, r.dst_port, r.src_local, r.dst_local,
+			c.proto, c.service, c.conn_state, c.duration, c.src_bytes, c.dst_bytes, c.src_ip_bytes, c.dst_ip_bytes, c.src_packets, c.dst_packets,
+			r.cookie, r.result, r.security_protocol, r.keyboard_layout, r.client_build, r.client_name, c.sensor
+		FROM rdp_tmp r
+		INNER JOIN conn_tmp c USING zeek_uid
+	`)
+	if err != nil {
+		if ctx.Err() != nil {
+			logger.Warn().Msg("cancelling RDP connection linking")
+			return ctx.Err()
+		}
+		return err
+	}
+
+	progress.Send(progressbar.ProgressMsg{ID: barID, Percent: 1})
+	return nil
+}
@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+)
+
+var ErrNoRecordsToImport = errors.New("no records to import")
+
+// Records holds zeek records that were read from a source other than log files, such as a stream
+type Records struct {
+	Conn     []zeektypes.Conn
+	OpenConn []zeektypes.Conn
+	DNS      []zeektypes.DNS
+	HTTP     []zeektypes.HTTP
+	OpenHTTP []zeektypes.HTTP
+	SSL      []zeektypes.SSL
+	OpenSSL  []zeektypes.SSL
+	RDP      []zeektypes.RDP
+	FTP      []zeektypes.FTP
+	X509     []zeektypes.X509
+	Kerberos []zeektypes.Kerberos
+	NTLM     []zeektypes.NTLM
+}
+
+// Len returns the total number of records across all log types
+func (r *Records) Len() int {
+	return len(r.Conn) + len(r.OpenConn) + len(r.DNS) + len(r.HTTP) + len(r.OpenHTTP) + len(r.SSL) + len(r.OpenSSL) + len(r.RDP) + len(r.FTP) + len(r.X509) + len(r.Kerberos) + len(r.NTLM)
+}
+
+// ImportRecords writes a batch of already parsed zeek records to the database, using the same
+// formatting, filtering, and linking steps as a file import
+func (importer *Importer) ImportRecords(records *Records) error {
+	logger := zlog.GetLogger()
+
+	if records == nil || records.Len() == 0 {
+		return ErrNoRecordsToImport
+	}
+
+	batchStart := time.Now()
+
+	// add import started record to metadatabase
+	if err := importer.importStartedCallback(importer.ImportID); err != nil {
+		return err
+	}
+
+	// initialize writers
+	importer.startWritersCallback(importer.NumWriters)
+
+	// start goroutines to format records and send them to the writers
+	importer.startParseRoutines()
+
+	// feed the records to the parsers in the same order that log files are fed during a file import
+	for _, entry := range records.Conn {
+		importer.EntryChannels.Conn <- entry
+	}
+	for _, entry := range records.HTTP {
+		importer.EntryChannels.HTTP <- entry
+	}
+	for _, entry := range records.SSL {
+		importer.EntryChannels.SSL <- entry
+	}
+	for _, entry := range records.RDP {
+		importer.EntryChannels.RDP <- entry
+	}
+	for _, entry := range records.OpenConn {
+		importer.EntryChannels.OpenConn <- entry
+	}
+	for _, entry := range records.OpenHTTP {
+		importer.EntryChannels.OpenHTTP <- entry
+	}
+	for _, entry := range records.OpenSSL {
+		importer.EntryChannels.OpenSSL <- entry
+	}
+	for _, entry := range records.DNS {
+		importer.EntryChannels.DNS <- entry
+	}
+	for _, entry := range records.FTP {
+		importer.EntryChannels.FTP <- entry
+	}
+	for _, entry := range records.X509 {
+		importer.EntryChannels.X509 <- entry
+	}
+	for _, entry := range records.Kerberos {
+		importer.EntryChannels.Kerberos <- entry
+	}
+	for _, entry := range records.NTLM {
+		importer.EntryChannels.NTLM <- entry
+	}
+
+	// close log entry channels
+	close(importer.EntryChannels.Conn)
+	close(importer.EntryChannels.OpenConn)
+	close(importer.EntryChannels.DNS)
+	close(importer.EntryChannels.HTTP)
+	close(importer.EntryChannels.OpenHTTP)
+	close(importer.EntryChannels.SSL)
+	close(importer.EntryChannels.OpenSSL)
+	close(importer.EntryChannels.RDP)
+	close(importer.EntryChannels.FTP)
+	close(importer.EntryChannels.X509)
+	close(importer.EntryChannels.Kerberos)
+	close(importer.EntryChannels.NTLM)
+
+	// wait for log routine groups
+	importer.wg.Conn.Wait()
+	importer.wg.OpenConn.Wait()
+	importer.wg.DNS.Wait()
+	importer.wg.HTTP.Wait()
+	importer.wg.OpenHTTP.Wait()
+	importer.wg.SSL.Wait()
+	importer.wg.OpenSSL.Wait()
+	importer.wg.RDP.Wait()
+	importer.wg.FTP.Wait()
+	importer.wg.X509.Wait()
+	importer.wg.Kerberos.Wait()
+	importer.wg.NTLM.Wait()
+
+	// close writers
+	if err := importer.closeWritersCallback(); err != nil {
+		return err
+	}
+
+	logger.Debug().Int("records", records.Len()).Str("elapsed_time", time.Since(batchStart).String()).Msg("Finished parsing record batch")
+
+	return importer.season()
+}
@@ -54,6 +54,7 @@ type SSLEntry struct {
 	NextProtocol     string           `ch:"next_protocol"`
 	Established      bool             `ch:"established"`
 	ServerCertFUIDs  []string         `ch:"server_cert_fuids"`
+	ServerCertFPs    []string         `ch:"server_cert_fps"`
 	ClientCertFUIDs  []string         `ch:"client_cert_fuids"`
 	ServerSubject    string           `ch:"server_subject"`
 	ServerIssuer     string           `ch:"server_issuer"`
@@ -62,6 +63,7 @@ type SSLEntry struct {
 	ValidationStatus string           `ch:"validation_status"`
 	JA3              string           `ch:"ja3"`
 	JA3S             string           `ch:"ja3s"`
+	Sensor           string           `ch:"sensor"`
 }
 
 // parseSSL listens on a channel of raw ssl/openssl log records, formats them and sends them to be linked with conn/openconn records and written to the database
@@ -119,7 +121,8 @@ func formatSSLRecord(cfg *config.Config, parseSSL *zeektypes.SSL, importTime tim
 		return nil, fmt.Errorf("could not parse SSL connection %s -> %s: %w", src, dst, errServerNameEmpty)
 	}
 
-	ignore := cfg.Filter.FilterDomain(sni) || cfg.Filter.FilterConnPair(srcIP, dstIP) || cfg.Filter.FilterSNIPair(srcIP)
+	ignore := cfg.Filter.FilterDomain(sni) || cfg.Filter.FilterConnPair(srcIP, dstIP) || cfg.Filter.FilterSNIPair(srcIP) ||
+		cfg.Filter.FilterPort(uint16(parseSSL.DestinationPort), "tcp") || cfg.Filter.FilterInternalDomainPair(srcIP, sni)
 	if ignore {
 		return nil, nil
 	}
@@ -132,7 +135,8 @@ func formatSSLRecord(cfg *config.Config, parseSSL *zeektypes.SSL, importTime tim
 		return nil, err
 	}
 
-	hash, err := util.NewFixedStringHash(srcIP.To16().String(), srcNUID.String(), dstIP.To16().String(), dstNUID.String(), sni)
+	// only identifies the record until it is linked to its connection, which replaces it with the hash of its beacon type
+	hash, err := util.NewConnectionHash(util.ConnectionIdentity{Src: srcIP, SrcNUID: srcNUID, Dst: dstIP, DstNUID: dstNUID, FQDN: sni}, util.SNIHashComponents...)
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +153,7 @@ func formatSSLRecord(cfg *config.Config, parseSSL *zeektypes.SSL, importTime tim
 		SrcPort:          uint16(parseSSL.SourcePort),
 		DstPort:          uint16(parseSSL.DestinationPort),
 		SrcLocal:         cfg.Filter.CheckIfInternal(srcIP),
-		DstLocal:         cfg.Filter.CheckIfInternal(dstIP),
+		DstLocal:         cfg.Filter.CheckIfInternal(dstIP) || cfg.Filter.CheckIfInternalDomain(sni), // servers of internal domains are internal
 		Version:          parseSSL.Version,
 		Cipher:           parseSSL.Cipher,
 		Curve:            parseSSL.Curve,
@@ -158,6 +162,7 @@ func formatSSLRecord(cfg *config.Config, parseSSL *zeektypes.SSL, importTime tim
 		NextProtocol:     parseSSL.NextProtocol,
 		Established:      parseSSL.Established,
 		ServerCertFUIDs:  parseSSL.CertChainFuids,
+		ServerCertFPs:    parseSSL.CertChainFps,
 		ClientCertFUIDs:  parseSSL.ClientCertChainFuids,
 		ServerSubject:    parseSSL.Subject,
 		ServerIssuer:     parseSSL.Issuer,
@@ -199,12 +204,12 @@ func (importer *Importer) writeLinkedSSL(ctx context.Context, progress *tea.Prog
 		s.zeek_uid as zeek_uid, c.ts AS ts, s.src as src, s.src_nuid as src_nuid, s.dst as dst, s.dst_nuid as dst_nuid,
 		s.src_port as src_port, s.dst_port as dst_port, s.src_local as src_local, s.dst_local as dst_local, server_name as server_name,
 		s.version as version, s.cipher as cipher, s.curve as curve, s.resumed as resumed, s.next_protocol as next_protocol, s.established as established, 
-		s.server_cert_fuids as server_cert_fuids, client_cert_fuids, server_subject, server_issuer, client_subject, client_issuer, validation_status,
+		s.server_cert_fuids as server_cert_fuids, s.server_cert_fps as server_cert_fps, client_cert_fuids, server_subject, server_issuer, client_subject, client_issuer, validation_status,
 		ja3, ja3s,
 		-- set proto and service regardless of whether it was linked already or not
 		-- since multi-requests can use different dst ports and still have the same UID, so
 		-- it is useful to be able to see the dst ports coming from multi request entries as well
-		c.proto as proto, c.service as service,
+		c.proto as proto, c.service as service, c.sensor as sensor,
 		c.src_ip_bytes as src_ip_bytes,
 		c.dst_ip_bytes as dst_ip_bytes,
 		c.src_bytes as src_bytes,
@@ -242,7 +247,7 @@ func (importer *Importer) writeLinkedSSL(ctx context.Context, progress *tea.Prog
 			}
 			entry.ImportTime = importer.Database.ImportStartedAt
 
-			hash, err := util.NewFixedStringHash(entry.Src.To16().String(), entry.SrcNUID.String(), entry.ServerName)
+			hash, err := util.NewBeaconHash("sni", util.ConnectionIdentity{Src: entry.Src, SrcNUID: entry.SrcNUID, FQDN: entry.ServerName})
 			if err != nil {
 				log.Panicln(err)
 			}
@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+)
+
+var errMissingCertID = errors.New("blank or missing fingerprint and id fields in x509 log entry, skipping entry")
+
+type X509Entry struct {
+	ImportTime     time.Time `ch:"import_time"`
+	Timestamp      time.Time `ch:"ts"`
+	CertID         string    `ch:"cert_id"`
+	Fingerprint    string    `ch:"fingerprint"`
+	FUID           string    `ch:"fuid"`
+	Subject        string    `ch:"subject"`
+	SubjectCN      string    `ch:"subject_cn"`
+	Issuer         string    `ch:"issuer"`
+	NotValidBefore time.Time `ch:"not_valid_before"`
+	NotValidAfter  time.Time `ch:"not_valid_after"`
+	SelfSigned     bool      `ch:"self_signed"`
+	HostCert       bool      `ch:"host_cert"`
+	Sensor         string    `ch:"sensor"`
+}
+
+// parseX509 listens on a channel of raw x509 log records, formats them and sends them to be written to the database
+func parseX509(x509 <-chan zeektypes.X509, output chan<- database.Data, importTime time.Time, logDir string, numX509 *uint64) {
+	logger := zlog.GetLogger()
+
+	// loop over raw x509 channel
+	for x := range x509 {
+
+		// parse raw record as an x509 entry
+		entry, err := formatX509Record(&x, importTime)
+		if err != nil {
+			logger.Debug().Err(err).
+				Str("log_path", x.LogPath).
+				Str("timestamp", (time.Unix(int64(x.TimeStamp), 0)).String()).
+				Str("subject", x.Subject).
+				Send()
+			continue
+		}
+
+		// record which sensor this certificate was seen by
+		entry.Sensor = ParseSensor(logDir, x.LogPath)
+
+		output <- entry
+		// increment record counter
+		atomic.AddUint64(numX509, 1)
+	}
+}
+
+// formatX509Record takes a raw x509 record and formats it into the structure needed by the database.
+// Certificates aren't filtered since they aren't tied to a connection until they are joined with the ssl
+// records that reference them, and those are filtered.
+func formatX509Record(parseX509 *zeektypes.X509, importTime time.Time) (*X509Entry, error) {
+	// newer zeek versions identify certificates by their fingerprint, older versions by their file ID,
+	// which the ssl log references in cert_chain_fps and cert_chain_fuids respectively
+	certID := parseX509.Fingerprint
+	if certID == "" {
+		certID = parseX509.ID
+	}
+	if certID == "" {
+		return nil, errMissingCertID
+	}
+
+	entry := &X509Entry{
+		ImportTime:     importTime,
+		Timestamp:      time.Unix(int64(parseX509.TimeStamp), 0),
+		CertID:         certID,
+		Fingerprint:    parseX509.Fingerprint,
+		FUID:           parseX509.ID,
+		Subject:        parseX509.Subject,
+		SubjectCN:      ParseCommonName(parseX509.Subject),
+		Issuer:         parseX509.Issuer,
+		NotValidBefore: time.Unix(int64(parseX509.NotValidBefore), 0),
+		NotValidAfter:  time.Unix(int64(parseX509.NotValidAfter), 0),
+		// a certificate that was issued by its own subject wasn't signed by a certificate authority
+		SelfSigned: parseX509.Subject != "" && parseX509.Subject == parseX509.Issuer,
+		HostCert:   parseX509.HostCert,
+	}
+
+	return entry, nil
+}
+
+// ParseCommonName returns the common name (CN) of a distinguished name such as "CN=example.com,O=Example,C=US".
+// An empty string is returned if the distinguished name doesn't have a common name.
+func ParseCommonName(dn string) string {
+	// split on commas that aren't escaped
+	var attributes []string
+	start := 0
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case ',':
+			attributes = append(attributes, dn[start:i])
+			start = i + 1
+		}
+	}
+	attributes = append(attributes, dn[start:])
+
+	for _, attribute := range attributes {
+		key, value, found := strings.Cut(strings.TrimSpace(attribute), "=")
+		if found && strings.EqualFold(strings.TrimSpace(key), "CN") {
+			return strings.ReplaceAll(strings.TrimSpace(value), `\,`, ",")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/importer/zeektypes"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatX509Record(t *testing.T) {
+	importTime := time.Unix(1713500000, 0)
+
+	t.Run("Fingerprint", func(t *testing.T) {
+		entry, err := formatX509Record(&zeektypes.X509{
+			TimeStamp:      1713499000,
+			Fingerprint:    "b5ab32a1b2b2bb1e0fd9a5dcd16b0a3b0a2d2e0b87a85e0d4d0c7d7a32e1f2a3",
+			Subject:        "CN=update.example.com,O=Example\\, Inc.,C=US",
+			Issuer:         "CN=Example CA,O=Example\\, Inc.,C=US",
+			NotValidBefore: 1700000000,
+			NotValidAfter:  1731536000,
+			HostCert:       true,
+		}, importTime)
+		require.NoError(t, err)
+
+		require.Equal(t, "b5ab32a1b2b2bb1e0fd9a5dcd16b0a3b0a2d2e0b87a85e0d4d0c7d7a32e1f2a3", entry.CertID, "certificates should be identified by their fingerprint")
+		require.Equal(t, "update.example.com", entry.SubjectCN)
+		require.Equal(t, "CN=Example CA,O=Example\\, Inc.,C=US", entry.Issuer)
+		require.Equal(t, time.Unix(1700000000, 0), entry.NotValidBefore)
+		require.Equal(t, time.Unix(1731536000, 0), entry.NotValidAfter)
+		require.Equal(t, time.Unix(1713499000, 0), entry.Timestamp)
+		require.Equal(t, importTime, entry.ImportTime)
+		require.False(t, entry.SelfSigned)
+		require.True(t, entry.HostCert)
+	})
+
+	t.Run("File ID", func(t *testing.T) {
+		// older zeek versions don't log fingerprints, the ssl log references their file IDs instead
+		entry, err := formatX509Record(&zeektypes.X509{
+			TimeStamp: 1713499000,
+			ID:        "FtXzvk3Bbzh1NFMZa4",
+			Subject:   "CN=localhost",
+			Issuer:    "CN=localhost",
+		}, importTime)
+		require.NoError(t, err)
+
+		require.Equal(t, "FtXzvk3Bbzh1NFMZa4", entry.CertID)
+		require.Equal(t, "localhost", entry.SubjectCN)
+		require.True(t, entry.SelfSigned, "certificates issued by their own subject should be self-signed")
+	})
+
+	t.Run("Missing ID", func(t *testing.T) {
+		entry, err := formatX509Record(&zeektypes.X509{TimeStamp: 1713499000, Subject: "CN=localhost", Issuer: "CN=localhost"}, importTime)
+		require.ErrorIs(t, err, errMissingCertID)
+		require.Nil(t, entry)
+	})
+}
+
+func TestParseCommonName(t *testing.T) {
+	tests := []struct {
+		name     string
+		dn       string
+		expected string
+	}{
+		{name: "First Attribute", dn: "CN=*.example.com,O=Example,C=US", expected: "*.example.com"},
+		{name: "Last Attribute", dn: "C=US,O=Example,CN=example.com", expected: "example.com"},
+		{name: "Escaped Comma", dn: "O=Example\\, Inc.,CN=Example\\, Inc. Root", expected: "Example, Inc. Root"},
+		{name: "Spaces", dn: "O=Example, CN = example.com", expected: "example.com"},
+		{name: "No Common Name", dn: "O=Example,C=US", expected: ""},
+		{name: "Empty", dn: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, ParseCommonName(test.dn))
+		})
+	}
+}
@@ -63,10 +63,13 @@ type Conn struct {
 	OrigIPBytes int64 `zeek:"orig_ip_bytes" zeektype:"count" json:"orig_ip_bytes"`
 	// RespPackets counts response packets
 	RespPackets int64 `zeek:"resp_pkts" zeektype:"count" json:"resp_pkts"`
-	// RespIpBytes gives the bytecount of response data
-	RespIPBytes int64 `zeek:"resp_ip_bytes" zeektype:"count" json:"resp_ip_bytes"`
+	// RespIpBytes gives the bytecount of response data, it is nil when the field is unset
+	RespIPBytes *int64 `zeek:"resp_ip_bytes" zeektype:"count" json:"resp_ip_bytes"`
 	// TunnelParents lists tunnel parents
 	TunnelParents []string `zeek:"tunnel_parents" zeektype:"set[string]" json:"tunnel_parents"`
+	// CommunityID is the Community ID flow hash of this connection, which is added by Corelight sensors and the
+	// zeek community-id package and identifies the same flow in the logs of other tools
+	CommunityID string `zeek:"community_id" zeektype:"string" json:"community_id"`
 	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
 	AgentHostname string `zeek:"agent_hostname" zeektype:"string" json:"agent_hostname"`
 	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
@@ -0,0 +1,45 @@
+package zeektypes
+
+// EntryTypeFTP should be matched against zeekFile.EntryType()
+// before using OpenZeekReader[ZeekFTP](fs, zeekFile) to read from the file.
+const EntryTypeFTP = "ftp"
+
+// FTP provides a data structure for entries in the zeek FTP log
+type FTP struct {
+	// TimeStamp of this command
+	TimeStamp Timestamp `zeek:"ts" zeektype:"time" json:"ts"`
+	// UID is the Unique Id for this connection (generated by zeek)
+	UID string `zeek:"uid" zeektype:"string" json:"uid"`
+	// Source is the source address for this connection
+	Source string `zeek:"id.orig_h" zeektype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of this connection
+	SourcePort int `zeek:"id.orig_p" zeektype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection
+	Destination string `zeek:"id.resp_h" zeektype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `zeek:"id.resp_p" zeektype:"port" json:"id.resp_p"`
+	// User is the username for the current FTP session
+	User string `zeek:"user" zeektype:"string" json:"user"`
+	// Command is the command given by the client
+	Command string `zeek:"command" zeektype:"string" json:"command"`
+	// Arg is the argument for the command, if one is given
+	Arg string `zeek:"arg" zeektype:"string" json:"arg"`
+	// MIMEType is the sniffed mime type of the file, if the command transferred a file
+	MIMEType string `zeek:"mime_type" zeektype:"string" json:"mime_type"`
+	// FileSize is the size of the file, if the command transferred a file
+	FileSize int64 `zeek:"file_size" zeektype:"count" json:"file_size"`
+	// ReplyCode is the reply code from the server in response to the command
+	ReplyCode int64 `zeek:"reply_code" zeektype:"count" json:"reply_code"`
+	// ReplyMsg is the reply message from the server in response to the command
+	ReplyMsg string `zeek:"reply_msg" zeektype:"string" json:"reply_msg"`
+	// FUID is the file unique ID of the transferred file
+	FUID string `zeek:"fuid" zeektype:"string" json:"fuid"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `zeek:"agent_hostname" zeektype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `zeek:"agent_uuid" zeektype:"string" json:"agent_uuid"`
+	// Path of log file containing this record
+	LogPath string
+}
+
+func (f *FTP) SetLogPath(path string) { f.LogPath = path }
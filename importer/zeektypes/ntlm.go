@@ -0,0 +1,43 @@
+package zeektypes
+
+// EntryTypeNTLM should be matched against zeekFile.EntryType()
+// before using OpenZeekReader[ZeekNTLM](fs, zeekFile) to read from the file.
+const EntryTypeNTLM = "ntlm"
+
+// NTLM provides a data structure for entries in the zeek NTLM log
+type NTLM struct {
+	// TimeStamp of this authentication attempt
+	TimeStamp Timestamp `zeek:"ts" zeektype:"time" json:"ts"`
+	// UID is the Unique Id for this connection (generated by zeek)
+	UID string `zeek:"uid" zeektype:"string" json:"uid"`
+	// Source is the source address for this connection
+	Source string `zeek:"id.orig_h" zeektype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of this connection
+	SourcePort int `zeek:"id.orig_p" zeektype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection
+	Destination string `zeek:"id.resp_h" zeektype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `zeek:"id.resp_p" zeektype:"port" json:"id.resp_p"`
+	// Username is the username given by the client
+	Username string `zeek:"username" zeektype:"string" json:"username"`
+	// Hostname is the hostname given by the client
+	Hostname string `zeek:"hostname" zeektype:"string" json:"hostname"`
+	// DomainName is the domain name given by the client
+	DomainName string `zeek:"domainname" zeektype:"string" json:"domainname"`
+	// ServerNBComputerName is the NetBIOS name given by the server in a CHALLENGE
+	ServerNBComputerName string `zeek:"server_nb_computer_name" zeektype:"string" json:"server_nb_computer_name"`
+	// ServerDNSComputerName is the DNS name given by the server in a CHALLENGE
+	ServerDNSComputerName string `zeek:"server_dns_computer_name" zeektype:"string" json:"server_dns_computer_name"`
+	// ServerTreeName is the tree name given by the server in a CHALLENGE
+	ServerTreeName string `zeek:"server_tree_name" zeektype:"string" json:"server_tree_name"`
+	// Success indicates if the authentication attempt was successful
+	Success bool `zeek:"success" zeektype:"bool" json:"success"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `zeek:"agent_hostname" zeektype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `zeek:"agent_uuid" zeektype:"string" json:"agent_uuid"`
+	// Path of log file containing this record
+	LogPath string
+}
+
+func (n *NTLM) SetLogPath(path string) { n.LogPath = path }
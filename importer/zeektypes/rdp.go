@@ -0,0 +1,61 @@
+package zeektypes
+
+// EntryTypeRDP should be matched against zeekFile.EntryType()
+// before using OpenZeekReader[ZeekRDP](fs, zeekFile) to read from the file.
+const EntryTypeRDP = "rdp"
+
+// RDP provides a data structure for zeek's rdp data
+type RDP struct {
+	// TimeStamp of this connection
+	TimeStamp Timestamp `zeek:"ts" zeektype:"time" json:"ts"`
+	// UID is the Unique Id for this connection (generated by zeek)
+	UID string `zeek:"uid" zeektype:"string" json:"uid"`
+	// Source is the source address for this connection
+	Source string `zeek:"id.orig_h" zeektype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of this connection
+	SourcePort int `zeek:"id.orig_p" zeektype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection
+	Destination string `zeek:"id.resp_h" zeektype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `zeek:"id.resp_p" zeektype:"port" json:"id.resp_p"`
+	// Cookie : Cookie value used by the client machine, usually the username
+	Cookie string `zeek:"cookie" zeektype:"string" json:"cookie"`
+	// Result : Status result for the connection
+	Result string `zeek:"result" zeektype:"string" json:"result"`
+	// SecurityProtocol : Security protocol chosen by the server
+	SecurityProtocol string `zeek:"security_protocol" zeektype:"string" json:"security_protocol"`
+	// ClientChannels : The channels requested by the client
+	ClientChannels []string `zeek:"client_channels" zeektype:"vector[string]" json:"client_channels"`
+	// KeyboardLayout : Keyboard layout (language) of the client machine
+	KeyboardLayout string `zeek:"keyboard_layout" zeektype:"string" json:"keyboard_layout"`
+	// ClientBuild : RDP client version used by the client machine
+	ClientBuild string `zeek:"client_build" zeektype:"string" json:"client_build"`
+	// ClientName : Name of the client machine
+	ClientName string `zeek:"client_name" zeektype:"string" json:"client_name"`
+	// ClientDigProductID : Product ID of the client machine
+	ClientDigProductID string `zeek:"client_dig_product_id" zeektype:"string" json:"client_dig_product_id"`
+	// DesktopWidth : Desktop width of the client machine
+	DesktopWidth int `zeek:"desktop_width" zeektype:"count" json:"desktop_width"`
+	// DesktopHeight : Desktop height of the client machine
+	DesktopHeight int `zeek:"desktop_height" zeektype:"count" json:"desktop_height"`
+	// RequestedColorDepth : The color depth requested by the client in the high_color_depth field
+	RequestedColorDepth string `zeek:"requested_color_depth" zeektype:"string" json:"requested_color_depth"`
+	// CertType : If the connection is being encrypted with native RDP encryption, this is the type of cert being used
+	CertType string `zeek:"cert_type" zeektype:"string" json:"cert_type"`
+	// CertCount : The number of certs seen. X.509 can transfer an entire certificate chain
+	CertCount int `zeek:"cert_count" zeektype:"count" json:"cert_count"`
+	// CertPermanent : Indicates if the provided certificate or certificate chain is permanent or temporary
+	CertPermanent bool `zeek:"cert_permanent" zeektype:"bool" json:"cert_permanent"`
+	// EncryptionLevel : Encryption level of the connection
+	EncryptionLevel string `zeek:"encryption_level" zeektype:"string" json:"encryption_level"`
+	// EncryptionMethod : Encryption method of the connection
+	EncryptionMethod string `zeek:"encryption_method" zeektype:"string" json:"encryption_method"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `zeek:"agent_hostname" zeektype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `zeek:"agent_uuid" zeektype:"string" json:"agent_uuid"`
+	// Path of log file containing this record
+	LogPath string
+}
+
+func (r *RDP) SetLogPath(path string) { r.LogPath = path }
@@ -72,6 +72,9 @@ type SSL struct {
 	CertChainFuids []string `zeek:"cert_chain_fuids" zeektype:"vector[string]" json:"cert_chain_fuids"`
 	// ClientCertChainFuids
 	ClientCertChainFuids []string `zeek:"client_cert_chain_fuids" zeektype:"vector[string]" json:"client_cert_chain_fuids"`
+	// CertChainFps are the fingerprints of the certificates offered by the server, newer zeek versions
+	// log these instead of CertChainFuids
+	CertChainFps []string `zeek:"cert_chain_fps" zeektype:"vector[string]" json:"cert_chain_fps"`
 	// Subject
 	Subject string `zeek:"subject" zeektype:"string" json:"subject"`
 	// Issuer
@@ -0,0 +1,47 @@
+package zeektypes
+
+// EntryTypeX509 should be matched against zeekFile.EntryType()
+// before using OpenZeekReader[ZeekX509](fs, zeekFile) to read from the file.
+const EntryTypeX509 = "x509"
+
+// X509 provides a data structure for entries in the zeek x509 log
+type X509 struct {
+	// TimeStamp of when the certificate was seen
+	TimeStamp Timestamp `zeek:"ts" zeektype:"time" json:"ts"`
+	// ID is the file unique ID of the certificate, only logged by older zeek versions
+	ID string `zeek:"id" zeektype:"string" json:"id"`
+	// Fingerprint is the hash of the certificate, it matches the cert_chain_fps field of the ssl log
+	Fingerprint string `zeek:"fingerprint" zeektype:"string" json:"fingerprint"`
+	// Version is the version number of the certificate
+	Version int `zeek:"certificate.version" zeektype:"count" json:"certificate.version"`
+	// Serial is the serial number of the certificate
+	Serial string `zeek:"certificate.serial" zeektype:"string" json:"certificate.serial"`
+	// Subject is the subject distinguished name of the certificate
+	Subject string `zeek:"certificate.subject" zeektype:"string" json:"certificate.subject"`
+	// Issuer is the issuer distinguished name of the certificate
+	Issuer string `zeek:"certificate.issuer" zeektype:"string" json:"certificate.issuer"`
+	// NotValidBefore is the time that the certificate becomes valid
+	NotValidBefore Timestamp `zeek:"certificate.not_valid_before" zeektype:"time" json:"certificate.not_valid_before"`
+	// NotValidAfter is the time that the certificate expires
+	NotValidAfter Timestamp `zeek:"certificate.not_valid_after" zeektype:"time" json:"certificate.not_valid_after"`
+	// KeyAlgorithm is the name of the key algorithm
+	KeyAlgorithm string `zeek:"certificate.key_alg" zeektype:"string" json:"certificate.key_alg"`
+	// SignatureAlgorithm is the name of the signature algorithm
+	SignatureAlgorithm string `zeek:"certificate.sig_alg" zeektype:"string" json:"certificate.sig_alg"`
+	// SANDNS are the DNS entries of the subject alternative name extension
+	SANDNS []string `zeek:"san.dns" zeektype:"vector[string]" json:"san.dns"`
+	// BasicConstraintsCA indicates if the certificate belongs to a certificate authority
+	BasicConstraintsCA bool `zeek:"basic_constraints.ca" zeektype:"bool" json:"basic_constraints.ca"`
+	// HostCert indicates if the certificate was sent by the server
+	HostCert bool `zeek:"host_cert" zeektype:"bool" json:"host_cert"`
+	// ClientCert indicates if the certificate was sent by the client
+	ClientCert bool `zeek:"client_cert" zeektype:"bool" json:"client_cert"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `zeek:"agent_hostname" zeektype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `zeek:"agent_uuid" zeektype:"string" json:"agent_uuid"`
+	// Path of log file containing this record
+	LogPath string
+}
+
+func (x *X509) SetLogPath(path string) { x.LogPath = path }
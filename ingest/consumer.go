@@ -0,0 +1,167 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/activecm/rita/v5/importer"
+	"github.com/activecm/rita/v5/importer/zeektypes"
+	zlog "github.com/activecm/rita/v5/logger"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var ErrUnsupportedLogType = errors.New("unsupported zeek log type")
+var ErrInvalidBatchSize = errors.New("batch size must be greater than 0")
+var ErrInvalidFlushInterval = errors.New("flush interval must be greater than 0")
+
+// commitTimeout is how long committing a batch that was imported may take, even if the consumer is shutting down
+const commitTimeout = 30 * time.Second
+
+// BatchHandler imports a batch of records, returning an error if the batch was not fully written to the database
+type BatchHandler func(records *importer.Records) error
+
+// Consumer reads zeek records from a StreamSource and hands them off in batches
+type Consumer struct {
+	Source        StreamSource
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// NewConsumer creates a new Consumer for the given source
+func NewConsumer(source StreamSource, batchSize int, flushInterval time.Duration) (*Consumer, error) {
+	if batchSize < 1 {
+		return nil, ErrInvalidBatchSize
+	}
+	if flushInterval <= 0 {
+		return nil, ErrInvalidFlushInterval
+	}
+
+	return &Consumer{
+		Source:        source,
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+	}, nil
+}
+
+// Run reads batches of records from the source until the context is cancelled, passing each batch to the handler.
+// Messages are only committed after the handler has successfully imported the batch that contains them, so
+// a batch that fails to import will be read again the next time the consumer is started. A batch that was imported
+// is still committed if the consumer is cancelled while it is being imported, so that it isn't imported twice.
+func (c *Consumer) Run(ctx context.Context, handle BatchHandler) error {
+	logger := zlog.GetLogger()
+
+	for {
+		records, msgs, err := c.nextBatch(ctx)
+		if err != nil {
+			// stop without committing the partial batch if the consumer was cancelled
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if records.Len() > 0 {
+			if err := handle(records); err != nil {
+				return fmt.Errorf("could not import batch of streamed records: %w", err)
+			}
+		}
+
+		if len(msgs) > 0 {
+			if err := c.commit(ctx, msgs); err != nil {
+				return fmt.Errorf("could not commit batch of streamed records: %w", err)
+			}
+			logger.Debug().Int("messages", len(msgs)).Int("records", records.Len()).Msg("committed batch of streamed records")
+		}
+	}
+}
+
+// commit commits the messages of an imported batch with a context that isn't cancelled when ctx is, bounded by the
+// commit timeout
+func (c *Consumer) commit(ctx context.Context, msgs []Message) error {
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+	defer cancel()
+	return c.Source.Commit(commitCtx, msgs)
+}
+
+// nextBatch reads messages until either the batch size is reached or the flush interval has passed
+func (c *Consumer) nextBatch(ctx context.Context) (*importer.Records, []Message, error) {
+	logger := zlog.GetLogger()
+
+	records := &importer.Records{}
+	var msgs []Message
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.FlushInterval)
+	defer cancel()
+
+	for len(msgs) < c.BatchSize {
+		msg, err := c.Source.Fetch(fetchCtx)
+		if err != nil {
+			// flush the batch once the interval has passed
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+			return nil, nil, err
+		}
+
+		// skip records that can't be decoded, they are still committed so that they aren't read again
+		if err := DecodeRecord(msg, records); err != nil {
+			logger.Warn().Err(err).Str("source", msg.Source).Bytes("record", msg.Value).Msg("skipping streamed record")
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return records, msgs, nil
+}
+
+// DecodeRecord unmarshals a JSON zeek record based on its _path field and adds it to the records of that log type
+func DecodeRecord(msg Message, records *importer.Records) error {
+	logType := jsoniter.Get(msg.Value, "_path").ToString()
+
+	switch logType {
+	case importer.ConnPrefix:
+		return decodeInto(msg, &records.Conn)
+	case importer.OpenConnPrefix:
+		return decodeInto(msg, &records.OpenConn)
+	case importer.DNSPrefix:
+		return decodeInto(msg, &records.DNS)
+	case importer.HTTPPrefix:
+		return decodeInto(msg, &records.HTTP)
+	case importer.OpenHTTPPrefix:
+		return decodeInto(msg, &records.OpenHTTP)
+	case importer.SSLPrefix:
+		return decodeInto(msg, &records.SSL)
+	case importer.OpenSSLPrefix:
+		return decodeInto(msg, &records.OpenSSL)
+	case importer.RDPPrefix:
+		return decodeInto(msg, &records.RDP)
+	case importer.FTPPrefix:
+		return decodeInto(msg, &records.FTP)
+	case importer.X509Prefix:
+		return decodeInto(msg, &records.X509)
+	case importer.KerberosPrefix:
+		return decodeInto(msg, &records.Kerberos)
+	case importer.NTLMPrefix:
+		return decodeInto(msg, &records.NTLM)
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnsupportedLogType, logType)
+}
+
+// decodeInto unmarshals the message into a zeek record and appends it to the list
+func decodeInto[Z zeektypes.Conn | zeektypes.DNS | zeektypes.HTTP | zeektypes.SSL | zeektypes.RDP | zeektypes.FTP | zeektypes.X509 | zeektypes.Kerberos | zeektypes.NTLM](msg Message, list *[]Z) error {
+	var entry Z
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(msg.Value, &entry); err != nil {
+		return err
+	}
+
+	// set log path field
+	if setter, ok := any(&entry).(interface{ SetLogPath(string) }); ok {
+		setter.SetLogPath(msg.Source)
+	}
+
+	*list = append(*list, entry)
+	return nil
+}
@@ -0,0 +1,178 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/importer"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	err := godotenv.Load("../.env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	m.Run()
+}
+
+// mockSource is a StreamSource that serves a fixed list of messages and then blocks until the context is done
+type mockSource struct {
+	mu        sync.Mutex
+	msgs      []Message
+	committed []Message
+}
+
+func (m *mockSource) Fetch(ctx context.Context) (Message, error) {
+	m.mu.Lock()
+	if len(m.msgs) > 0 {
+		msg := m.msgs[0]
+		m.msgs = m.msgs[1:]
+		m.mu.Unlock()
+		return msg, nil
+	}
+	m.mu.Unlock()
+
+	<-ctx.Done()
+	return Message{}, ctx.Err()
+}
+
+func (m *mockSource) Commit(ctx context.Context, msgs []Message) error {
+	// like kafka, a commit with a cancelled context fails
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("commits should have a deadline")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committed = append(m.committed, msgs...)
+	return nil
+}
+
+func (m *mockSource) Close() error { return nil }
+
+func newMessage(value string) Message {
+	return Message{Source: "mock://zeek/0", Value: []byte(value)}
+}
+
+func TestDecodeRecord(t *testing.T) {
+	records := &importer.Records{}
+
+	err := DecodeRecord(newMessage(`{"_path":"conn","ts":1517336042.279652,"uid":"CAb1","id.orig_h":"10.0.0.1","id.orig_p":5000,"id.resp_h":"1.1.1.1","id.resp_p":443,"proto":"tcp"}`), records)
+	require.NoError(t, err, "decoding conn record should not produce an error")
+	require.Len(t, records.Conn, 1)
+	require.Equal(t, "10.0.0.1", records.Conn[0].Source)
+	require.Equal(t, 443, records.Conn[0].DestinationPort)
+	require.Equal(t, "mock://zeek/0", records.Conn[0].LogPath, "log path should be set to the message source")
+
+	err = DecodeRecord(newMessage(`{"_path":"dns","ts":1517336042.279652,"uid":"CAb2","id.orig_h":"10.0.0.1","id.resp_h":"10.0.0.53","query":"example.com"}`), records)
+	require.NoError(t, err, "decoding dns record should not produce an error")
+	require.Len(t, records.DNS, 1)
+	require.Equal(t, "example.com", records.DNS[0].Query)
+
+	err = DecodeRecord(newMessage(`{"_path":"open_ssl","ts":1517336042.279652,"uid":"CAb3","id.orig_h":"10.0.0.1","id.resp_h":"1.1.1.1","server_name":"example.com"}`), records)
+	require.NoError(t, err, "decoding open ssl record should not produce an error")
+	require.Len(t, records.OpenSSL, 1)
+
+	err = DecodeRecord(newMessage(`{"_path":"rdp","ts":1517336042.279652,"uid":"CAb4","id.orig_h":"10.0.0.1","id.resp_h":"10.0.0.2","id.resp_p":3389,"cookie":"admin","result":"Success"}`), records)
+	require.NoError(t, err, "decoding rdp record should not produce an error")
+	require.Len(t, records.RDP, 1)
+	require.Equal(t, "admin", records.RDP[0].Cookie)
+
+	err = DecodeRecord(newMessage(`{"_path":"weird","ts":1517336042.279652}`), records)
+	require.ErrorIs(t, err, ErrUnsupportedLogType, "decoding unsupported log type should produce an error")
+
+	err = DecodeRecord(newMessage(`{"_path":"conn","ts":"not a timestamp"`), records)
+	require.Error(t, err, "decoding malformed record should produce an error")
+
+	require.Equal(t, 4, records.Len())
+}
+
+func TestNewConsumer(t *testing.T) {
+	_, err := NewConsumer(&mockSource{}, 0, time.Second)
+	require.ErrorIs(t, err, ErrInvalidBatchSize)
+
+	_, err = NewConsumer(&mockSource{}, 10, 0)
+	require.ErrorIs(t, err, ErrInvalidFlushInterval)
+}
+
+func TestConsumerRun(t *testing.T) {
+	conn := `{"_path":"conn","ts":1517336042.279652,"uid":"CAb1","id.orig_h":"10.0.0.1","id.resp_h":"1.1.1.1"}`
+	dns := `{"_path":"dns","ts":1517336042.279652,"uid":"CAb2","id.orig_h":"10.0.0.1","id.resp_h":"10.0.0.53","query":"example.com"}`
+
+	t.Run("Batches Are Committed After Import", func(t *testing.T) {
+		source := &mockSource{msgs: []Message{newMessage(conn), newMessage(dns), newMessage(conn), newMessage(`{"_path":"weird"}`), newMessage(dns)}}
+		consumer, err := NewConsumer(source, 2, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var batchSizes []int
+		err = consumer.Run(ctx, func(records *importer.Records) error {
+			batchSizes = append(batchSizes, records.Len())
+			// stop once all records have been handled
+			if len(batchSizes) == 3 {
+				cancel()
+			}
+			return nil
+		})
+		require.NoError(t, err, "cancelling the consumer should not produce an error")
+
+		// the unsupported record is skipped but still committed
+		require.Equal(t, []int{2, 1, 1}, batchSizes, "records should be handled in batches of the batch size")
+		require.Len(t, source.committed, 5, "all messages should be committed")
+	})
+
+	t.Run("Imported Batch Is Committed After Shutdown", func(t *testing.T) {
+		source := &mockSource{msgs: []Message{newMessage(conn), newMessage(dns)}}
+		consumer, err := NewConsumer(source, 2, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		// the consumer is interrupted while the batch is being imported
+		ctx, cancel := context.WithCancel(context.Background())
+		err = consumer.Run(ctx, func(_ *importer.Records) error {
+			cancel()
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, source.committed, 2, "a batch that was imported should be committed so that it isn't imported again")
+	})
+
+	t.Run("Failed Batches Are Not Committed", func(t *testing.T) {
+		source := &mockSource{msgs: []Message{newMessage(conn), newMessage(dns)}}
+		consumer, err := NewConsumer(source, 2, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		errImport := errors.New("import failed")
+		err = consumer.Run(context.Background(), func(_ *importer.Records) error {
+			return errImport
+		})
+		require.ErrorIs(t, err, errImport, "import errors should be returned")
+		require.Empty(t, source.committed, "messages should not be committed if the import failed")
+	})
+
+	t.Run("Partial Batch Is Flushed After Interval", func(t *testing.T) {
+		source := &mockSource{msgs: []Message{newMessage(conn)}}
+		consumer, err := NewConsumer(source, 100, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		handled := 0
+		err = consumer.Run(ctx, func(records *importer.Records) error {
+			handled += records.Len()
+			cancel()
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, handled, "partial batch should be handled once the flush interval passes")
+		require.Len(t, source.committed, 1)
+	})
+}
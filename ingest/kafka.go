@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var ErrNoKafkaBrokers = errors.New("cannot read from kafka without any brokers")
+
+// KafkaSource reads zeek records from a kafka topic as part of a consumer group
+type KafkaSource struct {
+	reader *kafka.Reader
+}
+
+// NewKafkaSource creates a StreamSource that reads from the kafka topic in the streaming config
+func NewKafkaSource(cfg *config.Config) (*KafkaSource, error) {
+	// the kafka reader panics if it is created without any brokers
+	if len(cfg.Streaming.Kafka.Brokers) == 0 {
+		return nil, ErrNoKafkaBrokers
+	}
+
+	return &KafkaSource{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.Streaming.Kafka.Brokers,
+			Topic:   cfg.Streaming.Kafka.Topic,
+			GroupID: cfg.Streaming.Kafka.GroupID,
+			// offsets are committed manually once a batch has been written to the database
+			CommitInterval: 0,
+		}),
+	}, nil
+}
+
+// Fetch reads the next message from the topic without committing its offset
+func (k *KafkaSource) Fetch(ctx context.Context) (Message, error) {
+	msg, err := k.reader.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Source: fmt.Sprintf("kafka://%s/%d", msg.Topic, msg.Partition),
+		Value:  msg.Value,
+		Raw:    msg,
+	}, nil
+}
+
+// Commit commits the offsets of the given messages for the consumer group
+func (k *KafkaSource) Commit(ctx context.Context, msgs []Message) error {
+	kafkaMsgs := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if kafkaMsg, ok := msg.Raw.(kafka.Message); ok {
+			kafkaMsgs = append(kafkaMsgs, kafkaMsg)
+		}
+	}
+
+	if len(kafkaMsgs) == 0 {
+		return nil
+	}
+
+	return k.reader.CommitMessages(ctx, kafkaMsgs...)
+}
+
+// Close closes the kafka reader
+func (k *KafkaSource) Close() error {
+	return k.reader.Close()
+}
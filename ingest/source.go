@@ -0,0 +1,25 @@
+package ingest
+
+import (
+	"context"
+)
+
+// StreamSource is a stream of JSON encoded zeek records
+type StreamSource interface {
+	// Fetch blocks until the next message is available or the context is done
+	Fetch(ctx context.Context) (Message, error)
+	// Commit marks the messages as processed so that they are not read again
+	Commit(ctx context.Context, msgs []Message) error
+	// Close closes the connection to the stream
+	Close() error
+}
+
+// Message is a single zeek record read from a StreamSource
+type Message struct {
+	// Source describes where the message was read from and is used in place of the log file path
+	Source string
+	// Value is the JSON encoded zeek record
+	Value []byte
+	// Raw is the message as returned by the underlying client, which is used when committing
+	Raw any
+}
@@ -0,0 +1,62 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type deterministicMixtapeRow struct {
+	Hash            string  `ch:"hash"`
+	BeaconType      string  `ch:"beacon_type"`
+	ModifierName    string  `ch:"modifier_name"`
+	ModifierValue   string  `ch:"modifier_value"`
+	ModifierScore   float32 `ch:"modifier_score"`
+	Count           uint64  `ch:"count"`
+	BeaconScore     float32 `ch:"beacon_score"`
+	LongConnScore   float32 `ch:"long_conn_score"`
+	StrobeScore     float32 `ch:"strobe_score"`
+	C2OverDNSScore  float32 `ch:"c2_over_dns_score"`
+	PrevalenceScore float32 `ch:"prevalence_score"`
+	FirstSeenScore  float32 `ch:"first_seen_score"`
+}
+
+// TestDeterministicImport imports the same logs twice with --deterministic and verifies that the analysis and
+// modifier phases produce the same results both times
+func TestDeterministicImport(t *testing.T) {
+	afs := afero.NewOsFs()
+
+	cfg, err := config.ReadFileConfig(afs, ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+
+	cmd.SetImportOptions(cfg, cmd.ImportOptions{Deterministic: true})
+	t.Cleanup(func() { cmd.SetImportOptions(cfg, cmd.ImportOptions{}) })
+
+	var runs [2][]deterministicMixtapeRow
+	for i, dbName := range []string{"deterministic_first", "deterministic_second"} {
+		_, err := cmd.RunImportCmd(time.Now(), cfg, afs, "../test_data/valid_tsv", dbName, false, true)
+		require.NoError(t, err)
+
+		db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+		require.NoError(t, err)
+
+		err = db.Conn.Select(db.GetContext(), &runs[i], `--sql
+			SELECT hex(hash) AS hash, beacon_type, modifier_name, modifier_value, modifier_score, count,
+				beacon_score, long_conn_score, strobe_score, c2_over_dns_score, prevalence_score, first_seen_score
+			FROM threat_mixtape
+			ORDER BY hash, beacon_type, modifier_name, modifier_value
+		`)
+		require.NoError(t, err)
+	}
+
+	require.NotEmpty(t, runs[0], "the deterministic import should have results")
+	require.Equal(t, runs[0], runs[1], "repeated deterministic imports should produce the same results")
+}
@@ -0,0 +1,75 @@
+package integration_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDNSCorrelatedRotatingIPs verifies that the connections without an SNI to the rotating IPs of a domain are added
+// to the SNI connection of that domain, while the HTTP connections without a host header to those IPs stay in the IP
+// connection of their server
+func TestDNSCorrelatedRotatingIPs(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+	cfg.Scoring.Beacon.CorrelateDNSResolvedIPs = true
+
+	ips := []string{"52.12.0.1", "52.12.0.2", "52.12.0.3"}
+
+	// returns a JSON log line for a connection from 10.0.0.1 to dst that started at ts
+	conn := func(ts time.Time, uid string, dst string, port int) string {
+		return fmt.Sprintf(`{"ts":%d.000001,"uid":"%s","id.orig_h":"10.0.0.1","id.orig_p":51234,"id.resp_h":"%s","id.resp_p":%d,"proto":"tcp","duration":1.0,"orig_bytes":150,"resp_bytes":400,"orig_ip_bytes":200,"resp_ip_bytes":450,"conn_state":"SF"}`+"\n",
+			ts.Unix(), uid, dst, port)
+	}
+
+	var dns, ssl, http, conns strings.Builder
+	// the domain resolves to every IP that it rotates between
+	dns.WriteString(fmt.Sprintf(`{"ts":%d.000001,"uid":"D0","id.orig_h":"10.0.0.1","id.orig_p":5353,"id.resp_h":"8.8.8.8","id.resp_p":53,"proto":"udp","query":"rotating.example.com","qtype_name":"A","answers":["%s"]}`+"\n",
+		openConnTestBase.Add(-time.Minute).Unix(), strings.Join(ips, `","`)))
+
+	// the beacon rotates between the IPs, only the connections to the first one have an SNI
+	for i := 0; i < 18; i++ {
+		ts := openConnTestBase.Add(time.Duration(i) * 10 * time.Minute)
+		uid := fmt.Sprintf("CBeacon%d", i)
+		conns.WriteString(conn(ts, uid, ips[i%3], 443))
+		if i%3 == 0 {
+			ssl.WriteString(fmt.Sprintf(`{"ts":%d.000001,"uid":"%s","id.orig_h":"10.0.0.1","id.orig_p":51234,"id.resp_h":"%s","id.resp_p":443,"server_name":"rotating.example.com"}`+"\n",
+				ts.Unix(), uid, ips[0]))
+		}
+	}
+
+	// HTTP connections without a host header to the second IP
+	for i := 0; i < 3; i++ {
+		ts := openConnTestBase.Add(time.Duration(i)*10*time.Minute + 5*time.Minute)
+		uid := fmt.Sprintf("CMissingHost%d", i)
+		conns.WriteString(conn(ts, uid, ips[1], 80))
+		http.WriteString(fmt.Sprintf(`{"ts":%d.000001,"uid":"%s","id.orig_h":"10.0.0.1","id.orig_p":51234,"id.resp_h":"%s","id.resp_p":80,"trans_depth":1,"method":"GET","uri":"/","user_agent":"rotating"}`+"\n",
+			ts.Unix(), uid, ips[1]))
+	}
+
+	entries := scoopTestLogs(t, cfg, "dns_correlated_rotating_ips", map[string]string{
+		"conn.log": conns.String(),
+		"dns.log":  dns.String(),
+		"ssl.log":  ssl.String(),
+		"http.log": http.String(),
+	})
+
+	sni, ok := entries["10.0.0.1-rotating.example.com"]
+	require.True(t, ok, "the SNI connection should be analyzed")
+	require.EqualValues(t, 21, sni.Count, "the connections without an SNI to every IP of the domain should be added to the SNI connection")
+
+	missingHost, ok := entries["10.0.0.1-"+ips[1]]
+	require.True(t, ok, "the HTTP connections without a host header should stay in the IP connection of their server")
+	require.EqualValues(t, 3, missingHost.MissingHostCount, "the HTTP connections without a host header should be counted")
+	require.EqualValues(t, 0, missingHost.Count, "the connections that were added to the SNI connection shouldn't be counted again")
+
+	_, ok = entries["10.0.0.1-"+ips[2]]
+	require.False(t, ok, "the connections that were added to the SNI connection shouldn't be analyzed by their IP")
+}
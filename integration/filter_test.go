@@ -612,7 +612,7 @@ func (it *FilterTestSuite) TestFilterExternalToInternal() {
 	require.False(t, notFromConn, "min and max timestamps should be from conn table")
 	require.False(t, useCurrentTime, "first seen analysis should not use the current time")
 
-	analyzer, err := analysis.NewAnalyzer(db, cfg, importResults.ImportID[0], minTS, maxTS, minTSBeacon, maxTSBeacon, useCurrentTime, false)
+	analyzer, err := analysis.NewAnalyzer(db, cfg, importResults.ImportID[0], minTS, maxTS, minTSBeacon, maxTSBeacon, false, false)
 	require.NoError(t, err)
 
 	ctx := context.Background()
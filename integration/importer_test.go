@@ -2,6 +2,7 @@ package integration_test
 
 import (
 	"context"
+	"net"
 	"time"
 
 	"github.com/activecm/rita/v5/cmd"
@@ -559,6 +560,37 @@ func (it *ValidDatasetTestSuite) TestTableFieldsThatCannotBeUnset() {
 
 }
 
+// TestTopFindingsIPs verifies that the top findings and top beacons of an import list IPv4 addresses in dotted form
+// instead of as the IPv4-mapped IPv6 addresses that they are stored as
+// go test -v ./integration -run TestValidTSV/TestTopFindingsIPs
+func (it *ValidDatasetTestSuite) TestTopFindingsIPs() {
+	require.NotEmpty(it.T(), it.importResults.ImportID)
+
+	findings, err := it.db.GetTopFindings(it.importResults.ImportID, 10, 0)
+	require.NoError(it.T(), err)
+	require.NotEmpty(it.T(), findings, "the dataset should have strobe and beacon findings")
+	for _, finding := range findings {
+		for _, ip := range []string{finding.Src, finding.Dst} {
+			require.NotNil(it.T(), net.ParseIP(ip), "%q should be an IP address", ip)
+			require.NotContains(it.T(), ip, "::ffff:", "IPv4 addresses should not be printed as IPv4-mapped IPv6 addresses")
+		}
+	}
+
+	var numBeacons int
+	for _, importID := range it.importResults.ImportID {
+		beacons, err := it.db.GetTopBeacons(importID, 10)
+		require.NoError(it.T(), err)
+		for _, beacon := range beacons {
+			for _, ip := range []string{beacon.Src, beacon.Dst} {
+				require.NotNil(it.T(), net.ParseIP(ip), "%q should be an IP address", ip)
+				require.NotContains(it.T(), ip, "::ffff:", "IPv4 addresses should not be printed as IPv4-mapped IPv6 addresses")
+			}
+		}
+		numBeacons += len(beacons)
+	}
+	require.Positive(it.T(), numBeacons, "the dataset should have beacons")
+}
+
 // TestMixtapeFields
 // go test -v ./integration -run TestValidTSV/TestMixtapeFields
 func (it *ValidDatasetTestSuite) TestMixtapeFields() {
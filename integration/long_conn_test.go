@@ -0,0 +1,50 @@
+package integration_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLongConnDurationBounds verifies that the duration of each connection is bounded before the durations of
+// concurrent connections are totaled, instead of bounding their total
+func TestLongConnDurationBounds(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+	cfg.Scoring.LongConnectionDurationBounds = config.DurationBounds{Min: 60, Max: 3600}
+
+	// returns a conn log line for a connection from 10.0.0.1 to 52.12.0.1 that started at ts and lasted for duration
+	record := func(ts time.Time, uid string, duration float64) string {
+		return fmt.Sprintf("%d.000000\t%s\t10.0.0.1\t51234\t52.12.0.1\t443\ttcp\t%.1f\t60\t150\t100\t200\n",
+			ts.Unix(), uid, duration)
+	}
+
+	var conns strings.Builder
+	conns.WriteString(connLogHeader("conn"))
+	// four concurrent connections that each claim to have lasted longer than the maximum
+	for i := 0; i < 4; i++ {
+		conns.WriteString(record(openConnTestBase, fmt.Sprintf("CLong%d", i), 5000))
+	}
+	// two concurrent connections within the bounds
+	conns.WriteString(record(openConnTestBase.Add(time.Hour), "CPlausible0", 1800))
+	conns.WriteString(record(openConnTestBase.Add(time.Hour), "CPlausible1", 1800))
+	// a connection shorter than the minimum
+	conns.WriteString(record(openConnTestBase.Add(2*time.Hour), "CShort", 30))
+
+	entries := scoopTestLogs(t, cfg, "long_conn_duration_bounds", map[string]string{
+		"conn.log": conns.String(),
+	})
+
+	entry, ok := entries["10.0.0.1-52.12.0.1"]
+	require.True(t, ok, "the connection should be analyzed")
+
+	require.InDelta(t, 4*3600+2*1800, entry.TotalDuration, 0.0001, "each connection should be bounded before the durations are totaled")
+	require.EqualValues(t, 4, entry.ClampedDurationCount, "every connection longer than the maximum should be counted as clamped")
+}
@@ -1,13 +1,23 @@
 package integration_test
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
 	"github.com/activecm/rita/v5/importer"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -807,3 +817,65 @@ func (it *ValidDatasetTestSuite) TestLongConnections() {
 		}
 	})
 }
+
+// TestDisabledModuleViews verifies that the rare_signatures and mime_type_uris tables aren't filled while the modules
+// that read them are disabled, and that their materialized views are created again once the modules are enabled
+func TestDisabledModuleViews(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+
+	ts := openConnTestBase.Unix()
+	logs := map[string]string{
+		"ssl.log":  fmt.Sprintf(`{"ts":%d.000001,"uid":"CSSL","id.orig_h":"10.0.0.1","id.orig_p":51234,"id.resp_h":"52.12.0.1","id.resp_p":443,"server_name":"rare.example.com","ja3":"6734f37431670b3ab4292b8f60f29984"}`+"\n", ts),
+		"http.log": fmt.Sprintf(`{"ts":%d.000001,"uid":"CHTTP","id.orig_h":"10.0.0.1","id.orig_p":51235,"id.resp_h":"52.12.0.2","id.resp_p":80,"trans_depth":1,"method":"GET","host":"mime.example.com","uri":"/download.exe","user_agent":"rare","resp_mime_types":["image/png"]}`+"\n", ts),
+		"conn.log": fmt.Sprintf(`{"ts":%[1]d.000001,"uid":"CSSL","id.orig_h":"10.0.0.1","id.orig_p":51234,"id.resp_h":"52.12.0.1","id.resp_p":443,"proto":"tcp","duration":1.0,"orig_bytes":150,"resp_bytes":400,"orig_ip_bytes":200,"resp_ip_bytes":450,"conn_state":"SF"}`+"\n"+
+			`{"ts":%[1]d.000001,"uid":"CHTTP","id.orig_h":"10.0.0.1","id.orig_p":51235,"id.resp_h":"52.12.0.2","id.resp_p":80,"proto":"tcp","duration":1.0,"orig_bytes":150,"resp_bytes":400,"orig_ip_bytes":200,"resp_ip_bytes":450,"conn_state":"SF"}`+"\n", ts),
+	}
+
+	// imports the logs into a new dataset
+	importLogs := func(dbName string) {
+		afs := afero.NewMemMapFs()
+		directory := "/logs"
+		require.NoError(t, afs.Mkdir(directory, os.FileMode(0o775)))
+		for name, contents := range logs {
+			require.NoError(t, afero.WriteFile(afs, filepath.Join(directory, name), []byte(contents), os.FileMode(0o775)))
+		}
+		_, err := cmd.RunImportCmd(time.Now(), cfg, afs, directory, dbName, false, true)
+		require.NoError(t, err)
+	}
+
+	views := []string{"rare_signatures_http_mv", "rare_signatures_ssl_mv", "rare_signatures_missing_host_mv", "mime_type_uris_mv"}
+
+	// returns the number of the module views in the dataset and the number of rows in the tables that they fill
+	counts := func(dbName string) (uint64, uint64, uint64) {
+		db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+		require.NoError(t, err)
+		defer db.Close()
+
+		ctx := db.QueryParameters(clickhouse.Parameters{"database": dbName})
+		var viewCount, signatures, mimeTypeURIs uint64
+		require.NoError(t, db.Conn.QueryRow(ctx, `--sql
+			SELECT count() FROM system.tables WHERE database = {database:String} AND name IN ('`+strings.Join(views, "','")+`')
+		`).Scan(&viewCount))
+		require.NoError(t, db.Conn.QueryRow(ctx, "SELECT count() FROM rare_signatures").Scan(&signatures))
+		require.NoError(t, db.Conn.QueryRow(ctx, "SELECT count() FROM mime_type_uris").Scan(&mimeTypeURIs))
+		return viewCount, signatures, mimeTypeURIs
+	}
+
+	cfg.DisabledModules = []string{config.ModuleRareSignatures, config.ModuleMIMEMismatch}
+	importLogs("disabled_module_views")
+
+	viewCount, signatures, mimeTypeURIs := counts("disabled_module_views")
+	require.EqualValues(t, 0, viewCount, "the views of the disabled modules shouldn't exist")
+	require.EqualValues(t, 0, signatures, "rare signatures shouldn't be recorded while the module is disabled")
+	require.EqualValues(t, 0, mimeTypeURIs, "MIME type mismatches shouldn't be recorded while the module is disabled")
+
+	cfg.DisabledModules = []string{}
+	importLogs("disabled_module_views")
+
+	viewCount, signatures, mimeTypeURIs = counts("disabled_module_views")
+	require.EqualValues(t, len(views), viewCount, "the views of the enabled modules should be created again")
+	require.Positive(t, signatures, "rare signatures should be recorded once the module is enabled")
+	require.Positive(t, mimeTypeURIs, "MIME type mismatches should be recorded once the module is enabled")
+}
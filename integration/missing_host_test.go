@@ -137,7 +137,7 @@ func (it *MissingHostSuite) TestThreat() {
 
 	filter := &viewer.Filter{Src: expected.src, Dst: expected.dst}
 	min = time.Unix(0, 0)
-	query, params, _ := viewer.BuildResultsQuery(filter, 0, 10, min)
+	query, params, _ := viewer.BuildResultsQuery(filter, 0, 10, min, 0)
 	ctx = it.db.QueryParameters(params)
 	rows, err := it.db.Conn.Query(ctx, query)
 	require.NoError(t, err, "getting the mixtape results shouldn't error")
@@ -202,7 +202,7 @@ func (it *MissingHostSuite) TestThreat() {
 	})
 
 	var portProto []string
-	expectedProtoService := []string{"icmp:8/0", "80:tcp:http", "22:tcp:ssh"}
+	expectedProtoService := []string{"8/0:icmp:icmp", "80:tcp:http", "22:tcp:ssh"}
 	err = it.db.Conn.QueryRow(ctx, `--sql
 		SELECT flatten(groupArray(port_proto_service)) FROM threat_mixtape
 		WHERE src = {src:String} AND dst = {dst:String} AND toStartOfHour(last_seen) = fromUnixTimestamp({last_seen:Int64})
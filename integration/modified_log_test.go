@@ -0,0 +1,64 @@
+package integration_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAppendedLogImport verifies that importing a log that grew since it was imported only imports the records that
+// were appended to it, and stores the same records as importing the whole log once
+func TestAppendedLogImport(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+
+	var imported, appended strings.Builder
+	imported.WriteString(connLogHeader("conn"))
+	for i := 0; i < 10; i++ {
+		imported.WriteString(connRecord(openConnTestBase.Add(time.Duration(i)*5*time.Minute), fmt.Sprintf("CImported%d", i), 60, 150, 100, 200))
+		appended.WriteString(connRecord(openConnTestBase.Add(time.Duration(i)*5*time.Minute+time.Minute), fmt.Sprintf("CAppended%d", i), 60, 150, 100, 200))
+	}
+
+	afs := afero.NewMemMapFs()
+	directory := "/logs/2024-05-14"
+	path := filepath.Join(directory, "conn.00:00:00-01:00:00.log")
+	require.NoError(t, afs.MkdirAll(directory, os.FileMode(0o775)))
+
+	// import the whole log into one dataset
+	require.NoError(t, afero.WriteFile(afs, path, []byte(imported.String()+appended.String()), os.FileMode(0o775)))
+	_, err = cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "appended_log_whole", true, true)
+	require.NoError(t, err)
+	whole := getImportCounts(t, cfg, "appended_log_whole")
+	require.EqualValues(t, 20, whole.Conn, "every record should be imported")
+
+	// import the log before and after it was appended to into another
+	require.NoError(t, afero.WriteFile(afs, path, []byte(imported.String()), os.FileMode(0o775)))
+	results, err := cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "appended_log_grown", true, true)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, results.Conn)
+
+	require.NoError(t, afero.WriteFile(afs, path, []byte(imported.String()+appended.String()), os.FileMode(0o775)))
+	results, err = cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "appended_log_grown", true, false)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, results.Conn, "only the appended records should be imported")
+
+	grown := getImportCounts(t, cfg, "appended_log_grown")
+	require.Equal(t, whole.Conn, grown.Conn, "the records of the log should only be stored once")
+	require.Equal(t, whole.Uconn, grown.Uconn, "the connections of the log should only be counted once")
+	require.Equal(t, whole.PortInfo, grown.PortInfo, "the ports of the log should only be counted once")
+	require.Equal(t, whole.Histogram, grown.Histogram, "the histogram of the log should only be counted once")
+
+	// the log is unchanged since its last import, so it isn't imported again
+	_, err = cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "appended_log_grown", true, false)
+	require.Error(t, err, "the log should already be imported")
+}
@@ -0,0 +1,208 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/progressbar"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// openConnTestBase is the start time of the connections in the open connection analysis tests
+var openConnTestBase = time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+
+// connLogHeader returns the header of a conn or open_conn log with the fields used by connRecord
+func connLogHeader(path string) string {
+	return "#separator \\x09\n" +
+		"#set_separator\t,\n" +
+		"#empty_field\t(empty)\n" +
+		"#unset_field\t-\n" +
+		"#path\t" + path + "\n" +
+		"#open\t2024-05-14-00-00-00\n" +
+		"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\tduration\torig_bytes\tresp_bytes\torig_ip_bytes\tresp_ip_bytes\n" +
+		"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\tinterval\tcount\tcount\tcount\tcount\n"
+}
+
+// connRecord returns a conn log line for a connection from 10.0.0.1 to 52.12.0.1 that started at ts
+func connRecord(ts time.Time, uid string, origBytes, respBytes, origIPBytes, respIPBytes int) string {
+	return fmt.Sprintf("%d.000000\t%s\t10.0.0.1\t51234\t52.12.0.1\t443\ttcp\t60.0\t%d\t%d\t%d\t%d\n",
+		ts.Unix(), uid, origBytes, respBytes, origIPBytes, respIPBytes)
+}
+
+// scoopTestLogs imports the given logs into a new dataset and returns the SNI and IP connection analysis results,
+// keyed by their source and domain for SNI connections or their source and destination for IP connections
+func scoopTestLogs(t *testing.T, cfg *config.Config, dbName string, logs map[string]string) map[string]analysis.AnalysisResult {
+	t.Helper()
+
+	afs := afero.NewMemMapFs()
+	directory := "/logs"
+	require.NoError(t, afs.Mkdir(directory, os.FileMode(0o775)))
+	for name, contents := range logs {
+		require.NoError(t, afero.WriteFile(afs, filepath.Join(directory, name), []byte(contents), os.FileMode(0o775)))
+	}
+
+	results, err := cmd.RunImportCmd(time.Now(), cfg, afs, directory, dbName, false, true)
+	require.NoError(t, err)
+
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	require.NoError(t, err)
+
+	minTSBeacon, maxTSBeacon, _, err := db.GetBeaconMinMaxTimestamps()
+	require.NoError(t, err)
+	minTS, maxTS, _, _, err := db.GetTrueMinMaxTimestamps()
+	require.NoError(t, err)
+
+	analyzer, err := analysis.NewAnalyzer(db, cfg, results.ImportID[0], minTS, maxTS, minTSBeacon, maxTSBeacon, false, false)
+	require.NoError(t, err)
+
+	queryGroup, ctx := errgroup.WithContext(context.Background())
+	bars := progressbar.New(ctx, []*progressbar.ProgressBar{
+		progressbar.NewBar("SNI Connection Analysis", 1, progress.New(progress.WithDefaultGradient())),
+		progressbar.NewBar("IP Connection Analysis ", 2, progress.New(progress.WithDefaultGradient())),
+	}, []progressbar.Spinner{})
+
+	entries := make(map[string]analysis.AnalysisResult)
+	queryGroup.Go(func() error {
+		for entry := range analyzer.UconnChan {
+			if entry.FQDN != "" {
+				entries[entry.Src.String()+"-"+entry.FQDN] = entry
+				continue
+			}
+			entries[entry.Src.String()+"-"+entry.Dst.String()] = entry
+		}
+		return nil
+	})
+
+	queryGroup.Go(func() error {
+		defer close(analyzer.UconnChan)
+		if err := analyzer.ScoopSNIConns(ctx, bars); err != nil {
+			return err
+		}
+		return analyzer.ScoopIPConns(ctx, bars)
+	})
+
+	queryGroup.Go(func() error {
+		_, err := bars.Run()
+		return err
+	})
+
+	require.NoError(t, queryGroup.Wait())
+	return entries
+}
+
+func TestOpenConnDataSizes(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+	cfg.Scoring.Beacon.EstimateOpenConnBytes = true
+
+	var conns strings.Builder
+	conns.WriteString(connLogHeader("conn"))
+	for i := 0; i < 10; i++ {
+		conns.WriteString(connRecord(openConnTestBase.Add(time.Duration(i)*10*time.Minute), fmt.Sprintf("CClosed%d", i), 60, 150, 100, 200))
+	}
+
+	openConns := connLogHeader("open_conn") +
+		// still open, its data size is estimated from the IP bytes, not the payload bytes
+		connRecord(openConnTestBase.Add(100*time.Minute), "COpen", 400, 900, 500, 1000) +
+		// also logged as closed in this import, so it is already counted by the closed connection
+		connRecord(openConnTestBase.Add(90*time.Minute), "CClosed9", 250, 650, 300, 700)
+
+	entries := scoopTestLogs(t, cfg, "open_conn_data_sizes", map[string]string{
+		"conn.log":      conns.String(),
+		"open_conn.log": openConns,
+	})
+
+	entry, ok := entries["10.0.0.1-52.12.0.1"]
+	require.True(t, ok, "the connection should be analyzed")
+
+	expectedBytes := []float64{100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 500}
+	expectedDstBytes := []float64{200, 200, 200, 200, 200, 200, 200, 200, 200, 200, 1000}
+	require.ElementsMatch(t, expectedBytes, entry.BytesList, "open connections should add their IP bytes once to the source data sizes")
+	require.ElementsMatch(t, expectedDstBytes, entry.DstBytesList, "open connections should add their IP bytes once to the destination data sizes")
+}
+
+func TestOpenConnBeaconTimeline(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+
+	var expectedTS []uint32
+	var conns strings.Builder
+	conns.WriteString(connLogHeader("conn"))
+	for i := 0; i < 10; i++ {
+		ts := openConnTestBase.Add(time.Duration(i) * 10 * time.Minute)
+		conns.WriteString(connRecord(ts, fmt.Sprintf("CClosed%d", i), 60, 150, 100, 200))
+		expectedTS = append(expectedTS, uint32(ts.Unix()))
+	}
+
+	// the open connection is placed on the timeline at the time it started
+	openStart := openConnTestBase.Add(100 * time.Minute)
+	expectedTS = append(expectedTS, uint32(openStart.Unix()))
+
+	openConns := strings.Builder{}
+	openConns.WriteString(connLogHeader("open_conn") +
+		connRecord(openStart, "COpen", 400, 900, 500, 1000) +
+		// also logged as closed in this import, so its start time is already on the timeline
+		connRecord(openConnTestBase.Add(90*time.Minute), "CClosed9", 250, 650, 300, 700))
+
+	// the SSL connections go to another server, so that they don't take the IP connection out of the IP analysis
+	sniRecord := func(ts time.Time, uid string) string {
+		return fmt.Sprintf("%d.000000\t%s\t10.0.0.1\t51235\t52.12.0.2\t443\ttcp\t60.0\t150\t100\t200\t300\n", ts.Unix(), uid)
+	}
+	sslRecord := func(ts time.Time, uid string) string {
+		return fmt.Sprintf(`{"ts":%d.000000,"uid":"%s","id.orig_h":"10.0.0.1","id.orig_p":51235,"id.resp_h":"52.12.0.2","id.resp_p":443,"server_name":"beacon.example.com"}`+"\n",
+			ts.Unix(), uid)
+	}
+
+	var expectedSNITS []uint32
+	var ssl strings.Builder
+	for i := 0; i < 10; i++ {
+		ts := openConnTestBase.Add(time.Duration(i)*10*time.Minute + 5*time.Minute)
+		uid := fmt.Sprintf("CClosedSNI%d", i)
+		conns.WriteString(sniRecord(ts, uid))
+		ssl.WriteString(sslRecord(ts, uid))
+		expectedSNITS = append(expectedSNITS, uint32(ts.Unix()))
+	}
+
+	// the open SSL connection is placed on the SNI timeline at the time it started
+	openSNIStart := openConnTestBase.Add(105 * time.Minute)
+	expectedSNITS = append(expectedSNITS, uint32(openSNIStart.Unix()))
+	openConns.WriteString(sniRecord(openSNIStart, "COpenSNI"))
+	openSSL := sslRecord(openSNIStart, "COpenSNI") +
+		// also logged as closed in this import, so its start time is already on the timeline
+		sslRecord(openConnTestBase.Add(95*time.Minute), "CClosedSNI9")
+	openConns.WriteString(sniRecord(openConnTestBase.Add(95*time.Minute), "CClosedSNI9"))
+
+	entries := scoopTestLogs(t, cfg, "open_conn_beacon_timeline", map[string]string{
+		"conn.log":      conns.String(),
+		"open_conn.log": openConns.String(),
+		"ssl.log":       ssl.String(),
+		"open_ssl.log":  openSSL,
+	})
+
+	entry, ok := entries["10.0.0.1-52.12.0.1"]
+	require.True(t, ok, "the connection should be analyzed")
+
+	require.Equal(t, expectedTS, entry.TSList, "open connections should be placed on the beacon timeline once, at the time they started")
+	require.EqualValues(t, 2, entry.OpenCount, "both open connections should be counted")
+
+	sni, ok := entries["10.0.0.1-beacon.example.com"]
+	require.True(t, ok, "the SNI connection should be analyzed")
+
+	require.Equal(t, expectedSNITS, sni.TSList, "open SSL connections should be placed on the beacon timeline once, at the time they started")
+	require.EqualValues(t, 2, sni.OpenCount, "both open SSL connections should be counted")
+}
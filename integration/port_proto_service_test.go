@@ -0,0 +1,75 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizedPortProtoService verifies that the port:proto:service keys of a result in threat_mixtape list aliased
+// services as the service they are treated as, and list every ephemeral port under one key
+func TestNormalizedPortProtoService(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+	cfg.PortProtoService = config.PortProtoService{
+		ServiceAliases:     map[string]string{"tls": "ssl", "quic": "ssl"},
+		EphemeralPortStart: 49152,
+	}
+
+	// returns a JSON log line for a connection from 10.0.0.1 to 52.12.0.1 that started at ts and lasted for duration
+	conn := func(ts time.Time, uid string, port int, proto string, service string, duration float64) string {
+		return fmt.Sprintf(`{"ts":%d.000001,"uid":"%s","id.orig_h":"10.0.0.1","id.orig_p":51234,"id.resp_h":"52.12.0.1","id.resp_p":%d,"proto":"%s","service":"%s","duration":%.1f,"orig_bytes":150,"resp_bytes":400,"orig_ip_bytes":200,"resp_ip_bytes":450,"conn_state":"SF"}`+"\n",
+			ts.Unix(), uid, port, proto, service, duration)
+	}
+
+	var conns strings.Builder
+	// a long connection so that the pair is scored
+	conns.WriteString(conn(openConnTestBase, "CLong", 443, "tcp", "ssl", 2*3600))
+	// an aliased service on the same port is listed with the service it is treated as
+	conns.WriteString(conn(openConnTestBase.Add(time.Hour), "CAlias", 443, "tcp", "tls", 60))
+	// a service that is listed twice once both names are aliased is only listed once
+	conns.WriteString(conn(openConnTestBase.Add(2*time.Hour), "CMulti", 8443, "udp", "quic,tls", 60))
+	// every port at or above the ephemeral port start is listed under one key
+	conns.WriteString(conn(openConnTestBase.Add(3*time.Hour), "CEphemeral0", 50123, "udp", "", 60))
+	conns.WriteString(conn(openConnTestBase.Add(3*time.Hour), "CEphemeral1", 61000, "udp", "", 60))
+	// ports below the ephemeral port start are kept separate
+	conns.WriteString(conn(openConnTestBase.Add(3*time.Hour), "CRegistered", 49151, "udp", "", 60))
+
+	afs := afero.NewMemMapFs()
+	directory := "/logs"
+	require.NoError(t, afs.Mkdir(directory, os.FileMode(0o775)))
+	require.NoError(t, afero.WriteFile(afs, filepath.Join(directory, "conn.log"), []byte(conns.String()), os.FileMode(0o775)))
+
+	dbName := "normalized_port_proto_service"
+	_, err = cmd.RunImportCmd(time.Now(), cfg, afs, directory, dbName, false, true)
+	require.NoError(t, err)
+
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	require.NoError(t, err)
+
+	var portProtoService []string
+	err = db.Conn.QueryRow(db.GetContext(), `--sql
+		SELECT port_proto_service FROM threat_mixtape
+		WHERE src = '10.0.0.1' AND dst = '52.12.0.1' AND modifier_name = ''
+	`).Scan(&portProtoService)
+	require.NoError(t, err, "the connection should be a result in threat_mixtape")
+
+	require.ElementsMatch(t, []string{
+		"443:tcp:ssl",
+		"8443:udp:ssl",
+		"49152+:udp:",
+		"49151:udp:",
+	}, portProtoService, "the port:proto:service keys should be normalized")
+}
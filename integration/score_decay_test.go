@@ -0,0 +1,76 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScoreDecay verifies that the current score of a result in a rolling dataset is halved for every half life since
+// it was last seen, so that a result that stopped sorts below an ongoing one even when its final score is higher
+func TestScoreDecay(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+	cfg.Scoring.ScoreDecayHalfLifeHours = 6
+
+	// returns a conn log line for a connection from src to dst that started at ts and lasted for duration
+	record := func(ts time.Time, uid string, src string, dst string, duration float64) string {
+		return fmt.Sprintf("%d.000000\t%s\t%s\t51234\t%s\t443\ttcp\t%.1f\t60\t150\t100\t200\n",
+			ts.Unix(), uid, src, dst, duration)
+	}
+
+	var conns strings.Builder
+	conns.WriteString(connLogHeader("conn"))
+	// a long connection at the start of the dataset that stopped twelve hours (two half lives) before the newest log
+	conns.WriteString(record(openConnTestBase, "CStale", "10.0.0.1", "52.12.0.1", 10*3600))
+	// a shorter long connection that was seen at the newest log
+	conns.WriteString(record(openConnTestBase.Add(12*time.Hour), "CCurrent", "10.0.0.2", "52.12.0.2", 2*3600))
+
+	afs := afero.NewMemMapFs()
+	directory := "/logs"
+	require.NoError(t, afs.Mkdir(directory, os.FileMode(0o775)))
+	require.NoError(t, afero.WriteFile(afs, filepath.Join(directory, "conn.log"), []byte(conns.String()), os.FileMode(0o775)))
+
+	dbName := "score_decay"
+	_, err = cmd.RunImportCmd(time.Now(), cfg, afs, directory, dbName, true, true)
+	require.NoError(t, err)
+
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	require.NoError(t, err)
+
+	_, maxTS, _, _, err := db.GetTrueMinMaxTimestamps()
+	require.NoError(t, err)
+
+	items, _, err := viewer.GetResults(db, &viewer.Filter{}, 0, 10, time.Unix(0, 0))
+	require.NoError(t, err, "getting the mixtape results shouldn't error")
+	require.Len(t, items, 2, "both connections should be results")
+
+	current, ok := items[0].(*viewer.Item)
+	require.True(t, ok)
+	stale, ok := items[1].(*viewer.Item)
+	require.True(t, ok)
+
+	require.Equal(t, "10.0.0.2", current.Src.String(), "the ongoing result should sort first")
+	require.Equal(t, "10.0.0.1", stale.Src.String(), "the stale result should sort last")
+	require.Greater(t, stale.FinalScore, current.FinalScore, "the stale result should have the higher final score")
+
+	require.InDelta(t, current.FinalScore, current.CurrentScore, 0.0001, "a result seen at the newest log shouldn't decay")
+
+	halfLives := maxTS.Sub(stale.LastSeen).Hours() / cfg.Scoring.ScoreDecayHalfLifeHours
+	require.InDelta(t, 2, halfLives, 0.01, "the stale result should be two half lives older than the newest log")
+	require.InDelta(t, float64(stale.FinalScore)*math.Pow(0.5, halfLives), stale.CurrentScore, 0.0001, "the stale result should be halved for every half life since it was last seen")
+}
@@ -0,0 +1,110 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// importCounts are the number of records that an import stored in the log and aggregate tables of a dataset
+type importCounts struct {
+	Conn      uint64 `ch:"conn"`
+	Uconn     uint64 `ch:"uconn"`
+	PortInfo  uint64 `ch:"port_info"`
+	Histogram uint64 `ch:"histogram"`
+	Mixtape   uint64 `ch:"mixtape"`
+	Files     uint64 `ch:"files"`
+}
+
+// getImportCounts returns the number of records stored in the dataset
+func getImportCounts(t *testing.T, cfg *config.Config, dbName string) importCounts {
+	t.Helper()
+
+	db, err := database.ConnectToDB(context.Background(), dbName, cfg, nil)
+	require.NoError(t, err)
+
+	var counts importCounts
+	err = db.Conn.QueryRow(db.QueryParameters(clickhouse.Parameters{"database": dbName}), `
+		SELECT
+			(SELECT count() FROM conn) AS conn,
+			(SELECT countMerge(count) FROM uconn) AS uconn,
+			(SELECT countMerge(count) FROM port_info) AS port_info,
+			(SELECT sum(count) FROM big_ol_histogram) AS histogram,
+			(SELECT count() FROM threat_mixtape) AS mixtape,
+			(SELECT count() FROM {metadatabase:Identifier}.files WHERE database = {database:String}) AS files
+	`).ScanStruct(&counts)
+	require.NoError(t, err)
+
+	return counts
+}
+
+// TestResumedImport verifies that resuming an import that never finished stores the same records as an import that
+// finished the first time, instead of storing the records of the unfinished import twice
+func TestResumedImport(t *testing.T) {
+	cfg, err := config.ReadFileConfig(afero.NewOsFs(), ConfigPath)
+	require.NoError(t, err)
+	cfg.DBConnection = dockerInfo.clickhouseConnection
+
+	afs := afero.NewMemMapFs()
+	directory := "/logs/2024-05-14"
+	require.NoError(t, afs.MkdirAll(directory, os.FileMode(0o775)))
+
+	// the second hour's log also has late records from the first hour, so the first hour has records from both imports
+	var firstHour, secondHour strings.Builder
+	firstHour.WriteString(connLogHeader("conn"))
+	secondHour.WriteString(connLogHeader("conn"))
+	for i := 0; i < 10; i++ {
+		firstHour.WriteString(connRecord(openConnTestBase.Add(time.Duration(i)*5*time.Minute), fmt.Sprintf("CFirst%d", i), 60, 150, 100, 200))
+		secondHour.WriteString(connRecord(openConnTestBase.Add(time.Hour+time.Duration(i)*5*time.Minute), fmt.Sprintf("CSecond%d", i), 60, 150, 100, 200))
+	}
+	for i := 0; i < 3; i++ {
+		secondHour.WriteString(connRecord(openConnTestBase.Add(55*time.Minute+time.Duration(i)*time.Minute), fmt.Sprintf("CLate%d", i), 60, 150, 100, 200))
+	}
+	require.NoError(t, afero.WriteFile(afs, filepath.Join(directory, "conn.00:00:00-01:00:00.log"), []byte(firstHour.String()), os.FileMode(0o775)))
+	require.NoError(t, afero.WriteFile(afs, filepath.Join(directory, "conn.01:00:00-02:00:00.log"), []byte(secondHour.String()), os.FileMode(0o775)))
+
+	_, err = cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "resume_clean", true, true)
+	require.NoError(t, err)
+	clean := getImportCounts(t, cfg, "resume_clean")
+	require.EqualValues(t, 23, clean.Conn, "every record should be imported")
+
+	results, err := cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "resume_resumed", true, true)
+	require.NoError(t, err)
+	require.Len(t, results.ImportID, 2, "each hour should be imported separately")
+
+	// remove the finished record of the second hour's import, as if it had been interrupted
+	db, err := database.ConnectToDB(context.Background(), "resume_resumed", cfg, nil)
+	require.NoError(t, err)
+	err = db.Conn.Exec(db.QueryParameters(clickhouse.Parameters{
+		"database": "resume_resumed",
+		"importID": results.ImportID[1].Hex(),
+	}), `
+		DELETE FROM {metadatabase:Identifier}.imports
+		WHERE database = {database:String} AND import_id = unhex({importID:String}) AND ended_at > toDateTime(0)
+	`)
+	require.NoError(t, err)
+
+	results, err = cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "resume_resumed", true, false)
+	require.NoError(t, err)
+	require.Len(t, results.ImportID, 1, "only the unfinished hour should be imported again")
+	require.EqualValues(t, 13, results.Conn, "the unfinished hour's records should be imported again")
+
+	require.Equal(t, clean, getImportCounts(t, cfg, "resume_resumed"), "a resumed import should store the same records as a clean import")
+
+	// the cleanup only runs once, so importing again doesn't change anything
+	_, err = cmd.RunImportCmd(time.Now(), cfg, afs, "/logs", "resume_resumed", true, false)
+	require.Error(t, err, "every file should already be imported")
+	require.Equal(t, clean, getImportCounts(t, cfg, "resume_resumed"), "importing finished files again should not change the stored records")
+}
@@ -154,6 +154,14 @@ func (d *TTLTestSuite) TestHistoricalFirstSeen() {
 		require.Equal(t, expectedFirstSeen[i].FirstSeen, results[i].FirstSeen, "first seen timestamps should match for %s %s, expected: %s, got: %s", expectedFirstSeen[i].IP, expectedFirstSeen[i].FQDN, time.Unix(int64(expectedFirstSeen[i].FirstSeen), 0).UTC().String(), time.Unix(int64(results[i].FirstSeen), 0).UTC().String())
 	}
 
+	// every entry should be attributed to the network of the internal host that saw it
+	var unattributed uint64
+	err = server.Conn.QueryRow(server.GetContext(), `
+		SELECT count() FROM metadatabase.historical_first_seen WHERE nuid = toUUID('00000000-0000-0000-0000-000000000000')
+	`).Scan(&unattributed)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, unattributed, "all historical first seen entries should have a network UUID")
+
 	// import the future mock data
 	_, err = cmd.RunImportCmd(time.Now(), d.cfg, afero.NewOsFs(), futureLogDir, "test_historical", true, true)
 	require.NoError(t, err, "importing data should not produce an error")
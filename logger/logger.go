@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"log/syslog"
@@ -13,6 +14,12 @@ import (
 	"github.com/rs/zerolog/pkgerrors"
 )
 
+// log formats that can be selected with the LOG_FORMAT environment variable
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 var once sync.Once
 var zLogger zerolog.Logger
 var DebugMode bool
@@ -42,11 +49,20 @@ func GetLogger() zerolog.Logger {
 		zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
-		// create console writer
+		// get log format, defaulting to human readable text
+		logFormat, err := ParseFormat(os.Getenv("LOG_FORMAT"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// create console writer, json lines are written to stdout as is
 		var output io.Writer = zerolog.ConsoleWriter{
 			Out:        os.Stdout,
 			TimeFormat: time.RFC3339,
 		}
+		if logFormat == FormatJSON {
+			output = os.Stdout
+		}
 		tmpLogger := zerolog.New(output).With().Timestamp().Logger()
 
 		// get logging configuration from environment variables
@@ -115,6 +131,24 @@ func GetLogger() zerolog.Logger {
 	return zLogger
 }
 
+// WithImport returns a logger that adds the database and import ID to each event so that
+// the events of an import can be correlated with each other
+func WithImport(database string, importID string) zerolog.Logger {
+	return GetLogger().With().Str("database", database).Str("import_id", importID).Logger()
+}
+
+// ParseFormat validates the value of the LOG_FORMAT environment variable, an empty value selects text
+func ParseFormat(format string) (string, error) {
+	switch format {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("environment variable LOG_FORMAT must be %q or %q, got %q", FormatText, FormatJSON, format)
+	}
+}
+
 // WriteLevel writes the given bytes to the writer if the level is greater than or equal to the LevelWriterAdapter's Level
 func (lw LevelWriterAdapter) WriteLevel(l zerolog.Level, p []byte) (n int, err error) {
 	if l >= lw.Level {
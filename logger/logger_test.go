@@ -28,3 +28,29 @@ func TestLoggerNil(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "Unset", format: "", expected: FormatText},
+		{name: "Text", format: "text", expected: FormatText},
+		{name: "JSON", format: "json", expected: FormatJSON},
+		{name: "Invalid", format: "yaml", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, err := ParseFormat(test.format)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, format)
+		})
+	}
+}
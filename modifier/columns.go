@@ -0,0 +1,197 @@
+package modifier
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	"github.com/activecm/rita/v5/util"
+)
+
+// columnModifier is implemented by the built-in modifiers whose scores are stored in a column of each result instead
+// of in modifier rows, since refresh-modifiers recomputes prevalence and first seen in place and the viewer and exports
+// break the final score down by these columns
+type columnModifier interface {
+	Modifier
+	column() string
+}
+
+// threatIntelModifier scores the results whose external host or domain is on a threat intel feed
+type threatIntelModifier struct {
+	score float32
+}
+
+// Name returns the name of the modifier
+func (m *threatIntelModifier) Name() string { return THREAT_INTEL_MODIFIER_NAME }
+
+func (m *threatIntelModifier) column() string { return "threat_intel_score" }
+
+// Prepare returns the modifier with the threat intel score of the config
+func (m *threatIntelModifier) Prepare(_ context.Context, runner *Runner) (Modifier, error) {
+	return &threatIntelModifier{score: runner.Config.Scoring.ThreatIntelImpact.Score}, nil
+}
+
+// Score returns the threat intel score if the result was flagged as threat intel during analysis
+func (m *threatIntelModifier) Score(_ context.Context, row *analysis.ThreatMixtape) (float32, string) {
+	if !row.ThreatIntel {
+		return 0, ""
+	}
+	return m.score, ""
+}
+
+// prevalenceModifier scores the results by the fraction of the network that made them
+type prevalenceModifier struct {
+	modifiers config.Modifiers
+}
+
+// Name returns the name of the modifier
+func (m *prevalenceModifier) Name() string { return PREVALENCE_MODIFIER_NAME }
+
+func (m *prevalenceModifier) column() string { return "prevalence_score" }
+
+// Prepare returns the modifier with the prevalence thresholds of the config
+func (m *prevalenceModifier) Prepare(_ context.Context, runner *Runner) (Modifier, error) {
+	return &prevalenceModifier{modifiers: runner.Config.Modifiers}, nil
+}
+
+// Score returns the prevalence score of the result
+func (m *prevalenceModifier) Score(_ context.Context, row *analysis.ThreatMixtape) (float32, string) {
+	return calculatePrevalenceScore(m.modifiers, row.Prevalence), ""
+}
+
+// firstSeenModifier scores the results by how long ago their external host or domain was first seen
+type firstSeenModifier struct {
+	modifiers config.Modifiers
+
+	// first seen is only scored for rolling datasets
+	rolling bool
+
+	// the time that the days since a result was first seen are counted up to
+	relativeTime time.Time
+
+	// connections first seen before this time are not given the first seen score increase
+	graceEnd time.Time
+}
+
+// newFirstSeenModifier returns the first seen modifier that scores results against the current timestamps of the dataset
+func newFirstSeenModifier(db *database.DB, cfg *config.Config, rolling bool) (*firstSeenModifier, error) {
+	_, maxTS, _, useCurrentTime, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		return nil, err
+	}
+
+	var firstSeenMaxTS time.Time
+	if !useCurrentTime {
+		firstSeenMaxTS = maxTS
+	}
+
+	// determine the end of the first seen grace window, which starts at the beginning of the dataset
+	var graceEnd time.Time
+	if cfg.Modifiers.FirstSeenGraceHours > 0 {
+		datasetMinTS, err := db.GetDatasetMinTimestamp()
+		if err != nil {
+			return nil, err
+		}
+		graceEnd = datasetMinTS.Add(time.Duration(float64(cfg.Modifiers.FirstSeenGraceHours) * float64(time.Hour)))
+	}
+
+	return &firstSeenModifier{
+		modifiers:    cfg.Modifiers,
+		rolling:      rolling,
+		relativeTime: util.GetRelativeFirstSeenTimestamp(useCurrentTime, firstSeenMaxTS),
+		graceEnd:     graceEnd,
+	}, nil
+}
+
+// Name returns the name of the modifier
+func (m *firstSeenModifier) Name() string { return FIRST_SEEN_MODIFIER_NAME }
+
+func (m *firstSeenModifier) column() string { return "first_seen_score" }
+
+// Prepare returns the modifier that scores the results against the timestamps of the imported dataset
+func (m *firstSeenModifier) Prepare(_ context.Context, runner *Runner) (Modifier, error) {
+	return newFirstSeenModifier(runner.Database, runner.Config, runner.Database.Rolling)
+}
+
+// Score returns the first seen score of the result
+func (m *firstSeenModifier) Score(_ context.Context, row *analysis.ThreatMixtape) (float32, string) {
+	if !m.rolling {
+		return 0, ""
+	}
+	daysSinceFirstSeen := float32(m.relativeTime.Sub(row.FirstSeenHistorical).Hours() / 24)
+	return calculateFirstSeenScore(m.modifiers, daysSinceFirstSeen, row.FirstSeenHistorical, m.graceEnd), ""
+}
+
+// c2OverDNSDirectConnModifier scores the C2 over DNS results whose domain was only connected to by the hosts that
+// queried it
+type c2OverDNSDirectConnModifier struct {
+	score float32
+}
+
+// Name returns the name of the modifier
+func (m *c2OverDNSDirectConnModifier) Name() string { return C2_OVER_DNS_DIRECT_CONN_MODIFIER_NAME }
+
+func (m *c2OverDNSDirectConnModifier) column() string { return "c2_over_dns_direct_conn_score" }
+
+// Prepare returns the modifier with the direct connection score of the config
+func (m *c2OverDNSDirectConnModifier) Prepare(_ context.Context, runner *Runner) (Modifier, error) {
+	return &c2OverDNSDirectConnModifier{score: runner.Config.Modifiers.C2OverDNSDirectConnScoreIncrease}, nil
+}
+
+// Score returns the direct connection score if the result is a C2 over DNS result that only the querying hosts connected to
+func (m *c2OverDNSDirectConnModifier) Score(_ context.Context, row *analysis.ThreatMixtape) (float32, string) {
+	if row.BeaconType != "dns" || !shouldHaveC2OverDNSDirectConnModifier(row.DirectConns, row.QueriedBy) {
+		return 0, ""
+	}
+	return m.score, ""
+}
+
+// calculateFirstSeenScore returns the first seen modifier score for a connection based on the number of days since it was first seen.
+// Connections first seen before the end of the grace window only look new because the dataset just started, so they are not boosted.
+func calculateFirstSeenScore(modifiers config.Modifiers, daysSinceFirstSeen float32, firstSeen time.Time, graceEnd time.Time) float32 {
+	switch {
+	case daysSinceFirstSeen <= modifiers.FirstSeenIncreaseThreshold:
+		if firstSeen.Before(graceEnd) {
+			return 0
+		}
+		return modifiers.FirstSeenScoreIncrease
+	case daysSinceFirstSeen >= modifiers.FirstSeenDecreaseThreshold:
+		return -1 * modifiers.FirstSeenScoreDecrease
+	}
+	return 0
+}
+
+// calculatePrevalenceScore returns the prevalence modifier score for a connection based on the fraction of the network
+// that made it. Rare connections are boosted and common ones are reduced.
+func calculatePrevalenceScore(modifiers config.Modifiers, prevalence float32) float32 {
+	switch {
+	case prevalence <= modifiers.PrevalenceIncreaseThreshold:
+		return modifiers.PrevalenceScoreIncrease
+	case prevalence >= modifiers.PrevalenceDecreaseThreshold:
+		return -1 * modifiers.PrevalenceScoreDecrease
+	}
+	return 0
+}
+
+// shouldHaveC2OverDNSDirectConnModifier returns true if no ips other than the ones in queriedby made connections to this domain
+func shouldHaveC2OverDNSDirectConnModifier(directConns, queriedBy []net.IP) bool {
+	if len(queriedBy) > 0 {
+		queried := make(map[string]struct{})
+		for _, ip := range queriedBy {
+			queried[ip.String()] = struct{}{}
+		}
+
+		// check for any ips in direct conns that aren't in queried by
+		for _, ip := range directConns {
+			if _, ok := queried[ip.String()]; !ok {
+				return false
+			}
+		}
+
+	}
+	// apply direct conn modifier if no ips other than the ones in queried by made connections to this domain
+	return true
+}
@@ -0,0 +1,144 @@
+package modifier
+
+import (
+	"context"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	err := godotenv.Load("../.env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	m.Run()
+}
+
+func TestCalculatePrevalenceScore(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+	modifiers := cfg.Modifiers
+
+	require.Equal(t, modifiers.PrevalenceScoreIncrease, calculatePrevalenceScore(modifiers, 0), "connections made by no other hosts should be boosted")
+	require.Equal(t, modifiers.PrevalenceScoreIncrease, calculatePrevalenceScore(modifiers, modifiers.PrevalenceIncreaseThreshold))
+	require.Equal(t, -1*modifiers.PrevalenceScoreDecrease, calculatePrevalenceScore(modifiers, modifiers.PrevalenceDecreaseThreshold))
+	require.Equal(t, -1*modifiers.PrevalenceScoreDecrease, calculatePrevalenceScore(modifiers, 1), "connections made by every host should be reduced")
+	require.Zero(t, calculatePrevalenceScore(modifiers, (modifiers.PrevalenceIncreaseThreshold+modifiers.PrevalenceDecreaseThreshold)/2))
+}
+
+func TestCalculateFirstSeenScore(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+	modifiers := cfg.Modifiers
+
+	datasetStart := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		daysSinceFirstSeen float32
+		firstSeen          time.Time
+		graceEnd           time.Time
+		expectedScore      float32
+	}{
+		{
+			name:               "Recently Seen, No Grace Window",
+			daysSinceFirstSeen: 1,
+			firstSeen:          datasetStart,
+			expectedScore:      modifiers.FirstSeenScoreIncrease,
+		},
+		{
+			name:               "Recently Seen, Within Grace Window",
+			daysSinceFirstSeen: 1,
+			firstSeen:          datasetStart.Add(30 * time.Minute),
+			graceEnd:           datasetStart.Add(time.Hour),
+			expectedScore:      0,
+		},
+		{
+			name:               "Recently Seen, After Grace Window",
+			daysSinceFirstSeen: 1,
+			firstSeen:          datasetStart.Add(2 * time.Hour),
+			graceEnd:           datasetStart.Add(time.Hour),
+			expectedScore:      modifiers.FirstSeenScoreIncrease,
+		},
+		{
+			name:               "Recently Seen, At End of Grace Window",
+			daysSinceFirstSeen: 1,
+			firstSeen:          datasetStart.Add(time.Hour),
+			graceEnd:           datasetStart.Add(time.Hour),
+			expectedScore:      modifiers.FirstSeenScoreIncrease,
+		},
+		{
+			name:               "Seen Long Ago, Within Grace Window",
+			daysSinceFirstSeen: modifiers.FirstSeenDecreaseThreshold + 1,
+			firstSeen:          datasetStart,
+			graceEnd:           datasetStart.Add(time.Hour),
+			expectedScore:      -1 * modifiers.FirstSeenScoreDecrease,
+		},
+		{
+			name:               "Between Thresholds",
+			daysSinceFirstSeen: (modifiers.FirstSeenIncreaseThreshold + modifiers.FirstSeenDecreaseThreshold) / 2,
+			firstSeen:          datasetStart,
+			expectedScore:      0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := calculateFirstSeenScore(modifiers, test.daysSinceFirstSeen, test.firstSeen, test.graceEnd)
+			require.InDelta(t, test.expectedScore, score, 0.0001, "first seen score should match expected value")
+		})
+	}
+}
+
+func TestColumnModifierScore(t *testing.T) {
+	cfg, err := config.GetDefaultConfig()
+	require.NoError(t, err)
+
+	threatIntel := &threatIntelModifier{score: cfg.Scoring.ThreatIntelImpact.Score}
+	score, _ := threatIntel.Score(context.Background(), &analysis.ThreatMixtape{ThreatIntel: true})
+	require.InDelta(t, cfg.Scoring.ThreatIntelImpact.Score, score, 0.0001, "results flagged as threat intel should get the threat intel score")
+	score, _ = threatIntel.Score(context.Background(), &analysis.ThreatMixtape{})
+	require.Zero(t, score)
+
+	querier := net.ParseIP("10.0.0.1")
+	directConn := &c2OverDNSDirectConnModifier{score: cfg.Modifiers.C2OverDNSDirectConnScoreIncrease}
+	row := analysis.ThreatMixtape{BeaconType: "dns", AnalysisResult: analysis.AnalysisResult{DirectConns: []net.IP{querier}, QueriedBy: []net.IP{querier}}}
+	score, _ = directConn.Score(context.Background(), &row)
+	require.InDelta(t, cfg.Modifiers.C2OverDNSDirectConnScoreIncrease, score, 0.0001, "domains only connected to by the querying hosts should be boosted")
+
+	row.DirectConns = append(row.DirectConns, net.ParseIP("10.0.0.2"))
+	score, _ = directConn.Score(context.Background(), &row)
+	require.Zero(t, score, "domains connected to by hosts that didn't query them shouldn't be boosted")
+
+	row.BeaconType = "sni"
+	row.DirectConns = []net.IP{querier}
+	score, _ = directConn.Score(context.Background(), &row)
+	require.Zero(t, score, "only C2 over DNS results should be boosted")
+
+	firstSeen := &firstSeenModifier{modifiers: cfg.Modifiers, relativeTime: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)}
+	row.FirstSeenHistorical = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	score, _ = firstSeen.Score(context.Background(), &row)
+	require.Zero(t, score, "first seen should only be scored for rolling datasets")
+
+	firstSeen.rolling = true
+	score, _ = firstSeen.Score(context.Background(), &row)
+	require.InDelta(t, cfg.Modifiers.FirstSeenScoreIncrease, score, 0.0001, "results first seen a day ago should be boosted")
+
+	// every column modifier writes to a different column of the results
+	columns := map[string]bool{}
+	for _, mod := range RegisteredModifiers() {
+		if col, ok := mod.(columnModifier); ok {
+			require.False(t, columns[col.column()], "column %s should only be written by one modifier", col.column())
+			columns[col.column()] = true
+		}
+	}
+	require.Len(t, columns, 4)
+}
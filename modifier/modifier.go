@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/activecm/rita/v5/analysis"
@@ -20,17 +21,38 @@ import (
 
 const RARE_SIGNATURE_MODIFIER_NAME = "rare_signature"
 const MIME_TYPE_MISMATCH_MODIFIER_NAME = "mime_type_mismatch"
+const RDP_FAN_OUT_MODIFIER_NAME = "rdp_fan_out"
+const FAILED_CONN_MODIFIER_NAME = "failed_conn"
+const DGA_NXDOMAIN_MODIFIER_NAME = "dga_nxdomain"
+const DNS_SUBDOMAIN_ENTROPY_MODIFIER_NAME = "dns_subdomain_entropy"
+const LONG_CONN_SESSIONS_MODIFIER_NAME = "long_conn_sessions"
+const FTP_UPLOAD_VOLUME_MODIFIER_NAME = "ftp_upload_volume"
+const FTP_SCRIPTED_UPLOAD_MODIFIER_NAME = "ftp_scripted_upload"
+const SUSPICIOUS_CERT_MODIFIER_NAME = "suspicious_cert"
+const KERBEROS_ANOMALY_MODIFIER_NAME = "kerberos_anomaly"
+const NTLM_ANOMALY_MODIFIER_NAME = "ntlm_anomaly"
+const DNS_FLOOD_MODIFIER_NAME = "dns_flood"
+const CNAME_CHAIN_MODIFIER_NAME = "cname_chain"
+const THREAT_INTEL_MODIFIER_NAME = config.ModuleThreatIntel
+const PREVALENCE_MODIFIER_NAME = config.ModulePrevalence
+const FIRST_SEEN_MODIFIER_NAME = config.ModuleFirstSeen
+const C2_OVER_DNS_DIRECT_CONN_MODIFIER_NAME = "c2_over_dns_direct_conn"
 
 // we must batch if we want all of the modifiers pre-scored in one row
 // we don't need to if we don't need them all in the same row
 
-type Modifier struct {
+// Runner applies the registered modifiers to the results of an import
+type Runner struct {
 	Database        *database.DB
 	ImportID        util.FixedString
 	Config          *config.Config
 	ModifierWorkers int
 	minTS           time.Time
 
+	// prepares the modifiers one at a time and scores the results in hash order, so that the modifier rows are
+	// written in the same order every time
+	deterministic bool
+
 	writer *database.BulkWriter
 }
 
@@ -48,68 +70,204 @@ type ThreatModifier struct {
 	ModifierScore float32          `ch:"modifier_score"`
 }
 
-func NewModifier(db *database.DB, cfg *config.Config, importID util.FixedString, minTS time.Time) (*Modifier, error) {
+func NewRunner(db *database.DB, cfg *config.Config, importID util.FixedString, minTS time.Time, deterministic bool) (*Runner, error) {
 	// create a rate limiter to control the rate of writing to the database
 	limiter := rate.NewLimiter(5, 5)
 
-	return &Modifier{
+	return &Runner{
 		Database:        db,
 		ImportID:        importID,
 		Config:          cfg,
 		ModifierWorkers: 1,
 		minTS:           minTS,
+		deterministic:   deterministic,
 		writer: database.NewBulkWriter(
 			db, cfg, 1, db.GetSelectedDB(), "threat_mixtape", "INSERT INTO {database:Identifier}.threat_mixtape", limiter, false,
 		),
 	}, nil
 }
 
-func (modifier *Modifier) Modify() error {
-	logger := zlog.GetLogger()
+// MinTS returns the start of the window that the results of this import were analyzed over
+func (runner *Runner) MinTS() time.Time {
+	return runner.minTS
+}
+
+// Modify applies every registered modifier to the results of the import and writes the modifiers that matched
+func (runner *Runner) Modify() error {
+	logger := zlog.WithImport(runner.Database.GetSelectedDB(), runner.ImportID.Hex())
 
 	// log the start time of the modifier detection
 	start := time.Now()
 	logger.Debug().Msg("Starting Modifier")
 
-	modifier.writer.Start(0)
+	runner.writer.Start(0)
 	// create an error group to manage the modifier threads
 	modifierErrGroup, ctx := errgroup.WithContext(context.Background())
+	if runner.deterministic {
+		modifierErrGroup.SetLimit(1)
+		runner.Database = runner.Database.SingleThreaded()
+	}
 
-	// kick off individual modifier threads
-	modifierErrGroup.Go(func() error {
-		err := modifier.detectRareSignature(ctx)
-		return err
-	})
+	// prepare each modifier in its own thread, since most of them query the dataset before they can score results
+	modifiers := enabledModifiers(runner.Config, RegisteredModifiers())
+	scorers := make([]Modifier, len(modifiers))
+	for i, mod := range modifiers {
+		scorers[i] = mod
+		preparer, ok := mod.(Preparer)
+		if !ok {
+			continue
+		}
 
-	modifierErrGroup.Go(func() error {
-		err := modifier.detectMIMETypeMismatch(ctx)
-		return err
-	})
+		modifierErrGroup.Go(func() error {
+			scorer, err := preparer.Prepare(ctx, runner)
+			if err != nil {
+				return fmt.Errorf("could not prepare %s modifier: %w", mod.Name(), err)
+			}
+			scorers[i] = scorer
+			return nil
+		})
+	}
 
-	// wait for all modifier threads to finish
-	if err := modifierErrGroup.Wait(); err != nil {
-		logger.Fatal().Err(err).Msg("could not perform modifier detection")
+	// wait for all modifier threads to finish, then score the results with every modifier
+	err := modifierErrGroup.Wait()
+	if err == nil {
+		err = runner.scoreResults(ctx, modifiers, scorers)
+	}
+
+	// close the modifier writer on every exit path, nothing writes to it once the results have been scored
+	if writerErr := runner.writer.Close(); err == nil && writerErr != nil {
+		err = fmt.Errorf("could not write modifier results: %w", writerErr)
+	}
+
+	if err != nil {
+		// queries that were cancelled by the analysis timeout are reported by the caller
+		if runner.Database.GetContext().Err() != nil {
+			logger.Error().Err(err).Msg("modifier detection was cancelled")
+			return err
+		}
+		logger.Error().Err(err).Msg("could not perform modifier detection")
 		return err
 	}
 
-	modifier.writer.Close()
 	// log the end time of the modifer detection
 	end := time.Now()
 	diff := time.Since(start)
-	logger.Info().Time("modification_began", start).Time("modification_finished", end).Str("elapsed_time", diff.String()).Msg("Finished Modification! 🎉")
+	logger.Info().Str("phase", "modifier").Dur("duration", diff).Time("modification_began", start).Time("modification_finished", end).Str("elapsed_time", diff.String()).Msg("Finished Modification! 🎉")
 
 	return nil
 }
 
-func (modifier *Modifier) detectRareSignature(ctx context.Context) error {
+// scoreResults passes each result of the import to every modifier and writes a modifier row for each one that matched.
+// The names are taken from the registered modifiers, since prepared scorers don't have to be the same type. The scores
+// of the built-in modifiers that are stored in a column of each result are written to the results once every result
+// has been scored.
+func (runner *Runner) scoreResults(ctx context.Context, modifiers []Modifier, scorers []Modifier) error {
+	// the score columns of the column modifiers, in the order that the modifiers are scored
+	var columns []string
+	for _, scorer := range scorers {
+		if col, ok := scorer.(columnModifier); ok {
+			columns = append(columns, col.column())
+		}
+	}
+	var columnScores []database.ResultScores
+
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"import_id": runner.ImportID.Hex(),
+	})
+
+	query := `--sql
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, server_ips, last_seen, port_proto_service, sensor, count,
+			beacon_type, beacon_score, total_duration, long_conn_score, strobe_score, c2_over_dns_score,
+			threat_intel, prevalence, first_seen_historical, direct_conns, queried_by
+		FROM threat_mixtape
+		WHERE modifier_name = '' -- score only non-modifier rows to avoid duplicating results
+		AND import_id = unhex({import_id:String}) -- score only the results for this import
+	`
+	if runner.deterministic {
+		query += "ORDER BY hash, beacon_type, last_seen"
+	}
+
+	rows, err := runner.Database.Conn.Query(chCtx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			var row analysis.ThreatMixtape
+			if err := rows.ScanStruct(&row); err != nil {
+				return fmt.Errorf("could not read result for modifier detection: %w", err)
+			}
+
+			scores := make([]float32, 0, len(columns))
+			scored := false
+			for i, scorer := range scorers {
+				score, value := scorer.Score(ctx, &row)
+				if _, ok := scorer.(columnModifier); ok {
+					scores = append(scores, score)
+					scored = scored || score != 0
+					continue
+				}
+				if score == 0 && value == "" {
+					continue
+				}
+				runner.writer.WriteChannel <- runner.newModifierRow(&row, modifiers[i].Name(), score, value)
+			}
+
+			// the score columns of the results are written as 0 during analysis, so only the scored results are updated
+			if scored {
+				columnScores = append(columnScores, database.ResultScores{Hash: row.Hash, Scores: scores})
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return runner.Database.UpdateResultScores(runner.ImportID, columns, columnScores)
+}
+
+// newModifierRow returns the modifier row for a result, only the fields that identify the result are copied so
+// that the modifier row doesn't change the scores of the result when the rows are combined
+func (runner *Runner) newModifierRow(row *analysis.ThreatMixtape, name string, score float32, value string) *analysis.ThreatMixtape {
+	var res analysis.ThreatMixtape
+	res.Hash = row.Hash
+	res.Src = row.Src
+	res.SrcNUID = row.SrcNUID
+	res.Dst = row.Dst
+	res.DstNUID = row.DstNUID
+	res.FQDN = row.FQDN
+	res.LastSeen = row.LastSeen
+
+	// set analyzed at time to the time the import was started
+	res.AnalyzedAt = runner.Database.ImportStartedAt.Truncate(time.Microsecond)
+
+	// set the first seen timestamp to the beginning of the Unix epoch because ClickHouse is being
+	// finicky with these fields not being directly set
+	res.FirstSeenHistorical = time.Unix(0, 0)
+
+	res.ImportID = runner.ImportID
+	res.ModifierName = name
+	res.ModifierScore = score
+	res.ModifierValue = value
+
+	return &res
+}
+
+func detectRareSignature(ctx context.Context, runner *Runner) (matchSet, error) {
 	logger := zlog.GetLogger()
 	logger.Debug().Msg("Starting detection of rare signatures...")
-	chCtx := modifier.Database.QueryParameters(clickhouse.Parameters{
-		"min_ts":    fmt.Sprintf("%d", modifier.minTS.UTC().Unix()),
-		"import_id": modifier.ImportID.Hex(),
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
 	})
 
-	rows, err := modifier.Database.Conn.Query(chCtx, `--sql
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
 	WITH rare_sig_modifiers AS (
 		SELECT src, src_nuid, dst, dst_nuid, fqdn, 
 			   signature as modifier_value, 
@@ -137,52 +295,42 @@ func (modifier *Modifier) detectRareSignature(ctx context.Context) error {
 	`)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	matches := matchSet{}
 	for rows.Next() {
 		select {
 		// abort this function if the context was cancelled
 		case <-ctx.Done():
 			logger.Warn().Msg("cancelling rare signature modifier query")
 			rows.Close()
-			return ctx.Err()
+			return nil, ctx.Err()
 		default:
 			var res analysis.ThreatMixtape
 			if err := rows.ScanStruct(&res); err != nil {
 				// return error and cancel all uconn analysis
-				return fmt.Errorf("could not read entry for rare signature modifier detection: %w", err)
+				return nil, fmt.Errorf("could not read entry for rare signature modifier detection: %w", err)
 			}
 
-			res.AnalyzedAt = modifier.Database.ImportStartedAt.Truncate(time.Microsecond)
-
-			// set the first and last timestamps to the beginning of the Unix epoch because ClickHouse is being
-			// finicky with these fields not being directly set
-			beginningEpoch := time.Unix(0, 0)
-			res.FirstSeenHistorical = beginningEpoch
-			// res.LastSeen = beginningEpoch
-
-			res.ImportID = modifier.ImportID
-			res.ModifierName = RARE_SIGNATURE_MODIFIER_NAME
-			res.ModifierScore = modifier.Config.Modifiers.RareSignatureScoreIncrease
-			// send the unique sni connections to the uconn analysis channel
-			modifier.writer.WriteChannel <- &res
+			res.ModifierScore = runner.Config.Modifiers.RareSignatureScoreIncrease
+			matches.add(&res)
 		}
 	}
 	rows.Close()
 
-	return nil
+	return matches, nil
 }
 
-func (modifier *Modifier) detectMIMETypeMismatch(ctx context.Context) error {
+func detectMIMETypeMismatch(ctx context.Context, runner *Runner) (matchSet, error) {
 	logger := zlog.GetLogger()
 	logger.Debug().Msg("Starting detection of MIME type/URI mismatch...")
-	chCtx := modifier.Database.QueryParameters(clickhouse.Parameters{
-		"min_ts":    fmt.Sprintf("%d", modifier.minTS.UTC().Unix()),
-		"import_id": modifier.ImportID.Hex(),
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
 	})
 
-	rows, err := modifier.Database.Conn.Query(chCtx, `--sql
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
 		WITH totaled_mimeuri AS (
 			SELECT hash, countMerge(mismatch_count) as mismatch_count
 			FROM mime_type_uris
@@ -196,41 +344,461 @@ func (modifier *Modifier) detectMIMETypeMismatch(ctx context.Context) error {
 	`)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	matches := matchSet{}
 	for rows.Next() {
 		select {
 		// abort this function if the context was cancelled
 		case <-ctx.Done():
 			logger.Warn().Msg("cancelling MIME type/URI mismatch modifier query")
 			rows.Close()
-			return ctx.Err()
+			return nil, ctx.Err()
 		default:
 			var res analysis.ThreatMixtape
 			if err := rows.ScanStruct(&res); err != nil {
 				// return error and cancel all uconn analysis
-				return fmt.Errorf("could not read entry for MIME type/URI mismatch modifier detection: %w", err)
+				return nil, fmt.Errorf("could not read entry for MIME type/URI mismatch modifier detection: %w", err)
 			}
 
-			// set analyzed at time to the time the import was started
-			res.AnalyzedAt = modifier.Database.ImportStartedAt.Truncate(time.Microsecond)
+			res.ModifierScore = runner.Config.Modifiers.MIMETypeMismatchScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectRDPFanOut adds a modifier to the results of hosts that made RDP connections to an unusually high number of internal hosts
+func detectRDPFanOut(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of RDP fan out...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+		"threshold": fmt.Sprint(runner.Config.Modifiers.RDPFanOutThreshold),
+	})
 
-			// set the first seen timestamp to the beginning of the Unix epoch because ClickHouse is being
-			// finicky with these fields not being directly set
-			res.FirstSeenHistorical = time.Unix(0, 0)
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH rdp_fan_out AS (
+			SELECT src, src_nuid, uniqExact(dst) AS dst_count
+			FROM rdp
+			WHERE src_local AND dst_local AND ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY src, src_nuid
+			HAVING dst_count >= {threshold:UInt64}
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen, toString(r.dst_count) as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN rdp_fan_out r USING src, src_nuid
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
 
-			res.ImportID = modifier.ImportID
-			res.ModifierName = MIME_TYPE_MISMATCH_MODIFIER_NAME
-			res.ModifierScore = modifier.Config.Modifiers.MIMETypeMismatchScoreIncrease
+	if err != nil {
+		return nil, err
+	}
 
-			// send the modifier to the writer
-			modifier.writer.WriteChannel <- &res
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling RDP fan out modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for RDP fan out modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.RDPFanOutScoreIncrease
+			matches.add(&res)
 		}
 	}
 	rows.Close()
 
-	return nil
+	return matches, nil
+}
+
+// detectFailedConnBeacons boosts the score of beacons whose connections were mostly rejected or half-open.
+// Only closed connections are considered since open connections don't have a final state yet.
+func detectFailedConnBeacons(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of failed connection beacons...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+		"threshold": fmt.Sprint(runner.Config.Modifiers.FailedConnThreshold),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH failed_conns AS (
+			SELECT hash, countMerge(count) AS conn_count, countMerge(failed_count) AS failed_conn_count
+			FROM uconn
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			GROUP BY hash
+			HAVING conn_count > 0 AND failed_conn_count / conn_count >= {threshold:Float64}
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			concat(toString(round(100 * f.failed_conn_count / f.conn_count)), '%') as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN failed_conns f USING hash
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+		AND t.beacon_score > 0
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling failed connection beacon modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for failed connection beacon modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.FailedConnScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectDGANXDomain adds a modifier to the results of hosts whose DNS queries mostly returned NXDOMAIN, which
+// is typical of domain generation algorithm (DGA) malware. This doesn't depend on beaconing, so bursty DGA
+// activity is still scored.
+func detectDGANXDomain(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of DGA NXDOMAIN ratios...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":      fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id":   runner.ImportID.Hex(),
+		"threshold":   fmt.Sprint(runner.Config.Modifiers.DGANXDomainThreshold),
+		"min_queries": fmt.Sprint(runner.Config.Modifiers.DGANXDomainMinQueries),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH nxdomain_ratios AS (
+			SELECT src, src_nuid, countMerge(visits) AS query_count, countMerge(nxdomain_count) AS nxdomain_query_count
+			FROM udns
+			WHERE src_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			GROUP BY src, src_nuid
+			HAVING query_count >= {min_queries:UInt64} AND nxdomain_query_count / query_count >= {threshold:Float64}
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			concat(toString(round(100 * n.nxdomain_query_count / n.query_count)), '%') as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN nxdomain_ratios n USING src, src_nuid
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling DGA NXDOMAIN modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for DGA NXDOMAIN modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.DGANXDomainScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectDNSSubdomainEntropy boosts the score of C2 over DNS domains whose subdomains look random, such as
+// base64 encoded data. The Shannon entropy of the leftmost label of each unique query is averaged per domain.
+func detectDNSSubdomainEntropy(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of DNS subdomain entropy...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH c2_domains AS (
+			SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen
+			FROM threat_mixtape
+			WHERE beacon_type = 'dns'
+			AND modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+			AND import_id = unhex({import_id:String}) -- join only on the results for this import
+		),
+		subdomains AS (
+			-- a sample of unique queries is enough to estimate the mean entropy of domains with many subdomains
+			SELECT tld, groupUniqArray(10000)(fqdn) AS queries
+			FROM udns
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND fqdn != tld
+			AND tld IN (SELECT fqdn FROM c2_domains)
+			GROUP BY tld
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen, s.queries AS queries
+		FROM c2_domains c
+		INNER JOIN subdomains s ON c.fqdn = s.tld
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling DNS subdomain entropy modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			var queries []string
+			if err := rows.Scan(&res.Hash, &res.Src, &res.SrcNUID, &res.Dst, &res.DstNUID, &res.FQDN, &res.LastSeen, &queries); err != nil {
+				return nil, fmt.Errorf("could not read entry for DNS subdomain entropy modifier detection: %w", err)
+			}
+
+			// skip domains whose subdomains don't look random enough
+			entropy := meanSubdomainEntropy(queries)
+			if entropy <= float64(runner.Config.Modifiers.DNSSubdomainEntropyThreshold) {
+				continue
+			}
+
+			res.ModifierValue = fmt.Sprintf("%.2f", entropy)
+			res.ModifierScore = runner.Config.Modifiers.DNSSubdomainEntropyScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// meanSubdomainEntropy returns the mean Shannon entropy of the leftmost label of each query
+func meanSubdomainEntropy(queries []string) float64 {
+	if len(queries) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, query := range queries {
+		label, _, _ := strings.Cut(query, ".")
+		total += util.ShannonEntropy(label)
+	}
+
+	return total / float64(len(queries))
+}
+
+// detectLongConnSessions adds the session breakdown to long connections between IPs so that a single sustained
+// session can be told apart from many short sessions that add up to the same total duration. Only long connections
+// that were mostly made up of a single session get a score increase.
+func detectLongConnSessions(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of long connection sessions...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH long_conns AS (
+			SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen
+			FROM threat_mixtape
+			WHERE beacon_type = 'ip' AND long_conn_score > 0
+			AND modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+			AND import_id = unhex({import_id:String}) -- join only on the results for this import
+		),
+		sessions AS (
+			SELECT hash, groupArrayMerge(86400)(duration_list) AS durations
+			FROM uconn
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64})) AND hash IN (SELECT hash FROM long_conns)
+			GROUP BY hash
+
+			UNION ALL
+
+			-- open connections are sessions that are still in progress
+			SELECT hash, groupArray(86400)(duration) AS durations
+			FROM openconn
+			WHERE missing_host_header = false AND hash IN (SELECT hash FROM long_conns)
+			GROUP BY hash
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen, groupArrayArray(s.durations) AS durations
+		FROM long_conns l
+		INNER JOIN sessions s USING hash
+		GROUP BY hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling long connection sessions modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			var durations []float64
+			if err := rows.Scan(&res.Hash, &res.Src, &res.SrcNUID, &res.Dst, &res.DstNUID, &res.FQDN, &res.LastSeen, &durations); err != nil {
+				return nil, fmt.Errorf("could not read entry for long connection sessions modifier detection: %w", err)
+			}
+
+			breakdown := database.NewSessionBreakdown(durations)
+
+			res.ModifierValue = breakdown.String()
+
+			// many short sessions are still shown, but only a single sustained session raises the score
+			if breakdown.LongestSessionRatio() >= float64(runner.Config.Modifiers.LongConnSustainedThreshold) {
+				res.ModifierScore = runner.Config.Modifiers.LongConnSustainedScoreIncrease
+			}
+
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectFTPUploadVolume adds a modifier to the results of hosts that uploaded a large amount of data to an external FTP server
+func detectFTPUploadVolume(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of FTP upload volume...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+		"threshold": fmt.Sprint(runner.Config.Modifiers.FTPUploadVolumeThreshold),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH ftp_uploads AS (
+			SELECT hash, sum(file_size) AS upload_bytes
+			FROM ftp_proto
+			WHERE command IN ('STOR', 'STOU', 'APPE') AND src_local AND NOT dst_local
+			AND ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY hash
+			HAVING upload_bytes >= {threshold:UInt64}
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen, formatReadableSize(f.upload_bytes) as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN ftp_uploads f USING hash
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling FTP upload volume modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for FTP upload volume modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.FTPUploadVolumeScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectFTPScriptedUpload adds a modifier to the results of FTP sessions that uploaded many files without ever
+// listing a directory, which is typical of scripts that stage or exfiltrate data rather than people browsing a server
+func detectFTPScriptedUpload(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of scripted FTP uploads...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":      fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id":   runner.ImportID.Hex(),
+		"min_uploads": fmt.Sprint(runner.Config.Modifiers.FTPScriptedUploadMinUploads),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH scripted_sessions AS (
+			SELECT hash, zeek_uid, countIf(command IN ('STOR', 'STOU', 'APPE')) AS uploads
+			FROM ftp_proto
+			WHERE ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY hash, zeek_uid
+			HAVING uploads >= {min_uploads:UInt64} AND countIf(command IN ('LIST', 'NLST', 'MLSD', 'MLST')) = 0
+		),
+		scripted_pairs AS (
+			SELECT hash, max(uploads) AS max_uploads
+			FROM scripted_sessions
+			GROUP BY hash
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen, toString(s.max_uploads) as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN scripted_pairs s USING hash
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling scripted FTP upload modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for scripted FTP upload modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.FTPScriptedUploadScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
 }
 
 // RESULTS
@@ -251,3 +819,306 @@ func (modifier *Modifier) detectMIMETypeMismatch(ctx context.Context) error {
 // 192.14.54.2
 
 // if no ips other than the ones in server ips are in direct connections, we boost score
+
+// detectSuspiciousCert adds a modifier to the results of beaconing SNIs whose server presented a self-signed
+// certificate or a certificate that was expired or not yet valid when it was used, since C2 servers often use these
+func detectSuspiciousCert(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of suspicious certificates...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH certs AS (
+			SELECT cert_id, any(self_signed) AS self_signed, any(not_valid_before) AS not_valid_before, any(not_valid_after) AS not_valid_after
+			FROM x509
+			GROUP BY cert_id
+		),
+		suspicious_certs AS (
+			SELECT hash, max(c.self_signed) AS self_signed, max(s.ts < c.not_valid_before OR s.ts > c.not_valid_after) AS expired
+			FROM (
+				-- the first certificate in the chain is the server's certificate, newer zeek versions
+				-- reference it by its fingerprint and older versions by its file ID
+				SELECT hash, ts, if(notEmpty(server_cert_fps), server_cert_fps[1], server_cert_fuids[1]) AS cert_id
+				FROM ssl
+				WHERE ts >= fromUnixTimestamp({min_ts:Int64}) AND (notEmpty(server_cert_fps) OR notEmpty(server_cert_fuids))
+			) s
+			INNER JOIN certs c USING cert_id
+			GROUP BY hash
+			HAVING self_signed OR expired
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			arrayStringConcat(arrayFilter(x -> x != '', [if(c.self_signed, 'self-signed', ''), if(c.expired, 'expired', '')]), ', ') as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN suspicious_certs c USING hash
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+		AND t.fqdn != '' AND t.beacon_score > 0
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling suspicious certificate modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for suspicious certificate modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.SuspiciousCertScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectKerberosAnomaly adds a modifier to the results of internal hosts that were issued kerberos tickets with
+// a weak (RC4 or DES) cipher, which is requested by attacks such as kerberoasting, or that made many failed
+// kerberos requests, which is a sign of password spraying or of enumerating service principals
+func detectKerberosAnomaly(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of kerberos anomalies...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+		"threshold": fmt.Sprint(runner.Config.Modifiers.KerberosFailureThreshold),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH kerberos_anomalies AS (
+			SELECT src, src_nuid, countIf(weak_cipher) AS weak_cipher_count,
+				-- clients are expected to retry without preauthentication first, so those failures are ignored
+				countIf(NOT success AND error_msg != 'KDC_ERR_PREAUTH_REQUIRED') AS failed_count
+			FROM kerberos_proto
+			WHERE src_local AND ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY src, src_nuid
+			HAVING weak_cipher_count > 0 OR failed_count >= {threshold:UInt64}
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			arrayStringConcat(arrayFilter(x -> x != '', [
+				if(k.weak_cipher_count > 0, concat(toString(k.weak_cipher_count), ' weak cipher tickets'), ''),
+				if(k.failed_count >= {threshold:UInt64}, concat(toString(k.failed_count), ' failed requests'), '')
+			]), ', ') as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN kerberos_anomalies k USING src, src_nuid
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling kerberos anomaly modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for kerberos anomaly modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.KerberosAnomalyScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectNTLMAnomaly adds a modifier to the results of internal hosts that authenticated with ntlm as many
+// distinct users, which is a sign of credential reuse or password spraying, or to many distinct hosts,
+// which is a sign of pass-the-hash or other lateral movement
+func detectNTLMAnomaly(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of ntlm anomalies...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":         fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id":      runner.ImportID.Hex(),
+		"user_threshold": fmt.Sprint(runner.Config.Modifiers.NTLMDistinctUserThreshold),
+		"host_threshold": fmt.Sprint(runner.Config.Modifiers.NTLMDistinctHostThreshold),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH ntlm_anomalies AS (
+			SELECT src, src_nuid,
+				-- the same username in different domains belongs to different accounts
+				uniqExact(domain, username) AS user_count,
+				uniqExact(dst, dst_nuid) AS host_count
+			FROM ntlm_proto
+			WHERE src_local AND ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY src, src_nuid
+			HAVING user_count >= {user_threshold:UInt64} OR host_count >= {host_threshold:UInt64}
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			arrayStringConcat(arrayFilter(x -> x != '', [
+				if(n.user_count >= {user_threshold:UInt64}, concat(toString(n.user_count), ' users'), ''),
+				if(n.host_count >= {host_threshold:UInt64}, concat(toString(n.host_count), ' hosts'), '')
+			]), ', ') as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN ntlm_anomalies n USING src, src_nuid
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling ntlm anomaly modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for ntlm anomaly modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.NTLMAnomalyScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectDNSFlood adds a modifier to the results of hosts that queried more than max_fqdns_per_src distinct domains
+// during an import, which is a sign of massive DNS enumeration or tunneling. The queries these hosts made for new
+// domains past the limit were skipped during the import, so the anomaly is surfaced here instead of being hidden.
+func detectDNSFlood(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of DNS floods...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH dns_floods AS (
+			SELECT src, src_nuid, sum(dropped_count) AS dropped_count
+			FROM dns_floods
+			WHERE last_seen >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY src, src_nuid
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			toString(f.dropped_count) as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN dns_floods f USING src, src_nuid
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling DNS flood modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for DNS flood modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.DNSFloodScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
+
+// detectCNAMEChain adds a modifier to the results of domains that were resolved through more CNAME records than the
+// threshold, since long chains can indicate DNS redirection or tunneling. SNI results are matched by their domain, and
+// C2 over DNS results by the deepest chain of any of the queries under their top level domain.
+func detectCNAMEChain(ctx context.Context, runner *Runner) (matchSet, error) {
+	logger := zlog.GetLogger()
+	logger.Debug().Msg("Starting detection of CNAME chains...")
+	chCtx := runner.Database.QueryParameters(clickhouse.Parameters{
+		"min_ts":    fmt.Sprintf("%d", runner.minTS.UTC().Unix()),
+		"import_id": runner.ImportID.Hex(),
+		"threshold": fmt.Sprint(runner.Config.Modifiers.CNAMEChainDepthThreshold),
+	})
+
+	rows, err := runner.Database.Conn.Query(chCtx, `--sql
+		WITH deep_chains AS (
+			SELECT fqdn, tld, maxMerge(max_cname_depth) AS cname_depth
+			FROM udns
+			WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			GROUP BY fqdn, tld
+			HAVING cname_depth > {threshold:UInt8}
+		),
+		chain_depths AS (
+			SELECT fqdn, max(cname_depth) AS cname_depth FROM (
+				SELECT fqdn, cname_depth FROM deep_chains
+				UNION ALL
+				SELECT tld AS fqdn, cname_depth FROM deep_chains
+			)
+			GROUP BY fqdn
+		)
+		SELECT hash, src, src_nuid, dst, dst_nuid, fqdn, last_seen,
+			toString(c.cname_depth) as modifier_value
+		FROM threat_mixtape t
+		INNER JOIN chain_depths c USING fqdn
+		WHERE modifier_name = '' -- join only on non-modifier rows to avoid duplicating results
+		AND t.import_id = unhex({import_id:String}) -- join only on the results for this import
+	`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchSet{}
+	for rows.Next() {
+		select {
+		// abort this function if the context was cancelled
+		case <-ctx.Done():
+			logger.Warn().Msg("cancelling CNAME chain modifier query")
+			rows.Close()
+			return nil, ctx.Err()
+		default:
+			var res analysis.ThreatMixtape
+			if err := rows.ScanStruct(&res); err != nil {
+				return nil, fmt.Errorf("could not read entry for CNAME chain modifier detection: %w", err)
+			}
+
+			res.ModifierScore = runner.Config.Modifiers.CNAMEChainScoreIncrease
+			matches.add(&res)
+		}
+	}
+	rows.Close()
+
+	return matches, nil
+}
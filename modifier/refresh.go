@@ -0,0 +1,254 @@
+package modifier
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	zlog "github.com/activecm/rita/v5/logger"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// refreshedResult is the current prevalence and first seen of an analysis result
+type refreshedResult struct {
+	Hash                util.FixedString `ch:"hash"`
+	ImportID            util.FixedString `ch:"import_id"`
+	PrevalenceTotal     uint64           `ch:"prevalence_total"`
+	FirstSeenHistorical time.Time        `ch:"first_seen_historical"`
+}
+
+// RefreshModifiers recomputes the prevalence and/or first seen of the stored analysis results against the data that
+// is currently in the dataset and scores them again, without re-running analysis. Every other score of the results
+// is kept. Distributed beacons are left out since their prevalence and first seen come from their pool of
+// destinations. First seen is only scored for rolling datasets, so it is only refreshed for them. Returns the number
+// of results that were refreshed.
+func RefreshModifiers(db *database.DB, cfg *config.Config, prevalence bool, firstSeen bool) (int, error) {
+	logger := zlog.GetLogger()
+
+	rolling, err := database.GetRollingStatus(db.GetContext(), db.Conn, db.GetMetaDatabase(), db.GetSelectedDB())
+	if err != nil {
+		return 0, err
+	}
+	if firstSeen && !rolling {
+		logger.Warn().Str("dataset", db.GetSelectedDB()).Msg("first seen is only scored for rolling datasets, skipping it")
+		firstSeen = false
+	}
+	if !prevalence && !firstSeen {
+		return 0, nil
+	}
+
+	minTS, _, _, _, err := db.GetTrueMinMaxTimestamps()
+	if err != nil {
+		return 0, fmt.Errorf("could not find imported data: %w", err)
+	}
+
+	// prevalence is counted over the same window as the analysis queries
+	minTSBeacon, _, _, err := db.GetBeaconMinMaxTimestamps()
+	if errors.Is(err, database.ErrInvalidMinMaxTimestamp) {
+		minTSBeacon = minTS
+	} else if err != nil {
+		return 0, fmt.Errorf("could not find min/max timestamps for beaconing analysis: %w", err)
+	}
+
+	networkSize, err := db.GetNetworkSize(minTS)
+	if err != nil {
+		return 0, err
+	}
+
+	// the results are scored again by the same modifiers that scored them during their import
+	prevalenceScorer := &prevalenceModifier{modifiers: cfg.Modifiers}
+	firstSeenScorer, err := newFirstSeenModifier(db, cfg, rolling)
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := getCurrentModifierValues(db, minTSBeacon)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]database.RefreshedModifiers, 0, len(current))
+	for _, res := range current {
+		refreshed := database.RefreshedModifiers{
+			Hash:            res.Hash,
+			ImportID:        res.ImportID,
+			PrevalenceTotal: res.PrevalenceTotal,
+		}
+
+		if networkSize > 0 {
+			refreshed.Prevalence = float32(float64(res.PrevalenceTotal) / float64(networkSize))
+		}
+		refreshed.FirstSeenHistorical, _ = util.ValidateTimestamp(res.FirstSeenHistorical)
+
+		row := analysis.ThreatMixtape{AnalysisResult: analysis.AnalysisResult{
+			Prevalence:          refreshed.Prevalence,
+			FirstSeenHistorical: refreshed.FirstSeenHistorical,
+		}}
+		if cfg.ModuleEnabled(config.ModulePrevalence) {
+			refreshed.PrevalenceScore, _ = prevalenceScorer.Score(db.GetContext(), &row)
+		}
+		if cfg.ModuleEnabled(config.ModuleFirstSeen) {
+			refreshed.FirstSeenScore, _ = firstSeenScorer.Score(db.GetContext(), &row)
+		}
+
+		results = append(results, refreshed)
+	}
+
+	if err := db.UpdateRefreshedModifiers(results, prevalence, firstSeen); err != nil {
+		return 0, fmt.Errorf("could not update refreshed modifiers: %w", err)
+	}
+
+	return len(results), nil
+}
+
+// getCurrentModifierValues returns the prevalence total and first seen of every analysis result that can be refreshed,
+// counted the same way as in the analysis query that found each type of result
+func getCurrentModifierValues(db *database.DB, minTS time.Time) ([]refreshedResult, error) {
+	params := clickhouse.Parameters{
+		"min_ts": fmt.Sprintf("%d", minTS.UTC().Unix()),
+	}
+	if _, err := analysis.CreateParentDomainTable(db, minTS, params); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := db.DropParentDomainTable(); err != nil {
+			logger := zlog.GetLogger()
+			logger.Warn().Err(err).Msg("could not drop the parent domain table")
+		}
+	}()
+	chCtx := db.QueryParameters(params)
+
+	var results []refreshedResult
+	err := db.Conn.Select(chCtx, &results, `--sql
+		WITH `+analysis.ParentDomainCTEs+`,
+		results AS (
+			SELECT DISTINCT hash, import_id, src, src_nuid, dst, dst_nuid, fqdn, beacon_type FROM threat_mixtape
+			WHERE modifier_name = '' AND beacon_type IN ('sni', 'sni_parent', 'dns', 'ip', 'ip_port', 'rdp')
+		),
+		-- internal hosts that connected to or looked up each domain
+		domain_hosts AS (
+			SELECT DISTINCT fqdn, src FROM usni
+			WHERE src_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			UNION DISTINCT
+			SELECT DISTINCT fqdn, dst AS src FROM usni
+			WHERE dst_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			UNION DISTINCT
+			SELECT DISTINCT host AS fqdn, src FROM openhttp WHERE src_local
+			UNION DISTINCT
+			SELECT DISTINCT host AS fqdn, dst AS src FROM openhttp WHERE dst_local
+			UNION DISTINCT
+			SELECT DISTINCT server_name AS fqdn, src FROM openssl WHERE src_local
+			UNION DISTINCT
+			SELECT DISTINCT server_name AS fqdn, dst AS src FROM openssl WHERE dst_local
+			UNION DISTINCT
+			SELECT DISTINCT fqdn, src FROM udns
+			WHERE src_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+			UNION DISTINCT
+			SELECT DISTINCT fqdn, dst AS src FROM udns
+			WHERE dst_local AND hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+		),
+		domain_prevalence AS (
+			SELECT fqdn, uniqExact(src) AS prevalence_total FROM domain_hosts GROUP BY fqdn
+		),
+		-- C2 over DNS results are counted across every subdomain of their domain, the same way as the C2 over DNS analysis
+		tld_prevalence AS (
+			SELECT cutToFirstSignificantSubdomain(fqdn) AS tld, uniqExact(src) AS prevalence_total FROM domain_hosts
+			GROUP BY tld
+		),
+		-- internal hosts that connected to each external IP
+		ip_prevalence AS (
+			SELECT ip, count() AS prevalence_total FROM (
+				SELECT DISTINCT if(src_local, dst, src) AS ip, if(src_local, src, dst) AS internal FROM uconn
+				WHERE hour >= toStartOfHour(fromUnixTimestamp({min_ts:Int64}))
+				UNION DISTINCT
+				SELECT DISTINCT if(src_local, dst, src) AS ip, if(src_local, src, dst) AS internal FROM openconn
+			)
+			GROUP BY ip
+		),
+		-- which side of each IP result is internal, since the results don't record it
+		ip_locality AS (
+			SELECT src, dst, any(src_local) AS src_local, any(dst_local) AS dst_local FROM uconn
+			WHERE (src, dst) IN (SELECT src, dst FROM results WHERE beacon_type IN ('ip', 'ip_port'))
+			GROUP BY src, dst
+		),
+		-- internal hosts that made an rdp connection to each destination
+		rdp_prevalence AS (
+			SELECT dst, uniqExact(src) AS prevalence_total FROM rdp
+			WHERE src_local AND ts >= fromUnixTimestamp({min_ts:Int64})
+			GROUP BY dst
+		),
+		-- first seen is tracked per internal network so that each network's novelty is independent
+		domain_history AS (
+			SELECT fqdn, nuid, min(first_seen) AS first_seen FROM {metadatabase:Identifier}.historical_first_seen
+			WHERE fqdn IN (SELECT fqdn FROM results WHERE beacon_type = 'sni')
+			GROUP BY fqdn, nuid
+		),
+		tld_history AS (
+			SELECT cutToFirstSignificantSubdomain(fqdn) AS tld, min(first_seen) AS first_seen
+			FROM {metadatabase:Identifier}.historical_first_seen
+			WHERE tld IN (SELECT fqdn FROM results WHERE beacon_type = 'dns')
+			GROUP BY tld
+		),
+		ip_history AS (
+			SELECT ip, nuid, min(first_seen) AS first_seen FROM {metadatabase:Identifier}.historical_first_seen
+			WHERE ip IN (SELECT dst FROM results UNION DISTINCT SELECT src FROM results)
+			GROUP BY ip, nuid
+		),
+		rdp_history AS (
+			SELECT hash, min(ts) AS first_seen FROM rdp
+			WHERE hash IN (SELECT hash FROM results WHERE beacon_type = 'rdp')
+			GROUP BY hash
+		)
+		SELECT r.hash AS hash, r.import_id AS import_id, p.prevalence_total AS prevalence_total, h.first_seen AS first_seen_historical
+		FROM results r
+		LEFT JOIN domain_prevalence p ON r.fqdn = p.fqdn
+		LEFT JOIN domain_history h ON r.fqdn = h.fqdn AND r.src_nuid = h.nuid
+		WHERE r.beacon_type = 'sni'
+
+		UNION ALL
+
+		-- parent domain results are grouped and counted the same way as the parent domain analysis
+		SELECT r.hash AS hash, r.import_id AS import_id, p.prevalence_total AS prevalence_total, h.first_seen AS first_seen_historical
+		FROM results r
+		LEFT JOIN parent_domain_prevalence p ON r.fqdn = p.parent
+		LEFT JOIN parent_domain_history h ON r.fqdn = h.parent AND r.src_nuid = h.nuid
+		WHERE r.beacon_type = 'sni_parent'
+
+		UNION ALL
+
+		-- C2 over DNS results aren't attributed to a network, so they use the earliest first seen of any network
+		SELECT r.hash AS hash, r.import_id AS import_id, p.prevalence_total AS prevalence_total, h.first_seen AS first_seen_historical
+		FROM results r
+		LEFT JOIN tld_prevalence p ON r.fqdn = p.tld
+		LEFT JOIN tld_history h ON r.fqdn = h.tld
+		WHERE r.beacon_type = 'dns'
+
+		UNION ALL
+
+		SELECT r.hash AS hash, r.import_id AS import_id, p.prevalence_total AS prevalence_total, h.first_seen AS first_seen_historical
+		FROM results r
+		LEFT JOIN ip_locality l ON r.src = l.src AND r.dst = l.dst
+		LEFT JOIN ip_prevalence p ON if(l.src_local, r.dst, r.src) = p.ip
+		LEFT JOIN ip_history h ON multiIf(l.src_local, r.dst, l.dst_local, r.src, r.dst) = h.ip
+			AND multiIf(l.src_local, r.src_nuid, l.dst_local, r.dst_nuid, r.src_nuid) = h.nuid
+		WHERE r.beacon_type IN ('ip', 'ip_port')
+
+		UNION ALL
+
+		SELECT r.hash AS hash, r.import_id AS import_id, p.prevalence_total AS prevalence_total, h.first_seen AS first_seen_historical
+		FROM results r
+		LEFT JOIN rdp_prevalence p ON r.dst = p.dst
+		LEFT JOIN rdp_history h ON r.hash = h.hash
+		WHERE r.beacon_type = 'rdp'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the current prevalence and first seen of the analysis results: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,171 @@
+package modifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/util"
+)
+
+var ErrInvalidModifier = errors.New("modifier must have a name")
+var ErrDuplicateModifier = errors.New("a modifier with this name is already registered")
+var ErrReservedModifier = errors.New("a modifier with this name is scored during analysis")
+
+// Modifier scores the results of an import. For each result, Score returns the amount that the modifier changes
+// the final score by and a value that is shown alongside the modifier. Results that get a score of 0 and an empty
+// value aren't modified.
+type Modifier interface {
+	Name() string
+	Score(ctx context.Context, row *analysis.ThreatMixtape) (float32, string)
+}
+
+// Preparer is implemented by modifiers that need to query the dataset before they can score results. Prepare is
+// called once per import, before any results are scored, and returns the modifier that scores the results of that import.
+type Preparer interface {
+	Prepare(ctx context.Context, runner *Runner) (Modifier, error)
+}
+
+var (
+	registryMutex sync.RWMutex
+	// registry holds the modifiers that are applied to every import, in the order that they were registered
+	registry = []Modifier{
+		&threatIntelModifier{},
+		&prevalenceModifier{},
+		&firstSeenModifier{},
+		&c2OverDNSDirectConnModifier{},
+		&queryModifier{name: RARE_SIGNATURE_MODIFIER_NAME, detect: detectRareSignature},
+		&queryModifier{name: MIME_TYPE_MISMATCH_MODIFIER_NAME, detect: detectMIMETypeMismatch},
+		&queryModifier{name: RDP_FAN_OUT_MODIFIER_NAME, detect: detectRDPFanOut},
+		&queryModifier{name: FAILED_CONN_MODIFIER_NAME, detect: detectFailedConnBeacons},
+		&queryModifier{name: DGA_NXDOMAIN_MODIFIER_NAME, detect: detectDGANXDomain},
+		&queryModifier{name: DNS_SUBDOMAIN_ENTROPY_MODIFIER_NAME, detect: detectDNSSubdomainEntropy},
+		&queryModifier{name: LONG_CONN_SESSIONS_MODIFIER_NAME, detect: detectLongConnSessions},
+		&queryModifier{name: FTP_UPLOAD_VOLUME_MODIFIER_NAME, detect: detectFTPUploadVolume},
+		&queryModifier{name: FTP_SCRIPTED_UPLOAD_MODIFIER_NAME, detect: detectFTPScriptedUpload},
+		&queryModifier{name: SUSPICIOUS_CERT_MODIFIER_NAME, detect: detectSuspiciousCert},
+		&queryModifier{name: KERBEROS_ANOMALY_MODIFIER_NAME, detect: detectKerberosAnomaly},
+		&queryModifier{name: NTLM_ANOMALY_MODIFIER_NAME, detect: detectNTLMAnomaly},
+		&queryModifier{name: DNS_FLOOD_MODIFIER_NAME, detect: detectDNSFlood},
+		&queryModifier{name: CNAME_CHAIN_MODIFIER_NAME, detect: detectCNAMEChain},
+	}
+)
+
+// analysisModifiers are the modifiers that are scored during analysis instead of through the registry. Adaptive beacon
+// rows are written by the beacon analysis, so its name is reserved so that a custom modifier can't be mistaken for it.
+var analysisModifiers = []string{
+	analysis.ADAPTIVE_BEACON_MODIFIER_NAME,
+}
+
+// moduleModifiers maps the built-in modifiers that make up an analysis module to that module,
+// so that they are skipped when the module is disabled
+var moduleModifiers = map[string]string{
+	THREAT_INTEL_MODIFIER_NAME:            config.ModuleThreatIntel,
+	PREVALENCE_MODIFIER_NAME:              config.ModulePrevalence,
+	FIRST_SEEN_MODIFIER_NAME:              config.ModuleFirstSeen,
+	C2_OVER_DNS_DIRECT_CONN_MODIFIER_NAME: config.ModuleC2OverDNS,
+	RARE_SIGNATURE_MODIFIER_NAME:          config.ModuleRareSignatures,
+	MIME_TYPE_MISMATCH_MODIFIER_NAME:      config.ModuleMIMEMismatch,
+}
+
+// RegisterModifier adds a modifier that is applied to the results of every import analyzed after this call.
+// The built-in modifiers are registered by default.
+func RegisterModifier(mod Modifier) error {
+	if mod == nil || mod.Name() == "" {
+		return ErrInvalidModifier
+	}
+
+	if slices.Contains(analysisModifiers, mod.Name()) {
+		return fmt.Errorf("%w: %s", ErrReservedModifier, mod.Name())
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for _, registered := range registry {
+		if registered.Name() == mod.Name() {
+			return fmt.Errorf("%w: %s", ErrDuplicateModifier, mod.Name())
+		}
+	}
+
+	registry = append(registry, mod)
+	return nil
+}
+
+// RegisteredModifiers returns the modifiers that are applied to every import
+func RegisteredModifiers() []Modifier {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	modifiers := make([]Modifier, len(registry))
+	copy(modifiers, registry)
+	return modifiers
+}
+
+// enabledModifiers returns the modifiers whose analysis module is enabled, modifiers that aren't part of a module always run
+func enabledModifiers(cfg *config.Config, modifiers []Modifier) []Modifier {
+	enabled := make([]Modifier, 0, len(modifiers))
+	for _, mod := range modifiers {
+		if module, ok := moduleModifiers[mod.Name()]; ok && !cfg.ModuleEnabled(module) {
+			continue
+		}
+		enabled = append(enabled, mod)
+	}
+	return enabled
+}
+
+// rowKey identifies a single result of an import
+type rowKey struct {
+	hash util.FixedString
+	src  string
+	dst  string
+	fqdn string
+}
+
+func newRowKey(row *analysis.ThreatMixtape) rowKey {
+	return rowKey{hash: row.Hash, src: row.Src.String(), dst: row.Dst.String(), fqdn: row.FQDN}
+}
+
+type match struct {
+	score float32
+	value string
+}
+
+// matchSet holds the score and value of each result that a built-in modifier query matched
+type matchSet map[rowKey]match
+
+func (m matchSet) add(row *analysis.ThreatMixtape) {
+	m[newRowKey(row)] = match{score: row.ModifierScore, value: row.ModifierValue}
+}
+
+// queryModifier is a built-in modifier that finds the results it applies to with a single query per import
+type queryModifier struct {
+	name   string
+	detect func(ctx context.Context, runner *Runner) (matchSet, error)
+
+	// the results that the query matched, set on the modifier returned by Prepare
+	matches matchSet
+}
+
+// Name returns the name of the modifier
+func (q *queryModifier) Name() string { return q.name }
+
+// Score returns the score and value of the result if it was matched by the query of the import, results aren't
+// modified by a query modifier that hasn't been prepared
+func (q *queryModifier) Score(_ context.Context, row *analysis.ThreatMixtape) (float32, string) {
+	res := q.matches[newRowKey(row)]
+	return res.score, res.value
+}
+
+// Prepare runs the query of the modifier and returns a copy of the modifier that scores the results it matched
+func (q *queryModifier) Prepare(ctx context.Context, runner *Runner) (Modifier, error) {
+	matches, err := q.detect(ctx, runner)
+	if err != nil {
+		return nil, err
+	}
+	return &queryModifier{name: q.name, detect: q.detect, matches: matches}, nil
+}
@@ -0,0 +1,126 @@
+package modifier
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testModifier struct {
+	name string
+}
+
+func (m *testModifier) Name() string { return m.name }
+
+func (m *testModifier) Score(_ context.Context, row *analysis.ThreatMixtape) (float32, string) {
+	if row.FQDN == "example.com" {
+		return 0.1, "matched"
+	}
+	return 0, ""
+}
+
+func TestRegisterModifier(t *testing.T) {
+	// restore the built-in modifiers after the test
+	builtins := RegisteredModifiers()
+	t.Cleanup(func() {
+		registryMutex.Lock()
+		registry = builtins
+		registryMutex.Unlock()
+	})
+
+	require.Len(t, builtins, 18, "built-in modifiers should be registered by default")
+	for _, mod := range builtins {
+		_, ok := mod.(Preparer)
+		require.True(t, ok, "built-in modifier %s should be prepared before scoring", mod.Name())
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		require.NoError(t, RegisterModifier(&testModifier{name: "custom"}))
+
+		modifiers := RegisteredModifiers()
+		require.Len(t, modifiers, len(builtins)+1)
+		require.Equal(t, "custom", modifiers[len(modifiers)-1].Name(), "modifiers should be applied in the order they were registered")
+	})
+
+	t.Run("Duplicate Name", func(t *testing.T) {
+		for _, name := range []string{RARE_SIGNATURE_MODIFIER_NAME, THREAT_INTEL_MODIFIER_NAME, PREVALENCE_MODIFIER_NAME, FIRST_SEEN_MODIFIER_NAME, C2_OVER_DNS_DIRECT_CONN_MODIFIER_NAME} {
+			require.ErrorIs(t, RegisterModifier(&testModifier{name: name}), ErrDuplicateModifier, "built-in modifiers can't be registered again: %s", name)
+		}
+	})
+
+	t.Run("Analysis Modifier Name", func(t *testing.T) {
+		require.ErrorIs(t, RegisterModifier(&testModifier{name: analysis.ADAPTIVE_BEACON_MODIFIER_NAME}), ErrReservedModifier, "modifiers scored during analysis can't be registered")
+	})
+
+	t.Run("Missing Name", func(t *testing.T) {
+		require.ErrorIs(t, RegisterModifier(&testModifier{}), ErrInvalidModifier)
+		require.ErrorIs(t, RegisterModifier(nil), ErrInvalidModifier)
+	})
+}
+
+func TestQueryModifierScore(t *testing.T) {
+	hash, err := util.NewFixedStringHash("10.0.0.1", "93.184.216.34")
+	require.NoError(t, err)
+
+	matched := analysis.ThreatMixtape{AnalysisResult: analysis.AnalysisResult{Hash: hash, Src: net.ParseIP("10.0.0.1"), Dst: net.ParseIP("93.184.216.34")}}
+	matched.ModifierScore = 0.15
+	matched.ModifierValue = "self-signed"
+
+	matches := matchSet{}
+	matches.add(&matched)
+
+	mod := &queryModifier{name: SUSPICIOUS_CERT_MODIFIER_NAME, detect: func(context.Context, *Runner) (matchSet, error) {
+		return matches, nil
+	}}
+
+	// the row that is scored doesn't carry the modifier fields of the query result
+	row := analysis.ThreatMixtape{AnalysisResult: matched.AnalysisResult}
+	score, value := mod.Score(context.Background(), &row)
+	require.Zero(t, score, "a query modifier that wasn't prepared shouldn't modify any results")
+	require.Empty(t, value)
+
+	prepared, err := mod.Prepare(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, SUSPICIOUS_CERT_MODIFIER_NAME, prepared.Name(), "the prepared modifier should keep its name")
+
+	score, value = prepared.Score(context.Background(), &row)
+	require.InDelta(t, float32(0.15), score, 0.00001)
+	require.Equal(t, "self-signed", value)
+
+	// results that weren't matched by the query aren't modified
+	row.Dst = net.ParseIP("93.184.216.35")
+	score, value = prepared.Score(context.Background(), &row)
+	require.Zero(t, score)
+	require.Empty(t, value)
+}
+
+func TestEnabledModifiers(t *testing.T) {
+	modifiers := append(RegisteredModifiers(), &testModifier{name: "custom"})
+
+	// every modifier runs when no modules are disabled
+	cfg := config.Config{}
+	require.Len(t, enabledModifiers(&cfg, modifiers), len(modifiers))
+
+	cfg.DisabledModules = []string{config.ModuleRareSignatures, config.ModuleMIMEMismatch}
+	enabled := enabledModifiers(&cfg, modifiers)
+	require.Len(t, enabled, len(modifiers)-2)
+	for _, mod := range enabled {
+		require.NotContains(t, []string{RARE_SIGNATURE_MODIFIER_NAME, MIME_TYPE_MISMATCH_MODIFIER_NAME}, mod.Name())
+	}
+	require.Equal(t, "custom", enabled[len(enabled)-1].Name(), "modifiers that aren't part of a module should still run")
+
+	// modules that aren't listed in enabled_modules are skipped
+	cfg = config.Config{EnabledModules: []string{config.ModuleBeacons, config.ModuleMIMEMismatch}}
+	enabled = enabledModifiers(&cfg, modifiers)
+	skipped := []string{RARE_SIGNATURE_MODIFIER_NAME, THREAT_INTEL_MODIFIER_NAME, PREVALENCE_MODIFIER_NAME, FIRST_SEEN_MODIFIER_NAME, C2_OVER_DNS_DIRECT_CONN_MODIFIER_NAME}
+	require.Len(t, enabled, len(modifiers)-len(skipped))
+	for _, mod := range enabled {
+		require.NotContains(t, skipped, mod.Name())
+	}
+}
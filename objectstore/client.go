@@ -0,0 +1,254 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty request body, which every request sent by the client has
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// requestTimeout bounds the requests that list and stat objects, reads are only bounded by their context since logs
+// can take a long time to stream
+const requestTimeout = 60 * time.Second
+
+var ErrRequestFailed = errors.New("object storage request failed")
+
+// Client makes the requests of an S3-compatible object store that are needed to read logs
+type Client struct {
+	endpoint    *url.URL
+	region      string
+	pathStyle   bool
+	credentials aws.Credentials
+	signer      *v4.Signer
+	http        *http.Client
+}
+
+// object is a single object listed or found in a bucket
+type object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// listBucketResult is a page of a ListObjectsV2 response
+type listBucketResult struct {
+	Contents       []object `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// NewClient returns a client for the object store in cfg. The credentials in cfg are used if they are set, otherwise
+// the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables are used. Requests are sent unsigned when
+// neither is set, which only works for public buckets.
+func NewClient(cfg config.ObjectStorage, client *http.Client) (*Client, error) {
+	rawEndpoint := cfg.Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	endpoint, err := url.ParseRequestURI(rawEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid object storage endpoint %s: %w", rawEndpoint, err)
+	}
+
+	credentials := aws.Credentials{AccessKeyID: cfg.AccessKeyID, SecretAccessKey: cfg.SecretAccessKey}
+	if credentials.AccessKeyID == "" && credentials.SecretAccessKey == "" {
+		credentials = aws.Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+
+	return &Client{
+		endpoint:    endpoint,
+		region:      cfg.Region,
+		pathStyle:   cfg.UsePathStyle,
+		credentials: credentials,
+		// keys are already escaped the way that S3 expects, so they can't be escaped again when they are signed
+		signer: v4.NewSigner(func(options *v4.SignerOptions) {
+			options.DisableURIPathEscaping = true
+		}),
+		http: client,
+	}, nil
+}
+
+// list returns the objects and the common prefixes directly under prefix, following every page of the listing
+func (c *Client) list(ctx context.Context, bucket string, prefix string) ([]object, []string, error) {
+	var objects []object
+	var prefixes []string
+
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("delimiter", "/")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		var page listBucketResult
+		if err := c.do(ctx, http.MethodGet, bucket, "", query, nil, func(resp *http.Response) error {
+			return xml.NewDecoder(resp.Body).Decode(&page)
+		}); err != nil {
+			return nil, nil, err
+		}
+
+		objects = append(objects, page.Contents...)
+		for _, common := range page.CommonPrefixes {
+			prefixes = append(prefixes, common.Prefix)
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, prefixes, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// head returns the size and modification time of the object at key, or an error that satisfies os.IsNotExist if
+// there is no such object
+func (c *Client) head(ctx context.Context, bucket string, key string) (object, error) {
+	obj := object{Key: key}
+	err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, func(resp *http.Response) error {
+		obj.Size = resp.ContentLength
+		if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+			obj.LastModified = modified
+		}
+		return nil
+	})
+	return obj, err
+}
+
+// get returns the contents of the object at key starting at offset. The caller must close the returned reader.
+func (c *Client) get(ctx context.Context, bucket string, key string, offset int64) (io.ReadCloser, error) {
+	var header http.Header
+	if offset > 0 {
+		header = http.Header{"Range": []string{"bytes=" + strconv.FormatInt(offset, 10) + "-"}}
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, bucket, key, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, key); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends a request that is bounded by the request timeout and hands a successful response to read
+func (c *Client) do(ctx context.Context, method string, bucket string, key string, query url.Values, header http.Header, read func(resp *http.Response) error) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, method, bucket, key, query, header)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, key); err != nil {
+		return err
+	}
+	return read(resp)
+}
+
+// newRequest builds a signed request for the object at key in bucket, or for the bucket itself if key is empty
+func (c *Client) newRequest(ctx context.Context, method string, bucket string, key string, query url.Values, header http.Header) (*http.Request, error) {
+	u := *c.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if c.pathStyle {
+		basePath += "/" + bucket
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path = basePath + "/" + key
+	u.RawPath = escapePath(basePath) + "/" + escapePath(key)
+	// spaces must be encoded as %20 to match the signature
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+
+	// public buckets are read without signing the requests
+	if c.credentials.AccessKeyID == "" {
+		return req, nil
+	}
+	if err := c.signer.SignHTTP(ctx, c.credentials, req, emptyPayloadHash, "s3", c.region, time.Now()); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// checkResponse returns an error describing a failed response, which satisfies os.IsNotExist if the object or bucket
+// wasn't found
+func checkResponse(resp *http.Response, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return &os.PathError{Op: "open", Path: key, Err: os.ErrNotExist}
+	}
+
+	// HEAD responses don't have a body, so only the status can be reported
+	var body errorResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body); err != nil || body.Code == "" {
+		return fmt.Errorf("%w: %s", ErrRequestFailed, resp.Status)
+	}
+	return fmt.Errorf("%w: %s: %s: %s", ErrRequestFailed, resp.Status, body.Code, body.Message)
+}
+
+// escapePath percent-encodes every byte of an object key except for unreserved characters and slashes, which is the
+// encoding that S3 uses to sign paths
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,451 @@
+// Package objectstore reads logs from S3-compatible object stores through an afero file system, so that they can be
+// walked and imported like a log directory on disk.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// MountDirectory is the directory that buckets are mounted under, each bucket is mounted at MountDirectory/<bucket>
+const MountDirectory = "/s3"
+
+// pathScheme is the scheme of the paths of objects in a bucket, ex: s3://bucket/prefix
+const pathScheme = "s3://"
+
+var ErrInvalidObjectPath = errors.New("object storage path must be in the form s3://bucket/prefix")
+
+// Fs is a read-only afero file system that mounts a bucket at MountDirectory/<bucket> and reads every other path from
+// a base file system, so that files such as threat intel feeds can still be read during an import. Directories are
+// the common prefixes of the object keys, split on "/".
+type Fs struct {
+	ctx    context.Context
+	base   afero.Fs
+	client *Client
+	bucket string
+	root   string
+
+	// the info of listed objects and prefixes is cached by mount path, since walking a prefix stats each of them
+	mu    sync.Mutex
+	infos map[string]*fileInfo
+}
+
+// fileInfo describes an object or a common prefix
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+// NewFs returns a file system that reads the bucket through client. Requests are canceled once ctx is done.
+func NewFs(ctx context.Context, base afero.Fs, client *Client, bucket string) *Fs {
+	return &Fs{
+		ctx:    ctx,
+		base:   base,
+		client: client,
+		bucket: bucket,
+		root:   MountPath(bucket, ""),
+		infos:  make(map[string]*fileInfo),
+	}
+}
+
+// ParsePath returns the bucket and the key prefix of an object storage path, ex: s3://bucket/prefix
+func ParsePath(objectPath string) (string, string, error) {
+	if !strings.HasPrefix(strings.ToLower(objectPath), pathScheme) {
+		return "", "", ErrInvalidObjectPath
+	}
+	bucket, prefix, _ := strings.Cut(objectPath[len(pathScheme):], "/")
+	if bucket == "" {
+		return "", "", ErrInvalidObjectPath
+	}
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// MountPath returns the path that the objects under prefix in bucket are read from
+func MountPath(bucket string, prefix string) string {
+	return path.Join(MountDirectory, bucket, prefix)
+}
+
+// key returns the object key of a path in the bucket and whether the path is in the bucket at all. The key of the
+// bucket itself is empty.
+func (fs *Fs) key(name string) (string, bool) {
+	name = path.Clean("/" + name)
+	if name == fs.root {
+		return "", true
+	}
+	if key, ok := strings.CutPrefix(name, fs.root+"/"); ok {
+		return key, true
+	}
+	return "", false
+}
+
+func (fs *Fs) Name() string { return "ObjectStoreFs" }
+
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	key, ok := fs.key(name)
+	if !ok {
+		return fs.base.Stat(name)
+	}
+	return fs.stat(name, key)
+}
+
+// stat returns the info of the object or prefix at key. Keys that aren't cached are looked up as an object first and
+// then as a prefix, since prefixes only exist as part of the keys of other objects.
+func (fs *Fs) stat(name string, key string) (*fileInfo, error) {
+	if key == "" {
+		return &fileInfo{name: fs.bucket, dir: true}, nil
+	}
+
+	mountPath := path.Join(fs.root, key)
+	fs.mu.Lock()
+	info, ok := fs.infos[mountPath]
+	fs.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	obj, err := fs.client.head(fs.ctx, fs.bucket, key)
+	switch {
+	case err == nil:
+		info = &fileInfo{name: path.Base(key), size: obj.Size, modTime: obj.LastModified}
+	case os.IsNotExist(err):
+		objects, prefixes, err := fs.client.list(fs.ctx, fs.bucket, key+"/")
+		if err != nil {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+		}
+		if len(objects) == 0 && len(prefixes) == 0 {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+		}
+		info = &fileInfo{name: path.Base(key), dir: true}
+	default:
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	fs.mu.Lock()
+	fs.infos[mountPath] = info
+	fs.mu.Unlock()
+	return info, nil
+}
+
+// readDir lists the objects and prefixes directly inside of the prefix at key, sorted by name
+func (fs *Fs) readDir(key string) ([]os.FileInfo, error) {
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	objects, prefixes, err := fs.client.list(fs.ctx, fs.bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(objects)+len(prefixes))
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		// skip the empty objects that some tools create to mark a directory
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		info := &fileInfo{name: name, size: obj.Size, modTime: obj.LastModified}
+		fs.infos[path.Join(fs.root, obj.Key)] = info
+		infos = append(infos, info)
+	}
+	for _, common := range prefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(common, prefix), "/")
+		if name == "" {
+			continue
+		}
+		info := &fileInfo{name: name, dir: true}
+		fs.infos[path.Join(fs.root, prefix+name)] = info
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (fs *Fs) Open(name string) (afero.File, error) {
+	key, ok := fs.key(name)
+	if !ok {
+		return fs.base.Open(name)
+	}
+
+	info, err := fs.stat(name, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.dir {
+		return &dirFile{readOnlyFile: readOnlyFile{name: name}, fs: fs, key: key, info: info}, nil
+	}
+	// the object is only requested once it is read, since walking the bucket opens every file to check that it can
+	// be read
+	return &objectFile{readOnlyFile: readOnlyFile{name: name}, fs: fs, key: key, info: info}, nil
+}
+
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.OpenFile(name, flag, perm)
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+	}
+	return fs.Open(name)
+}
+
+func (fs *Fs) Create(name string) (afero.File, error) {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.Create(name)
+	}
+	return nil, &os.PathError{Op: "create", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.Mkdir(name, perm)
+	}
+	return &os.PathError{Op: "mkdir", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) MkdirAll(name string, perm os.FileMode) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.MkdirAll(name, perm)
+	}
+	return &os.PathError{Op: "mkdir", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) Remove(name string) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.Remove(name)
+	}
+	return &os.PathError{Op: "remove", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) RemoveAll(name string) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.RemoveAll(name)
+	}
+	return &os.PathError{Op: "remove", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) Rename(oldname string, newname string) error {
+	_, oldInBucket := fs.key(oldname)
+	_, newInBucket := fs.key(newname)
+	if !oldInBucket && !newInBucket {
+		return fs.base.Rename(oldname, newname)
+	}
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EPERM}
+}
+
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.Chmod(name, mode)
+	}
+	return &os.PathError{Op: "chmod", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) Chown(name string, uid, gid int) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.Chown(name, uid, gid)
+	}
+	return &os.PathError{Op: "chown", Path: name, Err: syscall.EPERM}
+}
+
+func (fs *Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if _, ok := fs.key(name); !ok {
+		return fs.base.Chtimes(name, atime, mtime)
+	}
+	return &os.PathError{Op: "chtimes", Path: name, Err: syscall.EPERM}
+}
+
+func (info *fileInfo) Name() string       { return info.name }
+func (info *fileInfo) Size() int64        { return info.size }
+func (info *fileInfo) ModTime() time.Time { return info.modTime }
+func (info *fileInfo) IsDir() bool        { return info.dir }
+func (info *fileInfo) Sys() any           { return nil }
+
+func (info *fileInfo) Mode() os.FileMode {
+	if info.dir {
+		return os.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// readOnlyFile rejects every write to a file in the bucket
+type readOnlyFile struct {
+	name string
+}
+
+func (f *readOnlyFile) Name() string { return f.name }
+func (f *readOnlyFile) Sync() error  { return nil }
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EPERM}
+}
+
+func (f *readOnlyFile) WriteAt([]byte, int64) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EPERM}
+}
+
+func (f *readOnlyFile) WriteString(string) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EPERM}
+}
+
+func (f *readOnlyFile) Truncate(int64) error {
+	return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EPERM}
+}
+
+// dirFile is an open prefix of the bucket
+type dirFile struct {
+	readOnlyFile
+	fs      *Fs
+	key     string
+	info    *fileInfo
+	entries []os.FileInfo
+	listed  bool
+	offset  int
+}
+
+// objectFile is an open object of the bucket, which is read from the current offset until it is closed or seeked
+type objectFile struct {
+	readOnlyFile
+	fs     *Fs
+	key    string
+	info   *fileInfo
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *dirFile) Stat() (os.FileInfo, error)        { return f.info, nil }
+func (f *dirFile) Close() error                      { return nil }
+func (f *dirFile) Read([]byte) (int, error)          { return 0, f.isDirError("read") }
+func (f *dirFile) ReadAt([]byte, int64) (int, error) { return 0, f.isDirError("read") }
+func (f *dirFile) Seek(int64, int) (int64, error)    { return 0, f.isDirError("seek") }
+
+func (f *dirFile) isDirError(op string) error {
+	return &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+}
+
+// Readdir returns the next count entries of the prefix, or all of the remaining entries if count is not positive
+func (f *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.listed {
+		entries, err := f.fs.readDir(f.key)
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: f.name, Err: err}
+		}
+		f.entries = entries
+		f.listed = true
+	}
+
+	remaining := f.entries[f.offset:]
+	if count <= 0 {
+		f.offset = len(f.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	f.offset += count
+	return remaining[:count], nil
+}
+
+func (f *dirFile) Readdirnames(n int) ([]string, error) {
+	entries, err := f.Readdir(n)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, err
+}
+
+func (f *objectFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *objectFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+func (f *objectFile) Readdirnames(int) ([]string, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+func (f *objectFile) Read(p []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		body, err := f.fs.client.get(f.fs.ctx, f.fs.bucket, f.key, f.offset)
+		if err != nil {
+			return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body = body
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *objectFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.info.size {
+		return 0, io.EOF
+	}
+	body, err := f.fs.client.get(f.fs.ctx, f.fs.bucket, f.key, off)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *objectFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	// the next read requests the object again from the new offset
+	if offset != f.offset {
+		if err := f.Close(); err != nil {
+			return 0, err
+		}
+		f.offset = offset
+	}
+	return f.offset, nil
+}
+
+func (f *objectFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
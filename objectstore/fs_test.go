@@ -0,0 +1,220 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBucket serves the requests that the client makes against a single bucket with path-style addressing
+func fakeBucket(t *testing.T, bucket string, objects map[string]string, modTime time.Time) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every request must be signed since the test client has credentials
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		key, ok := strings.CutPrefix(r.URL.Path, "/"+bucket)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		key = strings.TrimPrefix(key, "/")
+
+		// list the bucket
+		if key == "" && r.URL.Query().Get("list-type") == "2" {
+			prefix := r.URL.Query().Get("prefix")
+			var result listBucketResult
+			seen := make(map[string]bool)
+			for k, body := range objects {
+				rest, ok := strings.CutPrefix(k, prefix)
+				if !ok {
+					continue
+				}
+				if dir, _, found := strings.Cut(rest, "/"); found {
+					if !seen[dir] {
+						seen[dir] = true
+						result.CommonPrefixes = append(result.CommonPrefixes, struct {
+							Prefix string `xml:"Prefix"`
+						}{Prefix: prefix + dir + "/"})
+					}
+					continue
+				}
+				result.Contents = append(result.Contents, object{Key: k, Size: int64(len(body)), LastModified: modTime})
+			}
+			sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+			w.Header().Set("Content-Type", "application/xml")
+			require.NoError(t, xml.NewEncoder(w).Encode(result))
+			return
+		}
+
+		body, ok := objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			require.NoError(t, err)
+			body = body[start:]
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, err := io.WriteString(w, body)
+		require.NoError(t, err)
+	}))
+}
+
+func newTestFs(t *testing.T, objects map[string]string) (*Fs, time.Time) {
+	t.Helper()
+
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	server := fakeBucket(t, "logs", objects, modTime)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(config.ObjectStorage{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		AccessKeyID:     "test",
+		SecretAccessKey: "secret",
+		UsePathStyle:    true,
+	}, server.Client())
+	require.NoError(t, err)
+
+	return NewFs(context.Background(), afero.NewMemMapFs(), client, "logs"), modTime
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedBucket string
+		expectedPrefix string
+		expectedErr    error
+	}{
+		{name: "Bucket and Prefix", path: "s3://logs/sensor1/2024-05-01", expectedBucket: "logs", expectedPrefix: "sensor1/2024-05-01"},
+		{name: "Trailing Slash", path: "s3://logs/sensor1/", expectedBucket: "logs", expectedPrefix: "sensor1"},
+		{name: "Bucket Only", path: "s3://logs", expectedBucket: "logs", expectedPrefix: ""},
+		{name: "Uppercase Scheme", path: "S3://logs/sensor1", expectedBucket: "logs", expectedPrefix: "sensor1"},
+		{name: "Missing Bucket", path: "s3:///sensor1", expectedErr: ErrInvalidObjectPath},
+		{name: "Local Path", path: "/logs/sensor1", expectedErr: ErrInvalidObjectPath},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bucket, prefix, err := ParsePath(test.path)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedBucket, bucket)
+			require.Equal(t, test.expectedPrefix, prefix)
+		})
+	}
+}
+
+func TestFsWalk(t *testing.T) {
+	afs, modTime := newTestFs(t, map[string]string{
+		"sensor1/2024-05-01/conn.00:00:00-01:00:00.log": "conn",
+		"sensor1/2024-05-01/dns.00:00:00-01:00:00.log":  "dns log",
+		"sensor1/notes.txt":                             "notes",
+		"sensor2/conn.log":                              "other sensor",
+	})
+
+	// walk the prefix like the import does
+	var files []string
+	err := afero.Walk(afs, MountPath("logs", "sensor1"), func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		if !info.IsDir() {
+			files = append(files, path)
+			require.Equal(t, modTime, info.ModTime())
+			require.Equal(t, os.FileMode(0o444), info.Mode().Perm())
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/s3/logs/sensor1/2024-05-01/conn.00:00:00-01:00:00.log",
+		"/s3/logs/sensor1/2024-05-01/dns.00:00:00-01:00:00.log",
+		"/s3/logs/sensor1/notes.txt",
+	}, files)
+
+	// objects that weren't listed are found with a HEAD request
+	uncached, _ := newTestFs(t, map[string]string{"sensor2/conn.log": "other sensor"})
+	info, err := uncached.Stat("/s3/logs/sensor2/conn.log")
+	require.NoError(t, err)
+	require.False(t, info.IsDir())
+	require.EqualValues(t, len("other sensor"), info.Size())
+
+	// prefixes are directories
+	info, err = uncached.Stat("/s3/logs/sensor2")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	_, err = uncached.Stat("/s3/logs/missing")
+	require.True(t, os.IsNotExist(err), "missing keys should not exist, got: %v", err)
+}
+
+func TestFsRead(t *testing.T) {
+	afs, _ := newTestFs(t, map[string]string{"sensor1/dns.log": "0123456789"})
+
+	contents, err := afero.ReadFile(afs, "/s3/logs/sensor1/dns.log")
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(contents))
+
+	file, err := afs.Open("/s3/logs/sensor1/dns.log")
+	require.NoError(t, err)
+	defer file.Close()
+
+	// seeking requests the rest of the object from the new offset
+	offset, err := file.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, offset)
+	rest, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.Equal(t, "456789", string(rest))
+
+	buf := make([]byte, 3)
+	n, err := file.ReadAt(buf, 2)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, "234", string(buf))
+}
+
+func TestFsReadOnly(t *testing.T) {
+	afs, _ := newTestFs(t, map[string]string{"sensor1/dns.log": "dns"})
+
+	_, err := afs.Create("/s3/logs/sensor1/new.log")
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.ErrorIs(t, afs.Remove("/s3/logs/sensor1/dns.log"), os.ErrPermission)
+	_, err = afs.OpenFile("/s3/logs/sensor1/dns.log", os.O_WRONLY, 0o644)
+	require.ErrorIs(t, err, os.ErrPermission)
+
+	// paths outside of the bucket are read and written through the base file system
+	require.NoError(t, afero.WriteFile(afs, "/feeds/feed.txt", []byte("1.2.3.4"), 0o644))
+	contents, err := afero.ReadFile(afs, "/feeds/feed.txt")
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", string(contents))
+}
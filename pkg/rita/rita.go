@@ -0,0 +1,215 @@
+// Package rita runs imports and analyses from other Go programs, without the command line.
+//
+// The import options are shared by every import in the process, so imports are run one at a time. Unrecoverable
+// failures while writing to the database still exit the process, like they do for the command line.
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/activecm/rita/v5/analysis"
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/database"
+	i "github.com/activecm/rita/v5/importer"
+	"github.com/activecm/rita/v5/util"
+
+	"github.com/spf13/afero"
+)
+
+var ErrMissingConfig = errors.New("config is required")
+var ErrMissingDatabase = errors.New("database is required")
+
+// importMu keeps imports from running at the same time, since they share their options
+var importMu sync.Mutex
+
+// Options are the settings of an import, matching the flags of the import command
+type Options struct {
+	Logs                 string        // log directory, gzipped tarball of a log directory, or s3://bucket/prefix
+	Files                []string      // log files to import instead of Logs, the log type and hour are taken from each file's name
+	Database             string        // name of the dataset to import into
+	Rolling              bool          // builds on and removes data to maintain a fixed length of time
+	Rebuild              bool          // destroys the existing dataset before importing
+	Since                time.Duration // only score beacons over this much time before the newest connection, 0 uses the full window
+	Exclude              []string      // glob patterns of log paths, relative to the log directory, that are skipped
+	RefreshFeeds         bool          // download every online threat intel feed in full
+	FailOnWalkErrors     bool          // fail without importing anything if any file would be left out of the import
+	VerboseWalk          bool          // log how each file found in the log directory was classified, or why it was skipped
+	ShowProgress         bool          // report the percentage of files parsed, the parsing rate, and the time left
+	Deterministic        bool          // import files one at a time in a stable order
+	MaxImportConcurrency int           // maximum number of log files to parse at the same time, 0 uses the config value
+}
+
+// Results are the outcome of an import. Each hour of logs is imported and analyzed as its own import.
+type Results struct {
+	Database    string
+	ImportIDs   []util.FixedString
+	Counts      i.ResultCounts
+	Timestamps  []cmd.ImportTimestamps // time range of each import, in the same order as ImportIDs
+	TopFindings []database.TopFinding  // highest scoring findings of the imports, see top_findings_limit
+}
+
+// AnalysisResults are the outcome of analyzing a dataset again
+type AnalysisResults struct {
+	Database    string
+	ImportID    util.FixedString
+	Timestamps  cmd.ImportTimestamps
+	TopFindings []database.TopFinding // highest scoring findings of the analysis, see top_findings_limit
+}
+
+// Import imports and analyzes the logs in opts.Logs into the opts.Database dataset, reading them from afs. Once ctx is
+// done, the hour of logs that is being imported is finished and the rest are left out.
+func Import(ctx context.Context, cfg *config.Config, afs afero.Fs, opts Options) (*Results, error) {
+	if cfg == nil {
+		return nil, ErrMissingConfig
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := cmd.ValidateDatabaseName(opts.Database); err != nil {
+		return nil, err
+	}
+	if err := cmd.ValidateExcludePatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+	if opts.Since < 0 {
+		return nil, cmd.ErrInvalidBeaconLookback
+	}
+	if opts.MaxImportConcurrency < 0 {
+		return nil, cmd.ErrInvalidImportConcurrency
+	}
+
+	// extract gzipped tarballs so that the tree inside can be imported like a log directory
+	logDir := opts.Logs
+	var files []string
+	var skipped []cmd.WalkError
+	if len(opts.Files) > 0 {
+		// listed files are imported instead of a log directory
+		if logDir != "" {
+			return nil, cmd.ErrFilesWithLogs
+		}
+		var err error
+		files, err = cmd.ParseFileList(opts.Files)
+		if err != nil {
+			return nil, err
+		}
+	} else if cmd.IsTarballPath(logDir) {
+		var err error
+		afs, logDir, skipped, err = cmd.ExtractTarballLogs(afs, logDir, cfg.MaxLogFileBytes)
+		if err != nil {
+			return nil, err
+		}
+	} else if cmd.IsObjectStoragePath(logDir) {
+		// mount object storage buckets so that the objects under the prefix can be imported like a log directory
+		var err error
+		afs, logDir, err = cmd.OpenObjectStorageLogs(ctx, afs, cfg.ObjectStorage, logDir)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := cmd.ValidateLogDirectory(afs, logDir); err != nil {
+		return nil, err
+	}
+
+	importMu.Lock()
+	defer importMu.Unlock()
+
+	cmd.SetImportOptions(cfg, cmd.ImportOptions{
+		BeaconLookback:       opts.Since,
+		Files:                files,
+		ExcludePatterns:      opts.Exclude,
+		SkippedFiles:         skipped,
+		RefreshFeeds:         opts.RefreshFeeds,
+		FailOnWalkErrors:     opts.FailOnWalkErrors,
+		VerboseWalk:          opts.VerboseWalk,
+		ShowProgress:         opts.ShowProgress,
+		Deterministic:        opts.Deterministic,
+		MaxImportConcurrency: opts.MaxImportConcurrency,
+	})
+
+	imported, err := cmd.RunImportContext(ctx, time.Now(), cfg, afs, logDir, opts.Database, opts.Rolling, opts.Rebuild)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{
+		Database:   opts.Database,
+		ImportIDs:  imported.ImportID,
+		Counts:     imported.ResultCounts,
+		Timestamps: imported.ImportTimestamps,
+	}
+
+	db, err := database.ConnectToDB(ctx, opts.Database, cfg, nil)
+	if err != nil {
+		return results, err
+	}
+	defer db.Close()
+
+	results.TopFindings, err = db.GetTopFindings(results.ImportIDs, cfg.TopFindingsLimit, cfg.TopFindingsMinScore)
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// Analyze analyzes every record stored in the dataset that db is connected to again, using cfg, and replaces the
+// results of the previous analyses. The dataset must have finished at least one import.
+func Analyze(ctx context.Context, cfg *config.Config, db *database.DB) (*AnalysisResults, error) {
+	return AnalyzeHosts(ctx, cfg, db, nil, nil)
+}
+
+// AnalyzeHosts analyzes the connections from the onlySrc hosts to the onlyDst hosts again like Analyze, and only
+// replaces their results, like the --only-src and --only-dst flags of the reconfigure command. An empty list matches
+// every host.
+func AnalyzeHosts(ctx context.Context, cfg *config.Config, db *database.DB, onlySrc []net.IP, onlyDst []net.IP) (*AnalysisResults, error) {
+	if cfg == nil {
+		return nil, ErrMissingConfig
+	}
+	if db == nil {
+		return nil, ErrMissingDatabase
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the analysis queries are canceled along with ctx
+	db = db.WithContext(ctx)
+
+	analyzed, err := db.HasFinishedImport()
+	if err != nil {
+		return nil, err
+	}
+	if !analyzed {
+		return nil, fmt.Errorf("%w: %s", cmd.ErrDatabaseNotAnalyzed, db.GetSelectedDB())
+	}
+
+	importMu.Lock()
+	defer importMu.Unlock()
+
+	// the records are analyzed again, so the options of earlier imports are cleared
+	cmd.SetImportOptions(cfg, cmd.ImportOptions{HostFilter: analysis.HostFilter{Src: onlySrc, Dst: onlyDst}})
+
+	importID, timestamps, err := cmd.ReanalyzeDataset(db, cfg, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	results := &AnalysisResults{
+		Database:   db.GetSelectedDB(),
+		ImportID:   importID,
+		Timestamps: timestamps,
+	}
+
+	results.TopFindings, err = db.GetTopFindings([]util.FixedString{importID}, cfg.TopFindingsLimit, cfg.TopFindingsMinScore)
+	if err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
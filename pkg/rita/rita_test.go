@@ -0,0 +1,85 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/activecm/rita/v5/cmd"
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportValidatesOptions(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afs.MkdirAll("/logs", 0o755))
+	cfg := &config.Config{}
+
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		opts        Options
+		expectedErr error
+	}{
+		{
+			name:        "Missing Config",
+			opts:        Options{Logs: "/logs", Database: "mydataset"},
+			expectedErr: ErrMissingConfig,
+		},
+		{
+			name:        "Missing Database",
+			cfg:         cfg,
+			opts:        Options{Logs: "/logs"},
+			expectedErr: cmd.ErrMissingDatabaseName,
+		},
+		{
+			name:        "Missing Log Directory",
+			cfg:         cfg,
+			opts:        Options{Database: "mydataset"},
+			expectedErr: cmd.ErrMissingLogDirectory,
+		},
+		{
+			name:        "Invalid Exclude Pattern",
+			cfg:         cfg,
+			opts:        Options{Logs: "/logs", Database: "mydataset", Exclude: []string{"[dns"}},
+			expectedErr: cmd.ErrInvalidExcludePattern,
+		},
+		{
+			name:        "Negative Since",
+			cfg:         cfg,
+			opts:        Options{Logs: "/logs", Database: "mydataset", Since: -1},
+			expectedErr: cmd.ErrInvalidBeaconLookback,
+		},
+		{
+			name:        "Negative Import Concurrency",
+			cfg:         cfg,
+			opts:        Options{Logs: "/logs", Database: "mydataset", MaxImportConcurrency: -1},
+			expectedErr: cmd.ErrInvalidImportConcurrency,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := Import(context.Background(), test.cfg, afs, test.opts)
+			require.ErrorIs(t, err, test.expectedErr)
+			require.Nil(t, results)
+		})
+	}
+
+	t.Run("Canceled Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		results, err := Import(ctx, cfg, afs, Options{Logs: "/logs", Database: "mydataset"})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Nil(t, results)
+	})
+}
+
+func TestAnalyzeRequiresDatabase(t *testing.T) {
+	_, err := Analyze(context.Background(), nil, nil)
+	require.ErrorIs(t, err, ErrMissingConfig)
+
+	_, err = Analyze(context.Background(), &config.Config{}, nil)
+	require.ErrorIs(t, err, ErrMissingDatabase)
+}
@@ -0,0 +1,24 @@
+package util
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ParentDomain returns the registrable domain that a fqdn is a subdomain of, using the public suffix list, ex: the
+// parent domain of a1.evil.com and a2.evil.com is evil.com. It returns false if the fqdn is an IP address, is
+// already a registrable domain, or is a public suffix.
+func ParentDomain(fqdn string) (string, bool) {
+	fqdn = strings.TrimSuffix(strings.ToLower(fqdn), ".")
+	if fqdn == "" || net.ParseIP(fqdn) != nil {
+		return "", false
+	}
+
+	parent, err := publicsuffix.EffectiveTLDPlusOne(fqdn)
+	if err != nil || parent == fqdn {
+		return "", false
+	}
+	return parent, true
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParentDomain(t *testing.T) {
+	tests := []struct {
+		name           string
+		fqdn           string
+		expectedParent string
+		expectedOK     bool
+	}{
+		{name: "Subdomain", fqdn: "a1.evil.com", expectedParent: "evil.com", expectedOK: true},
+		{name: "Nested Subdomain", fqdn: "x.y.evil.com", expectedParent: "evil.com", expectedOK: true},
+		{name: "Multi-Label Public Suffix", fqdn: "cdn.example.co.uk", expectedParent: "example.co.uk", expectedOK: true},
+		{name: "Private Public Suffix", fqdn: "evil.github.io", expectedOK: false},
+		{name: "Subdomain of Private Public Suffix", fqdn: "a.evil.github.io", expectedParent: "evil.github.io", expectedOK: true},
+		{name: "Uppercase and Trailing Dot", fqdn: "A1.Evil.COM.", expectedParent: "evil.com", expectedOK: true},
+		{name: "Registrable Domain", fqdn: "evil.com", expectedOK: false},
+		{name: "Public Suffix", fqdn: "co.uk", expectedOK: false},
+		{name: "Single Label", fqdn: "localhost", expectedOK: false},
+		{name: "IPv4 Address", fqdn: "10.0.0.1", expectedOK: false},
+		{name: "IPv6 Address", fqdn: "2001:db8::1", expectedOK: false},
+		{name: "Empty", fqdn: "", expectedOK: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, ok := ParentDomain(test.fqdn)
+			require.Equal(t, test.expectedOK, ok)
+			require.Equal(t, test.expectedParent, parent)
+		})
+	}
+}
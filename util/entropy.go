@@ -0,0 +1,31 @@
+package util
+
+import "math"
+
+// ShannonEntropy returns the Shannon entropy of a string in bits per character. Strings made up of
+// a few repeated characters score close to 0, while random looking strings such as base64 encoded
+// data score higher. Each byte is treated as a character.
+func ShannonEntropy(value string) float64 {
+	if len(value) == 0 {
+		return 0
+	}
+
+	// count how many times each character appears
+	var counts [256]int
+	for i := 0; i < len(value); i++ {
+		counts[value[i]]++
+	}
+
+	// sum the information of each character weighted by its probability
+	entropy := 0.0
+	length := float64(len(value))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		probability := float64(count) / length
+		entropy -= probability * math.Log2(probability)
+	}
+
+	return entropy
+}
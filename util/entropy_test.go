@@ -0,0 +1,31 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected float64
+	}{
+		{name: "Empty String", value: "", expected: 0},
+		{name: "Single Character", value: "a", expected: 0},
+		{name: "Repeated Character", value: "aaaaaaaa", expected: 0},
+		{name: "Two Characters Evenly Split", value: "abab", expected: 1},
+		{name: "Four Unique Characters", value: "abcd", expected: 2},
+		{name: "Uneven Distribution", value: "aaab", expected: 0.811278},
+		{name: "Sixteen Unique Characters", value: "0123456789abcdef", expected: 4},
+		{name: "Dictionary Word", value: "google", expected: 1.918296},
+		{name: "Base64 Label", value: "aGVsbG8gd29ybGQgZXhmaWw", expected: 4.055958},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.InDelta(t, test.expected, ShannonEntropy(test.value), 0.000001, "entropy should match expected value")
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// HashComponent is a part of a connection that can be used to identify it, see NewConnectionHash
+type HashComponent int
+
+const (
+	HashBeaconType       HashComponent = iota // the beacon type, keeps results of different types made from the same parts apart
+	HashSrc                                   // the source IP
+	HashSrcNUID                               // the network ID of the source
+	HashDst                                   // the destination IP
+	HashDstNUID                               // the network ID of the destination
+	HashFQDN                                  // the server name or domain
+	HashDstPort                               // the destination port, written as :port
+	HashProto                                 // the protocol, written as :proto
+	HashPortProtoService                      // the port:proto:service keys, joined with commas
+)
+
+// ConnectionIdentity holds the values that the hash of a connection can be made from. Only the values of the chosen
+// components are hashed, the others can be left empty.
+type ConnectionIdentity struct {
+	BeaconType       string
+	Src              net.IP
+	SrcNUID          uuid.UUID
+	Dst              net.IP
+	DstNUID          uuid.UUID
+	FQDN             string
+	DstPort          string // the port as it is keyed, ex: 443, 49152+, or 8/0 for ICMP
+	Proto            string
+	PortProtoService []string
+}
+
+// The components that identify each kind of result. Hashes made from them are the same as the hashes that were stored
+// before the components could be chosen, so that the results of existing datasets keep their hash.
+var (
+	// IPHashComponents identify the connections between two hosts
+	IPHashComponents = []HashComponent{HashSrc, HashSrcNUID, HashDst, HashDstNUID}
+	// SNIHashComponents identify the connections between two hosts for a server name
+	SNIHashComponents = []HashComponent{HashSrc, HashSrcNUID, HashDst, HashDstNUID, HashFQDN}
+	// SrcFQDNHashComponents identify the connections from a host to a server name on any destination
+	SrcFQDNHashComponents = []HashComponent{HashSrc, HashSrcNUID, HashFQDN}
+	// IPPortHashComponents identify the connections between two hosts on a single port and protocol
+	IPPortHashComponents = []HashComponent{HashSrc, HashSrcNUID, HashDst, HashDstNUID, HashDstPort, HashProto}
+	// SNIParentHashComponents identify the connections from a host to the subdomains of a domain
+	SNIParentHashComponents = []HashComponent{HashBeaconType, HashSrc, HashSrcNUID, HashFQDN}
+	// DistributedHashComponents identify the connections from a host to a pool of destinations
+	DistributedHashComponents = []HashComponent{HashBeaconType, HashSrc, HashSrcNUID, HashPortProtoService}
+	// DNSHashComponents identify a domain, DNS results are always keyed on the domain alone: hash = MD5(fqdn)
+	DNSHashComponents = []HashComponent{HashFQDN}
+)
+
+// BeaconHashComponents are the components that identify the results of each beacon type, which can be changed to
+// separate or merge the results of a type. IP and SNI connections are hashed when they're imported, the other types
+// during analysis. The defaults keep the hashes that were stored before the components could be chosen, so that
+// results of existing datasets keep their hash.
+var BeaconHashComponents = map[string][]HashComponent{
+	"ip":          IPHashComponents,
+	"sni":         SrcFQDNHashComponents,
+	"ip_port":     IPPortHashComponents,
+	"sni_parent":  SNIParentHashComponents,
+	"distributed": DistributedHashComponents,
+	"dns":         DNSHashComponents,
+}
+
+// NewBeaconHash returns the hash that identifies a result of the beacon type, made from the components chosen for that
+// type in BeaconHashComponents. DNS results are always keyed on the domain alone.
+func NewBeaconHash(beaconType string, id ConnectionIdentity) (FixedString, error) {
+	if beaconType == "dns" {
+		return NewConnectionHash(id, DNSHashComponents...)
+	}
+
+	components, ok := BeaconHashComponents[beaconType]
+	if !ok {
+		return FixedString{}, fmt.Errorf("no hash components for beacon type: %s", beaconType)
+	}
+	id.BeaconType = beaconType
+	return NewConnectionHash(id, components...)
+}
+
+// NewConnectionHash creates a FixedString from a hash of the chosen components of a connection, in the order they
+// are listed
+func NewConnectionHash(id ConnectionIdentity, components ...HashComponent) (FixedString, error) {
+	if len(components) == 0 {
+		return FixedString{}, errors.New("no hash components provided")
+	}
+
+	parts := make([]string, 0, len(components))
+	for _, component := range components {
+		switch component {
+		case HashBeaconType:
+			parts = append(parts, id.BeaconType)
+		case HashSrc:
+			parts = append(parts, id.Src.To16().String())
+		case HashSrcNUID:
+			parts = append(parts, id.SrcNUID.String())
+		case HashDst:
+			parts = append(parts, id.Dst.To16().String())
+		case HashDstNUID:
+			parts = append(parts, id.DstNUID.String())
+		case HashFQDN:
+			parts = append(parts, id.FQDN)
+		case HashDstPort:
+			parts = append(parts, ":"+id.DstPort)
+		case HashProto:
+			parts = append(parts, ":"+id.Proto)
+		case HashPortProtoService:
+			parts = append(parts, strings.Join(id.PortProtoService, ","))
+		default:
+			return FixedString{}, fmt.Errorf("unknown hash component: %d", component)
+		}
+	}
+
+	return NewFixedStringHash(parts...)
+}
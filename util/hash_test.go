@@ -0,0 +1,134 @@
+package util
+
+import (
+	"crypto/md5" // #nosec G501
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConnectionHash(t *testing.T) {
+	src, dst := net.ParseIP("10.55.100.111"), net.ParseIP("203.0.113.7")
+	srcNUID := uuid.MustParse("11111111-2222-3333-4444-555555555555")
+	dstNUID := uuid.Nil
+
+	id := ConnectionIdentity{
+		Src: src, SrcNUID: srcNUID, Dst: dst, DstNUID: dstNUID, FQDN: "www.example.com", DstPort: "443", Proto: "tcp",
+		PortProtoService: []string{"443:tcp:ssl"},
+	}
+
+	tests := []struct {
+		name       string
+		components []HashComponent
+		expected   string // the string that was hashed before the components could be chosen
+	}{
+		{
+			name:       "IP",
+			components: IPHashComponents,
+			expected:   src.To16().String() + srcNUID.String() + dst.To16().String() + dstNUID.String(),
+		},
+		{
+			name:       "SNI",
+			components: SNIHashComponents,
+			expected:   src.To16().String() + srcNUID.String() + dst.To16().String() + dstNUID.String() + "www.example.com",
+		},
+		{
+			name:       "Source And FQDN",
+			components: SrcFQDNHashComponents,
+			expected:   src.To16().String() + srcNUID.String() + "www.example.com",
+		},
+		{
+			name:       "IP And Port",
+			components: IPPortHashComponents,
+			expected:   src.To16().String() + srcNUID.String() + dst.To16().String() + dstNUID.String() + ":443:tcp",
+		},
+		{
+			name:       "DNS",
+			components: DNSHashComponents,
+			expected:   "www.example.com",
+		},
+		{
+			name:       "Custom",
+			components: []HashComponent{HashSrc, HashDst, HashDstPort},
+			expected:   src.To16().String() + dst.To16().String() + ":443",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := NewConnectionHash(id, test.components...)
+			require.NoError(t, err)
+			// #nosec G401 : this md5 is used for hashing, not for security
+			require.Equal(t, FixedString{Data: md5.Sum([]byte(test.expected))}, hash, "hash should match the hash of the default components")
+		})
+	}
+
+	t.Run("Port Separates Services", func(t *testing.T) {
+		https, err := NewConnectionHash(id, IPPortHashComponents...)
+		require.NoError(t, err)
+		id.DstPort = "8443"
+		alt, err := NewConnectionHash(id, IPPortHashComponents...)
+		require.NoError(t, err)
+		require.NotEqual(t, https, alt)
+	})
+
+	t.Run("Invalid Components", func(t *testing.T) {
+		_, err := NewConnectionHash(id)
+		require.Error(t, err, "a hash needs at least one component")
+
+		_, err = NewConnectionHash(id, HashSrc, HashComponent(-1))
+		require.Error(t, err, "unknown components should be rejected")
+	})
+}
+
+func TestNewBeaconHash(t *testing.T) {
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+	srcNUID := uuid.MustParse("11111111-2222-3333-4444-555555555555")
+
+	// the hashes of each beacon type should match the hashes that were stored before the components could be chosen
+	expected := map[string]string{
+		"ip":          src.To16().String() + srcNUID.String() + dst.To16().String() + uuid.Nil.String(),
+		"sni":         src.To16().String() + srcNUID.String() + "example.com",
+		"ip_port":     src.To16().String() + srcNUID.String() + dst.To16().String() + uuid.Nil.String() + ":443:tcp",
+		"sni_parent":  "sni_parent" + src.To16().String() + srcNUID.String() + "example.com",
+		"distributed": "distributed" + src.To16().String() + srcNUID.String() + "443:tcp:ssl,8443:tcp:ssl",
+		"dns":         "example.com",
+	}
+	id := ConnectionIdentity{
+		Src: src, SrcNUID: srcNUID, Dst: dst, FQDN: "example.com", DstPort: "443", Proto: "tcp",
+		PortProtoService: []string{"443:tcp:ssl", "8443:tcp:ssl"},
+	}
+	for beaconType, joined := range expected {
+		hash, err := NewBeaconHash(beaconType, id)
+		require.NoError(t, err)
+		require.Equal(t, FixedString{Data: md5.Sum([]byte(joined))}, hash, "%s hash should be stable", beaconType) // #nosec G401
+	}
+
+	t.Run("Chosen Components", func(t *testing.T) {
+		defaults := BeaconHashComponents["ip"]
+		defer func() { BeaconHashComponents["ip"] = defaults }()
+
+		BeaconHashComponents["ip"] = IPPortHashComponents
+		hash, err := NewBeaconHash("ip", id)
+		require.NoError(t, err)
+		require.Equal(t, FixedString{Data: md5.Sum([]byte(expected["ip_port"]))}, hash, "the chosen components should form the hash") // #nosec G401
+	})
+
+	t.Run("DNS Keyed On Domain", func(t *testing.T) {
+		defaults := BeaconHashComponents["dns"]
+		defer func() { BeaconHashComponents["dns"] = defaults }()
+
+		// dns results are keyed on the domain alone, no matter what else is known or chosen
+		BeaconHashComponents["dns"] = SNIHashComponents
+		dnsHash, err := NewBeaconHash("dns", ConnectionIdentity{FQDN: "example.com"})
+		require.NoError(t, err)
+		withHosts, err := NewBeaconHash("dns", id)
+		require.NoError(t, err)
+		require.Equal(t, dnsHash, withHosts)
+	})
+
+	_, err := NewBeaconHash("carrier_pigeon", id)
+	require.Error(t, err, "beacon types without components can't be hashed")
+}
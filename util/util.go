@@ -7,12 +7,14 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -86,6 +88,62 @@ func NewFixedStringHash(args ...string) (FixedString, error) {
 	return fs, nil
 }
 
+// checksumSampleSize is the number of bytes read from the start and from the end of a file for its checksum
+const checksumSampleSize = 64 * 1024
+
+// FileChecksum returns a checksum of the contents of a file. Files larger than twice the sample size are checksummed
+// by their size and the first and last sample size bytes so that large logs don't have to be read in full.
+func FileChecksum(afs afero.Fs, path string) (FixedString, error) {
+	info, err := afs.Stat(path)
+	if err != nil {
+		return FixedString{}, err
+	}
+	return FilePrefixChecksum(afs, path, info.Size())
+}
+
+// FilePrefixChecksum returns the checksum of the first size bytes of a file, which is the checksum that FileChecksum
+// returned when the file only held those bytes. This tells a file that was appended to apart from one that was rewritten.
+func FilePrefixChecksum(afs afero.Fs, path string, size int64) (FixedString, error) {
+	file, err := afs.Open(path)
+	if err != nil {
+		return FixedString{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return FixedString{}, err
+	}
+	if info.Size() < size {
+		return FixedString{}, fmt.Errorf("file is %d bytes, which is shorter than the %d bytes to checksum", info.Size(), size)
+	}
+	contents := io.NewSectionReader(file, 0, size)
+
+	// #nosec
+	hash := md5.New()
+	fmt.Fprintf(hash, "%d:", size)
+
+	if size <= 2*checksumSampleSize {
+		if _, err := io.Copy(hash, contents); err != nil {
+			return FixedString{}, err
+		}
+	} else {
+		if _, err := io.CopyN(hash, contents, checksumSampleSize); err != nil {
+			return FixedString{}, err
+		}
+		if _, err := contents.Seek(size-checksumSampleSize, io.SeekStart); err != nil {
+			return FixedString{}, err
+		}
+		if _, err := io.CopyN(hash, contents, checksumSampleSize); err != nil {
+			return FixedString{}, err
+		}
+	}
+
+	var checksum FixedString
+	copy(checksum.Data[:], hash.Sum(nil))
+	return checksum, nil
+}
+
 // NewFixedStringFromString creates a FixedString from a passed in hex string
 func NewFixedStringFromHex(h string) (FixedString, error) {
 	if h == "" {
@@ -185,6 +243,64 @@ func ParseSubnets(subnets []string) ([]*net.IPNet, error) {
 	return parsedSubnets, nil
 }
 
+// PortRange is an inclusive range of ports for a single transport protocol
+type PortRange struct {
+	Start uint16
+	End   uint16
+	Proto string
+}
+
+// ContainsPort checks if a given port and protocol pair is contained in a list of port ranges
+func ContainsPort(ranges []PortRange, port uint16, proto string) bool {
+	for _, r := range ranges {
+		if r.Proto == proto && port >= r.Start && port <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePortRanges parses the provided port:proto or start-end:proto entries into PortRange format
+func ParsePortRanges(entries []string) ([]PortRange, error) {
+	var parsedRanges []PortRange
+
+	for _, entry := range entries {
+		// split the port(s) from the protocol
+		ports, proto, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found {
+			return nil, fmt.Errorf("error parsing port entry %q: expected format port:proto", entry)
+		}
+
+		proto = strings.ToLower(proto)
+		if proto != "tcp" && proto != "udp" {
+			return nil, fmt.Errorf("error parsing port entry %q: protocol must be tcp or udp", entry)
+		}
+
+		// check for a range of ports
+		startPort, endPort, isRange := strings.Cut(ports, "-")
+		if !isRange {
+			endPort = startPort
+		}
+
+		start, err := strconv.ParseUint(startPort, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing port entry %q: %w", entry, err)
+		}
+
+		end, err := strconv.ParseUint(endPort, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing port entry %q: %w", entry, err)
+		}
+
+		if start > end {
+			return nil, fmt.Errorf("error parsing port entry %q: start of port range is greater than the end", entry)
+		}
+
+		parsedRanges = append(parsedRanges, PortRange{Start: uint16(start), End: uint16(end), Proto: proto})
+	}
+	return parsedRanges, nil
+}
+
 // IPIsPubliclyRoutable checks if an IP address is publicly routable. See privateIPBlocks.
 func IPIsPubliclyRoutable(ip net.IP) bool {
 	// cache IPv4 conversion so it not performed every in every ip.IsXXX method
@@ -97,6 +97,103 @@ func TestNewFixedStringHash(t *testing.T) {
 	}
 }
 
+func TestFileChecksum(t *testing.T) {
+	afs := afero.NewMemMapFs()
+
+	small := []byte("#separator \\x09\n1715640000.000001\tC1\t10.0.0.1\n")
+	large := make([]byte, 3*checksumSampleSize)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+
+	write := func(path string, contents []byte) {
+		require.NoError(t, afero.WriteFile(afs, path, contents, 0o644))
+	}
+
+	checksum := func(path string) FixedString {
+		sum, err := FileChecksum(afs, path)
+		require.NoError(t, err)
+		return sum
+	}
+
+	t.Run("Small File", func(t *testing.T) {
+		write("/logs/small.log", small)
+		original := checksum("/logs/small.log")
+		require.NotEqual(t, [16]byte{}, original.Data)
+		require.Equal(t, original, checksum("/logs/small.log"), "checksum should not change if the file didn't")
+
+		// small files are checksummed in full, so any change to the contents changes the checksum
+		edited := append([]byte{}, small...)
+		edited[len(edited)/2] = 'X'
+		write("/logs/small.log", edited)
+		require.NotEqual(t, original.Data, checksum("/logs/small.log").Data)
+	})
+
+	t.Run("Large File", func(t *testing.T) {
+		write("/logs/large.log", large)
+		original := checksum("/logs/large.log")
+
+		// changes to the start, end, or size of large files change the checksum
+		edited := append([]byte{}, large...)
+		edited[0]++
+		write("/logs/large.log", edited)
+		require.NotEqual(t, original.Data, checksum("/logs/large.log").Data, "start of file")
+
+		edited = append([]byte{}, large...)
+		edited[len(edited)-1]++
+		write("/logs/large.log", edited)
+		require.NotEqual(t, original.Data, checksum("/logs/large.log").Data, "end of file")
+
+		write("/logs/large.log", append(append([]byte{}, large...), '\n'))
+		require.NotEqual(t, original.Data, checksum("/logs/large.log").Data, "size of file")
+
+		// the middle of large files isn't read
+		edited = append([]byte{}, large...)
+		edited[len(edited)/2]++
+		write("/logs/large.log", edited)
+		require.Equal(t, original.Data, checksum("/logs/large.log").Data, "middle of file")
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		_, err := FileChecksum(afs, "/logs/missing.log")
+		require.Error(t, err)
+	})
+}
+
+func TestFilePrefixChecksum(t *testing.T) {
+	afs := afero.NewMemMapFs()
+
+	for name, size := range map[string]int{"Small File": 100, "Large File": 3 * checksumSampleSize} {
+		t.Run(name, func(t *testing.T) {
+			contents := make([]byte, size)
+			for i := range contents {
+				contents[i] = byte(i % 251)
+			}
+			require.NoError(t, afero.WriteFile(afs, "/logs/conn.log", contents, 0o644))
+			original, err := FileChecksum(afs, "/logs/conn.log")
+			require.NoError(t, err)
+
+			// the prefix of an appended file has the checksum of the file before it was appended to
+			require.NoError(t, afero.WriteFile(afs, "/logs/conn.log", append(append([]byte{}, contents...), "appended\n"...), 0o644))
+			prefix, err := FilePrefixChecksum(afs, "/logs/conn.log", int64(size))
+			require.NoError(t, err)
+			require.Equal(t, original, prefix, "appended file")
+
+			// the prefix of a rewritten file doesn't
+			edited := append([]byte{}, contents...)
+			edited[len(edited)-1]++
+			require.NoError(t, afero.WriteFile(afs, "/logs/conn.log", append(edited, "appended\n"...), 0o644))
+			prefix, err = FilePrefixChecksum(afs, "/logs/conn.log", int64(size))
+			require.NoError(t, err)
+			require.NotEqual(t, original, prefix, "rewritten file")
+
+			// a file that is shorter than the prefix can't have it
+			_, err = FilePrefixChecksum(afs, "/logs/conn.log", int64(size+100))
+			require.Error(t, err)
+		})
+	}
+}
+
 func TestNewFixedStringFromHex(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -496,6 +593,95 @@ func TestParseSubnets(t *testing.T) {
 	}
 }
 
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []string
+		expected  []PortRange
+		expectErr bool
+	}{
+		{
+			name:    "Single Ports",
+			entries: []string{"123:udp", "9100:tcp"},
+			expected: []PortRange{
+				{Start: 123, End: 123, Proto: "udp"},
+				{Start: 9100, End: 9100, Proto: "tcp"},
+			},
+		},
+		{
+			name:    "Port Range",
+			entries: []string{"1000-2000:tcp"},
+			expected: []PortRange{
+				{Start: 1000, End: 2000, Proto: "tcp"},
+			},
+		},
+		{
+			name:    "Uppercase Protocol",
+			entries: []string{"53:UDP"},
+			expected: []PortRange{
+				{Start: 53, End: 53, Proto: "udp"},
+			},
+		},
+		{
+			name:      "Missing Protocol",
+			entries:   []string{"123"},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid Protocol",
+			entries:   []string{"123:icmp"},
+			expectErr: true,
+		},
+		{
+			name:      "Port Out of Range",
+			entries:   []string{"70000:tcp"},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid Port",
+			entries:   []string{"ntp:udp"},
+			expectErr: true,
+		},
+		{
+			name:      "Reversed Range",
+			entries:   []string{"2000-1000:tcp"},
+			expectErr: true,
+		},
+		{
+			name:     "Empty input",
+			entries:  []string{},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := ParsePortRanges(test.entries)
+
+			if test.expectErr {
+				require.Error(t, err, "parsing port ranges should produce an error")
+			} else {
+				require.NoError(t, err, "parsing port ranges should not produce an error")
+				require.Equal(t, test.expected, result, "parsed port ranges should match expected value")
+			}
+		})
+	}
+}
+
+func TestContainsPort(t *testing.T) {
+	ranges := []PortRange{
+		{Start: 123, End: 123, Proto: "udp"},
+		{Start: 1000, End: 2000, Proto: "tcp"},
+	}
+
+	require.True(t, ContainsPort(ranges, 123, "udp"), "port should be contained in list")
+	require.False(t, ContainsPort(ranges, 123, "tcp"), "port with a different protocol should not be contained in list")
+	require.True(t, ContainsPort(ranges, 1000, "tcp"), "start of range should be contained in list")
+	require.True(t, ContainsPort(ranges, 2000, "tcp"), "end of range should be contained in list")
+	require.False(t, ContainsPort(ranges, 2001, "tcp"), "port outside of range should not be contained in list")
+	require.False(t, ContainsPort(nil, 123, "udp"), "empty list should not contain any port")
+}
+
 func TestIPIsPubliclyRoutable(t *testing.T) {
 	tests := []struct {
 		name     string
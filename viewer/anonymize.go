@@ -0,0 +1,84 @@
+package viewer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/activecm/rita/v5/config"
+
+	"github.com/spf13/afero"
+)
+
+var ErrMissingAnonymizationSalt = errors.New("anonymization_salt must be set in the config file to anonymize results")
+
+// Anonymizer replaces internal IPs with pseudonyms so that results can be shared outside of the organization.
+// Pseudonyms are derived from an HMAC of the IP keyed with the configured salt, so the same IP always gets the
+// same pseudonym and correlations between rows are preserved.
+type Anonymizer struct {
+	filter *config.Filter
+	salt   []byte
+
+	mu      sync.Mutex
+	mapping map[string]string
+}
+
+// NewAnonymizer creates an Anonymizer that uses the internal subnets and anonymization salt from the config
+func NewAnonymizer(cfg *config.Config) (*Anonymizer, error) {
+	if cfg.AnonymizationSalt == "" {
+		return nil, ErrMissingAnonymizationSalt
+	}
+
+	return &Anonymizer{
+		filter:  &cfg.Filter,
+		salt:    []byte(cfg.AnonymizationSalt),
+		mapping: make(map[string]string),
+	}, nil
+}
+
+// IP returns the pseudonym of an internal IP, or the IP itself if it is external
+func (a *Anonymizer) IP(ip net.IP) string {
+	if a == nil || ip == nil || !a.filter.CheckIfInternal(ip) {
+		return ip.String()
+	}
+
+	original := ip.String()
+
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(original))
+	pseudonym := "internal-" + hex.EncodeToString(mac.Sum(nil))[:16]
+
+	// record the pseudonym so that it can be reversed by someone with access to the mapping file
+	a.mu.Lock()
+	a.mapping[pseudonym] = original
+	a.mu.Unlock()
+
+	return pseudonym
+}
+
+// WriteMapping writes the pseudonyms handed out so far and their original IPs to a CSV file. The file
+// can be used to reverse the anonymization, so it is only readable by the current user.
+func (a *Anonymizer) WriteMapping(afs afero.Fs, path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// sort the pseudonyms so that the file is the same for the same results
+	pseudonyms := make([]string, 0, len(a.mapping))
+	for pseudonym := range a.mapping {
+		pseudonyms = append(pseudonyms, pseudonym)
+	}
+	sort.Strings(pseudonyms)
+
+	lines := []string{"Pseudonym,IP"}
+	for _, pseudonym := range pseudonyms {
+		lines = append(lines, fmt.Sprintf("%s,%s", pseudonym, a.mapping[pseudonym]))
+	}
+
+	return afero.WriteFile(afs, path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,89 @@
+package viewer_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/v5/config"
+	"github.com/activecm/rita/v5/util"
+	"github.com/activecm/rita/v5/viewer"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAnonymizer(t *testing.T, salt string) *viewer.Anonymizer {
+	t.Helper()
+
+	subnets, err := util.ParseSubnets([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+
+	cfg := &config.Config{AnonymizationSalt: salt}
+	cfg.Filter.InternalSubnets = subnets
+
+	anonymizer, err := viewer.NewAnonymizer(cfg)
+	require.NoError(t, err)
+	return anonymizer
+}
+
+func TestNewAnonymizerMissingSalt(t *testing.T) {
+	_, err := viewer.NewAnonymizer(&config.Config{})
+	require.ErrorIs(t, err, viewer.ErrMissingAnonymizationSalt)
+}
+
+func TestAnonymizerIP(t *testing.T) {
+	anonymizer := newTestAnonymizer(t, "pepper")
+
+	// internal IPs are replaced with a stable pseudonym
+	internal := anonymizer.IP(net.ParseIP("10.55.100.111"))
+	require.True(t, strings.HasPrefix(internal, "internal-"), "internal IP should be pseudonymized")
+	require.NotContains(t, internal, "10.55.100.111")
+	require.Equal(t, internal, anonymizer.IP(net.ParseIP("10.55.100.111")), "pseudonym should be the same every time")
+	require.NotEqual(t, internal, anonymizer.IP(net.ParseIP("10.55.100.112")), "different IPs should have different pseudonyms")
+	require.True(t, strings.HasPrefix(anonymizer.IP(net.ParseIP("fd00::1")), "internal-"), "internal IPv6 should be pseudonymized")
+
+	// external IPs are left alone
+	require.Equal(t, "88.221.81.192", anonymizer.IP(net.ParseIP("88.221.81.192")))
+	require.Equal(t, "::", anonymizer.IP(net.ParseIP("::")))
+
+	// a different salt gives different pseudonyms
+	require.NotEqual(t, internal, newTestAnonymizer(t, "salt").IP(net.ParseIP("10.55.100.111")))
+
+	// a nil anonymizer leaves every IP alone
+	var disabled *viewer.Anonymizer
+	require.Equal(t, "10.55.100.111", disabled.IP(net.ParseIP("10.55.100.111")))
+}
+
+func TestAnonymizerCSVAndMapping(t *testing.T) {
+	anonymizer := newTestAnonymizer(t, "pepper")
+	src := anonymizer.IP(net.ParseIP("10.55.100.111"))
+	dst := anonymizer.IP(net.ParseIP("10.55.100.1"))
+
+	items := []list.Item{
+		&viewer.Item{Src: net.ParseIP("10.55.100.111"), Dst: net.ParseIP("88.221.81.192"), FQDN: "example.com", FirstSeen: time.Unix(0, 0)},
+		&viewer.Item{Src: net.ParseIP("10.55.100.111"), Dst: net.ParseIP("10.55.100.1"), FirstSeen: time.Unix(0, 0)},
+	}
+
+	csv, err := viewer.FormatToCSV(items, time.Now(), anonymizer)
+	require.NoError(t, err)
+	require.NotContains(t, csv, "10.55.100", "internal IPs should not be in the output")
+
+	rows := strings.Split(csv, "\n")
+	require.Len(t, rows, 3)
+	require.True(t, strings.Contains(rows[1], ","+src+",88.221.81.192,example.com,"), "external IPs and fqdns should be left intact")
+	require.True(t, strings.Contains(rows[2], ","+src+","+dst+","), "the same IP should have the same pseudonym on every row")
+
+	// the mapping file lists each pseudonym with its original IP
+	afs := afero.NewMemMapFs()
+	require.NoError(t, anonymizer.WriteMapping(afs, "/mapping.csv"))
+
+	contents, err := afero.ReadFile(afs, "/mapping.csv")
+	require.NoError(t, err)
+	require.Contains(t, string(contents), "Pseudonym,IP\n")
+	require.Contains(t, string(contents), src+",10.55.100.111\n")
+	require.Contains(t, string(contents), dst+",10.55.100.1\n")
+	require.NotContains(t, string(contents), "88.221.81.192")
+}
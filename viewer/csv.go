@@ -13,12 +13,20 @@ import (
 
 // can pass in filter here so that users can pass in a search as a cmdline flag
 // func GetCSVOutput(items []list.Item, relativeTimestamp time.Time) string {
-func GetCSVOutput(db *database.DB, minTimestamp, relativeTimestamp time.Time, search string, limit int) (string, error) {
+// if anonymizer is not nil, internal IPs are replaced with their pseudonyms
+// results to destinations on the beacon allowlist are left out unless includeAllowlisted is set
+// only results that an analyst has annotated are kept if showAnnotated is set
+func GetCSVOutput(db *database.DB, minTimestamp, relativeTimestamp time.Time, search string, limit int, includeAllowlisted bool, showAnnotated bool, anonymizer *Anonymizer) (string, error) {
 	// parse the search input
 	filter, parseErr := ParseSearchInput(search)
 	if parseErr != "" {
 		return "", fmt.Errorf("error parsing search input: %s", parseErr)
 	}
+	if filter == nil {
+		filter = &Filter{}
+	}
+	filter.ExcludeAllowlisted = !includeAllowlisted
+	filter.Annotated = showAnnotated
 
 	// default to 100 results if no limit is specified
 	pageSize := 100
@@ -33,11 +41,11 @@ func GetCSVOutput(db *database.DB, minTimestamp, relativeTimestamp time.Time, se
 	}
 
 	// format the results into CSV
-	return FormatToCSV(items, relativeTimestamp)
+	return FormatToCSV(items, relativeTimestamp, anonymizer)
 
 }
 
-func FormatToCSV(items []list.Item, relativeTimestamp time.Time) (string, error) {
+func FormatToCSV(items []list.Item, relativeTimestamp time.Time, anonymizer *Anonymizer) (string, error) {
 	// if len(items) == 0 {
 	// 	return "", fmt.Errorf("no items to format")
 	// }
@@ -49,6 +57,13 @@ func FormatToCSV(items []list.Item, relativeTimestamp time.Time) (string, error)
 		"Destination IP",
 		"FQDN",
 		"Beacon Score",
+		"Beacon Timestamp Score",
+		"Beacon Data Size Score",
+		"Beacon Duration Score",
+		"Beacon Histogram Score",
+		"Beacon Interval P50",
+		"Beacon Interval P90",
+		"Beacon Interval P99",
 		"Strobe",
 		"Total Duration",
 		"Long Connection Score",
@@ -62,6 +77,14 @@ func FormatToCSV(items []list.Item, relativeTimestamp time.Time) (string, error)
 		"Total Bytes",
 		"Port:Proto:Service",
 		"Modifiers",
+		"Sensor",
+		"Allowlisted",
+		"Country",
+		"ASN",
+		"AS Organization",
+		"Hash",
+		"Annotation",
+		"Annotation Note",
 	}
 
 	// loop over the results and format into rows and columns
@@ -72,25 +95,40 @@ func FormatToCSV(items []list.Item, relativeTimestamp time.Time) (string, error)
 		if !ok {
 			return "", fmt.Errorf("error casting item to Item")
 		}
+		flat := FlattenItem(item, relativeTimestamp, anonymizer)
 
 		// create a slice to hold the fields for this row
 		fields := []string{
-			item.GetSeverity(false), item.Src.String(), item.Dst.String(), item.FQDN,
-			fmt.Sprint(item.BeaconScore), strconv.FormatBool(item.StrobeScore > 0),
-			fmt.Sprint(item.TotalDuration), fmt.Sprint(item.LongConnScore),
-			fmt.Sprint(item.Subdomains), fmt.Sprint(item.C2OverDNSScore), strconv.FormatBool(item.ThreatIntelScore > 0),
-			fmt.Sprint(item.Prevalence), item.GetFirstSeen(relativeTimestamp), strconv.FormatBool(item.MissingHostCount > 0),
-			fmt.Sprint(item.Count), fmt.Sprint(item.TotalBytes), fmt.Sprintf("\"%s\"", strings.Join(item.PortProtoService, ",")),
+			flat.Severity, flat.Src, flat.Dst, flat.FQDN,
+			fmt.Sprint(flat.BeaconScore), fmt.Sprint(flat.BeaconTSScore), fmt.Sprint(flat.BeaconDSScore),
+			fmt.Sprint(flat.BeaconDurScore), fmt.Sprint(flat.BeaconHistScore),
+			fmt.Sprint(flat.BeaconIntervalP50), fmt.Sprint(flat.BeaconIntervalP90), fmt.Sprint(flat.BeaconIntervalP99),
+			strconv.FormatBool(flat.Strobe),
+			fmt.Sprint(flat.TotalDuration), fmt.Sprint(flat.LongConnScore),
+			fmt.Sprint(flat.Subdomains), fmt.Sprint(flat.C2OverDNSScore), strconv.FormatBool(flat.ThreatIntel),
+			fmt.Sprint(flat.Prevalence), flat.FirstSeenAgo, strconv.FormatBool(flat.MissingHostHeader),
+			fmt.Sprint(flat.Count), fmt.Sprint(flat.TotalBytes), fmt.Sprintf("\"%s\"", flat.PortProtoService),
 		}
 
-		// create a slice to hold the modifiers
-		modifierList := make([]string, 0, len(item.Modifiers))
-		for _, mod := range item.Modifiers {
-			modifierList = append(modifierList, fmt.Sprintf("%s:%s", mod["modifier_name"], mod["modifier_value"]))
+		// add the modifiers to the fields
+		fields = append(fields, fmt.Sprintf("\"%s\"", flat.Modifiers))
+
+		// add the sensors that observed this connection
+		fields = append(fields, fmt.Sprintf("\"%s\"", flat.Sensor))
+
+		// flag results to destinations on the beacon allowlist, which are only included when requested
+		fields = append(fields, strconv.FormatBool(flat.Allowlisted))
+
+		// add the geolocation of the external destination, which is empty unless geoip databases are configured
+		asn := ""
+		if flat.ASN > 0 {
+			asn = fmt.Sprint(flat.ASN)
 		}
+		fields = append(fields, flat.Country, asn, fmt.Sprintf("\"%s\"", flat.ASOrg))
 
-		// add the modifiers to the fields
-		fields = append(fields, fmt.Sprintf("\"%s\"", strings.Join(modifierList, ",")))
+		// add the hash that identifies the result to rita annotate and the analyst's triage status,
+		// quotes in the free text note are escaped by doubling them
+		fields = append(fields, flat.Hash, flat.Annotation, fmt.Sprintf("\"%s\"", strings.ReplaceAll(flat.AnnotationNote, "\"", "\"\"")))
 
 		// create comma-delimited string from each field in this row
 		formattedRow := strings.Join(fields, ",")
@@ -106,3 +144,94 @@ func FormatToCSV(items []list.Item, relativeTimestamp time.Time) (string, error)
 	// print comma-delimited columns
 	return strings.Join(csvOutput, "\n"), nil
 }
+
+// FlatResult is a mixtape result flattened into plain values, one per column, for the CSV output and the export sinks.
+// Lists are joined with commas, and internal IPs are replaced with their pseudonyms if the result was flattened with
+// an anonymizer.
+type FlatResult struct {
+	Hash              string
+	ImportID          string
+	Severity          string
+	FinalScore        float32
+	Src               string
+	Dst               string
+	FQDN              string
+	BeaconScore       float32
+	BeaconTSScore     float32
+	BeaconDSScore     float32
+	BeaconDurScore    float32
+	BeaconHistScore   float32
+	BeaconIntervalP50 int64
+	BeaconIntervalP90 int64
+	BeaconIntervalP99 int64
+	Strobe            bool
+	TotalDuration     float32
+	LongConnScore     float32
+	Subdomains        uint64
+	C2OverDNSScore    float32
+	ThreatIntel       bool
+	Prevalence        float32
+	FirstSeen         time.Time
+	FirstSeenAgo      string // how long before the relative timestamp the result was first seen, ie: 2 days ago
+	LastSeen          time.Time
+	MissingHostHeader bool
+	Count             uint64
+	TotalBytes        uint64
+	PortProtoService  string
+	Modifiers         string // name:value pairs
+	Sensor            string
+	Allowlisted       bool
+	Country           string
+	ASN               uint32
+	ASOrg             string
+	Annotation        string
+	AnnotationNote    string
+}
+
+// FlattenItem flattens a mixtape result into the values of each column
+func FlattenItem(item *Item, relativeTimestamp time.Time, anonymizer *Anonymizer) FlatResult {
+	modifierList := make([]string, 0, len(item.Modifiers))
+	for _, mod := range item.Modifiers {
+		modifierList = append(modifierList, fmt.Sprintf("%s:%s", mod["modifier_name"], mod["modifier_value"]))
+	}
+
+	return FlatResult{
+		Hash:              item.Hash,
+		ImportID:          item.ImportID,
+		Severity:          item.GetSeverity(false),
+		FinalScore:        item.FinalScore,
+		Src:               anonymizer.IP(item.Src),
+		Dst:               anonymizer.IP(item.Dst),
+		FQDN:              item.FQDN,
+		BeaconScore:       item.BeaconScore,
+		BeaconTSScore:     item.BeaconTSScore,
+		BeaconDSScore:     item.BeaconDSScore,
+		BeaconDurScore:    item.BeaconDurScore,
+		BeaconHistScore:   item.BeaconHistScore,
+		BeaconIntervalP50: item.BeaconIntervalP50,
+		BeaconIntervalP90: item.BeaconIntervalP90,
+		BeaconIntervalP99: item.BeaconIntervalP99,
+		Strobe:            item.StrobeScore > 0,
+		TotalDuration:     item.TotalDuration,
+		LongConnScore:     item.LongConnScore,
+		Subdomains:        item.Subdomains,
+		C2OverDNSScore:    item.C2OverDNSScore,
+		ThreatIntel:       item.ThreatIntelScore > 0,
+		Prevalence:        item.Prevalence,
+		FirstSeen:         item.FirstSeen,
+		FirstSeenAgo:      item.GetFirstSeen(relativeTimestamp),
+		LastSeen:          item.LastSeen,
+		MissingHostHeader: item.MissingHostCount > 0,
+		Count:             item.Count,
+		TotalBytes:        item.TotalBytes,
+		PortProtoService:  strings.Join(item.PortProtoService, ","),
+		Modifiers:         strings.Join(modifierList, ","),
+		Sensor:            item.Sensor,
+		Allowlisted:       item.Allowlisted,
+		Country:           item.DstCountry,
+		ASN:               item.DstASN,
+		ASOrg:             item.DstASOrg,
+		Annotation:        item.AnnotationStatus,
+		AnnotationNote:    item.AnnotationNote,
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-const expectedCSVHeader = "Severity,Source IP,Destination IP,FQDN,Beacon Score,Strobe,Total Duration,Long Connection Score,Subdomains,C2 Over DNS Score,Threat Intel,Prevalence,First Seen,Missing Host Header,Connection Count,Total Bytes,Port:Proto:Service,Modifiers\n"
+const expectedCSVHeader = "Severity,Source IP,Destination IP,FQDN,Beacon Score,Beacon Timestamp Score,Beacon Data Size Score,Beacon Duration Score,Beacon Histogram Score,Beacon Interval P50,Beacon Interval P90,Beacon Interval P99,Strobe,Total Duration,Long Connection Score,Subdomains,C2 Over DNS Score,Threat Intel,Prevalence,First Seen,Missing Host Header,Connection Count,Total Bytes,Port:Proto:Service,Modifiers,Sensor,Allowlisted,Country,ASN,AS Organization,Hash,Annotation,Annotation Note\n"
 
 // func (s *ViewerTestSuite) TestGetCSVOutput() {
 // 	// minTimestamp, maxTimestamp, _, useCurrentTime, err := s.db.GetBeaconMinMaxTimestamps()
@@ -77,6 +77,13 @@ func (s *ViewerTestSuite) TestFormatToCSV() {
 					BeaconScore:              0.75,
 					StrobeScore:              0,
 					BeaconThreatScore:        0,
+					BeaconTSScore:            0.9,
+					BeaconDSScore:            0.6,
+					BeaconDurScore:           0.7,
+					BeaconHistScore:          0.8,
+					BeaconIntervalP50:        60,
+					BeaconIntervalP90:        62,
+					BeaconIntervalP99:        120,
 					TotalDuration:            10800,
 					LongConnScore:            0.8,
 					FirstSeen:                time.Now().Add(-3 * 24 * time.Hour),
@@ -92,11 +99,33 @@ func (s *ViewerTestSuite) TestFormatToCSV() {
 					TotalBytesFormatted:      "23.21 MiB",
 					MissingHostHeaderScore:   0.1,
 					MissingHostCount:         0,
+					Sensor:                   "sensor1,sensor2",
+					DstCountry:               "NL",
+					DstASN:                   16625,
+					DstASOrg:                 "Akamai Technologies, Inc.",
 				}),
 			},
 			relativeTimestamp: time.Now(),
 			expectedCSV: expectedCSVHeader +
-				"High,10.55.100.111,88.221.81.192,example.com,0.75,false,10800,0.8,3,0.45,true,0.35,3 days ago,false,2574,24335500,\"80:tcp:http,443:tcp:https\",\"\"",
+				"High,10.55.100.111,88.221.81.192,example.com,0.75,0.9,0.6,0.7,0.8,60,62,120,false,10800,0.8,3,0.45,true,0.35,3 days ago,false,2574,24335500,\"80:tcp:http,443:tcp:https\",\"\",\"sensor1,sensor2\",false,NL,16625,\"Akamai Technologies, Inc.\",,,\"\"",
+			expectedError: false,
+		},
+		{
+			name: "annotated result",
+			data: []list.Item{
+				list.Item(&viewer.Item{
+					Src:              net.ParseIP("10.55.100.111"),
+					Dst:              net.ParseIP("88.221.81.192"),
+					FinalScore:       0.8,
+					FirstSeen:        time.Now().Add(-3 * 24 * time.Hour),
+					Hash:             "8A1BC5F0D2E94C7B9E0F3A6D5C4B2A19",
+					AnnotationStatus: "benign",
+					AnnotationNote:   `backup agent, see "ticket 42"`,
+				}),
+			},
+			relativeTimestamp: time.Now(),
+			expectedCSV: expectedCSVHeader +
+				"High,10.55.100.111,88.221.81.192,,0,0,0,0,0,0,0,0,false,0,0,0,0,false,0,3 days ago,false,0,0,\"\",\"\",\"\",false,,,\"\",8A1BC5F0D2E94C7B9E0F3A6D5C4B2A19,benign,\"backup agent, see \"\"ticket 42\"\"\"",
 			expectedError: false,
 		},
 		{
@@ -113,7 +142,7 @@ func (s *ViewerTestSuite) TestFormatToCSV() {
 			require := require.New(t)
 
 			// run the function
-			csv, err := viewer.FormatToCSV(test.data, test.relativeTimestamp)
+			csv, err := viewer.FormatToCSV(test.data, test.relativeTimestamp, nil)
 
 			// check if error was expected
 			require.Equal(test.expectedError, err != nil, "expected error to be %v, but got %v", test.expectedError, err)
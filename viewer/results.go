@@ -22,11 +22,24 @@ type MixtapeResult struct {
 	Dst                      net.IP              `ch:"dst" json:"dst"`
 	FQDN                     string              `ch:"fqdn"`
 	FinalScore               float32             `ch:"final_score"`
+	CurrentScore             float32             `ch:"current_score"`
+	LastSeen                 time.Time           `ch:"last_seen"`
 	Count                    uint64              `ch:"count"`
 	ProxyCount               uint64              `ch:"proxy_count"`
 	BeaconScore              float32             `ch:"beacon_score"`
 	StrobeScore              float32             `ch:"strobe_score"`
 	BeaconThreatScore        float32             `ch:"beacon_threat_score"`
+	BeaconTSScore            float32             `ch:"ts_score"`
+	BeaconDSScore            float32             `ch:"ds_score"`
+	BeaconDurScore           float32             `ch:"dur_score"`
+	BeaconHistScore          float32             `ch:"hist_score"`
+	BeaconIntervalP50        int64               `ch:"ts_interval_p50"`
+	BeaconIntervalP90        int64               `ch:"ts_interval_p90"`
+	BeaconIntervalP99        int64               `ch:"ts_interval_p99"`
+	Allowlisted              bool                `ch:"allowlisted"`
+	DstCountry               string              `ch:"dst_country"`
+	DstASN                   uint32              `ch:"dst_asn"`
+	DstASOrg                 string              `ch:"dst_as_org"`
 	TotalDuration            float32             `ch:"total_duration"`
 	LongConnScore            float32             `ch:"long_conn_score"`
 	FirstSeen                time.Time           `ch:"first_seen_historical"`
@@ -44,9 +57,16 @@ type MixtapeResult struct {
 	TotalBytesFormatted      string              `ch:"total_bytes_formatted"`
 	MissingHostHeaderScore   float32             `ch:"missing_host_header_score"`
 	MissingHostCount         uint64              `ch:"missing_host_count"`
+	MissingBytesCount        uint64              `ch:"missing_bytes_count"`
 	ProxyIPs                 []net.IP            `ch:"proxy_ips"`
 	Modifiers                []map[string]string `ch:"modifiers"`
 	TotalModifierScore       float32             `ch:"total_modifier_score"`
+	Sensor                   string              `ch:"sensor"`
+	BeaconType               string              `ch:"beacon_type"`
+	Hash                     string              `ch:"hash_hex"`
+	ImportID                 string              `ch:"import_id_hex"`
+	AnnotationStatus         string              `ch:"annotation_status"`
+	AnnotationNote           string              `ch:"annotation_note"`
 }
 
 type Item MixtapeResult
@@ -59,9 +79,21 @@ func (i *Item) GetSrc() string {
 	return i.Src.String()
 }
 func (i *Item) GetDst() string {
+	// beacons to the subdomains of a domain are shown with a wildcard so that they can be told apart from the domain
+	if i.BeaconType == "sni_parent" {
+		return "*." + i.FQDN
+	}
 	if i.Dst.String() == "::" && len(i.FQDN) > 0 {
 		return i.FQDN
 	}
+	// distributed beacons are made to a pool of destinations, so they are shown by the port they were made on
+	if i.Dst.String() == "::" && len(i.PortProtoService) > 0 {
+		return "* " + i.PortProtoService[0]
+	}
+	// per-port beacons are shown with their port so that they can be told apart from the connection as a whole
+	if i.BeaconType == "ip_port" && len(i.PortProtoService) > 0 {
+		return i.Dst.String() + " " + i.PortProtoService[0]
+	}
 	return i.Dst.String()
 }
 
@@ -174,13 +206,13 @@ func (i *Item) GetSeverity(color bool) string {
 // GetResults queries the database for mixtape results based on the filter and pagination parameters
 func GetResults(db *database.DB, filter *Filter, currentPage, pageSize int, minTimestamp time.Time) ([]list.Item, bool, error) {
 	// build query
-	query, params, appliedFilter := BuildResultsQuery(filter, currentPage, pageSize, minTimestamp)
+	query, params, appliedFilter := BuildResultsQuery(filter, currentPage, pageSize, minTimestamp, db.GetScoreDecayHalfLife())
 
 	// set context
-	ctx := clickhouse.Context(db.GetContext(), clickhouse.WithParameters(params))
+	ctx := db.QueryParameters(params)
 
 	// query database for results
-	rows, err := db.Conn.Query(ctx, query)
+	rows, err := db.ReadConn.Query(ctx, query)
 	if err != nil {
 		return nil, false, err
 	}
@@ -199,8 +231,11 @@ func GetResults(db *database.DB, filter *Filter, currentPage, pageSize int, minT
 	return items, appliedFilter, nil
 }
 
-// BuildResultsQuery builds a query for fetching mixtape results based on the filter and pagination parameters
-func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp time.Time) (string, clickhouse.Parameters, bool) {
+// BuildResultsQuery builds a query for fetching mixtape results based on the filter and pagination parameters.
+// In rolling datasets, the current score of each result is halved for every scoreDecayHalfLife hours between when it
+// was last seen and the newest log in the dataset, so that results that stopped sort below the ones that are ongoing.
+// The current score is the same as the final score when scoreDecayHalfLife is 0.
+func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp time.Time, scoreDecayHalfLife float64) (string, clickhouse.Parameters, bool) {
 	params := clickhouse.Parameters{}
 	query := `--sql
 		SELECT src, dst, fqdn,
@@ -214,6 +249,17 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 		port_proto_service,
 		beacon_score as beacon_score,
 		beacon_threat_score,
+		ts_score,
+		ds_score,
+		dur_score,
+		hist_score,
+		ts_interval_p50,
+		ts_interval_p90,
+		ts_interval_p99,
+		allowlisted,
+		dst_country,
+		dst_asn,
+		dst_as_org,
 		c2_over_dns_score,
 		strobe_score,
 		total_duration,
@@ -227,10 +273,22 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 		threat_intel_data_size_score,
 		missing_host_count,
 		missing_host_header_score,
+		missing_bytes_count,
 		c2_over_dns_direct_conn_score,
 		modifiers,
 		total_modifier_score,
-		toFloat32(base_score + total_modifier_score + prevalence_score + first_seen_score + missing_host_header_score + threat_intel_data_size_score + c2_over_dns_direct_conn_score) as final_score
+		sensor,
+		beacon_type,
+		hex(r.hash) AS hash_hex,
+		import_id_hex,
+		annotation_status,
+		annotation_note,
+		toFloat32(base_score + total_modifier_score + prevalence_score + first_seen_score + missing_host_header_score + threat_intel_data_size_score + c2_over_dns_direct_conn_score) as final_score,
+		latest_seen as last_seen,
+		toFloat32(if({score_decay_half_life:Float64} > 0 AND d.rolling,
+			final_score * pow(0.5, greatest(dateDiff('second', latest_seen, d.max_ts), 0) / ({score_decay_half_life:Float64} * 3600)),
+			final_score
+		)) as current_score
 		-- base_score
 		-- total_modifier_score
 	
@@ -245,6 +303,17 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 			flatten(groupArray(port_proto_service)) as port_proto_service,
 			toFloat32(sum(beacon_score)) as beacon_score,
 			toFloat32(sum(beacon_threat_score)) as beacon_threat_score,
+			toFloat32(sum(ts_score)) as ts_score,
+			toFloat32(sum(ds_score)) as ds_score,
+			toFloat32(sum(dur_score)) as dur_score,
+			toFloat32(sum(hist_score)) as hist_score,
+			max(ts_interval_p50) as ts_interval_p50, -- modifier rows don't have intervals, so take the beacon row's value
+			max(ts_interval_p90) as ts_interval_p90,
+			max(ts_interval_p99) as ts_interval_p99,
+			max(allowlisted) as allowlisted,
+			max(dst_country) as dst_country, -- modifier rows aren't geolocated, so take the analysis row's value
+			max(dst_asn) as dst_asn,
+			max(dst_as_org) as dst_as_org,
 			toFloat32(sum(c2_over_dns_score)) as c2_over_dns_score,
 			toFloat32(sum(strobe_score)) as strobe_score,
 			toFloat32(sum(total_duration)) as total_duration,
@@ -257,11 +326,16 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 			toFloat32(sum(threat_intel_score)) as threat_intel_score,
 			toFloat32(sum(threat_intel_data_size_score)) as threat_intel_data_size_score,
 			sum(missing_host_count) as missing_host_count,
+			sum(missing_bytes_count) as missing_bytes_count,
 			toFloat32(sum(missing_host_header_score)) as missing_host_header_score,
 			toFloat32(sum(c2_over_dns_direct_conn_score)) as c2_over_dns_direct_conn_score,
 			arraySort(groupUniqArrayIf(map('modifier_name', modifier_name, 'modifier_value', modifier_value), modifier_name != '')) as modifiers,
 			toFloat32(sum(modifier_score)) as total_modifier_score,
-			greatest(beacon_threat_score, long_conn_score, strobe_score, c2_over_dns_score, threat_intel_score) as base_score
+			max(sensor) as sensor, -- modifier rows don't have a sensor, so take the non-empty value
+			max(beacon_type) as beacon_type, -- modifier rows don't have a beacon type, so take the non-empty value
+			greatest(beacon_threat_score, long_conn_score, strobe_score, c2_over_dns_score, threat_intel_score) as base_score,
+			max(x.max_last_seen) as latest_seen,
+			hex(any(x.import_id)) as import_id_hex -- the import that the result was last seen in
 		FROM threat_mixtape t
 		INNER JOIN (SELECT hash, argMax(import_id, last_seen) as import_id, max(last_seen) as max_last_seen FROM threat_mixtape GROUP BY hash) x
 		ON t.hash = x.hash and t.last_seen = x.max_last_seen and t.import_id = x.import_id
@@ -323,6 +397,10 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 			params["subdomains"] = filter.Subdomains.Value
 		}
 
+		if filter.ExcludeAllowlisted {
+			havingConditions = append(havingConditions, "allowlisted = false")
+		}
+
 		if filter.Duration.Value != "" && filter.Duration.Operator != "" {
 			if filter.Duration.Operator == "=" {
 				// round column down to the nearest integer if the operator is equ
@@ -339,24 +417,42 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 		query += "HAVING " + strings.Join(havingConditions, " AND ")
 	}
 
-	// add parentheses to close subquery
+	// close subquery and attach the analyst's annotations, which are keyed by hash so that they survive re-imports
 	query += `--sql
-	)`
+	) r
+	LEFT JOIN (
+		SELECT hash, argMax(status, updated_at) AS annotation_status, argMax(note, updated_at) AS annotation_note
+		FROM {metadatabase:Identifier}.annotations
+		WHERE database = currentDatabase()
+		GROUP BY hash
+	) a ON r.hash = a.hash
+	-- the newest log in the dataset, which the current score decays relative to
+	CROSS JOIN (
+		SELECT max(max_ts) AS max_ts, argMax(rolling, max_ts) AS rolling
+		FROM {metadatabase:Identifier}.min_max
+		WHERE database = currentDatabase()
+	) d
+	`
 
 	// add where conditions to the outer part of the query if any were specified
 	outerWhereConditions := []string{}
 	if filter != nil {
 		// add conditions for severity filter to query
-		if len(filter.Severity) > 0 {
-			for i, op := range filter.Severity {
-				paramName := fmt.Sprintf("final_score_%d", i)
-				outerWhereConditions = append(outerWhereConditions, "final_score "+op.Operator+fmt.Sprintf("{%s:Float32}", paramName))
-				params[paramName] = op.Value
-			}
-			query += "WHERE " + strings.Join(outerWhereConditions, " AND ")
+		for i, op := range filter.Severity {
+			paramName := fmt.Sprintf("final_score_%d", i)
+			outerWhereConditions = append(outerWhereConditions, "final_score "+op.Operator+fmt.Sprintf("{%s:Float32}", paramName))
+			params[paramName] = op.Value
+		}
+
+		if filter.Annotated {
+			outerWhereConditions = append(outerWhereConditions, "annotation_status != ''")
 		}
 	}
 
+	if len(outerWhereConditions) > 0 {
+		query += "WHERE " + strings.Join(outerWhereConditions, " AND ")
+	}
+
 	// set sorting conditions if any were specified
 	sortingConditions := []string{}
 	if filter != nil {
@@ -379,7 +475,7 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 		query += "ORDER BY " + strings.Join(sortingConditions, ",")
 	} else {
 		query += `--sql
-			ORDER BY final_score DESC, strobe_score DESC, beacon_score DESC
+			ORDER BY current_score DESC, final_score DESC, strobe_score DESC, beacon_score DESC
 		`
 	}
 
@@ -397,6 +493,7 @@ func BuildResultsQuery(filter *Filter, currentPage, pageSize int, minTimestamp t
 	}
 	params["page_size"] = fmt.Sprint(pageSize)
 	params["min_ts"] = fmt.Sprintf("%d", minTimestamp.UTC().Unix())
+	params["score_decay_half_life"] = fmt.Sprint(scoreDecayHalfLife)
 	appliedFilter := len(whereConditions) > 0 || len(havingConditions) > 0 || len(outerWhereConditions) > 0 || len(sortingConditions) > 0
 	return query, params, appliedFilter
 }
@@ -39,15 +39,19 @@ type OperatorFilter struct {
 	Value    string
 }
 type Filter struct {
-	Src            string
-	Dst            string
-	Fqdn           string
-	Severity       []OperatorFilter
-	Count          OperatorFilter
-	Beacon         OperatorFilter
-	Duration       OperatorFilter
-	Subdomains     OperatorFilter
-	ThreatIntel    string
+	Src         string
+	Dst         string
+	Fqdn        string
+	Severity    []OperatorFilter
+	Count       OperatorFilter
+	Beacon      OperatorFilter
+	Duration    OperatorFilter
+	Subdomains  OperatorFilter
+	ThreatIntel string
+	// ExcludeAllowlisted leaves out results to destinations on the beacon allowlist
+	ExcludeAllowlisted bool
+	// Annotated only keeps results that an analyst has annotated
+	Annotated      bool
 	SortSeverity   string
 	SortBeacon     string
 	SortDuration   string
@@ -150,6 +150,14 @@ func (m *sidebarModel) getSidebarContents() string {
 		bytes = dataStyle.Render(lipgloss.JoinVertical(lipgloss.Top, bytesHeader, m.Data.TotalBytesFormatted))
 	}
 
+	// display the analyst's triage status and note if the result was annotated
+	var annotationLabel, annotation string
+	if m.Data.AnnotationStatus != "" {
+		annotationLabel = sectionStyle.Render("「 Annotation 」")
+		statusStyle := lipgloss.NewStyle().Background(overlay2).Foreground(base).Bold(true).Padding(0, 2)
+		annotation = dataStyle.Render(lipgloss.JoinVertical(lipgloss.Top, statusStyle.Render(strings.ToUpper(m.Data.AnnotationStatus)), m.Data.AnnotationNote))
+	}
+
 	// get port:proto:service
 	portProtoService := m.Data.GetPortProtoService()
 	// DEBUG SIDEFEED SCROLLING WITH LONG PORT:PROTO:SERVICE
@@ -170,7 +178,7 @@ func (m *sidebarModel) getSidebarContents() string {
 	}
 
 	// join contents
-	return lipgloss.JoinVertical(lipgloss.Top, heading, modifierLabel, modifiers, connInfoLabel, connCount, bytes, ports)
+	return lipgloss.JoinVertical(lipgloss.Top, heading, annotationLabel, annotation, modifierLabel, modifiers, connInfoLabel, connCount, bytes, ports)
 }
 
 // renderModifiers aggregates and formats the modifiers for the currently selected item
@@ -234,10 +242,20 @@ func (m *sidebarModel) getModifiers() []modifier {
 		modifiers = append(modifiers, modifier{label: "First Seen", value: m.Data.GetFirstSeen(relativeTime), delta: m.Data.FirstSeenScore})
 	}
 
+	// results that haven't been seen recently in rolling datasets decay when score_decay_half_life_hours is set
+	if m.Data.CurrentScore < m.Data.FinalScore {
+		modifiers = append(modifiers, modifier{label: "Score Decay", value: fmt.Sprintf("Current score %1.2f%%", m.Data.CurrentScore*100), delta: m.Data.CurrentScore - m.Data.FinalScore})
+	}
+
 	if m.Data.MissingHostCount > 0 {
 		modifiers = append(modifiers, modifier{label: "Missing Host Header", value: fmt.Sprintf("Was missing host %dx", m.Data.MissingHostCount), delta: m.Data.MissingHostHeaderScore})
 	}
 
+	// connections whose responder byte counts were unset by zeek don't change the score, but can skew the datasize score
+	if m.Data.MissingBytesCount > 0 {
+		modifiers = append(modifiers, modifier{label: "Missing Resp Bytes", value: fmt.Sprintf("Was missing bytes %dx", m.Data.MissingBytesCount), delta: 0})
+	}
+
 	if m.Data.ThreatIntelDataSizeScore != 0 {
 		var label string
 		if m.Data.ThreatIntelDataSizeScore > 0 {
@@ -258,6 +276,35 @@ func (m *sidebarModel) getModifiers() []modifier {
 			modifiers = append(modifiers, modifier{label: "Rare Signature", value: mod["modifier_value"], delta: 10})
 		case "mime_type_mismatch":
 			modifiers = append(modifiers, modifier{label: "MIME Type Mismatch", value: "", delta: 10})
+		case "rdp_fan_out":
+			modifiers = append(modifiers, modifier{label: "RDP Fan Out", value: fmt.Sprintf("RDP to %s internal hosts", mod["modifier_value"]), delta: 10})
+		case "failed_conn":
+			modifiers = append(modifiers, modifier{label: "Failed Connections", value: fmt.Sprintf("%s rejected or half-open", mod["modifier_value"]), delta: 10})
+		case "dga_nxdomain":
+			modifiers = append(modifiers, modifier{label: "DGA NXDOMAIN", value: fmt.Sprintf("%s of DNS queries failed", mod["modifier_value"]), delta: 10})
+		case "dns_subdomain_entropy":
+			modifiers = append(modifiers, modifier{label: "Subdomain Entropy", value: fmt.Sprintf("%s bits per character", mod["modifier_value"]), delta: 10})
+		case "long_conn_sessions":
+			modifiers = append(modifiers, modifier{label: "Long Conn Sessions", value: mod["modifier_value"], delta: 10})
+		case "ftp_upload_volume":
+			modifiers = append(modifiers, modifier{label: "FTP Upload Volume", value: fmt.Sprintf("%s uploaded", mod["modifier_value"]), delta: 10})
+		case "ftp_scripted_upload":
+			modifiers = append(modifiers, modifier{label: "FTP Scripted Upload", value: fmt.Sprintf("%s uploads without a directory listing", mod["modifier_value"]), delta: 10})
+		case "suspicious_cert":
+			modifiers = append(modifiers, modifier{label: "Suspicious Cert", value: mod["modifier_value"], delta: 10})
+		case "kerberos_anomaly":
+			modifiers = append(modifiers, modifier{label: "Kerberos Anomaly", value: mod["modifier_value"], delta: 10})
+		case "ntlm_anomaly":
+			modifiers = append(modifiers, modifier{label: "NTLM Anomaly", value: mod["modifier_value"], delta: 10})
+		case "dns_flood":
+			modifiers = append(modifiers, modifier{label: "DNS Flood", value: fmt.Sprintf("%s queries for new domains skipped", mod["modifier_value"]), delta: 10})
+		case "cname_chain":
+			modifiers = append(modifiers, modifier{label: "CNAME Chain", value: fmt.Sprintf("Resolved through %s CNAMEs", mod["modifier_value"]), delta: 10})
+		case "adaptive_beacon":
+			modifiers = append(modifiers, modifier{label: "Adaptive Beacon", value: fmt.Sprintf("Interval shifted from %s", mod["modifier_value"]), delta: 15})
+		default:
+			// modifiers registered by other packages are shown by name, their score isn't known here
+			modifiers = append(modifiers, modifier{label: mod["modifier_name"], value: mod["modifier_value"], delta: 0})
 		}
 	}
 